@@ -11,6 +11,9 @@ require (
 	github.com/goki/gi v1.3.21
 	github.com/goki/ki v1.1.15
 	github.com/goki/mat32 v1.0.15
+	github.com/mattn/go-sqlite3 v1.14.16
+	gonum.org/v1/gonum v0.12.0
+	gonum.org/v1/plot v0.12.0
 )
 
 require (
@@ -63,6 +66,4 @@ require (
 	golang.org/x/text v0.8.0 // indirect
 	golang.org/x/tools v0.7.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
-	gonum.org/v1/gonum v0.12.0 // indirect
-	gonum.org/v1/plot v0.12.0 // indirect
 )
@@ -0,0 +1,175 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// ActExport saves the ActM of the Layers on each test trial, for offline
+// analyses without modifying the sim: each test epoch is saved to a
+// compressed numpy .npz archive, with an entry per trial and layer named
+// t<trial>_<layer>, and an index .tsv file of the same name, with a row per
+// trial of its position, heading and action.  The archive can be read with
+// numpy.load, and the index with pandas.read_csv(sep="\t").
+type ActExport struct {
+	On     bool     `desc:"save the ActM of the Layers on each test trial"`
+	Layers []string `desc:"layers whose ActM is saved -- empty = the ARFLayers"`
+
+	Epoch int           `inactive:"+" desc:"test epoch of the open archive"`
+	File  *os.File      `view:"-" desc:"the open archive file"`
+	Zip   *zip.Writer   `view:"-" desc:"zip writer of the archive"`
+	Index *etable.Table `view:"-" desc:"index of the trials of the open archive"`
+	Fnm   string        `view:"-" desc:"file name of the index of the open archive"`
+}
+
+// ActExportLayers returns the layers saved by ActExport
+func (ss *Sim) ActExportLayers() []string {
+	if len(ss.ActExport.Layers) > 0 {
+		return ss.ActExport.Layers
+	}
+	return ss.ARFLayers
+}
+
+// WriteNpy writes given tensor to w in the numpy .npy format, as float32
+func WriteNpy(w io.Writer, tsr *etensor.Float32) error {
+	shp := make([]string, tsr.NumDims())
+	for i := range shp {
+		shp[i] = strconv.Itoa(tsr.Dim(i))
+	}
+	tup := strings.Join(shp, ", ")
+	if len(shp) == 1 {
+		tup += ","
+	}
+	hdr := fmt.Sprintf("{'descr': '<f4', 'fortran_order': False, 'shape': (%s), }", tup)
+	pad := 64 - (10+len(hdr)+1)%64
+	if pad == 64 {
+		pad = 0
+	}
+	hdr += strings.Repeat(" ", pad) + "\n"
+	var b bytes.Buffer
+	b.WriteString("\x93NUMPY")
+	b.Write([]byte{1, 0})
+	binary.Write(&b, binary.LittleEndian, uint16(len(hdr)))
+	b.WriteString(hdr)
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, tsr.Values)
+}
+
+// ActExportOpen opens the archive and index of the current test epoch
+func (ss *Sim) ActExportOpen() error {
+	ae := &ss.ActExport
+	ae.Epoch = ss.TestEnv.Epoch.Cur
+	ae.Fnm = ss.LogFileName(fmt.Sprintf("acts_run%03d_epc%03d", ss.TrainEnv.Run.Cur, ae.Epoch))
+	f, err := os.Create(strings.TrimSuffix(ae.Fnm, ".tsv") + ".npz")
+	if err != nil {
+		return err
+	}
+	ae.File = f
+	ae.Zip = zip.NewWriter(f)
+	ae.Index = &etable.Table{}
+	ss.ConfigActExportIndex(ae.Index)
+	return nil
+}
+
+// ActExportClose closes the open archive, if any, saving its index --
+// called at the end of each test epoch, of testing, and of a run
+func (ss *Sim) ActExportClose() {
+	ae := &ss.ActExport
+	if ae.File == nil {
+		return
+	}
+	if err := ae.Zip.Close(); err != nil {
+		log.Println(err)
+	}
+	if err := ae.File.Close(); err != nil {
+		log.Println(err)
+	}
+	ae.Index.SaveCSV(gi.FileName(ae.Fnm), etable.Tab, etable.Headers)
+	ae.File = nil
+	ae.Zip = nil
+	ae.Index = nil
+}
+
+// ActExportTrial saves the ActM of the ActExport layers on the current test
+// trial, opening the archive of a new test epoch as needed -- called in
+// TestTrial
+func (ss *Sim) ActExportTrial() {
+	ae := &ss.ActExport
+	if !ae.On {
+		return
+	}
+	if ae.File != nil && ae.Epoch != ss.TestEnv.Epoch.Cur {
+		ss.ActExportClose()
+	}
+	if ae.File == nil {
+		if err := ss.ActExportOpen(); err != nil {
+			log.Printf("ActExport: %v\n", err)
+			ae.On = false
+			return
+		}
+	}
+	dt := ae.Index
+	trl := dt.Rows
+	for _, lnm := range ss.ActExportLayers() {
+		ly := ss.Net.LayerByName(lnm)
+		if ly == nil {
+			continue
+		}
+		vt := ss.ValsTsr(lnm)
+		ly.UnitValsTensor(vt, "ActM")
+		w, err := ae.Zip.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("t%05d_%s.npy", trl, lnm), Method: zip.Deflate})
+		if err == nil {
+			err = WriteNpy(w, vt)
+		}
+		if err != nil {
+			log.Printf("ActExport: %v\n", err)
+			ss.ActExportClose()
+			ae.On = false
+			return
+		}
+	}
+	ev := &ss.TestEnv
+	dt.SetNumRows(trl + 1)
+	dt.SetCellFloat("Trial", trl, float64(trl))
+	dt.SetCellFloat("Event", trl, float64(ev.Event.Cur))
+	dt.SetCellFloat("X", trl, float64(ev.PosI.X))
+	dt.SetCellFloat("Y", trl, float64(ev.PosI.Y))
+	dt.SetCellFloat("Angle", trl, float64(ev.Angle))
+	dt.SetCellString("ActAction", trl, ss.ActAction)
+	dt.SetCellFloat("CosDiff", trl, ss.TrlCosDiff)
+}
+
+func (ss *Sim) ConfigActExportIndex(dt *etable.Table) {
+	dt.SetMetaData("name", "ActExportIndex")
+	dt.SetMetaData("desc", "Index of the test trials of an ActM archive, whose entries are named t<Trial>_<layer>")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Trial", etensor.INT64, nil, nil},
+		{"Event", etensor.INT64, nil, nil},
+		{"X", etensor.INT64, nil, nil},
+		{"Y", etensor.INT64, nil, nil},
+		{"Angle", etensor.INT64, nil, nil},
+		{"ActAction", etensor.STRING, nil, nil},
+		{"CosDiff", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
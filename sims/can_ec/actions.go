@@ -0,0 +1,129 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/goki/ki/kit"
+)
+
+// Actions are the discrete action types available to the agent
+type Actions int32
+
+//go:generate stringer -type=PosDecodeMethods,EncoderTypes,Actions,WorldPresets,ClampPhases -output stringer.go
+
+var KiT_Actions = kit.Enums.AddEnum(ActionsN, false, nil)
+
+// The actions avail -- names are also used as the keys for action patterns in env Pats
+const (
+	// Left rotates left (counter-clockwise) by the turn angle, then steps forward
+	Left Actions = iota
+
+	// Right rotates right (clockwise) by the turn angle, then steps forward
+	Right
+
+	// Forward steps forward in the current heading, unless blocked by a barrier
+	Forward
+
+	ActionsN
+)
+
+// ActionNames returns the names of all the actions, in order
+func ActionNames() []string {
+	nms := make([]string, ActionsN)
+	for i := range nms {
+		nms[i] = Actions(i).String()
+	}
+	return nms
+}
+
+// ActionFromString returns the action with given name, and false if not found
+func ActionFromString(nm string) (Actions, bool) {
+	for a := Actions(0); a < ActionsN; a++ {
+		if a.String() == nm {
+			return a, true
+		}
+	}
+	return ActionsN, false
+}
+
+// Action is a motor command: a discrete action type with optional continuous
+// parameters -- zero-valued parameters use the env defaults, so the discrete
+// actions are just Action{Act: act}.
+type Action struct {
+	Act  Actions `desc:"discrete action type"`
+	Turn float32 `desc:"turn angle in degrees for Left / Right -- 0 = env default (AngInc)"`
+	Step float32 `desc:"step length in grid cells -- 0 = env default (1), NoStep = in place"`
+}
+
+// NoStep is the Step of an action that does not step: a turn in place for
+// Left / Right, and a pause in place for Forward, e.g., in head-scan bouts
+const NoStep float32 = -1
+
+// NewAction returns a discrete action with default parameters
+func NewAction(act Actions) Action {
+	return Action{Act: act}
+}
+
+// IsDiscrete returns true if the action uses default parameters
+func (ac Action) IsDiscrete() bool {
+	return ac.Turn == 0 && ac.Step == 0
+}
+
+func (ac Action) String() string {
+	if ac.IsDiscrete() {
+		return ac.Act.String()
+	}
+	return fmt.Sprintf("%s_%g_%g", ac.Act, ac.Turn, ac.Step)
+}
+
+// MotorNoise parameterizes noise in the execution of action commands,
+// so that the executed action can differ from the commanded one
+type MotorNoise struct {
+	On     bool    `desc:"apply motor noise"`
+	PFail  float32 `viewif:"On" def:"0.05" desc:"probability that the executed action is a different, randomly chosen, action than the commanded one"`
+	TurnSD float32 `viewif:"On" desc:"standard deviation of gaussian noise added to the turn angle of Left / Right actions, in degrees"`
+	StepSD float32 `viewif:"On" desc:"standard deviation of gaussian noise added to the step length, in grid cells"`
+}
+
+func (mn *MotorNoise) Defaults() {
+	mn.PFail = 0.05
+}
+
+// Apply returns the executed action for given commanded action, with noise applied
+// if On.  defTurn is the default turn angle, used for noise on discrete turns.
+func (mn *MotorNoise) Apply(ac Action, defTurn float32) Action {
+	if !mn.On {
+		return ac
+	}
+	ex := ac
+	if mn.PFail > 0 && rand.Float32() < mn.PFail {
+		ex.Act = Actions(rand.Intn(int(ActionsN) - 1))
+		if ex.Act >= ac.Act { // skip the commanded one
+			ex.Act++
+		}
+	}
+	if mn.TurnSD > 0 && ex.Act != Forward {
+		if ex.Turn == 0 {
+			ex.Turn = defTurn
+		}
+		ex.Turn += mn.TurnSD * float32(rand.NormFloat64())
+		if ex.Turn == 0 { // 0 = default
+			ex.Turn = 0.001
+		}
+	}
+	if mn.StepSD > 0 && ex.Step != NoStep {
+		if ex.Step == 0 {
+			ex.Step = 1
+		}
+		ex.Step += mn.StepSD * float32(rand.NormFloat64())
+		if ex.Step <= 0 {
+			ex.Step = 0.001
+		}
+	}
+	return ex
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/emer/etable/agg"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/metric"
+	"github.com/emer/etable/split"
+	"github.com/emer/leabra/leabra"
+)
+
+// The Action target layer (ActOn) decodes the action of the last step of
+// the trial from EC, as the cortical action decoding of ffpred and emery1,
+// so behavior-level performance is comparable across the sims: NetAction is
+// the action pattern closest to the Action layer minus-phase activity,
+// GenAction is the discrete action executed by the env on the last step,
+// which is rendered as the Action target, and ActMatch is 1 if they match.  The
+// epoch logs have the mean ActMatch, and the per-action accuracy as
+// <Act>Cor, from the trials grouped by GenAction.
+
+// DecodeAct returns the name of the action with the pattern of highest
+// correlation to the Action layer ActM state
+func (ss *Sim) DecodeAct(ev *XYHDEnv) string {
+	ly := ss.Net.LayerByName("Action").(leabra.LeabraLayer).AsLeabra()
+	vt := ss.ValsTsr("Action")
+	ly.UnitValsTensor(vt, "ActM")
+
+	cnm := ""
+	dst := float32(0)
+	for _, nm := range ev.Acts {
+		pat, ok := ev.Pats[nm]
+		if !ok {
+			continue
+		}
+		d := metric.Correlation32(vt.Values, pat.Values)
+		if cnm == "" || d > dst {
+			cnm = nm
+			dst = d
+		}
+	}
+	return cnm
+}
+
+// ActMatchTrial computes the NetAction, GenAction and ActMatch of the
+// current trial, if ActOn
+func (ss *Sim) ActMatchTrial(ev *XYHDEnv) {
+	if !ss.ActOn {
+		return
+	}
+	ss.NetAction = ss.DecodeAct(ev)
+	ss.GenAction = ev.ActExec.Act.String()
+	ss.ActMatch = 0
+	if ss.NetAction == ss.GenAction {
+		ss.ActMatch = 1
+	}
+}
+
+// LogActMatchTrl computes the action stats of the current trial, and
+// records them in given row of a trial log, if ActOn -- called in LogTrnTrl
+// and LogTstTrl
+func (ss *Sim) LogActMatchTrl(dt *etable.Table, row int, ev *XYHDEnv) {
+	if !ss.ActOn {
+		return
+	}
+	ss.ActMatchTrial(ev)
+	dt.SetCellString("NetAction", row, ss.NetAction)
+	dt.SetCellString("GenAction", row, ss.GenAction)
+	dt.SetCellFloat("ActMatch", row, ss.ActMatch)
+}
+
+// LogActMatchEpc records the mean ActMatch, and the per-action accuracy, of
+// the trials in trlix in given row of the TrnEpcLog, if ActOn
+func (ss *Sim) LogActMatchEpc(dt *etable.Table, row int, trlix *etable.IdxView) {
+	if !ss.ActOn {
+		return
+	}
+	dt.SetCellFloat("ActMatch", row, agg.Agg(trlix, "ActMatch", agg.AggMean)[0])
+
+	gpsp := split.GroupBy(trlix, []string{"GenAction"})
+	if _, err := split.AggTry(gpsp, "ActMatch", agg.AggMean); err != nil {
+		log.Println(err)
+		return
+	}
+	es := gpsp.AggsToTable(etable.ColNameOnly)
+	for _, lnm := range ss.TrainEnv.Acts {
+		rw := es.RowsByString("GenAction", lnm, etable.Equals, etable.UseCase)
+		if len(rw) > 0 {
+			dt.SetCellFloat(lnm+"Cor", row, es.CellFloat("ActMatch", rw[0]))
+		}
+	}
+}
+
+// ConfigActMatchTrlCols adds the action stats columns to given trial log
+// schema, if ActOn
+func (ss *Sim) ConfigActMatchTrlCols(sch etable.Schema) etable.Schema {
+	if !ss.ActOn {
+		return sch
+	}
+	sch = append(sch, etable.Column{"NetAction", etensor.STRING, nil, nil})
+	sch = append(sch, etable.Column{"GenAction", etensor.STRING, nil, nil})
+	sch = append(sch, etable.Column{"ActMatch", etensor.FLOAT64, nil, nil})
+	return sch
+}
+
+// ConfigActMatchEpcCols adds the ActMatch and per-action <Act>Cor columns
+// to given TrnEpcLog schema, if ActOn
+func (ss *Sim) ConfigActMatchEpcCols(sch etable.Schema) etable.Schema {
+	if !ss.ActOn {
+		return sch
+	}
+	sch = append(sch, etable.Column{"ActMatch", etensor.FLOAT64, nil, nil})
+	for _, lnm := range ss.TrainEnv.Acts {
+		sch = append(sch, etable.Column{lnm + "Cor", etensor.FLOAT64, nil, nil})
+	}
+	return sch
+}
+
+// ConfigActMatchTrlPlot sets the trial plot params for the action stats
+func ConfigActMatchTrlPlot(plt *eplot.Plot2D) {
+	plt.SetColParams("NetAction", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("GenAction", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("ActMatch", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+}
+
+// ConfigActMatchEpcPlot sets the TrnEpcPlot params for the action stats
+func (ss *Sim) ConfigActMatchEpcPlot(plt *eplot.Plot2D) {
+	plt.SetColParams("ActMatch", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	for _, lnm := range ss.TrainEnv.Acts {
+		plt.SetColParams(lnm+"Cor", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// ActStep is one action executed by TakeAction, with the position it was
+// executed from, and whether it moved the agent
+type ActStep struct {
+	Act   Actions    `desc:"action executed"`
+	Pos   evec.Vec2i `desc:"position the action was executed from"`
+	Moved bool       `desc:"whether the action changed the position"`
+	Dist  float32    `desc:"distance moved by the action, in continuous coordinates"`
+	Bout  string     `desc:"scan or pause bout the action was part of, if any"`
+}
+
+// ActStats accumulates the distribution of actions executed during training,
+// per epoch and per spatial region, logged to the ActLog and ActRegLog at the
+// end of each epoch, to detect degenerate policies, e.g., spinning in place,
+// that silently corrupt the training data.  A warning is printed when any
+// action exceeds MaxFrac of the actions in an epoch.
+type ActStats struct {
+	MaxFrac float64    `def:"0.8" min:"0" max:"1" desc:"warn when any action is more than this fraction of the actions in an epoch -- 0 = no warning"`
+	Regions evec.Vec2i `desc:"number of spatial regions along X and Y of the world, for the per-region action distributions"`
+
+	n      []int // counts per action
+	regN   []int // counts per region and action
+	nStill int   // steps that did not move
+}
+
+// Defaults sets default params
+func (as *ActStats) Defaults() {
+	as.MaxFrac = 0.8
+	as.Regions.Set(3, 3)
+}
+
+// Reset resets the accumulated counts
+func (as *ActStats) Reset() {
+	as.n = make([]int, ActionsN)
+	as.regN = make([]int, as.Regions.X*as.Regions.Y*int(ActionsN))
+	as.nStill = 0
+}
+
+// Region returns the region index of given position in a world of given size
+func (as *ActStats) Region(pos, size evec.Vec2i) int {
+	rx := (pos.X * as.Regions.X) / size.X
+	ry := (pos.Y * as.Regions.Y) / size.Y
+	if rx < 0 {
+		rx = 0
+	} else if rx >= as.Regions.X {
+		rx = as.Regions.X - 1
+	}
+	if ry < 0 {
+		ry = 0
+	} else if ry >= as.Regions.Y {
+		ry = as.Regions.Y - 1
+	}
+	return ry*as.Regions.X + rx
+}
+
+// AddTrial adds the actions of a trial, in a world of given size
+func (as *ActStats) AddTrial(steps []ActStep, size evec.Vec2i) {
+	if len(as.regN) != as.Regions.X*as.Regions.Y*int(ActionsN) {
+		as.Reset()
+	}
+	for _, st := range steps {
+		as.n[st.Act]++
+		as.regN[as.Region(st.Pos, size)*int(ActionsN)+int(st.Act)]++
+		if !st.Moved {
+			as.nStill++
+		}
+	}
+}
+
+// LogActs adds a row for the accumulated action distribution of given epoch
+// to dt, and a row for each region to rdt, warning if any action exceeds
+// MaxFrac, and resets the counts -- called in LogTrnEpc
+func (ss *Sim) LogActs(dt, rdt *etable.Table, epc int) {
+	as := &ss.ActStats
+	if len(as.n) == 0 {
+		return
+	}
+	nms := ActionNames()
+	tot := 0
+	for _, n := range as.n {
+		tot += n
+	}
+	if tot == 0 {
+		return
+	}
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(epc))
+	dt.SetCellFloat("N", row, float64(tot))
+	dt.SetCellFloat("Still", row, float64(as.nStill)/float64(tot))
+	mx := 0.0
+	mxa := 0
+	for ai, n := range as.n {
+		fr := float64(n) / float64(tot)
+		dt.SetCellFloat(nms[ai], row, fr)
+		if fr > mx {
+			mx = fr
+			mxa = ai
+		}
+	}
+	dt.SetCellFloat("MaxFrac", row, mx)
+	ss.SQLWriteRow("acts", dt, row)
+	if as.MaxFrac > 0 && mx > as.MaxFrac {
+		log.Printf("ActStats: run %d epoch %d: %s is %.3f of actions, more than MaxFrac %g -- degenerate policy?\n", ss.TrainEnv.Run.Cur, epc, nms[mxa], mx, as.MaxFrac)
+	}
+
+	nr := as.Regions.X * as.Regions.Y
+	for ri := 0; ri < nr; ri++ {
+		rn := as.regN[ri*int(ActionsN) : (ri+1)*int(ActionsN)]
+		rtot := 0
+		for _, n := range rn {
+			rtot += n
+		}
+		row := rdt.Rows
+		rdt.SetNumRows(row + 1)
+		rdt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+		rdt.SetCellFloat("Epoch", row, float64(epc))
+		rdt.SetCellFloat("Region", row, float64(ri))
+		rdt.SetCellFloat("X", row, float64(ri%as.Regions.X))
+		rdt.SetCellFloat("Y", row, float64(ri/as.Regions.X))
+		rdt.SetCellFloat("N", row, float64(rtot))
+		for ai, n := range rn {
+			if rtot > 0 {
+				rdt.SetCellFloat(nms[ai], row, float64(n)/float64(rtot))
+			}
+		}
+		ss.SQLWriteRow("act_reg", rdt, row)
+	}
+	as.Reset()
+
+	ss.UpdtPlot(ss.ActPlot)
+}
+
+func (ss *Sim) ConfigActLog(dt *etable.Table) {
+	dt.SetMetaData("name", "ActLog")
+	dt.SetMetaData("desc", "Distribution of actions executed per training epoch")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"N", etensor.INT64, nil, nil},
+		{"Still", etensor.FLOAT64, nil, nil},
+	}
+	for _, nm := range ActionNames() {
+		sch = append(sch, etable.Column{nm, etensor.FLOAT64, nil, nil})
+	}
+	sch = append(sch, etable.Column{"MaxFrac", etensor.FLOAT64, nil, nil})
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigActRegLog(dt *etable.Table) {
+	dt.SetMetaData("name", "ActRegLog")
+	dt.SetMetaData("desc", "Distribution of actions executed per training epoch, in each spatial region")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Region", etensor.INT64, nil, nil},
+		{"X", etensor.INT64, nil, nil},
+		{"Y", etensor.INT64, nil, nil},
+		{"N", etensor.INT64, nil, nil},
+	}
+	for _, nm := range ActionNames() {
+		sch = append(sch, etable.Column{nm, etensor.FLOAT64, nil, nil})
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigActPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Action Distribution Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("N", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Still", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	for _, nm := range ActionNames() {
+		plt.SetColParams(nm, eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	}
+	plt.SetColParams("MaxFrac", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+	"github.com/goki/mat32"
+)
+
+// Adapt adapts the difficulty of the training environment to the behavior
+// of the network, for curriculum experiments driven by performance rather
+// than fixed epoch milestones: every Window epochs, the mean position
+// decoding error (PosErr) over the last Window epochs is compared to the
+// target band from LoErr to HiErr, and the difficulty Level is raised by
+// Step below the band and lowered above it.  The Level sets the obstacle
+// density, as the number of interior walls of RandWorld worlds, from
+// MinWalls to MaxWalls, with a new random world generated when it changes,
+// and the cue reliability, as the probability, up to MaxDrop, that the
+// allothetic OcclLayers inputs are blanked on a trial.  The AdaptLog has
+// one row per epoch.
+type Adapt struct {
+	On       bool    `desc:"adapt the environment difficulty to the position decoding error"`
+	LoErr    float64 `def:"1" desc:"low end of the target band of PosErr, in world units -- difficulty is raised below it"`
+	HiErr    float64 `def:"2" desc:"high end of the target band of PosErr, in world units -- difficulty is lowered above it"`
+	Window   int     `def:"5" min:"1" desc:"number of epochs that PosErr is averaged over, and between changes of the difficulty"`
+	Step     float32 `def:"0.1" min:"0" max:"1" desc:"change of the difficulty Level at each adaptation"`
+	Start    float32 `def:"0" min:"0" max:"1" desc:"difficulty Level at the start of each run"`
+	MinWalls int     `def:"0" min:"0" desc:"number of interior walls at Level 0 -- only with RandWorld"`
+	MaxWalls int     `def:"12" min:"0" desc:"number of interior walls at Level 1 -- only with RandWorld"`
+	MaxDrop  float32 `def:"0.5" min:"0" max:"1" desc:"probability of blanking the allothetic cue inputs on a trial at Level 1"`
+
+	Level float32                     `inactive:"+" desc:"current difficulty, from 0 (easiest) to 1"`
+	Last  int                         `inactive:"+" desc:"epoch of the last adaptation"`
+	Drop  bool                        `inactive:"+" desc:"the allothetic cue inputs are blanked on the current trial"`
+	Pats  map[string]*etensor.Float32 `view:"-" desc:"blank input patterns, by layer"`
+}
+
+// Defaults sets default params
+func (ad *Adapt) Defaults() {
+	ad.LoErr = 1
+	ad.HiErr = 2
+	ad.Window = 5
+	ad.Step = 0.1
+	ad.Start = 0
+	ad.MinWalls = 0
+	ad.MaxWalls = 12
+	ad.MaxDrop = 0.5
+}
+
+// Reset resets all state -- called at start of a new run
+func (ad *Adapt) Reset() {
+	ad.Level = ad.Start
+	ad.Last = 0
+	ad.Drop = false
+}
+
+// NWalls returns the number of interior walls for the current Level
+func (ad *Adapt) NWalls() int {
+	return ad.MinWalls + int(mat32.Round(ad.Level*float32(ad.MaxWalls-ad.MinWalls)))
+}
+
+// DropP returns the probability of blanking the cue inputs for the current Level
+func (ad *Adapt) DropP() float32 {
+	return ad.Level * ad.MaxDrop
+}
+
+// Update updates the Level for given mean error, returning the change:
+// +1 if raised, -1 if lowered, and 0 if within the target band, or at the
+// limit
+func (ad *Adapt) Update(err float64) int {
+	lev := ad.Level
+	switch {
+	case err < ad.LoErr:
+		lev += ad.Step
+	case err > ad.HiErr:
+		lev -= ad.Step
+	}
+	lev = mat32.Clamp(lev, 0, 1)
+	chg := 0
+	if lev > ad.Level {
+		chg = 1
+	} else if lev < ad.Level {
+		chg = -1
+	}
+	ad.Level = lev
+	return chg
+}
+
+// InitAdapt starts the difficulty at the Start Level, setting the number of
+// walls of the RandWorld world of the run -- called in NewRun, before
+// NewRandWorld
+func (ss *Sim) InitAdapt() {
+	ad := &ss.Adapt
+	ad.Reset()
+	if ad.On && ss.RandWorld.On {
+		ss.RandWorld.NWalls = ad.NWalls()
+	}
+}
+
+// AdaptErr returns the mean PosErr over the last Window epochs of the
+// current run in the TrnEpcLog, and the number of epochs averaged
+func (ss *Sim) AdaptErr() (float64, int) {
+	dt := ss.TrnEpcLog
+	run := float64(ss.TrainEnv.Run.Cur)
+	sum, n := 0.0, 0
+	for row := dt.Rows - 1; row >= 0 && n < ss.Adapt.Window; row-- {
+		if dt.CellFloat("Run", row) != run {
+			break
+		}
+		sum += dt.CellFloat("PosErr", row)
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return sum / float64(n), n
+}
+
+// AdaptEpoch adapts the difficulty to the recent PosErr every Window epochs,
+// logging both for the last epoch, and generating a new RandWorld world if
+// the number of walls changed -- called in TrainEnvStep when the epoch
+// changes, after LogTrnEpc
+func (ss *Sim) AdaptEpoch(epc int) {
+	ad := &ss.Adapt
+	if !ad.On {
+		return
+	}
+	err, n := ss.AdaptErr()
+	chg := 0
+	if n >= ad.Window && epc-ad.Last >= ad.Window {
+		chg = ad.Update(err)
+		ad.Last = epc
+	}
+	ss.LogAdapt(ss.AdaptLog, ss.TrainEnv.Epoch.Prv, err, chg)
+	rw := &ss.RandWorld
+	if chg == 0 || !rw.On || ad.NWalls() == rw.NWalls {
+		return
+	}
+	rw.NWalls = ad.NWalls()
+	ev := &ss.TrainEnv
+	ev.GenRandWorld(rw.NWalls, rw.MaxLen, rand.New(rand.NewSource(rand.Int63())))
+	ev.MoveToStart()
+	ev.SaveWorld(gi.FileName(ss.LogFileName(fmt.Sprintf("world_run%03d_epc%04d", ev.Run.Cur, epc))))
+	log.Printf("Adapt: run %d epoch %d: PosErr %.3g: level %.2g: new world with %d walls\n", ev.Run.Cur, epc, err, ad.Level, rw.NWalls)
+}
+
+// AdaptTrial sets whether the cue inputs are blanked on the current trial,
+// with the DropP of the current Level -- called at the start of ApplyInputs
+func (ss *Sim) AdaptTrial() {
+	ad := &ss.Adapt
+	ad.Drop = ad.On && ad.MaxDrop > 0 && rand.Float32() < ad.DropP()
+}
+
+// AdaptPats returns the input pattern to apply to given layer: a blank
+// pattern if the cue inputs are blanked on the current trial and the layer
+// is one of the OcclLayers, and pats unchanged otherwise -- called in
+// ApplyStatePats
+func (ss *Sim) AdaptPats(lnm string, pats etensor.Tensor) etensor.Tensor {
+	ad := &ss.Adapt
+	if !ad.Drop {
+		return pats
+	}
+	cue := false
+	for _, l := range OcclLayers {
+		if l == lnm {
+			cue = true
+			break
+		}
+	}
+	if !cue {
+		return pats
+	}
+	if ad.Pats == nil {
+		ad.Pats = make(map[string]*etensor.Float32)
+	}
+	bp, has := ad.Pats[lnm]
+	if !has {
+		bp = etensor.NewFloat32(pats.Shapes(), nil, nil)
+		ad.Pats[lnm] = bp
+	}
+	return bp
+}
+
+// LogAdapt adds a row for given epoch, with the recent mean error, and the
+// difficulty adapted to it, for the next epochs
+func (ss *Sim) LogAdapt(dt *etable.Table, epc int, err float64, chg int) {
+	ad := &ss.Adapt
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(epc))
+	dt.SetCellFloat("PosErr", row, err)
+	dt.SetCellFloat("Level", row, float64(ad.Level))
+	dt.SetCellFloat("Change", row, float64(chg))
+	dt.SetCellFloat("DropP", row, float64(ad.DropP()))
+	nw := 0
+	if ss.RandWorld.On {
+		nw = ad.NWalls()
+	}
+	dt.SetCellFloat("NWalls", row, float64(nw))
+	ss.SQLWriteRow("adapt", dt, row)
+
+	ss.UpdtPlot(ss.AdaptPlot)
+}
+
+func (ss *Sim) ConfigAdaptLog(dt *etable.Table) {
+	dt.SetMetaData("name", "AdaptLog")
+	dt.SetMetaData("desc", "Environment difficulty adapted to the recent position decoding error, per epoch")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"PosErr", etensor.FLOAT64, nil, nil},
+		{"Level", etensor.FLOAT64, nil, nil},
+		{"Change", etensor.INT64, nil, nil},
+		{"DropP", etensor.FLOAT64, nil, nil},
+		{"NWalls", etensor.INT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigAdaptPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Adaptive Difficulty Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PosErr", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Level", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Change", eplot.Off, eplot.FixMin, -1, eplot.FixMax, 1)
+	plt.SetColParams("DropP", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("NWalls", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	return plt
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/etview"
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/giv"
+)
+
+// data that analyses can require, checked by AnalysisDataOk
+const (
+	// AnalysisARFs requires accumulated activation-based receptive fields
+	AnalysisARFs = "ARFs"
+
+	// AnalysisSR requires the successor representation eigenvectors from -sr
+	AnalysisSR = "SR"
+
+	// AnalysisLinDec requires at least LinDec.K recorded test trials from -lindec
+	AnalysisLinDec = "LinDec"
+)
+
+// AnalysisOut is one output of an Analysis: a table or a tensor, which is
+// viewed in a dialog in the GUI, and saved to a log file named by Name
+// on the command line
+type AnalysisOut struct {
+	Name   string           `desc:"name of the output, used in the dialog title and log file name"`
+	Table  *etable.Table    `desc:"table output, if non-nil"`
+	Tensor *etensor.Float32 `desc:"tensor output, if non-nil"`
+}
+
+// Analysis is a named analysis of the network or its accumulated data, that
+// registers itself with AddAnalysis and is then available as a toolbar action,
+// and with the -analyze flag at the end of each run
+type Analysis struct {
+	Name  string                      `desc:"name of the analysis, as given to -analyze"`
+	Label string                      `desc:"label of the toolbar action"`
+	Desc  string                      `desc:"description, used as the toolbar tooltip"`
+	Needs []string                    `desc:"data required for the analysis, e.g., AnalysisARFs"`
+	Run   func(ss *Sim) []AnalysisOut `desc:"runs the analysis and returns its outputs"`
+}
+
+// Analyses is the registry of all analyses, in order added
+var Analyses []*Analysis
+
+// AddAnalysis adds an analysis to the registry -- called in init() of the
+// file that implements it
+func AddAnalysis(an *Analysis) {
+	Analyses = append(Analyses, an)
+}
+
+// AnalysisByName returns the registered analysis of given name, nil if none
+func AnalysisByName(nm string) *Analysis {
+	for _, an := range Analyses {
+		if an.Name == nm {
+			return an
+		}
+	}
+	return nil
+}
+
+// ParseAnalyses parses a comma-separated list of analysis names, as given
+// to -analyze, returning an error for any unknown names
+func ParseAnalyses(str string) ([]string, error) {
+	var nms []string
+	for _, nm := range strings.Split(str, ",") {
+		nm = strings.TrimSpace(nm)
+		if nm == "" {
+			continue
+		}
+		if AnalysisByName(nm) == nil {
+			var all []string
+			for _, an := range Analyses {
+				all = append(all, an.Name)
+			}
+			return nil, fmt.Errorf("analysis not found: %s -- available: %s", nm, strings.Join(all, ", "))
+		}
+		nms = append(nms, nm)
+	}
+	return nms, nil
+}
+
+// AnalysisDataOk returns true if the given required data is available
+func (ss *Sim) AnalysisDataOk(nd string) bool {
+	switch nd {
+	case AnalysisARFs:
+		return len(ss.ARFs.RFs) > 0
+	case AnalysisSR:
+		return ss.SR.On && len(ss.SR.Eigs) > 0
+	case AnalysisLinDec:
+		return ss.LinDec.Trajs.Rows >= ss.LinDec.K
+	}
+	return false
+}
+
+// AnalysisReady returns true if all the data required by the analysis is available
+func (ss *Sim) AnalysisReady(an *Analysis) bool {
+	for _, nd := range an.Needs {
+		if !ss.AnalysisDataOk(nd) {
+			return false
+		}
+	}
+	return true
+}
+
+// RunAnalysis runs the named analysis, and saves its outputs to log files
+// if save is true.  Returns the outputs.
+func (ss *Sim) RunAnalysis(nm string, save bool) ([]AnalysisOut, error) {
+	an := AnalysisByName(nm)
+	if an == nil {
+		return nil, fmt.Errorf("analysis not found: %s", nm)
+	}
+	if !ss.AnalysisReady(an) {
+		return nil, fmt.Errorf("analysis %s: required data not available: %s", nm, strings.Join(an.Needs, ", "))
+	}
+	outs := an.Run(ss)
+	if save {
+		ss.SaveAnalysisOuts(outs, "")
+	}
+	return outs, nil
+}
+
+// SaveAnalysisOuts saves analysis outputs to log files named by their Name,
+// with given prefix
+func (ss *Sim) SaveAnalysisOuts(outs []AnalysisOut, pfx string) {
+	for _, out := range outs {
+		fnm := gi.FileName(ss.LogFileName(pfx + out.Name))
+		switch {
+		case out.Table != nil:
+			out.Table.SaveCSV(fnm, etable.Tab, etable.Headers)
+		case out.Tensor != nil:
+			etensor.SaveCSV(out.Tensor, fnm, '\t')
+		}
+	}
+}
+
+// RunAnalyses runs and saves each of the Analyze analyses -- called in RunEnd
+func (ss *Sim) RunAnalyses() {
+	for _, nm := range ss.Analyze {
+		if _, err := ss.RunAnalysis(nm, true); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// ViewAnalysis runs the given analysis and views its outputs in dialogs
+func (ss *Sim) ViewAnalysis(an *Analysis, vp *gi.Viewport2D) {
+	outs, err := ss.RunAnalysis(an.Name, false)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, out := range outs {
+		opts := giv.DlgOpts{Title: an.Label + " " + out.Name, Prompt: an.Desc, TmpSave: nil}
+		switch {
+		case out.Table != nil:
+			etview.TableViewDialog(vp, out.Table, opts, nil, nil)
+		case out.Tensor != nil:
+			etview.TensorGridDialog(vp, out.Tensor, opts, nil, nil)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+
+	"github.com/emer/emergent/actrf"
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/kit"
+	"github.com/goki/mat32"
+)
+
+// ArenaXForms are the transforms of the whole arena (walls, start, goals and
+// choice point) relative to the global frame of the position and heading
+// inputs, for global remapping experiments
+type ArenaXForms int32
+
+var KiT_ArenaXForms = kit.Enums.AddEnum(ArenaXFormsN, false, nil)
+
+const (
+	// XFormNone is the original arena
+	XFormNone ArenaXForms = iota
+
+	// XFormRot90 rotates the arena 90 degrees counter-clockwise (toward
+	// increasing angles) about its center -- requires a square world
+	XFormRot90
+
+	// XFormRot180 rotates the arena 180 degrees about its center
+	XFormRot180
+
+	// XFormRot270 rotates the arena 270 degrees counter-clockwise about its
+	// center -- requires a square world
+	XFormRot270
+
+	// XFormMirrorX mirrors the arena left-right, across its vertical midline
+	XFormMirrorX
+
+	// XFormMirrorY mirrors the arena top-bottom, across its horizontal midline
+	XFormMirrorY
+
+	ArenaXFormsN
+)
+
+// FromString sets the transform from its name, with or without the XForm prefix
+func (i *ArenaXForms) FromString(s string) error {
+	for j := ArenaXForms(0); j < ArenaXFormsN; j++ {
+		if j.String() == s || j.String() == "XForm"+s {
+			*i = j
+			return nil
+		}
+	}
+	return fmt.Errorf("ArenaXForms: %q not found", s)
+}
+
+// Inv returns the inverse transform
+func (i ArenaXForms) Inv() ArenaXForms {
+	switch i {
+	case XFormRot90:
+		return XFormRot270
+	case XFormRot270:
+		return XFormRot90
+	}
+	return i
+}
+
+// Point returns the transformed position p, in a world of given size
+func (i ArenaXForms) Point(p mat32.Vec2, sz evec.Vec2i) mat32.Vec2 {
+	mx := float32(sz.X - 1)
+	my := float32(sz.Y - 1)
+	switch i {
+	case XFormRot90:
+		return mat32.Vec2{mx - p.Y, p.X}
+	case XFormRot180:
+		return mat32.Vec2{mx - p.X, my - p.Y}
+	case XFormRot270:
+		return mat32.Vec2{p.Y, my - p.X}
+	case XFormMirrorX:
+		return mat32.Vec2{mx - p.X, p.Y}
+	case XFormMirrorY:
+		return mat32.Vec2{p.X, my - p.Y}
+	}
+	return p
+}
+
+// Cell returns the transformed grid cell p, in a world of given size
+func (i ArenaXForms) Cell(p evec.Vec2i, sz evec.Vec2i) evec.Vec2i {
+	return evec.NewVec2iFmVec2Round(i.Point(p.ToVec2(), sz))
+}
+
+// Angle returns the transformed heading ang, in degrees, in the range 0-359
+func (i ArenaXForms) Angle(ang int) int {
+	switch i {
+	case XFormRot90:
+		ang += 90
+	case XFormRot180:
+		ang += 180
+	case XFormRot270:
+		ang += 270
+	case XFormMirrorX:
+		ang = 180 - ang
+	case XFormMirrorY:
+		ang = -ang
+	}
+	return ((ang % 360) + 360) % 360
+}
+
+// ArenaBlock is one block of training epochs with the arena transformed
+type ArenaBlock struct {
+	Epoch int    `desc:"training epoch at the start of which the block starts -- it lasts until the next block"`
+	XForm string `desc:"transform of the arena relative to its original layout: None, Rot90, Rot180, Rot270, MirrorX or MirrorY"`
+
+	Type ArenaXForms `json:"-" view:"-" desc:"parsed XForm"`
+}
+
+// Arena implements global remapping experiments, in which the whole arena is
+// rotated or mirrored relative to the global frame of the position and
+// heading inputs, over blocks of training epochs.  The agent moves with the
+// arena, so it stays in the same place relative to the walls.  The position
+// RFs of the ARFLayers are accumulated over the training trials of each
+// block, and at the end of each block the ArenaLog compares them with those
+// of the previous block, in the global frame (AlloCorr, high if the map stays
+// allocentric), and in the frame of the arena (ArenaCorr, high if the map
+// rotates with the arena), to probe which reference frame the map uses.
+type Arena struct {
+	Blocks []ArenaBlock `desc:"arena schedule -- if empty, the arena is never transformed and nothing is logged"`
+	File   string       `desc:"protocol file that Blocks were loaded from"`
+
+	Block int         `inactive:"+" desc:"index of current block, -1 = none"`
+	Cur   ArenaXForms `inactive:"+" desc:"current transform of the TrainEnv arena"`
+	N     int         `inactive:"+" desc:"number of trials accumulated in the RFs of the current block"`
+
+	Base           *etensor.Int     `view:"-" desc:"original world, saved when the arena is first transformed"`
+	BaseStart      evec.Vec2i       `view:"-" desc:"original Start location"`
+	BaseStartAngle int              `view:"-" desc:"original StartAngle"`
+	BaseGoals      []evec.Vec2i     `view:"-" desc:"original Goals"`
+	BaseChoicePt   evec.Vec2i       `view:"-" desc:"original ChoicePt"`
+	Pos            *etensor.Float32 `view:"-" desc:"one-hot map of the TrainEnv position, the source of the RFs"`
+	RFs            actrf.RFs        `view:"no-inline" desc:"position RFs of the ARFLayers over the training trials of the current block"`
+	PrvRFs         actrf.RFs        `view:"-" desc:"position RFs of the previous block"`
+	PrvN           int              `view:"-" desc:"number of trials accumulated in PrvRFs"`
+	PrvXForm       ArenaXForms      `view:"-" desc:"arena transform of the previous block"`
+}
+
+// BlockAt returns the index of the block for given training epoch, -1 = none
+// -- blocks last until the next one, as epoch-level blocks of CurBlock
+func (ar *Arena) BlockAt(epc int) int {
+	return CurBlock(len(ar.Blocks), epc, 0, 1, func(bi int) (int, int) { return ar.Blocks[bi].Epoch, 0 })
+}
+
+// SaveBase saves the current world layout of ev as the original one
+func (ar *Arena) SaveBase(ev *XYHDEnv) {
+	ar.Base = ev.World.Clone().(*etensor.Int)
+	ar.BaseStart = ev.Start
+	ar.BaseStartAngle = ev.StartAngle
+	ar.BaseGoals = append(ar.BaseGoals[:0], ev.Goals...)
+	ar.BaseChoicePt = ev.ChoicePt
+}
+
+// Apply sets the world layout of ev to the original one transformed by xf
+func (ar *Arena) Apply(ev *XYHDEnv, xf ArenaXForms) {
+	sz := ev.Size
+	for y := 0; y < sz.Y; y++ {
+		for x := 0; x < sz.X; x++ {
+			c := xf.Cell(evec.Vec2i{x, y}, sz)
+			ev.World.Set([]int{c.Y, c.X}, ar.Base.Value([]int{y, x}))
+		}
+	}
+	ev.Start = xf.Cell(ar.BaseStart, sz)
+	ev.StartAngle = xf.Angle(ar.BaseStartAngle)
+	ev.Goals = ev.Goals[:0]
+	for _, g := range ar.BaseGoals {
+		ev.Goals = append(ev.Goals, xf.Cell(g, sz))
+	}
+	ev.ChoicePt = xf.Cell(ar.BaseChoicePt, sz)
+}
+
+// Validate returns an error if transform xf can not be applied to ev
+func (ar *Arena) Validate(ev *XYHDEnv, xf ArenaXForms) error {
+	if (xf == XFormRot90 || xf == XFormRot270) && ev.Size.X != ev.Size.Y {
+		return fmt.Errorf("Arena: %s requires a square world, not %v", xf, ev.Size)
+	}
+	if xf.Angle(0)%ev.AngInc != 0 {
+		return fmt.Errorf("Arena: %s requires AngInc to divide %d, not %d", xf, xf.Angle(0), ev.AngInc)
+	}
+	return nil
+}
+
+// SetArena transforms the TrainEnv arena to xf, relative to the original
+// layout, moving the agent with it
+func (ss *Sim) SetArena(xf ArenaXForms) {
+	ar := &ss.Arena
+	ev := &ss.TrainEnv
+	if xf == ar.Cur {
+		return
+	}
+	if err := ar.Validate(ev, xf); err != nil {
+		log.Println(err)
+		return
+	}
+	if ar.Cur == XFormNone {
+		ar.SaveBase(ev)
+	}
+	inv := ar.Cur.Inv()
+	sz := ev.Size
+	ev.PosF = xf.Point(inv.Point(ev.PosF, sz), sz)
+	ev.PosI = evec.NewVec2iFmVec2Round(ev.PosF)
+	ev.PrevPosF = xf.Point(inv.Point(ev.PrevPosF, sz), sz)
+	ev.PrevPosI = evec.NewVec2iFmVec2Round(ev.PrevPosF)
+	ev.Angle = xf.Angle(inv.Angle(ev.Angle))
+	ev.PrevAngle = xf.Angle(inv.Angle(ev.PrevAngle))
+	ar.Apply(ev, xf)
+	ar.Cur = xf
+}
+
+// ResetArena restores the original arena, if transformed, and resets the
+// schedule and RFs -- called in NewRun, before the TrainEnv is initialized
+func (ss *Sim) ResetArena() {
+	ar := &ss.Arena
+	if ar.Cur != XFormNone && ar.Base != nil {
+		ar.Apply(&ss.TrainEnv, XFormNone)
+	}
+	ar.Cur = XFormNone
+	ar.Block = -1
+	ar.N = 0
+	ar.RFs = actrf.RFs{}
+	ar.PrvRFs = actrf.RFs{}
+	ar.PrvN = 0
+}
+
+// ArenaEpoch sets the arena of the block for given training epoch, logging
+// the RFs of the block that ended, and also logs them at the end of the run
+// -- called at the start of each epoch, and in NewRun for epoch 0
+func (ss *Sim) ArenaEpoch(epc int) {
+	ar := &ss.Arena
+	if len(ar.Blocks) == 0 {
+		return
+	}
+	bi := ar.BlockAt(epc)
+	end := epc >= ss.MaxEpcs
+	if bi == ar.Block && !end {
+		return
+	}
+	ss.LogArena(ss.ArenaLog, epc)
+	ar.PrvRFs, ar.RFs = ar.RFs, actrf.RFs{}
+	ar.PrvN, ar.N = ar.N, 0
+	ar.PrvXForm = ar.Cur
+	if end {
+		return
+	}
+	ar.Block = bi
+	xf := XFormNone
+	if bi >= 0 {
+		xf = ar.Blocks[bi].Type
+	}
+	ss.SetArena(xf)
+}
+
+// ArenaTrial accumulates the position RFs of the ARFLayers for the current
+// training trial -- called in LogTrnTrl
+func (ss *Sim) ArenaTrial() {
+	ar := &ss.Arena
+	if len(ar.Blocks) == 0 {
+		return
+	}
+	ev := &ss.TrainEnv
+	if ar.Pos == nil {
+		ar.Pos = &etensor.Float32{}
+	}
+	ar.Pos.CopyShapeFrom(ev.World)
+	ar.Pos.SetZeros()
+	ar.Pos.Set([]int{ev.PosI.Y, ev.PosI.X}, 1)
+	for _, lnm := range ss.ARFLayers {
+		ly := ss.Net.LayerByName(lnm)
+		if ly == nil {
+			continue
+		}
+		vt := ss.ValsTsr(lnm)
+		ly.UnitValsTensor(vt, "ActM")
+		if ar.RFs.RFByName(lnm) == nil {
+			ar.RFs.AddRF(lnm, vt, ar.Pos)
+		}
+		ar.RFs.Add(lnm, vt, ar.Pos, 0.01)
+	}
+	ar.N++
+}
+
+// ArenaCorrs returns the mean correlation across units of RF cur with prv in
+// the global frame (allo), and with prv in the frame of the arena (arena),
+// given the arena transform of cur and prv, and the proportion of units with
+// arena > allo.  Only cells visited in both blocks are compared.
+func ArenaCorrs(cur, prv *actrf.RF, xf, pxf ArenaXForms) (allo, arena, pct float64) {
+	aNy, aNx := cur.RF.Dim(0), cur.RF.Dim(1)
+	sz := evec.Vec2i{cur.RF.Dim(3), cur.RF.Dim(2)}
+	inv := xf.Inv()
+	n := 0
+	var ca, cp, pa []float64
+	for ay := 0; ay < aNy; ay++ {
+		for ax := 0; ax < aNx; ax++ {
+			ca, cp, pa = ca[:0], cp[:0], pa[:0]
+			for sy := 0; sy < sz.Y; sy++ {
+				for sx := 0; sx < sz.X; sx++ {
+					if cur.SumSrc.Value([]int{sy, sx}) == 0 {
+						continue
+					}
+					ac := pxf.Cell(inv.Cell(evec.Vec2i{sx, sy}, sz), sz)
+					if prv.SumSrc.Value([]int{sy, sx}) == 0 || prv.SumSrc.Value([]int{ac.Y, ac.X}) == 0 {
+						continue
+					}
+					ca = append(ca, float64(cur.RF.Value([]int{ay, ax, sy, sx})))
+					cp = append(cp, float64(prv.RF.Value([]int{ay, ax, sy, sx})))
+					pa = append(pa, float64(prv.RF.Value([]int{ay, ax, ac.Y, ac.X})))
+				}
+			}
+			al, aok := CorrelOk(ca, cp)
+			ar, rok := CorrelOk(ca, pa)
+			if !aok || !rok {
+				continue
+			}
+			allo += al
+			arena += ar
+			if ar > al {
+				pct++
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		allo /= float64(n)
+		arena /= float64(n)
+		pct /= float64(n)
+	}
+	return
+}
+
+// LogArena adds a row per ARF layer comparing the RFs of the current block
+// with those of the previous block, if both have trials -- called in
+// ArenaEpoch at the end of each block
+func (ss *Sim) LogArena(dt *etable.Table, epc int) {
+	ar := &ss.Arena
+	if ar.N == 0 || ar.PrvN == 0 {
+		return
+	}
+	ar.RFs.Avg()
+	ar.PrvRFs.Avg()
+	for _, cur := range ar.RFs.RFs {
+		prv := ar.PrvRFs.RFByName(cur.Name)
+		if prv == nil {
+			continue
+		}
+		allo, arena, pct := ArenaCorrs(cur, prv, ar.Cur, ar.PrvXForm)
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+		dt.SetCellFloat("Epoch", row, float64(epc))
+		dt.SetCellFloat("Block", row, float64(ar.Block))
+		dt.SetCellString("PrvXForm", row, ar.PrvXForm.String())
+		dt.SetCellString("XForm", row, ar.Cur.String())
+		dt.SetCellString("Layer", row, cur.Name)
+		dt.SetCellFloat("AlloCorr", row, allo)
+		dt.SetCellFloat("ArenaCorr", row, arena)
+		dt.SetCellFloat("PctArena", row, pct)
+		ss.SQLWriteRow("arena", dt, row)
+	}
+
+	ss.UpdtPlot(ss.ArenaPlot)
+}
+
+// OpenArena loads the arena schedule from a JSON protocol file with a list
+// of blocks, e.g.: [{"Epoch": 50, "XForm": "Rot90"}, {"Epoch": 60, "XForm": "None"}]
+func (ss *Sim) OpenArena(filename gi.FileName) error {
+	b, err := ioutil.ReadFile(string(filename))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	var blks []ArenaBlock
+	if err = json.Unmarshal(b, &blks); err != nil {
+		log.Println(err)
+		return err
+	}
+	for i := range blks {
+		if err = blks[i].Type.FromString(blks[i].XForm); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	ss.Arena.Blocks = blks
+	ss.Arena.File = string(filename)
+	return nil
+}
+
+func (ss *Sim) ConfigArenaLog(dt *etable.Table) {
+	dt.SetMetaData("name", "ArenaLog")
+	dt.SetMetaData("desc", "Position RF correlations with the previous arena block, in the global vs. the arena frame, per block and layer")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Block", etensor.INT64, nil, nil},
+		{"PrvXForm", etensor.STRING, nil, nil},
+		{"XForm", etensor.STRING, nil, nil},
+		{"Layer", etensor.STRING, nil, nil},
+		{"AlloCorr", etensor.FLOAT64, nil, nil},
+		{"ArenaCorr", etensor.FLOAT64, nil, nil},
+		{"PctArena", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigArenaPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Arena Remapping Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.Params.LegendCol = "Layer"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Block", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("AlloCorr", eplot.On, eplot.FixMin, -1, eplot.FixMax, 1)
+	plt.SetColParams("ArenaCorr", eplot.On, eplot.FixMin, -1, eplot.FixMax, 1)
+	plt.SetColParams("PctArena", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/etview"
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/giv"
+)
+
+// ARFDiff compares the activation-based receptive fields saved by SaveAllARFs
+// in two directories, A and B, e.g., lightened vs. dark testing, or pre vs.
+// post lesion.  Files are matched by ARF name, regardless of the run name,
+// so each directory must hold the ARFs of a single run.  For each ARF, the
+// per-unit difference map B - A is computed, along with the correlation,
+// mean absolute difference and peak shift of each unit's map, which are
+// summarized over units in the Summary table.
+type ARFDiff struct {
+	DirA    string             `desc:"directory of the A (reference) ARFs"`
+	DirB    string             `desc:"directory of the B (comparison) ARFs"`
+	Names   []string           `desc:"names of the ARFs found in both directories"`
+	Diffs   []*etensor.Float32 `view:"no-inline" desc:"difference maps B - A, in the same order as Names, with the same [aNy, aNx, sNy, sNx] shape as the ARFs"`
+	Units   *etable.Table      `view:"no-inline" desc:"stats of each unit of each ARF"`
+	Summary *etable.Table      `view:"no-inline" desc:"stats of each ARF, averaged over units"`
+}
+
+// ARFDir returns the directory of given path, which can be a saved ARF file
+func ARFDir(path string) string {
+	if strings.HasSuffix(path, ".tsv") {
+		path, _ = filepath.Split(path)
+	}
+	if path == "" {
+		path = "."
+	}
+	return path
+}
+
+// ARFFile returns the ARF file of given name in dir, saved by SaveAllARFs as
+// <net>_<run>_<name>.tsv, returning an error if there is none or several
+func ARFFile(dir, nm string) (string, error) {
+	fns, err := filepath.Glob(filepath.Join(dir, "*_"+nm+".tsv"))
+	if err != nil {
+		return "", err
+	}
+	switch len(fns) {
+	case 0:
+		return "", fmt.Errorf("ARFDiff: no %s ARF file in: %s", nm, dir)
+	case 1:
+		return fns[0], nil
+	}
+	return "", fmt.Errorf("ARFDiff: %d %s ARF files in: %s -- must hold a single run: %v", len(fns), nm, dir, fns)
+}
+
+// ARFPeak returns the y, x index of the peak of the nsy x nsx map in vals
+func ARFPeak(vals []float32, nsx int) (int, int) {
+	mi := 0
+	for i, v := range vals {
+		if v > vals[mi] {
+			mi = i
+		}
+	}
+	return mi / nsx, mi % nsx
+}
+
+// RunARFDiff compares the ARFs saved in dirA and dirB, returning the
+// differences and their stats.  ARFs missing from either directory are
+// skipped with a message, and it is an error if none are found.
+func (ss *Sim) RunARFDiff(dirA, dirB string) (*ARFDiff, error) {
+	ad := &ARFDiff{DirA: ARFDir(dirA), DirB: ARFDir(dirB), Units: &etable.Table{}, Summary: &etable.Table{}}
+	ss.ConfigARFDiffUnits(ad.Units)
+	ss.ConfigARFDiffLog(ad.Summary)
+	if len(ss.ARFs.RFs) == 0 {
+		ss.UpdtARFs() // configures the ARFs, for their names and shapes
+	}
+	for _, paf := range ss.ARFs.RFs {
+		fa, err := ARFFile(ad.DirA, paf.Name)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		fb, err := ARFFile(ad.DirB, paf.Name)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		var ta, tb etensor.Float32
+		ta.CopyShapeFrom(&paf.NormRF)
+		tb.CopyShapeFrom(&paf.NormRF)
+		if err := etensor.OpenCSV(&ta, gi.FileName(fa), '\t'); err != nil {
+			log.Println(err)
+			continue
+		}
+		if err := etensor.OpenCSV(&tb, gi.FileName(fb), '\t'); err != nil {
+			log.Println(err)
+			continue
+		}
+		ad.Add(paf.Name, &ta, &tb)
+	}
+	if len(ad.Names) == 0 {
+		return nil, fmt.Errorf("ARFDiff: no ARFs found in both %s and %s", ad.DirA, ad.DirB)
+	}
+	return ad, nil
+}
+
+// Add adds the difference of ARF nm between ta and tb, with its stats
+func (ad *ARFDiff) Add(nm string, ta, tb *etensor.Float32) {
+	df := &etensor.Float32{}
+	df.CopyShapeFrom(ta)
+	df.SetMetaData("name", nm)
+	df.SetMetaData("colormap", "ColdHot")
+	df.SetMetaData("grid-fill", "1")
+	for i, va := range ta.Values {
+		df.Values[i] = tb.Values[i] - va
+	}
+	ad.Names = append(ad.Names, nm)
+	ad.Diffs = append(ad.Diffs, df)
+
+	nsy, nsx := ta.Dim(2), ta.Dim(3)
+	nsrc := nsy * nsx
+	nu := ta.Len() / nsrc
+	ut := ad.Units
+	var sumCorr, sumMAD, sumShift float64
+	nCorr, nShift := 0, 0
+	fa := make([]float64, nsrc)
+	fb := make([]float64, nsrc)
+	for ui := 0; ui < nu; ui++ {
+		st := ui * nsrc
+		ua := ta.Values[st : st+nsrc]
+		ub := tb.Values[st : st+nsrc]
+		mad := 0.0
+		for i, va := range ua {
+			mad += math.Abs(float64(ub[i] - va))
+			fa[i], fb[i] = float64(va), float64(ub[i])
+		}
+		mad /= float64(nsrc)
+		sumMAD += mad
+		row := ut.Rows
+		ut.SetNumRows(row + 1)
+		ut.SetCellString("RF", row, nm)
+		ut.SetCellFloat("Unit", row, float64(ui))
+		ut.SetCellFloat("MAD", row, mad)
+		if cr, ok := CorrelOk(fa, fb); ok {
+			ut.SetCellFloat("Corr", row, cr)
+			sumCorr += cr
+			nCorr++
+			ay, ax := ARFPeak(ua, nsx)
+			by, bx := ARFPeak(ub, nsx)
+			sh := math.Hypot(float64(by-ay), float64(bx-ax))
+			ut.SetCellFloat("PeakShift", row, sh)
+			sumShift += sh
+			nShift++
+		} else {
+			ut.SetCellFloat("Corr", row, math.NaN())
+			ut.SetCellFloat("PeakShift", row, math.NaN())
+		}
+	}
+
+	dt := ad.Summary
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("RF", row, nm)
+	dt.SetCellFloat("NUnits", row, float64(nu))
+	dt.SetCellFloat("NFlat", row, float64(nu-nCorr))
+	if nCorr > 0 {
+		dt.SetCellFloat("Corr", row, sumCorr/float64(nCorr))
+	}
+	if nShift > 0 {
+		dt.SetCellFloat("PeakShift", row, sumShift/float64(nShift))
+	}
+	if nu > 0 {
+		dt.SetCellFloat("MAD", row, sumMAD/float64(nu))
+	}
+}
+
+// SaveARFDiff saves the summary and unit tables, and each difference map,
+// to log files prefixed with arfdiff
+func (ss *Sim) SaveARFDiff(ad *ARFDiff) {
+	fnm := ss.LogFileName("arfdiff")
+	ad.Summary.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+	ad.Units.SaveCSV(gi.FileName(ss.LogFileName("arfdiff_units")), etable.Tab, etable.Headers)
+	for i, nm := range ad.Names {
+		etensor.SaveCSV(ad.Diffs[i], gi.FileName(ss.LogFileName("arfdiff_"+nm)), '\t')
+	}
+	fmt.Printf("ARFDiff: %s vs. %s saved to: %s\n", ad.DirB, ad.DirA, fnm)
+}
+
+// DiffARFs compares the ARFs saved in the directories of the selected paths
+// (can select a file too), saves the results, and views them in the GUI
+func (ss *Sim) DiffARFs(dirA, dirB gi.FileName) {
+	ad, err := ss.RunARFDiff(string(dirA), string(dirB))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	ss.SaveARFDiff(ad)
+	if ss.Win == nil {
+		return
+	}
+	vp := ss.Win.Viewport
+	etview.TableViewDialog(vp, ad.Summary, giv.DlgOpts{Title: "ARF Diff", Prompt: ad.DirB + " - " + ad.DirA, TmpSave: nil}, nil, nil)
+	for i, nm := range ad.Names {
+		etview.TensorGridDialog(vp, ad.Diffs[i], giv.DlgOpts{Title: "ARF Diff " + nm, Prompt: nm, TmpSave: nil}, nil, nil)
+	}
+}
+
+func (ss *Sim) ConfigARFDiffUnits(dt *etable.Table) {
+	dt.SetMetaData("name", "ARFDiffUnits")
+	dt.SetMetaData("desc", "Comparison of each unit's activation-based receptive fields between two saved conditions")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"RF", etensor.STRING, nil, nil},
+		{"Unit", etensor.INT64, nil, nil},
+		{"Corr", etensor.FLOAT64, nil, nil},
+		{"MAD", etensor.FLOAT64, nil, nil},
+		{"PeakShift", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigARFDiffLog(dt *etable.Table) {
+	dt.SetMetaData("name", "ARFDiff")
+	dt.SetMetaData("desc", "Comparison of activation-based receptive fields between two saved conditions, averaged over units -- Corr and PeakShift exclude flat units")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"RF", etensor.STRING, nil, nil},
+		{"NUnits", etensor.INT64, nil, nil},
+		{"NFlat", etensor.INT64, nil, nil},
+		{"Corr", etensor.FLOAT64, nil, nil},
+		{"MAD", etensor.FLOAT64, nil, nil},
+		{"PeakShift", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
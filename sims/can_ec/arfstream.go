@@ -0,0 +1,127 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/emer/emergent/actrf"
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// ARFStream manages incremental normalized snapshots of the activation-based
+// receptive fields written to disk, and a cap on the number of trials
+// accumulated in the sums, beyond which older trials decay away.
+type ARFStream struct {
+	SnapEvery int     `desc:"write normalized snapshots of all ARFs to disk every this many trials -- 0 = off"`
+	MaxN      float32 `desc:"memory cap on the number of trials accumulated in the ARF sums: once reached, sums are decayed by 1/MaxN each trial so older trials are gradually forgotten -- 0 = no cap"`
+	NTrials   int     `inactive:"+" desc:"number of trials accumulated since last reset"`
+	NSnaps    int     `inactive:"+" desc:"number of snapshots written since last reset"`
+	NormOk    bool    `view:"-" desc:"RF and NormRF are current with the sums, so do not need to be recomputed"`
+}
+
+func (as *ARFStream) Reset() {
+	as.NTrials = 0
+	as.NSnaps = 0
+	as.NormOk = false
+}
+
+// Decay decays the sums of all RFs if the MaxN cap has been reached --
+// call prior to adding a new trial
+func (as *ARFStream) Decay(rfs *actrf.RFs) {
+	if as.MaxN <= 0 || float32(as.NTrials) < as.MaxN {
+		return
+	}
+	dk := 1 - 1/as.MaxN
+	for _, af := range rfs.RFs {
+		ScaleTsr(&af.SumProd, dk)
+		ScaleTsr(&af.SumSrc, dk)
+	}
+}
+
+// Trial records that a new trial has been added, returning true if a snapshot is due
+func (as *ARFStream) Trial() bool {
+	as.NTrials++
+	as.NormOk = false
+	return as.SnapEvery > 0 && as.NTrials%as.SnapEvery == 0
+}
+
+// ScaleTsr multiplies all values in the tensor by given factor
+func ScaleTsr(tsr *etensor.Float32, fact float32) {
+	for i := range tsr.Values {
+		tsr.Values[i] *= fact
+	}
+}
+
+// AvgNormARFs computes the ARF averages and normalized values, only if they
+// are not already current.  prog if non-nil is called after each RF with
+// the number done and total number, for progress updates.
+func (ss *Sim) AvgNormARFs(prog func(done, n int)) {
+	if ss.ARFStream.NormOk {
+		return
+	}
+	n := len(ss.ARFs.RFs)
+	for i, af := range ss.ARFs.RFs {
+		af.AvgNorm()
+		ss.TestEnv.ArenaNormRF(af)
+		if prog != nil {
+			prog(i+1, n)
+		}
+	}
+	ss.ARFStream.NormOk = true
+}
+
+// SnapARFs writes a normalized snapshot of all ARFs to files,
+// numbered sequentially with the snapshot number
+func (ss *Sim) SnapARFs() {
+	ss.AvgNormARFs(nil)
+	snm := fmt.Sprintf("snap%03d", ss.ARFStream.NSnaps)
+	for _, paf := range ss.ARFs.RFs {
+		fnm := ss.LogFileName(paf.Name + "_" + snm)
+		etensor.SaveCSV(&paf.NormRF, gi.FileName(fnm), '\t')
+	}
+	ss.ARFStream.NSnaps++
+}
+
+// ArenaNormRF re-normalizes the NormRF of af, if its source is the world
+// grid (i.e., a Pos RF), over the cells inside the arena only, so that the
+// walls and out-of-arena cells of irregular arenas, which are never
+// occupied, do not set the range of the normalization: they are set to 0.
+func (ev *XYHDEnv) ArenaNormRF(af *actrf.RF) {
+	nr := &af.NormRF
+	if nr.NumDims() != 4 || nr.Dim(2) != ev.Size.Y || nr.Dim(3) != ev.Size.X {
+		return
+	}
+	nsrc := ev.Size.Y * ev.Size.X
+	nu := nr.Len() / nsrc
+	for ui := 0; ui < nu; ui++ {
+		st := ui * nsrc
+		rf := af.RF.Values[st : st+nsrc]
+		vals := nr.Values[st : st+nsrc]
+		first := true
+		var mn, mx float32
+		for i, v := range rf {
+			if !ev.InArena(evec.Vec2i{i % ev.Size.X, i / ev.Size.X}) {
+				continue
+			}
+			if first || v < mn {
+				mn = v
+			}
+			if first || v > mx {
+				mx = v
+			}
+			first = false
+		}
+		for i, v := range rf {
+			if mx <= mn || !ev.InArena(evec.Vec2i{i % ev.Size.X, i / ev.Size.X}) {
+				vals[i] = 0
+				continue
+			}
+			vals[i] = (v - mn) / (mx - mn)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/mat32"
+)
+
+// AttrShiftParams are the parameters of the velocity-controlled attractor
+// shift test: the EC bump is first set up by ClampTrials trials of full input
+// at the Probe Pos and Angle with no rotation, then, with the position and
+// heading inputs silenced, a constant Vestibular rotation input is applied for
+// Cycles cycles, and the displacement of the centroid of the EC bump (in
+// pools, around the toroidal sheet) is compared with the expected integration
+// of the rotation -- rot degrees per trial of cycles.  This is done for NVel
+// rotations evenly spanning -AngInc to +AngInc, giving the calibration curve
+// of bump speed vs. expected rotation speed.  The heading decoded from
+// Orientation is also logged, for comparison with the readout.
+type AttrShiftParams struct {
+	ClampTrials int `def:"3" min:"1" desc:"number of trials of full input to set up the bump before each shift"`
+	Cycles      int `def:"200" min:"1" desc:"number of cycles of constant velocity input with position and heading inputs silenced"`
+	SampleInt   int `def:"10" min:"1" desc:"interval in cycles between samples of the decoded heading and position"`
+	NVel        int `def:"9" min:"2" desc:"number of rotations tested, evenly spanning -AngInc to +AngInc"`
+}
+
+// Defaults sets default params
+func (ap *AttrShiftParams) Defaults() {
+	ap.ClampTrials = 3
+	ap.Cycles = 200
+	ap.SampleInt = 10
+	ap.NVel = 9
+}
+
+func init() {
+	AddAnalysis(&Analysis{Name: "attrshift", Label: "Attr Shift", Desc: "velocity-controlled attractor shift test: set up the bump, then apply a constant vestibular input with position and heading inputs silenced, and compare the EC bump displacement with the expected integration, for a range of rotations.", Run: func(ss *Sim) []AnalysisOut {
+		dt, cal := ss.AttractorShift()
+		return []AnalysisOut{{Name: "attrshift", Table: dt}, {Name: "attrcal", Table: cal}}
+	}})
+}
+
+// AttractorShift runs the attractor shift test, returning the bump displacement
+// at each sample for each rotation, and the calibration curve with one row per
+// rotation.  The network activation state is reset before each rotation, and
+// restored to its state before the test at the end.
+func (ss *Sim) AttractorShift() (dt, cal *etable.Table) {
+	ap := &ss.AttrShift
+	ev := &ss.TrainEnv
+	var ns NetState
+	ss.SaveNetState(&ns)
+	defer ss.RestoreNetState(&ns)
+	dt = &etable.Table{}
+	ConfigAttrShiftLog(dt)
+	cal = &etable.Table{}
+	ConfigAttrCalLog(cal)
+
+	pos := ss.Probe.Pos
+	if pos.IsNil() {
+		pos = ev.Size.DivScalar(2)
+	}
+	posf := pos.ToVec2()
+	clamp := make(map[string]etensor.Tensor)
+	for _, lnm := range []string{"Vestibular", "Prev_Position", "Out_Position", "Prev_Orientation", "Orientation"} {
+		st := "Vestibular"
+		switch lnm {
+		case "Prev_Position", "Out_Position":
+			st = "Position"
+		case "Prev_Orientation", "Orientation":
+			st = "Angle"
+		}
+		pat := etensor.NewFloat32(ev.NextStates[st].Shapes(), nil, nil)
+		switch st {
+		case "Vestibular":
+			ev.EncodeVestibular(pat, 0)
+		case "Position":
+			ev.EncodePosition(pat, st, posf)
+		case "Angle":
+			ev.EncodeAngle(pat, st, ss.Probe.Angle)
+		}
+		clamp[lnm] = pat
+	}
+	vpat := etensor.NewFloat32(ev.NextStates["Vestibular"].Shapes(), nil, nil)
+	vlay := ss.Net.LayerByName("Vestibular").(leabra.LeabraLayer).AsLeabra()
+	trlCyc := float64(ss.CycPerQtr * (ss.MinusQtrs + ss.PlusQtrs))
+
+	for vi := 0; vi < ap.NVel; vi++ {
+		rot := -ev.AngInc + (2*ev.AngInc*vi)/(ap.NVel-1)
+		ss.Net.InitActs()
+		for trl := 0; trl < ap.ClampTrials; trl++ {
+			ss.RunTrial(clamp)
+		}
+		ss.Net.InitExt()
+		ev.EncodeVestibular(vpat, rot)
+		vlay.ApplyExt(vpat)
+		ss.Net.AlphaCycInit(false)
+		ss.Time.CycPerQtr = ss.CycPerQtr
+		ss.Time.AlphaCycStart()
+
+		prvOri := ss.DecodeOriAct()
+		prvCtr := ss.BumpCentroid("EC")
+		shift := 0.0
+		var disp mat32.Vec2
+		var sxy, sxx float64
+		var dpos mat32.Vec2
+		for cyc := 1; cyc <= ap.Cycles; cyc++ {
+			ss.Net.Cycle(&ss.Time)
+			ss.Time.CycleInc()
+			if cyc%ap.SampleInt != 0 && cyc != ap.Cycles {
+				continue
+			}
+			ctr := ss.BumpCentroid("EC")
+			disp = disp.Add(ss.BumpDiff("EC", ctr, prvCtr))
+			prvCtr = ctr
+			bshift := float64(disp.Length())
+			ori := ss.DecodeOriAct()
+			shift += AngDiff(ori, prvOri)
+			prvOri = ori
+			exp := float64(rot) * float64(cyc) / trlCyc
+			dpos = ss.DecodePosAct(ev, true)
+			drift := float64(dpos.Sub(posf).Length())
+			sxy += math.Abs(exp) * bshift
+			sxx += exp * exp
+			row := dt.Rows
+			dt.SetNumRows(row + 1)
+			dt.SetCellFloat("Rot", row, float64(rot))
+			dt.SetCellFloat("Cycle", row, float64(cyc))
+			dt.SetCellFloat("ExpShift", row, exp)
+			dt.SetCellFloat("BumpX", row, float64(disp.X))
+			dt.SetCellFloat("BumpY", row, float64(disp.Y))
+			dt.SetCellFloat("BumpShift", row, bshift)
+			dt.SetCellFloat("DecShift", row, shift)
+			dt.SetCellFloat("DecOri", row, ori)
+			dt.SetCellFloat("PosDrift", row, drift)
+		}
+		row := cal.Rows
+		cal.SetNumRows(row + 1)
+		cal.SetCellFloat("Rot", row, float64(rot))
+		cal.SetCellFloat("ExpSpeed", row, float64(rot))
+		cal.SetCellFloat("BumpSpeed", row, float64(disp.Length())*trlCyc/float64(ap.Cycles))
+		cal.SetCellFloat("DecSpeed", row, shift*trlCyc/float64(ap.Cycles))
+		gain := 0.0
+		if sxx > 0 {
+			gain = sxy / sxx
+		}
+		cal.SetCellFloat("Gain", row, gain)
+		cal.SetCellFloat("PosDrift", row, float64(dpos.Sub(posf).Length()))
+	}
+	fmt.Printf("AttractorShift: tested %d rotations over %d cycles\n", ap.NVel, ap.Cycles)
+	return
+}
+
+// BumpCentroid returns the centroid of the activity bump of given EC layer, in
+// pools (units for a 2D sheet), as the circular mean of the activity along
+// each axis, as the sheet wraps around as a torus
+func (ss *Sim) BumpCentroid(lnm string) mat32.Vec2 {
+	ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+	ny, nx := ly.Shp.Dim(0), ly.Shp.Dim(1)
+	nu := len(ly.Neurons) / (ny * nx)
+	xs := make([]float64, len(ly.Neurons))
+	ys := make([]float64, len(ly.Neurons))
+	wts := make([]float64, len(ly.Neurons))
+	for ni := range ly.Neurons {
+		pi := ni / nu
+		ys[ni] = float64(pi/nx) * 360 / float64(ny)
+		xs[ni] = float64(pi%nx) * 360 / float64(nx)
+		wts[ni] = float64(ly.Neurons[ni].Act)
+	}
+	cx, _ := CircMean(xs, wts)
+	cy, _ := CircMean(ys, wts)
+	return mat32.Vec2{X: float32(cx * float64(nx) / 360), Y: float32(cy * float64(ny) / 360)}
+}
+
+// BumpDiff returns the displacement a - b between two bump centroids of given
+// EC layer, the shortest way around the torus
+func (ss *Sim) BumpDiff(lnm string, a, b mat32.Vec2) mat32.Vec2 {
+	ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+	ny, nx := float64(ly.Shp.Dim(0)), float64(ly.Shp.Dim(1))
+	dx := AngDiff(float64(a.X)*360/nx, float64(b.X)*360/nx) * nx / 360
+	dy := AngDiff(float64(a.Y)*360/ny, float64(b.Y)*360/ny) * ny / 360
+	return mat32.Vec2{X: float32(dx), Y: float32(dy)}
+}
+
+// DecodeOriAct decodes the heading, in degrees, from the Orientation layer
+// current activity (Act)
+func (ss *Sim) DecodeOriAct() float64 {
+	ori := ss.Net.LayerByName("Orientation").(leabra.LeabraLayer).AsLeabra()
+	ori_tsr := make([]float32, len(ori.Neurons))
+	for i, val := range ori.Neurons {
+		ori_tsr[i] = val.Act
+	}
+	return AngNorm(float64(ss.TrainEnv.AngCode.Decode(ori_tsr) * 360))
+}
+
+func ConfigAttrShiftLog(dt *etable.Table) {
+	dt.SetMetaData("name", "AttrShiftLog")
+	dt.SetMetaData("desc", "EC bump displacement, in pools, and decoded heading shift, under constant velocity input with position and heading inputs silenced")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Rot", etensor.FLOAT64, nil, nil},
+		{"Cycle", etensor.INT64, nil, nil},
+		{"ExpShift", etensor.FLOAT64, nil, nil},
+		{"BumpX", etensor.FLOAT64, nil, nil},
+		{"BumpY", etensor.FLOAT64, nil, nil},
+		{"BumpShift", etensor.FLOAT64, nil, nil},
+		{"DecShift", etensor.FLOAT64, nil, nil},
+		{"DecOri", etensor.FLOAT64, nil, nil},
+		{"PosDrift", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func ConfigAttrCalLog(dt *etable.Table) {
+	dt.SetMetaData("name", "AttrCalLog")
+	dt.SetMetaData("desc", "Attractor integration calibration: EC bump speed, in pools per trial, and decoded rotation speed, vs. expected rotation speed, in degrees per trial -- Gain is the bump displacement per degree")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Rot", etensor.FLOAT64, nil, nil},
+		{"ExpSpeed", etensor.FLOAT64, nil, nil},
+		{"BumpSpeed", etensor.FLOAT64, nil, nil},
+		{"DecSpeed", etensor.FLOAT64, nil, nil},
+		{"Gain", etensor.FLOAT64, nil, nil},
+		{"PosDrift", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// BlockSpan returns the start epoch, and the number of trials (0 = rest of
+// the run), of the block with given index of a schedule
+type BlockSpan func(bi int) (epoch, trials int)
+
+// CurBlock returns the index of the block of a schedule of nblk blocks that
+// given training epoch and trial within the epoch is in, with ntrls trials
+// per epoch, -1 = none.  Each block starts at the start of its epoch, and
+// lasts for its number of trials, or the rest of the run if 0.  Later blocks
+// take precedence.  This is the scheduler of the Occlusion, VestibGain and
+// Arena blocks.
+func CurBlock(nblk, epc, trl, ntrls int, span BlockSpan) int {
+	cur := -1
+	t := epc*ntrls + trl
+	for bi := 0; bi < nblk; bi++ {
+		epoch, trials := span(bi)
+		st := epoch * ntrls
+		if t < st || (trials > 0 && t >= st+trials) {
+			continue
+		}
+		cur = bi
+	}
+	return cur
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/emer/emergent/actrf"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// BorderParams computes the border score of each unit of the Layers, from
+// its position RF, with its significance relative to NShuffle shuffles of
+// the RF among the visited arena cells: a unit is a significant border cell
+// if its score is above the Pctile percentile of the shuffled scores.  The
+// RFs are accumulated over the training trials, and on the Periodic schedule
+// the score of each unit is logged to the BorderLog.  The scores of the ARFs
+// are computed by the border analysis.
+type BorderParams struct {
+	Periodic
+	NShuffle int     `def:"100" min:"1" desc:"number of shuffles of each RF for the significance of its score"`
+	Pctile   float64 `def:"0.99" min:"0" max:"1" desc:"percentile of the shuffled scores that the score must exceed to be significant"`
+	FieldThr float64 `def:"0.2" desc:"fraction of the peak rate above which a location is in the firing field"`
+
+	RFs TrnRFs `view:"inline" desc:"RFs of the Layers since the last scores"`
+}
+
+// Defaults sets default params
+func (bp *BorderParams) Defaults() {
+	bp.NShuffle = 100
+	bp.Pctile = 0.99
+	bp.FieldThr = 0.2
+}
+
+// ShuffleScores returns the border scores of n shuffles of rates among the
+// arena cells with occupancy occ > 0, sorted in increasing order, using rnd
+// for the shuffles
+func (bp *BorderParams) ShuffleScores(ag *ArenaGeom, rates, occ []float64, n int, rnd *rand.Rand) []float64 {
+	var vis []int
+	for i, o := range occ {
+		if o > 0 && ag.In[i] {
+			vis = append(vis, i)
+		}
+	}
+	shuf := make([]float64, len(rates))
+	vals := make([]float64, len(vis))
+	scores := make([]float64, n)
+	for si := range scores {
+		for i, ci := range vis {
+			vals[i] = rates[ci]
+		}
+		rnd.Shuffle(len(vals), func(i, j int) { vals[i], vals[j] = vals[j], vals[i] })
+		for i, ci := range vis {
+			shuf[ci] = vals[i]
+		}
+		_, fld := ag.Field(shuf, bp.FieldThr)
+		scores[si] = ag.BorderScore(shuf, fld)
+	}
+	sort.Float64s(scores)
+	return scores
+}
+
+// BorderScores computes the border score of each unit of given layers, with
+// its shuffle threshold and p value, from their Pos RFs in rfs, which must
+// have been averaged, in the arena of given env
+func (ss *Sim) BorderScores(rfs *actrf.RFs, lays []string, ev *XYHDEnv) *etable.Table {
+	bp := &ss.Border
+	dt := &etable.Table{}
+	ConfigBorderTable(dt)
+	ag := NewArenaGeom(ev)
+	for _, lnm := range lays {
+		paf, err := rfs.RFByNameTry(lnm + "_Pos")
+		if err != nil {
+			continue
+		}
+		np := len(paf.SumSrc.Values)
+		if np != len(ag.In) {
+			continue // RFs of another world size
+		}
+		occ := make([]float64, np)
+		for pi := range occ {
+			occ[pi] = float64(paf.SumSrc.Values[pi])
+		}
+		rates := make([]float64, np)
+		nu := len(paf.RF.Values) / np
+		for ui := 0; ui < nu; ui++ {
+			for pi := range rates {
+				rates[pi] = float64(paf.RF.Values[ui*np+pi])
+			}
+			_, fld := ag.Field(rates, bp.FieldThr)
+			bs := ag.BorderScore(rates, fld)
+			shuf := bp.ShuffleScores(ag, rates, occ, bp.NShuffle, ss.ShufRand)
+			thr := shuf[int(bp.Pctile*float64(len(shuf)-1))]
+			nge := len(shuf) - sort.SearchFloat64s(shuf, bs)
+			row := dt.Rows
+			dt.SetNumRows(row + 1)
+			dt.SetCellString("Layer", row, lnm)
+			dt.SetCellFloat("Unit", row, float64(ui))
+			dt.SetCellFloat("Border", row, bs)
+			dt.SetCellFloat("ShufThr", row, thr)
+			dt.SetCellFloat("P", row, float64(nge+1)/float64(len(shuf)+1))
+			if bs > thr {
+				dt.SetCellFloat("Sig", row, 1)
+			}
+		}
+	}
+	return dt
+}
+
+func init() {
+	AddAnalysis(&Analysis{Name: "border", Label: "Border Score", Desc: "compute the border score of ARF layer units from their Pos activation rfs: coverage of a wall by the firing field vs. its distance from the walls, with significance relative to shuffles of the rfs.", Needs: []string{AnalysisARFs}, Run: func(ss *Sim) []AnalysisOut {
+		ss.AvgNormARFs(nil)
+		return []AnalysisOut{{Name: "border", Table: ss.BorderScores(&ss.ARFs, ss.PeriodicLayers(&ss.Border.Periodic), &ss.TestEnv)}}
+	}})
+}
+
+// BorderTrial accumulates the RFs of given layers for the current training
+// trial -- the Trial of the border PeriodicAnalysis
+func (ss *Sim) BorderTrial(lays []string) {
+	ss.AccumTrnRFs(&ss.Border.RFs, lays)
+}
+
+// BorderEpoch computes the border scores of the units of given layers from
+// the RFs accumulated since the last time, adding them per unit to dt --
+// the Epoch of the border PeriodicAnalysis
+func (ss *Sim) BorderEpoch(dt *etable.Table, epc int, lays []string) {
+	bp := &ss.Border
+	if bp.RFs.N == 0 {
+		return
+	}
+	bp.RFs.RFs.Avg()
+	bt := ss.BorderScores(&bp.RFs.RFs, lays, &ss.TrainEnv)
+	for br := 0; br < bt.Rows; br++ {
+		row := ss.AddPeriodicRow(dt, epc, bt.CellString("Layer", br))
+		for _, cn := range []string{"Unit", "Border", "ShufThr", "P", "Sig"} {
+			dt.SetCellFloat(cn, row, bt.CellFloat(cn, br))
+		}
+	}
+}
+
+func ConfigBorderTable(dt *etable.Table) {
+	dt.SetMetaData("name", "Border")
+	dt.SetMetaData("desc", "Border score of units from their Pos receptive fields, with its shuffle threshold and p value")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Layer", etensor.STRING, nil, nil},
+		{"Unit", etensor.INT64, nil, nil},
+		{"Border", etensor.FLOAT64, nil, nil},
+		{"ShufThr", etensor.FLOAT64, nil, nil},
+		{"P", etensor.FLOAT64, nil, nil},
+		{"Sig", etensor.INT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigBorderLog(dt *etable.Table) {
+	dt.SetMetaData("name", "BorderLog")
+	dt.SetMetaData("desc", "Border score of each unit over training, with its shuffle threshold and p value")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := PeriodicSchema(etable.Schema{
+		{"Unit", etensor.INT64, nil, nil},
+		{"Border", etensor.FLOAT64, nil, nil},
+		{"ShufThr", etensor.FLOAT64, nil, nil},
+		{"P", etensor.FLOAT64, nil, nil},
+		{"Sig", etensor.INT64, nil, nil},
+	})
+	dt.SetFromSchema(sch, 0)
+}
@@ -17,25 +17,25 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/emer/etable/agg"
 
 	"github.com/emer/empi/mpi"
 
-	"github.com/goki/gi/gist"
-
+	"github.com/ccnlab/map-nav/sims/netlayout"
+	"github.com/ccnlab/map-nav/sims/paramcomp"
 	"github.com/emer/emergent/actrf"
 	"github.com/emer/emergent/edge"
-	"github.com/emer/emergent/efuns"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
+	"github.com/emer/emergent/erand"
 	"github.com/emer/emergent/evec"
 	"github.com/emer/emergent/netview"
 	"github.com/emer/emergent/params"
 	"github.com/emer/emergent/patgen"
 	"github.com/emer/emergent/prjn"
-	"github.com/emer/emergent/relpos"
 	"github.com/emer/etable/eplot"
 	"github.com/emer/etable/etable"
 	"github.com/emer/etable/etensor"
@@ -44,6 +44,7 @@ import (
 	"github.com/goki/gi/gi"
 	"github.com/goki/gi/gimain"
 	"github.com/goki/gi/giv"
+	"github.com/goki/gi/oswin"
 	"github.com/goki/ki/ki"
 	"github.com/goki/ki/kit"
 	"github.com/goki/mat32"
@@ -63,6 +64,7 @@ func main() {
 
 func guirun() {
 	TheSim.Init()
+	TheSim.Queue.Open()
 	win := TheSim.ConfigGui()
 	fwin := TheSim.ConfigWorldGui()
 	fwin.GoStartEventLoop()
@@ -80,6 +82,7 @@ var ParamSets = params.Sets{
 			{Sel: "Prjn", Desc: "no extra learning factors, hebbian learning",
 				Params: params.Params{
 					"Prjn.Learn.Learn":        "true",
+					"Prjn.Learn.Lrate":        "0.04",  // must be set for all prjns -- see LrateMultParam
 					"Prjn.Learn.Norm.On":      "false", // works well without
 					"Prjn.Learn.Momentum.On":  "false",
 					"Prjn.Learn.WtBal.On":     "true", // this is typically critical
@@ -130,7 +133,7 @@ var ParamSets = params.Sets{
 			//	Params: params.Params{
 			//		"Prjn.WtScale.Rel": "0.1",
 			//	}},
-			{Sel: "#EC", Desc: "all EC layers: only pools, no layer-level",
+			{Sel: ".EC", Desc: "all EC layers: only pools, no layer-level",
 				Params: params.Params{
 					//"Layer.Act.Init.Decay": "0",
 					"Layer.Act.Noise.Dist":    "Gaussian",
@@ -140,6 +143,25 @@ var ParamSets = params.Sets{
 					"Layer.Inhib.ActAvg.Init": "0.08",
 					"Layer.Inhib.Layer.Gi":    "1.8",
 				}},
+			{Sel: ".ECInh", Desc: "EC inhibitory interneurons for Dale's law: relay their EC unit, with no competition among them",
+				Params: params.Params{
+					"Layer.Inhib.Layer.On":    "false",
+					"Layer.Inhib.ActAvg.Init": "0.08", // same as EC
+				}},
+			{Sel: ".ECToInh", Desc: "one-to-one drive of the EC inhibitory interneurons",
+				Params: params.Params{
+					"Prjn.Learn.Learn": "false",
+					"Prjn.WtInit.Mean": "0.8",
+					"Prjn.WtInit.Var":  "0",
+					"Prjn.WtInit.Sym":  "false",
+				}},
+			{Sel: ".Conj", Desc: "conjunctive grid x HD layer: pool-level inhibition, so units in each pool compete on heading",
+				Params: params.Params{
+					"Layer.Inhib.Layer.On":    "false",
+					"Layer.Inhib.Pool.On":     "true",
+					"Layer.Inhib.Pool.Gi":     "1.8",
+					"Layer.Inhib.ActAvg.Init": "0.15",
+				}},
 			{Sel: ".Position", Desc: "position layers",
 				Params: params.Params{
 					// "Layer.Act.Init.Decay":    "0",
@@ -152,12 +174,34 @@ var ParamSets = params.Sets{
 					"Layer.Inhib.Layer.Gi":    "2.0",
 					"Layer.Inhib.ActAvg.Init": "0.15", // it is essential to set this for all layers
 				}},
-			{Sel: "#ECToOut_Position", Desc: "DG learning is surprisingly critical: maxed out fast, hebbian works best",
+			{Sel: ".S1", Desc: "somatosensory proximity (whisker) input",
+				Params: params.Params{
+					"Layer.Inhib.ActAvg.Init": "0.25",
+				}},
+			{Sel: ".Goal", Desc: "egocentric goal direction and distance readouts",
+				Params: params.Params{
+					"Layer.Inhib.Layer.Gi":    "2.0",
+					"Layer.Inhib.ActAvg.Init": "0.15",
+				}},
+			{Sel: ".GoalBack", Desc: "goal readouts should not drive EC much",
+				Params: params.Params{
+					"Prjn.WtScale.Rel": ".1",
+				}},
+			{Sel: ".Action", Desc: "action readout, as the VL of emery1",
+				Params: params.Params{
+					"Layer.Inhib.Layer.Gi":    "1.6",
+					"Layer.Inhib.ActAvg.Init": "0.25",
+				}},
+			{Sel: ".ActionBack", Desc: "action readout should not drive EC much",
+				Params: params.Params{
+					"Prjn.WtScale.Rel": ".1",
+				}},
+			{Sel: ".ECToPosition", Desc: "DG learning is surprisingly critical: maxed out fast, hebbian works best",
 				Params: params.Params{
 					"Prjn.WtInit.Var": "0.25",
 					"Prjn.WtInit.Sym": "false", // couldn't see difference
 				}},
-			{Sel: "#ECToOrientation", Desc: "DG learning is surprisingly critical: maxed out fast, hebbian works best",
+			{Sel: ".ECToOrientation", Desc: "DG learning is surprisingly critical: maxed out fast, hebbian works best",
 				Params: params.Params{
 					"Prjn.WtInit.Var": "0.25",
 					"Prjn.WtInit.Sym": "false",
@@ -178,6 +222,7 @@ var ParamSets = params.Sets{
 					//"Prjn.Off":         "true",
 					"Prjn.Learn.Learn": "true",
 					"Prjn.WtInit.Var":  "0.25",
+					"Prjn.Delay":       "0", // conduction delay in cycles -- see VestDelay set
 				}},
 			{Sel: "#Prev_PositionToEC", Desc: "DG learning is surprisingly critical: maxed out fast, hebbian works best",
 				Params: params.Params{
@@ -192,9 +237,53 @@ var ParamSets = params.Sets{
 					//"Prjn.WtInit.Var":  "0",
 				}},
 		},
+		"LrateMult": &params.Sheet{ // see LrateMultParam
+			// {Sel: "#Out_Position", Desc: "faster decoding",
+			// 	Params: params.Params{
+			// 		"Prjn.LrateMult": "2",
+			// 	}},
+		},
+	}},
+	{Name: "FastDecoder", Desc: "readout decoders learn faster than the EC self-organization, so they track it", Sheets: params.Sheets{
+		"LrateMult": &params.Sheet{
+			{Sel: "#Out_Position", Desc: "position decoder",
+				Params: params.Params{
+					"Prjn.LrateMult": "2",
+				}},
+			{Sel: "#Orientation", Desc: "heading decoder",
+				Params: params.Params{
+					"Prjn.LrateMult": "2",
+				}},
+			{Sel: "#EC", Desc: "slower EC self-organization, from all prjns into EC",
+				Params: params.Params{
+					"Prjn.LrateMult": "0.5",
+				}},
+		},
+	}},
+	{Name: "VestDelay", Desc: "realistic conduction delay from vestibular input to EC, for attractor drift, with the FastDecoder tracking the drift", Sheets: params.Sheets{
+		"Network": &params.Sheet{
+			{Sel: "#VestibularToEC", Desc: "vestibular signal arrives after position / orientation inputs",
+				Params: params.Params{
+					"Prjn.Delay": "10",
+				}},
+		},
+	}},
+	{Name: "LongPlus", Desc: "extra-long plus phase, for prediction-horizon experiments", Sheets: params.Sheets{
+		"Sim": &params.Sheet{
+			{Sel: "Sim", Desc: "two quarters of plus phase",
+				Params: params.Params{
+					"Sim.PlusQtrs": "2",
+				}},
+		},
 	}},
 }
 
+// ParamDeps are the sets that each of the ParamSets builds on, applied
+// before it when it is used in the ParamSet spec
+var ParamDeps = paramcomp.Deps{
+	"VestDelay": {"FastDecoder"},
+}
+
 // Sim encapsulates the entire simulation model, and we define all the
 // functionality as methods on this struct.  This structure keeps all relevant
 // state information organized and available without having to pass everything around
@@ -203,61 +292,153 @@ var ParamSets = params.Sets{
 type Sim struct {
 	Entorhinal EcParams     `desc:"EC sizing parameters"`
 	Pat        PatParams    `desc:"parameters for the input patterns"`
+	PosDecode  PosDecoder   `desc:"decoder for continuous (sub-cell) position from the Out_Position population code"`
 	PoolVocab  patgen.Vocab `view:"no-inline" desc:"pool patterns vocabulary"`
 	//ExcitLateralScale float32           `def:"0.2" desc:"excitatory lateral (recurrent) WtScale.Rel value"`
 	//InhibLateralScale float32           `def:"0.2" desc:"inhibitory lateral (recurrent) WtScale.Abs value"`
 	//ExcitLateralLearn bool              `def:"true" desc:"do excitatory lateral (recurrent) connections learn?"`
-	Net              *leabra.Network  `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
-	CorticalInput    *etable.Table    `view:"no-inline" desc:"input patterns generated"`
-	OrientationInput *etable.Table    `view:"no-inline" desc:"input patterns generated"`
-	Probes           *etable.Table    `view:"no-inline" desc:"probe inputs"`
-	ARFs             actrf.RFs        `view:"no-inline" desc:"activation-based receptive fields"`
-	TrnTrlLog        *etable.Table    `view:"no-inline" desc:"training trial-level log data"`
-	TrnEpcLog        *etable.Table    `view:"no-inline" desc:"training epoch-level log data"`
-	TstEpcLog        *etable.Table    `view:"no-inline" desc:"testing epoch-level log data"`
-	TstTrlLog        *etable.Table    `view:"no-inline" desc:"testing trial-level log data"`
-	RunLog           *etable.Table    `view:"no-inline" desc:"summary log of each run"`
-	RunStats         *etable.Table    `view:"no-inline" desc:"aggregate stats on all runs"`
-	Params           params.Sets      `view:"no-inline" desc:"full collection of param sets"`
-	ParamSet         string           `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
-	Tag              string           `desc:"extra tag string to add to any file names output from sim (e.g., weights files, log files, params for run)"`
-	EConWts          *etensor.Float32 `view:"-" desc:"weights from input to EC layer"`
-	ECoffWts         *etensor.Float32 `view:"-" desc:"weights from input to EC layer"`
-	ECWts            *etensor.Float32 `view:"no-inline" desc:"net on - off weights from input to EC layer"`
-	MaxRuns          int              `desc:"maximum number of model runs to perform"`
-	MaxEpcs          int              `desc:"maximum number of epochs to run per model run"`
-	TestEpcs         int              `desc:"number of epochs of testing to run, cumulative after MaxEpcs of training"`
+	Net              *leabra.Network    `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
+	CorticalInput    *etable.Table      `view:"no-inline" desc:"input patterns generated"`
+	OrientationInput *etable.Table      `view:"no-inline" desc:"input patterns generated"`
+	Probes           *etable.Table      `view:"no-inline" desc:"synthetic probe stimuli for RunTrial, generated by GenProbes"`
+	Probe            ProbeParams        `desc:"parameters for the synthetic probe stimuli"`
+	AttrShift        AttrShiftParams    `desc:"parameters for the velocity-controlled attractor shift test, run as the attrshift analysis"`
+	ARFs             actrf.RFs          `view:"no-inline" desc:"activation-based receptive fields"`
+	JourneyARFs      actrf.RFs          `view:"no-inline" desc:"position activation-based receptive fields split by journey (e.g., left vs. right choice), for track and maze world presets"`
+	ARFStream        ARFStream          `desc:"incremental snapshots of the ARFs to disk, and memory cap on accumulated trials"`
+	TrnTrlLog        *etable.Table      `view:"no-inline" desc:"training trial-level log data"`
+	TrnEpcLog        *etable.Table      `view:"no-inline" desc:"training epoch-level log data"`
+	TstEpcLog        *etable.Table      `view:"no-inline" desc:"testing epoch-level log data"`
+	TstTrlLog        *etable.Table      `view:"no-inline" desc:"testing trial-level log data"`
+	RunLog           *etable.Table      `view:"no-inline" desc:"summary log of each run"`
+	LapLog           *etable.Table      `view:"no-inline" desc:"log of each lap / journey, for track and maze world presets"`
+	ChoiceLog        *etable.Table      `view:"no-inline" desc:"log of prospective position decoding at each approach to the maze choice point"`
+	Choice           ChoiceSweep        `view:"inline" desc:"choice-point forward sweep analysis for TMaze and Figure8 worlds"`
+	SRLog            *etable.Table      `view:"no-inline" desc:"log of successor representation eigenvector analysis per epoch"`
+	SR               SRAnalysis         `view:"inline" desc:"successor representation implied by hidden layer activity, compared with learned grid patterns"`
+	SelfLocLog       *etable.Table      `view:"no-inline" desc:"log of self-localization drift by steps since ground-truth reset, over the last epoch"`
+	VestibGain       VestibGain         `desc:"vestibular gain adaptation experiment: scales the angular-velocity signal over blocks of trials"`
+	Occlusion        Occlusion          `desc:"heading-dependent sensory occlusion experiment: blanks visual and proximity inputs in a dark sector of headings over blocks of trials"`
+	OcclLog          *etable.Table      `view:"no-inline" desc:"log of decoding errors on dark vs. light trials under sensory occlusion, per epoch and block"`
+	Adapt            Adapt              `desc:"adaptive curriculum: adapts the obstacle density and cue reliability of the environment to keep the position decoding error in a target band"`
+	AdaptLog         *etable.Table      `view:"no-inline" desc:"log of the adapted environment difficulty, per epoch"`
+	Arena            Arena              `desc:"global remapping experiment: rotates or mirrors the whole arena relative to the global frame over blocks of epochs"`
+	ArenaLog         *etable.Table      `view:"no-inline" desc:"log of position RF correlations with the previous arena block, in the global vs. the arena frame"`
+	UnitClass        UnitClass          `desc:"classification of units as grid, border, place, head-direction or conjunctive cells over training"`
+	UnitClassLog     *etable.Table      `view:"no-inline" desc:"log of the fraction of units in each functional class per layer over training"`
+	UnitGroups       UnitGroups         `desc:"named groups of units within a layer, e.g., the sub-units of each EC pool, with their own stats, ARFs and NetView highlighting"`
+	UnitGroupLog     *etable.Table      `view:"no-inline" desc:"log of the activity of each unit group over training"`
+	Border           BorderParams       `desc:"border scores of units over training, with shuffle-based significance"`
+	BorderLog        *etable.Table      `view:"no-inline" desc:"log of the border score of each unit over training"`
+	Speed            SpeedCells         `desc:"regression of unit activities on movement speed over training, to detect speed cells"`
+	SpeedLog         *etable.Table      `view:"no-inline" desc:"log of the regression of each unit's activity on movement speed over training"`
+	WtChg            WtChg              `desc:"DWt and cumulative weight change norms of each prjn per epoch, for which pathways are learning"`
+	WtChgLog         *etable.Table      `view:"no-inline" desc:"log of the weight changes of each prjn over training"`
+	Protocol         Protocol           `desc:"scripted multi-phase experiment protocol (e.g., train, dark test, cue rotation, retrain), loaded from a JSON file"`
+	Task             TaskState          `desc:"intermixed task blocks (exploration, foraging, goal navigation) of the protocol phases, driving the actions in the TrainEnv"`
+	TaskLog          *etable.Table      `view:"no-inline" desc:"log of each block of intermixed tasks in the protocol phases"`
+	VestibLog        *etable.Table      `view:"no-inline" desc:"log of decoded heading shift under vestibular gain manipulation, per epoch and gain block"`
+	ActLog           *etable.Table      `view:"no-inline" desc:"log of the distribution of actions executed per training epoch"`
+	ActRegLog        *etable.Table      `view:"no-inline" desc:"log of the distribution of actions executed per training epoch, in each spatial region"`
+	LatDiag          LatDiag            `desc:"per-epoch diagnostics of the EC lateral weights"`
+	LrateMults       map[string]float32 `inactive:"+" desc:"learning rate multiplier of each prjn, by name, from the LrateMult params sheet -- applied on top of Prjn.Learn.Lrate"`
+	LatDiagLog       *etable.Table      `view:"no-inline" desc:"log of EC lateral weight symmetry, stats and drift, per epoch and EC module"`
+	LatKernLog       *etable.Table      `view:"no-inline" desc:"log of EC lateral weight stats per kernel pool offset, per epoch and EC module"`
+	ActStats         ActStats           `desc:"accumulates the distribution of actions executed in training, to detect degenerate policies"`
+	SelfLoc          SelfLoc            `view:"inline" desc:"drive Prev_Position and Prev_Orientation inputs from the network's own previous estimates"`
+	RandWorld        RandWorld          `view:"inline" desc:"train each run on a new random world, and test on a fixed held-out world"`
+	Shuffle          ShuffleEnv         `view:"inline" desc:"control condition that shuffles the temporal order of training steps, breaking trajectory continuity"`
+	SimMat           SimMat             `desc:"live similarity matrix of layer activity during testing, viewed in the SimMat tab"`
+	ActExport        ActExport          `desc:"export of the ActM of selected layers on each test trial to a compressed archive per test epoch, for offline analyses"`
+	Traj             Trajectory         `desc:"saved trajectory of the TrainEnv, replayed exactly in every run so that behavior does not differ between networks"`
+	LinDec           LinDec             `desc:"learned linear decoder of position and heading, cross-validated on recorded test trials"`
+	Report           Report             `desc:"HTML summary of each run, saved next to the logs at the end of the run"`
+	PlotExport       PlotExport         `desc:"saves GUI plots as SVG / PDF figures at the end of each run, and from the Export Plot toolbar action"`
+	ParamAudit       ParamAudit         `desc:"record of every param applied by SetParams, with old and new values"`
+	Bench            Bench              `desc:"standard benchmark protocol, run with the -bench flag"`
+	CkptEval         CkptEval           `desc:"saving of weight checkpoints during training, and their evaluation with frozen weights, with the -eval-ckpts flag"`
+	Timers           PhaseTimers        `view:"-" desc:"timers for the parts of training, logged per epoch"`
+	LatKernel        LatKernel          `view:"-" desc:"cached EC lateral weight kernel, used in InitLateralWts"`
+	RunStats         *etable.Table      `view:"no-inline" desc:"aggregate stats on all runs"`
+	HDTuning         *etable.Table      `view:"no-inline" desc:"head-direction tuning of each unit in ARFLayers, computed from the Ang activation-based receptive fields"`
+	Params           params.Sets        `view:"no-inline" desc:"full collection of param sets"`
+	ParamsChanges    string             `view:"-" desc:"log of params changed by each ApplyParams during the run"`
+	ParamSet         string             `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- a paramcomp spec of set names and numeric overrides separated by + (or spaces), e.g., VestDelay+Gi=1.6, with the ParamDeps of each set applied before it"`
+	SaveParams       string             `view:"-" desc:"if set, the composed params set applied by SetParams is saved to this file, as JSON"`
+	Queue            RunQueue           `view:"no-inline" desc:"queue of configurations to run sequentially with Run Queue, saved to disk"`
+	Tag              string             `desc:"extra tag string to add to any file names output from sim (e.g., weights files, log files, params for run)"`
+	EConWts          *etensor.Float32   `view:"-" desc:"weights from input to EC layer"`
+	ECoffWts         *etensor.Float32   `view:"-" desc:"weights from input to EC layer"`
+	ECWts            *etensor.Float32   `view:"no-inline" desc:"net on - off weights from input to EC layer"`
+	MaxRuns          int                `desc:"maximum number of model runs to perform"`
+	MaxEpcs          int                `desc:"maximum number of epochs to run per model run"`
+	TestEpcs         int                `desc:"number of epochs of testing to run in TestEnv, each time the network is tested"`
+	TestWorld        string             `desc:"world preset to test in, e.g., OpenField -- empty = the current training world, or the held-out world with RandWorld"`
 	//MaxTrls           int               `desc:"maximum number of training trials per epoch"`
 	//TrainEnv   env.FixedTable    `desc:"Training environment -- visual images"`
-	Time      leabra.Time       `desc:"leabra timing parameters and state"`
-	ViewOn    bool              `desc:"whether to update the network view while running"`
-	TrainUpdt leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
-	TestUpdt  leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
-	ARFLayers []string          `desc:"names of layers to compute position activation fields on"`
-	TrainEnv  XYHDEnv           `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
+	Time        leabra.Time       `desc:"leabra timing parameters and state"`
+	MinusQtrs   int               `def:"3" min:"1" desc:"number of quarters in the minus phase"`
+	PlusQtrs    int               `def:"1" min:"1" desc:"number of quarters in the plus phase -- more than 1 gives an extra-long plus phase"`
+	CycPerQtr   int               `def:"25" min:"1" desc:"number of cycles per quarter"`
+	TrialSteps  int               `def:"1" min:"1" desc:"number of env steps per trial: inputs of each step are applied at even intervals over the minus phase, so the targets in the plus phase are those of the last step, for prediction over longer horizons without changing the cycles per trial"`
+	ITI         ITI               `view:"inline" desc:"inter-trial interval of blank cycles with inputs off, and activity decay, between trials"`
+	ClampScheds []ClampSched      `desc:"per-layer schedules for when external input is applied within the trial -- layers not listed are clamped throughout, as usual"`
+	ViewOn      bool              `desc:"whether to update the network view while running"`
+	PlotUpdt    PlotUpdt          `view:"inline" desc:"batched updating of the plots, decoupled from the logging"`
+	Demo        Demo              `desc:"soft real-time training at a fixed trial rate, for live demos"`
+	TrainUpdt   leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
+	TestUpdt    leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
+	ARFLayers   []string          `desc:"names of layers to compute position activation fields on"`
+	TrainEnv    XYHDEnv           `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
+	TestEnv     XYHDEnv           `desc:"Testing environment -- separate from TrainEnv, with its own world and counters, so that testing does not disturb the training trajectory and its stats"`
+	PatsFile    string            `desc:"pattern bank file opened by both envs instead of their pats.json, e.g., saved by SavePatBank -- empty = pats.json"`
 
 	// statistics: note use float64 as that is best for etable.Table
 	RFMaps        map[string]*etensor.Float32 `view:"no-inline" desc:"maps for plotting activation-based receptive fields"`
 	InputLays     []string                    `view:"-" desc:"input layers"`
 	TargetLays    []string                    `view:"-" desc:"target layers"`
-	ActAction     string                      `inactive:"+" desc:"action generated & taken"`
+	S1On          bool                        `desc:"include an S1 somatosensory input layer driven by the env ProxWhisker wall proximity / contact state, projecting to EC"`
+	SupFrac       float32                     `def:"1" min:"0" max:"1" desc:"fraction of training trials on which targets are provided for SupLays -- on other trials those layers run unsupervised, to study how much explicit position feedback is needed"`
+	SupLays       []string                    `desc:"target layers that only get targets on Supervised trials"`
+	Supervised    bool                        `inactive:"+" desc:"targets are provided for SupLays on the current trial"`
+	GoalOn        bool                        `desc:"include GoalDir and GoalDist target layers encoding the egocentric direction and distance to the env goal, trained from EC"`
+	ActOn         bool                        `desc:"include an Action target layer encoding the action of the last step of the trial, trained from EC, with NetAction / GenAction / ActMatch stats as in ffpred and emery1"`
+	NetAction     string                      `inactive:"+" desc:"action decoded from the Action layer, if ActOn"`
+	GenAction     string                      `inactive:"+" desc:"action executed by the env on the last step of the trial, the Action target, if ActOn"`
+	ActMatch      float64                     `inactive:"+" desc:"1 if NetAction matches GenAction, 0 otherwise"`
+	Inputs        []InputMap                  `desc:"env states applied to each input and target layer by ApplyInputs -- set to the DefaultInputs for the sim config at each Config unless InputsSet"`
+	InputsSet     bool                        `desc:"if true, the Inputs were set by the user (e.g., -inputs) and are kept at Config -- otherwise they are reset to the DefaultInputs, which depend on the layers that are on"`
+	StrictInputs  bool                        `desc:"at Config, exit if any layer or state of the Inputs does not exist, instead of reporting it and skipping it in ApplyInputs"`
+	Conj          ConjParams                  `view:"inline" desc:"optional Conj layer of conjunctive grid x head-direction cells, and tuning classification"`
+	ActAction     string                      `inactive:"+" desc:"action generated & commanded, with its continuous parameters if any"`
+	ExecAction    string                      `inactive:"+" desc:"action actually executed by the env, with its continuous parameters if any -- differs from ActAction under env MotorNoise"`
+	TrlSteps      []ActStep                   `view:"-" desc:"actions executed in the current trial by TakeAction"`
+	StuckPrv      StuckCounts                 `view:"-" desc:"TrainEnv StuckCnt at the last epoch log"`
 	TrlCosDiff    float64                     `inactive:"+" desc:"current trial's overall cosine difference"`
 	TrlCosDiffTGT []float64                   `inactive:"+" desc:"current trial's cosine difference for target layers"`
 	EpcCosDiff    float64                     `inactive:"+" desc:"last epoch's average cosine difference for output layer (a normalized error measure, maximum of 1 when the minus phase exactly matches the plus)"`
 	NumTrlStats   int                         `view:"-" inactive:"+" desc:"sum to increment as we go through epoch"`
+	NumSupStats   int                         `view:"-" inactive:"+" desc:"number of Supervised trials in SumCosDiff"`
 	SumCosDiff    float64                     `view:"-" inactive:"+" desc:"sum to increment as we go through epoch"`
 
 	// internal state - view:"-"
 	Win           *gi.Window                  `view:"-" desc:"main GUI window"`
 	NetView       *netview.NetView            `view:"-" desc:"the network viewer"`
 	ToolBar       *gi.ToolBar                 `view:"-" desc:"the master toolbar"`
+	TabView       *gi.TabView                 `view:"-" desc:"the tab view of the network and plots"`
 	WorldWin      *gi.Window                  `view:"-" desc:"XYHDEnv GUI window"`
+	Detach        GuiDetach                   `view:"-" desc:"detaches the GUI from running training, which continues headless, and re-attaches it"`
+	GuiMu         sync.Mutex                  `view:"-" desc:"guards the GUI pointers, which are cleared and set again by Detach while training runs: held by the running goroutine while it updates the views, and by the GUI while it changes them"`
 	WorldTabs     *gi.TabView                 `view:"-" desc:"XYHDEnv TabView"`
-	MatColors     []string                    `desc:"color strings in material order"`
+	MatColors     []string                    `desc:"color strings in material order, from the color Theme"`
+	Theme         string                      `desc:"world color theme: the name of one of the ColorThemes (Default, ColorBlind), or a .json theme file -- empty = theme.json if present, else Default"`
+	CurTheme      *ColorTheme                 `view:"-" desc:"the current world color theme, opened from Theme"`
 	Trace         *etensor.Int                `view:"no-inline" desc:"trace of movement for visualization"`
 	TraceView     *etview.TensorGrid          `desc:"view of the activity trace"`
 	dTrace        *etensor.Int                `view:"no-inline" desc:"trace of movement for visualization"`
 	dTraceView    *etview.TensorGrid          `desc:"view of the activity trace"`
+	ErrTrace      ErrTrace                    `desc:"decoding error lines in the dTrace view, and epoch map of decoding errors in the ErrMap view"`
+	ErrMapView    *etview.TensorGrid          `view:"-" desc:"view of the decoding error map"`
 	WorldView     *etview.TensorGrid          `desc:"view of the world"`
 	CurImgGrid    *etview.TensorGrid          `view:"-" desc:"the current image grid view"`
 	WtsGrid       *etview.TensorGrid          `view:"-" desc:"the weights grid view"`
@@ -266,19 +447,60 @@ type Sim struct {
 	TstEpcPlot    *eplot.Plot2D               `view:"-" desc:"the testing epoch plot"`
 	TstTrlPlot    *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
 	RunPlot       *eplot.Plot2D               `view:"-" desc:"the run plot"`
-	TrnEpcFile    *os.File                    `view:"-" desc:"log file"`
-	TstEpcFile    *os.File                    `view:"-" desc:"log file"`
-	RunFile       *os.File                    `view:"-" desc:"log file"`
+	LapPlot       *eplot.Plot2D               `view:"-" desc:"the lap plot"`
+	ChoicePlot    *eplot.Plot2D               `view:"-" desc:"the choice point plot"`
+	SRPlot        *eplot.Plot2D               `view:"-" desc:"the successor representation plot"`
+	SelfLocPlot   *eplot.Plot2D               `view:"-" desc:"the self-localization drift plot"`
+	VestibPlot    *eplot.Plot2D               `view:"-" desc:"the vestibular gain plot"`
+	ActPlot       *eplot.Plot2D               `view:"-" desc:"the action distribution plot"`
+	OcclPlot      *eplot.Plot2D               `view:"-" desc:"the sensory occlusion plot"`
+	AdaptPlot     *eplot.Plot2D               `view:"-" desc:"the adaptive difficulty plot"`
+	ArenaPlot     *eplot.Plot2D               `view:"-" desc:"the arena remapping plot"`
+	UnitClassPlot *eplot.Plot2D               `view:"-" desc:"the unit class plot"`
+	UnitGroupPlot *eplot.Plot2D               `view:"-" desc:"the unit group plot"`
+	WtChgPlot     *eplot.Plot2D               `view:"-" desc:"the weight change plot"`
+	TaskPlot      *eplot.Plot2D               `view:"-" desc:"the task block plot"`
+	LatDiagPlot   *eplot.Plot2D               `view:"-" desc:"the lateral weight diagnostics plot"`
+	SimMatView    *etview.TensorGrid          `view:"-" desc:"the similarity matrix view"`
+	PrjnView      *giv.TableView              `view:"-" desc:"the projections panel, for toggling and scaling projections at run time"`
+	PrjnCtrls     []PrjnCtrl                  `view:"-" desc:"run-time state of each projection, edited in the PrjnView"`
+	LapTrials     []LapTrial                  `view:"-" desc:"trials of the current lap, for per-journey ARFs"`
+	LapN          int                         `view:"-" desc:"number of trials in current lap"`
+	LapPosErr     float64                     `view:"-" desc:"sum of position error over current lap"`
+	LapPosACC     float64                     `view:"-" desc:"sum of position accuracy over current lap"`
+	LapSeen       int                         `view:"-" desc:"lap count of the TrainEnv when last checked for a completed lap, for the LapLog"`
+	LapARFSeen    int                         `view:"-" desc:"lap count of the TestEnv when last checked for a completed lap, for the per-journey ARFs"`
+	TrnTrlFile    *LogFile                    `view:"-" desc:"log file"`
+	TrnEpcFile    *LogFile                    `view:"-" desc:"log file"`
+	TstEpcFile    *LogFile                    `view:"-" desc:"log file"`
+	RunFile       *LogFile                    `view:"-" desc:"log file"`
+	SQLLog        *SQLiteLog                  `view:"-" desc:"if non-nil, all logs are written to this SQLite database"`
+	LogView       LogView                     `desc:"opens saved log files into the plot tabs for inspection, downsampled if large"`
+	Plots         map[string]*PlotTab         `view:"-" desc:"all plot tabs by name, for OpenLogPlot"`
+	LogGz         bool                        `view:"-" desc:"for command-line run only, gzip the streamed trial, epoch and run log files"`
+	AppendLogs    bool                        `view:"-" desc:"append to existing trial, epoch and run log files, e.g., when resuming a run, instead of truncating them"`
+	LogCfgs       map[string]*LogConfig       `desc:"precision and columns written to log files and the SQLite log, per log name (trn_trl, trn_epc, run, etc), with all for the default"`
+	LogWriters    map[string]*LogWriter       `view:"-" desc:"writers for log files, per log name, configured from LogCfgs"`
 	ValsTsrs      map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
 	EClateralflag bool                        `view:"-" desc:"flag for EClateral"`
 	IsRunning     bool                        `view:"-" desc:"true if sim is running"`
 	StopNow       bool                        `view:"-" desc:"flag to stop running"`
+	Stage         TrainStages                 `view:"-" desc:"stage of the current training trial in the training stepper"`
+	Alpha         AlphaState                  `view:"-" desc:"state of the alpha cycle in progress"`
+	RunsDone      bool                        `view:"-" desc:"set when training has completed all MaxRuns"`
 	NeedsNewRun   bool                        `view:"-" desc:"flag to initialize NewRun if last one finished"`
 	UseMPI        bool                        `view:"-" desc:"if true, use MPI to distribute computation across nodes"`
 	SaveWts       bool                        `view:"-" desc:"for command-line run only, auto-save final weights after each run"`
 	SaveARFs      bool                        `view:"-" desc:"for command-line run only, auto-save receptive field data"`
+	SaveSD        bool                        `view:"-" desc:"for command-line run only, auto-save final weights as a PyTorch-style state dict after each run"`
+	InitSD        string                      `desc:"if set, PyTorch-style state dict file to initialize weights from after each InitWts, e.g., from a deep-learning grid cell model"`
+	Analyze       []string                    `desc:"names of registered analyses to run at the end of each run, saving their outputs to log files"`
+	ExecHook      ExecHook                    `desc:"external command run after each training epoch, e.g., for incremental analysis"`
+	Watchdog      Watchdog                    `desc:"checks for NaN / Inf or runaway activity each epoch, and stops training with a diagnostic dump if found"`
+	SDNames       map[string]string           `desc:"optional mapping from our state dict keys (Recv.Send.weight, or Recv.Send.Class.weight for prjns between the same layers) to those of an external model, used for both saving and loading"`
 	NoGui         bool                        `view:"-" desc:"if true, runing in no GUI mode"`
 	RndSeed       int64                       `view:"-" desc:"the current random seed"`
+	ShufRand      *rand.Rand                  `view:"-" desc:"random numbers of the shuffle controls of the analyses, separate from the global ones of training so that running the analyses does not change training -- seeded from RndSeed and the run in NewRun"`
 	Comm          *mpi.Comm                   `view:"-" desc:"mpi communicator"`
 	AllDWts       []float32                   `view:"-" desc:"buffer of all dwt weight changes -- for mpi sharing"`
 	SumDWts       []float32                   `view:"-" desc:"buffer of MPI summed dwt weight changes"`
@@ -286,21 +508,31 @@ type Sim struct {
 
 // EcParams have the entorhinal cortex size and connectivity parameters
 type EcParams struct {
-	ECSize            evec.Vec2i `desc:"size of EC"`
-	InputSize         evec.Vec2i `desc:"size of Input"`
-	PositionSize      evec.Vec2i `desc:"size of Position"`
-	OrientationSize   evec.Vec2i `desc:"size of Orientation (head direction, 0-360)"`
-	VestibularSize    evec.Vec2i `desc:"size of Vestibular (left, forward, right)"`
-	InputPctAct       float32    `desc:"percent active in input patterns"`
-	OrientationPctAct float32    `desc:"percent active in input patterns"`
-	excitRadius2D     int        `desc:"excitRadius2D"` // note: note visible b/c lower case..
-	inhibRadius2D     int        `desc:"inhibRadius2D"`
-	excitRadius4D     int        `desc:"excitRadius4D"`
-	inhibRadius4D     int        `desc:"inhibRadius4D"`
-	excitSigma2D      float32    `desc:"excitSigma2D"`
-	inhibSigma2D      float32    `desc:"inhibSigma2D"`
-	excitSigma4D      float32    `desc:"excitSigma4D"`
-	inhibSigma4D      float32    `desc:"inhibSigma4D"`
+	ECSize            evec.Vec2i      `desc:"size of EC"`
+	InputSize         evec.Vec2i      `desc:"size of Input"`
+	PositionSize      evec.Vec2i      `desc:"size of Position"`
+	OrientationSize   evec.Vec2i      `desc:"size of Orientation (head direction, 0-360) -- X is set to the env RingSize in ConfigNet"`
+	VestibularSize    evec.Vec2i      `desc:"size of Vestibular (left, forward, right)"`
+	InputPctAct       float32         `desc:"percent active in input patterns"`
+	OrientationPctAct float32         `desc:"percent active in input patterns"`
+	InPCon            float32         `def:"1" min:"0" max:"1" desc:"proportion of connectivity in the input prjns into EC (from Prev_Position, Prev_Orientation, Vestibular, S1) -- less than 1 uses sparse uniform random connectivity, to cut memory and cycle time for larger EC sheets.  Netinput scaling (WtScale) is automatically computed from the actual number of connections, so overall input strength is preserved."`
+	GTauVar           erand.RndParams `desc:"distribution of offsets added to the EC Act.Dt.GTau per unit or pool, for heterogeneous time constants -- Var = 0 and Mean = 0 for homogeneous"`
+	GTauPerPool       bool            `desc:"draw one EC GTau offset per pool (hypercolumn), shared by its units, instead of per unit"`
+	TwistTorus        bool            `desc:"use the TwistTorus pattern for the EC lateral inhibition, wrapping around the EC sheet as a twisted torus with hexagonal periodic boundaries, instead of the standard torus Circle, which favors square grids -- module radii are then in pools"`
+	Dale              bool            `desc:"enforce Dale's law for the EC lateral inhibition: it is sent by a separate population of inhibitory interneurons (ECInh, EC2Inh...), driven one-to-one by the EC units, instead of directly by the excitatory EC units"`
+	ExcitLateral      bool            `desc:"add the excitatory EC lateral prjn (ExciteLateral), with the gaussian weights of InitLateralWts centered on a different corner for each unit of a pool, for an orientation bias in single EC cells -- 4D EC only"`
+	EC2D              bool            `desc:"use a 2D EC sheet of 2*ECSize units per side, without pools, instead of the 4D sheet of ECSize pools of 2x2 units, with matched unit counts -- the Circle lateral inhibition is then the same, as it flattens the 4D sheet to units, and TwistTorus radii, which are in pools, are doubled to match"`
+	Modules           []EcModule      `desc:"parallel EC sheets (grid modules), each with its own lateral inhibition kernel (grid spacing), all receiving the same inputs and projecting to the readouts -- named EC, EC2, EC3... -- empty = one EC sheet with the default kernel"`
+	KernelScale       bool            `def:"true" desc:"scale the lateral kernel radii and sigmas of the EC modules, and of the excitatory lateral kernel, with the ECSize, keeping them the same fractions of the sheet size as at KernelRef, so they need not be re-tuned by hand for each size -- otherwise they are used as is"`
+	KernelRef         int             `def:"10" desc:"ECSize that the lateral kernel radii and sigmas (including those of Modules) are tuned for, and scaled from by KernelScale"`
+	excitRadius2D     int             `desc:"excitRadius2D"` // note: note visible b/c lower case..
+	inhibRadius2D     int             `desc:"inhibRadius2D"`
+	excitRadius4D     int             `desc:"excitRadius4D"`
+	inhibRadius4D     int             `desc:"inhibRadius4D"`
+	excitSigma2D      float32         `desc:"excitSigma2D"`
+	inhibSigma2D      float32         `desc:"inhibSigma2D"`
+	excitSigma4D      float32         `desc:"excitSigma4D"`
+	inhibSigma4D      float32         `desc:"inhibSigma4D"`
 }
 
 // PatParams have the pattern parameters
@@ -323,6 +555,8 @@ func (ss *Sim) New() {
 	ss.CorticalInput = &etable.Table{}
 	ss.OrientationInput = &etable.Table{}
 	ss.Probes = &etable.Table{}
+	ss.Probe.Defaults()
+	ss.AttrShift.Defaults()
 	ss.TrnTrlLog = &etable.Table{}
 	ss.TrnEpcLog = &etable.Table{}
 	ss.TstEpcLog = &etable.Table{}
@@ -330,17 +564,77 @@ func (ss *Sim) New() {
 	ss.EConWts = &etensor.Float32{}
 	ss.ECoffWts = &etensor.Float32{}
 	ss.ECWts = &etensor.Float32{}
+	ss.Queue.Defaults()
 	ss.RunLog = &etable.Table{}
+	ss.LapLog = &etable.Table{}
+	ss.ChoiceLog = &etable.Table{}
+	ss.Choice.Defaults()
+	ss.SRLog = &etable.Table{}
+	ss.SR.Defaults()
+	ss.SelfLocLog = &etable.Table{}
+	ss.VestibLog = &etable.Table{}
+	ss.OcclLog = &etable.Table{}
+	ss.AdaptLog = &etable.Table{}
+	ss.ArenaLog = &etable.Table{}
+	ss.UnitClassLog = &etable.Table{}
+	ss.UnitClass.Defaults()
+	ss.UnitGroupLog = &etable.Table{}
+	ss.UnitGroups.Defaults()
+	ss.BorderLog = &etable.Table{}
+	ss.Border.Defaults()
+	ss.SpeedLog = &etable.Table{}
+	ss.WtChgLog = &etable.Table{}
+	ss.Speed.Defaults()
+	ss.TaskLog = &etable.Table{}
+	ss.Task.Defaults()
+	ss.LatDiagLog = &etable.Table{}
+	ss.LatKernLog = &etable.Table{}
+	ss.LatDiag.Defaults()
+	ss.ActLog = &etable.Table{}
+	ss.ActRegLog = &etable.Table{}
+	ss.ActStats.Defaults()
+	ss.TrainEnv.Stuck.Defaults()
+	ss.TrainEnv.Scan.Defaults()
+	ss.TrainEnv.GridMix.Defaults()
+	ss.SelfLoc.Defaults()
+	ss.Shuffle.Defaults()
+	ss.SimMat.Defaults()
+	ss.LinDec.Defaults()
+	ss.Conj.Defaults()
+	ss.ErrTrace.Defaults()
+	ss.Report.Defaults()
+	ss.PlotExport.Defaults()
+	ss.ParamAudit.Defaults()
+	ss.RandWorld.Defaults()
+	ss.Adapt.Defaults()
+	ss.Bench.Defaults()
+	ss.CkptEval.Defaults()
+	ss.LogView.Defaults()
+	ss.Demo.Defaults()
+	ss.PlotUpdt.Defaults()
+	ss.ExecHook.Every = 1
+	ss.Watchdog.Defaults()
+	ss.LogCfgs = make(map[string]*LogConfig)
 	ss.RunStats = &etable.Table{}
+	ss.HDTuning = &etable.Table{}
 	ss.Params = ParamSets
 	ss.RndSeed = 1
+	ss.ShufRand = rand.New(rand.NewSource(ss.RndSeed))
 	ss.ViewOn = true
 	ss.TrainUpdt = leabra.Cycle
 	ss.TestUpdt = leabra.Cycle
+	ss.MinusQtrs = 3
+	ss.PlusQtrs = 1
+	ss.CycPerQtr = 25
+	ss.TrialSteps = 1
+	ss.SupFrac = 1
+	ss.SupLays = []string{"Out_Position", "Orientation"}
+	ss.Supervised = true
 	ss.ARFLayers = []string{"EC", "Orientation", "Out_Position"}
 	ss.EClateralflag = true
 
 	ss.Entorhinal.Defaults()
+	ss.PosDecode.Defaults()
 	ss.Pat.Defaults()
 }
 
@@ -351,6 +645,9 @@ func (ec *EcParams) Defaults() {
 	ec.VestibularSize.Set(12, 1)
 	ec.InputPctAct = 0.25
 	ec.OrientationPctAct = 0.25
+	ec.InPCon = 1
+	ec.GTauVar.Dist = erand.Uniform
+	ec.GTauVar.Var = 0
 
 	//ec.excitRadius2D = 5
 	//ec.excitSigma2D = 3
@@ -361,6 +658,8 @@ func (ec *EcParams) Defaults() {
 	ec.excitSigma4D = 2
 	ec.inhibRadius4D = 2 // def 5 (Pos Gi 3.6 works), smaller (5) for dMEC, higher (8) for vMEC
 	ec.inhibSigma4D = 2  // not really sure what this should be, seems like as long as it's not too small it's fine, 2 looks best
+	ec.KernelScale = true
+	ec.KernelRef = 10 // the ECSize the kernels above are tuned for
 }
 
 func (pp *PatParams) Defaults() {
@@ -374,15 +673,35 @@ func (pp *PatParams) Defaults() {
 
 // Config configures all the elements using the standard functions
 func (ss *Sim) Config() {
-	//ss.OpenPats()
 	//ss.ConfigPats()
 	ss.ConfigEnv()
 	ss.ConfigNet(ss.Net)
+	ss.ConfigInputs()
+	ss.ConfigUnitGroups()
+	ss.UpdtPrjnCtrls()
 	ss.ConfigTrnTrlLog(ss.TrnTrlLog)
 	ss.ConfigTrnEpcLog(ss.TrnEpcLog)
 	ss.ConfigTstEpcLog(ss.TstEpcLog)
 	ss.ConfigTstTrlLog(ss.TstTrlLog)
 	ss.ConfigRunLog(ss.RunLog)
+	ss.ConfigLapLog(ss.LapLog)
+	ss.ConfigChoiceLog(ss.ChoiceLog)
+	ss.ConfigSRLog(ss.SRLog)
+	ss.ConfigSelfLocLog(ss.SelfLocLog)
+	ss.ConfigOcclLog(ss.OcclLog)
+	ss.ConfigAdaptLog(ss.AdaptLog)
+	ss.ConfigArenaLog(ss.ArenaLog)
+	ss.ConfigUnitClassLog(ss.UnitClassLog)
+	ss.ConfigUnitGroupLog(ss.UnitGroupLog)
+	ss.ConfigBorderLog(ss.BorderLog)
+	ss.ConfigSpeedLog(ss.SpeedLog)
+	ss.ConfigWtChgLog(ss.WtChgLog)
+	ss.ConfigTaskLog(ss.TaskLog)
+	ss.ConfigLatDiagLog(ss.LatDiagLog)
+	ss.ConfigLatKernLog(ss.LatKernLog)
+	ss.ConfigActLog(ss.ActLog)
+	ss.ConfigActRegLog(ss.ActRegLog)
+	ss.ConfigVestibLog(ss.VestibLog)
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -390,8 +709,8 @@ func (ss *Sim) ConfigEnv() {
 		ss.MaxRuns = 1
 	}
 	if ss.MaxEpcs == 0 { // allow user override
-		ss.MaxEpcs = 200   // zycyc, test, 1000
-		ss.TestEpcs = 1000 // zycyc, test, needs to be extra high, 3000
+		ss.MaxEpcs = 200  // zycyc, test, 1000
+		ss.TestEpcs = 800 // zycyc, test, needs to be extra high, 2800
 	}
 	//if ss.MaxTrls == 0 { // allow user override
 	//	ss.MaxTrls = 100
@@ -403,8 +722,17 @@ func (ss *Sim) ConfigEnv() {
 	ss.TrainEnv.Run.Max = ss.MaxRuns // note: we are not setting epoch max -- do that manually
 	ss.TrainEnv.Init(0)
 	ss.TrainEnv.Validate()
+	ss.Shuffle.Env = &ss.TrainEnv
+	ss.RandWorld.Eval = nil // re-load or re-generate in NewRun
+
+	ss.ConfigTestEnv()
+	if ss.PatsFile != "" {
+		ss.TrainEnv.OpenPats(gi.FileName(ss.PatsFile))
+		ss.TestEnv.OpenPats(gi.FileName(ss.PatsFile))
+	}
 
 	ss.ConfigRFMaps()
+	ss.GenProbes(ss.Probes)
 }
 
 func (ss *Sim) ConfigRFMaps() {
@@ -422,8 +750,19 @@ func (ss *Sim) ConfigRFMaps() {
 	ss.RFMaps["Rot"] = mt
 }
 
+// AddARFLayer adds given layer to the ARFLayers, if not already there
+func (ss *Sim) AddARFLayer(lnm string) {
+	for _, nm := range ss.ARFLayers {
+		if nm == lnm {
+			return
+		}
+	}
+	ss.ARFLayers = append(ss.ARFLayers, lnm)
+}
+
 func (ss *Sim) ConfigNet(net *leabra.Network) {
 	ecParam := &ss.Entorhinal
+	ecParam.OrientationSize.X = ss.TrainEnv.RingSize // must match env Angle patterns
 	net.InitName(net, "can_ec")
 	prevPosition := net.AddLayer2D("Prev_Position", ecParam.PositionSize.Y, ecParam.PositionSize.X, emer.Input)
 	prevPosition.SetClass("Position")
@@ -432,8 +771,22 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 
 	vestibular := net.AddLayer2D("Vestibular", ecParam.VestibularSize.Y, ecParam.VestibularSize.X, emer.Input)
 	vestibular.SetClass("Orientation")
-	ec := net.AddLayer4D("EC", ecParam.ECSize.Y, ecParam.ECSize.X, 2, 2, emer.Hidden)
-	// ec := net.AddLayer2D("EC", 16, 16, emer.Hidden)
+	var s1 emer.Layer
+	if ss.S1On {
+		s1 = net.AddLayer4D("S1", 1, 4, ss.TrainEnv.ProxRange, 1, emer.Input)
+		s1.SetClass("S1")
+	}
+	mods := ecParam.ECModules()
+	if err := ecParam.ValidateKernels(); err != nil {
+		log.Println(err)
+	}
+	ecs := make([]emer.Layer, len(mods))
+	for mi := range mods {
+		ecl := &ECLayer{GTauVar: ecParam.GTauVar, GTauPool: ecParam.GTauPerPool}
+		net.AddLayerInit(ecl, ECModuleName(mi), ecParam.ECShape(), emer.Hidden)
+		ecl.SetClass("EC")
+		ecs[mi] = ecl
+	}
 
 	outPosition := net.AddLayer2D("Out_Position", ecParam.PositionSize.Y, ecParam.PositionSize.X, emer.Target)
 	outPosition.SetClass("Position")
@@ -441,26 +794,31 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 	orientation := net.AddLayer2D("Orientation", ecParam.OrientationSize.Y, ecParam.OrientationSize.X, emer.Target)
 	orientation.SetClass("Orientation")
 
-	//////////////////////////////////////////// EC first for indexing convenience
-	//ec := net.AddLayer2D("EC", ecParam.ECSize.Y, ecParam.ECSize.X, emer.Hidden) // 2D EC
+	var goalDir, goalDist emer.Layer
+	if ss.GoalOn {
+		goalDir = net.AddLayer2D("GoalDir", ecParam.OrientationSize.Y, ecParam.OrientationSize.X, emer.Target)
+		goalDir.SetClass("Goal")
+		goalDist = net.AddLayer2D("GoalDist", ecParam.VestibularSize.Y, ecParam.VestibularSize.X, emer.Target)
+		goalDist.SetClass("Goal")
+	}
 
-	// 2D EC
-	//excit := prjn.NewCircle()
-	//excit.TopoWts = true
-	//excit.Radius = ecParam.excitRadius2D
-	//excit.Sigma = ecParam.excitSigma2D
+	var action emer.Layer
+	if ss.ActOn {
+		action = net.AddLayer2D("Action", ss.TrainEnv.PatSize.Y, ss.TrainEnv.PatSize.X, emer.Target)
+		action.SetClass("Action")
+	}
 
-	//inhib := prjn.NewCircle()
-	//inhib.TopoWts = true
-	//inhib.Radius = ecParam.inhibRadius2D
-	//inhib.Sigma = ecParam.inhibSigma2D
+	var conj emer.Layer
+	if ss.Conj.On && ecParam.EC2D {
+		log.Println("Conj requires the 4D EC, as it receives pool-to-pool from it: not added with EC2D")
+	}
+	if ss.Conj.On && !ecParam.EC2D {
+		conj = net.AddLayer4D("Conj", ecParam.ECSize.Y, ecParam.ECSize.X, ss.Conj.PoolSize.Y, ss.Conj.PoolSize.X, emer.Hidden)
+		conj.SetClass("Conj")
+	}
 
-	//rect := prjn.NewRect()
-	//rect.Size.Set(1, 1)
-	//orie := net.ConnectLayers(orientation, ec, rect, emer.Forward)
-	//orie.SetClass("OrientationForward")
+	//////////////////////////////////////////// EC first for indexing convenience
 
-	// 4D EC
 	//excit := prjn.NewCircle()
 	//excit.TopoWts = true
 	//excit.Radius = ecParam.excitRadius4D
@@ -475,11 +833,6 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 		//excit.TopoRange.Min = 0.8
 		//excit.GaussInPool.On = false
 
-		inhib := prjn.NewCircle()
-		inhib.TopoWts = true
-		inhib.Radius = ecParam.inhibRadius4D
-		inhib.Sigma = ecParam.inhibSigma4D
-
 		// inhib := prjn.NewPoolTile()
 		// inhib.Size.Set(2*ecParam.inhibRadius4D+1, 2*ecParam.inhibRadius4D+1)
 		// inhib.Skip.Set(1, 1)
@@ -489,20 +842,83 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 
 		//rec := net.ConnectLayers(ec, ec, excit, emer.Lateral)
 		//rec.SetClass("ExciteLateral")
+		if ecParam.ExcitLateral && !ecParam.EC2D {
+			erad, _ := ecParam.ExcitKernel()
+			excit := prjn.NewPoolTile()
+			excit.Size.Set(2*erad+1, 2*erad+1)
+			excit.Skip.Set(1, 1)
+			excit.Start.Set(-erad, -erad)
+			excit.Wrap = true
+			rec := net.ConnectLayers(ecs[0], ecs[0], excit, emer.Lateral)
+			rec.SetClass("ExciteLateral")
+		}
 
 		//inh := net.ConnectLayers(ec, ec, full, emer.Inhib)
-		inh := net.ConnectLayers(ec, ec, inhib, emer.Inhib)
-		inh.SetClass("InhibLateral")
+		for mi, mod := range mods {
+			var inhib prjn.Pattern
+			if ecParam.TwistTorus {
+				tt := NewTwistTorus()
+				tt.TopoWts = true
+				tt.Radius = float32(mod.InhibRadius * ecParam.PoolSide())
+				tt.Sigma = mod.InhibSigma
+				inhib = tt
+			} else {
+				cr := prjn.NewCircle()
+				cr.TopoWts = true
+				cr.Radius = mod.InhibRadius
+				cr.Sigma = mod.InhibSigma
+				inhib = cr
+			}
+			inhSend := ecs[mi]
+			if ecParam.Dale {
+				inhSend = ConfigECInh(net, ecs[mi])
+			}
+			inh := net.ConnectLayers(inhSend, ecs[mi], inhib, emer.Inhib)
+			inh.SetClass("InhibLateral")
+		}
 	}
 	//////////////////////////////////////////// other connections
 	full := prjn.NewFull()
+	var inPat prjn.Pattern = full
+	if ecParam.InPCon < 1 {
+		sparse := prjn.NewUnifRnd()
+		sparse.PCon = ecParam.InPCon
+		inPat = sparse
+	}
+
+	for _, ec := range ecs {
+		// input prjns support conduction delays via Prjn.Delay params
+		net.ConnectLayersPrjn(prevPosition, ec, inPat, emer.Forward, &DelayPrjn{})
+		net.ConnectLayersPrjn(prevOri, ec, inPat, emer.Forward, &DelayPrjn{})
+		net.ConnectLayersPrjn(vestibular, ec, inPat, emer.Forward, &DelayPrjn{})
+		if s1 != nil {
+			net.ConnectLayersPrjn(s1, ec, inPat, emer.Forward, &DelayPrjn{})
+		}
 
-	net.ConnectLayers(prevPosition, ec, full, emer.Forward)
-	net.ConnectLayers(prevOri, ec, full, emer.Forward)
-	net.ConnectLayers(vestibular, ec, full, emer.Forward)
-
-	net.BidirConnectLayers(ec, outPosition, full)
-	net.BidirConnectLayers(ec, orientation, full)
+		fw, _ := net.BidirConnectLayers(ec, outPosition, full)
+		fw.SetClass("ECToPosition")
+		fw, _ = net.BidirConnectLayers(ec, orientation, full)
+		fw.SetClass("ECToOrientation")
+		if ss.GoalOn {
+			_, bk := net.BidirConnectLayers(ec, goalDir, full)
+			bk.SetClass("GoalBack")
+			_, bk = net.BidirConnectLayers(ec, goalDist, full)
+			bk.SetClass("GoalBack")
+		}
+		if ss.ActOn {
+			_, bk := net.BidirConnectLayers(ec, action, full)
+			bk.SetClass("ActionBack")
+		}
+	}
+	if conj != nil {
+		pool1to1 := prjn.NewPoolOneToOne()
+		for _, ec := range ecs {
+			pj := net.ConnectLayers(ec, conj, pool1to1, emer.Forward)
+			pj.SetClass("ECToConj")
+		}
+		pj := net.ConnectLayers(orientation, conj, full, emer.Forward)
+		pj.SetClass("OrientationToConj")
+	}
 
 	//one2one := prjn.NewOneToOne()
 	//net.LateralConnectLayer(outPosition, full)
@@ -510,15 +926,23 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 
 	//net.LateralConnectLayer(orientation, one2one)
 
-	prevOri.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "Prev_Position", YAlign: relpos.Front, Space: 2})
-	vestibular.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "Prev_Orientation", YAlign: relpos.Front, Space: 2})
-	ec.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: "Prev_Position", XAlign: relpos.Left, YAlign: relpos.Front, Space: 0})
-	outPosition.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: "EC", XAlign: relpos.Left, YAlign: relpos.Front, Space: 0})
-	orientation.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "Out_Position", YAlign: relpos.Front, Space: 2})
+	lay := netlayout.Layout{}
+	lay.Defaults()
+	lay.Space = 2
+	lay.Levels = map[string]int{"Conj": 1} // next to EC, not above Orientation
+	if err := lay.Apply(net); err != nil {
+		log.Println(err)
+	}
 
 	//////////////////////////////////////
 	// collect
 
+	for mi := 1; mi < len(ecs); mi++ { // ARFs on all modules
+		ss.AddARFLayer(ECModuleName(mi))
+	}
+	if conj != nil {
+		ss.AddARFLayer("Conj")
+	}
 	if len(ss.InputLays) == 0 && len(ss.TargetLays) == 0 {
 		ss.InputLays = make([]string, 0, 10)
 		ss.TargetLays = make([]string, 0, 10)
@@ -549,6 +973,9 @@ func (ss *Sim) ReConfigNet() {
 	//ss.ConfigPats()
 	ss.Net = &leabra.Network{} // start over with new network
 	ss.ConfigNet(ss.Net)
+	ss.ConfigInputs()
+	ss.ConfigUnitGroups()
+	ss.UpdtPrjnCtrls()
 	if ss.NetView != nil {
 		ss.NetView.SetNet(ss.Net)
 		ss.NetView.Update() // issue #41 closed
@@ -557,48 +984,68 @@ func (ss *Sim) ReConfigNet() {
 
 func (ss *Sim) InitWts(net *leabra.Network) {
 	net.InitTopoScales() // needed for gaussian topo Circle wts
+	InitTwistTorusScales(net)
 	net.InitWts()
+	if ss.InitSD != "" {
+		ss.OpenStateDict(gi.FileName(ss.InitSD))
+	}
 
 	// only if we need orientation bias in single EC cells
-	//if ss.EClateralflag {
-	//	ss.InitLateralWts(net)
-	//}
+	if ss.EClateralflag && ss.Entorhinal.ExcitLateral {
+		ss.InitLateralWts(net)
+	}
 }
 
+// InitLateralWts sets the EC ExciteLateral weights from the cached LatKernel,
+// in parallel across rows of receiving pools
 func (ss *Sim) InitLateralWts(net *leabra.Network) {
 	ecParam := &ss.Entorhinal
 	ec := net.LayerByName("EC").(leabra.LeabraLayer).AsLeabra()
-	lat := ec.RecvPrjn(0) // ?? zycyc: fix this
+	var lat emer.Prjn
+	for _, pj := range ec.RcvPrjns {
+		if pj.Type() == emer.Lateral {
+			lat = pj
+			break
+		}
+	}
+	if lat == nil || !ec.Is4D() {
+		return
+	}
 	nPy := ec.Shape().Dim(0)
 	nPx := ec.Shape().Dim(1)
 	//radius := ecParam.excitRadius2D // 2D EC
-	radius := ecParam.excitRadius4D // 4D EC
+	radius, sigma := ecParam.ExcitKernel() // 4D EC
 
-	offsets := []float32{1, 1, -1, 1, 1, -1, -1, -1} // four corners
 	//offsets := []float32{0, -1, 1, 0, -1, 0, 0, 1} // up, down, left, right
+	ss.LatKernel.Update(radius, sigma)
+	lk := &ss.LatKernel
 
 	// 4D EC
+	var wg sync.WaitGroup
 	for py := 0; py < nPy; py++ {
-		for px := 0; px < nPx; px++ {
-			sri := (py*nPx + px) * 4
-			for i := 0; i < 4; i++ { // 4 receiving units
-				ri := sri + i
-				rctr := mat32.Vec2{offsets[i*2], offsets[i*2+1]} // center of gaussian for recv unit
-				for sy := -radius; sy <= radius; sy++ {          // circle
-					for sx := -radius; sx <= radius; sx++ {
-						for j := 0; j < 4; j++ { // 4 sending units
-							spy, _ := edge.Edge(py+sy, nPy, true)
+		wg.Add(1)
+		go func(py int) { // each goroutine sets a distinct set of receiving units
+			defer wg.Done()
+			for px := 0; px < nPx; px++ {
+				sri := (py*nPx + px) * 4
+				for i := 0; i < 4; i++ { // 4 receiving units
+					ri := sri + i
+					for sy := -radius; sy <= radius; sy++ { // circle
+						spy, _ := edge.Edge(py+sy, nPy, true)
+						for sx := -radius; sx <= radius; sx++ {
 							spx, _ := edge.Edge(px+sx, nPx, true)
-							si := (spy*nPx+spx)*4 + j
-							v := mat32.NewVec2(float32(sx), float32(sy))
-							wt := efuns.GaussVecDistNoNorm(v, rctr, ecParam.excitSigma4D)
-							lat.SetSynVal("Wt", si, ri, wt)
+							wt := lk.Wt(i, sy, sx)
+							for j := 0; j < 4; j++ { // 4 sending units
+								si := (spy*nPx+spx)*4 + j
+								lat.SetSynVal("Wt", si, ri, wt)
+							}
 						}
 					}
 				}
 			}
-		}
+		}(py)
 	}
+	wg.Wait()
 
 	// 2D EC
 	//for py := 0; py < nPy; py++ {
@@ -669,7 +1116,9 @@ func (ss *Sim) InitLateralWts(net *leabra.Network) {
 func (ss *Sim) Init() {
 	rand.Seed(ss.RndSeed)
 	ss.StopNow = false
+	ss.RunsDone = false
 	ss.SetParams("", false) // all sheets
+	ss.ParamsChanges = ""
 	ss.ReConfigNet()
 	ss.ConfigEnv() // re-config env just in case a different set of patterns was
 	ss.NewRun()
@@ -691,13 +1140,18 @@ func (ss *Sim) Counters(train bool) string {
 		//return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tTrial:\t%d\tCycle:\t%d\tName:\t%s\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Trial.Cur, ss.Time.Cycle, ss.TrainEnv.TrialName.Cur)
 		return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tEvent:\t%d\tCycle:\t%d\tAct:\t%v\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Event.Cur, ss.Time.Cycle, ss.ActAction)
 	} else {
-		return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tTrial:\t%d\tCycle:\t%d\tName:\t%s\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Event.Cur, ss.Time.Cycle, ss.ActAction)
+		return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tTrial:\t%d\tCycle:\t%d\tName:\t%s\t\t\t", ss.TestEnv.Run.Cur, ss.TestEnv.Epoch.Cur, ss.TestEnv.Event.Cur, ss.Time.Cycle, ss.ActAction)
 	}
 }
 
 func (ss *Sim) UpdateView(train bool) {
+	ss.Timers.GUI.Start()
+	defer ss.Timers.GUI.Stop()
+	ss.GuiMu.Lock()
+	defer ss.GuiMu.Unlock()
 	if ss.NetView != nil && ss.NetView.IsVisible() {
 		ss.NetView.Record(ss.Counters(train))
+		ss.HighlightUnitGroup(ss.NetView)
 		// note: essential to use Go version of update when called from another goroutine
 		ss.NetView.GoUpdate() // note: using counters is significantly slower..
 	}
@@ -714,60 +1168,149 @@ func (ss *Sim) UpdateView(train bool) {
 // Handles netview updating within scope of AlphaCycle
 func (ss *Sim) AlphaCyc(train bool) {
 	// ss.Win.PollEvents() // this can be used instead of running in a separate goroutine
-	viewUpdt := ss.TrainUpdt
+	ss.AlphaCycStart(train)
+	for {
+		if _, done := ss.AlphaCycCycle(); done {
+			break
+		}
+	}
+}
+
+// AlphaCycStart starts an alpha cycle, to be run one cycle at a time by
+// AlphaCycCycle, as AlphaCyc does, or by the training stepper
+func (ss *Sim) AlphaCycStart(train bool) {
+	as := &ss.Alpha
+	as.Active = true
+	as.Train = train
+	as.ViewUpdt = ss.TrainUpdt
 	if !train {
-		viewUpdt = ss.TestUpdt
+		as.ViewUpdt = ss.TestUpdt
 	}
+	as.Qtr = 0
+	as.Cyc = 0
+	as.SubStep = 1 // first step taken before the trial
 
 	// update prior weight changes at start, so any DWt values remain visible at end
 	// you might want to do this less frequently to achieve a mini-batch update
 	// in which case, move it out to the TrainTrial method where the relevant
 	// counters are being dealt with.
 	if train {
+		ss.Timers.DWt.Start()
 		ss.Net.WtFmDWt()
+		ss.Timers.DWt.Stop()
 	}
 
+	as.DecCyc = false // set by TrainTrialStart on approach to choice point
+
 	ss.Net.AlphaCycInit(train)
+	ss.Time.CycPerQtr = ss.CycPerQtr
 	ss.Time.AlphaCycStart()
-	for qtr := 0; qtr < 4; qtr++ {
-		for cyc := 0; cyc < ss.Time.CycPerQtr; cyc++ {
-			ss.Net.Cycle(&ss.Time)
-			ss.Time.CycleInc()
-			if ss.ViewOn {
-				switch viewUpdt {
-				case leabra.Cycle:
-					if cyc != ss.Time.CycPerQtr-1 { // will be updated by quarter
-						ss.UpdateView(train)
-					}
-				case leabra.FastSpike:
-					if (cyc+1)%10 == 0 {
-						ss.UpdateView(train)
-					}
-				}
+}
+
+// AlphaCycCycle runs the next cycle of the alpha cycle started by
+// AlphaCycStart, returning qtrEnd = true if it was the last cycle of a
+// quarter, and done = true if it was the last cycle of the alpha cycle,
+// which is then finished (DWt)
+func (ss *Sim) AlphaCycCycle() (qtrEnd, done bool) {
+	as := &ss.Alpha
+	train := as.Train
+	viewUpdt := as.ViewUpdt
+	nqtr := ss.MinusQtrs + ss.PlusQtrs
+	qtr := as.Qtr
+	cyc := as.Cyc
+	lq := ss.LeabraQtr(qtr)
+	if cyc == 0 {
+		ss.Time.Quarter = lq
+		if lq < 0 {
+			ss.Time.Quarter = 3
+		}
+		ss.Time.PlusPhase = qtr >= ss.MinusQtrs
+	}
+	ss.TrialSubStep()
+	ss.ClampCycle()
+	ss.Timers.Cycle.Start()
+	ss.Net.Cycle(&ss.Time)
+	ss.Timers.Cycle.Stop()
+	ss.Time.CycleInc()
+	if as.DecCyc && !ss.Time.PlusPhase { // minus phase only
+		ss.ChoiceCycle()
+	}
+	if ss.ViewOn {
+		switch viewUpdt {
+		case leabra.Cycle:
+			if cyc != ss.Time.CycPerQtr-1 { // will be updated by quarter
+				ss.UpdateView(train)
+			}
+		case leabra.FastSpike:
+			if (cyc+1)%10 == 0 {
+				ss.UpdateView(train)
 			}
 		}
+	}
+	as.Cyc++
+	if as.Cyc < ss.Time.CycPerQtr {
+		return false, false
+	}
+	if lq >= 0 {
 		ss.Net.QuarterFinal(&ss.Time)
-		ss.Time.QuarterInc()
-		if ss.ViewOn {
-			switch {
-			case viewUpdt <= leabra.Quarter:
+	}
+	if ss.ViewOn {
+		switch {
+		case viewUpdt <= leabra.Quarter:
+			ss.UpdateView(train)
+		case viewUpdt == leabra.Phase:
+			if qtr == ss.MinusQtrs-1 || qtr == nqtr-1 {
 				ss.UpdateView(train)
-			case viewUpdt == leabra.Phase:
-				if qtr >= 2 {
-					ss.UpdateView(train)
-				}
 			}
 		}
 	}
+	as.Qtr++
+	as.Cyc = 0
+	if as.Qtr < nqtr {
+		return true, false
+	}
+	ss.AlphaCycEnd()
+	return true, true
+}
 
-	if train {
+// AlphaCycEnd finishes the alpha cycle -- called by AlphaCycCycle after the
+// last cycle
+func (ss *Sim) AlphaCycEnd() {
+	as := &ss.Alpha
+	as.Active = false
+	ss.Time.Quarter = 4 // as after QuarterInc at end of standard alpha cycle
+	ss.Time.PlusPhase = false
+
+	if as.Train {
+		ss.Timers.DWt.Start()
 		ss.Net.DWt()
+		ss.Timers.DWt.Stop()
+		ss.WtChgTrial()
 	}
-	if ss.ViewOn && viewUpdt == leabra.AlphaCycle {
-		ss.UpdateView(train)
+	if ss.ViewOn && as.ViewUpdt == leabra.AlphaCycle {
+		ss.UpdateView(as.Train)
 	}
 }
 
+// LeabraQtr returns the leabra Time.Quarter for given quarter of the trial,
+// under the MinusQtrs / PlusQtrs phase structure: the last minus quarter is 2
+// and the last plus quarter is 3, so that QuarterFinal records ActM and ActP
+// (and clamps targets) at the end of each phase.  Returns -1 for plus quarters
+// before the last one, for which QuarterFinal is not called.
+func (ss *Sim) LeabraQtr(qtr int) int {
+	switch {
+	case qtr == ss.MinusQtrs-1:
+		return 2
+	case qtr == 0:
+		return 0
+	case qtr < ss.MinusQtrs:
+		return 1
+	case qtr == ss.MinusQtrs+ss.PlusQtrs-1:
+		return 3
+	}
+	return -1
+}
+
 //// QuarterInc increments at the quarter level, updating Quarter and PlusPhase
 //func (ss *Sim) QuarterInc() {
 //	tm := &ss.Time
@@ -788,10 +1331,24 @@ func (ss *Sim) TakeAction(net *leabra.Network, ev *XYHDEnv) {
 	//ev.Action(ss.ActAction, nil)
 
 	//multiple steps per trial
+	ss.TrlSteps = ss.TrlSteps[:0]
+	traj := ev == &ss.TrainEnv
+	if traj {
+		defer func() { ss.Traj.Call++ }()
+		if ss.Traj.Replaying() && ss.TrajReplay(ev) {
+			return
+		}
+	}
 	for i := 1; i <= rand.Intn(10)+10; i++ {
-		gact := ev.ActGen()
-		ss.ActAction = ev.Acts[gact]
-		ev.Action(ss.ActAction, nil)
+		gact := ss.TaskAct(ev)
+		ss.ActAction = gact.String()
+		pos, pf := ev.PosI, ev.PosF
+		ev.DoAction(gact)
+		ss.ExecAction = ev.ActExec.String()
+		ss.TrlSteps = append(ss.TrlSteps, ActStep{Act: ev.ActExec.Act, Pos: pos, Moved: ev.PosI != pos, Dist: ev.PosF.Sub(pf).Length(), Bout: ev.Bout})
+		if traj && ss.Traj.Recording() {
+			ss.TrajRecord(ev, ss.ActAction)
+		}
 	}
 
 	// fmt.Printf("action: %s\n", ev.Acts[act])
@@ -800,71 +1357,151 @@ func (ss *Sim) TakeAction(net *leabra.Network, ev *XYHDEnv) {
 // ApplyInputs applies input patterns from given environment.
 // It is good practice to have this be a separate method with appropriate
 // args so that it can be used for various different contexts
-// (training, testing, etc).
+// (training, testing, etc).  The training manipulations of the inputs
+// (self-localization, vestibular gain, cue rotation, occlusion) only apply
+// to the TrainEnv, and are set up for the trial here, so that the sub-steps
+// of the trial (see TrialSubStep) keep them.
 func (ss *Sim) ApplyInputs(en env.Env) {
 	//ss.Net.InitExt() // clear any existing inputs -- not strictly necessary if always
 	// going to the same layers, but good practice and cheap anyway
 
-	states := []string{"Vestibular", "Position", "Angle", "Position", "Angle"} // autoencoder
-	//states := []string{"Vestibular", "Position", "Angle", "PrevPosition", "PrevAngle"} // predictive learning
-	lays := []string{"Vestibular", "Out_Position", "Orientation", "Prev_Position", "Prev_Orientation"}
+	if en == env.Env(&ss.TrainEnv) {
+		ss.OcclTrial()
+		ss.AdaptTrial()
+		ss.VestibGainTrial()
+	}
+	if ev, ok := en.(*XYHDEnv); ok {
+		ss.Alpha.Env = ev
+	}
+	ss.ApplyStatePats(en, false)
+}
 
-	for i, lnm := range lays {
+// ApplyStatePats applies the patterns of the current state of given env to
+// the layers of the Inputs, with the training manipulations set up for the
+// trial by ApplyInputs.  For a sub-step within the trial (sub = true), the
+// self-localization estimate, which is of the start of the trial, is not
+// blended into the Prev inputs.
+func (ss *Sim) ApplyStatePats(en env.Env, sub bool) {
+	train := en == env.Env(&ss.TrainEnv)
+	for _, im := range ss.Inputs {
+		lnm := im.Layer
 		lyi := ss.Net.LayerByName(lnm)
 		if lyi == nil {
 			continue
 		}
 		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
-		pats := en.State(states[i])
+		pats := en.State(im.State)
 
 		//pats := en.State(ly.Nm)
+		if train && ss.PretrainOff(lnm) {
+			ss.ClampTrial(lnm, nil) // off in pretraining
+			ly.InitExt()
+			continue
+		}
+		if !ss.Supervised && ss.IsSupLay(lnm) {
+			ss.ClampTrial(lnm, nil) // no target this trial
+			ly.InitExt()
+			continue
+		}
 		if pats != nil {
+			if train {
+				if !sub {
+					pats = ss.SelfLocPats(lnm, im.State, pats)
+				}
+				pats = ss.VestibPats(lnm, im.State, pats)
+				pats = ss.CuePats(lnm, im.State, pats)
+				pats = ss.OcclPats(lnm, pats)
+				pats = ss.AdaptPats(lnm, pats)
+			}
+			if ss.ClampTrial(lnm, pats) { // applied in ClampCycle
+				continue
+			}
 			ly.ApplyExt(pats)
 		}
 	}
 }
 
-// TrainTrial runs one trial of training using TrainEnv
+// TrainTrial runs one trial of training using TrainEnv, or the rest of the
+// current trial if it was stepped partway through
 func (ss *Sim) TrainTrial() {
+	ss.TrainStep(StepTrial)
+}
+
+// TrainEnvStep takes the action and steps the TrainEnv for a new training
+// trial, with the epoch and run level bookkeeping when the epoch changes --
+// returns false if the run (or all runs) ended instead.
+// First stage of a trial in the training stepper.
+func (ss *Sim) TrainEnvStep() bool {
 	if ss.NeedsNewRun {
 		ss.NewRun()
 	}
 
+	ss.Shuffle.Unshuffle()
 	ss.TakeAction(ss.Net, &ss.TrainEnv)
+	ss.ActStats.AddTrial(ss.TrlSteps, ss.TrainEnv.Size)
+	ss.Speed.AddSteps(ss.TrlSteps)
 	ss.TrainEnv.Step() // the Env encapsulates and manages all counter state
 
 	// Key to query counters FIRST because current state is in NEXT epoch
 	// if epoch counter has changed
 	epc, _, chg := ss.TrainEnv.Counter(env.Epoch)
 	if chg {
+		ss.Timers.Log.Start()
 		ss.LogTrnEpc(ss.TrnEpcLog)
+		ss.Timers.Log.Stop()
+		ss.SaveCkpt(epc)
 		if ss.ViewOn && ss.TrainUpdt > leabra.AlphaCycle {
 			ss.UpdateView(true)
 		}
+		ss.ProtocolEpoch(epc)
+		ss.AdaptEpoch(epc)
+		ss.ArenaEpoch(epc)
+		ss.PeriodicEpoch(epc)
 
 		if epc >= ss.MaxEpcs {
 			if ss.SaveWts { // doing this earlier
 				ss.SaveWeights()
 			}
 			// done with training..
-			if ss.SaveARFs {
+			if ss.SaveARFs || ss.ActExport.On {
 				ss.TestAll()
 			}
 			ss.RunEnd()
 			if ss.TrainEnv.Run.Incr() { // we are done!
+				ss.RunsDone = true
 				ss.StopNow = true
-				return
+				return false
 			} else {
 				ss.NeedsNewRun = true
-				return
+				return false
 			}
 		}
 	}
+	return true
+}
 
+// TrainTrialStart applies the inputs for the training trial and starts its
+// alpha cycle -- second stage of a trial in the training stepper, followed
+// by the cycles (AlphaCycCycle) and TrainTrialEnd
+func (ss *Sim) TrainTrialStart() {
+	ss.Shuffle.Shuffle()
+	ss.SupTrial()
+	ss.ITICycles()
 	ss.ApplyInputs(&ss.TrainEnv)
-	ss.AlphaCyc(true)   // train
+	ss.AlphaCycStart(!ss.Protocol.Frozen()) // train, unless frozen by the protocol
+	ss.Alpha.DecCyc = ss.ChoiceTrial()      // decode position every cycle on approach to choice point
+}
+
+// TrainTrialEnd computes the stats and logs the training trial after its
+// alpha cycle -- last stage of a trial in the training stepper
+func (ss *Sim) TrainTrialEnd() {
 	ss.TrialStats(true) // accumulate
+	ss.SRTrial()
+	ss.SelfLocTrial()
+	ss.VestibTrial()
+	ss.Timers.Log.Start()
 	ss.LogTrnTrl(ss.TrnTrlLog)
+	ss.Timers.Log.Stop()
 	if ss.CurImgGrid != nil {
 		ss.CurImgGrid.UpdateSig()
 	}
@@ -873,9 +1510,19 @@ func (ss *Sim) TrainTrial() {
 // RunEnd is called at the end of a run -- save weights, record final log, etc here
 func (ss *Sim) RunEnd() {
 	ss.LogRun(ss.RunLog)
+	if ss.SaveSD {
+		ss.SaveStateDict(gi.FileName(ss.StateDictFileName()))
+	}
 	if ss.SaveARFs {
 		ss.SaveAllARFs()
 	}
+	ss.RunAnalyses()
+	ss.SavePeriodicLogs()
+	ss.SaveUnitGroupLog()
+	ss.SaveWtChgLog()
+	ss.SaveTraj()
+	ss.SaveReport()
+	ss.SavePlots()
 }
 
 // NewRun initializes a new run of the model, using the TrainEnv.Run counter
@@ -883,13 +1530,62 @@ func (ss *Sim) RunEnd() {
 func (ss *Sim) NewRun() {
 	run := ss.TrainEnv.Run.Cur
 	//ss.TrainEnv.Table = etable.NewIdxView(ss.OrientationInput)
+	ss.ResetArena()
+	ss.InitAdapt()
+	ss.NewRandWorld(run)
 	ss.TrainEnv.Init(run)
+	ss.ShufRand.Seed(ss.RndSeed + int64(run))
+	ss.ArenaLog.SetNumRows(0)
+	ss.ArenaEpoch(0)
+	ss.ResetPeriodic()
+	ss.UnitGroupLog.SetNumRows(0)
+	ss.UnitGroups.Reset()
+	ss.InitTestEnv()
 	ss.Time.Reset()
 	ss.InitWts(ss.Net)
+	ss.WtChgInit()
+	ss.WtChgLog.SetNumRows(0)
 	ss.InitStats()
 	ss.TrnTrlLog.SetNumRows(0)
 	ss.TrnEpcLog.SetNumRows(0)
 	ss.TstEpcLog.SetNumRows(0)
+	ss.LapLog.SetNumRows(0)
+	ss.ChoiceLog.SetNumRows(0)
+	ss.Choice.Approaching = false
+	ss.Choice.Pending = false
+	ss.Choice.LapSeen = 0
+	ss.SRLog.SetNumRows(0)
+	ss.SR.Reset()
+	ss.SelfLocLog.SetNumRows(0)
+	ss.SelfLoc.Reset()
+	ss.VestibLog.SetNumRows(0)
+	ss.VestibGain.Reset()
+	ss.OcclLog.SetNumRows(0)
+	ss.Occlusion.Reset()
+	ss.AdaptLog.SetNumRows(0)
+	ss.TaskLog.SetNumRows(0)
+	ss.Task.Reset()
+	ss.InitProtocol()
+	ss.LatDiagLog.SetNumRows(0)
+	ss.LatKernLog.SetNumRows(0)
+	ss.LatDiag.Snapshot(ss.Net)
+	ss.ActLog.SetNumRows(0)
+	ss.ActRegLog.SetNumRows(0)
+	ss.ActStats.Reset()
+	ss.StuckPrv = StuckCounts{}
+	ss.Shuffle.Reset()
+	ss.SimMat.Reset()
+	ss.ActExportClose()
+	ss.Traj.Reset()
+	ss.ConfigLinDec()
+	ss.Timers.Reset()
+	ss.Watchdog.Tripped = false
+	ss.LapTrials = nil
+	ss.LapN = 0
+	ss.LapPosErr = 0
+	ss.LapPosACC = 0
+	ss.LapSeen = 0
+	ss.ResetStage()
 	ss.NeedsNewRun = false
 }
 
@@ -897,6 +1593,7 @@ func (ss *Sim) NewRun() {
 // cumulative epoch stats -- called at start of new run
 func (ss *Sim) InitStats() {
 	ss.NumTrlStats = 0
+	ss.NumSupStats = 0
 	ss.TrlCosDiff = 0
 	ss.SumCosDiff = 0
 	ss.EpcCosDiff = 0
@@ -920,8 +1617,9 @@ func (ss *Sim) TrialStats(accum bool) {
 		ss.TrlCosDiffTGT[i] = cd
 	}
 	ss.TrlCosDiff = acd / float64(len(ss.TargetLays))
-	if accum {
+	if accum && ss.Supervised { // no error signal without targets
 		ss.SumCosDiff += ss.TrlCosDiff
+		ss.NumSupStats++
 	}
 
 	if accum {
@@ -935,40 +1633,17 @@ func (ss *Sim) TrialStats(accum bool) {
 
 // TrainEpoch runs training trials for remainder of this epoch
 func (ss *Sim) TrainEpoch() {
-	ss.StopNow = false
-	curEpc := ss.TrainEnv.Epoch.Cur
-	for {
-		ss.TrainTrial()
-		if ss.StopNow || ss.TrainEnv.Epoch.Cur != curEpc {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepEpoch, 1)
 }
 
 // TrainRun runs training trials for remainder of run
 func (ss *Sim) TrainRun() {
-	ss.StopNow = false
-	curRun := ss.TrainEnv.Run.Cur
-	for {
-		ss.TrainTrial()
-		if ss.StopNow || ss.TrainEnv.Run.Cur != curRun {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepRun, 1)
 }
 
 // Train runs the full training from this point onward
 func (ss *Sim) Train() {
-	ss.StopNow = false
-	for {
-		ss.TrainTrial()
-		if ss.StopNow {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepRun, ss.MaxRuns)
 }
 
 // Stop tells the sim to stop running
@@ -979,6 +1654,10 @@ func (ss *Sim) Stop() {
 // Stopped is called when a run method stops running -- updates the IsRunning flag and toolbar
 func (ss *Sim) Stopped() {
 	ss.IsRunning = false
+	ss.UpdtDetachStatus()
+	ss.FlushPlots()
+	ss.GuiMu.Lock()
+	defer ss.GuiMu.Unlock()
 	if ss.Win != nil {
 		vp := ss.Win.WinViewport2D()
 		if ss.ToolBar != nil {
@@ -988,12 +1667,24 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// GuiDo runs given function in the event loop of the GUI window, e.g., to
+// open dialogs from a run goroutine -- runs it directly if there is no GUI
+func (ss *Sim) GuiDo(fun func()) {
+	if ss.Win == nil {
+		fun()
+		return
+	}
+	ss.Win.SendCustomEvent(fun)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights() {
 	fnm := ss.WeightsFileName()
 	fmt.Printf("Saving Weights to: %v\n", fnm)
-	ss.Net.SaveWtsJSON(gi.FileName(fnm))
+	if err := ss.SaveWtsMeta(fnm); err != nil {
+		log.Println(err)
+	}
 }
 
 func (ss *Sim) ConfigWts(dt *etensor.Float32) {
@@ -1008,21 +1699,22 @@ func (ss *Sim) SetAFMetaData(af etensor.Tensor) {
 	af.SetMetaData("grid-fill", "1")
 }
 
-// UpdtARFs updates position activation rf's
+// UpdtARFs updates position activation rf's, from the TestEnv state
 func (ss *Sim) UpdtARFs() {
+	ev := &ss.TestEnv
 	for nm, mt := range ss.RFMaps {
 		mt.SetZeros()
 		switch nm {
 		case "Pos":
-			mt.Set([]int{ss.TrainEnv.PosI.Y, ss.TrainEnv.PosI.X}, 1)
+			mt.Set([]int{ev.PosI.Y, ev.PosI.X}, 1)
 		case "Ang":
-			mt.Set1D(ss.TrainEnv.Angle/90, 1)
+			mt.Set1D(ev.AngIdx(ev.Angle), 1)
 		case "Rot":
-			mt.Set1D(1+ss.TrainEnv.RotAng/90, 1)
+			mt.Set1D(ev.RotIdx(ev.RotAng), 1)
 		}
 	}
 
-	naf := len(ss.ARFLayers) * (len(ss.RFMaps) + 1)
+	naf := len(ss.ARFLayers)*(len(ss.RFMaps)+1) + ss.UnitGroupARFs()
 	if len(ss.ARFs.RFs) != naf {
 		ly := ss.Net.LayerByName("Out_Position")
 		vt := ss.ValsTsr("Out_Position")
@@ -1041,7 +1733,9 @@ func (ss *Sim) UpdtARFs() {
 			af := ss.ARFs.AddRF(lnm+"_"+"Out_Position", vt, ss.ValsTsr("Out_Position"))
 			ss.SetAFMetaData(&af.NormRF)
 		}
+		ss.AddUnitGroupARFs()
 	}
+	ss.ARFStream.Decay(&ss.ARFs)
 	for _, lnm := range ss.ARFLayers {
 		ly := ss.Net.LayerByName(lnm)
 		if ly == nil {
@@ -1054,6 +1748,11 @@ func (ss *Sim) UpdtARFs() {
 		}
 		ss.ARFs.Add(lnm+"_"+"Out_Position", vt, ss.ValsTsr("Out_Position"), 0.01) // thr prevent weird artifacts
 	}
+	ss.AccumUnitGroupARFs()
+	ss.LapTrialAdd()
+	if ss.ARFStream.Trial() {
+		ss.SnapARFs()
+	}
 }
 
 func (ss *Sim) multiply(array []int) int {
@@ -1066,19 +1765,87 @@ func (ss *Sim) multiply(array []int) int {
 
 // SaveAllARFs saves all ARFs to files
 func (ss *Sim) SaveAllARFs() {
-	ss.ARFs.Avg()
-	ss.ARFs.Norm()
+	ss.AvgNormARFs(nil)
 	for _, paf := range ss.ARFs.RFs {
 		fnm := ss.LogFileName(paf.Name)
 		etensor.SaveCSV(&paf.NormRF, gi.FileName(fnm), '\t')
 	}
+	ss.JourneyARFs.AvgNorm()
+	for _, paf := range ss.JourneyARFs.RFs {
+		ss.TestEnv.ArenaNormRF(paf)
+		fnm := ss.LogFileName(paf.Name)
+		etensor.SaveCSV(&paf.NormRF, gi.FileName(fnm), '\t')
+	}
+	ss.AnalyzeHDTuning()
+	ss.HDTuning.SaveCSV(gi.FileName(ss.LogFileName("hdtune")), etable.Tab, etable.Headers)
+}
+
+func init() {
+	AddAnalysis(&Analysis{Name: "hdtune", Label: "HD Tuning", Desc: "compute head-direction tuning (preferred direction, mean vector length etc) of ARF layer units from current Ang activation rfs, and view it.", Needs: []string{AnalysisARFs}, Run: func(ss *Sim) []AnalysisOut {
+		ss.AnalyzeHDTuning()
+		return []AnalysisOut{{Name: "hdtune", Table: ss.HDTuning}}
+	}})
+}
+
+// AnalyzeHDTuning computes the head-direction tuning of each unit in the ARFLayers,
+// from the Ang activation-based receptive fields, into the HDTuning table.
+// Each unit's tuning curve over angles is summarized with circular statistics:
+// preferred direction (circular mean), mean vector length (MVL, 0..1),
+// circular SD, and von Mises concentration (kappa).
+func (ss *Sim) AnalyzeHDTuning() {
+	ss.ConfigHDTuning(ss.HDTuning)
+	dt := ss.HDTuning
+	ss.AvgNormARFs(nil)
+	angs := make([]float64, ss.TrainEnv.NRotAngles)
+	for i := range angs {
+		angs[i] = float64(i * ss.TrainEnv.AngInc)
+	}
+	wts := make([]float64, len(angs))
+	for _, lnm := range ss.ARFLayers {
+		af, err := ss.ARFs.RFByNameTry(lnm + "_Ang")
+		if err != nil {
+			continue
+		}
+		nb := len(angs)
+		nu := len(af.RF.Values) / nb
+		for ui := 0; ui < nu; ui++ {
+			for bi := range wts {
+				wts[bi] = float64(af.RF.Values[ui*nb+bi])
+			}
+			pref, mvl := CircMean(angs, wts)
+			row := dt.Rows
+			dt.SetNumRows(row + 1)
+			dt.SetCellString("Layer", row, lnm)
+			dt.SetCellFloat("Unit", row, float64(ui))
+			dt.SetCellFloat("PrefDir", row, pref)
+			dt.SetCellFloat("MVL", row, mvl)
+			dt.SetCellFloat("CircSD", row, CircStd(mvl))
+			dt.SetCellFloat("Kappa", row, CircKappa(mvl))
+		}
+	}
+}
+
+func (ss *Sim) ConfigHDTuning(dt *etable.Table) {
+	dt.SetMetaData("name", "HDTuning")
+	dt.SetMetaData("desc", "Head-direction tuning of units computed from Ang activation-based receptive fields")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Layer", etensor.STRING, nil, nil},
+		{"Unit", etensor.INT64, nil, nil},
+		{"PrefDir", etensor.FLOAT64, nil, nil},
+		{"MVL", etensor.FLOAT64, nil, nil},
+		{"CircSD", etensor.FLOAT64, nil, nil},
+		{"Kappa", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
 }
 
 // OpenAllARFs open all ARFs from directory of given path
 func (ss *Sim) OpenAllARFs(path gi.FileName) {
 	ss.UpdtARFs()
-	ss.ARFs.Avg()
-	ss.ARFs.Norm()
+	ss.AvgNormARFs(nil)
 	ap := string(path)
 	if strings.HasSuffix(ap, ".tsv") {
 		ap, _ = filepath.Split(ap)
@@ -1098,13 +1865,14 @@ func (ss *Sim) OpenAllARFs(path gi.FileName) {
 ////////////////////////////////////////////////////////////////////////////////////////////
 // Testing
 
-// TestTrial runs one trial of testing -- always sequentially presented inputs
+// TestTrial runs one trial of testing on the TestEnv -- always sequentially
+// presented inputs
 func (ss *Sim) TestTrial(returnOnChg bool) {
-	ss.TakeAction(ss.Net, &ss.TrainEnv) // zycyc: ??
-	ss.TrainEnv.Step()
+	ss.TakeAction(ss.Net, &ss.TestEnv) // zycyc: ??
+	ss.TestEnv.Step()
 
 	// Query counters FIRST
-	epc, _, chg := ss.TrainEnv.Counter(env.Epoch)
+	epc, _, chg := ss.TestEnv.Counter(env.Epoch)
 	if chg {
 		ss.LogTstEpc(ss.TstEpcLog)
 		if ss.ViewOn && ss.TestUpdt > leabra.AlphaCycle {
@@ -1119,23 +1887,36 @@ func (ss *Sim) TestTrial(returnOnChg bool) {
 		}
 	}
 
-	ss.ApplyInputs(&ss.TrainEnv)
+	ss.Supervised = true
+	ss.ITICycles()
+	ss.ApplyInputs(&ss.TestEnv)
 	ss.AlphaCyc(false)   // !train
 	ss.TrialStats(false) // !accumulate
+	ss.SimMatTrial()
+	ss.ActExportTrial()
+	ss.RecordLinDec()
 	ss.LogTstTrl(ss.TstTrlLog)
 }
 
-// TestAll runs through the full set of testing items
+// TestAll runs TestEpcs epochs of testing on the TestEnv, from the start,
+// leaving StopNow as it was unless stopped during the test, so that training
+// continues after the test at the end of each run -- RunTestAll runs it from
+// the gui
 func (ss *Sim) TestAll() {
+	stop := ss.StopNow
 	ss.StopNow = false
-	curRun := ss.TrainEnv.Run.Cur
+	ss.FinishTrainTrial()
+	ss.InitTestEnv()
 	for {
 		ss.TestTrial(false)
-		if ss.StopNow || ss.TrainEnv.Run.Cur != curRun {
+		if ss.StopNow {
 			break
 		}
 	}
-	ss.Stopped()
+	ss.ActExportClose()
+	if ss.TestEnv.Epoch.Cur >= ss.TestEpcs { // completed, not stopped
+		ss.StopNow = stop
+	}
 }
 
 // RunTestAll runs through the full set of testing items, has stop running = false at end -- for gui
@@ -1148,33 +1929,51 @@ func (ss *Sim) RunTestAll() {
 /////////////////////////////////////////////////////////////////////////
 //   Params setting
 
-// ParamsName returns name of current set of parameters
+// ParamsName returns name of current set of parameters, usable in file
+// names (see paramcomp.FileName)
 func (ss *Sim) ParamsName() string {
 	if ss.ParamSet == "" {
 		return "Base"
 	}
-	return ss.ParamSet
+	return paramcomp.FileName(ss.ParamSet)
 }
 
-// SetParams sets the params for "Base" and then current ParamSet.
+// SetParams sets the params for "Base" and then current ParamSet, as
+// composed by ComposeParams.
 // If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
 // otherwise just the named sheet
 // if setMsg = true then we output a message for each param that was set.
 func (ss *Sim) SetParams(sheet string, setMsg bool) error {
 	if sheet == "" {
 		// this is important for catching typos and ensuring that all sheets can be used
-		ss.Params.ValidateSheets([]string{"Network", "Sim"})
+		ss.Params.ValidateSheets([]string{"Network", "Sim", "LrateMult"})
+		ss.ParamAudit.Reset()
+	}
+	pset, err := ss.ComposeParams()
+	if err != nil {
+		return err
 	}
-	err := ss.SetParamsSet("Base", sheet, setMsg)
-	if ss.ParamSet != "" && ss.ParamSet != "Base" {
-		sps := strings.Fields(ss.ParamSet)
-		for _, ps := range sps {
-			err = ss.SetParamsSet(ps, sheet, setMsg)
+	if sheet == "" && ss.SaveParams != "" {
+		if err := pset.SaveJSON(gi.FileName(ss.SaveParams)); err != nil {
+			log.Println(err)
 		}
 	}
+	err = ss.ApplyParamsSet(pset, sheet, setMsg)
+	if sheet == "" || sheet == "Network" {
+		ss.ApplyLrateMults(setMsg) // after all Network sheets set Lrate
+	}
+	if ss.ParamAudit.On {
+		ss.SaveParamAudit()
+	}
 	return err
 }
 
+// ComposeParams returns the params set composed from Base and the ParamSet
+// spec, with the ParamDeps
+func (ss *Sim) ComposeParams() (*params.Set, error) {
+	return paramcomp.Compose(ss.Params, ParamDeps, ss.ParamSet)
+}
+
 // SetParamsSet sets the params for given params.Set name.
 // If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
 // otherwise just the named sheet
@@ -1184,50 +1983,90 @@ func (ss *Sim) SetParamsSet(setNm string, sheet string, setMsg bool) error {
 	if err != nil {
 		return err
 	}
+	return ss.ApplyParamsSet(pset, sheet, setMsg)
+}
+
+// ApplyParamsSet applies the params of given set.
+// If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
+// otherwise just the named sheet
+// if setMsg = true then we output a message for each param that was set.
+func (ss *Sim) ApplyParamsSet(pset *params.Set, sheet string, setMsg bool) error {
+	setNm := pset.Name
 	if sheet == "" || sheet == "Network" {
 		netp, ok := pset.Sheets["Network"]
 		if ok {
+			if ss.ParamAudit.On {
+				ss.ParamAudit.Begin(setNm, "Network", netp, ss.ParamAuditNetObjs())
+			}
 			ss.Net.ApplyParams(netp, setMsg)
+			if ss.ParamAudit.On {
+				ss.ParamAudit.End()
+			}
 		}
 	}
 
 	if sheet == "" || sheet == "Sim" {
 		simp, ok := pset.Sheets["Sim"]
 		if ok {
+			if ss.ParamAudit.On {
+				ss.ParamAudit.Begin(setNm, "Sim", simp, []interface{}{ss})
+			}
 			simp.Apply(ss, setMsg)
+			if ss.ParamAudit.On {
+				ss.ParamAudit.End()
+			}
 		}
 	}
 	// note: if you have more complex environments with parameters, definitely add
 	// sheets for them, e.g., "TrainEnv", "TestEnv" etc
-	return err
+	return nil
+}
+
+// ApplyParams re-applies the current Network sheet params to the existing network
+// in place, without Init, so weights and state are preserved -- for interactive
+// tuning during a paused run.  Returns a log of the params that changed,
+// which is also saved in ParamsChanges.
+func (ss *Sim) ApplyParams() string {
+	before := ss.Net.AllParams()
+	ss.SetParams("Network", false)
+	after := ss.Net.AllParams()
+	chg := ParamsDiff(before, after)
+	if chg == "" {
+		chg = "no params changed\n"
+	}
+	ss.ParamsChanges += fmt.Sprintf("Epoch: %d Trial: %d\n%s\n", ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Trial.Cur, chg)
+	fmt.Print(chg)
+	return chg
+}
+
+// ParamsDiff returns the lines of the params listing after that differ from before,
+// each preceded by the Layer or Prjn they belong to
+func ParamsDiff(before, after string) string {
+	bl := strings.Split(before, "\n")
+	al := strings.Split(after, "\n")
+	if len(bl) != len(al) {
+		return "params listing changed structure -- network must have been rebuilt\n"
+	}
+	diff := ""
+	hdr := ""
+	lasthdr := ""
+	for i, a := range al {
+		if strings.Contains(a, "Layer:") || strings.Contains(a, "Prjn:") {
+			hdr = strings.TrimSpace(strings.Trim(a, "/"))
+		}
+		b := bl[i]
+		if a == b {
+			continue
+		}
+		if hdr != lasthdr {
+			diff += hdr + "\n"
+			lasthdr = hdr
+		}
+		diff += "  - " + strings.TrimSpace(b) + "\n  + " + strings.TrimSpace(a) + "\n"
+	}
+	return diff
 }
 
-//// OpenPatAsset opens pattern file from embedded assets
-//func (ss *Sim) OpenPatAsset(dt *etable.Table, fnm, name, desc string) error {
-//	dt.SetMetaData("name", name)
-//	dt.SetMetaData("desc", desc)
-//	ab, err := Asset(fnm)
-//	if err != nil {
-//		log.Println(err)
-//		return err
-//	}
-//	err = dt.ReadCSV(bytes.NewBuffer(ab), etable.Tab)
-//	if err != nil {
-//		log.Println(err)
-//	} else {
-//		for i := 1; i < len(dt.Cols); i++ {
-//			dt.Cols[i].SetMetaData("grid-fill", "0.9")
-//		}
-//	}
-//	return err
-//}
-//
-//func (ss *Sim) OpenPats() {
-//	// patgen.ReshapeCppFile(ss.Probes, "ProbeInputData.dat", "probes.csv") // one-time reshape
-//	ss.OpenPatAsset(ss.Probes, "probes.tsv", "Probes", "Probe inputs for testing")
-//	// err := dt.OpenCSV("probes.tsv", etable.Tab)
-//}
-
 //func (ss *Sim) ConfigPats() {
 //	ec := &ss.Entorhinal
 //	inputY := ec.InputSize.Y
@@ -1292,6 +2131,21 @@ func (ss *Sim) LogFileName(lognm string) string {
 	return ss.Net.Nm + "_" + ss.RunName() + "_" + lognm + ".tsv"
 }
 
+// SQLFileName returns default SQLite log database file name
+func (ss *Sim) SQLFileName() string {
+	return ss.Net.Nm + "_" + ss.RunName() + ".sqlite"
+}
+
+// SQLWriteRow writes given row of log table dt to table tnm in SQLLog, if open
+func (ss *Sim) SQLWriteRow(tnm string, dt *etable.Table, row int) {
+	if ss.SQLLog == nil {
+		return
+	}
+	if err := ss.SQLLog.WriteRow(tnm, dt, row, ss.LogCfg(tnm)); err != nil {
+		log.Println(err)
+	}
+}
+
 //////////////////////////////////////////////
 //  TrnTrlLog
 
@@ -1307,41 +2161,7 @@ func (ss *Sim) LogTrnTrl(dt *etable.Table) {
 	}
 	dt.SetNumRows(row + 1)
 
-	// decode position and orientation
-	pos := ss.Net.LayerByName("Out_Position").(leabra.LeabraLayer).AsLeabra()
-	pos_tsr := &etensor.Float32{}
-	pos_tsr.SetShape([]int{env.PosSize.Y, env.PosSize.X}, nil, []string{"Y", "X"})
-	for i, val := range pos.Neurons {
-		pos_tsr.Values[i] = val.ActM
-	}
-	dec_pos, _ := env.PopCode2d.Decode(pos_tsr)
-
-	ori := ss.Net.LayerByName("Orientation").(leabra.LeabraLayer).AsLeabra()
-	ori_tsr := make([]float32, len(ori.Neurons))
-	for i, val := range ori.Neurons {
-		ori_tsr[i] = val.ActM
-	}
-	dec_ori := env.AngCode.Decode(ori_tsr)
-
-	// acc of decoding
-	dX := math.Round(float64(dec_pos.X * (float32(env.Size.X) - 2)))
-	dY := math.Round(float64(dec_pos.Y * (float32(env.Size.Y) - 2)))
-	poserr := math.Sqrt(math.Pow(float64(env.PosI.X)-dX, 2) + math.Pow(float64(env.PosI.Y)-dY, 2))
-	posbool := float64(env.PosI.X) == dX && float64(env.PosI.Y) == dY
-
-	oribool := false
-	if math.Round(float64(dec_ori*360)) < 0 {
-		dec_ori = 1 + dec_ori
-	}
-	if math.Round(float64(dec_ori*360)) < float64(env.AngInc)/2 || math.Abs(math.Round(float64(dec_ori*360))-360) < float64(env.AngInc)/2 {
-		if env.Angle == 360 || env.Angle == 0 {
-			dec_ori = float32(env.Angle)
-			oribool = true
-		}
-	} else if math.Abs(math.Round(float64(dec_ori*360))-float64(env.Angle)) < float64(env.AngInc)/2 {
-		dec_ori = float32(env.Angle)
-		oribool = true
-	}
+	ds := ss.DecodeTrial(env)
 
 	// add rows
 	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
@@ -1350,35 +2170,112 @@ func (ss *Sim) LogTrnTrl(dt *etable.Table) {
 	dt.SetCellFloat("Event", row, float64(env.Event.Cur))
 	dt.SetCellFloat("X", row, float64(env.PosI.X))
 	dt.SetCellFloat("Y", row, float64(env.PosI.Y))
-	dt.SetCellFloat("dX", row, dX)
-	dt.SetCellFloat("dY", row, dY)
-	dt.SetCellFloat("PosErr", row, poserr)
-	if posbool {
+	dt.SetCellFloat("dX", row, ds.DX)
+	dt.SetCellFloat("dY", row, ds.DY)
+	dt.SetCellFloat("PosErr", row, ds.PosErr)
+	if ds.PosACC {
 		dt.SetCellFloat("PosACC", row, float64(1))
 	} else {
 		dt.SetCellFloat("PosACC", row, float64(0))
 	}
 	dt.SetCellFloat("Ori", row, float64(env.Angle))
-	if oribool {
-		dt.SetCellFloat("dOri", row, float64(dec_ori))
-		dt.SetCellFloat("OriErr", row, math.Abs(float64(dec_ori)-float64(env.Angle)))
+	dt.SetCellFloat("dOri", row, ds.DOri)
+	dt.SetCellFloat("OriErr", row, math.Abs(ds.OriErr))
+	dt.SetCellFloat("OriSErr", row, ds.OriErr)
+	if ds.OriACC {
 		dt.SetCellFloat("OriACC", row, float64(1))
 	} else {
-		dt.SetCellFloat("dOri", row, math.Round(float64(dec_ori*360)))
-		dt.SetCellFloat("OriErr", row, float64(int(math.Abs(math.Round(float64(dec_ori*360))-float64(env.Angle)))%180))
 		dt.SetCellFloat("OriACC", row, float64(0))
 	}
 	dt.SetCellString("ActAction", row, ss.ActAction)
+	dt.SetCellString("ExecAction", row, ss.ExecAction)
 	dt.SetCellFloat("CosDiff", row, ss.TrlCosDiff)
+	if ss.Supervised {
+		dt.SetCellFloat("Sup", row, 1)
+	} else {
+		dt.SetCellFloat("Sup", row, 0)
+	}
 	//dt.SetCellString("TrialName", row, ss.TrainEnv.TrialName.Cur)
 	for i, lnm := range ss.TargetLays {
 		dt.SetCellFloat(lnm+"_CosDiff", row, float64(ss.TrlCosDiffTGT[i]))
 	}
 
-	// note: essential to use Go version of update when called from another goroutine
-	if ss.TrnTrlPlot != nil {
-		ss.TrnTrlPlot.GoUpdate()
+	ss.LogScanTrl(dt, row)
+	ss.LogActMatchTrl(dt, row, env)
+	ss.LapTrialStats(dt, row)
+	ss.OcclTrialStats(dt, row)
+	ss.ArenaTrial()
+	ss.PeriodicTrial()
+	ss.UnitGroupTrial()
+	ss.TaskTrialStats(dt, row)
+	if ss.TrnTrlFile != nil {
+		ss.WriteLogRow(ss.TrnTrlFile, "trn_trl", dt, row)
 	}
+	ss.SQLWriteRow("trn_trl", dt, row)
+
+	ss.UpdtPlot(ss.TrnTrlPlot)
+}
+
+// DecodeStats are the position and orientation decoding results for a trial
+type DecodeStats struct {
+	DX     float64 `desc:"decoded X position, in world units"`
+	DY     float64 `desc:"decoded Y position, in world units"`
+	PosErr float64 `desc:"distance between the decoded and actual position, in world units (grid cells)"`
+	PosACC bool    `desc:"decoded position rounds to the actual grid cell"`
+	DOri   float64 `desc:"decoded orientation, in degrees"`
+	OriErr float64 `desc:"signed difference between the decoded and actual orientation, in degrees"`
+	OriACC bool    `desc:"decoded orientation is within half an AngInc of the actual"`
+}
+
+// DecodeTrial decodes position and orientation from the Out_Position and
+// Orientation layer minus-phase activity, and compares them to given env
+func (ss *Sim) DecodeTrial(env *XYHDEnv) DecodeStats {
+	var ds DecodeStats
+	dec_pos := ss.DecodePos(env)
+
+	ori := ss.Net.LayerByName("Orientation").(leabra.LeabraLayer).AsLeabra()
+	ori_tsr := make([]float32, len(ori.Neurons))
+	for i, val := range ori.Neurons {
+		ori_tsr[i] = val.ActM
+	}
+	dec_ori := env.AngCode.Decode(ori_tsr)
+
+	// acc of decoding -- error is continuous, in world units (grid cells)
+	ds.DX = float64(dec_pos.X)
+	ds.DY = float64(dec_pos.Y)
+	ds.PosErr = math.Sqrt(math.Pow(float64(env.PosF.X)-ds.DX, 2) + math.Pow(float64(env.PosF.Y)-ds.DY, 2))
+	ds.PosACC = float64(env.PosI.X) == math.Round(ds.DX) && float64(env.PosI.Y) == math.Round(ds.DY)
+
+	ds.DOri = AngNorm(float64(dec_ori * 360))
+	ds.OriErr = AngDiff(ds.DOri, float64(env.Angle))
+	ds.OriACC = math.Abs(ds.OriErr) < float64(env.AngInc)/2
+	return ds
+}
+
+// DecodePos decodes the continuous-valued position, in world units of given
+// env, from the Out_Position layer minus-phase activity, using PosDecode
+func (ss *Sim) DecodePos(env *XYHDEnv) mat32.Vec2 {
+	return ss.DecodePosAct(env, false)
+}
+
+// DecodePosAct decodes the continuous-valued position, in world units of given
+// env, from the Out_Position layer current activity (Act) if cur is true, else
+// minus-phase ActM
+func (ss *Sim) DecodePosAct(env *XYHDEnv, cur bool) mat32.Vec2 {
+	pos := ss.Net.LayerByName("Out_Position").(leabra.LeabraLayer).AsLeabra()
+	pos_tsr := ss.ValsTsr("Out_Position_Dec")
+	pos_tsr.SetShape([]int{env.PosSize.Y, env.PosSize.X}, nil, []string{"Y", "X"})
+	for i, val := range pos.Neurons {
+		if cur {
+			pos_tsr.Values[i] = val.Act
+		} else {
+			pos_tsr.Values[i] = val.ActM
+		}
+	}
+	dec := ss.PosDecode.Decode(pos_tsr, &env.PopCode2d)
+	dec.X *= float32(env.Size.X) - 2
+	dec.Y *= float32(env.Size.Y) - 2
+	return dec
 }
 
 func (ss *Sim) ConfigTrnTrlLog(dt *etable.Table) {
@@ -1405,10 +2302,15 @@ func (ss *Sim) ConfigTrnTrlLog(dt *etable.Table) {
 		{"Ori", etensor.FLOAT64, nil, nil},
 		{"dOri", etensor.FLOAT64, nil, nil},
 		{"OriErr", etensor.FLOAT64, nil, nil},
+		{"OriSErr", etensor.FLOAT64, nil, nil},
 		{"OriACC", etensor.FLOAT64, nil, nil},
 		{"ActAction", etensor.STRING, nil, nil},
+		{"ExecAction", etensor.STRING, nil, nil},
 		{"CosDiff", etensor.FLOAT64, nil, nil},
+		{"Sup", etensor.FLOAT64, nil, nil},
 	}
+	sch = ConfigScanCols(sch)
+	sch = ss.ConfigActMatchTrlCols(sch)
 
 	for _, lnm := range ss.TargetLays {
 		sch = append(sch, etable.Column{lnm + "_CosDiff", etensor.FLOAT64, nil, nil})
@@ -1435,9 +2337,14 @@ func (ss *Sim) ConfigTrnTrlPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 	plt.SetColParams("Ori", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
 	plt.SetColParams("dOri", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
 	plt.SetColParams("OriErr", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("OriSErr", eplot.Off, eplot.FixMin, -180, eplot.FixMax, 180)
 	plt.SetColParams("OriACC", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
 	plt.SetColParams("ActAction", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("ExecAction", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("CosDiff", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Sup", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	ConfigScanPlot(plt)
+	ConfigActMatchTrlPlot(plt)
 
 	for _, lnm := range ss.TargetLays {
 		plt.SetColParams(lnm+"_CosDiff", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
@@ -1459,10 +2366,13 @@ func (ss *Sim) LogTrnEpc(dt *etable.Table) {
 	// nt := float64(ss.TrainEnv.Trial.Max)
 
 	//ss.ECRFs()
-	nt := float64(ss.NumTrlStats)
-	ss.EpcCosDiff = ss.SumCosDiff / nt
+	ss.EpcCosDiff = 0
+	if ss.NumSupStats > 0 {
+		ss.EpcCosDiff = ss.SumCosDiff / float64(ss.NumSupStats)
+	}
 	ss.SumCosDiff = 0
 	ss.NumTrlStats = 0
+	ss.NumSupStats = 0
 
 	trl := ss.TrnTrlLog
 	trlix := etable.NewIdxView(trl)
@@ -1486,14 +2396,36 @@ func (ss *Sim) LogTrnEpc(dt *etable.Table) {
 	dt.SetCellFloat("OriErr", row, agg.Agg(trlix, "OriErr", agg.AggMean)[0])
 	dt.SetCellFloat("OriACC", row, agg.Agg(trlix, "OriACC", agg.AggMean)[0])
 
-	// note: essential to use Go version of update when called from another goroutine
-	ss.TrnEpcPlot.GoUpdate()
+	orierrs := make([]float64, trlix.Len())
+	for i, ri := range trlix.Idxs {
+		orierrs[i] = trl.CellFloat("OriSErr", ri)
+	}
+	omean, osd, okap := CircErrStats(orierrs)
+	dt.SetCellFloat("OriMeanErr", row, omean)
+	dt.SetCellFloat("OriCircSD", row, osd)
+	dt.SetCellFloat("OriKappa", row, okap)
+	ss.LogSupEpc(dt, row, trlix)
+	ss.LogActMatchEpc(dt, row, trlix)
+	ss.LogStuckEpc(dt, row)
+	ss.LogPhaseTimes(dt, row)
+
+	ss.SRAnalyze()
+	ss.LogSelfLoc(ss.SelfLocLog)
+	ss.LogVestibEpc()
+	ss.LogOcclEpc()
+	ss.LogLatDiag(ss.LatDiagLog, ss.LatKernLog, epc)
+	ss.LogActs(ss.ActLog, ss.ActRegLog, epc)
+	ss.LogUnitGroups(ss.UnitGroupLog, epc)
+	ss.LogWtChg(ss.WtChgLog, epc)
+
+	ss.UpdtPlot(ss.TrnEpcPlot)
+	ss.UpdtDetachStatus()
 	if ss.TrnEpcFile != nil {
-		if ss.TrainEnv.Run.Cur == 0 && epc == 0 {
-			dt.WriteCSVHeaders(ss.TrnEpcFile, etable.Tab)
-		}
-		dt.WriteCSVRow(ss.TrnEpcFile, row, etable.Tab)
+		ss.WriteLogRow(ss.TrnEpcFile, "trn_epc", dt, row)
 	}
+	ss.SQLWriteRow("trn_epc", dt, row)
+	ss.WatchdogEpoch(epc)
+	ss.ExecAfterEpoch(epc)
 }
 
 func (ss *Sim) ConfigTrnEpcLog(dt *etable.Table) {
@@ -1514,6 +2446,17 @@ func (ss *Sim) ConfigTrnEpcLog(dt *etable.Table) {
 	sch = append(sch, etable.Column{"PosACC", etensor.FLOAT64, nil, nil})
 	sch = append(sch, etable.Column{"OriErr", etensor.FLOAT64, nil, nil})
 	sch = append(sch, etable.Column{"OriACC", etensor.FLOAT64, nil, nil})
+	sch = append(sch, etable.Column{"OriMeanErr", etensor.FLOAT64, nil, nil})
+	sch = append(sch, etable.Column{"OriCircSD", etensor.FLOAT64, nil, nil})
+	sch = append(sch, etable.Column{"OriKappa", etensor.FLOAT64, nil, nil})
+	for _, pfx := range []string{"Sup_", "Unsup_"} {
+		for _, st := range SupStats {
+			sch = append(sch, etable.Column{pfx + st, etensor.FLOAT64, nil, nil})
+		}
+	}
+	sch = ss.ConfigActMatchEpcCols(sch)
+	sch = ConfigStuckCols(sch)
+	sch = ConfigPhaseTimeCols(sch)
 
 	dt.SetFromSchema(sch, 0)
 	ss.ConfigWts(ss.EConWts)
@@ -1536,6 +2479,18 @@ func (ss *Sim) ConfigTrnEpcPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 	plt.SetColParams("PosACC", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
 	plt.SetColParams("OriErr", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("OriACC", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("OriMeanErr", eplot.Off, eplot.FixMin, -180, eplot.FixMax, 180)
+	plt.SetColParams("OriCircSD", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("OriKappa", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	for _, pfx := range []string{"Sup_", "Unsup_"} {
+		plt.SetColParams(pfx+"PosErr", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+		plt.SetColParams(pfx+"PosACC", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+		plt.SetColParams(pfx+"OriErr", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+		plt.SetColParams(pfx+"OriACC", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	}
+	ss.ConfigActMatchEpcPlot(plt)
+	ConfigStuckPlot(plt)
+	ConfigPhaseTimePlot(plt)
 
 	return plt
 }
@@ -1549,7 +2504,7 @@ func (ss *Sim) LogTstTrl(dt *etable.Table) {
 	row := dt.Rows
 	dt.SetNumRows(row + 1)
 
-	env := &ss.TrainEnv
+	env := &ss.TestEnv
 
 	dt.SetCellFloat("Run", row, float64(env.Run.Cur))
 	dt.SetCellFloat("Epoch", row, float64(env.Epoch.Cur))
@@ -1558,7 +2513,9 @@ func (ss *Sim) LogTstTrl(dt *etable.Table) {
 	dt.SetCellFloat("Y", row, float64(env.PosI.Y))
 	dt.SetCellFloat("Angle", row, float64(env.Angle))
 	dt.SetCellString("ActAction", row, ss.ActAction)
+	dt.SetCellString("ExecAction", row, ss.ExecAction)
 	dt.SetCellFloat("CosDiff", row, ss.TrlCosDiff)
+	ss.LogActMatchTrl(dt, row, env)
 
 	//epc := ss.TrainEnv.Epoch.Prv // this is triggered by increment so use previous value
 	//
@@ -1574,8 +2531,9 @@ func (ss *Sim) LogTstTrl(dt *etable.Table) {
 	//dt.SetCellFloat("Trial", row, float64(trl))
 	//dt.SetCellString("TrialName", row, ss.TestEnv.TrialName.Cur)
 
-	// note: essential to use Go version of update when called from another goroutine
-	ss.TstTrlPlot.GoUpdate()
+	ss.SQLWriteRow("tst_trl", dt, row)
+
+	ss.UpdtPlot(ss.TstTrlPlot)
 }
 
 func (ss *Sim) ConfigTstTrlLog(dt *etable.Table) {
@@ -1593,8 +2551,10 @@ func (ss *Sim) ConfigTstTrlLog(dt *etable.Table) {
 		{"Y", etensor.FLOAT64, nil, nil},
 		{"Angle", etensor.FLOAT64, nil, nil},
 		{"ActAction", etensor.STRING, nil, nil},
+		{"ExecAction", etensor.STRING, nil, nil},
 		{"CosDiff", etensor.FLOAT64, nil, nil},
 	}
+	sch = ss.ConfigActMatchTrlCols(sch)
 	dt.SetFromSchema(sch, 0)
 }
 
@@ -1610,7 +2570,9 @@ func (ss *Sim) ConfigTstTrlPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 	plt.SetColParams("Y", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
 	plt.SetColParams("Angle", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
 	plt.SetColParams("ActAction", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("ExecAction", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("CosDiff", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	ConfigActMatchTrlPlot(plt)
 	// order of params: on, fixMin, min, fixMax, max 0)
 
 	return plt
@@ -1625,15 +2587,19 @@ func (ss *Sim) LogTstEpc(dt *etable.Table) {
 
 	// trl := ss.TstTrlLog
 	// tix := etable.NewIdxView(trl)
-	epc := ss.TrainEnv.Epoch.Prv // ?
+	epc := ss.TestEnv.Epoch.Prv // ?
 
 	// note: this shows how to use agg methods to compute summary data from another
 	// data table, instead of incrementing on the Sim
-	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Run", row, float64(ss.TestEnv.Run.Cur))
 	dt.SetCellFloat("Epoch", row, float64(epc))
 
-	// note: essential to use Go version of update when called from another goroutine
-	ss.TstEpcPlot.GoUpdate()
+	if ss.TstEpcFile != nil {
+		ss.WriteLogRow(ss.TstEpcFile, "tst_epc", dt, row)
+	}
+	ss.SQLWriteRow("tst_epc", dt, row)
+
+	ss.UpdtPlot(ss.TstEpcPlot)
 }
 
 func (ss *Sim) ConfigTstEpcLog(dt *etable.Table) {
@@ -1689,14 +2655,11 @@ func (ss *Sim) LogRun(dt *etable.Table) {
 	// split.Desc(spl, "PctCor")
 	// ss.RunStats = spl.AggsToTable(etable.AddAggName)
 
-	// note: essential to use Go version of update when called from another goroutine
-	ss.RunPlot.GoUpdate()
+	ss.UpdtPlot(ss.RunPlot)
 	if ss.RunFile != nil {
-		if row == 0 {
-			dt.WriteCSVHeaders(ss.RunFile, etable.Tab)
-		}
-		dt.WriteCSVRow(ss.RunFile, row, etable.Tab)
+		ss.WriteLogRow(ss.RunFile, "run", dt, row)
 	}
+	ss.SQLWriteRow("run", dt, row)
 }
 
 func (ss *Sim) ConfigRunLog(dt *etable.Table) {
@@ -1734,11 +2697,11 @@ func (ss *Sim) ConfigNetView(nv *netview.NetView) {
 
 // ConfigWorldGui configures all the world view GUI elements
 func (ss *Sim) ConfigWorldGui() *gi.Window {
-	// order: Empty, wall, food, water, foodwas, waterwas
-	ss.MatColors = []string{"lightgrey", "black", "orange", "blue", "brown", "navy"}
+	ss.ApplyColorTheme()
 
 	ss.Trace = ss.TrainEnv.World.Clone().(*etensor.Int)
 	ss.dTrace = ss.TrainEnv.World.Clone().(*etensor.Int)
+	ss.ErrTrace.Reset(ss.TrainEnv.World)
 
 	width := 1600
 	height := 1200
@@ -1774,6 +2737,10 @@ func (ss *Sim) ConfigWorldGui() *gi.Window {
 	dtg.SetTensor(ss.dTrace)
 	ss.ConfigWorldView(dtg)
 
+	etg := tv.AddNewTab(etview.KiT_TensorGrid, "ErrMap").(*etview.TensorGrid)
+	ss.ErrMapView = etg
+	ss.ConfigErrMapGrid(etg)
+
 	wg := tv.AddNewTab(etview.KiT_TensorGrid, "World").(*etview.TensorGrid)
 	ss.WorldView = wg
 	wg.SetTensor(ss.TrainEnv.World)
@@ -1835,8 +2802,49 @@ func (ss *Sim) ConfigWorldGui() *gi.Window {
 		giv.CallMethod(&ss.TrainEnv, "SavePats", vp)
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Save Pat Bank", Icon: "file-save", Tooltip: "Save bit patterns to .json file as a versioned pattern bank, identified by the hash of the patterns", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		giv.CallMethod(&ss.TrainEnv, "SavePatBank", vp)
+	})
+
+	tbar.AddSeparator("sep-theme")
+
+	gi.AddNewLabel(tbar, "theme-lbl", "Theme:")
+	tcb := gi.AddNewComboBox(tbar, "theme")
+	tcb.ItemsFromStringList(ColorThemeNames(), false, 20)
+	tcb.SetCurVal(ss.CurTheme.Name)
+	tcb.Tooltip = "world color theme -- ColorBlind is distinguishable with red-green color blindness"
+	tcb.ComboSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.SetTheme(tcb.CurVal.(string))
+		vp.SetFullReRender()
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Open Theme", Icon: "file-open", Tooltip: "Open a world color theme from a .json file, with the material colors and color maps"}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenColorTheme", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Save Theme", Icon: "file-save", Tooltip: "Save the current world color theme to a .json file, as a starting point for a custom theme"}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "SaveColorTheme", vp)
+		})
+
 	vp.UpdateEndNoSig(updt)
 
+	win.SetCloseCleanFunc(func(w *gi.Window) {
+		go func() { // not blocking the GUI while a view update finishes
+			ss.GuiMu.Lock()
+			defer ss.GuiMu.Unlock()
+			if ss.WorldWin == w {
+				ss.ClearWorldGui() // closing the World window does not quit -- reopen with World
+				if ss.ToolBar != nil {
+					ss.ToolBar.UpdateActions()
+				}
+			}
+		}()
+	})
+
 	// main menu
 	appnm := gi.AppName()
 	mmen := win.MainMenu
@@ -1853,27 +2861,8 @@ func (ss *Sim) ConfigWorldGui() *gi.Window {
 }
 
 func (ss *Sim) ConfigWorldView(tg *etview.TensorGrid) {
-	cnm := "XYHDEnvColors"
-	cm, ok := giv.AvailColorMaps[cnm]
-	if !ok {
-		cm = &giv.ColorMap{}
-		cm.Name = cnm
-		cm.Indexed = true
-		nc := len(ss.TrainEnv.Mats)
-		cm.Colors = make([]gist.Color, nc+ss.TrainEnv.NRotAngles)
-		cm.NoColor = gist.Black
-		for i, cnm := range ss.MatColors {
-			cm.Colors[i].SetString(cnm, nil)
-		}
-		ch := giv.AvailColorMaps["ColdHot"]
-		for i := 0; i < ss.TrainEnv.NRotAngles; i++ {
-			nv := float64(i) / float64(ss.TrainEnv.NRotAngles-1)
-			cm.Colors[nc+i] = ch.Map(nv) // color map of rotation
-		}
-		giv.AvailColorMaps[cnm] = cm
-	}
 	tg.Disp.Defaults()
-	tg.Disp.ColorMap = giv.ColorMapName(cnm)
+	tg.Disp.ColorMap = WorldColorMap // registered by ApplyColorTheme
 	tg.Disp.GridFill = 1
 	tg.SetStretchMax()
 }
@@ -1889,19 +2878,13 @@ func (ss *Sim) UpdateWorldGui() {
 	}
 
 	nc := len(ss.TrainEnv.Mats)
-	ss.Trace.Set([]int{ss.TrainEnv.PosI.Y, ss.TrainEnv.PosI.X}, nc+ss.TrainEnv.Angle/ss.TrainEnv.AngInc)
+	ss.Trace.Set([]int{ss.TrainEnv.PosI.Y, ss.TrainEnv.PosI.X}, nc+ss.TrainEnv.AngIdx(ss.TrainEnv.Angle))
 
 	////////////////////////////////////// decoding trace
 	env := &ss.TrainEnv
-	pos := ss.Net.LayerByName("Out_Position").(leabra.LeabraLayer).AsLeabra()
-	pos_tsr := &etensor.Float32{}
-	pos_tsr.SetShape([]int{env.PosSize.Y, env.PosSize.X}, nil, []string{"Y", "X"})
-	for i, val := range pos.Neurons {
-		pos_tsr.Values[i] = val.ActM
-	}
-	dec_pos, _ := env.PopCode2d.Decode(pos_tsr)
-	dX := int(math.Round(float64(dec_pos.X * (float32(env.Size.X) - 2))))
-	dY := int(math.Round(float64(dec_pos.Y * (float32(env.Size.Y) - 2))))
+	dec_pos := ss.DecodePos(env)
+	dX := int(math.Round(float64(dec_pos.X)))
+	dY := int(math.Round(float64(dec_pos.Y)))
 
 	ori := ss.Net.LayerByName("Orientation").(leabra.LeabraLayer).AsLeabra()
 	ori_tsr := make([]float32, len(ori.Neurons))
@@ -1909,29 +2892,33 @@ func (ss *Sim) UpdateWorldGui() {
 		ori_tsr[i] = val.ActM
 	}
 	dec_ori := env.AngCode.Decode(ori_tsr)
-	dOri := int(math.Round(float64(dec_ori * 360)))
+	dOri := int(math.Round(AngNorm(float64(dec_ori * 360))))
 
-	ss.dTrace.Set([]int{dY, dX}, nc+dOri/env.AngInc)
+	perr := math.Hypot(float64(env.PosF.X-dec_pos.X), float64(env.PosF.Y-dec_pos.Y))
+	ss.DrawErrLine(env.PosI.X, env.PosI.Y, dX, dY, nc+env.NRotAngles+ss.ErrTrace.Bin(perr))
+	ss.dTrace.Set([]int{dY, dX}, nc+env.AngIdx(dOri))
+	ss.ErrTrace.Add(env.PosI.X, env.PosI.Y, perr, env.Epoch.Cur)
 	////////////////////////////////////////////////////////////////////////
 
 	updt := ss.WorldTabs.UpdateStart()
 	ss.TraceView.UpdateSig()
 	ss.dTraceView.UpdateSig()
+	ss.ErrMapView.UpdateSig()
 	ss.WorldTabs.UpdateEnd(updt)
 }
 
 func (ss *Sim) Left() {
-	ss.TrainEnv.Action("Left", nil)
+	ss.TrainEnv.DoAction(NewAction(Left))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Right() {
-	ss.TrainEnv.Action("Right", nil)
+	ss.TrainEnv.DoAction(NewAction(Right))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Forward() {
-	ss.TrainEnv.Action("Forward", nil)
+	ss.TrainEnv.DoAction(NewAction(Forward))
 	ss.UpdateWorldGui()
 }
 
@@ -1954,6 +2941,11 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	tbar := gi.AddNewToolBar(mfr, "tbar")
 	tbar.SetStretchMaxWidth()
 	ss.ToolBar = tbar
+	win.EventMgr.ConnectEvent(tbar.This(), oswin.CustomEventType, gi.RegPri, func(recv, send ki.Ki, sig int64, data interface{}) {
+		if fun, ok := data.(*oswin.CustomEvent).Data.(func()); ok { // from GuiDo
+			fun()
+		}
+	})
 
 	split := gi.AddNewSplitView(mfr, "split")
 	split.Dim = mat32.X
@@ -1963,6 +2955,7 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	sv.SetStruct(ss)
 
 	tv := gi.AddNewTabView(split, "tv")
+	ss.TabView = tv
 
 	nv := tv.AddNewTab(netview.KiT_NetView, "NetView").(*netview.NetView)
 	nv.Var = "Act"
@@ -1970,21 +2963,74 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	ss.NetView = nv
 	ss.ConfigNetView(nv)
 
-	plt := tv.AddNewTab(eplot.KiT_Plot2D, "TrnTrlPlot").(*eplot.Plot2D)
+	plt := ss.AddPlotTab(tv, "TrnTrlPlot", ss.TrnTrlLog)
 	ss.TrnTrlPlot = ss.ConfigTrnTrlPlot(plt, ss.TrnTrlLog)
 
-	plt = tv.AddNewTab(eplot.KiT_Plot2D, "TrnEpcPlot").(*eplot.Plot2D)
+	plt = ss.AddPlotTab(tv, "TrnEpcPlot", ss.TrnEpcLog)
 	ss.TrnEpcPlot = ss.ConfigTrnEpcPlot(plt, ss.TrnEpcLog)
 
-	plt = tv.AddNewTab(eplot.KiT_Plot2D, "TstTrlPlot").(*eplot.Plot2D)
+	plt = ss.AddPlotTab(tv, "TstTrlPlot", ss.TstTrlLog)
 	ss.TstTrlPlot = ss.ConfigTstTrlPlot(plt, ss.TstTrlLog)
 
-	plt = tv.AddNewTab(eplot.KiT_Plot2D, "TstEpcPlot").(*eplot.Plot2D)
+	plt = ss.AddPlotTab(tv, "TstEpcPlot", ss.TstEpcLog)
 	ss.TstEpcPlot = ss.ConfigTstEpcPlot(plt, ss.TstEpcLog)
 
-	plt = tv.AddNewTab(eplot.KiT_Plot2D, "RunPlot").(*eplot.Plot2D)
+	plt = ss.AddPlotTab(tv, "RunPlot", ss.RunLog)
 	ss.RunPlot = ss.ConfigRunPlot(plt, ss.RunLog)
 
+	plt = ss.AddPlotTab(tv, "LapPlot", ss.LapLog)
+	ss.LapPlot = ss.ConfigLapPlot(plt, ss.LapLog)
+
+	plt = ss.AddPlotTab(tv, "ChoicePlot", ss.ChoiceLog)
+	ss.ChoicePlot = ss.ConfigChoicePlot(plt, ss.ChoiceLog)
+
+	plt = ss.AddPlotTab(tv, "SRPlot", ss.SRLog)
+	ss.SRPlot = ss.ConfigSRPlot(plt, ss.SRLog)
+
+	plt = ss.AddPlotTab(tv, "SelfLocPlot", ss.SelfLocLog)
+	ss.SelfLocPlot = ss.ConfigSelfLocPlot(plt, ss.SelfLocLog)
+
+	plt = ss.AddPlotTab(tv, "VestibPlot", ss.VestibLog)
+	ss.VestibPlot = ss.ConfigVestibPlot(plt, ss.VestibLog)
+
+	plt = ss.AddPlotTab(tv, "OcclPlot", ss.OcclLog)
+	ss.OcclPlot = ss.ConfigOcclPlot(plt, ss.OcclLog)
+
+	plt = ss.AddPlotTab(tv, "AdaptPlot", ss.AdaptLog)
+	ss.AdaptPlot = ss.ConfigAdaptPlot(plt, ss.AdaptLog)
+
+	plt = ss.AddPlotTab(tv, "ArenaPlot", ss.ArenaLog)
+	ss.ArenaPlot = ss.ConfigArenaPlot(plt, ss.ArenaLog)
+
+	plt = ss.AddPlotTab(tv, "UnitClassPlot", ss.UnitClassLog)
+	ss.UnitClassPlot = ss.ConfigUnitClassPlot(plt, ss.UnitClassLog)
+
+	plt = ss.AddPlotTab(tv, "UnitGroupPlot", ss.UnitGroupLog)
+	ss.UnitGroupPlot = ss.ConfigUnitGroupPlot(plt, ss.UnitGroupLog)
+
+	plt = ss.AddPlotTab(tv, "WtChgPlot", ss.WtChgLog)
+	ss.WtChgPlot = ss.ConfigWtChgPlot(plt, ss.WtChgLog)
+
+	plt = ss.AddPlotTab(tv, "TaskPlot", ss.TaskLog)
+	ss.TaskPlot = ss.ConfigTaskPlot(plt, ss.TaskLog)
+
+	plt = ss.AddPlotTab(tv, "LatDiagPlot", ss.LatDiagLog)
+	ss.LatDiagPlot = ss.ConfigLatDiagPlot(plt, ss.LatDiagLog)
+
+	plt = ss.AddPlotTab(tv, "ActPlot", ss.ActLog)
+	ss.ActPlot = ss.ConfigActPlot(plt, ss.ActLog)
+
+	tg := tv.AddNewTab(etview.KiT_TensorGrid, "SimMat").(*etview.TensorGrid)
+	ss.SimMatView = tg
+	ss.ConfigSimMatGrid(tg)
+
+	pv := tv.AddNewTab(giv.KiT_TableView, "Prjns").(*giv.TableView)
+	ss.PrjnView = pv
+	pv.SetSlice(&ss.PrjnCtrls)
+	pv.ViewSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.ApplyPrjnCtrls()
+	})
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -1994,6 +3040,14 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		vp.SetNeedsFullRender()
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Apply Params", Icon: "update", Tooltip: "Re-applies the current Network params to the existing network, without Init -- weights are preserved.  Shows a log of the params that changed.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		chg := ss.ApplyParams()
+		giv.TextViewDialog(vp, []byte(chg), giv.DlgOpts{Title: "Params Changed"})
+		vp.SetNeedsFullRender()
+	})
+
 	tbar.AddAction(gi.ActOpts{Label: "Train", Icon: "run", Tooltip: "Starts the network training, picking up from wherever it may have left off.  If not stopped, training will complete the specified number of Runs through the full number of Epochs of training, with testing automatically occuring at the specified interval.",
 		UpdateFunc: func(act *gi.Action) {
 			act.SetActiveStateUpdt(!ss.IsRunning)
@@ -2012,14 +3066,30 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		ss.Stop()
 	})
 
-	tbar.AddAction(gi.ActOpts{Label: "Step Trial", Icon: "step-fwd", Tooltip: "Advances one training trial at a time.", UpdateFunc: func(act *gi.Action) {
+	tbar.AddAction(gi.ActOpts{Label: "Step Cycle", Icon: "step-fwd", Tooltip: "Advances one training cycle at a time.", UpdateFunc: func(act *gi.Action) {
 		act.SetActiveStateUpdt(!ss.IsRunning)
 	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
 		if !ss.IsRunning {
 			ss.IsRunning = true
-			ss.TrainTrial()
-			ss.IsRunning = false
-			vp.SetNeedsFullRender()
+			ss.TrainSteps(StepCycle, 1)
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Step Quarter", Icon: "step-fwd", Tooltip: "Advances to the end of the current quarter of the training trial.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			ss.TrainSteps(StepQuarter, 1)
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Step Trial", Icon: "step-fwd", Tooltip: "Advances one training trial at a time -- or to the end of the current trial, if it was stepped partway through.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			ss.TrainSteps(StepTrial, 1)
 		}
 	})
 
@@ -2043,22 +3113,78 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Demo", Icon: "play", Tooltip: "Runs training in soft real time, at the Demo.Rate set by the speed slider, with the views updated at Demo.ViewUpdt -- for live demos of the agent navigating.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.RunDemo()
+		}
+	})
+
+	gi.AddNewLabel(tbar, "demo-lbl", "Speed:")
+	dsl := gi.AddNewSlider(tbar, "demo-rate")
+	dsl.Dim = mat32.X
+	dsl.Min = 0.5
+	dsl.Max = 50
+	dsl.Step = 0.5
+	dsl.SetValue(ss.Demo.Rate)
+	dsl.Tooltip = "Demo speed, in training trials per second -- can be changed while the demo runs"
+	dsl.SliderSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if sig == int64(gi.SliderValueChanged) {
+			ss.Demo.SetRate(dsl.Value)
+		}
+	})
+
+	if gnms := ss.UnitGroupNames(); len(gnms) > 0 {
+		gi.AddNewLabel(tbar, "group-lbl", "Group:")
+		gcb := gi.AddNewComboBox(tbar, "group")
+		gcb.ItemsFromStringList(append([]string{""}, gnms...), false, 20)
+		gcb.SetCurVal(ss.UnitGroups.Highlight)
+		gcb.Tooltip = "unit group highlighted in the NetView, masking the other units of its layer -- empty = none"
+		gcb.ComboSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			ss.UnitGroups.Highlight = gcb.CurVal.(string)
+		})
+	}
+
 	tbar.AddSeparator("spec")
 
 	tbar.AddAction(gi.ActOpts{Label: "Reset ARFs", Icon: "reset", Tooltip: "reset current position activation rfs accumulation data", UpdateFunc: func(act *gi.Action) {
 		act.SetActiveStateUpdt(!ss.IsRunning)
 	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
 		ss.ARFs.Reset()
+		ss.ARFStream.Reset()
+		ss.JourneyARFs.Reset()
+		ss.LapTrials = nil
 	})
 
+	var arfProg *gi.Label // progress of computing ARFs for View ARFs
 	tbar.AddAction(gi.ActOpts{Label: "View ARFs", Icon: "file-image", Tooltip: "compute activation rfs and view them.", UpdateFunc: func(act *gi.Action) {
 		act.SetActiveStateUpdt(!ss.IsRunning)
 	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
-		ss.ARFs.Avg()
-		ss.ARFs.Norm()
-		for _, paf := range ss.ARFs.RFs {
-			etview.TensorGridDialog(vp, &paf.NormRF, giv.DlgOpts{Title: "Act RF " + paf.Name, Prompt: paf.Name, TmpSave: nil}, nil, nil)
-		}
+		ss.IsRunning = true
+		tbar.UpdateActions()
+		go func() {
+			ss.AvgNormARFs(func(done, n int) {
+				updt := arfProg.UpdateStart()
+				arfProg.SetText(fmt.Sprintf("ARFs: %d / %d", done, n))
+				arfProg.UpdateEnd(updt)
+			})
+			updt := arfProg.UpdateStart()
+			arfProg.SetText("")
+			arfProg.UpdateEnd(updt)
+			ss.JourneyARFs.AvgNorm()
+			for _, paf := range ss.JourneyARFs.RFs {
+				ss.TestEnv.ArenaNormRF(paf)
+			}
+			ss.GuiDo(func() {
+				for _, paf := range append(ss.ARFs.RFs, ss.JourneyARFs.RFs...) {
+					etview.TensorGridDialog(vp, &paf.NormRF, giv.DlgOpts{Title: "Act RF " + paf.Name, Prompt: paf.Name, TmpSave: nil}, nil, nil)
+				}
+				ss.Stopped()
+			})
+		}()
 	})
 
 	tbar.AddAction(gi.ActOpts{Label: "Open ARFs", Icon: "file-open", Tooltip: "Open saved ARF .tsv files -- select a path or specific file in path", UpdateFunc: func(act *gi.Action) {
@@ -2067,6 +3193,21 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		giv.CallMethod(ss, "OpenAllARFs", vp)
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Export Plot", Icon: "file-save", Tooltip: "save the plot in the current tab as SVG / PDF figures, as set in PlotExport"}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.ExportCurPlot()
+	})
+
+	for _, an := range Analyses {
+		an := an
+		tbar.AddAction(gi.ActOpts{Label: an.Label, Icon: "file-sheet", Tooltip: an.Desc, UpdateFunc: func(act *gi.Action) {
+			act.SetActiveStateUpdt(!ss.IsRunning && ss.AnalysisReady(an))
+		}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			ss.ViewAnalysis(an, vp)
+		})
+	}
+
+	arfProg = gi.AddNewLabel(tbar, "arf-prog", "")
+
 	tbar.AddSeparator("test")
 
 	tbar.AddAction(gi.ActOpts{Label: "Test Trial", Icon: "step-fwd", Tooltip: "Runs the next testing trial.", UpdateFunc: func(act *gi.Action) {
@@ -2098,8 +3239,38 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			ss.RunPlot.Update()
 		})
 
+	tbar.AddSeparator("queue")
+
+	tbar.AddAction(gi.ActOpts{Label: "Enqueue", Icon: "plus", Tooltip: "Adds a configuration (ParamSet, Tag, Epochs, Runs) to the end of the run queue, which is saved to disk.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		giv.CallMethod(ss, "Enqueue", vp)
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Run Queue", Icon: "run", Tooltip: "Runs each remaining configuration in the run queue in turn, saving logs and weights for each.  Stop interrupts the current configuration, which will be restarted on the next Run Queue.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.RunQueue()
+		}
+	})
+
 	tbar.AddSeparator("misc")
 
+	tbar.AddAction(gi.ActOpts{Label: "World", Icon: "file-image", Tooltip: "Re-opens the World window, if it was closed.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(ss.WorldWin == nil)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.OpenWorldGui()
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Detach", Icon: "close", Tooltip: "Closes the windows while training continues headless, without any view updates, leaving a small window to Re-attach them later.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.DetachGui()
+	})
+
 	tbar.AddAction(gi.ActOpts{Label: "New Seed", Icon: "new", Tooltip: "Generate a new initial random seed to get different results.  By default, Init re-establishes the same initial seed every time."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			ss.NewRndSeed()
@@ -2146,6 +3317,20 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			return
 		}
 		inClosePrompt = true
+		if ss.IsRunning { // offer to keep training headless
+			gi.ChoiceDialog(vp, gi.DlgOpts{Title: "Really Close Window?",
+				Prompt: "Training is running: Detach the GUI to keep training headless, and Re-attach later, or Quit the App, interrupting training and losing all unsaved params, weights, logs, etc?"}, []string{"Detach", "Quit", "Cancel"},
+				win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+					inClosePrompt = false
+					switch sig {
+					case 0:
+						ss.DetachGui()
+					case 1:
+						gi.Quit()
+					}
+				})
+			return
+		}
 		gi.PromptDialog(vp, gi.DlgOpts{Title: "Really Close Window?",
 			Prompt: "Are you <i>sure</i> you want to close the window?  This will Quit the App as well, losing all unsaved params, weights, logs, etc"}, gi.AddOk, gi.AddCancel,
 			win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
@@ -2158,7 +3343,9 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	})
 
 	win.SetCloseCleanFunc(func(w *gi.Window) {
-		go gi.Quit() // once main window is closed, quit
+		if !ss.Detach.Detached {
+			go gi.Quit() // once main window is closed, quit, unless detached
+		}
 	})
 
 	win.MainMenuUpdated()
@@ -2177,6 +3364,101 @@ var SimProps = ki.Props{
 				}},
 			},
 		}},
+		{"OpenWeights", ki.Props{
+			"desc": "open network weights from file, warning if their seed, world or params do not match the current sim",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".wts,.wts.gz",
+				}},
+			},
+		}},
+		{"SaveStateDict", ki.Props{
+			"desc": "save network weights to a PyTorch-style state dict JSON file",
+			"icon": "file-save",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
+		{"OpenStateDict", ki.Props{
+			"desc": "set network weights from a PyTorch-style state dict JSON file",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
+		{"OpenColorTheme", ki.Props{
+			"desc": "open a world color theme from a .json file, with the material colors, and the color maps of head directions and decoding errors",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
+		{"SaveColorTheme", ki.Props{
+			"desc": "save the current world color theme to a .json file, as a starting point for a custom theme",
+			"icon": "file-save",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
+		{"OpenVestibGain", ki.Props{
+			"desc": "load a vestibular gain schedule from a JSON protocol file with a list of blocks",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
+		{"OpenOcclusion", ki.Props{
+			"desc": "load a heading-dependent sensory occlusion schedule from a JSON protocol file with a list of blocks",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
+		{"OpenArena", ki.Props{
+			"desc": "load a global remapping schedule of arena rotations and mirrorings from a JSON protocol file with a list of blocks",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
+		{"OpenProtocol", ki.Props{
+			"desc": "load a multi-phase experiment protocol from a JSON file -- takes effect at the start of the next run",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
+		{"Enqueue", ki.Props{
+			"desc": "add a configuration to the end of the run queue",
+			"icon": "plus",
+			"Args": ki.PropSlice{
+				{"Param Set", ki.Props{}},
+				{"Tag", ki.Props{}},
+				{"Epochs", ki.Props{
+					"default-field": "MaxEpcs",
+				}},
+				{"Runs", ki.Props{
+					"default-field": "MaxRuns",
+				}},
+			},
+		}},
 		{"OpenAllARFs", ki.Props{
 			"desc": "open all Activation-based Receptive Fields from selected path (can select a file too)",
 			"icon": "file-open",
@@ -2186,26 +3468,316 @@ var SimProps = ki.Props{
 				}},
 			},
 		}},
+		{"OpenLogPlot", ki.Props{
+			"desc": "open a saved log file (.tsv, or .tsv.gz) into the plot tab of given name, e.g., TrnTrlPlot -- large files are memory-mapped and downsampled to at most LogView.MaxRows rows -- an empty file name restores the live log",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.gz",
+				}},
+				{"Plot", ki.Props{}},
+			},
+		}},
+		{"ExportPlot", ki.Props{
+			"desc": "save the plot tab of given name, e.g., TrnEpcPlot, as SVG / PDF figures, as set in PlotExport",
+			"icon": "file-save",
+			"Args": ki.PropSlice{
+				{"Plot", ki.Props{}},
+			},
+		}},
+		{"DiffARFs", ki.Props{
+			"desc": "compare the Activation-based Receptive Fields saved in two directories (can select files too), saving and viewing per-unit difference maps B - A and their stats",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"Dir A", ki.Props{
+					"ext": ".tsv",
+				}},
+				{"Dir B", ki.Props{
+					"ext": ".tsv",
+				}},
+			},
+		}},
+		{"DiffPatBanks", ki.Props{
+			"desc": "compare the env bit patterns of two pattern bank (or plain pattern) .json files, saving and viewing a table of the patterns that differ",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File A", ki.Props{
+					"ext": ".json",
+				}},
+				{"File B", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
 	},
 }
 
 func (ss *Sim) CmdArgs() {
 	ss.NoGui = true
 	var nogui bool
+	var saveTrlLog bool
 	var saveEpcLog bool
 	var saveRunLog bool
+	var saveSQL bool
+	var world string
+	var worldSize string
+	var step string
+	var nSteps int
+	var clamp string
+	var ecModules string
+	var kernelCheck string
+	var inputs string
+	var supFrac float64
+	var inPCon float64
+	var gtauVar float64
+	var maxGe float64
+	var itiDecay float64
+	var forageDist float64
+	var scanP float64
+	var pauseP float64
+	var ecSize int
+	var parallel int
+	var sdNames string
+	var vestibGain string
+	var occlude string
+	var arena string
+	var protocol string
 	var note string
-	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
+	var bench bool
+	var ecCompare bool
+	var pprofAddr string
+	var logPrec int
+	var logConfig string
+	var analyze string
+	var evalCkpts string
+	var arfDiff string
+	var patsDiff string
+	var unitGroups string
+	var compare string
+	var actExportLays string
+	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet to use on top of Base -- names of sets as listed in compiled-in params or loaded params, and numeric overrides, separated by +, e.g., VestDelay+Gi=1.6 or LongPlus+#EC:Layer.Inhib.Layer.Gi=1.8")
+	flag.StringVar(&ss.SaveParams, "save-params", "", "if set, save the fully composed params set applied to this file, as JSON")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
 	flag.StringVar(&note, "note", "", "user note -- describe the run params etc")
 	flag.IntVar(&ss.MaxRuns, "runs", 1, "number of runs to do (note that MaxEpcs is in paramset)")
 	flag.BoolVar(&ss.SaveWts, "wts", true, "if true, save final weights after each run")
+	flag.IntVar(&ss.CkptEval.Every, "wts-every", 0, "save a weights checkpoint every this many training epochs, for -eval-ckpts -- 0 = none")
+	flag.StringVar(&evalCkpts, "eval-ckpts", "", "if set, glob pattern of weights checkpoints (from -wts-every) to evaluate on a fixed trajectory with frozen weights, saving a table of decoding metrics per epoch, instead of the usual runs")
+	flag.IntVar(&ss.CkptEval.Trials, "eval-ckpts-trials", 1000, "number of trials in the -eval-ckpts evaluation trajectory")
+	flag.StringVar(&ss.CkptEval.Decoder, "eval-ckpts-decoder", "last", "weights file whose decoder (readout) weights are frozen and used with every -eval-ckpts checkpoint -- last = the last checkpoint, empty = each checkpoint's own decoder")
 	flag.BoolVar(&ss.SaveARFs, "arfs", true, "if true, save final arfs after each run")
+	flag.StringVar(&arfDiff, "arf-diff", "", "if set, dirA,dirB directories of ARFs saved by -arfs to compare, saving per-unit difference maps and a table of their stats, instead of the usual runs")
+	flag.StringVar(&compare, "compare", "", "if set, comma-separated run directories whose epoch logs and ARFs are opened into a GUI window, overlaid by run tag (the directory name), for comparison, instead of the usual runs")
+	flag.StringVar(&ss.PatsFile, "pats", "", "if set, pattern bank (or plain pattern) .json file of the env bit patterns of materials and actions, instead of pats.json -- its hash is recorded in the weights files")
+	flag.StringVar(&patsDiff, "pats-diff", "", "if set, fileA,fileB pattern bank .json files to compare, saving a table of the patterns that differ, instead of the usual runs")
+	flag.BoolVar(&saveTrlLog, "trllog", false, "if true, save train trial log to file")
 	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
 	flag.BoolVar(&saveRunLog, "runlog", false, "if true, save run epoch log to file")
+	flag.BoolVar(&ss.LogGz, "log-gz", false, "if true, gzip the trial, epoch and run log files as they are written, adding .gz to their names")
+	flag.BoolVar(&ss.AppendLogs, "append-logs", false, "if true, append to existing trial, epoch and run log files, e.g., when resuming a run, instead of truncating them -- headers are not repeated")
+	flag.BoolVar(&saveSQL, "sqlite", false, "if true, save all logs to a single SQLite database file instead of .tsv files (requires build with -tags sqlite)")
+	flag.IntVar(&logPrec, "log-prec", 0, "precision for float values written to all log files, unless set per log in -log-config -- 0 = default")
+	flag.BoolVar(&ss.ParamAudit.On, "params-audit", false, "if true, record every param applied by SetParams, with its selector, object, and old and new values, to the params_audit log file")
+	flag.StringVar(&logConfig, "log-config", "", "JSON file with per-log precision and Include / Exclude column lists, keyed by log name (trn_trl, trn_epc, run, etc, or all)")
 	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
 	flag.BoolVar(&ss.UseMPI, "mpi", false, "if set, use MPI for distributed computation")
+	flag.BoolVar(&ss.S1On, "s1", false, "if true, include S1 somatosensory wall proximity input layer")
+	flag.BoolVar(&ss.GoalOn, "goal", false, "if true, include egocentric goal direction and distance target layers")
+	flag.BoolVar(&ss.ActOn, "action", false, "if true, include an Action target layer decoding the action from EC, with NetAction, GenAction and ActMatch stats and per-action accuracy in the epoch log")
+	flag.BoolVar(&ss.Conj.On, "conj", false, "if true, include the Conj layer of conjunctive grid x head-direction cells, receiving from EC pools and Orientation -- add conjtune to -analyze to classify unit tuning")
+	flag.StringVar(&unitGroups, "unit-groups", "", "if set, named unit groups within layers with their own stats and NetView highlighting, as semicolon-separated Name=Layer:idx,idx entries of unit indexes within each pool, or Layer:each for a group per unit index, e.g., EC:each")
+	flag.BoolVar(&ss.UnitGroups.ARFs, "unit-group-arfs", false, "if true, compute the ARFs of each unit group, along with those of the ARF layers")
+	flag.BoolVar(&ss.WtChg.On, "wt-chg", false, "if true, log the mean L2 norm of DWt per trial, and of the cumulative weight change since the start of the run, of each prjn per epoch")
+	ss.UnitClass.FlagVar("unit-class", "classify the units of the ARF layers as grid, border, place, head-direction or conjunctive cells, logging the fraction in each class,")
+	ss.Border.FlagVar("border", "compute the border score of each unit of the ARF layers, with shuffle-based significance, logging them per unit,")
+	flag.IntVar(&ss.Border.NShuffle, "border-shuffles", 100, "number of shuffles of each unit's position RF for the significance of its -border score")
+	ss.Speed.FlagVar("speed", "regress the activity of each unit of the ARF layers on the mean movement speed of each trial, with circular-shift shuffle controls, logging slope and R2 per unit,")
+	flag.StringVar(&ss.Traj.Save, "traj-save", "", "if set, file to save the trajectory of the first run to: the executed actions and positions of each step of the training env")
+	flag.StringVar(&ss.Traj.File, "traj", "", "if set, trajectory file saved by -traj-save that the training env replays exactly in every run, whatever the seed, instead of generating its actions")
+	flag.BoolVar(&ss.ActExport.On, "act-export", false, "if true, test after training and save the ActM of the -act-export-layers on each test trial to a compressed numpy .npz archive per test epoch, with an index .tsv file")
+	flag.StringVar(&actExportLays, "act-export-layers", "", "comma-separated layers saved by -act-export -- empty = the ARF layers")
+	flag.StringVar(&step, "step", "", "instead of training all the runs, train -nsteps steps of given grain: Cycle, Quarter, Trial, Epoch or Run")
+	flag.IntVar(&nSteps, "nsteps", 1, "number of -step steps to train")
+	flag.StringVar(&world, "world", "OpenField", "world preset: OpenField, LinearTrack, TMaze, Figure8, RadialArm, CircularField, LShape")
+	flag.StringVar(&worldSize, "world-size", "", "X,Y size of the world grid, including the outer walls -- empty = 50,50")
+	flag.BoolVar(&ss.RandWorld.On, "rand-world", false, "if true, train each run on a newly generated random world, and test on a fixed held-out world -- both are saved alongside the logs")
+	flag.IntVar(&ss.RandWorld.NWalls, "rand-world-walls", 6, "number of interior wall segments in -rand-world worlds")
+	flag.StringVar(&ss.RandWorld.EvalFile, "eval-world", "", "world .tsv file to use as the held-out -rand-world evaluation world -- if empty, it is generated from -eval-seed")
+	flag.Int64Var(&ss.RandWorld.EvalSeed, "eval-seed", 1000, "random seed for generating the held-out -rand-world evaluation world")
+	flag.BoolVar(&ss.Adapt.On, "adapt", false, "if true, adapt the environment difficulty to keep the position decoding error within -adapt-lo to -adapt-hi: the number of -rand-world walls, and the probability of blanking the allothetic cue inputs")
+	flag.Float64Var(&ss.Adapt.LoErr, "adapt-lo", 1, "low end of the -adapt target band of PosErr, in world units -- difficulty is raised below it")
+	flag.Float64Var(&ss.Adapt.HiErr, "adapt-hi", 2, "high end of the -adapt target band of PosErr, in world units -- difficulty is lowered above it")
+	flag.IntVar(&ss.Adapt.Window, "adapt-window", 5, "number of epochs that PosErr is averaged over, and between -adapt difficulty changes")
+	flag.IntVar(&ss.Adapt.MaxWalls, "adapt-max-walls", 12, "number of interior -rand-world walls at the highest -adapt difficulty")
+	flag.BoolVar(&ss.Choice.On, "choice", false, "if true, decode prospective position at maze choice points (TMaze, Figure8 worlds)")
+	flag.IntVar(&ss.MinusQtrs, "minus-qtrs", 3, "number of quarters in the minus phase")
+	flag.IntVar(&ss.PlusQtrs, "plus-qtrs", 1, "number of quarters in the plus phase")
+	flag.IntVar(&ss.CycPerQtr, "cyc-per-qtr", 25, "number of cycles per quarter")
+	flag.IntVar(&ss.TrialSteps, "trial-steps", 1, "number of env steps per trial, with inputs applied at even intervals over the minus phase and the targets of the last step")
+	flag.IntVar(&ss.ITI.Cycles, "iti-cycles", 0, "number of blank cycles with all inputs off between trials -- 0 = none")
+	flag.Float64Var(&itiDecay, "iti-decay", 0, "proportion of activation state decayed between trials, before the -iti-cycles -- 0 = none, 1 = full reset")
+	flag.StringVar(&ss.TestWorld, "test-world", "", "world preset to test in, separately from training: OpenField, LinearTrack, TMaze, Figure8, RadialArm, CircularField, LShape -- empty = the training world, or the held-out world with -rand-world")
+	flag.IntVar(&ss.TrainEnv.AngInc, "ang-inc", 90, "head direction resolution: angle increment for rotation, in degrees -- must evenly divide 360")
+	flag.StringVar(&vestibGain, "vestib-gain", "", "JSON protocol file with a list of vestibular gain blocks, e.g., [{\"Epoch\": 100, \"Trials\": 2000, \"Gain\": 2}]")
+	flag.StringVar(&protocol, "protocol", "", "JSON file with a multi-phase experiment protocol: phases with Epochs, Frozen learning, World preset, Occlude blocks, VestibGain, CueRot, Clamp schedules and Analyses to run at the end -- sets the number of epochs")
+	flag.Float64Var(&forageDist, "forage-dist", 5, "distance to the goal, in grid cells, within which it is approached in protocol Forage task blocks")
+	flag.StringVar(&arena, "arena", "", "JSON protocol file with a list of arena blocks, rotating or mirroring the whole arena relative to the global frame from the block's Epoch, e.g., [{\"Epoch\": 50, \"XForm\": \"Rot90\"}, {\"Epoch\": 60, \"XForm\": \"None\"}]")
+	flag.StringVar(&occlude, "occlude", "", "JSON protocol file with a list of sensory occlusion blocks, blanking Prev_Position, Prev_Orientation and S1 (or the block's Layers) while the heading is in a dark sector, e.g., [{\"Epoch\": 100, \"MinAng\": 90, \"MaxAng\": 180}]")
+	flag.StringVar(&clamp, "clamp", "", "per-layer clamp schedules as comma-separated Layer:Phase[:PTrial] entries, e.g., Prev_Position:ClampPlus:.2")
+	flag.IntVar(&ss.TrainEnv.Stuck.Steps, "stuck-steps", 20, "number of steps without displacement after which the agent is stuck -- 0 = no stuck detection")
+	flag.BoolVar(&ss.TrainEnv.Stuck.Unstick, "unstick", false, "if true, the reflexive policy heads toward the most open direction while the agent is stuck")
+	flag.BoolVar(&ss.TrainEnv.Scan.On, "scan", false, "if true, the reflexive policy occasionally stops and scans, turning in place to sample multiple headings, or pauses in place -- marked in the ScanSteps and PauseSteps columns of the training trial log")
+	flag.Float64Var(&scanP, "scan-p", 0.02, "probability of starting a -scan bout on each step of exploration in the open")
+	flag.Float64Var(&pauseP, "pause-p", 0.02, "probability of starting a -scan pause on each step of exploration in the open")
+	flag.Float64Var(&ss.ActStats.MaxFrac, "act-max-frac", 0.8, "warn when any action is more than this fraction of the actions executed in a training epoch -- 0 = no warning")
+	flag.Float64Var(&supFrac, "sup-frac", 1, "fraction of training trials on which Out_Position and Orientation targets are provided")
+	flag.BoolVar(&ss.SelfLoc.On, "selfloc", false, "if true, drive Prev_Position and Prev_Orientation inputs from the network's own previous decoded outputs, blended with ground truth")
+	flag.IntVar(&ss.SelfLoc.ResetInt, "selfloc-reset", 50, "number of trials between ground-truth resets in selfloc mode -- 0 = only at start of run")
+	flag.BoolVar(&ss.Shuffle.On, "shuffle", false, "if true, shuffle the temporal order of training steps, breaking trajectory continuity while keeping the same input distribution -- control condition")
+	flag.IntVar(&ss.Shuffle.BufSize, "shuffle-buf", 1000, "number of steps in the -shuffle buffer")
+	flag.BoolVar(&ss.LinDec.On, "lindec", false, "if true, record test trials for the k-fold cross-validated linear decoder -- add lindec to -analyze to save its per-fold stats at the end of each run")
+	flag.IntVar(&ss.LinDec.K, "lindec-folds", 5, "number of -lindec cross-validation folds")
+	flag.IntVar(&ss.AttrShift.Cycles, "attrshift-cycles", 200, "number of cycles of constant velocity input in the attrshift analysis")
+	flag.BoolVar(&ss.SR.On, "sr", false, "if true, estimate the successor representation implied by hidden layers and save its eigenvectors each epoch")
+	flag.IntVar(&ecSize, "ec-size", 10, "number of pools along each dimension of the EC sheet")
+	flag.Float64Var(&inPCon, "in-pcon", 1, "proportion of connectivity in the input prjns into EC -- less than 1 uses sparse random connectivity")
+	flag.Float64Var(&gtauVar, "ec-gtau-var", 0, "half-range of the uniform random offsets added to the EC Act.Dt.GTau per unit, for heterogeneous time constants -- 0 = homogeneous")
+	flag.BoolVar(&ss.LatDiag.On, "latdiag", false, "if true, log EC lateral weight symmetry, per-offset kernel stats and drift from the initial weights each epoch")
+	flag.BoolVar(&ss.Entorhinal.TwistTorus, "ec-twist", false, "if true, use twisted-torus (hexagonal) wrap-around for the EC lateral inhibition instead of the standard torus")
+	flag.BoolVar(&ss.Entorhinal.Dale, "ec-dale", false, "if true, enforce Dale's law for the EC lateral inhibition, sending it through separate inhibitory interneuron layers instead of directly from the excitatory EC units")
+	flag.BoolVar(&ss.Entorhinal.ExcitLateral, "ec-excit-lateral", false, "if true, add the excitatory EC lateral prjn, with gaussian weights biased toward a different corner for each unit of a pool, for orientation bias in single EC cells")
+	flag.BoolVar(&ss.Entorhinal.EC2D, "ec-2d", false, "if true, use a 2D EC sheet without pools, with the same number of units as the 4D one")
+	flag.BoolVar(&ecCompare, "ec-compare", false, "if true, run the benchmark protocol for both the 4D and the 2D EC, and save a side-by-side table of their metrics, instead of the usual runs")
+	flag.BoolVar(&ss.Entorhinal.GTauPerPool, "ec-gtau-pool", false, "if true, draw one -ec-gtau-var offset per EC pool instead of per unit")
+	flag.StringVar(&inputs, "inputs", "", "env states applied to the input and target layers, as comma-separated State:Layer entries, e.g., PrevPosition:Prev_Position,PrevAngle:Prev_Orientation for predictive learning -- empty = the defaults for the sim config")
+	flag.BoolVar(&ss.StrictInputs, "strict-inputs", false, "if true, exit at Config if any layer or state of the -inputs does not exist, instead of skipping it")
+	flag.BoolVar(&ss.Entorhinal.KernelScale, "kernel-scale", true, "scale the EC lateral kernel radii and sigmas (including those of -ec-modules) with -ec-size, as fractions of the sheet size relative to -kernel-ref -- false to use them as is")
+	flag.IntVar(&ss.Entorhinal.KernelRef, "kernel-ref", 10, "EC size that the lateral kernel radii and sigmas are tuned for, and scaled from with -kernel-scale")
+	flag.StringVar(&kernelCheck, "kernel-check", "", "if set, comma-separated EC sizes to print the scaled lateral kernels of, and whether they fit, instead of the usual runs")
+	flag.StringVar(&ecModules, "ec-modules", "", "parallel EC sheets (grid modules) as comma-separated lateral inhibition Radius:Sigma entries, e.g., 2:2,3:2,5:3 -- empty = one EC sheet")
+	flag.IntVar(&parallel, "parallel", 1, "number of Sims with different seeds to run concurrently in this process, each writing its own logs")
+	flag.BoolVar(&ss.SaveSD, "save-sd", false, "if true, save final weights as a PyTorch-style state dict JSON file after each run")
+	flag.StringVar(&ss.InitSD, "init-sd", "", "PyTorch-style state dict JSON file to initialize weights from at the start of each run")
+	flag.StringVar(&sdNames, "sd-names", "", "JSON file mapping our state dict keys (Recv.Send.weight) to those of an external model")
+	flag.StringVar(&ss.ExecHook.Cmd, "exec-after-epoch", "", "external command (with args) to run after each training epoch, given the paths to the epoch log and latest weights as extra args")
+	flag.IntVar(&ss.ExecHook.Every, "exec-every", 1, "run the -exec-after-epoch command every this many epochs")
+	flag.BoolVar(&ss.Watchdog.On, "watchdog", true, "if true, check for NaN / Inf or runaway Ge each epoch, and stop training with a diagnostic dump if found")
+	flag.Float64Var(&maxGe, "watchdog-max-ge", 5, "maximum Ge of any unit before the -watchdog considers a layer runaway")
+	flag.BoolVar(&bench, "bench", false, "if true, run the standard benchmark protocol and append the results to the scoreboard file, instead of the usual runs")
+	flag.StringVar(&ss.Bench.File, "bench-file", "can_ec_bench.tsv", "scoreboard file for -bench results")
+	flag.StringVar(&analyze, "analyze", "", "comma-separated list of analyses to run at the end of each run, saving their outputs to log files, e.g., hdtune,sr")
+	flag.BoolVar(&ss.Report.On, "report", false, "if true, render an HTML summary of each run, with epoch curves, ARF images, grid score histograms and params, saved next to the logs")
+	flag.StringVar(&pprofAddr, "pprof", "", "if set, serve pprof profiles over HTTP at this address, e.g., localhost:6060")
 	flag.Parse()
+	if pprofAddr != "" {
+		StartPProf(pprofAddr)
+	}
+	ss.SR.Save = ss.SR.On
+	ss.SupFrac = float32(supFrac)
+	ss.Entorhinal.InPCon = float32(inPCon)
+	ss.Entorhinal.GTauVar.Var = gtauVar
+	ss.Watchdog.MaxGe = float32(maxGe)
+	ss.ITI.Decay = float32(itiDecay)
+	ss.Task.ForageDist = float32(forageDist)
+	ss.TrainEnv.Scan.PScan = float32(scanP)
+	ss.TrainEnv.Scan.PPause = float32(pauseP)
+	ss.Entorhinal.ECSize.Set(ecSize, ecSize)
+	if ss.Traj.File != "" {
+		if err := ss.OpenTraj(); err != nil {
+			log.Println(err)
+			ss.Traj.File = ""
+		}
+	}
+	if actExportLays != "" {
+		ss.ActExport.Layers = strings.Split(actExportLays, ",")
+	}
+	if sdNames != "" {
+		ss.OpenSDNames(gi.FileName(sdNames))
+	}
+	if vestibGain != "" {
+		ss.OpenVestibGain(gi.FileName(vestibGain))
+	}
+	if occlude != "" {
+		ss.OpenOcclusion(gi.FileName(occlude))
+	}
+	if arena != "" {
+		ss.OpenArena(gi.FileName(arena))
+	}
+	if logConfig != "" {
+		ss.OpenLogConfigs(gi.FileName(logConfig))
+	}
+	if logPrec > 0 {
+		ss.SetLogPrec(logPrec)
+	}
+	if ans, err := ParseAnalyses(analyze); err != nil {
+		log.Println(err)
+	} else {
+		ss.Analyze = ans
+	}
+	if css, err := ParseClampScheds(clamp); err != nil {
+		log.Println(err)
+	} else {
+		ss.ClampScheds = css
+	}
+	if ims, err := ParseInputs(inputs); err != nil {
+		if ss.StrictInputs {
+			log.Fatalln(err)
+		}
+		log.Println(err)
+	} else if len(ims) > 0 {
+		ss.Inputs = ims
+		ss.InputsSet = true
+	}
+	if grps, each, err := ParseUnitGroups(unitGroups); err != nil {
+		log.Println(err)
+	} else {
+		ss.UnitGroups.Groups = grps
+		ss.UnitGroups.Each = each
+	}
+	if mods, err := ParseEcModules(ecModules); err != nil {
+		log.Println(err)
+	} else {
+		ss.Entorhinal.Modules = mods
+	}
+	if wp, err := WorldPresetFromString(world); err != nil {
+		log.Println(err)
+	} else {
+		ss.TrainEnv.Preset = wp
+	}
+	if worldSize != "" {
+		var sz evec.Vec2i
+		if _, err := fmt.Sscanf(worldSize, "%d,%d", &sz.X, &sz.Y); err != nil || sz.X < 8 || sz.Y < 8 {
+			log.Printf("-world-size must be X,Y of at least 8,8: %s\n", worldSize)
+		} else {
+			ss.TrainEnv.Size = sz
+		}
+	}
+	if ss.TestWorld != "" {
+		if _, err := WorldPresetFromString(ss.TestWorld); err != nil {
+			log.Println(err)
+			ss.TestWorld = ""
+		}
+	}
+	if protocol != "" { // after world and clamp, which are its base values
+		ss.OpenProtocol(gi.FileName(protocol))
+	}
+	if kernelCheck != "" {
+		var sizes []int
+		for _, ks := range strings.Split(kernelCheck, ",") {
+			sz, err := strconv.Atoi(strings.TrimSpace(ks))
+			if err != nil || sz < 1 {
+				log.Printf("-kernel-check sizes must be positive integers: %s\n", ks)
+				return
+			}
+			sizes = append(sizes, sz)
+		}
+		ss.Entorhinal.KernelCheck(sizes)
+		return
+	}
+	if parallel > 1 {
+		ss.RunParallel(parallel, saveTrlLog, saveEpcLog, saveRunLog)
+		return
+	}
 	ss.Init()
 
 	//if ss.UseMPI {
@@ -2227,42 +3799,88 @@ func (ss *Sim) CmdArgs() {
 		fmt.Printf("Using ParamSet: %s\n", ss.ParamSet)
 	}
 
-	if saveEpcLog {
-		var err error
-		fnm := ss.LogFileName("trn_epc")
-		ss.TrnEpcFile, err = os.Create(fnm)
-		if err != nil {
+	if bench {
+		ss.RunBench()
+		return
+	}
+
+	if ecCompare {
+		ss.RunECCompare()
+		return
+	}
+
+	if evalCkpts != "" {
+		if err := ss.CkptEval.SetFiles(evalCkpts); err != nil {
 			log.Println(err)
-			ss.TrnEpcFile = nil
-		} else {
-			fmt.Printf("Saving training epoch log to: %v\n", fnm)
-			defer ss.TrnEpcFile.Close()
+			return
 		}
-		fnm = ss.LogFileName("tst_epc")
-		ss.TstEpcFile, err = os.Create(fnm)
+		ss.RunCkptEval()
+		return
+	}
+
+	if compare != "" {
+		cmp, err := ss.OpenCompare(strings.Split(compare, ","))
 		if err != nil {
 			log.Println(err)
-			ss.TstEpcFile = nil
-		} else {
-			fmt.Printf("Saving testing epoch log to: %v\n", fnm)
-			defer ss.TstEpcFile.Close()
+			return
+		}
+		gimain.Main(func() {
+			win := ss.ConfigCompareGui(cmp)
+			win.StartEventLoop()
+		})
+		return
+	}
+
+	if patsDiff != "" {
+		fns := strings.Split(patsDiff, ",")
+		if len(fns) != 2 {
+			log.Printf("-pats-diff must be fileA,fileB: %s\n", patsDiff)
+			return
 		}
+		ss.DiffPatBanks(gi.FileName(fns[0]), gi.FileName(fns[1]))
+		return
 	}
-	if saveRunLog {
+
+	if arfDiff != "" {
+		dirs := strings.Split(arfDiff, ",")
+		if len(dirs) != 2 {
+			log.Printf("-arf-diff must be dirA,dirB: %s\n", arfDiff)
+			return
+		}
+		ss.DiffARFs(gi.FileName(dirs[0]), gi.FileName(dirs[1]))
+		return
+	}
+
+	if saveSQL {
 		var err error
-		fnm := ss.LogFileName("run")
-		ss.RunFile, err = os.Create(fnm)
+		fnm := ss.SQLFileName()
+		ss.SQLLog, err = OpenSQLiteLog(fnm)
 		if err != nil {
 			log.Println(err)
-			ss.RunFile = nil
+			ss.SQLLog = nil
 		} else {
-			fmt.Printf("Saving run log to: %v\n", fnm)
-			defer ss.RunFile.Close()
+			fmt.Printf("Saving all logs to SQLite database: %v\n", fnm)
+			defer ss.SQLLog.Close()
+			saveTrlLog = false
+			saveEpcLog = false
+			saveRunLog = false
 		}
 	}
+	ss.OpenLogFiles(saveTrlLog, saveEpcLog, saveRunLog)
+	defer ss.CloseLogFiles()
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
+	if step != "" {
+		var grain StepGrains
+		if err := grain.FromString(step); err != nil {
+			log.Println(err)
+			return
+		}
+		fmt.Printf("Running %d %s steps\n", nSteps, grain)
+		ss.TrainSteps(grain, nSteps)
+		return
+	}
 	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
 	ss.Train()
 }
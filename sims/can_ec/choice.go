@@ -0,0 +1,176 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// InApproach returns true if the agent is on the stem approaching the
+// ChoicePt, within dist grid cells, on the way out from the Start location
+func (ev *XYHDEnv) InApproach(dist int) bool {
+	if ev.Preset != TMaze && ev.Preset != Figure8 {
+		return false
+	}
+	if !ev.PassedStart {
+		return false
+	}
+	hw := ev.TrackW / 2
+	if absInt(ev.PosI.X-ev.ChoicePt.X) > hw {
+		return false
+	}
+	return ev.PosI.Y >= ev.ChoicePt.Y-dist && ev.PosI.Y < ev.ChoicePt.Y-hw
+}
+
+// ChoiceSweep analyzes the prospective position representation at maze choice
+// points: during each approach to the choice point, the position is decoded
+// from Out_Position every cycle of the minus phase, and the mean lateral offset
+// of the decoded position from the stem predicts the upcoming arm (forward sweep).
+// The prediction is compared with the actual journey at the end of the lap.
+type ChoiceSweep struct {
+	On          bool    `desc:"decode prospective position during approach to the choice point on TMaze and Figure8 worlds"`
+	Dist        int     `def:"8" desc:"number of grid cells before the choice point in which approach trials are analyzed"`
+	Approaching bool    `inactive:"+" desc:"currently in an approach to the choice point"`
+	Pending     bool    `inactive:"+" desc:"approach done, waiting for the lap to complete to compare prediction"`
+	NTrials     int     `inactive:"+" desc:"number of trials in the current approach"`
+	NCycles     int     `inactive:"+" desc:"number of cycles decoded in the current approach"`
+	SumX        float64 `inactive:"+" desc:"sum of decoded X offset from the choice point (positive = toward Right arm)"`
+	MaxAhead    float64 `inactive:"+" desc:"maximum distance of decoded position ahead of current position"`
+	Pred        string  `inactive:"+" desc:"predicted arm from the last approach"`
+	LapSeen     int     `view:"-" desc:"lap count of the TrainEnv when last checked for a completed lap"`
+}
+
+func (cs *ChoiceSweep) Defaults() {
+	cs.Dist = 8
+}
+
+// Reset resets the accumulated sweep data for a new approach
+func (cs *ChoiceSweep) Reset() {
+	cs.NTrials = 0
+	cs.NCycles = 0
+	cs.SumX = 0
+	cs.MaxAhead = 0
+}
+
+// SweepX returns the mean decoded X offset over the approach
+func (cs *ChoiceSweep) SweepX() float64 {
+	if cs.NCycles == 0 {
+		return 0
+	}
+	return cs.SumX / float64(cs.NCycles)
+}
+
+// ChoiceTrial updates the choice sweep state at the start of each trial
+// (after the env has been stepped), returning true if position should be
+// decoded every cycle during this trial
+func (ss *Sim) ChoiceTrial() bool {
+	cs := &ss.Choice
+	ev := &ss.TrainEnv
+	if !cs.On {
+		return false
+	}
+	if ev.LapSince(&cs.LapSeen) && cs.Pending {
+		ss.LogChoice(ss.ChoiceLog)
+		cs.Pending = false
+	}
+	in := ev.InApproach(cs.Dist)
+	switch {
+	case in && !cs.Approaching:
+		cs.Approaching = true
+		cs.Pending = false
+		cs.Reset()
+	case !in && cs.Approaching:
+		cs.Approaching = false
+		cs.Pending = true
+		cs.Pred = "Left"
+		if cs.SweepX() > 0 {
+			cs.Pred = "Right"
+		}
+	}
+	if in {
+		cs.NTrials++
+	}
+	return in
+}
+
+// ChoiceCycle decodes the current position from Out_Position activity
+// and accumulates the sweep stats -- called every minus-phase cycle of approach trials
+func (ss *Sim) ChoiceCycle() {
+	cs := &ss.Choice
+	ev := &ss.TrainEnv
+	dec := ss.DecodePosAct(ev, true)
+	cs.SumX += float64(dec.X) - float64(ev.ChoicePt.X)
+	ahead := float64(dec.Y) - float64(ev.PosF.Y)
+	if ahead > cs.MaxAhead {
+		cs.MaxAhead = ahead
+	}
+	cs.NCycles++
+}
+
+// LogChoice adds data for the last approach to the choice point to the ChoiceLog
+func (ss *Sim) LogChoice(dt *etable.Table) {
+	cs := &ss.Choice
+	ev := &ss.TrainEnv
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ev.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ev.Epoch.Cur))
+	dt.SetCellFloat("Lap", row, float64(ev.Lap.Prv))
+	dt.SetCellFloat("NTrials", row, float64(cs.NTrials))
+	dt.SetCellFloat("NCycles", row, float64(cs.NCycles))
+	dt.SetCellFloat("SweepX", row, cs.SweepX())
+	dt.SetCellFloat("SweepAhead", row, cs.MaxAhead)
+	dt.SetCellString("Pred", row, cs.Pred)
+	dt.SetCellString("Actual", row, ev.Journey)
+	if cs.Pred == ev.Journey {
+		dt.SetCellFloat("Correct", row, 1)
+	} else {
+		dt.SetCellFloat("Correct", row, 0)
+	}
+	ss.SQLWriteRow("choice", dt, row)
+
+	ss.UpdtPlot(ss.ChoicePlot)
+}
+
+func (ss *Sim) ConfigChoiceLog(dt *etable.Table) {
+	dt.SetMetaData("name", "ChoiceLog")
+	dt.SetMetaData("desc", "Prospective position decoding at maze choice points, and whether it predicts the upcoming arm")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Lap", etensor.INT64, nil, nil},
+		{"NTrials", etensor.INT64, nil, nil},
+		{"NCycles", etensor.INT64, nil, nil},
+		{"SweepX", etensor.FLOAT64, nil, nil},
+		{"SweepAhead", etensor.FLOAT64, nil, nil},
+		{"Pred", etensor.STRING, nil, nil},
+		{"Actual", etensor.STRING, nil, nil},
+		{"Correct", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigChoicePlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Choice Point Plot"
+	plt.Params.XAxisCol = "Lap"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Lap", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NTrials", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NCycles", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("SweepX", eplot.On, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("SweepAhead", eplot.On, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Correct", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
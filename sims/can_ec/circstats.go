@@ -0,0 +1,97 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "math"
+
+// circular statistics on angles expressed in degrees -- used for
+// orientation decoding errors and head-direction tuning analysis.
+
+// AngNorm wraps given angle in degrees into the range [0, 360)
+func AngNorm(ang float64) float64 {
+	ang = math.Mod(ang, 360)
+	if ang < 0 {
+		ang += 360
+	}
+	return ang
+}
+
+// AngDiff returns the signed circular difference a - b in degrees,
+// wrapped into the range (-180, 180], so that e.g., 350 vs. 10 is -20, not 340
+func AngDiff(a, b float64) float64 {
+	d := AngNorm(a - b)
+	if d > 180 {
+		d -= 360
+	}
+	return d
+}
+
+// CircMean returns the circular mean direction (in degrees, [0, 360)) and the
+// mean resultant length r (0..1) of given angles in degrees.
+// wts are optional weights for each angle (e.g., activity in a tuning curve) -- nil = all 1.
+// r is 0 if there is no net direction (no data, or uniformly distributed angles).
+func CircMean(angs, wts []float64) (mean, r float64) {
+	var sx, sy, sw float64
+	for i, a := range angs {
+		w := 1.0
+		if wts != nil {
+			w = wts[i]
+		}
+		rad := a * math.Pi / 180
+		sx += w * math.Cos(rad)
+		sy += w * math.Sin(rad)
+		sw += w
+	}
+	if sw <= 0 {
+		return 0, 0
+	}
+	r = math.Sqrt(sx*sx+sy*sy) / sw
+	mean = AngNorm(math.Atan2(sy, sx) * 180 / math.Pi)
+	return
+}
+
+// CircStd returns the circular standard deviation in degrees as a function of
+// mean resultant length r: sqrt(-2 ln r).  Returns 180 for r = 0 (no concentration).
+func CircStd(r float64) float64 {
+	if r <= 0 {
+		return 180
+	}
+	if r >= 1 {
+		return 0
+	}
+	return math.Sqrt(-2*math.Log(r)) * 180 / math.Pi
+}
+
+// CircMaxR is the maximum mean resultant length used by CircKappa, so that
+// kappa stays finite (about 5e5) when all the angles are the same, e.g., all
+// 0 errors once the network has learned
+const CircMaxR = 1 - 1e-6
+
+// CircKappa returns the approximate maximum-likelihood concentration parameter kappa
+// of a von Mises distribution with mean resultant length r (Fisher, 1993).
+// 0 = uniform, larger = more concentrated, up to the kappa of CircMaxR.
+func CircKappa(r float64) float64 {
+	r = math.Min(r, CircMaxR)
+	switch {
+	case r <= 0:
+		return 0
+	case r < 0.53:
+		return 2*r + r*r*r + 5*r*r*r*r*r/6
+	case r < 0.85:
+		return -0.4 + 1.39*r + 0.43/(1-r)
+	}
+	return 1 / (r*r*r - 4*r*r + 3*r)
+}
+
+// CircErrStats returns summary statistics of signed angular errors in degrees:
+// the circular mean error (signed, in (-180, 180] -- i.e., the bias),
+// the circular standard deviation, and the concentration kappa.
+func CircErrStats(errs []float64) (mean, sd, kappa float64) {
+	m, r := CircMean(errs, nil)
+	mean = AngDiff(m, 0)
+	sd = CircStd(r)
+	kappa = CircKappa(r)
+	return
+}
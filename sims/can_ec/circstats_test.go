@@ -0,0 +1,47 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCircKappa(t *testing.T) {
+	tests := []struct {
+		name string
+		r    float64
+		want float64
+	}{
+		{"uniform", 0, 0},
+		{"low", 0.5, 1 + 0.125 + 5*0.03125/6},
+		{"mid", 0.8, -0.4 + 1.39*0.8 + 0.43/0.2},
+		{"high", 0.9, 1 / (0.729 - 4*0.81 + 2.7)},
+	}
+	for _, tt := range tests {
+		if got := CircKappa(tt.r); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: CircKappa(%g) = %g, want %g", tt.name, tt.r, got, tt.want)
+		}
+	}
+	kmax := CircKappa(CircMaxR)
+	if math.IsInf(kmax, 0) || math.IsNaN(kmax) || kmax < 1e5 {
+		t.Errorf("CircKappa(CircMaxR) = %g, want large and finite", kmax)
+	}
+	for _, r := range []float64{1, 1 + 1e-12} {
+		if got := CircKappa(r); got != kmax {
+			t.Errorf("CircKappa(%g) = %g, want the kappa of CircMaxR %g", r, got, kmax)
+		}
+	}
+}
+
+func TestCircErrStatsSame(t *testing.T) {
+	mean, sd, kappa := CircErrStats([]float64{0, 0, 0, 0})
+	if mean != 0 || sd != 0 {
+		t.Errorf("mean, sd = %g, %g, want 0, 0", mean, sd)
+	}
+	if math.IsInf(kappa, 0) || math.IsNaN(kappa) {
+		t.Errorf("kappa = %g, want finite", kappa)
+	}
+}
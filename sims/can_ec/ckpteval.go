@@ -0,0 +1,242 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+// CkptEval evaluates a series of saved weight checkpoints with frozen weights,
+// for learning-dynamics figures without keeping the training process alive.
+// Checkpoints are saved every Every training epochs, named with the run and
+// epoch as in WeightsFileName.  Each checkpoint is loaded in turn, and run on
+// the same evaluation trajectory of Trials steps, generated from Seed, without
+// learning -- actions do not depend on the network, so the trajectory is
+// identical for all checkpoints.  The decoder (the prjns into the SupLays
+// readout layers) is frozen: the decoder weights of one checkpoint (by default
+// the last) are used with the EC of every checkpoint, so the metrics track
+// the learning of the EC representation itself, not that of its readout.
+// Decoding metrics are averaged over the trajectory into one row per
+// checkpoint of the CkptEvalLog.
+type CkptEval struct {
+	Every   int      `min:"0" desc:"save a weights checkpoint every this many training epochs -- 0 = none"`
+	Files   []string `desc:"checkpoint weights files to evaluate, sorted by run and epoch"`
+	Trials  int      `def:"1000" min:"1" desc:"number of trials in the evaluation trajectory"`
+	Seed    int64    `def:"1" desc:"random seed for the evaluation trajectory"`
+	Decoder string   `def:"last" desc:"weights file whose decoder weights are frozen and used for all checkpoints -- last = the last of Files, empty = each checkpoint's own decoder"`
+
+	DecWts [][]leabra.Synapse `view:"-" desc:"frozen decoder weights, for each prjn into the SupLays"`
+}
+
+// Defaults sets default params
+func (ce *CkptEval) Defaults() {
+	ce.Trials = 1000
+	ce.Seed = 1
+	ce.Decoder = "last"
+}
+
+// DecoderPrjns returns the prjns of the decoder: those received by the SupLays
+func (ss *Sim) DecoderPrjns() []*leabra.Prjn {
+	var pjs []*leabra.Prjn
+	for _, lnm := range ss.SupLays {
+		lyi := ss.Net.LayerByName(lnm)
+		if lyi == nil {
+			continue
+		}
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		for _, pj := range ly.RcvPrjns {
+			pjs = append(pjs, pj.(leabra.LeabraPrjn).AsLeabra())
+		}
+	}
+	return pjs
+}
+
+// FreezeDecoder loads the Decoder weights file, and saves its decoder
+// weights in DecWts, to be used for all checkpoints
+func (ss *Sim) FreezeDecoder() error {
+	ce := &ss.CkptEval
+	ce.DecWts = nil
+	fnm := ce.Decoder
+	switch {
+	case fnm == "":
+		return nil
+	case fnm == "last":
+		fnm = ce.Files[len(ce.Files)-1]
+	}
+	if err := ss.OpenWts(fnm); err != nil {
+		return err
+	}
+	fmt.Printf("CkptEval: frozen decoder from: %s\n", fnm)
+	for _, pj := range ss.DecoderPrjns() {
+		ce.DecWts = append(ce.DecWts, append([]leabra.Synapse(nil), pj.Syns...))
+	}
+	return nil
+}
+
+// SetFrozenDecoder sets the decoder weights to the frozen DecWts, if any
+func (ss *Sim) SetFrozenDecoder() {
+	ce := &ss.CkptEval
+	if ce.DecWts == nil {
+		return
+	}
+	for i, pj := range ss.DecoderPrjns() {
+		copy(pj.Syns, ce.DecWts[i])
+	}
+}
+
+// CkptFileRe matches the run and epoch in checkpoint file names from WeightsFileName
+var CkptFileRe = regexp.MustCompile(`_(\d+)_(\d+)\.wts(\.gz)?$`)
+
+// CkptRunEpoch returns the run and epoch of a checkpoint file name from
+// WeightsFileName, and false if it does not match
+func CkptRunEpoch(fnm string) (run, epc int, ok bool) {
+	m := CkptFileRe.FindStringSubmatch(fnm)
+	if m == nil {
+		return 0, 0, false
+	}
+	run, _ = strconv.Atoi(m[1])
+	epc, _ = strconv.Atoi(m[2])
+	return run, epc, true
+}
+
+// SetFiles sets Files to the checkpoint files matching the given glob pattern,
+// sorted by run and epoch, skipping any that are not named by WeightsFileName
+func (ce *CkptEval) SetFiles(pat string) error {
+	fns, err := filepath.Glob(pat)
+	if err != nil {
+		return err
+	}
+	ce.Files = ce.Files[:0]
+	for _, fn := range fns {
+		if _, _, ok := CkptRunEpoch(fn); ok {
+			ce.Files = append(ce.Files, fn)
+		}
+	}
+	if len(ce.Files) == 0 {
+		return fmt.Errorf("no checkpoint weights files match: %s", pat)
+	}
+	sort.Slice(ce.Files, func(i, j int) bool {
+		ri, ei, _ := CkptRunEpoch(ce.Files[i])
+		rj, ej, _ := CkptRunEpoch(ce.Files[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return ei < ej
+	})
+	return nil
+}
+
+// SaveCkpt saves a weights checkpoint every CkptEval.Every epochs --
+// called at the end of each training epoch
+func (ss *Sim) SaveCkpt(epc int) {
+	ce := &ss.CkptEval
+	if ce.Every <= 0 || epc%ce.Every != 0 {
+		return
+	}
+	ss.SaveWeights()
+}
+
+// RunCkptEval evaluates each of the CkptEval Files, and saves the results to
+// the ckpt_eval log
+func (ss *Sim) RunCkptEval() {
+	ce := &ss.CkptEval
+	dt := &etable.Table{}
+	ss.ConfigCkptEvalLog(dt)
+	ss.FinishTrainTrial()
+	ss.InitTestEnv()
+	if err := ss.FreezeDecoder(); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, fn := range ce.Files {
+		if err := ss.EvalCkpt(fn, dt); err != nil {
+			log.Println(err)
+		}
+	}
+	fnm := ss.LogFileName("ckpt_eval")
+	dt.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+	fmt.Printf("CkptEval: results saved to: %s\n", fnm)
+}
+
+// EvalCkpt loads the given checkpoint and runs the evaluation trajectory on it,
+// adding a row of results to dt
+func (ss *Sim) EvalCkpt(fnm string, dt *etable.Table) error {
+	ce := &ss.CkptEval
+	if err := ss.OpenWts(fnm); err != nil {
+		return err
+	}
+	ss.SetFrozenDecoder()
+	run, epc, _ := CkptRunEpoch(fnm)
+	fmt.Printf("CkptEval: run: %d  epoch: %d  file: %s\n", run, epc, fnm)
+
+	rand.Seed(ce.Seed)
+	ev := &ss.TestEnv
+	ev.Init(run)
+	ss.Net.InitActs()
+	ss.Supervised = true
+	var cosdiff, poserr, posacc, orierr, oriacc float64
+	for trl := 0; trl < ce.Trials; trl++ {
+		ss.TakeAction(ss.Net, ev)
+		ev.Step()
+		ss.ITICycles()
+		ss.ApplyInputs(ev)
+		ss.AlphaCyc(false)  // !train
+		ss.TrialStats(true) // accumulate, so ARFs are not updated
+		ds := ss.DecodeTrial(ev)
+		cosdiff += ss.TrlCosDiff
+		poserr += ds.PosErr
+		orierr += math.Abs(ds.OriErr)
+		if ds.PosACC {
+			posacc++
+		}
+		if ds.OriACC {
+			oriacc++
+		}
+	}
+
+	n := float64(ce.Trials)
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(run))
+	dt.SetCellFloat("Epoch", row, float64(epc))
+	dt.SetCellString("File", row, filepath.Base(fnm))
+	dt.SetCellFloat("CosDiff", row, cosdiff/n)
+	dt.SetCellFloat("PosErr", row, poserr/n)
+	dt.SetCellFloat("PosACC", row, posacc/n)
+	dt.SetCellFloat("OriErr", row, orierr/n)
+	dt.SetCellFloat("OriACC", row, oriacc/n)
+	return nil
+}
+
+func (ss *Sim) ConfigCkptEvalLog(dt *etable.Table) {
+	dt.SetMetaData("name", "CkptEvalLog")
+	dt.SetMetaData("desc", "Decoding metrics on a fixed evaluation trajectory per weights checkpoint")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"File", etensor.STRING, nil, nil},
+		{"CosDiff", etensor.FLOAT64, nil, nil},
+		{"PosErr", etensor.FLOAT64, nil, nil},
+		{"PosACC", etensor.FLOAT64, nil, nil},
+		{"OriErr", etensor.FLOAT64, nil, nil},
+		{"OriACC", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
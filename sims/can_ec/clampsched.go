@@ -0,0 +1,206 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/ki/kit"
+)
+
+// ClampPhases are the portions of the trial during which a ClampSched applies input
+type ClampPhases int32
+
+var KiT_ClampPhases = kit.Enums.AddEnum(ClampPhasesN, false, nil)
+
+const (
+	// ClampAll applies input throughout the trial
+	ClampAll ClampPhases = iota
+
+	// ClampMinus applies input only during the minus phase
+	ClampMinus
+
+	// ClampPlus applies input only during the plus phase
+	ClampPlus
+
+	ClampPhasesN
+)
+
+// ClampSched specifies when external input is applied to a layer within the trial,
+// vs. withheld.  Input layers are hard clamped to zero activity while withheld.
+// Target layers only use their input in the plus phase, so for them the schedule
+// mainly determines on which trials the target is present (PTrial), and
+// when in the plus phase it is.
+type ClampSched struct {
+	Layer    string      `desc:"name of layer to schedule"`
+	Phase    ClampPhases `desc:"phase of the trial during which input is applied"`
+	StartCyc int         `desc:"cycle within the Phase at which input starts being applied"`
+	EndCyc   int         `desc:"cycle within the Phase at which input stops being applied -- 0 = end of Phase"`
+	PTrial   float32     `def:"1" min:"0" max:"1" desc:"probability of applying input on any given trial -- e.g., .2 = only on 20% of trials"`
+
+	TrialOn bool           `inactive:"+" desc:"input is applied on the current trial, drawn according to PTrial"`
+	Clamped bool           `inactive:"+" desc:"input is currently applied"`
+	Pats    etensor.Tensor `view:"-" desc:"input pattern for the current trial"`
+	Zeros   etensor.Tensor `view:"-" desc:"zero pattern applied while input is withheld"`
+}
+
+// NewTrial records the input pattern for the current trial and determines
+// whether it is applied on this trial, according to PTrial.
+// A nil pats means no input at all on this trial.
+func (cs *ClampSched) NewTrial(pats etensor.Tensor) {
+	cs.Pats = pats
+	cs.Clamped = false
+	if pats == nil {
+		cs.TrialOn = false
+		return
+	}
+	if cs.Zeros == nil || cs.Zeros.Len() != pats.Len() {
+		cs.Zeros = etensor.NewFloat32(pats.Shapes(), nil, nil)
+	}
+	cs.TrialOn = cs.PTrial >= 1 || rand.Float32() < cs.PTrial
+}
+
+// ClampAt returns whether input should be applied at given cycle of the trial,
+// for given number of minus and plus phase cycles
+func (cs *ClampSched) ClampAt(cyc, minusCyc, plusCyc int) bool {
+	if !cs.TrialOn {
+		return false
+	}
+	st := 0
+	n := minusCyc + plusCyc
+	switch cs.Phase {
+	case ClampMinus:
+		n = minusCyc
+	case ClampPlus:
+		st = minusCyc
+		n = plusCyc
+	}
+	pc := cyc - st
+	ed := cs.EndCyc
+	if ed <= 0 || ed > n {
+		ed = n
+	}
+	return pc >= cs.StartCyc && pc < ed
+}
+
+// ParseClampScheds parses clamp schedules from a comma-separated list
+// of Layer:Phase[:PTrial] entries, e.g., "Prev_Position:ClampPlus:.2"
+func ParseClampScheds(str string) ([]ClampSched, error) {
+	var css []ClampSched
+	for _, s := range strings.Split(str, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		fs := strings.Split(s, ":")
+		if len(fs) < 2 {
+			return nil, fmt.Errorf("ClampSched: %q must be Layer:Phase[:PTrial]", s)
+		}
+		cs := ClampSched{Layer: fs[0], PTrial: 1}
+		if err := cs.Phase.FromString(fs[1]); err != nil {
+			return nil, fmt.Errorf("ClampSched: %q phase: %v", s, err)
+		}
+		if len(fs) > 2 {
+			p, err := strconv.ParseFloat(fs[2], 32)
+			if err != nil {
+				return nil, fmt.Errorf("ClampSched: %q PTrial: %v", s, err)
+			}
+			cs.PTrial = float32(p)
+		}
+		css = append(css, cs)
+	}
+	return css, nil
+}
+
+// FromString sets the phase from its name
+func (i *ClampPhases) FromString(s string) error {
+	for j := ClampPhases(0); j < ClampPhasesN; j++ {
+		if j.String() == s {
+			*i = j
+			return nil
+		}
+	}
+	return fmt.Errorf("ClampPhases: %q not found", s)
+}
+
+// ClampSchedByLayer returns the clamp schedule for given layer, nil if none
+func (ss *Sim) ClampSchedByLayer(lnm string) *ClampSched {
+	for i := range ss.ClampScheds {
+		if ss.ClampScheds[i].Layer == lnm {
+			return &ss.ClampScheds[i]
+		}
+	}
+	return nil
+}
+
+// ClampTrial is called from ApplyStatePats for each layer: if the layer has a
+// clamp schedule, it records the pattern for ClampCycle to apply, and returns
+// true, so the input is not applied directly
+func (ss *Sim) ClampTrial(lnm string, pats etensor.Tensor) bool {
+	cs := ss.ClampSchedByLayer(lnm)
+	if cs == nil {
+		return false
+	}
+	if ss.Alpha.Active { // TrialSubStep
+		cs.SubStep(pats)
+		return true
+	}
+	cs.NewTrial(pats)
+	return true
+}
+
+// ClampCycle applies or withholds input on scheduled layers for the current
+// cycle -- called in AlphaCyc prior to each Net.Cycle
+func (ss *Sim) ClampCycle() {
+	mc := ss.MinusQtrs * ss.CycPerQtr
+	pc := ss.PlusQtrs * ss.CycPerQtr
+	cyc := ss.Time.Cycle
+	for i := range ss.ClampScheds {
+		cs := &ss.ClampScheds[i]
+		if cs.Pats == nil {
+			continue
+		}
+		on := cs.ClampAt(cyc, mc, pc)
+		if on == cs.Clamped && cyc > 0 {
+			continue
+		}
+		lyi := ss.Net.LayerByName(cs.Layer)
+		if lyi == nil {
+			continue
+		}
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		switch {
+		case on:
+			ly.ApplyExt(cs.Pats)
+			if ly.Type() == emer.Target && cyc >= mc {
+				TargToExt(ly)
+			}
+		case ly.Type() == emer.Input:
+			ly.ApplyExt(cs.Zeros)
+		default:
+			ly.InitExt()
+		}
+		cs.Clamped = on
+	}
+}
+
+// TargToExt copies Targ to Ext for the neurons of given layer that have a
+// target, as QuarterFinal does at the end of the minus phase -- needed for
+// targets applied during the plus phase, after that copy has been done
+func TargToExt(ly *leabra.Layer) {
+	for ni := range ly.Neurons {
+		nrn := &ly.Neurons[ni]
+		if nrn.HasFlag(leabra.NeurHasTarg) {
+			nrn.Ext = nrn.Targ
+			nrn.SetFlag(leabra.NeurHasExt)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/gist"
+	"github.com/goki/gi/giv"
+)
+
+// ColorTheme configures the colors of the world views: the colors of the
+// materials, and the color maps of head directions in the Trace views and
+// of the decoding error lines and ErrMap.  Themes are either one of the
+// ColorThemes presets, or read from a .json theme file, which can also
+// define its own color maps in Maps, as lists of colors interpolated in
+// order, registered in AvailColorMaps.  The default colors of the materials
+// and the green to red error scale are not distinguishable for red-green
+// color blind users, so the ColorBlind preset uses the Okabe-Ito palette
+// and the cividis color map, which are.
+type ColorTheme struct {
+	Name       string              `desc:"name of the theme"`
+	MatColors  []string            `desc:"color of each material, in material order: Empty, wall, food, water, foodwas, waterwas -- lightgrey for any missing"`
+	RotMap     string              `desc:"color map of head directions, in the Trace views"`
+	ErrMap     string              `desc:"color map of the decoding error lines in the dTrace view, from low to high error"`
+	ErrGridMap string              `desc:"color map of the ErrMap view of the mean decoding error"`
+	Maps       map[string][]string `desc:"color maps defined by the theme, as lists of colors interpolated in order, registered in AvailColorMaps under their name"`
+}
+
+// ColorThemes are the preset world color themes, by name
+var ColorThemes = map[string]*ColorTheme{
+	"Default": {
+		Name:       "Default",
+		MatColors:  []string{"lightgrey", "black", "orange", "blue", "brown", "navy"},
+		RotMap:     "ColdHot",
+		ErrMap:     "GreenRed",
+		ErrGridMap: "ColdHot",
+		Maps: map[string][]string{
+			"GreenRed": {"#00ff00", "#ffff00", "#ff0000"},
+		},
+	},
+	"ColorBlind": {
+		Name:       "ColorBlind",
+		MatColors:  []string{"#eeeeee", "#000000", "#e69f00", "#0072b2", "#f0e442", "#56b4e9"},
+		RotMap:     "Cividis",
+		ErrMap:     "BlueVermillion",
+		ErrGridMap: "Cividis",
+		Maps: map[string][]string{
+			"Cividis":        {"#00204d", "#414d6b", "#7c7b78", "#bcaf6f", "#ffea46"},
+			"BlueVermillion": {"#0072b2", "#f0e442", "#d55e00"},
+		},
+	},
+}
+
+// WorldColorMap is the name of the indexed color map of the world views,
+// with the materials, then head directions, then decoding error levels
+const WorldColorMap = "XYHDEnvColors"
+
+// RegisterColorMap registers a color map of given name in AvailColorMaps,
+// interpolating the given colors in order
+func RegisterColorMap(name string, colors []string) error {
+	if len(colors) == 0 {
+		return fmt.Errorf("ColorTheme: color map %s has no colors", name)
+	}
+	cm := &giv.ColorMap{Name: name, NoColor: gist.Black}
+	cm.Colors = make([]gist.Color, len(colors))
+	for i, cs := range colors {
+		if err := cm.Colors[i].SetString(cs, nil); err != nil {
+			return fmt.Errorf("ColorTheme: color map %s: %v", name, err)
+		}
+	}
+	giv.AvailColorMaps[name] = cm
+	return nil
+}
+
+// RegisterColorThemes registers the color maps of all the ColorThemes
+func RegisterColorThemes() {
+	for _, th := range ColorThemes {
+		th.RegisterMaps()
+	}
+}
+
+// RegisterMaps registers the color maps defined by the theme
+func (th *ColorTheme) RegisterMaps() {
+	for nm, cl := range th.Maps {
+		if err := RegisterColorMap(nm, cl); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// ColorThemeNames returns the names of the ColorThemes, sorted
+func ColorThemeNames() []string {
+	nms := make([]string, 0, len(ColorThemes))
+	for nm := range ColorThemes {
+		nms = append(nms, nm)
+	}
+	sort.Strings(nms)
+	return nms
+}
+
+// OpenColorThemeFile reads a color theme from a .json file, registering its
+// color maps, and checking that the color maps it uses exist
+func OpenColorThemeFile(fnm string) (*ColorTheme, error) {
+	b, err := ioutil.ReadFile(fnm)
+	if err != nil {
+		return nil, err
+	}
+	th := &ColorTheme{}
+	if err := json.Unmarshal(b, th); err != nil {
+		return nil, err
+	}
+	th.RegisterMaps()
+	for _, cnm := range []string{th.RotMap, th.ErrMap, th.ErrGridMap} {
+		if _, ok := giv.AvailColorMaps[cnm]; !ok {
+			return nil, fmt.Errorf("ColorTheme: color map %q of %s not found", cnm, fnm)
+		}
+	}
+	if th.Name == "" {
+		th.Name = fnm
+	}
+	return th, nil
+}
+
+// ThemeFile is the theme file used if present when no Theme is set, so that
+// users can keep their own colors without changing the sim
+const ThemeFile = "theme.json"
+
+// OpenTheme returns the world color theme set by Theme: the name of one of
+// the ColorThemes, or a .json theme file -- if empty, the ThemeFile if
+// present, and Default if not found
+func (ss *Sim) OpenTheme() *ColorTheme {
+	RegisterColorThemes()
+	if ss.Theme == "" {
+		if _, err := os.Stat(ThemeFile); err == nil {
+			ss.Theme = ThemeFile
+		}
+	}
+	if strings.HasSuffix(ss.Theme, ".json") {
+		th, err := OpenColorThemeFile(ss.Theme)
+		if err == nil {
+			return th
+		}
+		log.Println(err)
+	} else if th, ok := ColorThemes[ss.Theme]; ok {
+		return th
+	} else if ss.Theme != "" {
+		log.Printf("ColorTheme: %s not found, using Default -- presets: %v\n", ss.Theme, ColorThemeNames())
+	}
+	return ColorThemes["Default"]
+}
+
+// ApplyColorTheme opens the current Theme, setting the MatColors and
+// registering the WorldColorMap of the world views -- called in
+// ConfigWorldGui, and when the Theme is changed
+func (ss *Sim) ApplyColorTheme() {
+	th := ss.OpenTheme()
+	ss.CurTheme = th
+	ss.MatColors = th.MatColors
+
+	nc := len(ss.TrainEnv.Mats)
+	nrot := ss.TrainEnv.NRotAngles
+	cm := &giv.ColorMap{Name: WorldColorMap, Indexed: true, NoColor: gist.Black}
+	cm.Colors = make([]gist.Color, nc+nrot+ErrTraceColors)
+	for i := 0; i < nc; i++ {
+		cs := "lightgrey"
+		if i < len(ss.MatColors) {
+			cs = ss.MatColors[i]
+		}
+		if err := cm.Colors[i].SetString(cs, nil); err != nil {
+			log.Printf("ColorTheme: material %d: %v\n", i, err)
+		}
+	}
+	rm := giv.AvailColorMaps[th.RotMap]
+	for i := 0; i < nrot; i++ {
+		nv := float64(i) / float64(nrot-1)
+		cm.Colors[nc+i] = rm.Map(nv) // color map of rotation
+	}
+	em := giv.AvailColorMaps[th.ErrMap]
+	for i := 0; i < ErrTraceColors; i++ {
+		cm.Colors[nc+nrot+i] = ErrTraceColor(em, i) // decoding error
+	}
+	giv.AvailColorMaps[WorldColorMap] = cm
+}
+
+// SetTheme sets the world color Theme, to the name of one of the
+// ColorThemes, or a .json theme file, and updates the world views
+func (ss *Sim) SetTheme(theme string) {
+	ss.Theme = theme
+	ss.ApplyColorTheme()
+	if ss.WorldWin == nil {
+		return
+	}
+	ss.ErrMapView.Disp.ColorMap = giv.ColorMapName(ss.CurTheme.ErrGridMap)
+	updt := ss.WorldTabs.UpdateStart()
+	ss.TraceView.UpdateSig()
+	ss.dTraceView.UpdateSig()
+	ss.ErrMapView.UpdateSig()
+	ss.WorldView.UpdateSig()
+	ss.WorldTabs.UpdateEnd(updt)
+	ss.WorldWin.Viewport.SetFullReRender()
+}
+
+// OpenColorTheme opens a world color theme from a .json file
+func (ss *Sim) OpenColorTheme(filename gi.FileName) {
+	ss.SetTheme(string(filename))
+}
+
+// SaveColorTheme saves the current world color theme to a .json file, as a
+// starting point for a custom theme, with the color maps it defines
+func (ss *Sim) SaveColorTheme(filename gi.FileName) error {
+	th := ss.CurTheme
+	if th == nil {
+		th = ss.OpenTheme()
+	}
+	jenc, err := json.MarshalIndent(th, "", " ")
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = ioutil.WriteFile(string(filename), jenc, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
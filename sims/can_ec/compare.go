@@ -0,0 +1,294 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/etview"
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+// Compare opens several completed run directories side by side, for
+// interactive comparison without external tooling: the training and testing
+// epoch logs of all the runs are overlaid in the same plots, with a line per
+// run Tag, and the ARFs saved by SaveAllARFs are shown side by side for the
+// selected ARF and runs.  Each run is tagged with the name of its directory,
+// and as for ARFDiff, each directory must hold the ARFs of a single run.
+type Compare struct {
+	Dirs   []string                               `desc:"run directories compared"`
+	Tags   []string                               `desc:"tag of each run, from the name of its directory"`
+	TrnEpc *etable.Table                          `view:"no-inline" desc:"training epoch logs of all the runs, with their Tag"`
+	TstEpc *etable.Table                          `view:"no-inline" desc:"testing epoch logs of all the runs, with their Tag"`
+	Names  []string                               `desc:"names of the ARFs found in any run"`
+	ARFs   map[string]map[string]*etensor.Float32 `view:"-" desc:"ARFs of each run, by tag then ARF name"`
+	ARF    string                                 `desc:"ARF shown side by side"`
+	Show   map[string]bool                        `desc:"runs whose ARFs are shown, by tag"`
+	ARFLay *gi.Layout                             `view:"-" desc:"layout of the side-by-side ARFs"`
+}
+
+// CompareTags returns the tag of each of dirs, the name of the directory,
+// made unique with a numeric suffix if needed
+func CompareTags(dirs []string) []string {
+	tags := make([]string, len(dirs))
+	n := make(map[string]int)
+	for i, d := range dirs {
+		tg := filepath.Base(filepath.Clean(d))
+		n[tg]++
+		if n[tg] > 1 {
+			tg = fmt.Sprintf("%s_%d", tg, n[tg])
+		}
+		tags[i] = tg
+	}
+	return tags
+}
+
+// AppendTagged appends the rows of src to dst, with the Tag column set to
+// tag -- dst is configured from the columns of src if it has none, and
+// columns of src not in dst, or of a different cell size, are skipped
+func AppendTagged(dst, src *etable.Table, tag string) {
+	if dst.NumCols() == 0 {
+		sch := etable.Schema{{"Tag", etensor.STRING, nil, nil}}
+		for _, cl := range src.Schema() {
+			if cl.Name != "Tag" {
+				sch = append(sch, cl)
+			}
+		}
+		dst.SetFromSchema(sch, 0)
+		dst.SetMetaData("read-only", "true")
+		dst.SetMetaData("precision", strconv.Itoa(LogPrec))
+	}
+	if src.Rows == 0 {
+		return
+	}
+	st := dst.Rows
+	dst.SetNumRows(st + src.Rows)
+	for ri := 0; ri < src.Rows; ri++ {
+		dst.SetCellString("Tag", st+ri, tag)
+	}
+	for ci, cnm := range dst.ColNames {
+		si := src.ColIdx(cnm)
+		if cnm == "Tag" || si < 0 {
+			continue
+		}
+		sc, dc := src.Cols[si], dst.Cols[ci]
+		n := sc.Len() / src.Rows
+		if n != dc.Len()/dst.Rows {
+			log.Printf("Compare: column %s of %s has a different shape, skipped\n", cnm, tag)
+			continue
+		}
+		str := dc.DataType() == etensor.STRING
+		for i := 0; i < sc.Len(); i++ {
+			if str {
+				dc.SetString1D(st*n+i, sc.StringVal1D(i))
+			} else {
+				dc.SetFloat1D(st*n+i, sc.FloatVal1D(i))
+			}
+		}
+	}
+}
+
+// OpenCompareLogs appends the epoch logs of given name (e.g., trn_epc) in
+// dir, plain or gzipped, to dt, tagged with tag, returning the number found
+func (ss *Sim) OpenCompareLogs(dt *etable.Table, dir, lognm, tag string) int {
+	var fns []string
+	for _, sfx := range []string{".tsv", ".tsv.gz"} {
+		fs, err := filepath.Glob(filepath.Join(dir, "*_"+lognm+sfx))
+		if err != nil {
+			log.Println(err)
+		}
+		fns = append(fns, fs...)
+	}
+	sort.Strings(fns)
+	for _, fn := range fns {
+		lt, err := ss.LogView.Open(fn)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		AppendTagged(dt, lt, tag)
+	}
+	return len(fns)
+}
+
+// OpenCompare opens the epoch logs and ARFs of the runs saved in dirs.
+// Logs and ARFs missing from a run are skipped with a message, and it is
+// an error if no run has any.
+func (ss *Sim) OpenCompare(dirs []string) (*Compare, error) {
+	cmp := &Compare{Dirs: dirs, Tags: CompareTags(dirs), TrnEpc: &etable.Table{}, TstEpc: &etable.Table{}}
+	cmp.ARFs = make(map[string]map[string]*etensor.Float32)
+	cmp.Show = make(map[string]bool)
+	cmp.TrnEpc.SetMetaData("name", "CompareTrnEpc")
+	cmp.TstEpc.SetMetaData("name", "CompareTstEpc")
+	if len(ss.ARFs.RFs) == 0 {
+		ss.UpdtARFs() // configures the ARFs, for their names and shapes
+	}
+	has := make(map[string]bool)
+	nfound := 0
+	for i, dir := range cmp.Dirs {
+		tag := cmp.Tags[i]
+		cmp.Show[tag] = true
+		n := ss.OpenCompareLogs(cmp.TrnEpc, dir, "trn_epc", tag)
+		n += ss.OpenCompareLogs(cmp.TstEpc, dir, "tst_epc", tag)
+		if n == 0 {
+			log.Printf("Compare: no epoch logs in: %s\n", dir)
+		}
+		arfs := make(map[string]*etensor.Float32)
+		for _, paf := range ss.ARFs.RFs {
+			fn, err := ARFFile(dir, paf.Name)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			tsr := &etensor.Float32{}
+			tsr.CopyShapeFrom(&paf.NormRF)
+			if err := etensor.OpenCSV(tsr, gi.FileName(fn), '\t'); err != nil {
+				log.Println(err)
+				continue
+			}
+			ss.SetAFMetaData(tsr)
+			arfs[paf.Name] = tsr
+			has[paf.Name] = true
+		}
+		cmp.ARFs[tag] = arfs
+		nfound += n + len(arfs)
+	}
+	if nfound == 0 {
+		return nil, fmt.Errorf("Compare: no epoch logs or ARFs found in: %v", dirs)
+	}
+	for _, paf := range ss.ARFs.RFs {
+		if has[paf.Name] {
+			cmp.Names = append(cmp.Names, paf.Name)
+		}
+	}
+	if len(cmp.Names) > 0 {
+		cmp.ARF = cmp.Names[0]
+	}
+	return cmp, nil
+}
+
+// UpdtCompareARFs shows the selected ARF of the selected runs side by side
+func (cmp *Compare) UpdtCompareARFs() {
+	lay := cmp.ARFLay
+	if lay == nil {
+		return
+	}
+	updt := lay.UpdateStart()
+	lay.DeleteChildren(ki.DestroyKids)
+	for _, tag := range cmp.Tags {
+		if !cmp.Show[tag] {
+			continue
+		}
+		rl := gi.AddNewLayout(lay, tag, gi.LayoutVert)
+		rl.SetStretchMax()
+		tsr, ok := cmp.ARFs[tag][cmp.ARF]
+		if !ok {
+			gi.AddNewLabel(rl, "lbl", tag+": no "+cmp.ARF)
+			continue
+		}
+		gi.AddNewLabel(rl, "lbl", tag+":")
+		tg := &etview.TensorGrid{}
+		tg.SetName(tag + "ARF")
+		rl.AddChild(tg)
+		tg.SetStretchMax()
+		tg.SetTensor(tsr)
+	}
+	lay.UpdateEnd(updt)
+}
+
+// ConfigCompareGui configures the window of the Compare mode, with the
+// overlaid epoch plots and the side-by-side ARFs, selected in the toolbar
+func (ss *Sim) ConfigCompareGui(cmp *Compare) *gi.Window {
+	width := 1600
+	height := 1200
+
+	gi.SetAppName("can_ec")
+	gi.SetAppAbout(`Compare saved can_ec runs`)
+
+	win := gi.NewMainWindow("can_ec", "Compare Runs", width, height)
+	ss.Win = win
+
+	vp := win.WinViewport2D()
+	updt := vp.UpdateStart()
+
+	mfr := win.SetMainFrame()
+
+	tbar := gi.AddNewToolBar(mfr, "tbar")
+	tbar.SetStretchMaxWidth()
+
+	tv := gi.AddNewTabView(mfr, "tv")
+	tv.SetStretchMax()
+
+	plt := tv.AddNewTab(eplot.KiT_Plot2D, "TrnEpcPlot").(*eplot.Plot2D)
+	ss.ConfigTrnEpcPlot(plt, cmp.TrnEpc)
+	plt.Params.Title = "CAN_EC Compare Epoch Plot"
+	plt.Params.LegendCol = "Tag"
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "TstEpcPlot").(*eplot.Plot2D)
+	ss.ConfigTstEpcPlot(plt, cmp.TstEpc)
+	plt.Params.Title = "CAN_EC Compare Testing Epoch Plot"
+	plt.Params.LegendCol = "Tag"
+
+	arl := tv.AddNewTab(gi.KiT_Layout, "ARFs").(*gi.Layout)
+	arl.Lay = gi.LayoutHoriz
+	arl.SetStretchMax()
+	cmp.ARFLay = arl
+	cmp.UpdtCompareARFs()
+
+	gi.AddNewLabel(tbar, "arf-lbl", "ARF:")
+	cb := gi.AddNewComboBox(tbar, "arf")
+	cb.ItemsFromStringList(cmp.Names, false, 30)
+	if len(cmp.Names) > 0 {
+		cb.SetCurVal(cmp.ARF)
+	}
+	cb.ComboSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		cmp.ARF = cb.CurVal.(string)
+		cmp.UpdtCompareARFs()
+		vp.SetFullReRender()
+	})
+	tbar.AddSeparator("runsep")
+	for _, tag := range cmp.Tags {
+		tag := tag
+		chk := gi.AddNewCheckBox(tbar, tag)
+		chk.SetChecked(cmp.Show[tag])
+		chk.SetText(tag)
+		chk.Tooltip = "show the ARFs of the run in: " + tag
+		chk.ButtonSig.Connect(win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+			if sig == int64(gi.ButtonToggled) {
+				cmp.Show[tag] = send.(*gi.CheckBox).IsChecked()
+				cmp.UpdtCompareARFs()
+				vp.SetFullReRender()
+			}
+		})
+	}
+
+	vp.UpdateEndNoSig(updt)
+
+	// main menu
+	appnm := gi.AppName()
+	mmen := win.MainMenu
+	mmen.ConfigMenus([]string{appnm, "File", "Edit", "Window"})
+
+	amen := win.MainMenu.ChildByName(appnm, 0).(*gi.Action)
+	amen.Menu.AddAppMenu(win)
+
+	emen := win.MainMenu.ChildByName("Edit", 1).(*gi.Action)
+	emen.Menu.AddCopyCutPaste(win)
+
+	win.SetCloseCleanFunc(func(w *gi.Window) {
+		go gi.Quit() // once main window is closed, quit
+	})
+
+	win.MainMenuUpdated()
+	return win
+}
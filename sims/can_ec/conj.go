@@ -0,0 +1,196 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// ConjParams configures the optional Conj layer of conjunctive grid x
+// head-direction cells, as in deeper layers of MEC, and the classification of
+// units by their position and heading tuning.  Conj has the same pools as EC,
+// each receiving from the corresponding EC pool (of every EC module) and from
+// all of Orientation, with pool-level inhibition, so units within a pool share
+// a spatial input and compete on heading.
+type ConjParams struct {
+	On          bool       `desc:"include the Conj layer"`
+	PoolSize    evec.Vec2i `desc:"number of units in each Conj pool (one pool per EC pool)"`
+	SpatInfoThr float64    `def:"0.5" desc:"spatial information, in bits, above which a unit is position tuned"`
+	MVLThr      float64    `def:"0.25" desc:"head-direction mean vector length above which a unit is heading tuned"`
+}
+
+// Defaults sets default params
+func (cp *ConjParams) Defaults() {
+	cp.PoolSize.Set(4, 4)
+	cp.SpatInfoThr = 0.5
+	cp.MVLThr = 0.25
+}
+
+func init() {
+	AddAnalysis(&Analysis{Name: "conjtune", Label: "Conj Tuning", Desc: "classify ARF layer units as position, head-direction or conjunctive position x heading tuned, from the spatial information of their Pos and the mean vector length of their Ang activation rfs.", Needs: []string{AnalysisARFs}, Run: func(ss *Sim) []AnalysisOut {
+		dt := ss.AnalyzeConjTuning()
+		return []AnalysisOut{{Name: "conjtune", Table: dt}, {Name: "conjfrac", Table: ConjFracs(dt)}}
+	}})
+}
+
+// SpatialInfo returns the Skaggs spatial information, in bits, of a unit with
+// given mean rates at each location, and occupancy of each location
+func SpatialInfo(rates, occ []float64) float64 {
+	var sumocc, mrate float64
+	for i, o := range occ {
+		sumocc += o
+		mrate += o * rates[i]
+	}
+	if sumocc == 0 || mrate <= 0 {
+		return 0
+	}
+	mrate /= sumocc
+	info := 0.0
+	for i, o := range occ {
+		if o == 0 || rates[i] <= 0 {
+			continue
+		}
+		rr := rates[i] / mrate
+		info += (o / sumocc) * rr * math.Log2(rr)
+	}
+	return info
+}
+
+// ConjClass returns the tuning class of a unit: Conj if both position and
+// heading tuned, Pos or HD if only one, and None otherwise
+func (cp *ConjParams) ConjClass(spatInfo, mvl float64) string {
+	pos := spatInfo >= cp.SpatInfoThr
+	hd := mvl >= cp.MVLThr
+	switch {
+	case pos && hd:
+		return "Conj"
+	case pos:
+		return "Pos"
+	case hd:
+		return "HD"
+	}
+	return "None"
+}
+
+// AnalyzeConjTuning computes the spatial information of the Pos activation
+// rf, and the head-direction tuning of the Ang activation rf, of each unit in
+// the ARFLayers, and classifies the unit by ConjClass
+func (ss *Sim) AnalyzeConjTuning() *etable.Table {
+	cp := &ss.Conj
+	dt := &etable.Table{}
+	ConfigConjTuning(dt)
+	ss.AvgNormARFs(nil)
+	angs := make([]float64, ss.TrainEnv.NRotAngles)
+	for i := range angs {
+		angs[i] = float64(i * ss.TrainEnv.AngInc)
+	}
+	wts := make([]float64, len(angs))
+	for _, lnm := range ss.ARFLayers {
+		paf, err := ss.ARFs.RFByNameTry(lnm + "_Pos")
+		if err != nil {
+			continue
+		}
+		aaf, err := ss.ARFs.RFByNameTry(lnm + "_Ang")
+		if err != nil {
+			continue
+		}
+		np := len(paf.SumSrc.Values)
+		occ := make([]float64, np)
+		for pi := range occ {
+			occ[pi] = float64(paf.SumSrc.Values[pi])
+		}
+		rates := make([]float64, np)
+		nb := len(angs)
+		nu := len(aaf.RF.Values) / nb
+		for ui := 0; ui < nu; ui++ {
+			for pi := range rates {
+				rates[pi] = float64(paf.RF.Values[ui*np+pi])
+			}
+			for bi := range wts {
+				wts[bi] = float64(aaf.RF.Values[ui*nb+bi])
+			}
+			si := SpatialInfo(rates, occ)
+			pref, mvl := CircMean(angs, wts)
+			row := dt.Rows
+			dt.SetNumRows(row + 1)
+			dt.SetCellString("Layer", row, lnm)
+			dt.SetCellFloat("Unit", row, float64(ui))
+			dt.SetCellFloat("SpatInfo", row, si)
+			dt.SetCellFloat("PrefDir", row, pref)
+			dt.SetCellFloat("MVL", row, mvl)
+			dt.SetCellString("Class", row, cp.ConjClass(si, mvl))
+		}
+	}
+	return dt
+}
+
+func ConfigConjTuning(dt *etable.Table) {
+	dt.SetMetaData("name", "ConjTuning")
+	dt.SetMetaData("desc", "Position and head-direction tuning of units, from Pos and Ang activation-based receptive fields")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Layer", etensor.STRING, nil, nil},
+		{"Unit", etensor.INT64, nil, nil},
+		{"SpatInfo", etensor.FLOAT64, nil, nil},
+		{"PrefDir", etensor.FLOAT64, nil, nil},
+		{"MVL", etensor.FLOAT64, nil, nil},
+		{"Class", etensor.STRING, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+// ConjFracs returns the fraction of units in each tuning class, per layer,
+// from the AnalyzeConjTuning table
+func ConjFracs(tt *etable.Table) *etable.Table {
+	return ClassFracs(tt, "ConjFracs", []string{"Pos", "HD", "Conj", "None"})
+}
+
+// ClassFracs returns a table named nm of the fraction of units in each of
+// given classes, per layer, from a table of units with Layer and Class columns
+func ClassFracs(tt *etable.Table, nm string, classes []string) *etable.Table {
+	dt := &etable.Table{}
+	dt.SetMetaData("name", nm)
+	dt.SetMetaData("desc", "Fraction of units in each tuning class per layer")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Layer", etensor.STRING, nil, nil},
+		{"NUnits", etensor.INT64, nil, nil},
+	}
+	for _, cl := range classes {
+		sch = append(sch, etable.Column{cl, etensor.FLOAT64, nil, nil})
+	}
+	dt.SetFromSchema(sch, 0)
+
+	lrow := make(map[string]int)
+	for i := 0; i < tt.Rows; i++ {
+		lnm := tt.CellString("Layer", i)
+		row, has := lrow[lnm]
+		if !has {
+			row = dt.Rows
+			dt.SetNumRows(row + 1)
+			dt.SetCellString("Layer", row, lnm)
+			lrow[lnm] = row
+		}
+		dt.SetCellFloat("NUnits", row, dt.CellFloat("NUnits", row)+1)
+		cl := tt.CellString("Class", i)
+		dt.SetCellFloat(cl, row, dt.CellFloat(cl, row)+1)
+	}
+	for row := 0; row < dt.Rows; row++ {
+		n := dt.CellFloat("NUnits", row)
+		for _, cl := range classes {
+			dt.SetCellFloat(cl, row, dt.CellFloat(cl, row)/n)
+		}
+	}
+	return dt
+}
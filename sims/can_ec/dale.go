@@ -0,0 +1,32 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/emergent/emer"
+	"github.com/emer/emergent/prjn"
+	"github.com/emer/leabra/leabra"
+)
+
+// ECInhName returns the name of the inhibitory interneuron layer of given EC
+// module layer, e.g., ECInh for EC
+func ECInhName(ecnm string) string {
+	return ecnm + "Inh"
+}
+
+// ConfigECInh adds the inhibitory interneuron layer of given EC module, for
+// EcParams.Dale: a population of the same shape as the EC, driven one-to-one
+// by its excitatory units, which then sends the lateral inhibition back to
+// the EC in place of the EC units themselves, so that each unit is either
+// excitatory or inhibitory, but not both, as per Dale's law.  The sign of
+// each prjn is fixed by its type, and weights are positive, so learning can
+// not change it either.
+func ConfigECInh(net *leabra.Network, ec emer.Layer) emer.Layer {
+	inl := net.AddLayer(ECInhName(ec.Name()), ec.Shape().Shp, emer.Hidden)
+	inl.SetClass("ECInh")
+	pj := net.ConnectLayers(ec, inl, prjn.NewOneToOne(), emer.Forward)
+	pj.SetClass("ECToInh")
+	return inl
+}
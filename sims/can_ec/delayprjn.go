@@ -0,0 +1,76 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/ki/kit"
+)
+
+// DelayPrjn is a leabra.Prjn with a conduction delay: the net input deltas sent
+// by each sending neuron are buffered and delivered to the receivers Delay cycles later.
+// Delay = 0 is identical to a standard leabra.Prjn.  Delay can be set via params
+// using "Prjn.Delay".
+type DelayPrjn struct {
+	leabra.Prjn
+	Delay    int         `min:"0" desc:"conduction delay in cycles between sending activation and delivering it to receivers"`
+	DelayBuf [][]float32 `view:"-" desc:"ring buffer of sent deltas per sending neuron, for each of Delay+1 cycles"`
+	DelayIdx int         `view:"-" desc:"index into DelayBuf of the slot to be delivered in the current cycle"`
+}
+
+var KiT_DelayPrjn = kit.Types.AddType(&DelayPrjn{}, nil)
+
+// AllocDelayBuf allocates the delay ring buffer if the Delay or sending layer size has changed
+func (pj *DelayPrjn) AllocDelayBuf() {
+	nsend := len(pj.SConN)
+	if len(pj.DelayBuf) == pj.Delay+1 && (len(pj.DelayBuf) == 0 || len(pj.DelayBuf[0]) == nsend) {
+		return
+	}
+	pj.DelayBuf = make([][]float32, pj.Delay+1)
+	for i := range pj.DelayBuf {
+		pj.DelayBuf[i] = make([]float32, nsend)
+	}
+	pj.DelayIdx = 0
+}
+
+// SendGDelta buffers the delta-activation from sending neuron index si,
+// to be delivered to receivers after Delay cycles
+func (pj *DelayPrjn) SendGDelta(si int, delta float32) {
+	if pj.Delay <= 0 {
+		pj.Prjn.SendGDelta(si, delta)
+		return
+	}
+	pj.AllocDelayBuf()
+	di := (pj.DelayIdx + pj.Delay) % len(pj.DelayBuf)
+	pj.DelayBuf[di][si] += delta
+}
+
+// RecvGInc delivers the deltas due in the current cycle, and then increments
+// the receiver's GeInc or GiInc as usual
+func (pj *DelayPrjn) RecvGInc() {
+	if pj.Delay > 0 {
+		pj.AllocDelayBuf()
+		buf := pj.DelayBuf[pj.DelayIdx]
+		for si, delta := range buf {
+			if delta != 0 {
+				pj.Prjn.SendGDelta(si, delta)
+				buf[si] = 0
+			}
+		}
+		pj.DelayIdx = (pj.DelayIdx + 1) % len(pj.DelayBuf)
+	}
+	pj.Prjn.RecvGInc()
+}
+
+// InitGInc initializes the per-projection synaptic conductance increments,
+// including any deltas still in transit
+func (pj *DelayPrjn) InitGInc() {
+	pj.Prjn.InitGInc()
+	for _, buf := range pj.DelayBuf {
+		for si := range buf {
+			buf[si] = 0
+		}
+	}
+}
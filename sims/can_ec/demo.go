@@ -0,0 +1,79 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/emer/leabra/leabra"
+)
+
+// Demo runs training in soft real time, for live demos of the agent
+// navigating: training trials are paced to Rate trials per second of
+// wall-clock time, with the views updated at ViewUpdt, so the behavior
+// neither crawls at Cycle updates nor blurs past at full speed.  It is
+// soft real time: a trial that runs late delays the ones after it, rather
+// than making them catch up.  Rate is set from the speed slider in the
+// toolbar, through SetRate, and can be changed while the demo runs.
+type Demo struct {
+	Rate     float32           `def:"10" min:"0.5" max:"50" desc:"training trials per second of wall-clock time"`
+	ViewUpdt leabra.TimeScales `def:"AlphaCycle" desc:"time scale of the view updates during the demo -- AlphaCycle updates once per trial, Quarter animates within the trial"`
+
+	NTrials int `inactive:"+" desc:"number of trials run in the current or last demo"`
+	NLate   int `inactive:"+" desc:"number of those trials that took longer than 1 / Rate to run, so the demo ran slower than Rate"`
+
+	mu sync.Mutex // guards Rate, set by the GUI while the demo runs on the training goroutine
+}
+
+// Defaults sets default params
+func (dm *Demo) Defaults() {
+	dm.Rate = 10
+	dm.ViewUpdt = leabra.AlphaCycle
+}
+
+// SetRate sets the Rate -- safe to call from the GUI while the demo runs
+func (dm *Demo) SetRate(rate float32) {
+	dm.mu.Lock()
+	dm.Rate = rate
+	dm.mu.Unlock()
+}
+
+// Period returns the wall-clock duration of a trial at Rate
+func (dm *Demo) Period() time.Duration {
+	dm.mu.Lock()
+	rate := dm.Rate
+	dm.mu.Unlock()
+	if rate < 0.5 {
+		rate = 0.5
+	}
+	return time.Duration(float64(time.Second) / float64(rate))
+}
+
+// RunDemo runs training trials paced at Demo.Rate until stopped or all the
+// runs are done, with the views updated at Demo.ViewUpdt
+func (ss *Sim) RunDemo() {
+	dm := &ss.Demo
+	dm.NTrials = 0
+	dm.NLate = 0
+	updt, view := ss.TrainUpdt, ss.ViewOn
+	ss.TrainUpdt = dm.ViewUpdt
+	ss.ViewOn = true
+	ss.StopNow = false
+	next := time.Now()
+	for !ss.StopNow {
+		ss.TrainStep(StepTrial)
+		dm.NTrials++
+		next = next.Add(dm.Period())
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+		} else {
+			dm.NLate++
+			next = time.Now() // soft: no catching up
+		}
+	}
+	ss.TrainUpdt, ss.ViewOn = updt, view
+	ss.Stopped()
+}
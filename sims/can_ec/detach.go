@@ -0,0 +1,218 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+// GuiDetach detaches the GUI from a running training session, which then
+// continues headless, without any view updates, and re-attaches it later.
+// The training goroutine never touches the windows directly: it only goes
+// through the GUI pointers of the Sim, which are all checked for nil, so
+// detaching clears them before closing the main and World windows, leaving
+// only a small window with the progress of training and a Re-attach button,
+// which configures the windows anew on the current state of the sim.  The
+// pointers are only changed with the GuiMu held, which the training
+// goroutine holds while it updates the views, so they never change in the
+// middle of an update -- in a separate goroutine, as the view updates wait
+// on the GUI.
+type GuiDetach struct {
+	Detached bool       `desc:"the GUI is detached, and training runs headless"`
+	Win      *gi.Window `desc:"the small window shown while detached"`
+	Status   *gi.Label  `desc:"progress of training shown while detached"`
+}
+
+// ClearGui clears all the GUI pointers of the Sim, so the training goroutine
+// no longer updates any of the views -- must be called with the GuiMu held
+func (ss *Sim) ClearGui() {
+	ss.Win = nil
+	ss.NetView = nil
+	ss.ToolBar = nil
+	ss.TabView = nil
+	ss.ClearWorldGui()
+	ss.CurImgGrid = nil
+	ss.WtsGrid = nil
+	ss.TrnTrlPlot = nil
+	ss.TrnEpcPlot = nil
+	ss.TstEpcPlot = nil
+	ss.TstTrlPlot = nil
+	ss.RunPlot = nil
+	ss.LapPlot = nil
+	ss.ChoicePlot = nil
+	ss.SRPlot = nil
+	ss.SelfLocPlot = nil
+	ss.VestibPlot = nil
+	ss.ActPlot = nil
+	ss.OcclPlot = nil
+	ss.AdaptPlot = nil
+	ss.ArenaPlot = nil
+	ss.UnitClassPlot = nil
+	ss.UnitGroupPlot = nil
+	ss.WtChgPlot = nil
+	ss.TaskPlot = nil
+	ss.LatDiagPlot = nil
+	ss.SimMatView = nil
+	ss.PrjnView = nil
+	ss.PlotUpdt.Dirty = nil
+}
+
+// ClearWorldGui clears the GUI pointers of the World window -- called when
+// it is closed, as UpdateWorldGui is skipped without a WorldWin, with the
+// GuiMu held
+func (ss *Sim) ClearWorldGui() {
+	ss.WorldWin = nil
+	ss.WorldTabs = nil
+	ss.TraceView = nil
+	ss.dTraceView = nil
+	ss.ErrMapView = nil
+	ss.WorldView = nil
+}
+
+// OpenWorldGui opens the World window again, if it was closed
+func (ss *Sim) OpenWorldGui() {
+	if ss.WorldWin != nil {
+		return
+	}
+	fwin := ss.ConfigWorldGui()
+	fwin.GoStartEventLoop()
+}
+
+// DetachGui closes the main and World windows, and continues headless,
+// showing the small detached window instead -- must be called in the GUI.
+// The windows are swapped once any view update in progress is done.
+func (ss *Sim) DetachGui() {
+	dt := &ss.Detach
+	if dt.Detached {
+		return
+	}
+	dt.Detached = true
+	go func() {
+		ss.GuiMu.Lock()
+		win, fwin := ss.Win, ss.WorldWin
+		ss.ClearGui()
+		dwin := ss.ConfigDetachGui()
+		ss.GuiMu.Unlock()
+		dwin.GoStartEventLoop()
+		if fwin != nil {
+			fwin.Close()
+		}
+		if win != nil {
+			win.Close()
+		}
+	}()
+}
+
+// AttachGui configures the main and World windows again, and closes the
+// detached window -- must be called in the GUI.  The windows are swapped
+// once any update of the detached status in progress is done.
+func (ss *Sim) AttachGui() {
+	dt := &ss.Detach
+	if !dt.Detached {
+		return
+	}
+	dt.Detached = false
+	go func() {
+		ss.GuiMu.Lock()
+		dwin := dt.Win
+		win := ss.ConfigGui()
+		ss.OpenWorldGui()
+		dt.Win = nil
+		dt.Status = nil
+		ss.GuiMu.Unlock()
+		win.GoStartEventLoop()
+		if dwin != nil {
+			dwin.Close()
+		}
+	}()
+}
+
+// DetachStatus returns the progress of training shown while detached
+func (ss *Sim) DetachStatus() string {
+	st := "stopped"
+	if ss.IsRunning {
+		st = "running headless"
+	}
+	return fmt.Sprintf("Training %s -- Run: %d  Epoch: %d  Trial: %d", st, ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Trial.Cur)
+}
+
+// UpdtDetachStatus updates the progress of training in the detached window,
+// if detached -- called at the end of each training epoch, and in Stopped
+func (ss *Sim) UpdtDetachStatus() {
+	ss.GuiMu.Lock()
+	defer ss.GuiMu.Unlock()
+	lbl := ss.Detach.Status
+	if lbl == nil {
+		return
+	}
+	updt := lbl.UpdateStart()
+	lbl.SetText(ss.DetachStatus())
+	lbl.UpdateEnd(updt)
+}
+
+// ConfigDetachGui configures the small window shown while the GUI is
+// detached, with the progress of training and Re-attach and Stop actions
+func (ss *Sim) ConfigDetachGui() *gi.Window {
+	width := 600
+	height := 120
+
+	win := gi.NewMainWindow("can_ec-detached", "can_ec (detached)", width, height)
+	ss.Detach.Win = win
+
+	vp := win.WinViewport2D()
+	updt := vp.UpdateStart()
+
+	mfr := win.SetMainFrame()
+
+	tbar := gi.AddNewToolBar(mfr, "tbar")
+	tbar.SetStretchMaxWidth()
+
+	lbl := gi.AddNewLabel(mfr, "status", ss.DetachStatus())
+	lbl.SetStretchMaxWidth()
+	ss.Detach.Status = lbl
+
+	tbar.AddAction(gi.ActOpts{Label: "Re-attach", Icon: "update", Tooltip: "Re-opens the main and World windows on the current state of the sim, with training continuing."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			ss.AttachGui()
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Stop", Icon: "stop", Tooltip: "Interrupts training at the end of the current trial."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			ss.Stop()
+		})
+
+	vp.UpdateEndNoSig(updt)
+
+	inQuitPrompt := false
+	quitPrompt := func() {
+		if inQuitPrompt {
+			return
+		}
+		inQuitPrompt = true
+		gi.PromptDialog(vp, gi.DlgOpts{Title: "Really Quit?",
+			Prompt: "Are you <i>sure</i> you want to quit, interrupting training and losing all unsaved params, weights, logs, etc?  Re-attach to keep training with the GUI"}, gi.AddOk, gi.AddCancel,
+			win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+				if sig == int64(gi.DialogAccepted) {
+					gi.Quit()
+				} else {
+					inQuitPrompt = false
+				}
+			})
+	}
+	gi.SetQuitReqFunc(quitPrompt) // the one of ConfigGui prompts in its closed window
+	win.SetCloseReqFunc(func(w *gi.Window) {
+		quitPrompt()
+	})
+
+	win.SetCloseCleanFunc(func(w *gi.Window) {
+		if ss.Detach.Win == w {
+			go gi.Quit() // closed while still detached
+		}
+	})
+	return win
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/emer/etable/agg"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// ECShape returns the shape of each EC sheet: ECSize pools of 2x2 units, or
+// 2*ECSize units per side with EC2D, so both have the same number of units
+func (ec *EcParams) ECShape() []int {
+	if ec.EC2D {
+		return []int{2 * ec.ECSize.Y, 2 * ec.ECSize.X}
+	}
+	return []int{ec.ECSize.Y, ec.ECSize.X, 2, 2}
+}
+
+// PoolSide returns the size, in grid positions of the EC sheet, of the side
+// of a 4D EC pool: 2 with EC2D, where units take the place of pools, else 1
+func (ec *EcParams) PoolSide() int {
+	if ec.EC2D {
+		return 2
+	}
+	return 1
+}
+
+// Arch returns the name of the EC architecture: 2D or 4D
+func (ec *EcParams) Arch() string {
+	if ec.EC2D {
+		return "2D"
+	}
+	return "4D"
+}
+
+// RunECCompare runs the Bench protocol for both the 4D and the 2D EC, each in
+// its own Sim with the same args, and saves the Bench stats of each run, and a
+// side-by-side table of their mean and standard deviation across seeds for
+// each architecture, with the difference of the means (2D - 4D)
+func (ss *Sim) RunECCompare() {
+	bn := &ss.Bench
+	wp, err := WorldPresetFromString(bn.World)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	archs := []bool{false, true}
+	rdt := &etable.Table{}
+	ss.ConfigECCompareRunLog(rdt)
+	for _, ec2d := range archs {
+		sm := &Sim{}
+		sm.New()
+		sm.CopyArgs(ss)
+		sm.NoGui = true
+		sm.SaveWts = false
+		sm.SaveARFs = false
+		sm.TrainEnv.Preset = wp
+		sm.Entorhinal.EC2D = ec2d
+		sm.Config()
+		arch := sm.Entorhinal.Arch()
+		for _, seed := range bn.Seeds {
+			fmt.Printf("ECCompare: EC: %s  world: %s  seed: %d  epochs: %d\n", arch, bn.World, seed, bn.Epochs)
+			sm.RndSeed = seed
+			sm.Init()
+			sm.MaxEpcs = bn.Epochs
+			stm := time.Now()
+			for !sm.StopNow && !sm.NeedsNewRun {
+				sm.TrainTrial()
+			}
+			secs := time.Since(stm).Seconds()
+
+			row := rdt.Rows
+			rdt.SetNumRows(row + 1)
+			rdt.SetCellString("Arch", row, arch)
+			rdt.SetCellFloat("Seed", row, float64(seed))
+			rdt.SetCellFloat("NUnits", row, float64(sm.Net.LayerByName("EC").Shape().Len()))
+			rdt.SetCellFloat("NSyns", row, float64(NSyns(sm.Net)))
+			for _, st := range bn.Stats {
+				rdt.SetCellFloat(st, row, sm.BenchAvg(st))
+			}
+			rdt.SetCellFloat("SecsPerEpc", row, secs/float64(bn.Epochs))
+		}
+	}
+
+	cdt := &etable.Table{}
+	ss.ConfigECCompareLog(cdt)
+	stats := append([]string{"NUnits", "NSyns"}, bn.Stats...)
+	stats = append(stats, "SecsPerEpc")
+	cdt.SetNumRows(len(stats))
+	for row, st := range stats {
+		cdt.SetCellString("Stat", row, st)
+		var mns [2]float64
+		for ai, ec2d := range archs {
+			arch := (&EcParams{EC2D: ec2d}).Arch()
+			ix := etable.NewIdxView(rdt)
+			ix.Filter(func(et *etable.Table, r int) bool {
+				return et.CellString("Arch", r) == arch
+			})
+			mns[ai] = agg.Agg(ix, st, agg.AggMean)[0]
+			cdt.SetCellFloat(arch, row, mns[ai])
+			cdt.SetCellFloat(arch+"_SD", row, agg.Agg(ix, st, agg.AggStd)[0])
+		}
+		cdt.SetCellFloat("Diff", row, mns[1]-mns[0])
+	}
+	ss.SaveECCompare(rdt, ss.LogFileName("eccompare_runs"))
+	ss.SaveECCompare(cdt, ss.LogFileName("eccompare"))
+	for row := 0; row < cdt.Rows; row++ {
+		fmt.Printf("%-12s  4D: %-10.4g  2D: %-10.4g  Diff: %.4g\n", cdt.CellString("Stat", row), cdt.CellFloat("4D", row), cdt.CellFloat("2D", row), cdt.CellFloat("Diff", row))
+	}
+}
+
+// SaveECCompare saves given ECCompare table to file
+func (ss *Sim) SaveECCompare(dt *etable.Table, fnm string) {
+	f, err := os.Create(fnm)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+	dt.WriteCSVHeaders(f, etable.Tab)
+	for row := 0; row < dt.Rows; row++ {
+		dt.WriteCSVRow(f, row, etable.Tab)
+	}
+	fmt.Printf("ECCompare: saved: %s\n", fnm)
+}
+
+func (ss *Sim) ConfigECCompareRunLog(dt *etable.Table) {
+	dt.SetMetaData("name", "ECCompareRunLog")
+	dt.SetMetaData("desc", "Bench stats of each run of the 4D vs. 2D EC comparison")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Arch", etensor.STRING, nil, nil},
+		{"Seed", etensor.INT64, nil, nil},
+		{"NUnits", etensor.INT64, nil, nil},
+		{"NSyns", etensor.INT64, nil, nil},
+	}
+	for _, st := range ss.Bench.Stats {
+		sch = append(sch, etable.Column{st, etensor.FLOAT64, nil, nil})
+	}
+	sch = append(sch, etable.Column{"SecsPerEpc", etensor.FLOAT64, nil, nil})
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigECCompareLog(dt *etable.Table) {
+	dt.SetMetaData("name", "ECCompareLog")
+	dt.SetMetaData("desc", "Side-by-side Bench stats of the 4D vs. 2D EC: mean and SD across seeds")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Stat", etensor.STRING, nil, nil},
+		{"4D", etensor.FLOAT64, nil, nil},
+		{"4D_SD", etensor.FLOAT64, nil, nil},
+		{"2D", etensor.FLOAT64, nil, nil},
+		{"2D_SD", etensor.FLOAT64, nil, nil},
+		{"Diff", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
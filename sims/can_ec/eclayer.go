@@ -0,0 +1,96 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/emergent/erand"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/ki/kit"
+)
+
+// ECLayer is a leabra.Layer with heterogeneous conductance time constants
+// (Act.Dt.GTau) across its units or pools, as grid modules in biology differ in
+// timescale.  At InitWts, an offset to the layer Act.Dt.GTau is drawn for each
+// unit, or each pool if GTauPool, from the GTauVar distribution -- so the
+// layer GTau param still sets the mean, and offsets are redrawn each run.
+// With GTauVar.Var = 0 and Mean = 0 it is identical to a standard leabra.Layer.
+type ECLayer struct {
+	leabra.Layer
+	GTauVar  erand.RndParams `desc:"distribution of the offsets added to Act.Dt.GTau for each unit or pool -- Var = 0 and Mean = 0 for homogeneous time constants"`
+	GTauPool bool            `desc:"draw one GTau offset per pool, shared by its units, instead of per unit"`
+	GTauOffs []float32       `view:"-" desc:"per-unit offsets added to Act.Dt.GTau, drawn at InitWts -- nil if homogeneous"`
+}
+
+var KiT_ECLayer = kit.Types.AddType(&ECLayer{}, leabra.LayerProps)
+
+// GTauHetero returns true if time constants vary across units
+func (ly *ECLayer) GTauHetero() bool {
+	return ly.GTauVar.Var != 0 || ly.GTauVar.Mean != 0
+}
+
+// InitGTaus draws the per-unit GTau offsets from GTauVar
+func (ly *ECLayer) InitGTaus() {
+	if !ly.GTauHetero() {
+		ly.GTauOffs = nil
+		return
+	}
+	nn := len(ly.Neurons)
+	if len(ly.GTauOffs) != nn {
+		ly.GTauOffs = make([]float32, nn)
+	}
+	if !ly.GTauPool || ly.Is2D() {
+		for ni := range ly.GTauOffs {
+			ly.GTauOffs[ni] = float32(ly.GTauVar.Gen(-1))
+		}
+		return
+	}
+	npool := ly.Shp.Dim(0) * ly.Shp.Dim(1)
+	nu := nn / npool
+	for pi := 0; pi < npool; pi++ {
+		off := float32(ly.GTauVar.Gen(-1))
+		for ui := 0; ui < nu; ui++ {
+			ly.GTauOffs[pi*nu+ui] = off
+		}
+	}
+}
+
+// GTau returns the conductance time constant of given unit
+func (ly *ECLayer) GTau(ni int) float32 {
+	tau := ly.Act.Dt.GTau
+	if ly.GTauOffs != nil {
+		tau += ly.GTauOffs[ni]
+	}
+	if tau < 1 {
+		tau = 1
+	}
+	return tau
+}
+
+// InitWts initializes the weights, and draws new GTau offsets
+func (ly *ECLayer) InitWts() {
+	ly.Layer.InitWts()
+	ly.InitGTaus()
+}
+
+// GFmInc integrates new synaptic conductances from increments sent during
+// last SendGDelta, using the per-unit GTau
+func (ly *ECLayer) GFmInc(ltime *leabra.Time) {
+	if ly.GTauOffs == nil {
+		ly.Layer.GFmInc(ltime)
+		return
+	}
+	ly.RecvGInc(ltime)
+	gdt := ly.Act.Dt.GDt
+	for ni := range ly.Neurons {
+		nrn := &ly.Neurons[ni]
+		if nrn.IsOff() {
+			continue
+		}
+		ly.Act.Dt.GDt = 1 / ly.GTau(ni)
+		ly.Act.GeFmRaw(nrn, nrn.GeRaw)
+		ly.Act.GiFmRaw(nrn, nrn.GiRaw)
+	}
+	ly.Act.Dt.GDt = gdt
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EcModule is the lateral inhibition kernel of one EC sheet (grid module):
+// the radius of the inhibitory surround sets the grid spacing, so modules with
+// larger radii have larger spacings, as in more ventral MEC.
+type EcModule struct {
+	InhibRadius int     `desc:"radius of the lateral inhibitory kernel, in pools"`
+	InhibSigma  float32 `desc:"sigma of the gaussian lateral inhibitory weights"`
+}
+
+// ECModuleName returns the layer name of given EC module: EC for the first,
+// so existing references to EC are unchanged, then EC2, EC3...
+func ECModuleName(mi int) string {
+	if mi == 0 {
+		return "EC"
+	}
+	return "EC" + strconv.Itoa(mi+1)
+}
+
+// ECModules returns the EC modules to configure: Modules, or one module with
+// the default lateral kernel if empty, scaled with the ECSize by ScaleModule
+func (ec *EcParams) ECModules() []EcModule {
+	mods := ec.Modules
+	if len(mods) == 0 {
+		mods = []EcModule{{InhibRadius: ec.inhibRadius4D, InhibSigma: ec.inhibSigma4D}}
+	}
+	sms := make([]EcModule, len(mods))
+	for mi, mod := range mods {
+		sms[mi] = ec.ScaleModule(mod)
+	}
+	return sms
+}
+
+// ParseEcModules parses EC modules as comma-separated Radius:Sigma entries,
+// e.g., 2:2,3:2,5:3
+func ParseEcModules(s string) ([]EcModule, error) {
+	var mods []EcModule
+	for _, ms := range strings.Split(s, ",") {
+		ms = strings.TrimSpace(ms)
+		if ms == "" {
+			continue
+		}
+		fs := strings.Split(ms, ":")
+		if len(fs) != 2 {
+			return nil, fmt.Errorf("EC module must be Radius:Sigma: %s", ms)
+		}
+		rad, err := strconv.Atoi(fs[0])
+		if err != nil || rad < 1 {
+			return nil, fmt.Errorf("EC module radius must be a positive integer: %s", ms)
+		}
+		sig, err := strconv.ParseFloat(fs[1], 32)
+		if err != nil || sig <= 0 {
+			return nil, fmt.Errorf("EC module sigma must be a positive number: %s", ms)
+		}
+		mods = append(mods, EcModule{InhibRadius: rad, InhibSigma: float32(sig)})
+	}
+	return mods, nil
+}
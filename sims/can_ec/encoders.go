@@ -0,0 +1,188 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+
+	"github.com/emer/emergent/popcode"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/ki/ints"
+	"github.com/goki/ki/kit"
+	"github.com/goki/mat32"
+)
+
+// Encoder renders a state value into a pattern of activity.
+// val is in the same units as the corresponding popcode in the env
+// (e.g., normalized position, fraction of a full rotation for angle).
+// 1D patterns (Dim(0) == 1) only use val.X.
+type Encoder interface {
+	Encode(pat *etensor.Float32, val mat32.Vec2)
+}
+
+// EncoderTypes are the types of encoders that can be selected per state in XYHDEnv.StateEnc
+type EncoderTypes int32
+
+var KiT_EncoderTypes = kit.Enums.AddEnum(EncoderTypesN, false, nil)
+
+const (
+	// EncGaussBump uses the env's gaussian bump popcodes (PopCode, AngCode, PopCode2d) -- the default
+	EncGaussBump EncoderTypes = iota
+
+	// EncOneHot activates the single unit closest to the value
+	EncOneHot
+
+	// EncBinary encodes the index of the closest unit as a binary number
+	// (for 2D, X bits in the first half of units, Y bits in the second half)
+	EncBinary
+
+	// EncGridMix is a mixture of periodic (grid-cell like) codes at multiple spatial scales
+	EncGridMix
+
+	EncoderTypesN
+)
+
+// EncRange is the range of values represented by an encoder, used to normalize
+// values to the 0-1 range across the units
+type EncRange struct {
+	Min  mat32.Vec2 `desc:"minimum value represented"`
+	Max  mat32.Vec2 `desc:"maximum value represented"`
+	Wrap bool       `desc:"values are periodic, with Max == Min"`
+}
+
+// Norm returns value normalized to 0-1 within range, clipped
+func (er *EncRange) Norm(val mat32.Vec2) mat32.Vec2 {
+	nv := val.Sub(er.Min).Div(er.Max.Sub(er.Min))
+	if er.Wrap {
+		nv.X -= mat32.Floor(nv.X)
+		nv.Y -= mat32.Floor(nv.Y)
+		return nv
+	}
+	nv.Clamp(mat32.Vec2{0, 0}, mat32.Vec2{1, 1})
+	return nv
+}
+
+// Idx returns the index of the unit closest to normalized value nv, for n units
+func (er *EncRange) Idx(nv float32, n int) int {
+	if er.Wrap {
+		return int(mat32.Round(nv*float32(n))) % n
+	}
+	return int(mat32.Round(nv * float32(n-1)))
+}
+
+// PopCodeEnc adapts the popcode.OneD, Ring, or TwoD (only one should be set) to the Encoder interface
+type PopCodeEnc struct {
+	OneD *popcode.OneD `desc:"1D linear popcode"`
+	Ring *popcode.Ring `desc:"1D periodic popcode"`
+	TwoD *popcode.TwoD `desc:"2D popcode"`
+}
+
+func (pe *PopCodeEnc) Encode(pat *etensor.Float32, val mat32.Vec2) {
+	switch {
+	case pe.TwoD != nil:
+		pe.TwoD.Encode(pat, val, popcode.Set)
+	case pe.Ring != nil:
+		pe.Ring.Encode(&pat.Values, val.X, pat.Len())
+	default:
+		pe.OneD.Encode(&pat.Values, val.X, pat.Len(), popcode.Set)
+	}
+}
+
+// OneHotEnc activates the single unit closest to the value
+type OneHotEnc struct {
+	EncRange
+}
+
+func (oe *OneHotEnc) Encode(pat *etensor.Float32, val mat32.Vec2) {
+	pat.SetZeros()
+	nv := oe.Norm(val)
+	ny := pat.Dim(0)
+	nx := pat.Dim(1)
+	if ny == 1 {
+		pat.Values[oe.Idx(nv.X, pat.Len())] = 1
+		return
+	}
+	pat.Set([]int{oe.Idx(nv.Y, ny), oe.Idx(nv.X, nx)}, 1)
+}
+
+// BinaryEnc encodes the index of the unit closest to the value as a binary number,
+// least-significant bit first
+type BinaryEnc struct {
+	EncRange
+}
+
+func (be *BinaryEnc) Encode(pat *etensor.Float32, val mat32.Vec2) {
+	pat.SetZeros()
+	nv := be.Norm(val)
+	ny := pat.Dim(0)
+	nx := pat.Dim(1)
+	if ny == 1 {
+		be.SetBits(pat.Values, be.Idx(nv.X, pat.Len()))
+		return
+	}
+	hn := pat.Len() / 2
+	be.SetBits(pat.Values[:hn], be.Idx(nv.X, nx))
+	be.SetBits(pat.Values[hn:], be.Idx(nv.Y, ny))
+}
+
+// SetBits sets the bits of idx into vals
+func (be *BinaryEnc) SetBits(vals []float32, idx int) {
+	for i := range vals {
+		if idx&(1<<uint(i)) != 0 {
+			vals[i] = 1
+		}
+	}
+}
+
+// GridMixEnc is a mixture of periodic (grid-cell like) codes at multiple scales.
+// Units are divided evenly among NScales modules, each with a period that increases
+// by ScaleRatio, and units within a module tile the phases of the period.
+// In 2D, each unit's activity is the sum of three gratings at 60 degrees (hexagonal grid).
+type GridMixEnc struct {
+	EncRange
+	NScales    int     `def:"4" desc:"number of modules with different spatial scales"`
+	MinPeriod  float32 `def:"0.2" desc:"period of the smallest scale, in normalized (0-1) units"`
+	ScaleRatio float32 `def:"1.42" desc:"ratio of period of each successive scale"`
+}
+
+func (ge *GridMixEnc) Defaults() {
+	ge.NScales = 4
+	ge.MinPeriod = 0.2
+	ge.ScaleRatio = 1.42
+}
+
+func (ge *GridMixEnc) Encode(pat *etensor.Float32, val mat32.Vec2) {
+	nv := ge.Norm(val)
+	n := pat.Len()
+	nper := n / ge.NScales
+	if nper < 1 {
+		nper = 1
+	}
+	twoD := pat.Dim(0) > 1
+	nph := int(math.Ceil(math.Sqrt(float64(nper)))) // phases per dim in 2D
+	for i := range pat.Values {
+		sc := ints.MinInt(i/nper, ge.NScales-1)
+		k := i % nper
+		per := ge.MinPeriod * mat32.Pow(ge.ScaleRatio, float32(sc))
+		if ge.Wrap { // integer number of periods so it wraps continuously
+			per = 1 / mat32.Max(1, mat32.Round(1/per))
+		}
+		frq := 2 * mat32.Pi / per
+		if !twoD {
+			ph := per * float32(k) / float32(nper)
+			pat.Values[i] = 0.5 + 0.5*mat32.Cos(frq*(nv.X-ph))
+			continue
+		}
+		ph := mat32.Vec2{per * float32(k%nph) / float32(nph), per * float32(k/nph) / float32(nph)}
+		ori := float32(sc) * mat32.Pi / (3 * float32(ge.NScales)) // rotate each module
+		dv := nv.Sub(ph)
+		sum := float32(0)
+		for g := 0; g < 3; g++ {
+			ang := ori + float32(g)*mat32.Pi/3
+			sum += mat32.Cos(frq * (dv.X*mat32.Cos(ang) + dv.Y*mat32.Sin(ang)))
+		}
+		pat.Values[i] = (sum + 1.5) / 4.5 // sum ranges -1.5..3
+	}
+}
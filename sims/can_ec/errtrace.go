@@ -0,0 +1,138 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/etview"
+	"github.com/goki/gi/gist"
+	"github.com/goki/gi/giv"
+)
+
+// ErrTraceColors is the number of error color levels in the dTrace view,
+// after the material and heading colors of XYHDEnvColors
+const ErrTraceColors = 8
+
+// ErrTrace configures the decoding error overlay in the dTrace view: each step
+// draws a line from the actual to the decoded position, colored by the position
+// error, up to MaxErr, on the ErrMap of the ColorTheme (green to red by
+// default).  It also accumulates the Map of the mean position error at each
+// actual position over the current epoch, viewed in the ErrMap tab, showing
+// the spatial structure of decoding errors.
+type ErrTrace struct {
+	MaxErr float32          `def:"5" desc:"position decoding error, in world units, at the top of the error color scale"`
+	Map    *etensor.Float32 `view:"no-inline" desc:"mean position decoding error at each actual position over the current epoch -- 0 where not visited"`
+
+	sum   []float64
+	n     []float64
+	epoch int
+}
+
+// Defaults sets default params
+func (et *ErrTrace) Defaults() {
+	et.MaxErr = 5
+	et.Map = &etensor.Float32{}
+}
+
+// Reset clears the Map and sets it to the shape of given world
+func (et *ErrTrace) Reset(world *etensor.Int) {
+	et.Map.SetShape(world.Shapes(), nil, []string{"Y", "X"})
+	et.Map.SetZeros()
+	nc := et.Map.Len()
+	et.sum = make([]float64, nc)
+	et.n = make([]float64, nc)
+}
+
+// Add adds the position error at actual position x, y on epoch epc to the Map,
+// which is reset at the start of each epoch
+func (et *ErrTrace) Add(x, y int, perr float64, epc int) {
+	if epc != et.epoch || len(et.sum) != et.Map.Len() {
+		et.Map.SetZeros()
+		et.sum = make([]float64, et.Map.Len())
+		et.n = make([]float64, et.Map.Len())
+		et.epoch = epc
+	}
+	if x < 0 || y < 0 || y >= et.Map.Dim(0) || x >= et.Map.Dim(1) {
+		return
+	}
+	i := et.Map.Offset([]int{y, x})
+	et.sum[i] += perr
+	et.n[i]++
+	et.Map.Values[i] = float32(et.sum[i] / et.n[i])
+}
+
+// Bin returns the error color level of given position error
+func (et *ErrTrace) Bin(perr float64) int {
+	bi := int(perr / float64(et.MaxErr) * ErrTraceColors)
+	if bi < 0 {
+		bi = 0
+	}
+	if bi >= ErrTraceColors {
+		bi = ErrTraceColors - 1
+	}
+	return bi
+}
+
+// ErrTraceColor returns the color of error level bi, from the low to the
+// high end of color map cm -- the ErrMap of the ColorTheme
+func ErrTraceColor(cm *giv.ColorMap, bi int) gist.Color {
+	nv := float64(bi) / float64(ErrTraceColors-1)
+	return cm.Map(nv)
+}
+
+// DrawErrLine draws a line of color index col into the dTrace from the actual
+// position x0, y0 to the decoded position x1, y1, excluding the end points,
+// and only over empty world locations, so walls remain visible
+func (ss *Sim) DrawErrLine(x0, y0, x1, y1, col int) {
+	world := ss.TrainEnv.World
+	dx, dy := x1-x0, y1-y0
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx - dy
+	x, y := x0, y0
+	for x != x1 || y != y1 {
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			x += sx
+		}
+		if e2 < dx {
+			err += dx
+			y += sy
+		}
+		if x == x1 && y == y1 {
+			break
+		}
+		if x < 0 || y < 0 || y >= world.Dim(0) || x >= world.Dim(1) {
+			continue
+		}
+		idx := []int{y, x}
+		if world.Value(idx) != 0 {
+			continue
+		}
+		ss.dTrace.Set(idx, col)
+	}
+}
+
+func (ss *Sim) ConfigErrMapGrid(tg *etview.TensorGrid) {
+	tg.Disp.Defaults()
+	tg.Disp.ColorMap = giv.ColorMapName(ss.CurTheme.ErrGridMap)
+	tg.Disp.Range.SetMin(0)
+	tg.Disp.Range.SetMax(float64(ss.ErrTrace.MaxErr))
+	tg.Disp.GridFill = 1
+	tg.SetStretchMax()
+	tg.SetTensor(ss.ErrTrace.Map)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+
+	"github.com/emer/etable/etable"
+	"github.com/goki/gi/gi"
+)
+
+// ExecHook runs an external command after each training epoch, e.g., a Python
+// analysis script, so analyses can run incrementally during training.
+// The command is given two extra args: the path to the epoch log and the path to
+// the latest weights, and the env vars MAPNAV_RUN and MAPNAV_EPOCH are set.
+// The command runs in the background: if it is still running at the end of the
+// next epoch, that epoch is skipped.
+type ExecHook struct {
+	Cmd   string `desc:"command with args, split on whitespace -- empty = no hook"`
+	Every int    `def:"1" min:"1" desc:"run the command every this many epochs"`
+
+	running int32 // 1 while the command is running -- accessed atomically
+}
+
+// ExecAfterEpoch runs the ExecHook command, if set, for given epoch --
+// called at the end of LogTrnEpc
+func (ss *Sim) ExecAfterEpoch(epc int) {
+	eh := &ss.ExecHook
+	if eh.Cmd == "" || eh.Every <= 0 || (epc+1)%eh.Every != 0 {
+		return
+	}
+	if atomic.LoadInt32(&eh.running) == 1 {
+		fmt.Printf("ExecHook: skipping epoch %d -- previous command still running\n", epc)
+		return
+	}
+	args := strings.Fields(eh.Cmd)
+	var lognm string
+	if ss.TrnEpcFile != nil {
+		ss.TrnEpcFile.Flush()
+		lognm = ss.TrnEpcFile.Name()
+	} else {
+		lognm = ss.LogFileName("trn_epc_latest")
+		ss.TrnEpcLog.SaveCSV(gi.FileName(lognm), etable.Tab, etable.Headers)
+	}
+	wtsnm := ss.Net.Nm + "_" + ss.RunName() + "_latest.wts.gz"
+	if err := ss.SaveWtsMeta(wtsnm); err != nil {
+		log.Println(err)
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], lognm, wtsnm)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("MAPNAV_RUN=%d", ss.TrainEnv.Run.Cur), fmt.Sprintf("MAPNAV_EPOCH=%d", epc))
+	if err := cmd.Start(); err != nil {
+		log.Println(err)
+		return
+	}
+	atomic.StoreInt32(&eh.running, 1)
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Printf("ExecHook: %s: %v\n", eh.Cmd, err)
+		}
+		atomic.StoreInt32(&eh.running, 0)
+	}()
+}
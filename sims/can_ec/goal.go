@@ -0,0 +1,81 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/emer/emergent/evec"
+	"github.com/goki/mat32"
+)
+
+// InitGoal initializes the current goal -- called in Init
+func (ev *XYHDEnv) InitGoal() {
+	ev.GoalIdx = -1
+	ev.NextGoal()
+	ev.UpdateGoal()
+	ev.GoalsHit = 0
+}
+
+// NextGoal selects the next goal: the next of the Goals for the Preset in order,
+// or a random empty location if there are no Goals (e.g., OpenField)
+func (ev *XYHDEnv) NextGoal() {
+	if len(ev.Goals) > 0 {
+		ev.GoalIdx = (ev.GoalIdx + 1) % len(ev.Goals)
+		ev.GoalPos = ev.Goals[ev.GoalIdx]
+		return
+	}
+	for {
+		p := evec.Vec2i{rand.Intn(ev.Size.X), rand.Intn(ev.Size.Y)}
+		if ev.GetWorld(p) == 0 && p != ev.PosI {
+			ev.GoalPos = p
+			return
+		}
+	}
+}
+
+// UpdateGoal selects the next goal if the current one has been reached,
+// and computes the egocentric direction and distance to the goal
+func (ev *XYHDEnv) UpdateGoal() {
+	rad := ev.TrackW/2 + 1
+	d := ev.GoalPos.Sub(ev.PosI)
+	if absInt(d.X) <= rad && absInt(d.Y) <= rad {
+		ev.GoalsHit++
+		ev.NextGoal()
+	}
+	dv := ev.GoalPos.ToVec2().Sub(ev.PosF)
+	ev.GoalDist = dv.Length()
+	allo := float64(mat32.RadToDeg(mat32.Atan2(dv.Y, dv.X)))
+	ev.GoalDir = float32(AngDiff(allo, float64(ev.Angle)))
+}
+
+// GoalAct returns the action that heads toward the current goal: a turn
+// toward it if it is more than half an AngInc off the heading, and Forward
+// otherwise, with the reflexive ActGen at walls and UnstickAct when stuck
+func (ev *XYHDEnv) GoalAct() int {
+	if ev.IsStuck && ev.Stuck.Unstick {
+		return ev.UnstickAct()
+	}
+	half := float32(ev.AngInc) / 2
+	switch {
+	case ev.GoalDir > half:
+		return int(Left)
+	case ev.GoalDir < -half:
+		return int(Right)
+	case ev.ProxMats[0] == ev.MatMap["Wall"]:
+		return ev.ActGen()
+	}
+	return int(Forward)
+}
+
+// RenderGoal renders the egocentric goal direction and distance
+func (ev *XYHDEnv) RenderGoal() {
+	gd := ev.NextStates["GoalDir"]
+	ev.Encs["GoalDir"].Encode(gd, mat32.Vec2{float32(AngNorm(float64(ev.GoalDir))) / 360, 0})
+	maxd := float32(math.Hypot(float64(ev.Size.X-2), float64(ev.Size.Y-2)))
+	gds := ev.NextStates["GoalDist"]
+	ev.Encs["GoalDist"].Encode(gds, mat32.Vec2{ev.GoalDist / maxd, 0})
+}
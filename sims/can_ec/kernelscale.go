@@ -0,0 +1,125 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goki/mat32"
+)
+
+// KernelFactor returns the factor that the lateral kernel radii and sigmas
+// of the EC modules are scaled by with KernelScale: the ratio of the ECSize
+// (the smaller side) to the KernelRef size they are tuned for, so that they
+// stay the same fractions of the sheet size -- 1 without KernelScale
+func (ec *EcParams) KernelFactor() float32 {
+	if !ec.KernelScale || ec.KernelRef <= 0 {
+		return 1
+	}
+	sz := ec.ECSize.X
+	if ec.ECSize.Y < sz {
+		sz = ec.ECSize.Y
+	}
+	return float32(sz) / float32(ec.KernelRef)
+}
+
+// ScaleKernel returns given kernel radius and sigma scaled by the
+// KernelFactor -- the radius is rounded, and at least 1
+func (ec *EcParams) ScaleKernel(rad int, sigma float32) (int, float32) {
+	kf := ec.KernelFactor()
+	if kf == 1 {
+		return rad, sigma
+	}
+	srad := int(mat32.Round(float32(rad) * kf))
+	if srad < 1 {
+		srad = 1
+	}
+	return srad, sigma * kf
+}
+
+// ScaleModule returns given module with its radius and sigma scaled by
+// ScaleKernel
+func (ec *EcParams) ScaleModule(mod EcModule) EcModule {
+	mod.InhibRadius, mod.InhibSigma = ec.ScaleKernel(mod.InhibRadius, mod.InhibSigma)
+	return mod
+}
+
+// ExcitKernel returns the radius and sigma of the excitatory lateral kernel
+// of the 4D EC, used by the ExciteLateral prjn and InitLateralWts, scaled
+// by ScaleKernel
+func (ec *EcParams) ExcitKernel() (int, float32) {
+	return ec.ScaleKernel(ec.excitRadius4D, ec.excitSigma4D)
+}
+
+// ValidateKernels checks that the lateral kernel of every EC module, and
+// the excitatory lateral kernel of the 4D EC, fits in the EC sheet without
+// wrapping onto itself, and has a positive sigma, returning an error
+// listing all the problems found
+func (ec *EcParams) ValidateKernels() error {
+	sz := ec.ECSize.X
+	if ec.ECSize.Y < sz {
+		sz = ec.ECSize.Y
+	}
+	var errs []string
+	for mi, mod := range ec.ECModules() {
+		// diameter and sheet side in grid positions: Circle flattens the
+		// sheet to units, 2 per pool side, while TwistTorus radii are in pools
+		dia, side := 2*mod.InhibRadius+1, 2*sz
+		if ec.TwistTorus {
+			dia, side = 2*mod.InhibRadius*ec.PoolSide()+1, sz*ec.PoolSide()
+		}
+		if dia > side {
+			errs = append(errs, fmt.Sprintf("%s radius %d does not fit in the %v sheet", ECModuleName(mi), mod.InhibRadius, ec.ECSize))
+		}
+		if mod.InhibSigma <= 0 {
+			errs = append(errs, fmt.Sprintf("%s sigma %g must be positive", ECModuleName(mi), mod.InhibSigma))
+		}
+	}
+	if !ec.EC2D { // in pools
+		rad, sigma := ec.ExcitKernel()
+		if 2*rad+1 > sz {
+			errs = append(errs, fmt.Sprintf("excitatory radius %d does not fit in the %v sheet", rad, ec.ECSize))
+		}
+		if sigma <= 0 {
+			errs = append(errs, fmt.Sprintf("excitatory sigma %g must be positive", sigma))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("EC kernels: %s", strings.Join(errs, "; "))
+}
+
+// KernelString returns the radius and sigma of the lateral kernel of each
+// EC module, and of the excitatory kernel of the 4D EC, as configured,
+// e.g., EC:2:2 EC2:3:2 Excit:3:2
+func (ec *EcParams) KernelString() string {
+	mods := ec.ECModules()
+	ks := make([]string, len(mods))
+	for mi, mod := range mods {
+		ks[mi] = fmt.Sprintf("%s:%d:%g", ECModuleName(mi), mod.InhibRadius, mod.InhibSigma)
+	}
+	if !ec.EC2D {
+		rad, sigma := ec.ExcitKernel()
+		ks = append(ks, fmt.Sprintf("Excit:%d:%g", rad, sigma))
+	}
+	return strings.Join(ks, " ")
+}
+
+// KernelCheck prints the scaled lateral kernels of the EC modules for each
+// of given ECSizes, and whether they are valid, to check a sheet-size sweep
+// before running it
+func (ec *EcParams) KernelCheck(sizes []int) {
+	for _, sz := range sizes {
+		ck := *ec
+		ck.ECSize.Set(sz, sz)
+		st := "ok"
+		if err := ck.ValidateKernels(); err != nil {
+			st = err.Error()
+		}
+		fmt.Printf("ECSize: %d\tFactor: %g\tKernels: %s\t%s\n", sz, ck.KernelFactor(), ck.KernelString(), st)
+	}
+}
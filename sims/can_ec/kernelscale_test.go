@@ -0,0 +1,73 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/goki/mat32"
+)
+
+func TestScaleKernels(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		scale  bool
+		factor float32
+		inhib  EcModule
+		excRad int
+		excSig float32
+		valid  bool
+	}{
+		{"ref", 10, true, 1, EcModule{2, 2}, 3, 2, true},
+		{"double", 20, true, 2, EcModule{4, 4}, 6, 4, true},
+		{"half", 5, true, 0.5, EcModule{1, 1}, 2, 1, true},      // excit 1.5 rounds to 2
+		{"small", 3, true, 0.3, EcModule{1, 0.6}, 1, 0.6, true}, // radii at least 1
+		{"unscaled", 20, false, 1, EcModule{2, 2}, 3, 2, true},
+		{"unscaled-small", 4, false, 1, EcModule{2, 2}, 3, 2, false}, // excit 7 pools > 4
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ec EcParams
+			ec.Defaults()
+			ec.ECSize.Set(tt.size, tt.size)
+			ec.KernelScale = tt.scale
+			if kf := ec.KernelFactor(); !approx(kf, tt.factor) {
+				t.Errorf("KernelFactor = %g, want %g", kf, tt.factor)
+			}
+			mods := ec.ECModules()
+			if len(mods) != 1 || mods[0].InhibRadius != tt.inhib.InhibRadius || !approx(mods[0].InhibSigma, tt.inhib.InhibSigma) {
+				t.Errorf("ECModules = %v, want [%v]", mods, tt.inhib)
+			}
+			if rad, sig := ec.ExcitKernel(); rad != tt.excRad || !approx(sig, tt.excSig) {
+				t.Errorf("ExcitKernel = %d, %g, want %d, %g", rad, sig, tt.excRad, tt.excSig)
+			}
+			if err := ec.ValidateKernels(); (err == nil) != tt.valid {
+				t.Errorf("ValidateKernels = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateKernelsModules(t *testing.T) {
+	var ec EcParams
+	ec.Defaults()
+	ec.Modules = []EcModule{{2, 2}, {5, 3}}
+	for _, sz := range []int{6, 10, 20, 30} {
+		ec.ECSize.Set(sz, sz)
+		if err := ec.ValidateKernels(); err != nil {
+			t.Errorf("ECSize %d: %v", sz, err)
+		}
+	}
+	ec.KernelScale = false
+	ec.ECSize.Set(4, 4) // Circle: module diameter 11 > 2*4 units
+	if err := ec.ValidateKernels(); err == nil {
+		t.Errorf("ECSize 4 unscaled: no error for module radius 5")
+	}
+}
+
+func approx(a, b float32) bool {
+	return mat32.Abs(a-b) < 1.0e-5
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/ki/ints"
+)
+
+////////////////////////////////////////////////////////////////////
+// Env: segmentation of behavior into laps / journeys
+
+// LapsOn returns true if the current Preset supports lap / journey segmentation
+func (ev *XYHDEnv) LapsOn() bool {
+	return ev.Preset == LinearTrack || ev.Preset == TMaze || ev.Preset == Figure8
+}
+
+// InitLaps initializes the lap tracking state -- called in Init
+func (ev *XYHDEnv) InitLaps() {
+	ev.Lap.Init()
+	ev.LastGoal = -1
+	ev.PassedStart = true
+	ev.Journey = ""
+	ev.LapDone = false
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// GoalAt returns the index of the goal within TrackW/2 + 1 of given
+// position, or -1 if none
+func (ev *XYHDEnv) GoalAt(p evec.Vec2i) int {
+	rad := ev.TrackW/2 + 1
+	for i, g := range ev.Goals {
+		d := p.Sub(g)
+		if absInt(d.X) <= rad && absInt(d.Y) <= rad {
+			return i
+		}
+	}
+	return -1
+}
+
+// JourneyName returns the name of the journey ending at given goal index:
+// LtoR or RtoL for LinearTrack, Left or Right choice for TMaze and Figure8
+func (ev *XYHDEnv) JourneyName(goal int) string {
+	if ev.Preset == LinearTrack {
+		if goal == 1 {
+			return "LtoR"
+		}
+		return "RtoL"
+	}
+	if goal == 0 {
+		return "Left"
+	}
+	return "Right"
+}
+
+// UpdateLaps updates lap tracking after a move: a lap is completed when the agent
+// reaches a goal -- for LinearTrack, the goal at the other end of the track,
+// and for TMaze and Figure8, either arm after passing through the Start location
+// since the last goal.  LapDone is set for the step on which a lap was completed,
+// and Journey is then the name of the completed journey -- see LapSince for
+// lap completion on any step of a trial.
+func (ev *XYHDEnv) UpdateLaps() {
+	ev.LapDone = false
+	if !ev.LapsOn() {
+		return
+	}
+	if ev.Preset != LinearTrack {
+		sd := ev.PosI.Sub(ev.Start)
+		if absInt(sd.X) <= ev.TrackW/2 && absInt(sd.Y) <= ev.TrackW/2 {
+			ev.PassedStart = true
+		}
+	}
+	g := ev.GoalAt(ev.PosI)
+	if g < 0 {
+		return
+	}
+	if ev.Preset == LinearTrack {
+		if g == ev.LastGoal {
+			return
+		}
+		ev.LapDone = ev.LastGoal >= 0 // first arrival at an end just starts the first lap
+	} else {
+		if !ev.PassedStart {
+			return
+		}
+		ev.LapDone = true
+		ev.PassedStart = false
+	}
+	ev.LastGoal = g
+	if ev.LapDone {
+		ev.Journey = ev.JourneyName(g)
+		ev.Lap.Incr()
+	}
+}
+
+// LapSince returns true if a lap has been completed since the lap count in
+// seen, which is then updated to the current count -- each user of lap
+// completion keeps its own count, so that a lap completed on any of the
+// steps of a trial is seen once, at the end of the trial.  seen must be reset
+// to 0 when the env is initialized.
+func (ev *XYHDEnv) LapSince(seen *int) bool {
+	if ev.Lap.Cur == *seen {
+		return false
+	}
+	*seen = ev.Lap.Cur
+	return true
+}
+
+////////////////////////////////////////////////////////////////////
+// Sim: per-lap logging and per-journey ARFs
+
+// LapTrial is the data saved for each trial of the current lap, used to compute
+// the per-journey ARFs once the journey is known at the end of the lap
+type LapTrial struct {
+	Pos  evec.Vec2i
+	Acts map[string]*etensor.Float32
+}
+
+// LapTrialAdd adds the current trial ARF layer activations to the current lap,
+// and at the end of a lap, adds all of the lap's trials to the per-journey
+// Pos ARFs -- called in UpdtARFs, after ValsTsrs are updated, on the laps of
+// the TestEnv
+func (ss *Sim) LapTrialAdd() {
+	ev := &ss.TestEnv
+	if !ev.LapsOn() {
+		return
+	}
+	lt := LapTrial{Pos: ev.PosI, Acts: make(map[string]*etensor.Float32)}
+	for _, lnm := range ss.ARFLayers {
+		if ss.Net.LayerByName(lnm) == nil {
+			continue
+		}
+		lt.Acts[lnm] = ss.ValsTsr(lnm).Clone().(*etensor.Float32)
+	}
+	ss.LapTrials = append(ss.LapTrials, lt)
+	if !ev.LapSince(&ss.LapARFSeen) {
+		return
+	}
+	jnm := ev.Journey
+	mt := ss.RFMaps["Pos"]
+	for _, lt := range ss.LapTrials {
+		mt.SetZeros()
+		mt.Set([]int{lt.Pos.Y, lt.Pos.X}, 1)
+		for lnm, vt := range lt.Acts {
+			nm := lnm + "_Pos_" + jnm
+			if _, err := ss.JourneyARFs.RFByNameTry(nm); err != nil {
+				af := ss.JourneyARFs.AddRF(nm, vt, mt)
+				ss.SetAFMetaData(&af.NormRF)
+			}
+			ss.JourneyARFs.Add(nm, vt, mt, 0.01) // thr prevent weird artifacts
+		}
+	}
+	ss.LapTrials = ss.LapTrials[:0]
+}
+
+// LapTrialStats accumulates per-lap stats from given row of the TrnTrlLog,
+// and logs the lap at the end of each lap
+func (ss *Sim) LapTrialStats(dt *etable.Table, row int) {
+	ev := &ss.TrainEnv
+	if !ev.LapsOn() {
+		return
+	}
+	ss.LapN++
+	ss.LapPosErr += dt.CellFloat("PosErr", row)
+	ss.LapPosACC += dt.CellFloat("PosACC", row)
+	if !ev.LapSince(&ss.LapSeen) {
+		return
+	}
+	ss.LogLap(ss.LapLog)
+	ss.LapN = 0
+	ss.LapPosErr = 0
+	ss.LapPosACC = 0
+}
+
+// LogLap adds data for the lap just completed to the LapLog table
+func (ss *Sim) LogLap(dt *etable.Table) {
+	ev := &ss.TrainEnv
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	n := float64(ints.MaxInt(ss.LapN, 1))
+	dt.SetCellFloat("Run", row, float64(ev.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ev.Epoch.Cur))
+	dt.SetCellFloat("Lap", row, float64(ev.Lap.Prv))
+	dt.SetCellString("Journey", row, ev.Journey)
+	dt.SetCellFloat("NTrials", row, float64(ss.LapN))
+	dt.SetCellFloat("PosErr", row, ss.LapPosErr/n)
+	dt.SetCellFloat("PosACC", row, ss.LapPosACC/n)
+	ss.SQLWriteRow("lap", dt, row)
+
+	ss.UpdtPlot(ss.LapPlot)
+}
+
+func (ss *Sim) ConfigLapLog(dt *etable.Table) {
+	dt.SetMetaData("name", "LapLog")
+	dt.SetMetaData("desc", "Record of each lap / journey on track and maze worlds")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Lap", etensor.INT64, nil, nil},
+		{"Journey", etensor.STRING, nil, nil},
+		{"NTrials", etensor.INT64, nil, nil},
+		{"PosErr", etensor.FLOAT64, nil, nil},
+		{"PosACC", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigLapPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Lap Plot"
+	plt.Params.XAxisCol = "Lap"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Lap", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NTrials", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PosErr", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PosACC", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
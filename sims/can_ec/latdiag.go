@@ -0,0 +1,248 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// LatDiag computes per-epoch diagnostics of the EC lateral weight matrices
+// (the self prjns of each EC module), to see how learning on them reshapes the
+// attractor over training: the symmetry index of the weight matrix, the mean
+// and variance of the weights at each pool offset of the kernel, and the drift
+// from the weights as initialized at the start of the run.
+type LatDiag struct {
+	On     bool `desc:"compute the diagnostics at the end of each training epoch"`
+	MaxOff int  `def:"3" min:"0" desc:"maximum pool offset (along Y or X) for the per-offset kernel stats in the LatKernLog"`
+
+	Init map[string][]float32 `view:"-" desc:"weights of each lateral prjn at the start of the run, in Syns order, by prjn name"`
+}
+
+// Defaults sets default params
+func (ld *LatDiag) Defaults() {
+	ld.MaxOff = 3
+}
+
+// LatPrjns returns the lateral prjns of the EC modules in net: self prjns, or
+// from their inhibitory interneurons with EcParams.Dale
+func LatPrjns(net *leabra.Network) []*leabra.Prjn {
+	var pjs []*leabra.Prjn
+	for mi := 0; ; mi++ {
+		lyi := net.LayerByName(ECModuleName(mi))
+		if lyi == nil {
+			break
+		}
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		for _, pji := range ly.RcvPrjns {
+			pj := pji.(leabra.LeabraPrjn).AsLeabra()
+			if snm := pj.Send.Name(); snm == ly.Name() || snm == ECInhName(ly.Name()) {
+				pjs = append(pjs, pj)
+			}
+		}
+	}
+	return pjs
+}
+
+// Snapshot records the current lateral weights as the initial ones, for the
+// drift -- called in NewRun after InitWts
+func (ld *LatDiag) Snapshot(net *leabra.Network) {
+	if !ld.On {
+		return
+	}
+	ld.Init = make(map[string][]float32)
+	for _, pj := range LatPrjns(net) {
+		wts := make([]float32, len(pj.Syns))
+		for i := range pj.Syns {
+			wts[i] = pj.Syns[i].Wt
+		}
+		ld.Init[pj.Name()] = wts
+	}
+}
+
+// wrapOff returns the offset d between pools wrapped around a dimension of
+// size n, in the range -n/2..n/2
+func wrapOff(d, n int) int {
+	d = ((d % n) + n) % n
+	if d > n/2 {
+		d -= n
+	}
+	return d
+}
+
+// RecipSyn returns the index in Syns of the reciprocal of the synapse from
+// sending unit si to receiving unit ri of given self prjn, i.e., from ri to
+// si, or -1 if there is none.  The receiving units of each sender are in
+// order, so it is found by binary search.
+func RecipSyn(pj *leabra.Prjn, si, ri int) int {
+	if ri >= len(pj.SConN) {
+		return -1
+	}
+	nc := int(pj.SConN[ri])
+	st := int(pj.SConIdxSt[ri])
+	ci := sort.Search(nc, func(i int) bool { return int(pj.SConIdx[st+i]) >= si })
+	if ci < nc && int(pj.SConIdx[st+ci]) == si {
+		return st + ci
+	}
+	return -1
+}
+
+// LogLatDiag adds a row for each lateral prjn for given epoch to dt, with the
+// symmetry index, overall weight stats and drift, and a row for each prjn
+// and pool offset within MaxOff to kdt -- called in LogTrnEpc
+func (ss *Sim) LogLatDiag(dt, kdt *etable.Table, epc int) {
+	ld := &ss.LatDiag
+	if !ld.On {
+		return
+	}
+	run := float64(ss.TrainEnv.Run.Cur)
+	for _, pj := range LatPrjns(ss.Net) {
+		sh := pj.Send.Shape()
+		nPy, nPx, nu := twistPools(sh)
+		n := sh.Len()
+		iwts := ld.Init[pj.Name()]
+		nk := 2*ld.MaxOff + 1
+		ksum := make([]float64, nk*nk)
+		ksum2 := make([]float64, nk*nk)
+		kinit := make([]float64, nk*nk)
+		kn := make([]float64, nk*nk)
+
+		var sum, sum2, drift, asym, tot float64
+		for si := 0; si < n; si++ {
+			spi := si / nu
+			nc := int(pj.SConN[si])
+			st := int(pj.SConIdxSt[si])
+			for ci := 0; ci < nc; ci++ {
+				idx := st + ci
+				ri := int(pj.SConIdx[idx])
+				wt := float64(pj.Syns[idx].Wt)
+				if ri < si { // each reciprocal pair once
+					if rsi := RecipSyn(pj, si, ri); rsi >= 0 {
+						rwt := float64(pj.Syns[rsi].Wt)
+						asym += math.Abs(wt - rwt)
+						tot += math.Abs(wt) + math.Abs(rwt)
+					}
+				}
+				sum += wt
+				sum2 += wt * wt
+				if len(iwts) == len(pj.Syns) {
+					drift += math.Abs(wt - float64(iwts[idx]))
+				}
+				rpi := ri / nu
+				dy := wrapOff(spi/nPx-rpi/nPx, nPy)
+				dx := wrapOff(spi%nPx-rpi%nPx, nPx)
+				if dy < -ld.MaxOff || dy > ld.MaxOff || dx < -ld.MaxOff || dx > ld.MaxOff {
+					continue
+				}
+				ki := (dy+ld.MaxOff)*nk + dx + ld.MaxOff
+				ksum[ki] += wt
+				ksum2[ki] += wt * wt
+				kn[ki]++
+				if len(iwts) == len(pj.Syns) {
+					kinit[ki] += float64(iwts[idx])
+				}
+			}
+		}
+		ns := float64(len(pj.Syns))
+		if ns == 0 {
+			continue
+		}
+		mean := sum / ns
+
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellFloat("Run", row, run)
+		dt.SetCellFloat("Epoch", row, float64(epc))
+		dt.SetCellString("Layer", row, pj.Recv.Name())
+		if tot > 0 {
+			dt.SetCellFloat("Sym", row, 1-asym/tot)
+		}
+		dt.SetCellFloat("MeanWt", row, mean)
+		dt.SetCellFloat("VarWt", row, sum2/ns-mean*mean)
+		dt.SetCellFloat("Drift", row, drift/ns)
+		ss.SQLWriteRow("latdiag", dt, row)
+
+		for ki, kc := range kn {
+			if kc == 0 {
+				continue
+			}
+			dy := ki/nk - ld.MaxOff
+			dx := ki%nk - ld.MaxOff
+			km := ksum[ki] / kc
+			row := kdt.Rows
+			kdt.SetNumRows(row + 1)
+			kdt.SetCellFloat("Run", row, run)
+			kdt.SetCellFloat("Epoch", row, float64(epc))
+			kdt.SetCellString("Layer", row, pj.Recv.Name())
+			kdt.SetCellFloat("DY", row, float64(dy))
+			kdt.SetCellFloat("DX", row, float64(dx))
+			kdt.SetCellFloat("Dist", row, math.Sqrt(float64(dy*dy+dx*dx)))
+			kdt.SetCellFloat("Mean", row, km)
+			kdt.SetCellFloat("Var", row, ksum2[ki]/kc-km*km)
+			kdt.SetCellFloat("InitMean", row, kinit[ki]/kc)
+			ss.SQLWriteRow("latkern", kdt, row)
+		}
+	}
+
+	ss.UpdtPlot(ss.LatDiagPlot)
+}
+
+func (ss *Sim) ConfigLatDiagLog(dt *etable.Table) {
+	dt.SetMetaData("name", "LatDiagLog")
+	dt.SetMetaData("desc", "EC lateral weight diagnostics per epoch: symmetry index, weight stats, and drift from the initial weights")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Layer", etensor.STRING, nil, nil},
+		{"Sym", etensor.FLOAT64, nil, nil},
+		{"MeanWt", etensor.FLOAT64, nil, nil},
+		{"VarWt", etensor.FLOAT64, nil, nil},
+		{"Drift", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigLatKernLog(dt *etable.Table) {
+	dt.SetMetaData("name", "LatKernLog")
+	dt.SetMetaData("desc", "EC lateral weight stats per epoch and pool offset of the kernel, with the initial mean")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Layer", etensor.STRING, nil, nil},
+		{"DY", etensor.INT64, nil, nil},
+		{"DX", etensor.INT64, nil, nil},
+		{"Dist", etensor.FLOAT64, nil, nil},
+		{"Mean", etensor.FLOAT64, nil, nil},
+		{"Var", etensor.FLOAT64, nil, nil},
+		{"InitMean", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigLatDiagPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Lateral Weight Diagnostics Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Sym", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("MeanWt", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("VarWt", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Drift", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	return plt
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/emergent/efuns"
+	"github.com/goki/mat32"
+)
+
+// LatOffsets are the gaussian centers of the lateral kernel for each of the 4
+// receiving units within an EC pool: the four corners
+var LatOffsets = []float32{1, 1, -1, 1, 1, -1, -1, -1}
+
+// LatKernel is the precomputed gaussian lateral weight kernel for the 4D EC,
+// indexed by receiving unit within the pool, then sending pool offset (sy, sx).
+// It only depends on the EcParams radius and sigma, so it is cached across
+// ReConfigNet and only recomputed when those change.
+type LatKernel struct {
+	Radius int       `desc:"radius of the kernel, in pools"`
+	Sigma  float32   `desc:"sigma of the gaussian"`
+	Wts    []float32 `desc:"weights, [4][2*Radius+1][2*Radius+1]"`
+}
+
+// Update recomputes the kernel if it is empty or radius or sigma have changed
+func (lk *LatKernel) Update(radius int, sigma float32) {
+	if lk.Wts != nil && lk.Radius == radius && lk.Sigma == sigma {
+		return
+	}
+	lk.Radius = radius
+	lk.Sigma = sigma
+	w := 2*radius + 1
+	lk.Wts = make([]float32, 4*w*w)
+	for i := 0; i < 4; i++ {
+		rctr := mat32.Vec2{LatOffsets[i*2], LatOffsets[i*2+1]}
+		for sy := -radius; sy <= radius; sy++ {
+			for sx := -radius; sx <= radius; sx++ {
+				v := mat32.NewVec2(float32(sx), float32(sy))
+				lk.Wts[lk.Idx(i, sy, sx)] = efuns.GaussVecDistNoNorm(v, rctr, sigma)
+			}
+		}
+	}
+}
+
+// Idx returns the index into Wts for given receiving unit and sending pool offset
+func (lk *LatKernel) Idx(ri, sy, sx int) int {
+	w := 2*lk.Radius + 1
+	return (ri*w+sy+lk.Radius)*w + sx + lk.Radius
+}
+
+// Wt returns the weight for given receiving unit and sending pool offset
+func (lk *LatKernel) Wt(ri, sy, sx int) float32 {
+	return lk.Wts[lk.Idx(ri, sy, sx)]
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/emer/emergent/decoder"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// LinDec is a learned linear decoder of position and heading from the
+// minus-phase activity (ActM) of Layer, evaluated by k-fold cross-validation
+// on recorded test trials, so decoding numbers are not inflated by overfitting.
+// Test trials are recorded into Trajs while On, up to MaxTrials per run.  The
+// recorded trajectory is split into K contiguous folds -- contiguous rather than
+// interleaved, so that temporally adjacent, nearly identical trials do not end
+// up on both sides of a split.  For each fold, a decoder.Linear is trained on
+// the other K-1 folds for Epochs passes, and evaluated on the held-out fold.
+// Outputs are the X, Y position normalized by the world size, and the cosine
+// and sine of the heading.
+type LinDec struct {
+	On        bool          `desc:"record test trials for the cross-validated linear decoder"`
+	Layer     string        `desc:"layer whose ActM activity is decoded"`
+	K         int           `def:"5" min:"2" desc:"number of cross-validation folds"`
+	Epochs    int           `def:"20" min:"1" desc:"number of passes through the training folds"`
+	LRate     float32       `def:"0.002" desc:"learning rate of the decoder"`
+	MaxTrials int           `def:"5000" desc:"maximum number of test trials recorded per run"`
+	Trajs     *etable.Table `view:"no-inline" desc:"recorded test trials: Layer ActM, and actual position and heading"`
+}
+
+// Defaults sets default params
+func (ld *LinDec) Defaults() {
+	ld.Layer = "EC"
+	ld.K = 5
+	ld.Epochs = 20
+	ld.LRate = 0.002
+	ld.MaxTrials = 5000
+	ld.Trajs = &etable.Table{}
+}
+
+func init() {
+	AddAnalysis(&Analysis{Name: "lindec", Label: "Lin Decode", Desc: "k-fold cross-validated linear decoding of position and heading from the recorded test trials, with train and held-out errors per fold.", Needs: []string{AnalysisLinDec}, Run: func(ss *Sim) []AnalysisOut {
+		return []AnalysisOut{{Name: "LinDecCV", Table: ss.LinDecCV()}}
+	}})
+}
+
+// ConfigLinDec configures the Trajs table for the LinDec layer, clearing any
+// recorded trials -- called at start of a new run
+func (ss *Sim) ConfigLinDec() {
+	ld := &ss.LinDec
+	dt := ld.Trajs
+	dt.SetMetaData("name", "LinDecTrajs")
+	dt.SetMetaData("desc", "Recorded test trials for the linear decoder")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	var shp []int
+	if ly := ss.Net.LayerByName(ld.Layer); ly != nil {
+		shp = ly.Shape().Shapes()
+	} else if ld.On {
+		log.Printf("LinDec: layer not found: %s\n", ld.Layer)
+	}
+	sch := etable.Schema{
+		{"X", etensor.FLOAT64, nil, nil},
+		{"Y", etensor.FLOAT64, nil, nil},
+		{"Angle", etensor.FLOAT64, nil, nil},
+		{"Act", etensor.FLOAT32, shp, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+// RecordLinDec records the current test trial for the linear decoder, if On --
+// called in TestTrial
+func (ss *Sim) RecordLinDec() {
+	ld := &ss.LinDec
+	if !ld.On || ld.Trajs.Rows >= ld.MaxTrials {
+		return
+	}
+	ly := ss.Net.LayerByName(ld.Layer)
+	if ly == nil {
+		return
+	}
+	ev := &ss.TestEnv
+	dt := ld.Trajs
+	tsr := ss.ValsTsr("LinDec")
+	ly.UnitValsTensor(tsr, "ActM")
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("X", row, float64(ev.PosF.X))
+	dt.SetCellFloat("Y", row, float64(ev.PosF.Y))
+	dt.SetCellFloat("Angle", row, float64(ev.Angle))
+	dt.SetCellTensor("Act", row, tsr)
+}
+
+// LinDecTargets sets targs to the decoder targets for given recorded row
+func (ss *Sim) LinDecTargets(row int, targs []float32) {
+	ev := &ss.TrainEnv
+	dt := ss.LinDec.Trajs
+	rad := dt.CellFloat("Angle", row) * math.Pi / 180
+	targs[0] = float32(dt.CellFloat("X", row)) / float32(ev.Size.X)
+	targs[1] = float32(dt.CellFloat("Y", row)) / float32(ev.Size.Y)
+	targs[2] = float32(math.Cos(rad))
+	targs[3] = float32(math.Sin(rad))
+}
+
+// LinDecErrs decodes the given rows, and returns the mean position error,
+// in world units, and the mean absolute heading error, in degrees
+func (ss *Sim) LinDecErrs(dec *decoder.Linear, rows []int) (poserr, orierr float64) {
+	ev := &ss.TrainEnv
+	dt := ss.LinDec.Trajs
+	acts := dt.ColByName("Act").(*etensor.Float32)
+	nin := dec.NInputs
+	for _, row := range rows {
+		copy(dec.Inputs, acts.Values[row*nin:(row+1)*nin])
+		dec.Forward()
+		dx := float64(dec.Units[0].Act*float32(ev.Size.X)) - dt.CellFloat("X", row)
+		dy := float64(dec.Units[1].Act*float32(ev.Size.Y)) - dt.CellFloat("Y", row)
+		poserr += math.Sqrt(dx*dx + dy*dy)
+		dori := AngNorm(math.Atan2(float64(dec.Units[3].Act), float64(dec.Units[2].Act)) * 180 / math.Pi)
+		orierr += math.Abs(AngDiff(dori, dt.CellFloat("Angle", row)))
+	}
+	if n := float64(len(rows)); n > 0 {
+		poserr /= n
+		orierr /= n
+	}
+	return
+}
+
+// LinDecCV runs the k-fold cross-validation of the linear decoder on the
+// recorded trials, returning a table with the train and held-out test errors
+// of each fold, and their mean in the last row
+func (ss *Sim) LinDecCV() *etable.Table {
+	ld := &ss.LinDec
+	dt := &etable.Table{}
+	dt.SetMetaData("name", "LinDecCV")
+	dt.SetMetaData("desc", "Cross-validated linear decoding errors per fold")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+	sch := etable.Schema{
+		{"Fold", etensor.STRING, nil, nil},
+		{"NTrain", etensor.INT64, nil, nil},
+		{"NTest", etensor.INT64, nil, nil},
+		{"TrnPosErr", etensor.FLOAT64, nil, nil},
+		{"TrnOriErr", etensor.FLOAT64, nil, nil},
+		{"PosErr", etensor.FLOAT64, nil, nil},
+		{"OriErr", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, ld.K+1)
+
+	n := ld.Trajs.Rows
+	acts := ld.Trajs.ColByName("Act").(*etensor.Float32)
+	nin := acts.Len() / n
+	targs := make([]float32, 4)
+	dec := &decoder.Linear{}
+	for f := 0; f < ld.K; f++ {
+		st, ed := f*n/ld.K, (f+1)*n/ld.K
+		var trn, tst []int
+		for row := 0; row < n; row++ {
+			if row >= st && row < ed {
+				tst = append(tst, row)
+			} else {
+				trn = append(trn, row)
+			}
+		}
+		dec.Init(len(targs), nin, -1, decoder.IdentityFunc)
+		dec.LRate = ld.LRate
+		dec.Weights.SetZeros()
+		for epc := 0; epc < ld.Epochs; epc++ {
+			for _, pi := range rand.Perm(len(trn)) {
+				row := trn[pi]
+				copy(dec.Inputs, acts.Values[row*nin:(row+1)*nin])
+				dec.Forward()
+				ss.LinDecTargets(row, targs)
+				dec.Train(targs)
+			}
+		}
+		trnpos, trnori := ss.LinDecErrs(dec, trn)
+		pos, ori := ss.LinDecErrs(dec, tst)
+		dt.SetCellString("Fold", f, strconv.Itoa(f))
+		dt.SetCellFloat("NTrain", f, float64(len(trn)))
+		dt.SetCellFloat("NTest", f, float64(len(tst)))
+		dt.SetCellFloat("TrnPosErr", f, trnpos)
+		dt.SetCellFloat("TrnOriErr", f, trnori)
+		dt.SetCellFloat("PosErr", f, pos)
+		dt.SetCellFloat("OriErr", f, ori)
+	}
+	dt.SetCellString("Fold", ld.K, "Mean")
+	for _, cn := range []string{"NTrain", "NTest", "TrnPosErr", "TrnOriErr", "PosErr", "OriErr"} {
+		sum := 0.0
+		for f := 0; f < ld.K; f++ {
+			sum += dt.CellFloat(cn, f)
+		}
+		dt.SetCellFloat(cn, ld.K, sum/float64(ld.K))
+	}
+	return dt
+}
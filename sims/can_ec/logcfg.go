@@ -0,0 +1,196 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// LogConfig configures what is written for one log to log files and the SQLite
+// log: the float precision and which columns are included, to keep large logs
+// (e.g., the trial log) manageable.  Column names can be path.Match patterns,
+// e.g., "*_CosDiff".  The in-memory log tables and plots are not affected.
+type LogConfig struct {
+	Prec    int      `desc:"precision for float values written to log files -- 0 = that of the all config, else LogPrec"`
+	Include []string `desc:"if non-empty, only these columns are written"`
+	Exclude []string `desc:"these columns are not written, even if Included"`
+}
+
+// LogConfigAll is the LogConfigs key for the config used for logs without their own
+const LogConfigAll = "all"
+
+// Keep returns true if given column is written -- true for all if lc is nil
+func (lc *LogConfig) Keep(cnm string) bool {
+	if lc == nil {
+		return true
+	}
+	if len(lc.Include) > 0 && !MatchAny(lc.Include, cnm) {
+		return false
+	}
+	return !MatchAny(lc.Exclude, cnm)
+}
+
+// MatchAny returns true if name matches any of given path.Match patterns
+func MatchAny(pats []string, nm string) bool {
+	for _, pat := range pats {
+		if ok, _ := path.Match(pat, nm); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LogWriter writes the configured columns of a log table to a log file, by way
+// of a one-row table with just those columns and the configured precision
+type LogWriter struct {
+	Cfg  *LogConfig    `desc:"config for this log -- nil = write all columns"`
+	Prec int           `desc:"precision for float values"`
+	Cidx []int         `desc:"indexes of the columns written, in the source table"`
+	Sub  *etable.Table `desc:"one-row table holding the row being written"`
+}
+
+// Config configures the writer for given source table
+func (lw *LogWriter) Config(dt *etable.Table) {
+	var sch etable.Schema
+	lw.Cidx = nil
+	for ci, cl := range dt.Cols {
+		cnm := dt.ColNames[ci]
+		if !lw.Cfg.Keep(cnm) {
+			continue
+		}
+		col := etable.Column{Name: cnm, Type: cl.DataType()}
+		if shp := cl.Shapes(); len(shp) > 1 {
+			col.CellShape = shp[1:]
+			if dn := cl.DimNames(); len(dn) > 1 {
+				col.DimNames = dn[1:]
+			}
+		}
+		sch = append(sch, col)
+		lw.Cidx = append(lw.Cidx, ci)
+	}
+	lw.Sub = etable.New(sch, 1)
+	for k, v := range dt.MetaData {
+		lw.Sub.SetMetaData(k, v)
+	}
+	lw.Sub.SetMetaData("precision", strconv.Itoa(lw.Prec))
+}
+
+// Headers returns the headers line for given source table, without a newline
+func (lw *LogWriter) Headers(dt *etable.Table) string {
+	if lw.Sub == nil {
+		lw.Config(dt)
+	}
+	var b bytes.Buffer
+	lw.Sub.WriteCSVHeaders(&b, etable.Tab)
+	return strings.TrimRight(b.String(), "\r\n")
+}
+
+// WriteRow writes given row of source table dt, preceded by the headers if requested
+func (lw *LogWriter) WriteRow(w io.Writer, dt *etable.Table, row int, headers bool) error {
+	if lw.Sub == nil {
+		lw.Config(dt)
+	}
+	for i, ci := range lw.Cidx {
+		src := dt.Cols[ci]
+		dst := lw.Sub.Cols[i]
+		n := dst.Len()
+		off := row * n
+		for j := 0; j < n; j++ {
+			if src.DataType() == etensor.STRING {
+				dst.SetString1D(j, src.StringVal1D(off+j))
+			} else {
+				dst.SetFloat1D(j, src.FloatVal1D(off+j))
+			}
+		}
+	}
+	if headers {
+		if _, err := lw.Sub.WriteCSVHeaders(w, etable.Tab); err != nil {
+			return err
+		}
+	}
+	return lw.Sub.WriteCSVRow(w, 0, etable.Tab)
+}
+
+// LogCfg returns the LogConfig for given log name, e.g., trn_trl,
+// or the LogConfigAll config if it has none -- nil if neither
+func (ss *Sim) LogCfg(lnm string) *LogConfig {
+	if lc, ok := ss.LogCfgs[lnm]; ok {
+		return lc
+	}
+	return ss.LogCfgs[LogConfigAll]
+}
+
+// LogCfgPrec returns the float precision for given log name: its own Prec if set,
+// else that of the LogConfigAll config if set, else LogPrec
+func (ss *Sim) LogCfgPrec(lnm string) int {
+	for _, nm := range []string{lnm, LogConfigAll} {
+		if lc, ok := ss.LogCfgs[nm]; ok && lc.Prec > 0 {
+			return lc.Prec
+		}
+	}
+	return LogPrec
+}
+
+// WriteLogRow writes given row of log table dt, with name lnm, to log file lf,
+// preceded by the headers if not yet in the file, using the LogConfig for that log
+func (ss *Sim) WriteLogRow(lf *LogFile, lnm string, dt *etable.Table, row int) {
+	if ss.LogWriters == nil {
+		ss.LogWriters = make(map[string]*LogWriter)
+	}
+	lw, ok := ss.LogWriters[lnm]
+	if !ok {
+		lw = &LogWriter{Cfg: ss.LogCfg(lnm), Prec: ss.LogCfgPrec(lnm)}
+		ss.LogWriters[lnm] = lw
+	}
+	headers, err := lf.CheckHeaders(lw.Headers(dt))
+	if err != nil {
+		log.Println(err)
+	}
+	if err := lw.WriteRow(lf, dt, row, headers); err != nil {
+		log.Println(err)
+	}
+}
+
+// OpenLogConfigs loads the LogCfgs from a JSON file with an object of log name:
+// LogConfig pairs, e.g.: {"all": {"Prec": 3}, "trn_trl": {"Exclude": ["*_CosDiff"]}}
+func (ss *Sim) OpenLogConfigs(filename gi.FileName) error {
+	b, err := ioutil.ReadFile(string(filename))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	lcs := make(map[string]*LogConfig)
+	if err = json.Unmarshal(b, &lcs); err != nil {
+		log.Println(err)
+		return err
+	}
+	for lnm, lc := range lcs {
+		ss.LogCfgs[lnm] = lc
+	}
+	ss.LogWriters = nil
+	return nil
+}
+
+// SetLogPrec sets the float precision for all logs that do not set their own
+func (ss *Sim) SetLogPrec(prec int) {
+	all, ok := ss.LogCfgs[LogConfigAll]
+	if !ok {
+		all = &LogConfig{}
+		ss.LogCfgs[LogConfigAll] = all
+	}
+	all.Prec = prec
+	ss.LogWriters = nil
+}
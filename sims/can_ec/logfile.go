@@ -0,0 +1,183 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// LogFile is a log file that is written through a gzip stream if its
+// name ends in .gz, and directly otherwise.  It must be Closed to
+// complete the gzip stream.  When opened for appending to an existing
+// file, e.g., when resuming a run, the existing headers are checked
+// against those of the log at the first write, and are not written again
+// if they match.  Appended gzip data is added as a new gzip member,
+// which gzip readers concatenate.
+type LogFile struct {
+	File       *os.File     `desc:"the underlying file"`
+	Gz         *gzip.Writer `desc:"gzip writer on File, if compressed"`
+	PrevHdrs   string       `desc:"headers line of the existing file when opened for appending -- empty if none"`
+	HasHeaders bool         `desc:"headers have been checked or written"`
+}
+
+// OpenLogFile opens the named log file, gzip compressed if the name ends in .gz.
+// If appnd is true and the file exists, it is appended to, otherwise it is created.
+func OpenLogFile(fnm string, appnd bool) (*LogFile, error) {
+	lf := &LogFile{}
+	if appnd {
+		hdrs, err := ReadLogHeaders(fnm)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		lf.PrevHdrs = hdrs
+	}
+	if err := lf.open(fnm); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+// open opens the file, appending if PrevHdrs is set, and creating otherwise
+func (lf *LogFile) open(fnm string) error {
+	var f *os.File
+	var err error
+	if lf.PrevHdrs != "" {
+		f, err = os.OpenFile(fnm, os.O_WRONLY|os.O_APPEND, 0644)
+	} else {
+		f, err = os.Create(fnm)
+	}
+	if err != nil {
+		return err
+	}
+	lf.File = f
+	lf.Gz = nil
+	if strings.HasSuffix(fnm, ".gz") {
+		lf.Gz = gzip.NewWriter(f)
+	}
+	return nil
+}
+
+// ReadLogHeaders returns the first (headers) line of the named log file,
+// decompressing if the name ends in .gz -- empty if the file is empty
+func ReadLogHeaders(fnm string) (string, error) {
+	f, err := os.Open(fnm)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(fnm, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err == io.EOF {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	ln, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(ln, "\r\n"), nil
+}
+
+// CheckHeaders returns true if the given headers line needs to be written:
+// always for a new file, and never for a file being appended to with the
+// same headers.  If an appended file has different headers, e.g., from a
+// different configuration, it is moved to a .prev file and a new file is
+// started, so the prior rows are not lost or mixed with the new ones.
+func (lf *LogFile) CheckHeaders(hdrs string) (bool, error) {
+	if lf.HasHeaders {
+		return false, nil
+	}
+	lf.HasHeaders = true
+	if lf.PrevHdrs == "" {
+		return true, nil
+	}
+	if lf.PrevHdrs == hdrs {
+		return false, nil
+	}
+	fnm := lf.Name()
+	lf.Close()
+	if err := os.Rename(fnm, fnm+".prev"); err != nil {
+		return false, err
+	}
+	lf.PrevHdrs = ""
+	return true, lf.open(fnm)
+}
+
+// Name returns the name of the file
+func (lf *LogFile) Name() string {
+	return lf.File.Name()
+}
+
+// Write writes to the file, through the gzip stream if compressed
+func (lf *LogFile) Write(b []byte) (int, error) {
+	if lf.Gz != nil {
+		return lf.Gz.Write(b)
+	}
+	return lf.File.Write(b)
+}
+
+// Flush flushes any pending compressed data, so that the file can be read
+// up to this point while it is still being written
+func (lf *LogFile) Flush() error {
+	if lf.Gz != nil {
+		return lf.Gz.Flush()
+	}
+	return nil
+}
+
+// Close completes the gzip stream, if compressed, and closes the file
+func (lf *LogFile) Close() error {
+	if lf.Gz != nil {
+		if err := lf.Gz.Close(); err != nil {
+			lf.File.Close()
+			return err
+		}
+	}
+	return lf.File.Close()
+}
+
+// StreamLogFileName returns the log file name for streamed (row by row) logs,
+// with a .gz suffix if LogGz is set
+func (ss *Sim) StreamLogFileName(lognm string) string {
+	fnm := ss.LogFileName(lognm)
+	if ss.LogGz {
+		fnm += ".gz"
+	}
+	return fnm
+}
+
+// CreateLogFile creates the log file for given log name, returning nil on error.
+// Appends to an existing file if AppendLogs is set.
+func (ss *Sim) CreateLogFile(lognm string) *LogFile {
+	fnm := ss.StreamLogFileName(lognm)
+	f, err := OpenLogFile(fnm, ss.AppendLogs)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	fmt.Printf("Saving %s log to: %v\n", lognm, fnm)
+	return f
+}
+
+// CloseLogFiles closes any open log files
+func (ss *Sim) CloseLogFiles() {
+	for _, f := range []**LogFile{&ss.TrnTrlFile, &ss.TrnEpcFile, &ss.TstEpcFile, &ss.RunFile} {
+		if *f != nil {
+			(*f).Close()
+			*f = nil
+		}
+	}
+}
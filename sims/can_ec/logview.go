@@ -0,0 +1,200 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/goki/gi/gi"
+)
+
+// LogView opens saved log files into the GUI plots for inspection, including
+// multi-hundred-MB trial logs, without freezing the GUI: the file is
+// memory-mapped (or streamed, if gzipped) rather than read into memory,
+// and only every Stride'th data row is parsed, with Stride set automatically
+// from the number of rows so that at most MaxRows rows are plotted.
+type LogView struct {
+	MaxRows int    `def:"20000" min:"100" desc:"maximum number of rows opened from a log file into a plot -- larger files are downsampled to every Nth row"`
+	File    string `inactive:"+" desc:"last log file opened"`
+	NRows   int    `inactive:"+" desc:"number of data rows in the last log file opened"`
+	Stride  int    `inactive:"+" desc:"only every Stride'th row of the last log file opened was plotted"`
+}
+
+// Defaults sets default params
+func (lv *LogView) Defaults() {
+	lv.MaxRows = 20000
+}
+
+// openLogData returns a reader for the named log file, memory-mapped, or
+// decompressed if the name ends in .gz, and a function that closes it
+func openLogData(fnm string) (io.Reader, func(), error) {
+	if !strings.HasSuffix(fnm, ".gz") {
+		data, unmap, err := MapFile(fnm)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bytes.NewReader(data), unmap, nil
+	}
+	f, err := os.Open(fnm)
+	if err != nil {
+		return nil, nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return gz, func() { gz.Close(); f.Close() }, nil
+}
+
+// CountLogRows returns the number of data rows, after the headers line, in r
+func CountLogRows(r io.Reader) (int, error) {
+	buf := make([]byte, 1<<16)
+	n := 0
+	last := byte('\n')
+	for {
+		c, err := r.Read(buf)
+		if c > 0 {
+			n += bytes.Count(buf[:c], []byte{'\n'})
+			last = buf[c-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if last != '\n' {
+		n++
+	}
+	if n > 0 {
+		n-- // headers
+	}
+	return n, nil
+}
+
+// SampleLogRows returns the headers line of r followed by every stride'th
+// data row, starting with the first
+func SampleLogRows(r io.Reader, stride int) ([]byte, error) {
+	var out bytes.Buffer
+	br := bufio.NewReaderSize(r, 1<<16)
+	for ri := -1; ; ri++ { // -1 = headers
+		keep := ri < 0 || ri%stride == 0
+		for {
+			ln, err := br.ReadSlice('\n')
+			if keep {
+				out.Write(ln)
+			}
+			if err == bufio.ErrBufferFull {
+				continue // long line: keep reading it
+			}
+			if err == io.EOF {
+				return out.Bytes(), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+}
+
+// Open opens the named log file (.tsv, or .tsv.gz) into a new table,
+// downsampled to at most MaxRows rows
+func (lv *LogView) Open(fnm string) (*etable.Table, error) {
+	r, cls, err := openLogData(fnm)
+	if err != nil {
+		return nil, err
+	}
+	n, err := CountLogRows(r)
+	cls()
+	if err != nil {
+		return nil, err
+	}
+	stride := 1
+	if lv.MaxRows > 0 && n > lv.MaxRows {
+		stride = (n + lv.MaxRows - 1) / lv.MaxRows
+	}
+	r, cls, err = openLogData(fnm)
+	if err != nil {
+		return nil, err
+	}
+	data, err := SampleLogRows(r, stride)
+	cls()
+	if err != nil {
+		return nil, err
+	}
+	dt := &etable.Table{}
+	if err := dt.ReadCSV(bytes.NewReader(data), etable.Tab); err != nil {
+		return nil, err
+	}
+	lv.File = fnm
+	lv.NRows = n
+	lv.Stride = stride
+	_, nm := filepath.Split(fnm)
+	dt.SetMetaData("name", nm)
+	dt.SetMetaData("desc", fmt.Sprintf("every %d of %d rows of log file: %s", stride, n, fnm))
+	dt.SetMetaData("read-only", "true")
+	return dt, nil
+}
+
+// PlotTab is a plot tab of the GUI, with the live log that it plots
+type PlotTab struct {
+	Plot *eplot.Plot2D `desc:"the plot"`
+	Log  *etable.Table `desc:"the live log plotted, restored by OpenLogPlot with no file"`
+}
+
+// AddPlotTab adds a plot tab of given name to tv, for given live log, and
+// records it in Plots for OpenLogPlot -- called in ConfigGui for all plot tabs
+func (ss *Sim) AddPlotTab(tv *gi.TabView, nm string, dt *etable.Table) *eplot.Plot2D {
+	plt := tv.AddNewTab(eplot.KiT_Plot2D, nm).(*eplot.Plot2D)
+	if ss.Plots == nil {
+		ss.Plots = make(map[string]*PlotTab)
+	}
+	ss.Plots[nm] = &PlotTab{Plot: plt, Log: dt}
+	return plt
+}
+
+// OpenLogPlot opens a saved log file (.tsv, or .tsv.gz) into the plot tab of
+// given name, downsampled to at most LogView.MaxRows rows, for inspection.
+// The plot then shows the file instead of its live log, which is restored
+// by calling with an empty file name.
+func (ss *Sim) OpenLogPlot(filename gi.FileName, plot string) error {
+	pt, ok := ss.Plots[plot]
+	if !ok {
+		var nms []string
+		for nm := range ss.Plots {
+			nms = append(nms, nm)
+		}
+		sort.Strings(nms)
+		return fmt.Errorf("OpenLogPlot: plot %q not found -- available: %s", plot, strings.Join(nms, ", "))
+	}
+	if filename == "" {
+		pt.Plot.SetTable(pt.Log)
+		pt.Plot.Update()
+		return nil
+	}
+	dt, err := ss.LogView.Open(string(filename))
+	if err != nil {
+		return err
+	}
+	pt.Plot.SetTable(dt)
+	pt.Plot.Update()
+	if lv := &ss.LogView; lv.Stride > 1 {
+		fmt.Printf("OpenLogPlot: plotting every %d of %d rows of: %s\n", lv.Stride, lv.NRows, filename)
+	}
+	return nil
+}
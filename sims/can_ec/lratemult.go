@@ -0,0 +1,111 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/emer/emergent/params"
+	"github.com/emer/leabra/leabra"
+)
+
+// LrateMultParam is the only param of the LrateMult params sheet: a multiplier
+// on the Prjn.Learn.Lrate of the selected prjns, e.g., to let the readout
+// decoder prjns into Out_Position and Orientation learn faster than the slow
+// self-organization of EC:
+//
+//	"LrateMult": &params.Sheet{
+//		{Sel: "#Out_Position", Desc: "faster decoding",
+//			Params: params.Params{
+//				"Prjn.LrateMult": "2",
+//			}},
+//	},
+//
+// Sels are as in the Network sheet, with a #Name also selecting all the
+// prjns into the layer of that name.  As with other params, the last Sel that
+// matches a prjn, in the composed Base and current ParamSet, sets its multiplier.
+const LrateMultParam = "Prjn.LrateMult"
+
+// LrateMultMatch returns true if sel selects given prjn for the LrateMult
+// sheet: by prjn name, class or type as usual, or by the name of its
+// receiving layer
+func LrateMultMatch(sel string, pj *leabra.Prjn) bool {
+	if params.SelMatch(sel, pj.Name(), pj.Class(), pj.TypeName(), "Prjn") {
+		return true
+	}
+	return strings.HasPrefix(sel, "#") && sel[1:] == pj.Recv.Name()
+}
+
+// AllPrjns returns all the prjns of the network, by receiving layer
+func (ss *Sim) AllPrjns() []*leabra.Prjn {
+	var pjs []*leabra.Prjn
+	for _, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		for _, pj := range ly.RcvPrjns {
+			pjs = append(pjs, pj.(leabra.LeabraPrjn).AsLeabra())
+		}
+	}
+	return pjs
+}
+
+// LrateMultSets gets the multiplier of each prjn from the LrateMult sheet
+// of the Base and current ParamSet, as composed by ComposeParams, recording
+// them in LrateMults
+func (ss *Sim) LrateMultSets() error {
+	ss.LrateMults = make(map[string]float32)
+	pset, err := ss.ComposeParams()
+	if err != nil {
+		return err
+	}
+	sh, ok := pset.Sheets["LrateMult"]
+	if !ok {
+		return nil
+	}
+	for _, sl := range *sh {
+		for pnm, pv := range sl.Params {
+			if pnm != LrateMultParam {
+				return fmt.Errorf("LrateMult: Sel %s: param %s is not %s", sl.Sel, pnm, LrateMultParam)
+			}
+			mult, err := strconv.ParseFloat(pv, 32)
+			if err != nil {
+				return fmt.Errorf("LrateMult: Sel %s: %v", sl.Sel, err)
+			}
+			for _, pj := range ss.AllPrjns() {
+				if LrateMultMatch(sl.Sel, pj) {
+					ss.LrateMults[pj.Name()] = float32(mult)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyLrateMults multiplies the learning rate of each prjn by its multiplier
+// from the LrateMult sheets, and sets its LrateInit to the result, so that a
+// learning rate schedule through Network.LrateMult modulates it
+// proportionally -- called at the end of SetParams when the Network sheet is
+// set.  This relies on the Prjn Sel of the Base Network sheet setting
+// Prjn.Learn.Lrate for all prjns, so that multipliers are not compounded
+// when SetParams is called again.
+func (ss *Sim) ApplyLrateMults(setMsg bool) {
+	if err := ss.LrateMultSets(); err != nil {
+		log.Println(err)
+		return
+	}
+	for _, pj := range ss.AllPrjns() {
+		mult, ok := ss.LrateMults[pj.Name()]
+		if !ok {
+			continue
+		}
+		pj.Learn.Lrate *= mult
+		pj.Learn.LrateInit = pj.Learn.Lrate
+		if setMsg {
+			fmt.Printf("Prjn: %s Set: %s = %g -> Lrate: %g\n", pj.Name(), LrateMultParam, mult, pj.Learn.Lrate)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package main
+
+import (
+	"io/ioutil"
+)
+
+// MapFile reads the named file, returning its data and a no-op function in
+// place of unmapping it -- memory-mapping is only used on unix systems
+func MapFile(fnm string) ([]byte, func(), error) {
+	data, err := ioutil.ReadFile(fnm)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() {}, nil
+}
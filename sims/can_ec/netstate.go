@@ -0,0 +1,71 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/leabra/leabra"
+)
+
+// NetState is a copy of the activation state of the network: the neurons
+// and pools of every layer, the conduction delay buffers of DelayPrjns, and
+// the Time, so that a test run on the live network (e.g., AttractorShift)
+// can restore it after, leaving training unaffected.  Weights are not saved.
+type NetState struct {
+	Neurons   [][]leabra.Neuron `view:"-" desc:"neurons of each layer"`
+	Pools     [][]leabra.Pool   `view:"-" desc:"pools of each layer"`
+	DelayBufs [][][]float32     `view:"-" desc:"delay buffers of each DelayPrjn, in order of layers and their receiving prjns"`
+	DelayIdxs []int             `view:"-" desc:"delay buffer index of each DelayPrjn"`
+	Time      leabra.Time       `view:"-" desc:"the Sim Time"`
+}
+
+// SaveNetState saves the activation state of the network in ns
+func (ss *Sim) SaveNetState(ns *NetState) {
+	nl := len(ss.Net.Layers)
+	ns.Neurons = make([][]leabra.Neuron, nl)
+	ns.Pools = make([][]leabra.Pool, nl)
+	ns.DelayBufs = ns.DelayBufs[:0]
+	ns.DelayIdxs = ns.DelayIdxs[:0]
+	for li, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		ns.Neurons[li] = append([]leabra.Neuron(nil), ly.Neurons...)
+		ns.Pools[li] = append([]leabra.Pool(nil), ly.Pools...)
+		for _, pj := range ly.RcvPrjns {
+			dp, ok := pj.(*DelayPrjn)
+			if !ok {
+				continue
+			}
+			buf := make([][]float32, len(dp.DelayBuf))
+			for i, b := range dp.DelayBuf {
+				buf[i] = append([]float32(nil), b...)
+			}
+			ns.DelayBufs = append(ns.DelayBufs, buf)
+			ns.DelayIdxs = append(ns.DelayIdxs, dp.DelayIdx)
+		}
+	}
+	ns.Time = ss.Time
+}
+
+// RestoreNetState restores the activation state of the network saved in ns
+// by SaveNetState
+func (ss *Sim) RestoreNetState(ns *NetState) {
+	di := 0
+	for li, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		copy(ly.Neurons, ns.Neurons[li])
+		copy(ly.Pools, ns.Pools[li])
+		for _, pj := range ly.RcvPrjns {
+			dp, ok := pj.(*DelayPrjn)
+			if !ok {
+				continue
+			}
+			for i, b := range ns.DelayBufs[di] {
+				copy(dp.DelayBuf[i], b)
+			}
+			dp.DelayIdx = ns.DelayIdxs[di]
+			di++
+		}
+	}
+	ss.Time = ns.Time
+}
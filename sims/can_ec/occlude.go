@@ -0,0 +1,231 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// OcclLayers are the sensory input layers blanked by an OcclBlock that does not
+// list its own Layers: the allothetic position, heading and proximity inputs,
+// leaving only the Vestibular self-motion input for path integration
+var OcclLayers = []string{"Prev_Position", "Prev_Orientation", "S1"}
+
+// OcclBlock is one block of training trials with a dark sector of the room:
+// on trials where the heading is within the sector, the inputs of Layers are
+// blanked
+type OcclBlock struct {
+	Epoch  int      `desc:"training epoch at the start of which the block starts"`
+	Trials int      `desc:"number of trials in the block -- 0 = rest of the run"`
+	MinAng int      `desc:"start of the dark sector of headings, in degrees"`
+	MaxAng int      `desc:"end of the dark sector of headings, in degrees, counter-clockwise from MinAng -- the sector can wrap through 0, and includes both ends"`
+	Layers []string `desc:"input layers blanked in the dark sector -- empty = OcclLayers"`
+}
+
+// InSector returns true if given heading, in degrees, is within the dark sector
+func (ob *OcclBlock) InSector(ang int) bool {
+	return AngMod(ang-ob.MinAng) <= AngMod(ob.MaxAng-ob.MinAng)
+}
+
+// Blanks returns true if given layer is blanked by the block
+func (ob *OcclBlock) Blanks(lnm string) bool {
+	lays := ob.Layers
+	if len(lays) == 0 {
+		lays = OcclLayers
+	}
+	for _, l := range lays {
+		if l == lnm {
+			return true
+		}
+	}
+	return false
+}
+
+// Occlusion implements heading-dependent sensory occlusion experiments: for
+// each OcclBlock, the visual and proximity inputs are blanked while the heading
+// is within the block's dark sector, so the network must rely on path
+// integration of the Vestibular input there.  The OcclLog compares position and
+// heading decoding errors on dark and light trials, per epoch and block.
+type Occlusion struct {
+	Blocks []OcclBlock `desc:"occlusion schedule -- if empty, inputs are never occluded and nothing is logged"`
+	File   string      `desc:"protocol file that Blocks were loaded from"`
+
+	Block int                         `inactive:"+" desc:"index of current block, -1 = none"`
+	Dark  bool                        `inactive:"+" desc:"heading is in the dark sector of the current block on the current trial"`
+	Pats  map[string]*etensor.Float32 `view:"-" desc:"blank input patterns, by layer"`
+
+	AccBlock int        `view:"-" desc:"block that the accumulators are for"`
+	N        [2]int     `view:"-" desc:"number of light, dark trials accumulated"`
+	SumPos   [2]float64 `view:"-" desc:"sum of PosErr on light, dark trials"`
+	SumOri   [2]float64 `view:"-" desc:"sum of OriErr on light, dark trials"`
+}
+
+// Reset resets all state -- called at start of a new run
+func (oc *Occlusion) Reset() {
+	oc.Block = -1
+	oc.Dark = false
+	oc.ResetAcc()
+}
+
+// ResetAcc resets the accumulators, for the current block
+func (oc *Occlusion) ResetAcc() {
+	oc.AccBlock = oc.Block
+	oc.N = [2]int{}
+	oc.SumPos = [2]float64{}
+	oc.SumOri = [2]float64{}
+}
+
+// SetBlock sets the current Block for given training epoch and trial within
+// the epoch, with ntrls trials per epoch, and Dark for given heading.
+// Later blocks take precedence.
+func (oc *Occlusion) SetBlock(epc, trl, ntrls, ang int) {
+	oc.Block = CurBlock(len(oc.Blocks), epc, trl, ntrls, func(bi int) (int, int) {
+		return oc.Blocks[bi].Epoch, oc.Blocks[bi].Trials
+	})
+	oc.Dark = oc.Block >= 0 && oc.Blocks[oc.Block].InSector(ang)
+}
+
+// OpenOcclusion loads the occlusion schedule from a JSON protocol file with a
+// list of blocks, e.g.: [{"Epoch": 100, "MinAng": 90, "MaxAng": 180}]
+func (ss *Sim) OpenOcclusion(filename gi.FileName) error {
+	b, err := ioutil.ReadFile(string(filename))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	var blks []OcclBlock
+	if err = json.Unmarshal(b, &blks); err != nil {
+		log.Println(err)
+		return err
+	}
+	ss.Occlusion.Blocks = blks
+	ss.Occlusion.File = string(filename)
+	return nil
+}
+
+// OcclTrial sets the current block, and whether the current heading is in its
+// dark sector -- called at the start of ApplyInputs
+func (ss *Sim) OcclTrial() {
+	oc := &ss.Occlusion
+	if len(oc.Blocks) == 0 {
+		return
+	}
+	ev := &ss.TrainEnv
+	oc.SetBlock(ev.Epoch.Cur, ev.Trial.Cur, ev.Trial.Max, ev.Angle)
+}
+
+// OcclPats returns the input pattern to apply to given layer: a blank pattern
+// if the current heading is in the dark sector and the layer is blanked by the
+// current block, and pats unchanged otherwise -- called in ApplyStatePats
+func (ss *Sim) OcclPats(lnm string, pats etensor.Tensor) etensor.Tensor {
+	oc := &ss.Occlusion
+	if len(oc.Blocks) == 0 || !oc.Dark || !oc.Blocks[oc.Block].Blanks(lnm) {
+		return pats
+	}
+	if oc.Pats == nil {
+		oc.Pats = make(map[string]*etensor.Float32)
+	}
+	bp, has := oc.Pats[lnm]
+	if !has {
+		bp = etensor.NewFloat32(pats.Shapes(), nil, nil)
+		oc.Pats[lnm] = bp
+	}
+	return bp
+}
+
+// OcclTrialStats accumulates the decoding errors from given row of the
+// TrnTrlLog on dark or light trials, logging the prior block's stats if the
+// block has changed -- called in LogTrnTrl
+func (ss *Sim) OcclTrialStats(dt *etable.Table, row int) {
+	oc := &ss.Occlusion
+	if len(oc.Blocks) == 0 {
+		return
+	}
+	if oc.Block != oc.AccBlock {
+		ss.LogOccl(ss.OcclLog, ss.TrainEnv.Epoch.Cur)
+		oc.ResetAcc()
+	}
+	di := 0
+	if oc.Dark {
+		di = 1
+	}
+	oc.N[di]++
+	oc.SumPos[di] += dt.CellFloat("PosErr", row)
+	oc.SumOri[di] += dt.CellFloat("OriErr", row)
+}
+
+// LogOccl adds a row for the accumulated stats for given epoch, if any trials
+// were accumulated -- called in OcclTrialStats and LogTrnEpc
+func (ss *Sim) LogOccl(dt *etable.Table, epc int) {
+	oc := &ss.Occlusion
+	if len(oc.Blocks) == 0 || oc.N[0]+oc.N[1] == 0 {
+		return
+	}
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(epc))
+	dt.SetCellFloat("Block", row, float64(oc.AccBlock))
+	for di, pfx := range []string{"Light", "Dark"} {
+		dt.SetCellFloat(pfx+"N", row, float64(oc.N[di]))
+		if oc.N[di] > 0 {
+			dt.SetCellFloat(pfx+"PosErr", row, oc.SumPos[di]/float64(oc.N[di]))
+			dt.SetCellFloat(pfx+"OriErr", row, oc.SumOri[di]/float64(oc.N[di]))
+		}
+	}
+	ss.SQLWriteRow("occl", dt, row)
+
+	ss.UpdtPlot(ss.OcclPlot)
+}
+
+// LogOcclEpc logs the stats for the last epoch and resets the accumulators --
+// called in LogTrnEpc
+func (ss *Sim) LogOcclEpc() {
+	ss.LogOccl(ss.OcclLog, ss.TrainEnv.Epoch.Prv)
+	ss.Occlusion.ResetAcc()
+}
+
+func (ss *Sim) ConfigOcclLog(dt *etable.Table) {
+	dt.SetMetaData("name", "OcclLog")
+	dt.SetMetaData("desc", "Decoding errors on dark vs. light trials under heading-dependent sensory occlusion, per epoch and block")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Block", etensor.INT64, nil, nil},
+	}
+	for _, pfx := range []string{"Light", "Dark"} {
+		sch = append(sch, etable.Column{pfx + "N", etensor.INT64, nil, nil})
+		sch = append(sch, etable.Column{pfx + "PosErr", etensor.FLOAT64, nil, nil})
+		sch = append(sch, etable.Column{pfx + "OriErr", etensor.FLOAT64, nil, nil})
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigOcclPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Occlusion Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Block", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	for _, pfx := range []string{"Light", "Dark"} {
+		plt.SetColParams(pfx+"N", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+		plt.SetColParams(pfx+"PosErr", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+		plt.SetColParams(pfx+"OriErr", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	}
+	return plt
+}
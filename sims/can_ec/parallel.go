@@ -0,0 +1,162 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CopyArgs copies the settings made by command-line args in CmdArgs from src,
+// for running additional Sims with the same configuration
+func (ss *Sim) CopyArgs(src *Sim) {
+	ss.ParamSet = src.ParamSet
+	ss.Tag = src.Tag
+	ss.MaxRuns = src.MaxRuns
+	ss.SaveWts = src.SaveWts
+	ss.CkptEval.Every = src.CkptEval.Every
+	ss.SaveARFs = src.SaveARFs
+	ss.SaveSD = src.SaveSD
+	ss.InitSD = src.InitSD
+	ss.SDNames = src.SDNames
+	ss.Analyze = src.Analyze
+	ss.Report.On = src.Report.On
+	ss.ParamAudit.On = src.ParamAudit.On
+	ss.ExecHook.Cmd = src.ExecHook.Cmd
+	ss.ExecHook.Every = src.ExecHook.Every
+	ss.Watchdog.On = src.Watchdog.On
+	ss.Watchdog.MaxGe = src.Watchdog.MaxGe
+	ss.LogCfgs = src.LogCfgs
+	ss.LogGz = src.LogGz
+	ss.AppendLogs = src.AppendLogs
+	ss.S1On = src.S1On
+	ss.GoalOn = src.GoalOn
+	ss.ActOn = src.ActOn
+	ss.Inputs = append([]InputMap(nil), src.Inputs...)
+	ss.InputsSet = src.InputsSet
+	ss.StrictInputs = src.StrictInputs
+	ss.PatsFile = src.PatsFile
+	ss.Conj = src.Conj
+	ss.UnitClass.Periodic = src.UnitClass.Periodic
+	ss.UnitGroups.Groups = src.UnitGroups.Groups
+	ss.UnitGroups.Each = src.UnitGroups.Each
+	ss.UnitGroups.ARFs = src.UnitGroups.ARFs
+	ss.WtChg.On = src.WtChg.On
+	ss.Border.Periodic = src.Border.Periodic
+	ss.Border.NShuffle = src.Border.NShuffle
+	ss.Speed.Periodic = src.Speed.Periodic
+	ss.ActExport.On = src.ActExport.On
+	ss.ActExport.Layers = src.ActExport.Layers
+	ss.Traj.Save = src.Traj.Save
+	ss.Traj.File = src.Traj.File
+	ss.Traj.Steps = src.Traj.Steps
+	ss.ActStats.MaxFrac = src.ActStats.MaxFrac
+	ss.LatDiag.On = src.LatDiag.On
+	ss.TrainEnv.Preset = src.TrainEnv.Preset
+	ss.TrainEnv.Size = src.TrainEnv.Size
+	ss.TrainEnv.AngInc = src.TrainEnv.AngInc
+	ss.TrainEnv.Stuck = src.TrainEnv.Stuck
+	ss.TrainEnv.Scan = src.TrainEnv.Scan
+	ss.TestWorld = src.TestWorld
+	ss.RandWorld.On = src.RandWorld.On
+	ss.RandWorld.NWalls = src.RandWorld.NWalls
+	ss.RandWorld.MaxLen = src.RandWorld.MaxLen
+	ss.RandWorld.EvalFile = src.RandWorld.EvalFile
+	ss.RandWorld.EvalSeed = src.RandWorld.EvalSeed
+	ss.Adapt.On = src.Adapt.On
+	ss.Adapt.LoErr = src.Adapt.LoErr
+	ss.Adapt.HiErr = src.Adapt.HiErr
+	ss.Adapt.Window = src.Adapt.Window
+	ss.Adapt.MaxWalls = src.Adapt.MaxWalls
+	ss.Choice.On = src.Choice.On
+	ss.MinusQtrs = src.MinusQtrs
+	ss.PlusQtrs = src.PlusQtrs
+	ss.CycPerQtr = src.CycPerQtr
+	ss.TrialSteps = src.TrialSteps
+	ss.ITI.Cycles = src.ITI.Cycles
+	ss.ITI.Decay = src.ITI.Decay
+	ss.ClampScheds = append([]ClampSched(nil), src.ClampScheds...)
+	ss.SupFrac = src.SupFrac
+	ss.SelfLoc.On = src.SelfLoc.On
+	ss.SelfLoc.ResetInt = src.SelfLoc.ResetInt
+	ss.Shuffle.On = src.Shuffle.On
+	ss.Shuffle.BufSize = src.Shuffle.BufSize
+	ss.VestibGain.Blocks = src.VestibGain.Blocks
+	ss.VestibGain.File = src.VestibGain.File
+	ss.Occlusion.Blocks = src.Occlusion.Blocks
+	ss.Occlusion.File = src.Occlusion.File
+	ss.Arena.Blocks = src.Arena.Blocks
+	ss.Arena.File = src.Arena.File
+	ss.Protocol = src.Protocol
+	ss.Protocol.CuePats = nil
+	ss.Protocol.PreLearn = nil
+	ss.Task.ForageDist = src.Task.ForageDist
+	ss.LinDec.On = src.LinDec.On
+	ss.LinDec.K = src.LinDec.K
+	ss.AttrShift = src.AttrShift
+	ss.SR.On = src.SR.On
+	ss.SR.Save = src.SR.Save
+	ss.Entorhinal.InPCon = src.Entorhinal.InPCon
+	ss.Entorhinal.ECSize = src.Entorhinal.ECSize
+	ss.Entorhinal.GTauVar = src.Entorhinal.GTauVar
+	ss.Entorhinal.GTauPerPool = src.Entorhinal.GTauPerPool
+	ss.Entorhinal.Modules = src.Entorhinal.Modules
+	ss.Entorhinal.TwistTorus = src.Entorhinal.TwistTorus
+	ss.Entorhinal.Dale = src.Entorhinal.Dale
+	ss.Entorhinal.EC2D = src.Entorhinal.EC2D
+	ss.Entorhinal.ExcitLateral = src.Entorhinal.ExcitLateral
+	ss.Entorhinal.KernelScale = src.Entorhinal.KernelScale
+	ss.Entorhinal.KernelRef = src.Entorhinal.KernelRef
+}
+
+// RunParallel runs n independent Sims, each with its own network, env and
+// random seed (RndSeed + i), concurrently in separate goroutines, in nogui mode.
+// Each Sim's Tag gets an s<seed> suffix so its logs, weights and ARFs are saved
+// to separate files.  All Sims share the global math/rand source, so each run
+// is independent but not exactly reproducible from its seed, unlike a serial run.
+func (ss *Sim) RunParallel(n int, saveTrlLog, saveEpcLog, saveRunLog bool) {
+	fmt.Printf("Running %d Sims in parallel, %d Runs each\n", n, ss.MaxRuns)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sm := &Sim{}
+		sm.New()
+		sm.CopyArgs(ss)
+		sm.NoGui = true
+		if i > 0 {
+			sm.Traj.Save = "" // saved by the first sim only
+		}
+		sm.RndSeed = ss.RndSeed + int64(i)
+		sm.Tag = fmt.Sprintf("s%d", sm.RndSeed)
+		if ss.Tag != "" {
+			sm.Tag = ss.Tag + "_" + sm.Tag
+		}
+		wg.Add(1)
+		go func(sm *Sim) {
+			defer wg.Done()
+			sm.Config()
+			sm.Init()
+			sm.OpenLogFiles(saveTrlLog, saveEpcLog, saveRunLog)
+			defer sm.CloseLogFiles()
+			sm.Train()
+			fmt.Printf("Sim: %s done\n", sm.Tag)
+		}(sm)
+	}
+	wg.Wait()
+}
+
+// OpenLogFiles opens the trial, epoch and run log files that are saved --
+// called in CmdArgs and for each sim of RunParallel
+func (ss *Sim) OpenLogFiles(saveTrlLog, saveEpcLog, saveRunLog bool) {
+	if saveTrlLog {
+		ss.TrnTrlFile = ss.CreateLogFile("trn_trl")
+	}
+	if saveEpcLog {
+		ss.TrnEpcFile = ss.CreateLogFile("trn_epc")
+		ss.TstEpcFile = ss.CreateLogFile("tst_epc")
+	}
+	if saveRunLog {
+		ss.RunFile = ss.CreateLogFile("run")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/ccnlab/map-nav/sims/paramcomp"
+	"github.com/emer/emergent/params"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/kit"
+)
+
+// ParamAudit records every application of a param by SetParams into the Log
+// table: the set (within the composed set), sheet and selector it came from,
+// the object it was applied to, and its value before and after -- a more
+// complete record than the setMsg console prints, for auditing param sheets.
+// When a later selector in the same sheet sets the same param on the same
+// object, the earlier row is marked as Overridden, and its New is the value it
+// set.  The Log is cleared at the start of each full SetParams, and saved to
+// the params_audit log file after it.
+type ParamAudit struct {
+	On  bool          `desc:"record every param application by SetParams"`
+	Log *etable.Table `view:"no-inline" desc:"record of param applications"`
+
+	pending []paramAuditRow
+	last    map[string]int
+}
+
+// paramAuditRow is a Log row waiting for its New value
+type paramAuditRow struct {
+	row  int
+	obj  interface{}
+	path string
+	key  string
+}
+
+// Defaults sets default params
+func (pa *ParamAudit) Defaults() {
+	pa.Log = &etable.Table{}
+	ConfigParamAuditLog(pa.Log)
+}
+
+// Reset clears the Log -- called at the start of a full SetParams
+func (pa *ParamAudit) Reset() {
+	pa.Log.SetNumRows(0)
+}
+
+func ConfigParamAuditLog(dt *etable.Table) {
+	dt.SetMetaData("name", "ParamAudit")
+	dt.SetMetaData("desc", "Record of every param applied by SetParams")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Set", etensor.STRING, nil, nil},
+		{"Sheet", etensor.STRING, nil, nil},
+		{"Sel", etensor.STRING, nil, nil},
+		{"Object", etensor.STRING, nil, nil},
+		{"Type", etensor.STRING, nil, nil},
+		{"Param", etensor.STRING, nil, nil},
+		{"Old", etensor.STRING, nil, nil},
+		{"New", etensor.STRING, nil, nil},
+		{"Overridden", etensor.INT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+// ParamValString returns the current value of the param at given path on obj
+// as a string, or an empty string if not found
+func ParamValString(obj interface{}, path string) string {
+	fld, err := params.FindParam(reflect.ValueOf(obj), path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprint(kit.NonPtrValue(fld).Interface())
+}
+
+// paramObjName returns the name and type of a params target object
+func paramObjName(obj interface{}) (string, string) {
+	if stylr, has := obj.(params.Styler); has {
+		return stylr.Name(), stylr.TypeName()
+	}
+	tnm := kit.NonPtrType(reflect.TypeOf(obj)).Name()
+	return tnm, tnm
+}
+
+// Begin records the params of given sheet that apply to each of objs, in the
+// order they are applied, with their current values as Old -- call before
+// applying the sheet, and End after
+func (pa *ParamAudit) Begin(setNm, shtNm string, sht *params.Sheet, objs []interface{}) {
+	pa.pending = pa.pending[:0]
+	pa.last = make(map[string]int)
+	dt := pa.Log
+	for _, obj := range objs {
+		onm, otyp := paramObjName(obj)
+		for _, sel := range *sht {
+			if !sel.TargetTypeMatch(obj) || !sel.SelMatch(obj) {
+				continue
+			}
+			var pnms []string
+			for pnm := range sel.Params {
+				pnms = append(pnms, pnm)
+			}
+			sort.Strings(pnms)
+			for _, pnm := range pnms {
+				path := sel.Params.Path(pnm)
+				key := onm + ":" + path
+				old := ""
+				if pi, has := pa.last[key]; has {
+					prv := &pa.pending[pi]
+					dt.SetCellFloat("Overridden", prv.row, 1)
+					old = dt.CellString("New", prv.row)
+				} else {
+					old = ParamValString(obj, path)
+				}
+				row := dt.Rows
+				dt.SetNumRows(row + 1)
+				dt.SetCellString("Set", row, paramcomp.SelSet(sel, setNm))
+				dt.SetCellString("Sheet", row, shtNm)
+				dt.SetCellString("Sel", row, sel.Sel)
+				dt.SetCellString("Object", row, onm)
+				dt.SetCellString("Type", row, otyp)
+				dt.SetCellString("Param", row, pnm)
+				dt.SetCellString("Old", row, old)
+				dt.SetCellString("New", row, sel.Params[pnm])
+				pa.last[key] = len(pa.pending)
+				pa.pending = append(pa.pending, paramAuditRow{row: row, obj: obj, path: path, key: key})
+			}
+		}
+	}
+}
+
+// End records the New values of the params recorded by Begin, after the
+// sheet has been applied
+func (pa *ParamAudit) End() {
+	dt := pa.Log
+	for i, pr := range pa.pending {
+		if pa.last[pr.key] != i {
+			continue // overridden: New is the value it set
+		}
+		dt.SetCellString("New", pr.row, ParamValString(pr.obj, pr.path))
+	}
+	pa.pending = pa.pending[:0]
+}
+
+// ParamAuditNetObjs returns the params target objects of the network:
+// each layer followed by its receiving prjns, in the order ApplyParams uses
+func (ss *Sim) ParamAuditNetObjs() []interface{} {
+	var objs []interface{}
+	for _, lyi := range ss.Net.Layers {
+		objs = append(objs, lyi)
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		for _, pji := range ly.RcvPrjns {
+			objs = append(objs, pji)
+		}
+	}
+	return objs
+}
+
+// SaveParamAudit saves the ParamAudit Log to the params_audit log file
+func (ss *Sim) SaveParamAudit() {
+	fnm := ss.LogFileName("params_audit")
+	ss.ParamAudit.Log.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+}
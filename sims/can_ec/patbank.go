@@ -0,0 +1,224 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/etview"
+	"github.com/goki/gi/gi"
+	"github.com/goki/gi/giv"
+)
+
+// PatBankInfo is the identity of a bank of env bit patterns (the Pats of
+// materials and actions): changing the patterns silently changes the task,
+// so banks are identified by a hash of their patterns, and versioned, with
+// each saved version recording the hash of the one it was derived from.
+type PatBankInfo struct {
+	Version int    `desc:"version of the bank, incremented each time a changed bank is saved -- 0 for plain pattern files"`
+	Hash    string `desc:"hash of the patterns of the bank: its identity"`
+	Parent  string `desc:"hash of the bank this version was derived from -- empty for the first"`
+	File    string `json:"-" desc:"file the bank was last opened from or saved to"`
+}
+
+// PatBank is a versioned bank of env patterns, as saved by SavePatBank
+type PatBank struct {
+	PatBankInfo
+	Pats map[string]*etensor.Float32 `desc:"the patterns, by material or action name"`
+}
+
+// HashPats returns the hash of given patterns, from their names, shapes and
+// values, independent of the order of the map
+func HashPats(pats map[string]*etensor.Float32) string {
+	nms := make([]string, 0, len(pats))
+	for nm := range pats {
+		nms = append(nms, nm)
+	}
+	sort.Strings(nms)
+	var b strings.Builder
+	for _, nm := range nms {
+		pt := pats[nm]
+		fmt.Fprintf(&b, "%s%v:", nm, pt.Shapes())
+		for _, v := range pt.Values {
+			b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+			b.WriteByte(',')
+		}
+		b.WriteByte(';')
+	}
+	return HashString(b.String())
+}
+
+// ReadPatBank reads the patterns of given file into pats, replacing those of
+// the same name, from either a PatBank or a plain map of patterns as saved
+// by SavePats, returning the identity of the bank.  It is reported if the
+// patterns of a bank do not match its hash, e.g., if it was edited by hand.
+func ReadPatBank(fnm string, pats map[string]*etensor.Float32) (PatBankInfo, error) {
+	var pi PatBankInfo
+	b, err := ioutil.ReadFile(fnm)
+	if err != nil {
+		return pi, err
+	}
+	var pb struct {
+		PatBankInfo
+		Pats json.RawMessage
+	}
+	if err := json.Unmarshal(b, &pb); err == nil && len(pb.Pats) > 0 {
+		if err := json.Unmarshal(pb.Pats, &pats); err != nil {
+			return pi, err
+		}
+		pi = pb.PatBankInfo
+		var own map[string]*etensor.Float32
+		if err := json.Unmarshal(pb.Pats, &own); err == nil && HashPats(own) != pi.Hash {
+			log.Printf("PatBank: patterns of %s do not match its hash %s -- edited?\n", fnm, pi.Hash)
+		}
+	} else {
+		if err := json.Unmarshal(b, &pats); err != nil {
+			return pi, err
+		}
+		pi.Hash = HashPats(pats)
+	}
+	pi.File = fnm
+	return pi, nil
+}
+
+// PatsHash returns the hash of the current Pats
+func (ev *XYHDEnv) PatsHash() string {
+	return HashPats(ev.Pats)
+}
+
+// SavePatBank saves the Pats as a versioned pattern bank: if they changed
+// since they were opened or last saved, the version is incremented, with
+// the previous hash as the parent
+func (ev *XYHDEnv) SavePatBank(filename gi.FileName) error {
+	pi := &ev.PatBank
+	if h := ev.PatsHash(); h != pi.Hash {
+		pi.Version++
+		pi.Parent = pi.Hash
+		pi.Hash = h
+	}
+	jenc, err := json.MarshalIndent(PatBank{PatBankInfo: *pi, Pats: ev.Pats}, "", " ")
+	if err != nil {
+		return err
+	}
+	pi.File = string(filename)
+	return ioutil.WriteFile(string(filename), jenc, 0644)
+}
+
+// DiffPats compares the patterns of banks a and b, returning a table with a
+// row per pattern name in either, with the number of values that differ,
+// and their maximum absolute difference
+func DiffPats(a, b map[string]*etensor.Float32) *etable.Table {
+	dt := &etable.Table{}
+	ConfigPatsDiff(dt)
+	has := make(map[string]bool)
+	var nms []string
+	for _, ps := range []map[string]*etensor.Float32{a, b} {
+		for nm := range ps {
+			if !has[nm] {
+				has[nm] = true
+				nms = append(nms, nm)
+			}
+		}
+	}
+	sort.Strings(nms)
+	for _, nm := range nms {
+		pa, ina := a[nm]
+		pb, inb := b[nm]
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellString("Name", row, nm)
+		dt.SetCellFloat("InA", row, b2f(ina))
+		dt.SetCellFloat("InB", row, b2f(inb))
+		if !ina || !inb {
+			continue
+		}
+		if pa.Len() != pb.Len() {
+			nd := pa.Len()
+			if pb.Len() > nd {
+				nd = pb.Len()
+			}
+			dt.SetCellFloat("NDiff", row, float64(nd)) // all differ
+			dt.SetCellFloat("MaxDiff", row, math.NaN())
+			continue
+		}
+		nd := 0
+		mx := 0.0
+		for i, va := range pa.Values {
+			d := math.Abs(float64(pb.Values[i] - va))
+			if d > 0 {
+				nd++
+			}
+			mx = math.Max(mx, d)
+		}
+		dt.SetCellFloat("NDiff", row, float64(nd))
+		dt.SetCellFloat("MaxDiff", row, mx)
+	}
+	return dt
+}
+
+// b2f returns 1 for true, 0 for false
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// DiffPatBanks compares the patterns of two pattern bank (or plain pattern)
+// files, saving the table of differences, and viewing it in the GUI
+func (ss *Sim) DiffPatBanks(fileA, fileB gi.FileName) {
+	pa := make(map[string]*etensor.Float32)
+	pb := make(map[string]*etensor.Float32)
+	ia, err := ReadPatBank(string(fileA), pa)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	ib, err := ReadPatBank(string(fileB), pb)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	dt := DiffPats(pa, pb)
+	nd := 0
+	for ri := 0; ri < dt.Rows; ri++ {
+		if dt.CellFloat("InA", ri) == 0 || dt.CellFloat("InB", ri) == 0 || dt.CellFloat("NDiff", ri) > 0 {
+			nd++
+		}
+	}
+	prompt := fmt.Sprintf("A: %s v%d %s  B: %s v%d %s: %d of %d patterns differ", fileA, ia.Version, ia.Hash, fileB, ib.Version, ib.Hash, nd, dt.Rows)
+	fmt.Printf("PatsDiff: %s\n", prompt)
+	fnm := ss.LogFileName("patsdiff")
+	dt.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+	if ss.Win == nil {
+		return
+	}
+	etview.TableViewDialog(ss.Win.Viewport, dt, giv.DlgOpts{Title: "Pats Diff", Prompt: prompt, TmpSave: nil}, nil, nil)
+}
+
+func ConfigPatsDiff(dt *etable.Table) {
+	dt.SetMetaData("name", "PatsDiff")
+	dt.SetMetaData("desc", "Comparison of the env bit patterns of two pattern banks A and B")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+		{"InA", etensor.INT64, nil, nil},
+		{"InB", etensor.INT64, nil, nil},
+		{"NDiff", etensor.INT64, nil, nil},
+		{"MaxDiff", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
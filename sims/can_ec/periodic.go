@@ -0,0 +1,130 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// Periodic is the schedule of an analysis of the units of some layers that
+// is run every Every training epochs, from the data recorded over the
+// training trials since the last time, and logged over training -- embedded
+// in the params of each PeriodicAnalysis
+type Periodic struct {
+	Every  int      `min:"0" desc:"run the analysis every this many training epochs, over the training trials since the last time -- 0 = off"`
+	Layers []string `desc:"layers whose units are analyzed -- empty = the ARFLayers"`
+}
+
+// FlagVar defines the command-line flag setting Every, with given name,
+// and usage describing what the analysis does
+func (pd *Periodic) FlagVar(name, usage string) {
+	flag.IntVar(&pd.Every, name, 0, usage+" every this many training epochs, logging the results over training -- 0 = off")
+}
+
+// PeriodicAnalysis is an analysis that is run over training on a Periodic
+// schedule, logging to its own log, which is saved at the end of each run
+// and written to the SQLite log, with the Name of the analysis
+type PeriodicAnalysis struct {
+	Name  string                                         `desc:"name of the analysis, used for its log file and SQLite table"`
+	Sched *Periodic                                      `desc:"schedule of the analysis"`
+	Log   *etable.Table                                  `desc:"log that Epoch adds rows to"`
+	Trial func(lays []string)                            `desc:"records the data of the current training trial"`
+	Epoch func(dt *etable.Table, epc int, lays []string) `desc:"runs the analysis over the trials recorded since the last time, adding its rows to dt, if there are any trials"`
+	Reset func()                                         `desc:"clears the recorded trials"`
+}
+
+// PeriodicAnalyses returns the analyses run over training on a Periodic
+// schedule
+func (ss *Sim) PeriodicAnalyses() []PeriodicAnalysis {
+	return []PeriodicAnalysis{
+		{Name: "unitclass", Sched: &ss.UnitClass.Periodic, Log: ss.UnitClassLog, Trial: ss.UnitClassTrial, Epoch: ss.UnitClassEpoch, Reset: ss.UnitClass.RFs.Reset},
+		{Name: "border", Sched: &ss.Border.Periodic, Log: ss.BorderLog, Trial: ss.BorderTrial, Epoch: ss.BorderEpoch, Reset: ss.Border.RFs.Reset},
+		{Name: "speed", Sched: &ss.Speed.Periodic, Log: ss.SpeedLog, Trial: ss.SpeedTrial, Epoch: ss.SpeedEpoch, Reset: ss.Speed.Reset},
+	}
+}
+
+// PeriodicLayers returns the layers analyzed on given schedule
+func (ss *Sim) PeriodicLayers(pd *Periodic) []string {
+	if len(pd.Layers) > 0 {
+		return pd.Layers
+	}
+	return ss.ARFLayers
+}
+
+// PeriodicTrial records the current training trial for the periodic
+// analyses that are on -- called in LogTrnTrl
+func (ss *Sim) PeriodicTrial() {
+	for _, pa := range ss.PeriodicAnalyses() {
+		if pa.Sched.Every > 0 {
+			pa.Trial(ss.PeriodicLayers(pa.Sched))
+		}
+	}
+}
+
+// PeriodicEpoch runs the periodic analyses that are due at given training
+// epoch, writing their new log rows to the SQLite log, and clears their
+// recorded trials -- called at the start of each training epoch
+func (ss *Sim) PeriodicEpoch(epc int) {
+	for _, pa := range ss.PeriodicAnalyses() {
+		pd := pa.Sched
+		if pd.Every <= 0 || epc%pd.Every != 0 {
+			continue
+		}
+		st := pa.Log.Rows
+		pa.Epoch(pa.Log, epc, ss.PeriodicLayers(pd))
+		for row := st; row < pa.Log.Rows; row++ {
+			ss.SQLWriteRow(pa.Name, pa.Log, row)
+		}
+		pa.Reset()
+	}
+}
+
+// ResetPeriodic clears the logs and recorded trials of the periodic
+// analyses -- called in NewRun
+func (ss *Sim) ResetPeriodic() {
+	for _, pa := range ss.PeriodicAnalyses() {
+		pa.Log.SetNumRows(0)
+		pa.Reset()
+	}
+}
+
+// SavePeriodicLogs saves the logs of the run of the periodic analyses that
+// are on, if any -- called in RunEnd
+func (ss *Sim) SavePeriodicLogs() {
+	for _, pa := range ss.PeriodicAnalyses() {
+		if pa.Sched.Every <= 0 || pa.Log.Rows == 0 {
+			continue
+		}
+		fnm := ss.LogFileName(fmt.Sprintf("%s_run%03d", pa.Name, ss.TrainEnv.Run.Cur))
+		pa.Log.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+	}
+}
+
+// PeriodicSchema returns the schema of a periodic analysis log: the Run,
+// Epoch and Layer columns, followed by given columns
+func PeriodicSchema(cols etable.Schema) etable.Schema {
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Layer", etensor.STRING, nil, nil},
+	}
+	return append(sch, cols...)
+}
+
+// AddPeriodicRow adds a row to the log of a periodic analysis, setting its
+// Run, Epoch and Layer columns, and returns its index
+func (ss *Sim) AddPeriodicRow(dt *etable.Table, epc int, lnm string) int {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(epc))
+	dt.SetCellString("Layer", row, lnm)
+	return row
+}
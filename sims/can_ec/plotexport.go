@@ -0,0 +1,106 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/emer/etable/eplot"
+	"github.com/goki/gi/gi"
+	"gonum.org/v1/plot/vg"
+)
+
+// PlotExport saves GUI plots as vector graphics, for publication figures
+// without re-plotting the logs: the Plots are saved at RunEnd, if On, and
+// any plot tab can be saved with ExportPlot, e.g., from the toolbar.  SVG
+// files are the plot as rendered in the GUI, and PDF files are rendered at
+// Width x Height from the same plot.
+type PlotExport struct {
+	On      bool     `desc:"save the Plots at RunEnd -- only in the GUI, as plots are not rendered without it"`
+	Plots   []string `desc:"names of the plot tabs saved at RunEnd"`
+	Formats []string `desc:"file formats saved: svg and / or pdf"`
+	Width   float32  `def:"400" min:"10" desc:"width of PDF files, in points"`
+	Height  float32  `def:"300" min:"10" desc:"height of PDF files, in points"`
+}
+
+// Defaults sets default params
+func (pe *PlotExport) Defaults() {
+	pe.Plots = []string{"TrnEpcPlot", "TstEpcPlot", "RunPlot"}
+	pe.Formats = []string{"svg", "pdf"}
+	pe.Width = 400
+	pe.Height = 300
+}
+
+// SavePlot saves given plot to files named fnm plus the extension of each
+// of the Formats
+func (pe *PlotExport) SavePlot(plt *eplot.Plot2D, fnm string) error {
+	for _, ext := range pe.Formats {
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		pfn := fnm + "." + ext
+		switch ext {
+		case "svg":
+			plt.SaveSVG(gi.FileName(pfn))
+		case "pdf":
+			if plt.GPlot == nil {
+				return fmt.Errorf("PlotExport: plot %s has not been rendered", plt.Name())
+			}
+			if err := plt.GPlot.Save(vg.Length(pe.Width), vg.Length(pe.Height), pfn); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("PlotExport: format %q not supported -- must be svg or pdf", ext)
+		}
+		fmt.Printf("Saved plot to: %s\n", pfn)
+	}
+	return nil
+}
+
+// PlotFileName returns the file name, without extension, for the named plot
+func (ss *Sim) PlotFileName(plot string) string {
+	return strings.TrimSuffix(ss.LogFileName(fmt.Sprintf("%s_run%03d", plot, ss.TrainEnv.Run.Cur)), ".tsv")
+}
+
+// ExportPlot saves the plot tab of given name, as in the Formats of PlotExport
+func (ss *Sim) ExportPlot(plot string) error {
+	pt, ok := ss.Plots[plot]
+	if !ok {
+		return fmt.Errorf("ExportPlot: plot %q not found", plot)
+	}
+	return ss.PlotExport.SavePlot(pt.Plot, ss.PlotFileName(plot))
+}
+
+// ExportCurPlot saves the plot in the current tab, if it is a plot --
+// called from the toolbar
+func (ss *Sim) ExportCurPlot() {
+	if ss.TabView == nil {
+		return
+	}
+	cur, _, ok := ss.TabView.CurTab()
+	if !ok {
+		return
+	}
+	if _, ok := ss.Plots[cur.Name()]; !ok {
+		log.Printf("ExportPlot: current tab %s is not a plot\n", cur.Name())
+		return
+	}
+	if err := ss.ExportPlot(cur.Name()); err != nil {
+		log.Println(err)
+	}
+}
+
+// SavePlots saves the PlotExport Plots, if On and in the GUI -- called in
+// RunEnd
+func (ss *Sim) SavePlots() {
+	if !ss.PlotExport.On || ss.Plots == nil {
+		return
+	}
+	for _, nm := range ss.PlotExport.Plots {
+		if err := ss.ExportPlot(nm); err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+
+	"github.com/emer/etable/eplot"
+)
+
+// PlotUpdt decouples the updating of the plots from the logging: the log
+// functions only notify that the table of a plot changed, with UpdtPlot,
+// which is a no-op without the GUI, and for nil plots, so logging never
+// depends on whether or which plots exist.  With the GUI, the changed plots
+// are updated together, at most every MinMSec, with the Go version of update
+// as the logs are written from the running goroutine.  Any pending updates
+// are done in Stopped.  The changed plots are only accessed with the Sim
+// GuiMu held, as they are cleared when the GUI is detached.
+type PlotUpdt struct {
+	MinMSec int `def:"100" min:"0" desc:"minimum interval between plot updates while running, in msec -- the plots changed in between are updated together -- 0 = update on every change"`
+
+	Dirty []*eplot.Plot2D `view:"-" desc:"plots changed since the last update"`
+	Last  time.Time       `view:"-" desc:"time of the last update"`
+}
+
+// Defaults sets default params
+func (pu *PlotUpdt) Defaults() {
+	pu.MinMSec = 100
+}
+
+// Notify records that given plot changed, and updates the changed plots if
+// the MinMSec interval has passed since the last update
+func (pu *PlotUpdt) Notify(plt *eplot.Plot2D) {
+	has := false
+	for _, dp := range pu.Dirty {
+		if dp == plt {
+			has = true
+			break
+		}
+	}
+	if !has {
+		pu.Dirty = append(pu.Dirty, plt)
+	}
+	if time.Since(pu.Last) >= time.Duration(pu.MinMSec)*time.Millisecond {
+		pu.Flush()
+	}
+}
+
+// Flush updates all the changed plots
+func (pu *PlotUpdt) Flush() {
+	for _, plt := range pu.Dirty {
+		plt.GoUpdate()
+	}
+	pu.Dirty = pu.Dirty[:0]
+	pu.Last = time.Now()
+}
+
+// UpdtPlot notifies that the table of given plot changed -- a no-op without
+// the GUI or plot, so it is safe to call from any logging path
+func (ss *Sim) UpdtPlot(plt *eplot.Plot2D) {
+	ss.GuiMu.Lock()
+	defer ss.GuiMu.Unlock()
+	if ss.Win == nil || plt == nil {
+		return
+	}
+	ss.PlotUpdt.Notify(plt)
+}
+
+// FlushPlots updates any plots with pending changes -- called in Stopped
+func (ss *Sim) FlushPlots() {
+	ss.GuiMu.Lock()
+	defer ss.GuiMu.Unlock()
+	if ss.Win == nil {
+		ss.PlotUpdt.Dirty = nil // e.g., detached
+		return
+	}
+	ss.PlotUpdt.Flush()
+}
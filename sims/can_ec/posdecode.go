@@ -0,0 +1,132 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+
+	"github.com/emer/emergent/popcode"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/ki/kit"
+	"github.com/goki/mat32"
+)
+
+// PosDecodeMethods are the methods for decoding a continuous-valued
+// position from a 2D population code
+type PosDecodeMethods int32
+
+var KiT_PosDecodeMethods = kit.Enums.AddEnum(PosDecodeMethodsN, false, nil)
+
+const (
+	// PopAvg is the popcode.TwoD activation-weighted average over the entire pattern.
+	// Spread-out activity biases this toward the center of the layer.
+	PopAvg PosDecodeMethods = iota
+
+	// CenterOfMass is the activation-weighted average of unit positions within
+	// Radius units of the most active unit.
+	CenterOfMass
+
+	// GaussFit fits a gaussian through the most active unit and its immediate
+	// neighbors along each axis (log-parabolic interpolation), giving a sub-cell
+	// estimate of the peak of the bump.
+	GaussFit
+
+	PosDecodeMethodsN
+)
+
+// PosDecoder decodes a continuous (sub-cell) position from a 2D population code,
+// returning values in the same normalized units as the popcode.TwoD used to encode it.
+type PosDecoder struct {
+	Method PosDecodeMethods `desc:"decoding method"`
+	Radius int              `def:"2" viewif:"Method=CenterOfMass" desc:"radius in units around the most active unit to include in the center-of-mass"`
+	Thr    float32          `def:"0.1" desc:"activations below this threshold are ignored"`
+}
+
+func (pd *PosDecoder) Defaults() {
+	pd.Method = GaussFit
+	pd.Radius = 2
+	pd.Thr = 0.1
+}
+
+// Decode decodes the 2D value represented by the given pattern, which was encoded
+// using given popcode (which provides the mapping from units to values)
+func (pd *PosDecoder) Decode(pat *etensor.Float32, pc *popcode.TwoD) mat32.Vec2 {
+	if pd.Method == PopAvg {
+		val, _ := pc.Decode(pat)
+		return val
+	}
+	ny := pat.Dim(0)
+	nx := pat.Dim(1)
+	py, px := 0, 0
+	mx := float32(-1)
+	for yi := 0; yi < ny; yi++ {
+		for xi := 0; xi < nx; xi++ {
+			act := pat.Value([]int{yi, xi})
+			if act > mx {
+				mx = act
+				py, px = yi, xi
+			}
+		}
+	}
+	var fi mat32.Vec2 // fractional unit coordinates
+	switch pd.Method {
+	case CenterOfMass:
+		var sum float32
+		for yi := py - pd.Radius; yi <= py+pd.Radius; yi++ {
+			if yi < 0 || yi >= ny {
+				continue
+			}
+			for xi := px - pd.Radius; xi <= px+pd.Radius; xi++ {
+				if xi < 0 || xi >= nx {
+					continue
+				}
+				act := pat.Value([]int{yi, xi})
+				if act < pd.Thr {
+					continue
+				}
+				fi.X += act * float32(xi)
+				fi.Y += act * float32(yi)
+				sum += act
+			}
+		}
+		if sum > 0 {
+			fi = fi.DivScalar(sum)
+		} else {
+			fi.Set(float32(px), float32(py))
+		}
+	case GaussFit:
+		fi.X = float32(px) + pd.GaussOff(pat, py, px, 0, 1)
+		fi.Y = float32(py) + pd.GaussOff(pat, py, px, 1, 0)
+	}
+	rng := pc.Max.Sub(pc.Min)
+	incr := rng.Div(mat32.Vec2{float32(nx - 1), float32(ny - 1)})
+	return pc.Min.Add(incr.Mul(fi))
+}
+
+// GaussOff returns the sub-unit offset of the peak of a gaussian fit through
+// the unit at py, px and its two neighbors along the dy, dx direction.
+// returns 0 at the edges or if the activations are not peaked.
+func (pd *PosDecoder) GaussOff(pat *etensor.Float32, py, px, dy, dx int) float32 {
+	ny := pat.Dim(0)
+	nx := pat.Dim(1)
+	if py-dy < 0 || px-dx < 0 || py+dy >= ny || px+dx >= nx {
+		return 0
+	}
+	lo := float64(pat.Value([]int{py - dy, px - dx}))
+	md := float64(pat.Value([]int{py, px}))
+	hi := float64(pat.Value([]int{py + dy, px + dx}))
+	if md < float64(pd.Thr) {
+		return 0
+	}
+	lo = math.Max(lo, 1.0e-6) // floor so log is defined
+	hi = math.Max(hi, 1.0e-6)
+	llo, lmd, lhi := math.Log(lo), math.Log(md), math.Log(hi)
+	den := llo - 2*lmd + lhi
+	if den >= 0 {
+		return 0
+	}
+	off := 0.5 * (llo - lhi) / den
+	return float32(math.Max(-0.5, math.Min(0.5, off)))
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/emer/emergent/evec"
+	"github.com/goki/ki/ints"
+	"github.com/goki/ki/kit"
+)
+
+// WorldPresets are built-in world layouts for classic rodent paradigms,
+// generated by GenWorld according to XYHDEnv.Preset
+type WorldPresets int32
+
+var KiT_WorldPresets = kit.Enums.AddEnum(WorldPresetsN, false, nil)
+
+const (
+	// OpenField is an empty square arena surrounded by walls -- start in the center
+	OpenField WorldPresets = iota
+
+	// LinearTrack is a horizontal corridor -- start at the left end facing right,
+	// with goals at both ends
+	LinearTrack
+
+	// TMaze is a vertical stem from the bottom, with a horizontal crossbar at the top --
+	// start at the base of the stem facing up, with goals at the ends of the left and right arms
+	TMaze
+
+	// Figure8 is a rectangular loop with a central vertical stem -- start at the base
+	// of the stem facing up, with goals at the bottom left and right corners of the loop
+	Figure8
+
+	// RadialArm is a central hub with 8 arms at 45 degree intervals -- start in the
+	// hub facing right, with goals at the ends of the arms
+	RadialArm
+
+	// CircularField is an empty circular arena, the classic open field of
+	// place and grid cell recordings -- start in the center
+	CircularField
+
+	// LShape is an open field with the upper right quadrant walled off --
+	// start in the center of the lower left quadrant
+	LShape
+
+	WorldPresetsN
+)
+
+// WorldPresetFromString returns the preset with given name
+func WorldPresetFromString(nm string) (WorldPresets, error) {
+	for p := WorldPresets(0); p < WorldPresetsN; p++ {
+		if p.String() == nm {
+			return p, nil
+		}
+	}
+	return OpenField, fmt.Errorf("WorldPreset: %q not found", nm)
+}
+
+// SetPreset sets the Preset by name, and regenerates the world.
+// Call Init after to move the agent to the Start location.
+func (ev *XYHDEnv) SetPreset(nm string) error {
+	p, err := WorldPresetFromString(nm)
+	if err != nil {
+		return err
+	}
+	ev.Preset = p
+	ev.GenWorld()
+	return nil
+}
+
+// WorldFill fills the rectangle from st to ed (inclusive) with given mat
+func (ev *XYHDEnv) WorldFill(st, ed evec.Vec2i, mat int) {
+	for y := st.Y; y <= ed.Y; y++ {
+		ev.WorldLineHoriz(evec.Vec2i{st.X, y}, evec.Vec2i{ed.X, y}, mat)
+	}
+}
+
+// Corridor carves an empty corridor of width TrackW, centered on the
+// horizontal or vertical line from st to ed
+func (ev *XYHDEnv) Corridor(st, ed evec.Vec2i) {
+	hw := ev.TrackW / 2
+	ev.WorldFill(evec.Vec2i{st.X - hw, st.Y - hw}, evec.Vec2i{ed.X + hw, ed.Y + hw}, 0)
+}
+
+// DiagCorridor carves an empty corridor of width TrackW along a line
+// at any angle from st to ed
+func (ev *XYHDEnv) DiagCorridor(st, ed evec.Vec2i) {
+	hw := ev.TrackW / 2
+	for o := -hw; o <= hw; o++ {
+		ev.WorldLine(evec.Vec2i{st.X + o, st.Y}, evec.Vec2i{ed.X + o, ed.Y}, 0)
+		ev.WorldLine(evec.Vec2i{st.X, st.Y + o}, evec.Vec2i{ed.X, ed.Y + o}, 0)
+	}
+}
+
+// GenPreset generates the world for the current Preset, setting the
+// Start location, StartAngle and Goals.  Only called for non-OpenField presets.
+func (ev *XYHDEnv) GenPreset() {
+	wall := ev.MatMap["Wall"]
+	ev.WorldFill(evec.Vec2i{0, 0}, evec.Vec2i{ev.Size.X - 1, ev.Size.Y - 1}, wall)
+	m := 2 + ev.TrackW/2 // margin from edge to corridor center line, keeping outer wall
+	mx := ev.Size.X - 1 - m
+	my := ev.Size.Y - 1 - m
+	ctr := ev.Size.DivScalar(2)
+	switch ev.Preset {
+	case LinearTrack:
+		ev.Corridor(evec.Vec2i{m, ctr.Y}, evec.Vec2i{mx, ctr.Y})
+		ev.Start = evec.Vec2i{m, ctr.Y}
+		ev.StartAngle = 0
+		ev.Goals = []evec.Vec2i{{m, ctr.Y}, {mx, ctr.Y}}
+	case TMaze:
+		ev.Corridor(evec.Vec2i{ctr.X, m}, evec.Vec2i{ctr.X, my})
+		ev.Corridor(evec.Vec2i{m, my}, evec.Vec2i{mx, my})
+		ev.Start = evec.Vec2i{ctr.X, m}
+		ev.StartAngle = 90
+		ev.Goals = []evec.Vec2i{{m, my}, {mx, my}}
+		ev.ChoicePt = evec.Vec2i{ctr.X, my}
+	case Figure8:
+		ev.Corridor(evec.Vec2i{m, m}, evec.Vec2i{mx, m})
+		ev.Corridor(evec.Vec2i{m, my}, evec.Vec2i{mx, my})
+		ev.Corridor(evec.Vec2i{m, m}, evec.Vec2i{m, my})
+		ev.Corridor(evec.Vec2i{mx, m}, evec.Vec2i{mx, my})
+		ev.Corridor(evec.Vec2i{ctr.X, m}, evec.Vec2i{ctr.X, my})
+		ev.Start = evec.Vec2i{ctr.X, m}
+		ev.StartAngle = 90
+		ev.Goals = []evec.Vec2i{{m, m}, {mx, m}}
+		ev.ChoicePt = evec.Vec2i{ctr.X, my}
+	case RadialArm:
+		hub := ev.TrackW + 1
+		ev.WorldFill(evec.Vec2i{ctr.X - hub, ctr.Y - hub}, evec.Vec2i{ctr.X + hub, ctr.Y + hub}, 0)
+		ev.Goals = nil
+		for a := 0; a < 360; a += 45 {
+			v := AngVec(a) // largest value is 1
+			r := ev.Size.X/2 - m
+			if ev.Size.Y < ev.Size.X {
+				r = ev.Size.Y/2 - m
+			}
+			end := evec.NewVec2iFmVec2Round(ctr.ToVec2().Add(v.MulScalar(float32(r))))
+			if a%90 == 0 {
+				ev.Corridor(evec.Vec2i{ints.MinInt(ctr.X, end.X), ints.MinInt(ctr.Y, end.Y)}, evec.Vec2i{ints.MaxInt(ctr.X, end.X), ints.MaxInt(ctr.Y, end.Y)})
+			} else {
+				ev.DiagCorridor(ctr, end)
+			}
+			ev.Goals = append(ev.Goals, end)
+		}
+		ev.Start = ctr
+		ev.StartAngle = 0
+	case CircularField:
+		r := float32(ints.MinInt(ev.Size.X, ev.Size.Y))/2 - 1.5 // keep outer wall
+		cx := float32(ev.Size.X-1) / 2
+		cy := float32(ev.Size.Y-1) / 2
+		for y := 1; y < ev.Size.Y-1; y++ {
+			for x := 1; x < ev.Size.X-1; x++ {
+				dx := float32(x) - cx
+				dy := float32(y) - cy
+				if dx*dx+dy*dy <= r*r {
+					ev.SetWorld(evec.Vec2i{x, y}, 0)
+				}
+			}
+		}
+		ev.Start = ctr
+		ev.StartAngle = 0
+		ev.Goals = nil
+	case LShape:
+		ev.WorldFill(evec.Vec2i{1, 1}, evec.Vec2i{ev.Size.X - 2, ev.Size.Y - 2}, 0)
+		ev.WorldFill(ctr, evec.Vec2i{ev.Size.X - 1, ev.Size.Y - 1}, wall)
+		ev.Start = ctr.DivScalar(2)
+		ev.StartAngle = 0
+		ev.Goals = nil
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/leabra/leabra"
+)
+
+// PretrainInputs are the input layers that are applied in a Pretrain
+// protocol phase: the vestibular signal and the previous state -- all other
+// inputs are off, and the targets other than the SupLays readouts
+var PretrainInputs = []string{"Vestibular", "Prev_Position", "Prev_Orientation"}
+
+// Pretraining returns true if the current protocol phase is a Pretrain phase
+func (pr *Protocol) Pretraining() bool {
+	ph := pr.Cur()
+	return ph != nil && ph.Pretrain
+}
+
+// PretrainOff returns true if the input or target of given layer is off in
+// the current trial because of a Pretrain phase -- called in ApplyStatePats
+func (ss *Sim) PretrainOff(lnm string) bool {
+	if !ss.Protocol.Pretraining() || ss.IsSupLay(lnm) {
+		return false
+	}
+	for _, nm := range PretrainInputs {
+		if nm == lnm {
+			return false
+		}
+	}
+	return true
+}
+
+// PretrainLearns returns true if given projection learns in a Pretrain
+// phase: those into the EC layers, including their laterals, and those
+// into the SupLays readouts if the phase is weakly supervised
+func (ss *Sim) PretrainLearns(ly leabra.LeabraLayer, sup bool) bool {
+	if _, ok := ly.(*ECLayer); ok {
+		return true
+	}
+	return sup && ss.IsSupLay(ly.Name())
+}
+
+// SetPretrain freezes the learning of the projections that do not learn in
+// a Pretrain phase, if on, saving their Learn state in Protocol.PreLearn,
+// or restores it, if off, unfreezing the full network -- called in
+// StartPhase and InitProtocol
+func (ss *Sim) SetPretrain(on bool, sup bool) {
+	pr := &ss.Protocol
+	for _, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer)
+		for _, pji := range ly.AsLeabra().RcvPrjns {
+			pj := pji.(leabra.LeabraPrjn).AsLeabra()
+			if !on {
+				if lrn, ok := pr.PreLearn[pj.Name()]; ok {
+					pj.Learn.Learn = lrn
+				}
+				continue
+			}
+			if ss.PretrainLearns(ly, sup) {
+				continue
+			}
+			if pr.PreLearn == nil {
+				pr.PreLearn = make(map[string]bool)
+			}
+			if _, ok := pr.PreLearn[pj.Name()]; !ok {
+				pr.PreLearn[pj.Name()] = pj.Learn.Learn
+			}
+			pj.Learn.Learn = false
+		}
+	}
+	if !on {
+		pr.PreLearn = nil
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/leabra/leabra"
+)
+
+// PrjnCtrl is the run-time state of one projection, as edited in the Prjns
+// tab: turning it off or scaling it applies immediately to the network, with
+// no Init, so the causal contribution of each pathway can be probed on a
+// trained network.  Changes are lost on Init, which rebuilds the network.
+type PrjnCtrl struct {
+	Prjn  string  `inactive:"+" width:"30" desc:"name of the projection, sending layer To receiving layer"`
+	Class string  `inactive:"+" desc:"class of the projection, for the params"`
+	On    bool    `desc:"projection is active -- uncheck to turn it Off"`
+	Rel   float32 `min:"0" step:"0.1" desc:"relative scaling of the projection (WtScale.Rel), normalized against the other projections into the receiving layer"`
+	Abs   float32 `min:"0" step:"0.1" desc:"absolute scaling of the projection (WtScale.Abs)"`
+}
+
+// UpdtPrjnCtrls sets the PrjnCtrls from the current state of the network
+// projections -- called after the network is (re)configured, and in Init
+func (ss *Sim) UpdtPrjnCtrls() {
+	ss.PrjnCtrls = ss.PrjnCtrls[:0]
+	for _, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		for _, pji := range ly.RcvPrjns {
+			pj := pji.(leabra.LeabraPrjn).AsLeabra()
+			ss.PrjnCtrls = append(ss.PrjnCtrls, PrjnCtrl{Prjn: pj.Name(), Class: pj.Class(), On: !pj.Off, Rel: pj.WtScale.Rel, Abs: pj.WtScale.Abs})
+		}
+	}
+	if ss.PrjnView != nil {
+		ss.PrjnView.SetSlice(&ss.PrjnCtrls)
+	}
+}
+
+// ApplyPrjnCtrls applies the PrjnCtrls to the network projections, and
+// recomputes the netinput scaling of the receiving layers -- called when the
+// Prjns tab is edited
+func (ss *Sim) ApplyPrjnCtrls() {
+	for _, pc := range ss.PrjnCtrls {
+		for _, lyi := range ss.Net.Layers {
+			ly := lyi.(leabra.LeabraLayer).AsLeabra()
+			for _, pji := range ly.RcvPrjns {
+				pj := pji.(leabra.LeabraPrjn).AsLeabra()
+				if pj.Name() != pc.Prjn {
+					continue
+				}
+				pj.Off = !pc.On
+				pj.WtScale.Rel = pc.Rel
+				pj.WtScale.Abs = pc.Abs
+			}
+		}
+	}
+	ss.Net.GScaleFmAvgAct()
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// ProbeLays are the input layers that probe stimuli are generated for, and
+// ProbeStates the env states whose encoders are used for each, as in ApplyInputs
+var (
+	ProbeLays   = []string{"Vestibular", "Prev_Position", "Prev_Orientation"}
+	ProbeStates = []string{"Vestibular", "Position", "Angle"}
+)
+
+// ProbeParams are the parameters for the synthetic probe stimuli generated by
+// GenProbes: single-position bumps at each of Positions with heading Angle and
+// no rotation, a ramp of headings at Pos, and velocity pulses of NVel rotations
+// evenly spanning -AngInc to +AngInc, at Pos and Angle.  All patterns are
+// encoded with the env encoders, as in training.
+type ProbeParams struct {
+	Positions []evec.Vec2i `desc:"positions of the single-position bump probes -- if empty, a 5x5 grid over the arena"`
+	Pos       evec.Vec2i   `desc:"position for the heading ramp and velocity pulse probes -- 0,0 = center of the arena"`
+	Angle     int          `desc:"heading for the position bump and velocity pulse probes, in degrees"`
+	AngStep   int          `desc:"step between headings in the heading ramp, in degrees -- 0 = env AngInc"`
+	NVel      int          `def:"9" min:"2" desc:"number of velocity pulse probes, evenly spanning rotations of -AngInc to +AngInc"`
+}
+
+// Defaults sets default params
+func (pp *ProbeParams) Defaults() {
+	pp.NVel = 9
+}
+
+// GenProbes generates the synthetic probe stimuli into dt, one row per probe,
+// with a column of input patterns for each of the ProbeLays -- use
+// ProbeInputs to get the inputs of a row for RunTrial
+func (ss *Sim) GenProbes(dt *etable.Table) {
+	ev := &ss.TrainEnv
+	pp := &ss.Probe
+	dt.SetMetaData("name", "Probes")
+	dt.SetMetaData("desc", "Synthetic probe stimuli for RunTrial")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+		{"Probe", etensor.STRING, nil, nil},
+		{"X", etensor.FLOAT64, nil, nil},
+		{"Y", etensor.FLOAT64, nil, nil},
+		{"Angle", etensor.FLOAT64, nil, nil},
+		{"Rot", etensor.FLOAT64, nil, nil},
+	}
+	pats := make([]*etensor.Float32, len(ProbeLays))
+	for i, lnm := range ProbeLays {
+		shp := ev.NextStates[ProbeStates[i]].Shapes()
+		sch = append(sch, etable.Column{Name: lnm, Type: etensor.FLOAT32, CellShape: shp})
+		pats[i] = etensor.NewFloat32(shp, nil, nil)
+	}
+	dt.SetFromSchema(sch, 0)
+
+	pos := pp.Pos
+	if pos.IsNil() {
+		pos = ev.Size.DivScalar(2)
+	}
+	addProbe := func(nm, probe string, p evec.Vec2i, ang, rot int) {
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellString("Name", row, nm)
+		dt.SetCellString("Probe", row, probe)
+		dt.SetCellFloat("X", row, float64(p.X))
+		dt.SetCellFloat("Y", row, float64(p.Y))
+		dt.SetCellFloat("Angle", row, float64(ang))
+		dt.SetCellFloat("Rot", row, float64(rot))
+		ev.EncodeVestibular(pats[0], rot)
+		ev.EncodePosition(pats[1], ProbeStates[1], p.ToVec2())
+		ev.EncodeAngle(pats[2], ProbeStates[2], ang)
+		for i, lnm := range ProbeLays {
+			dt.SetCellTensor(lnm, row, pats[i])
+		}
+	}
+
+	psns := pp.Positions
+	if len(psns) == 0 {
+		for y := 0; y < 5; y++ {
+			for x := 0; x < 5; x++ {
+				psns = append(psns, evec.Vec2i{1 + x*(ev.Size.X-3)/4, 1 + y*(ev.Size.Y-3)/4})
+			}
+		}
+	}
+	for _, p := range psns {
+		addProbe(fmt.Sprintf("Pos_%d_%d", p.X, p.Y), "Bump", p, pp.Angle, 0)
+	}
+	astep := pp.AngStep
+	if astep <= 0 {
+		astep = ev.AngInc
+	}
+	for ang := 0; ang < 360; ang += astep {
+		addProbe(fmt.Sprintf("Head_%d", ang), "Heading", pos, ang, 0)
+	}
+	if pp.NVel < 2 {
+		return
+	}
+	for i := 0; i < pp.NVel; i++ {
+		rot := -ev.AngInc + (2*ev.AngInc*i)/(pp.NVel-1)
+		addProbe(fmt.Sprintf("Vel_%d", rot), "Velocity", pos, pp.Angle, rot)
+	}
+}
+
+// ProbeInputs returns the inputs for RunTrial from given row of the probes
+// table generated by GenProbes, keyed by layer name
+func (ss *Sim) ProbeInputs(dt *etable.Table, row int) map[string]etensor.Tensor {
+	inputs := make(map[string]etensor.Tensor, len(ProbeLays))
+	for _, lnm := range ProbeLays {
+		inputs[lnm] = dt.CellTensor(lnm, row)
+	}
+	return inputs
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// ProtoPhase is one phase of a Protocol, lasting Epochs training epochs.
+// Manipulations that are not set in a phase take their base values, as they
+// were when the protocol was loaded, except World, which persists until a
+// later phase changes it.  Task blocks are logged to the TaskLog.
+type ProtoPhase struct {
+	Name        string      `desc:"name of the phase, used as a prefix for the files of its Analyses"`
+	Epochs      int         `min:"1" desc:"duration of the phase, in training epochs"`
+	Frozen      bool        `desc:"no learning during the phase: weights are not changed, as in testing"`
+	World       string      `desc:"world preset to switch to at the start of the phase (e.g., LinearTrack), moving the agent to its Start -- empty = keep the current world"`
+	Occlude     []OcclBlock `desc:"sensory occlusion blocks during the phase, with Epoch relative to the start of the phase, and Trials = 0 for the rest of the phase"`
+	VestibGain  float32     `desc:"gain on the Vestibular angular-velocity signal during the phase -- 0 = veridical"`
+	CueRot      int         `desc:"rotation of the allothetic heading cue (Prev_Orientation input) relative to the true heading, in degrees, as in cue-rotation experiments"`
+	Clamp       string      `desc:"clamp schedules during the phase, in the -clamp format -- empty = base schedules"`
+	Analyses    []string    `desc:"analyses to run at the end of the phase, saved with the phase Name as a prefix"`
+	Tasks       []TaskBlock `desc:"blocks of tasks driving the actions during the phase, intermixed in order, cycling until the end of the phase -- empty = exploration throughout"`
+	Pretrain    bool        `desc:"vestibular-only pretraining of the EC lateral / path-integration circuit: only the PretrainInputs are applied, and only the projections into the EC layers learn, the rest of the network being frozen until a later phase without Pretrain"`
+	PretrainSup float32     `min:"0" max:"1" desc:"in a Pretrain phase, fraction of trials on which the SupLays readouts get targets, and then their projections learn too -- 0 = unsupervised, small = weakly supervised"`
+
+	Clamps []ClampSched `json:"-" view:"-" desc:"parsed Clamp schedules"`
+}
+
+// Protocol is a scripted experiment of successive phases (e.g., train, dark
+// test, cue rotation, retrain), loaded from a JSON file and interpreted by
+// the Sim during training, so that multi-phase experiments are reproducible
+// from one file.  Phase occlusion and vestibular gain manipulations are
+// compiled into the Occlusion and VestibGain schedules at the start of each
+// run, replacing any loaded separately, and MaxEpcs is set to the total
+// duration of the phases.
+type Protocol struct {
+	Name   string       `desc:"name of the protocol"`
+	Phases []ProtoPhase `desc:"phases of the protocol, in order -- if empty, there is no protocol"`
+
+	File       string           `json:"-" desc:"file that the protocol was loaded from"`
+	Phase      int              `json:"-" inactive:"+" desc:"index of the current phase, -1 = none"`
+	PhaseStart int              `json:"-" inactive:"+" desc:"training epoch at which the current phase started"`
+	BaseWorld  WorldPresets     `json:"-" view:"-" desc:"world preset when the protocol was loaded, restored at the start of each run"`
+	BaseClamp  []ClampSched     `json:"-" view:"-" desc:"clamp schedules when the protocol was loaded, for phases without Clamp"`
+	CuePats    *etensor.Float32 `json:"-" view:"-" desc:"rotated heading cue input pattern"`
+	PreLearn   map[string]bool  `json:"-" view:"-" desc:"Learn state of the projections frozen by a Pretrain phase, by name, restored at its end"`
+}
+
+// PhaseAt returns the index of the phase that given training epoch is in,
+// and the epoch at which it starts -- len(Phases) after the last phase
+func (pr *Protocol) PhaseAt(epc int) (int, int) {
+	st := 0
+	for pi, ph := range pr.Phases {
+		if epc < st+ph.Epochs {
+			return pi, st
+		}
+		st += ph.Epochs
+	}
+	return len(pr.Phases), st
+}
+
+// Cur returns the current phase, nil if none
+func (pr *Protocol) Cur() *ProtoPhase {
+	if pr.Phase < 0 || pr.Phase >= len(pr.Phases) {
+		return nil
+	}
+	return &pr.Phases[pr.Phase]
+}
+
+// Frozen returns true if learning is off in the current phase
+func (pr *Protocol) Frozen() bool {
+	ph := pr.Cur()
+	return ph != nil && ph.Frozen
+}
+
+// OpenProtocol loads a Protocol from a JSON file, e.g.:
+// {"Name": "CueRot", "Phases": [{"Name": "Train", "Epochs": 100},
+// {"Name": "Dark", "Epochs": 10, "Frozen": true, "Occlude": [{"MinAng": 0, "MaxAng": 359}]},
+// {"Name": "Rot", "Epochs": 10, "CueRot": 90, "Analyses": ["posdecode"]},
+// {"Name": "Retrain", "Epochs": 50},
+// {"Name": "Mixed", "Epochs": 50, "Tasks": [{"Task": "Forage", "Trials": 500}, {"Task": "Goal", "Goals": 5}]}]}
+// A Pretrain phase first, e.g., {"Name": "Vestib", "Epochs": 20, "Pretrain": true,
+// "PretrainSup": 0.1}, trains the path integration of the EC from the
+// vestibular signal before the full network, as in development.
+func (ss *Sim) OpenProtocol(filename gi.FileName) error {
+	b, err := ioutil.ReadFile(string(filename))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	var pr Protocol
+	if err = json.Unmarshal(b, &pr); err != nil {
+		log.Println(err)
+		return err
+	}
+	for pi := range pr.Phases {
+		ph := &pr.Phases[pi]
+		if ph.Epochs < 1 {
+			err = fmt.Errorf("Protocol: phase %q must have Epochs >= 1", ph.Name)
+			log.Println(err)
+			return err
+		}
+		if ph.World != "" {
+			if _, err = WorldPresetFromString(ph.World); err != nil {
+				log.Println(err)
+				return err
+			}
+		}
+		if ph.PretrainSup < 0 || ph.PretrainSup > 1 {
+			err = fmt.Errorf("Protocol: phase %q PretrainSup must be between 0 and 1", ph.Name)
+			log.Println(err)
+			return err
+		}
+		if ph.Clamp != "" {
+			if ph.Clamps, err = ParseClampScheds(ph.Clamp); err != nil {
+				log.Println(err)
+				return err
+			}
+		}
+		if _, err = ParseAnalyses(strings.Join(ph.Analyses, ",")); err != nil {
+			log.Println(err)
+			return err
+		}
+		for ti := range ph.Tasks {
+			tb := &ph.Tasks[ti]
+			if err = tb.Type.FromString(tb.Task); err != nil {
+				log.Println(err)
+				return err
+			}
+			if tb.Trials < 1 && tb.Goals < 1 {
+				err = fmt.Errorf("Protocol: phase %q task %q must have Trials or Goals >= 1", ph.Name, tb.Task)
+				log.Println(err)
+				return err
+			}
+		}
+	}
+	pr.File = string(filename)
+	pr.Phase = -1
+	pr.BaseWorld = ss.TrainEnv.Preset
+	pr.BaseClamp = append([]ClampSched(nil), ss.ClampScheds...)
+	ss.Protocol = pr
+	return nil
+}
+
+// InitProtocol compiles the phase schedules and starts the first phase --
+// called in NewRun
+func (ss *Sim) InitProtocol() {
+	pr := &ss.Protocol
+	pr.Phase = -1
+	ss.SetPretrain(false, false)
+	if len(pr.Phases) == 0 {
+		return
+	}
+	ntrls := ss.TrainEnv.Trial.Max
+	var oblks []OcclBlock
+	var gblks []GainBlock
+	st := 0
+	for _, ph := range pr.Phases {
+		for _, ob := range ph.Occlude {
+			ob.Epoch += st
+			end := (st + ph.Epochs) * ntrls
+			if bst := ob.Epoch * ntrls; ob.Trials == 0 || bst+ob.Trials > end {
+				ob.Trials = end - bst
+			}
+			if ob.Trials > 0 {
+				oblks = append(oblks, ob)
+			}
+		}
+		if ph.VestibGain != 0 {
+			gblks = append(gblks, GainBlock{Epoch: st, Trials: ph.Epochs * ntrls, Gain: ph.VestibGain})
+		}
+		st += ph.Epochs
+	}
+	ss.Occlusion.Blocks = oblks
+	ss.Occlusion.File = pr.File
+	ss.VestibGain.Blocks = gblks
+	ss.VestibGain.File = pr.File
+	ss.MaxEpcs = st
+	ss.StartPhase(0, 0)
+}
+
+// StartPhase makes given phase current, starting at given epoch, applying its
+// world, clamp schedules and first task block
+func (ss *Sim) StartPhase(pi, epc int) {
+	pr := &ss.Protocol
+	pr.Phase = pi
+	pr.PhaseStart = epc
+	ss.StartTaskBlock(0)
+	ph := pr.Cur()
+	ss.SetPretrain(false, false)
+	if ph == nil {
+		return
+	}
+	if ph.Pretrain {
+		ss.SetPretrain(true, ph.PretrainSup > 0)
+	}
+	ev := &ss.TrainEnv
+	wp := ev.Preset
+	if ph.World != "" {
+		wp, _ = WorldPresetFromString(ph.World)
+	} else if pi == 0 {
+		wp = pr.BaseWorld
+	}
+	if wp != ev.Preset {
+		ev.SetPreset(wp.String())
+		ev.MoveToStart()
+	}
+	if ph.Clamp != "" {
+		ss.ClampScheds = append([]ClampSched(nil), ph.Clamps...)
+	} else {
+		ss.ClampScheds = append([]ClampSched(nil), pr.BaseClamp...)
+	}
+	log.Printf("Protocol %s: run %d epoch %d: starting phase %d: %s\n", pr.Name, ev.Run.Cur, epc, pi, ph.Name)
+}
+
+// EndPhase logs the current task block and runs the analyses of the current
+// phase, saving their outputs with the phase name as a prefix
+func (ss *Sim) EndPhase() {
+	ss.LogTask(ss.TaskLog)
+	ph := ss.Protocol.Cur()
+	if ph == nil {
+		return
+	}
+	for _, nm := range ph.Analyses {
+		outs, err := ss.RunAnalysis(nm, false)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		ss.SaveAnalysisOuts(outs, ph.Name+"_")
+	}
+}
+
+// ProtocolEpoch moves on to the phase of given training epoch, ending the
+// current phase if it is over -- called in TrainTrial when the epoch changes
+func (ss *Sim) ProtocolEpoch(epc int) {
+	pr := &ss.Protocol
+	if len(pr.Phases) == 0 {
+		return
+	}
+	pi, st := pr.PhaseAt(epc)
+	if pi == pr.Phase {
+		return
+	}
+	ss.EndPhase()
+	ss.StartPhase(pi, st)
+}
+
+// CuePats returns the input pattern to apply to given layer with given env
+// state: for an input layer that the Inputs map the Angle or PrevAngle
+// heading to (the allothetic heading cue, Prev_Orientation by default), that
+// heading rotated by the CueRot of the current phase, and pats unchanged
+// otherwise, e.g., for the Orientation target -- called in ApplyStatePats
+func (ss *Sim) CuePats(lnm, stnm string, pats etensor.Tensor) etensor.Tensor {
+	pr := &ss.Protocol
+	ph := pr.Cur()
+	if ph == nil || ph.CueRot == 0 || (stnm != "Angle" && stnm != "PrevAngle") {
+		return pats
+	}
+	if ly := ss.Net.LayerByName(lnm); ly == nil || ly.Type() != emer.Input {
+		return pats
+	}
+	if pr.CuePats == nil {
+		pr.CuePats = etensor.NewFloat32(pats.Shapes(), nil, nil)
+	}
+	ev := &ss.TrainEnv
+	ang := ev.Angle
+	if stnm == "PrevAngle" {
+		ang = ev.PrevAngle
+	}
+	ev.EncodeAngle(pr.CuePats, stnm, AngMod(ang+ph.CueRot))
+	return pr.CuePats
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ints"
+)
+
+// RandWorld trains each run on a freshly generated random world, and evaluates
+// (TestAll) on a fixed held-out world, to measure generalization of the learned
+// mapping rather than a single memorized arena.  Random worlds are an open field
+// with NWalls interior wall segments, replacing the Preset.  The held-out world
+// is loaded from EvalFile if set, and otherwise generated from EvalSeed, so it
+// is the same for all runs and never used for training.  Both worlds are saved
+// alongside the logs.
+type RandWorld struct {
+	On       bool   `desc:"train each run on a new random world, and test on the held-out world"`
+	NWalls   int    `def:"6" min:"0" desc:"number of interior wall segments in random worlds"`
+	MaxLen   int    `def:"15" min:"2" desc:"maximum length of interior wall segments, in grid cells"`
+	EvalFile string `desc:"world .tsv file to use as the held-out evaluation world -- if empty, it is generated from EvalSeed"`
+	EvalSeed int64  `desc:"random seed for generating the held-out evaluation world"`
+
+	Eval *etensor.Int `view:"-" desc:"the held-out evaluation world, tested in the TestEnv"`
+}
+
+// Defaults sets default params
+func (rw *RandWorld) Defaults() {
+	rw.NWalls = 6
+	rw.MaxLen = 15
+	rw.EvalSeed = 1000
+}
+
+// GenRandWorld generates an open field world surrounded by walls, with nwalls
+// random horizontal or vertical interior wall segments of length 2 to maxlen,
+// keeping the area around the Start location in the center clear
+func (ev *XYHDEnv) GenRandWorld(nwalls, maxlen int, rnd *rand.Rand) {
+	wall := ev.MatMap["Wall"]
+	ev.World.SetZeros()
+	ev.WorldRect(evec.Vec2i{0, 0}, evec.Vec2i{ev.Size.X - 1, ev.Size.Y - 1}, wall)
+	for i := 0; i < nwalls; i++ {
+		st := evec.Vec2i{1 + rnd.Intn(ev.Size.X-2), 1 + rnd.Intn(ev.Size.Y-2)}
+		ln := 2 + rnd.Intn(ints.MaxInt(maxlen-1, 1))
+		if rnd.Intn(2) == 0 {
+			ed := evec.Vec2i{ints.MinInt(st.X+ln, ev.Size.X-2), st.Y}
+			ev.WorldLineHoriz(st, ed, wall)
+		} else {
+			ed := evec.Vec2i{st.X, ints.MinInt(st.Y+ln, ev.Size.Y-2)}
+			ev.WorldLineVert(st, ed, wall)
+		}
+	}
+	ctr := ev.Size.DivScalar(2)
+	ev.WorldFill(ctr.SubScalar(2), ctr.AddScalar(2), 0)
+	ev.Start = ctr
+	ev.StartAngle = 0
+	ev.Goals = nil
+}
+
+// WorldFileSize returns the size of the world in given .tsv file, as saved by
+// SaveWorld: the number of lines, and the number of cells per line, each
+// followed by a tab -- an error if the lines differ in the number of cells
+func WorldFileSize(filename string) (evec.Vec2i, error) {
+	var sz evec.Vec2i
+	fp, err := os.Open(filename)
+	if err != nil {
+		return sz, err
+	}
+	defer fp.Close()
+	scan := bufio.NewScanner(fp)
+	for scan.Scan() {
+		ln := scan.Bytes()
+		if len(ln) == 0 {
+			break
+		}
+		nx := bytes.Count(ln, []byte("\t"))
+		if ln[len(ln)-1] != '\t' {
+			nx++
+		}
+		if sz.Y > 0 && nx != sz.X {
+			return sz, fmt.Errorf("world file %s: line %d has %d cells, but line 1 has %d", filename, sz.Y+1, nx, sz.X)
+		}
+		sz.X = nx
+		sz.Y++
+	}
+	return sz, scan.Err()
+}
+
+// ConfigEvalWorld loads or generates the held-out evaluation world,
+// in the TestEnv, and saves it alongside the logs.  An EvalFile that
+// does not match the size of the world is an error, and the world is
+// then generated from EvalSeed.
+func (ss *Sim) ConfigEvalWorld() {
+	rw := &ss.RandWorld
+	ev := &ss.TestEnv
+	loaded := false
+	if rw.EvalFile != "" {
+		sz, err := WorldFileSize(rw.EvalFile)
+		switch {
+		case err != nil:
+			log.Println(err)
+		case sz != ev.Size:
+			log.Printf("RandWorld: eval world %s is %v, but the world size is %v -- generating it from EvalSeed instead\n", rw.EvalFile, sz, ev.Size)
+		default:
+			if err := ev.OpenWorld(gi.FileName(rw.EvalFile)); err != nil {
+				log.Println(err)
+			} else {
+				loaded = true
+			}
+		}
+	}
+	if !loaded {
+		ev.GenRandWorld(rw.NWalls, rw.MaxLen, rand.New(rand.NewSource(rw.EvalSeed)))
+	}
+	rw.Eval = ev.World.Clone().(*etensor.Int)
+	ev.SaveWorld(gi.FileName(ss.LogFileName("evalworld")))
+}
+
+// NewRandWorld generates the random training world for given run, if On,
+// and saves it alongside the logs -- called in NewRun
+func (ss *Sim) NewRandWorld(run int) {
+	rw := &ss.RandWorld
+	if !rw.On {
+		return
+	}
+	if rw.Eval == nil {
+		ss.ConfigEvalWorld()
+	}
+	ev := &ss.TrainEnv
+	ev.GenRandWorld(rw.NWalls, rw.MaxLen, rand.New(rand.NewSource(rand.Int63())))
+	ev.SaveWorld(gi.FileName(ss.LogFileName(fmt.Sprintf("world_run%03d", run))))
+}
@@ -0,0 +1,343 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ccnlab/map-nav/sims/paramcomp"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/giv"
+)
+
+// Report renders an HTML summary of each run at RunEnd, saved next to the logs,
+// so results can be shared as a single file: run metadata, training epoch
+// curves of EpcStats, the ARFs as images, histograms of the grid scores
+// (gridness, see UnitClass) of the ARF layer units, the fraction of grid cells
+// over training if UnitClass is on, and the params that differ from Base, along
+// with any changed during the run by ApplyParams.  Plots are inline SVG and
+// images are embedded PNG, so the page has no external dependencies.
+type Report struct {
+	On       bool     `desc:"render an HTML summary of each run at RunEnd"`
+	EpcStats []string `desc:"TrnEpcLog columns plotted as epoch curves"`
+	Scale    int      `def:"2" min:"1" desc:"pixels per cell in the ARF images"`
+	NBins    int      `def:"20" min:"2" desc:"number of bins in histograms"`
+}
+
+// Defaults sets default params
+func (rp *Report) Defaults() {
+	rp.EpcStats = []string{"CosDiff", "PosErr", "PosACC", "OriErr", "OriACC"}
+	rp.Scale = 2
+	rp.NBins = 20
+}
+
+// SaveReport renders and saves the HTML report of the current run, if On --
+// called in RunEnd
+func (ss *Sim) SaveReport() {
+	if !ss.Report.On {
+		return
+	}
+	fnm := strings.TrimSuffix(ss.LogFileName(fmt.Sprintf("report_run%03d", ss.TrainEnv.Run.Cur)), ".tsv") + ".html"
+	if err := os.WriteFile(fnm, []byte(ss.RenderReport()), 0644); err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Printf("Saved run report to: %s\n", fnm)
+}
+
+// RenderReport returns the HTML report of the current run
+func (ss *Sim) RenderReport() string {
+	rp := &ss.Report
+	var b strings.Builder
+	title := html.EscapeString(ss.Net.Nm + " " + ss.RunName() + fmt.Sprintf(" run %d", ss.TrainEnv.Run.Cur))
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title>\n", title)
+	b.WriteString("<style>body{font-family:sans-serif;margin:2em} table{border-collapse:collapse} td,th{border:1px solid #ccc;padding:2px 8px;text-align:left} .fig{display:inline-block;margin:0 1em 1em 0;vertical-align:top} pre{background:#f4f4f4;padding:1em}</style>\n")
+	fmt.Fprintf(&b, "</head><body>\n<h1>%s</h1>\n", title)
+
+	b.WriteString("<h2>Run</h2>\n<table>\n")
+	meta := [][2]string{
+		{"Date", time.Now().Format("2006-01-02 15:04")},
+		{"Commit", GitCommit()},
+		{"Network", ss.Net.Nm},
+		{"ParamSet", ss.ParamsName()},
+		{"Tag", ss.Tag},
+		{"Run", fmt.Sprintf("%d", ss.TrainEnv.Run.Cur)},
+		{"Epochs", fmt.Sprintf("%d", ss.TrainEnv.Epoch.Cur)},
+		{"Seed", fmt.Sprintf("%d", ss.RndSeed)},
+		{"World", ss.TrainEnv.Preset.String()},
+		{"Pats", fmt.Sprintf("v%d %s", ss.TrainEnv.PatBank.Version, ss.TrainEnv.PatsHash())},
+		{"ECSize", fmt.Sprintf("%v", ss.Entorhinal.ECSize)},
+		{"ECKernels", ss.Entorhinal.KernelString()},
+	}
+	for _, kv := range meta {
+		fmt.Fprintf(&b, "<tr><th>%s</th><td>%s</td></tr>\n", kv[0], html.EscapeString(kv[1]))
+	}
+	b.WriteString("</table>\n")
+
+	b.WriteString("<h2>Training</h2>\n")
+	dt := ss.TrnEpcLog
+	if xc, err := dt.ColByNameTry("Epoch"); err == nil && dt.Rows > 0 {
+		xs := ColFloats(xc)
+		for _, st := range rp.EpcStats {
+			yc, err := dt.ColByNameTry(st)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&b, "<div class=\"fig\">%s</div>\n", SVGLinePlot(st, xs, ColFloats(yc)))
+		}
+	}
+
+	if len(ss.ARFs.RFs) > 0 {
+		ss.AvgNormARFs(nil)
+		b.WriteString("<h2>Activation-based receptive fields</h2>\n")
+		for _, af := range ss.ARFs.RFs {
+			fmt.Fprintf(&b, "<div class=\"fig\"><div>%s</div><img src=\"%s\"></div>\n", html.EscapeString(af.Name), RFImage(&af.NormRF, rp.Scale))
+		}
+	}
+
+	if len(ss.ARFs.RFs) > 0 {
+		gt := ss.ClassifyUnits(&ss.ARFs, ss.ARFLayers, &ss.TestEnv)
+		if gt.Rows > 0 {
+			b.WriteString("<h2>Grid scores</h2>\n")
+		}
+		lnms, byLay := RowsByLayer(gt)
+		for _, lnm := range lnms {
+			vals := make([]float64, len(byLay[lnm]))
+			for i, row := range byLay[lnm] {
+				vals[i] = gt.CellFloat("Gridness", row)
+			}
+			fmt.Fprintf(&b, "<div class=\"fig\">%s</div>\n", SVGHist(lnm+" Gridness", vals, rp.NBins))
+		}
+	}
+
+	if dt := ss.UnitClassLog; dt.Rows > 0 {
+		b.WriteString("<h2>Unit classes</h2>\n")
+		lnms, byLay := RowsByLayer(dt)
+		for _, lnm := range lnms {
+			xs := make([]float64, len(byLay[lnm]))
+			ys := make([]float64, len(byLay[lnm]))
+			for i, row := range byLay[lnm] {
+				xs[i] = dt.CellFloat("Epoch", row)
+				ys[i] = dt.CellFloat("Grid", row)
+			}
+			fmt.Fprintf(&b, "<div class=\"fig\">%s</div>\n", SVGLinePlot(lnm+" Grid fraction", xs, ys))
+		}
+	}
+
+	b.WriteString("<h2>Params</h2>\n")
+	fmt.Fprintf(&b, "<pre>%s</pre>\n", html.EscapeString(ss.ParamsSetDiff()))
+	if ss.ParamsChanges != "" {
+		b.WriteString("<h3>Changed during run</h3>\n")
+		fmt.Fprintf(&b, "<pre>%s</pre>\n", html.EscapeString(ss.ParamsChanges))
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// RowsByLayer returns the names in the Layer column of given table, in order
+// of first appearance, and the rows of each
+func RowsByLayer(dt *etable.Table) ([]string, map[string][]int) {
+	byLay := map[string][]int{}
+	var lnms []string
+	for row := 0; row < dt.Rows; row++ {
+		lnm := dt.CellString("Layer", row)
+		if _, has := byLay[lnm]; !has {
+			lnms = append(lnms, lnm)
+		}
+		byLay[lnm] = append(byLay[lnm], row)
+	}
+	return lnms, byLay
+}
+
+// ParamsSetDiff returns a listing of the params set by the current ParamSet,
+// composed by ComposeParams, which are applied on top of Base, with the set
+// of each Sel, and the Base value of each param, if any
+func (ss *Sim) ParamsSetDiff() string {
+	if ss.ParamSet == "" || ss.ParamSet == "Base" {
+		return "Base params only\n"
+	}
+	base, err := ss.Params.SetByNameTry("Base")
+	if err != nil {
+		return err.Error() + "\n"
+	}
+	pset, err := ss.ComposeParams()
+	if err != nil {
+		return err.Error() + "\n"
+	}
+	var snms []string
+	for snm := range pset.Sheets {
+		snms = append(snms, snm)
+	}
+	sort.Strings(snms)
+	diff := ""
+	for _, snm := range snms {
+		diff += fmt.Sprintf("Sheet: %s\n", snm)
+		bsht := base.Sheets[snm]
+		for _, sel := range *pset.Sheets[snm] {
+			setNm := paramcomp.SelSet(sel, "")
+			if setNm == "Base" {
+				continue
+			}
+			diff += fmt.Sprintf("  %s  [%s]\n", sel.Sel, setNm)
+			var pnms []string
+			for pnm := range sel.Params {
+				pnms = append(pnms, pnm)
+			}
+			sort.Strings(pnms)
+			for _, pnm := range pnms {
+				bval := "(not set)"
+				if bsht != nil {
+					for _, bsel := range *bsht {
+						if bv, has := bsel.Params[pnm]; has && bsel.Sel == sel.Sel {
+							bval = bv
+						}
+					}
+				}
+				diff += fmt.Sprintf("    %s: %s  (Base: %s)\n", pnm, sel.Params[pnm], bval)
+			}
+		}
+	}
+	return diff
+}
+
+// ColFloats returns the values of given column or tensor as float64s
+func ColFloats(col etensor.Tensor) []float64 {
+	var vals []float64
+	col.Floats(&vals)
+	return vals
+}
+
+// SVGLinePlot returns an inline SVG line plot of ys vs. xs, with given title
+func SVGLinePlot(title string, xs, ys []float64) string {
+	const w, h, mg = 360.0, 200.0, 40.0
+	xmin, xmax := MinMax(xs)
+	ymin, ymax := MinMax(ys)
+	var pts strings.Builder
+	for i, x := range xs {
+		y := ys[i]
+		if math.IsNaN(y) || math.IsInf(y, 0) {
+			continue
+		}
+		fmt.Fprintf(&pts, "%.1f,%.1f ", mg+(w-2*mg)*(x-xmin)/(xmax-xmin), h-mg-(h-2*mg)*(y-ymin)/(ymax-ymin))
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g">`+
+		`<text x="%g" y="20" text-anchor="middle">%s</text>`+
+		`<rect x="%g" y="%g" width="%g" height="%g" fill="none" stroke="#888"/>`+
+		`<polyline points="%s" fill="none" stroke="#1f77b4" stroke-width="1.5"/>`+
+		`<text x="%g" y="%g" font-size="10" text-anchor="end">%.3g</text>`+
+		`<text x="%g" y="%g" font-size="10" text-anchor="end">%.3g</text>`+
+		`<text x="%g" y="%g" font-size="10">%.3g</text>`+
+		`<text x="%g" y="%g" font-size="10" text-anchor="end">%.3g</text>`+
+		`</svg>`, w, h, w/2, html.EscapeString(title), mg, mg, w-2*mg, h-2*mg, pts.String(),
+		mg-2, mg+4, ymax, mg-2, h-mg, ymin, mg, h-mg+12, xmin, w-mg, h-mg+12, xmax)
+}
+
+// SVGHist returns an inline SVG histogram of vals in nbins bins, with given title
+func SVGHist(title string, vals []float64, nbins int) string {
+	const w, h, mg = 360.0, 200.0, 40.0
+	vmin, vmax := MinMax(vals)
+	cnts := make([]int, nbins)
+	mxc := 1
+	for _, v := range vals {
+		bi := int(float64(nbins) * (v - vmin) / (vmax - vmin))
+		if bi >= nbins {
+			bi = nbins - 1
+		}
+		if bi < 0 {
+			continue
+		}
+		cnts[bi]++
+		if cnts[bi] > mxc {
+			mxc = cnts[bi]
+		}
+	}
+	var bars strings.Builder
+	bw := (w - 2*mg) / float64(nbins)
+	for i, c := range cnts {
+		bh := (h - 2*mg) * float64(c) / float64(mxc)
+		fmt.Fprintf(&bars, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#1f77b4"/>`, mg+float64(i)*bw, h-mg-bh, bw-1, bh)
+	}
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g">`+
+		`<text x="%g" y="20" text-anchor="middle">%s</text>%s`+
+		`<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="#888"/>`+
+		`<text x="%g" y="%g" font-size="10" text-anchor="end">%d</text>`+
+		`<text x="%g" y="%g" font-size="10">%.3g</text>`+
+		`<text x="%g" y="%g" font-size="10" text-anchor="end">%.3g</text>`+
+		`</svg>`, w, h, w/2, html.EscapeString(title), bars.String(), mg, h-mg, w-mg, h-mg,
+		mg-2, mg+4, mxc, mg, h-mg+12, vmin, w-mg, h-mg+12, vmax)
+}
+
+// MinMax returns the min and max of the finite vals, with max > min
+func MinMax(vals []float64) (mn, mx float64) {
+	mn, mx = math.Inf(1), math.Inf(-1)
+	for _, v := range vals {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		mn = math.Min(mn, v)
+		mx = math.Max(mx, v)
+	}
+	if math.IsInf(mn, 1) {
+		return 0, 1
+	}
+	if mx <= mn {
+		mx = mn + 1
+	}
+	return
+}
+
+// RFImage returns a PNG data URI image of the given 4D [ActY][ActX][SrcY][SrcX]
+// receptive field, with the source map of each unit tiled in a grid of units,
+// scale pixels per cell, using the ColdHot color map
+func RFImage(rf *etensor.Float32, scale int) string {
+	if rf.NumDims() != 4 {
+		return ""
+	}
+	aNy, aNx, sNy, sNx := rf.Dim(0), rf.Dim(1), rf.Dim(2), rf.Dim(3)
+	vmin, vmax := MinMax(ColFloats(rf))
+	cm := giv.AvailColorMaps["ColdHot"]
+	img := image.NewRGBA(image.Rect(0, 0, aNx*(sNx+1)*scale, aNy*(sNy+1)*scale))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for ay := 0; ay < aNy; ay++ {
+		for ax := 0; ax < aNx; ax++ {
+			for sy := 0; sy < sNy; sy++ {
+				for sx := 0; sx < sNx; sx++ {
+					v := (float64(rf.Value([]int{ay, ax, sy, sx})) - vmin) / (vmax - vmin)
+					c := cm.Map(v)
+					clr := color.RGBA{c.R, c.G, c.B, 255}
+					// Y is up, as in the grid views
+					py := ((aNy-1-ay)*(sNy+1) + sNy - 1 - sy) * scale
+					px := (ax*(sNx+1) + sx) * scale
+					for dy := 0; dy < scale; dy++ {
+						for dx := 0; dx < scale; dx++ {
+							img.Set(px+dx, py+dy, clr)
+						}
+					}
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		log.Println(err)
+		return ""
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
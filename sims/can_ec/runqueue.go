@@ -0,0 +1,129 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/goki/gi/gi"
+)
+
+// RunQueueItem is one configuration to run in the RunQueue
+type RunQueueItem struct {
+	ParamSet string `desc:"ParamSet name(s) to use on top of Base"`
+	Tag      string `desc:"extra tag string to add to file names for this configuration"`
+	MaxEpcs  int    `desc:"maximum number of epochs to run per model run"`
+	MaxRuns  int    `desc:"number of model runs to perform"`
+	Done     bool   `desc:"all runs have completed -- items that were interrupted are run again from the start"`
+}
+
+// RunQueue is a queue of configurations that are run sequentially, each producing
+// its own logs and weights.  It is saved to File whenever it changes,
+// so it survives restarts.
+type RunQueue struct {
+	Items []*RunQueueItem `desc:"the configurations to run, in order"`
+	File  gi.FileName     `desc:"file the queue is saved to (JSON)"`
+}
+
+func (rq *RunQueue) Defaults() {
+	rq.File = "runqueue.json"
+}
+
+// Next returns the next item that is not Done, or nil if none
+func (rq *RunQueue) Next() *RunQueueItem {
+	for _, it := range rq.Items {
+		if !it.Done {
+			return it
+		}
+	}
+	return nil
+}
+
+// Save saves the queue to File
+func (rq *RunQueue) Save() error {
+	b, err := json.MarshalIndent(rq.Items, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = os.WriteFile(string(rq.File), b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// Open opens the queue from File -- it is not an error if File does not exist
+func (rq *RunQueue) Open() error {
+	b, err := os.ReadFile(string(rq.File))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		log.Println(err)
+		return err
+	}
+	err = json.Unmarshal(b, &rq.Items)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// Enqueue adds a configuration to the end of the run queue, and saves the queue
+func (ss *Sim) Enqueue(paramSet, tag string, epochs, runs int) {
+	ss.Queue.Items = append(ss.Queue.Items, &RunQueueItem{ParamSet: paramSet, Tag: tag, MaxEpcs: epochs, MaxRuns: runs})
+	ss.Queue.Save()
+}
+
+// RunQueue runs each remaining configuration in the Queue in turn, saving
+// epoch and run logs and final weights for each.  Stop interrupts the current
+// configuration, which is then run again from the start by the next RunQueue.
+// The Sim settings set by each configuration are restored after it is run.
+func (ss *Sim) RunQueue() {
+	ss.Queue.Save() // capture any edits
+	for {
+		it := ss.Queue.Next()
+		if it == nil {
+			break
+		}
+		prv, saveWts := ss.QueueSettings(), ss.SaveWts
+		ss.SetQueueSettings(it)
+		ss.SaveWts = true
+		ss.Init()
+		fmt.Printf("Run Queue: running %s for %d runs of %d epochs\n", ss.RunName(), ss.MaxRuns, ss.MaxEpcs)
+		ss.TrnEpcFile = ss.CreateLogFile("trn_epc")
+		ss.TstEpcFile = ss.CreateLogFile("tst_epc")
+		ss.RunFile = ss.CreateLogFile("run")
+		ss.IsRunning = true
+		ss.Train()
+		ss.CloseLogFiles()
+		done := ss.RunsDone
+		ss.SetQueueSettings(&prv)
+		ss.SaveWts = saveWts
+		if !done {
+			break // stopped
+		}
+		it.Done = true
+		ss.Queue.Save()
+	}
+	ss.Stopped()
+}
+
+// QueueSettings returns the current Sim settings that are set by a RunQueue item
+func (ss *Sim) QueueSettings() RunQueueItem {
+	return RunQueueItem{ParamSet: ss.ParamSet, Tag: ss.Tag, MaxEpcs: ss.MaxEpcs, MaxRuns: ss.MaxRuns}
+}
+
+// SetQueueSettings sets the Sim settings from given RunQueue item
+func (ss *Sim) SetQueueSettings(it *RunQueueItem) {
+	ss.ParamSet = it.ParamSet
+	ss.Tag = it.Tag
+	ss.MaxEpcs = it.MaxEpcs
+	ss.MaxRuns = it.MaxRuns
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+////////////////////////////////////////////////////////////////////
+// Env: head-scan and pause behaviors
+
+// ScanParams configures the pause-and-scan behaviors of the reflexive policy
+// (ReflexAct): while exploring in the open, the agent occasionally stops and
+// scans, rotating in place by Sweep degrees to one side and back, in NHeads
+// equal turns each way, sampling multiple headings from the same location
+// whatever the AngInc, or pauses in place for PauseSteps steps.
+// Head-scanning bouts are where head-direction and landmark integration is
+// most visible in real animals.
+type ScanParams struct {
+	On         bool    `desc:"enable the pause-and-scan behaviors"`
+	PScan      float32 `viewif:"On" def:"0.02" min:"0" max:"1" desc:"probability of starting a scan on each step of exploration in the open"`
+	Sweep      int     `viewif:"On" def:"90" min:"1" desc:"angle of a scan to one side, in degrees -- the scan turns back to the starting heading after"`
+	NHeads     int     `viewif:"On" def:"3" min:"1" desc:"number of turns in place of Sweep / NHeads degrees to each side in a scan, each sampling a new heading"`
+	PPause     float32 `viewif:"On" def:"0.02" min:"0" max:"1" desc:"probability of starting a pause on each step of exploration in the open"`
+	PauseSteps int     `viewif:"On" def:"3" min:"1" desc:"number of steps of a pause"`
+}
+
+// Defaults sets default params
+func (sp *ScanParams) Defaults() {
+	sp.PScan = 0.02
+	sp.Sweep = 90
+	sp.NHeads = 3
+	sp.PPause = 0.02
+	sp.PauseSteps = 3
+}
+
+// ScanCounts are counts of scan and pause bouts and their steps, accumulated
+// by the env since Init
+type ScanCounts struct {
+	Scans      int `desc:"number of scans started"`
+	ScanSteps  int `desc:"number of steps turning in place in scans"`
+	Pauses     int `desc:"number of pauses started"`
+	PauseSteps int `desc:"number of steps paused in place"`
+}
+
+// InitScan initializes the scan and pause state -- called in Init
+func (ev *XYHDEnv) InitScan() {
+	ev.EndBout()
+	ev.ScanCnt = ScanCounts{}
+}
+
+// EndBout ends any scan or pause bout in progress
+func (ev *XYHDEnv) EndBout() {
+	ev.Bout = ""
+	ev.BoutActs = ev.BoutActs[:0]
+}
+
+// StartScan starts a scan bout: turns in place by Sweep degrees in a random
+// direction, in NHeads turns, then back to the current heading
+func (ev *XYHDEnv) StartScan() {
+	n := ev.Scan.NHeads
+	if n < 1 {
+		n = 1
+	}
+	turn := float32(ev.Scan.Sweep) / float32(n)
+	dir, back := Left, Right
+	if rand.Intn(2) == 0 {
+		dir, back = Right, Left
+	}
+	ev.EndBout()
+	for i := 0; i < n; i++ {
+		ev.BoutActs = append(ev.BoutActs, Action{Act: dir, Turn: turn, Step: NoStep})
+	}
+	for i := 0; i < n; i++ {
+		ev.BoutActs = append(ev.BoutActs, Action{Act: back, Turn: turn, Step: NoStep})
+	}
+	ev.Bout = "Scan"
+	ev.ScanCnt.Scans++
+}
+
+// StartPause starts a pause bout of PauseSteps steps in place
+func (ev *XYHDEnv) StartPause() {
+	ev.EndBout()
+	for i := 0; i < ev.Scan.PauseSteps; i++ {
+		ev.BoutActs = append(ev.BoutActs, Action{Act: Forward, Step: NoStep})
+	}
+	ev.Bout = "Pause"
+	ev.ScanCnt.Pauses++
+}
+
+// ReflexAct returns the action of the reflexive policy: the next in-place
+// action of a scan or pause bout in progress, or one newly started with
+// PScan or PPause while exploring in the open, and otherwise the ActGen
+// action.  Bout is the bout of the returned action, if any.
+func (ev *XYHDEnv) ReflexAct() Action {
+	sp := &ev.Scan
+	if len(ev.BoutActs) == 0 {
+		ev.Bout = ""
+		open := !ev.IsStuck && ev.ProxMats[0] != ev.MatMap["Wall"]
+		if !sp.On || !open {
+			return NewAction(Actions(ev.ActGen()))
+		}
+		frnd := rand.Float32()
+		switch {
+		case frnd < sp.PScan:
+			ev.StartScan()
+		case frnd < sp.PScan+sp.PPause:
+			ev.StartPause()
+		default:
+			return NewAction(Actions(ev.ActGen()))
+		}
+	}
+	act := ev.BoutActs[0]
+	ev.BoutActs = ev.BoutActs[1:]
+	if ev.Bout == "Scan" {
+		ev.ScanCnt.ScanSteps++
+	} else {
+		ev.ScanCnt.PauseSteps++
+	}
+	ev.ActGenTrace(ev.Bout, int(act.Act))
+	return act
+}
+
+////////////////////////////////////////////////////////////////////
+// Sim: per-trial scan and pause logging
+
+// ScanCols are the TrnTrlLog columns marking the scan and pause steps of the
+// trial
+var ScanCols = []string{"ScanSteps", "PauseSteps"}
+
+// LogScanTrl records the number of steps of the trial in scan and pause
+// bouts, from the TrlSteps, in given row of the TrnTrlLog
+func (ss *Sim) LogScanTrl(dt *etable.Table, row int) {
+	nscan, npause := 0, 0
+	for _, st := range ss.TrlSteps {
+		switch st.Bout {
+		case "Scan":
+			nscan++
+		case "Pause":
+			npause++
+		}
+	}
+	dt.SetCellFloat("ScanSteps", row, float64(nscan))
+	dt.SetCellFloat("PauseSteps", row, float64(npause))
+}
+
+// ConfigScanCols adds the ScanCols to given TrnTrlLog schema
+func ConfigScanCols(sch etable.Schema) etable.Schema {
+	sch = append(sch, etable.Column{"ScanSteps", etensor.INT64, nil, nil})
+	sch = append(sch, etable.Column{"PauseSteps", etensor.INT64, nil, nil})
+	return sch
+}
+
+// ConfigScanPlot sets the TrnTrlPlot params for ScanCols
+func ConfigScanPlot(plt *eplot.Plot2D) {
+	plt.SetColParams("ScanSteps", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PauseSteps", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+}
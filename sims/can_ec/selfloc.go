@@ -0,0 +1,209 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/mat32"
+)
+
+// SelfLoc drives the Prev_Position and Prev_Orientation inputs from the network's
+// own decoded Out_Position and Orientation outputs on the previous trial, blended
+// with the env ground truth, so the model must self-localize over time.
+// Ground truth is restored every ResetInt trials, and the drift of the
+// estimates is tracked as a function of steps since the last reset.
+type SelfLoc struct {
+	On        bool    `desc:"drive Prev_ inputs from the network's own previous estimates"`
+	BlendSt   float32 `def:"0" min:"0" max:"1" desc:"starting blend factor: weight on the network's own estimate vs. ground truth (0 = all ground truth)"`
+	BlendEd   float32 `def:"1" min:"0" max:"1" desc:"final blend factor, reached after BlendEpcs epochs"`
+	BlendEpcs int     `def:"50" min:"0" desc:"number of epochs over which the blend factor ramps linearly from BlendSt to BlendEd"`
+	ResetInt  int     `def:"50" min:"0" desc:"number of trials between ground-truth resets -- 0 = only at the start of each run"`
+	MaxSteps  int     `def:"200" desc:"maximum number of steps since reset tracked in the SelfLocLog"`
+
+	Blend    float32    `inactive:"+" desc:"current blend factor"`
+	Steps    int        `inactive:"+" desc:"number of steps since the last ground-truth reset"`
+	PosEst   mat32.Vec2 `inactive:"+" desc:"previous trial's decoded position estimate, in world units"`
+	OriEst   float32    `inactive:"+" desc:"previous trial's decoded orientation estimate, in degrees"`
+	PosDrift float64    `inactive:"+" desc:"distance of the current decoded position from ground truth, in world units"`
+	OriDrift float64    `inactive:"+" desc:"absolute angular difference of the current decoded orientation from ground truth, in degrees"`
+
+	SumPos []float64                   `view:"-" desc:"sum of PosDrift by Steps over the epoch"`
+	SumOri []float64                   `view:"-" desc:"sum of OriDrift by Steps over the epoch"`
+	NSteps []int                       `view:"-" desc:"number of trials by Steps over the epoch"`
+	Pats   map[string]*etensor.Float32 `view:"-" desc:"blended input patterns, by layer"`
+}
+
+func (sl *SelfLoc) Defaults() {
+	sl.BlendSt = 0
+	sl.BlendEd = 1
+	sl.BlendEpcs = 50
+	sl.ResetInt = 50
+	sl.MaxSteps = 200
+}
+
+// Reset resets all state -- called at start of a new run
+func (sl *SelfLoc) Reset() {
+	sl.Steps = 0
+	sl.Blend = sl.BlendSt
+	sl.ResetEpc()
+}
+
+// ResetEpc resets the drift accumulators
+func (sl *SelfLoc) ResetEpc() {
+	sl.SumPos = make([]float64, sl.MaxSteps+1)
+	sl.SumOri = make([]float64, sl.MaxSteps+1)
+	sl.NSteps = make([]int, sl.MaxSteps+1)
+}
+
+// SetBlend sets the current Blend factor for given epoch, according to the schedule
+func (sl *SelfLoc) SetBlend(epc int) {
+	if sl.BlendEpcs <= 0 || epc >= sl.BlendEpcs {
+		sl.Blend = sl.BlendEd
+		return
+	}
+	sl.Blend = sl.BlendSt + (sl.BlendEd-sl.BlendSt)*float32(epc)/float32(sl.BlendEpcs)
+}
+
+// SelfLocPats returns the input pattern to apply to given layer, blending the given
+// ground truth pats for env state stnm with the network's previous estimate, for the
+// Prev_Position and Prev_Orientation layers.  Returns pats unchanged for other layers,
+// or right after a ground-truth reset.
+func (ss *Sim) SelfLocPats(lnm, stnm string, pats etensor.Tensor) etensor.Tensor {
+	sl := &ss.SelfLoc
+	if !sl.On || sl.Steps == 0 || sl.Blend == 0 {
+		return pats
+	}
+	ev := &ss.TrainEnv
+	var est mat32.Vec2
+	switch lnm {
+	case "Prev_Position":
+		est = sl.PosEst
+		est.X /= float32(ev.Size.X) - 2
+		est.Y /= float32(ev.Size.Y) - 2
+	case "Prev_Orientation":
+		est = mat32.Vec2{float32(AngNorm(float64(sl.OriEst))) / 360, 0}
+	default:
+		return pats
+	}
+	if sl.Pats == nil {
+		sl.Pats = make(map[string]*etensor.Float32)
+	}
+	tsr, ok := sl.Pats[lnm]
+	if !ok {
+		tsr = etensor.NewFloat32(pats.Shapes(), nil, nil)
+		sl.Pats[lnm] = tsr
+	}
+	ev.Encs[stnm].Encode(tsr, est)
+	gt := pats.(*etensor.Float32)
+	for i, v := range gt.Values {
+		tsr.Values[i] = (1-sl.Blend)*v + sl.Blend*tsr.Values[i]
+	}
+	return tsr
+}
+
+// DecodeOri decodes the orientation, in degrees, from the Orientation layer
+// minus-phase activity
+func (ss *Sim) DecodeOri() float32 {
+	ori := ss.Net.LayerByName("Orientation").(leabra.LeabraLayer).AsLeabra()
+	ori_tsr := make([]float32, len(ori.Neurons))
+	for i, val := range ori.Neurons {
+		ori_tsr[i] = val.ActM
+	}
+	return float32(AngNorm(float64(ss.TrainEnv.AngCode.Decode(ori_tsr) * 360)))
+}
+
+// SelfLocTrial records the network's decoded estimates for the next trial,
+// accumulates drift by steps since reset, and resets to ground truth every
+// ResetInt trials -- called in TrainTrial after AlphaCyc
+func (ss *Sim) SelfLocTrial() {
+	sl := &ss.SelfLoc
+	if !sl.On {
+		return
+	}
+	ev := &ss.TrainEnv
+	sl.PosEst = ss.DecodePos(ev)
+	sl.OriEst = ss.DecodeOri()
+	sl.PosDrift = float64(sl.PosEst.Sub(ev.PosF).Length())
+	sl.OriDrift = math.Abs(AngDiff(float64(sl.OriEst), float64(ev.Angle)))
+	if sl.Steps <= sl.MaxSteps {
+		sl.SumPos[sl.Steps] += sl.PosDrift
+		sl.SumOri[sl.Steps] += sl.OriDrift
+		sl.NSteps[sl.Steps]++
+	}
+	sl.Steps++
+	if sl.ResetInt > 0 && sl.Steps >= sl.ResetInt {
+		sl.Steps = 0
+	}
+}
+
+// LogSelfLoc records the average drift by steps since reset over the last epoch,
+// replacing the prior epoch, and updates the Blend for the next epoch --
+// called in LogTrnEpc
+func (ss *Sim) LogSelfLoc(dt *etable.Table) {
+	sl := &ss.SelfLoc
+	if !sl.On {
+		return
+	}
+	epc := ss.TrainEnv.Epoch.Prv
+	dt.SetNumRows(0)
+	for st, n := range sl.NSteps {
+		if n == 0 {
+			continue
+		}
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+		dt.SetCellFloat("Epoch", row, float64(epc))
+		dt.SetCellFloat("Blend", row, float64(sl.Blend))
+		dt.SetCellFloat("Steps", row, float64(st))
+		dt.SetCellFloat("N", row, float64(n))
+		dt.SetCellFloat("PosDrift", row, sl.SumPos[st]/float64(n))
+		dt.SetCellFloat("OriDrift", row, sl.SumOri[st]/float64(n))
+		ss.SQLWriteRow("selfloc", dt, row)
+	}
+	sl.ResetEpc()
+	sl.SetBlend(epc + 1)
+
+	ss.UpdtPlot(ss.SelfLocPlot)
+}
+
+func (ss *Sim) ConfigSelfLocLog(dt *etable.Table) {
+	dt.SetMetaData("name", "SelfLocLog")
+	dt.SetMetaData("desc", "Drift of self-localization estimates from ground truth as a function of steps since the last ground-truth reset, over the last epoch")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Blend", etensor.FLOAT64, nil, nil},
+		{"Steps", etensor.INT64, nil, nil},
+		{"N", etensor.INT64, nil, nil},
+		{"PosDrift", etensor.FLOAT64, nil, nil},
+		{"OriDrift", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigSelfLocPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Self-Localization Drift Plot"
+	plt.Params.XAxisCol = "Steps"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Blend", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Steps", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("N", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PosDrift", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("OriDrift", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	return plt
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/mat32"
+)
+
+// EnvStep is a snapshot of the state of an XYHDEnv after a Step: the rendered
+// input states and the position, heading and action values that the sim's
+// stats, decoding and receptive fields are computed from.
+type EnvStep struct {
+	States    map[string]*etensor.Float32 `desc:"copy of the env CurStates"`
+	PrevPosF  mat32.Vec2                  `desc:"previous location, floating point"`
+	PrevPosI  evec.Vec2i                  `desc:"previous location, integer"`
+	PosF      mat32.Vec2                  `desc:"location, floating point"`
+	PosI      evec.Vec2i                  `desc:"location, integer"`
+	PrevAngle int                         `desc:"previous angle, in degrees"`
+	Angle     int                         `desc:"angle, in degrees"`
+	RotAng    int                         `desc:"angle just rotated"`
+	Act       int                         `desc:"action taken"`
+	ActCmd    Action                      `desc:"action commanded"`
+	ActExec   Action                      `desc:"action executed"`
+	ProxDist  []int                       `desc:"distance to nearest barrier in each direction"`
+	GoalDir   float32                     `desc:"egocentric direction to the goal"`
+	GoalDist  float32                     `desc:"distance to the goal"`
+}
+
+// Save records the current state of given env
+func (es *EnvStep) Save(ev *XYHDEnv) {
+	if es.States == nil {
+		es.States = make(map[string]*etensor.Float32)
+	}
+	for k, cs := range ev.CurStates {
+		st, ok := es.States[k]
+		if !ok {
+			st = etensor.NewFloat32(cs.Shapes(), nil, nil)
+			es.States[k] = st
+		}
+		st.CopyFrom(cs)
+	}
+	es.PrevPosF = ev.PrevPosF
+	es.PrevPosI = ev.PrevPosI
+	es.PosF = ev.PosF
+	es.PosI = ev.PosI
+	es.PrevAngle = ev.PrevAngle
+	es.Angle = ev.Angle
+	es.RotAng = ev.RotAng
+	es.Act = ev.Act
+	es.ActCmd = ev.ActCmd
+	es.ActExec = ev.ActExec
+	es.ProxDist = append(es.ProxDist[:0], ev.ProxDist...)
+	es.GoalDir = ev.GoalDir
+	es.GoalDist = ev.GoalDist
+}
+
+// CopyFrom copies the recorded state of another step
+func (es *EnvStep) CopyFrom(src *EnvStep) {
+	sts := es.States
+	if sts == nil {
+		sts = make(map[string]*etensor.Float32)
+	}
+	for k, sst := range src.States {
+		st, ok := sts[k]
+		if !ok {
+			st = etensor.NewFloat32(sst.Shapes(), nil, nil)
+			sts[k] = st
+		}
+		st.CopyFrom(sst)
+	}
+	pd := append(es.ProxDist[:0], src.ProxDist...)
+	*es = *src
+	es.States = sts
+	es.ProxDist = pd
+}
+
+// Restore sets the state of given env to the recorded state
+func (es *EnvStep) Restore(ev *XYHDEnv) {
+	for k, st := range es.States {
+		if cs, ok := ev.CurStates[k]; ok {
+			cs.CopyFrom(st)
+		}
+	}
+	ev.PrevPosF = es.PrevPosF
+	ev.PrevPosI = es.PrevPosI
+	ev.PosF = es.PosF
+	ev.PosI = es.PosI
+	ev.PrevAngle = es.PrevAngle
+	ev.Angle = es.Angle
+	ev.RotAng = es.RotAng
+	ev.Act = es.Act
+	ev.ActCmd = es.ActCmd
+	ev.ActExec = es.ActExec
+	copy(ev.ProxDist, es.ProxDist)
+	ev.GoalDir = es.GoalDir
+	ev.GoalDist = es.GoalDist
+}
+
+// ShuffleEnv wraps an XYHDEnv for a control condition in which the temporal
+// order of its steps is shuffled, breaking trajectory continuity while keeping
+// the same distribution of inputs.  The agent moves along its trajectory as
+// usual, but after each Step, the step is added to a buffer of BufSize steps,
+// and the env is set to a randomly drawn step from the buffer, which is
+// removed and replaced by the new step once the buffer is full.  While the
+// buffer is filling at the start of a run, steps are drawn with replacement.
+// Unshuffle restores the actual current step before the next action.
+type ShuffleEnv struct {
+	On      bool      `desc:"shuffle the order of training steps"`
+	BufSize int       `def:"1000" min:"1" desc:"number of steps in the shuffle buffer -- steps are displaced in time by about this many trials on average"`
+	Env     *XYHDEnv  `view:"-" desc:"the env being shuffled"`
+	Buf     []EnvStep `view:"-" desc:"buffered steps"`
+	Real    EnvStep   `view:"-" desc:"the actual current step of the env, while it is set to a shuffled step"`
+	Swapped bool      `inactive:"+" desc:"env is currently set to a shuffled step"`
+}
+
+// Defaults sets default params
+func (se *ShuffleEnv) Defaults() {
+	se.BufSize = 1000
+}
+
+// Reset empties the buffer -- called at start of a new run
+func (se *ShuffleEnv) Reset() {
+	se.Buf = se.Buf[:0]
+	se.Swapped = false
+}
+
+// Shuffle sets the env to a shuffled step, adding its current step to the
+// buffer -- called after the env Step
+func (se *ShuffleEnv) Shuffle() {
+	if !se.On || se.Env == nil {
+		return
+	}
+	se.Unshuffle()
+	se.Real.Save(se.Env)
+	if len(se.Buf) < se.BufSize {
+		se.Buf = append(se.Buf, EnvStep{})
+		se.Buf[len(se.Buf)-1].CopyFrom(&se.Real)
+		se.Buf[rand.Intn(len(se.Buf))].Restore(se.Env)
+	} else {
+		es := &se.Buf[rand.Intn(len(se.Buf))]
+		es.Restore(se.Env)
+		es.CopyFrom(&se.Real)
+	}
+	se.Swapped = true
+}
+
+// Unshuffle restores the actual current step of the env, if it is set to a
+// shuffled step -- called before taking the next action
+func (se *ShuffleEnv) Unshuffle() {
+	if !se.Swapped {
+		return
+	}
+	se.Real.Restore(se.Env)
+	se.Swapped = false
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/etview"
+	"github.com/goki/mat32"
+)
+
+// SimMat is a live similarity matrix of the minus-phase activity (ActM) of
+// Layer during testing, for inspecting representational drift across an epoch.
+// The first NRefs test trials of each epoch are stored as references, and Mat
+// holds the cosine similarity between each pair of references, with the current
+// trial in the last row and column.
+type SimMat struct {
+	On     bool               `desc:"update the similarity matrix on each testing trial"`
+	Layer  string             `desc:"layer whose ActM activity is compared"`
+	NRefs  int                `def:"20" min:"1" desc:"number of reference trials, stored from the start of each test epoch"`
+	Refs   []*etensor.Float32 `view:"-" desc:"stored ActM of the reference trials"`
+	Cur    *etensor.Float32   `view:"-" desc:"ActM of the current trial"`
+	Mat    *etensor.Float32   `view:"no-inline" desc:"cosine similarity matrix [NRefs+1][NRefs+1] of the references, and the current trial in the last row and column"`
+	Epoch  int                `inactive:"+" desc:"test epoch that the references were stored in"`
+	RefLay string             `view:"-" desc:"Layer when the references were stored -- changing Layer resets them"`
+}
+
+// Defaults sets default params
+func (sm *SimMat) Defaults() {
+	sm.Layer = "EC"
+	sm.NRefs = 20
+	sm.Mat = &etensor.Float32{}
+}
+
+// Reset clears the references and the matrix -- called at start of a new run
+// and of each test epoch
+func (sm *SimMat) Reset() {
+	sm.Refs = sm.Refs[:0]
+	sm.Epoch = -1
+	sm.RefLay = sm.Layer
+	sm.Mat.SetShape([]int{sm.NRefs + 1, sm.NRefs + 1}, nil, []string{"Trial", "Trial"})
+	sm.Mat.SetZeros()
+}
+
+// Cosine returns the cosine similarity of two activity patterns
+func (sm *SimMat) Cosine(a, b *etensor.Float32) float32 {
+	var ab, aa, bb float32
+	for i, av := range a.Values {
+		bv := b.Values[i]
+		ab += av * bv
+		aa += av * av
+		bb += bv * bv
+	}
+	if aa == 0 || bb == 0 {
+		return 0
+	}
+	return ab / mat32.Sqrt(aa*bb)
+}
+
+// SimMatTrial records the activity of the SimMat layer on the current test
+// trial and updates the matrix and its view -- called in TestTrial
+func (ss *Sim) SimMatTrial() {
+	sm := &ss.SimMat
+	if !sm.On {
+		return
+	}
+	ly := ss.Net.LayerByName(sm.Layer)
+	if ly == nil {
+		log.Printf("SimMat: layer not found: %s\n", sm.Layer)
+		sm.On = false
+		return
+	}
+	epc := ss.TestEnv.Epoch.Cur
+	if sm.Epoch != epc || sm.RefLay != sm.Layer || sm.Mat.Dim(0) != sm.NRefs+1 {
+		sm.Reset()
+		sm.Epoch = epc
+		ss.ConfigSimMatView()
+	}
+	if sm.Cur == nil {
+		sm.Cur = &etensor.Float32{}
+	}
+	ly.UnitValsTensor(sm.Cur, "ActM")
+
+	nr := len(sm.Refs)
+	if nr < sm.NRefs {
+		ref := sm.Cur.Clone().(*etensor.Float32)
+		sm.Refs = append(sm.Refs, ref)
+		for i, ri := range sm.Refs {
+			cs := sm.Cosine(ri, ref)
+			sm.Mat.Set([]int{i, nr}, cs)
+			sm.Mat.Set([]int{nr, i}, cs)
+		}
+	}
+	for i, ri := range sm.Refs {
+		cs := sm.Cosine(ri, sm.Cur)
+		sm.Mat.Set([]int{i, sm.NRefs}, cs)
+		sm.Mat.Set([]int{sm.NRefs, i}, cs)
+	}
+	sm.Mat.Set([]int{sm.NRefs, sm.NRefs}, 1)
+
+	if ss.SimMatView != nil {
+		ss.SimMatView.UpdateSig()
+	}
+}
+
+// ConfigSimMatView sets the SimMat view to the current matrix
+func (ss *Sim) ConfigSimMatView() {
+	tg := ss.SimMatView
+	if tg == nil {
+		return
+	}
+	tg.SetTensor(ss.SimMat.Mat)
+}
+
+// ConfigSimMatGrid configures the similarity matrix grid view
+func (ss *Sim) ConfigSimMatGrid(tg *etview.TensorGrid) {
+	tg.Disp.Defaults()
+	tg.Disp.ColorMap = "ColdHot"
+	tg.Disp.Range.SetMin(-1)
+	tg.Disp.Range.SetMax(1)
+	tg.SetStretchMax()
+	tg.SetTensor(ss.SimMat.Mat)
+}
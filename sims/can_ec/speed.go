@@ -0,0 +1,176 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// SpeedCells detects speed cells: the ActM of each unit of the Layers is
+// regressed against the movement speed of the agent (the mean distance moved
+// in the TrainEnv per action step of the trial, which varies continuously
+// with MotorNoise) over the training trials, and on the Periodic schedule
+// the slope and R^2 of each unit are logged to the SpeedLog, with the Pctile
+// percentile of the R^2 of NShuffle controls in which the speeds are
+// circularly shifted relative to the activities by at least MinShift trials.
+type SpeedCells struct {
+	Periodic
+	NShuffle int     `def:"100" min:"1" desc:"number of shuffle controls"`
+	Pctile   float64 `def:"0.99" min:"0" max:"1" desc:"percentile of the shuffled R^2 that R^2 must exceed to be significant"`
+	MinShift int     `def:"20" min:"1" desc:"minimum circular shift of the speeds in the shuffle controls, in trials"`
+
+	Speeds []float64            `view:"-" desc:"speed on each trial since the last regressions"`
+	Dist   float64              `view:"-" desc:"distance moved over the action steps of the current trial so far"`
+	NSteps int                  `view:"-" desc:"number of action steps of the current trial so far"`
+	Acts   map[string][]float32 `view:"-" desc:"ActM of the units of each layer on each trial since the last regressions, trial-major"`
+}
+
+// Defaults sets default params
+func (sc *SpeedCells) Defaults() {
+	sc.NShuffle = 100
+	sc.Pctile = 0.99
+	sc.MinShift = 20
+}
+
+// Reset clears the recorded trials
+func (sc *SpeedCells) Reset() {
+	sc.Dist = 0
+	sc.NSteps = 0
+	sc.Speeds = sc.Speeds[:0]
+	for lnm := range sc.Acts {
+		sc.Acts[lnm] = sc.Acts[lnm][:0]
+	}
+}
+
+// Regress returns the slope, intercept and R^2 of the regression of ys on
+// xs, with xs shifted circularly by shift -- R^2 is 0 if either is constant
+func Regress(xs, ys []float64, shift int) (slope, icpt, r2 float64) {
+	n := len(xs)
+	var ca corrAcc
+	for i, y := range ys {
+		ca.Add(xs[(i+shift)%n], y)
+	}
+	if ca.n == 0 {
+		return
+	}
+	vx := ca.n*ca.sxx - ca.sx*ca.sx
+	if vx <= 0 {
+		return
+	}
+	slope = (ca.n*ca.sxy - ca.sx*ca.sy) / vx
+	icpt = (ca.sy - slope*ca.sx) / ca.n
+	if r, ok := ca.Corr(2); ok {
+		r2 = r * r
+	}
+	return
+}
+
+// AddSteps adds the distances moved by given action steps of the current
+// training trial -- called after each TakeAction on the TrainEnv, as there
+// can be several per trial (TrialSteps)
+func (sc *SpeedCells) AddSteps(steps []ActStep) {
+	if sc.Every <= 0 {
+		return
+	}
+	for _, st := range steps {
+		sc.Dist += float64(st.Dist)
+	}
+	sc.NSteps += len(steps)
+}
+
+// SpeedTrial records the speed, as the mean distance moved per action step
+// of the trial, and the activities of the units of given layers, for the
+// current training trial -- the Trial of the speed PeriodicAnalysis
+func (ss *Sim) SpeedTrial(lays []string) {
+	sc := &ss.Speed
+	spd := 0.0
+	if sc.NSteps > 0 {
+		spd = sc.Dist / float64(sc.NSteps)
+	}
+	sc.Dist = 0
+	sc.NSteps = 0
+	sc.Speeds = append(sc.Speeds, spd)
+	if sc.Acts == nil {
+		sc.Acts = make(map[string][]float32)
+	}
+	for _, lnm := range lays {
+		ly := ss.Net.LayerByName(lnm)
+		if ly == nil {
+			continue
+		}
+		vt := ss.ValsTsr(lnm)
+		ly.UnitValsTensor(vt, "ActM")
+		sc.Acts[lnm] = append(sc.Acts[lnm], vt.Values...)
+	}
+}
+
+// SpeedEpoch regresses the activities of the units of given layers on speed
+// over the trials since the last time, adding the results per unit to dt --
+// the Epoch of the speed PeriodicAnalysis
+func (ss *Sim) SpeedEpoch(dt *etable.Table, epc int, lays []string) {
+	sc := &ss.Speed
+	n := len(sc.Speeds)
+	if n == 0 {
+		return
+	}
+	shifts := make([]int, sc.NShuffle)
+	for i := range shifts {
+		if n > 2*sc.MinShift {
+			shifts[i] = sc.MinShift + ss.ShufRand.Intn(n-2*sc.MinShift+1)
+		} else {
+			shifts[i] = 1 + ss.ShufRand.Intn(n) // too few trials for MinShift
+		}
+	}
+	ys := make([]float64, n)
+	shuf := make([]float64, len(shifts))
+	for _, lnm := range lays {
+		acts := sc.Acts[lnm]
+		if len(acts) == 0 || len(acts)%n != 0 {
+			continue
+		}
+		nu := len(acts) / n
+		for ui := 0; ui < nu; ui++ {
+			for ti := range ys {
+				ys[ti] = float64(acts[ti*nu+ui])
+			}
+			slope, icpt, r2 := Regress(sc.Speeds, ys, 0)
+			for si, sh := range shifts {
+				_, _, shuf[si] = Regress(sc.Speeds, ys, sh)
+			}
+			sort.Float64s(shuf)
+			thr := shuf[int(sc.Pctile*float64(len(shuf)-1))]
+			row := ss.AddPeriodicRow(dt, epc, lnm)
+			dt.SetCellFloat("Unit", row, float64(ui))
+			dt.SetCellFloat("Slope", row, slope)
+			dt.SetCellFloat("Icpt", row, icpt)
+			dt.SetCellFloat("R2", row, r2)
+			dt.SetCellFloat("ShufThr", row, thr)
+			if r2 > thr {
+				dt.SetCellFloat("Sig", row, 1)
+			}
+		}
+	}
+}
+
+func (ss *Sim) ConfigSpeedLog(dt *etable.Table) {
+	dt.SetMetaData("name", "SpeedLog")
+	dt.SetMetaData("desc", "Regression of each unit's activity on movement speed over training, with the shuffle threshold of R2")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := PeriodicSchema(etable.Schema{
+		{"Unit", etensor.INT64, nil, nil},
+		{"Slope", etensor.FLOAT64, nil, nil},
+		{"Icpt", etensor.FLOAT64, nil, nil},
+		{"R2", etensor.FLOAT64, nil, nil},
+		{"ShufThr", etensor.FLOAT64, nil, nil},
+		{"Sig", etensor.INT64, nil, nil},
+	})
+	dt.SetFromSchema(sch, 0)
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build sqlite
+
+// SQLite log export requires cgo and the go-sqlite3 driver:
+//   go get github.com/mattn/go-sqlite3
+//   go build -tags sqlite
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteLog writes log tables as tables in a single SQLite database file,
+// for easy querying across runs and epochs.  Only scalar columns are written.
+type SQLiteLog struct {
+	File  string               `desc:"database file name"`
+	DB    *sql.DB              `view:"-" desc:"the database"`
+	Stmts map[string]*sql.Stmt `view:"-" desc:"prepared insert statements, per table"`
+	Cols  map[string][]int     `view:"-" desc:"indexes of the scalar columns written, per table"`
+}
+
+// SQLiteAvail is true if built with SQLite support
+const SQLiteAvail = true
+
+// OpenSQLiteLog opens (creating if needed) the given SQLite database file
+func OpenSQLiteLog(fnm string) (*SQLiteLog, error) {
+	db, err := sql.Open("sqlite3", fnm)
+	if err != nil {
+		return nil, err
+	}
+	sl := &SQLiteLog{File: fnm, DB: db}
+	sl.Stmts = make(map[string]*sql.Stmt)
+	sl.Cols = make(map[string][]int)
+	return sl, nil
+}
+
+// Close closes the database
+func (sl *SQLiteLog) Close() error {
+	for _, st := range sl.Stmts {
+		st.Close()
+	}
+	return sl.DB.Close()
+}
+
+// SQLType returns the SQLite column type for given etensor type
+func SQLType(typ etensor.Type) string {
+	switch typ {
+	case etensor.STRING:
+		return "TEXT"
+	case etensor.FLOAT32, etensor.FLOAT64:
+		return "REAL"
+	default:
+		return "INTEGER"
+	}
+}
+
+// ConfigTable creates the SQL table for given log table if it does not yet exist,
+// with indexes on the Run, Epoch and Trial columns, and prepares the insert statement.
+// Only columns kept by given LogConfig are included -- all if nil.
+func (sl *SQLiteLog) ConfigTable(tnm string, dt *etable.Table, lc *LogConfig) error {
+	var cols, defs, qs []string
+	var cidx []int
+	for ci, cl := range dt.Cols {
+		if cl.NumDims() > 1 { // only scalars
+			continue
+		}
+		cnm := dt.ColNames[ci]
+		if !lc.Keep(cnm) {
+			continue
+		}
+		cols = append(cols, `"`+cnm+`"`)
+		defs = append(defs, fmt.Sprintf(`"%s" %s`, cnm, SQLType(cl.DataType())))
+		qs = append(qs, "?")
+		cidx = append(cidx, ci)
+	}
+	_, err := sl.DB.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (%s)`, tnm, strings.Join(defs, ", ")))
+	if err != nil {
+		return err
+	}
+	for _, inm := range []string{"Run", "Epoch", "Trial"} {
+		if dt.ColIdx(inm) < 0 {
+			continue
+		}
+		_, err = sl.DB.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s_%s" ON "%s" ("%s")`, tnm, inm, tnm, inm))
+		if err != nil {
+			return err
+		}
+	}
+	st, err := sl.DB.Prepare(fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, tnm, strings.Join(cols, ", "), strings.Join(qs, ", ")))
+	if err != nil {
+		return err
+	}
+	sl.Stmts[tnm] = st
+	sl.Cols[tnm] = cidx
+	return nil
+}
+
+// WriteRow writes given row of log table dt to SQL table tnm, configuring the
+// SQL table on first use, with the columns kept by given LogConfig
+func (sl *SQLiteLog) WriteRow(tnm string, dt *etable.Table, row int, lc *LogConfig) error {
+	st, ok := sl.Stmts[tnm]
+	if !ok {
+		if err := sl.ConfigTable(tnm, dt, lc); err != nil {
+			return err
+		}
+		st = sl.Stmts[tnm]
+	}
+	cidx := sl.Cols[tnm]
+	vals := make([]interface{}, len(cidx))
+	for i, ci := range cidx {
+		cl := dt.Cols[ci]
+		switch SQLType(cl.DataType()) {
+		case "TEXT":
+			vals[i] = cl.StringVal1D(row)
+		case "REAL":
+			vals[i] = cl.FloatVal1D(row)
+		default:
+			vals[i] = int64(cl.FloatVal1D(row))
+		}
+	}
+	_, err := st.Exec(vals...)
+	return err
+}
+
+// Query runs the given SQL query and returns the results as an etable.Table,
+// with TEXT columns as strings and all others as float64, e.g.:
+//
+//	sl.Query(`SELECT Epoch, AVG(PosErr) FROM trn_trl WHERE Run = ? GROUP BY Epoch`, 0)
+func (sl *SQLiteLog) Query(query string, args ...interface{}) (*etable.Table, error) {
+	rows, err := sl.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	sch := make(etable.Schema, len(cts))
+	for i, ct := range cts {
+		typ := etensor.FLOAT64
+		if strings.ToUpper(ct.DatabaseTypeName()) == "TEXT" {
+			typ = etensor.STRING
+		}
+		sch[i] = etable.Column{Name: ct.Name(), Type: typ}
+	}
+	dt := etable.New(sch, 0)
+	vals := make([]interface{}, len(cts))
+	ptrs := make([]interface{}, len(cts))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return dt, err
+		}
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		for ci, v := range vals {
+			switch vv := v.(type) {
+			case int64:
+				dt.Cols[ci].SetFloat1D(row, float64(vv))
+			case float64:
+				dt.Cols[ci].SetFloat1D(row, vv)
+			case string:
+				dt.Cols[ci].SetString1D(row, vv)
+			case []byte:
+				dt.Cols[ci].SetString1D(row, string(vv))
+			}
+		}
+	}
+	return dt, rows.Err()
+}
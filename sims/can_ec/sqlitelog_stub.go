@@ -0,0 +1,39 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !sqlite
+
+package main
+
+import (
+	"errors"
+
+	"github.com/emer/etable/etable"
+)
+
+// SQLiteLog is only available when built with -tags sqlite -- see sqlitelog.go
+type SQLiteLog struct {
+	File string `desc:"database file name"`
+}
+
+// SQLiteAvail is true if built with SQLite support
+const SQLiteAvail = false
+
+var errNoSQLite = errors.New("SQLite log export not available: build with -tags sqlite")
+
+func OpenSQLiteLog(fnm string) (*SQLiteLog, error) {
+	return nil, errNoSQLite
+}
+
+func (sl *SQLiteLog) Close() error {
+	return errNoSQLite
+}
+
+func (sl *SQLiteLog) WriteRow(tnm string, dt *etable.Table, row int, lc *LogConfig) error {
+	return errNoSQLite
+}
+
+func (sl *SQLiteLog) Query(query string, args ...interface{}) (*etable.Table, error) {
+	return nil, errNoSQLite
+}
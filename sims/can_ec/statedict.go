@@ -0,0 +1,197 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+// SDTensor is one tensor in a StateDict, in the form expected by torch.tensor:
+// torch.tensor(t["data"]).reshape(t["shape"])
+type SDTensor struct {
+	Shape []int     `json:"shape"`
+	DType string    `json:"dtype"`
+	Data  []float32 `json:"data"`
+}
+
+// StateDict is a PyTorch-style state dict of named weight tensors, saved as JSON.
+// Each prjn is stored as key Recv.Send.weight, with shape [nRecv, nSend] as in a
+// torch.nn.Linear weight, with units in each layer in row-major (flat index) order.
+// Prjns between the same layers (e.g., the ExciteLateral and InhibLateral EC
+// prjns) are distinguished by their class, or their index in the RcvPrjns of
+// the receiving layer if they have none: Recv.Send.Class.weight.
+// Missing synapses in sparse prjns are stored as 0 and are skipped on load.
+type StateDict struct {
+	Format  string               `json:"format"`
+	Tensors map[string]*SDTensor `json:"tensors"`
+}
+
+// SDKey returns the state dict key for given prjn, mapped through SDNames if present
+func (ss *Sim) SDKey(pj *leabra.Prjn) string {
+	key := pj.Recv.Name() + "." + pj.Send.Name()
+	rly := pj.Recv.(leabra.LeabraLayer).AsLeabra()
+	pi, nsend := 0, 0
+	for i, rpj := range rly.RcvPrjns {
+		if rpj.SendLay() == pj.Send {
+			nsend++
+		}
+		if rpj.(leabra.LeabraPrjn).AsLeabra() == pj {
+			pi = i
+		}
+	}
+	if nsend > 1 {
+		if cls := pj.Class(); cls != "" {
+			key += "." + cls
+		} else {
+			key += fmt.Sprintf(".%d", pi)
+		}
+	}
+	key += ".weight"
+	if nm, ok := ss.SDNames[key]; ok {
+		return nm
+	}
+	return key
+}
+
+// StateDictFmNet returns a StateDict with the weights of all prjns in the
+// network.  Returns an error if the keys of two prjns are the same.
+func (ss *Sim) StateDictFmNet() (*StateDict, error) {
+	sd := &StateDict{Format: "state_dict", Tensors: make(map[string]*SDTensor)}
+	for _, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		for _, pji := range ly.RcvPrjns {
+			pj := pji.(leabra.LeabraPrjn).AsLeabra()
+			nr := pj.Recv.Shape().Len()
+			ns := pj.Send.Shape().Len()
+			t := &SDTensor{Shape: []int{nr, ns}, DType: "float32", Data: make([]float32, nr*ns)}
+			for ri := 0; ri < nr; ri++ {
+				for si := 0; si < ns; si++ {
+					wt := pj.SynVal("Wt", si, ri)
+					if !math.IsNaN(float64(wt)) {
+						t.Data[ri*ns+si] = wt
+					}
+				}
+			}
+			key := ss.SDKey(pj)
+			if _, has := sd.Tensors[key]; has {
+				return nil, fmt.Errorf("StateDict: %s: duplicate key for prjn %s", key, pj.Name())
+			}
+			sd.Tensors[key] = t
+		}
+	}
+	return sd, nil
+}
+
+// StateDictToNet sets the network weights from given StateDict.  Prjns without
+// a tensor in the state dict are left as is.  Returns an error for any tensor
+// whose shape does not match its prjn, or that has no matching prjn, or more
+// than one.
+func (ss *Sim) StateDictToNet(sd *StateDict) error {
+	var errs []string
+	used := make(map[string]bool)
+	for _, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		for _, pji := range ly.RcvPrjns {
+			pj := pji.(leabra.LeabraPrjn).AsLeabra()
+			key := ss.SDKey(pj)
+			t, ok := sd.Tensors[key]
+			if !ok {
+				continue
+			}
+			if used[key] {
+				errs = append(errs, fmt.Sprintf("%s: duplicate key for prjn %s", key, pj.Name()))
+				continue
+			}
+			used[key] = true
+			nr := pj.Recv.Shape().Len()
+			ns := pj.Send.Shape().Len()
+			if len(t.Shape) != 2 || t.Shape[0] != nr || t.Shape[1] != ns || len(t.Data) != nr*ns {
+				errs = append(errs, fmt.Sprintf("%s: shape %v != prjn shape [%d %d]", key, t.Shape, nr, ns))
+				continue
+			}
+			for ri := 0; ri < nr; ri++ {
+				for si := 0; si < ns; si++ {
+					pj.SetSynVal("Wt", si, ri, t.Data[ri*ns+si]) // error for missing synapses in sparse prjns
+				}
+			}
+		}
+	}
+	for key := range sd.Tensors {
+		if !used[key] {
+			errs = append(errs, fmt.Sprintf("%s: no matching prjn", key))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("StateDict: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SaveStateDict saves the network weights as a PyTorch-style state dict JSON file
+func (ss *Sim) SaveStateDict(filename gi.FileName) error {
+	sd, err := ss.StateDictFmNet()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	b, err := json.Marshal(sd)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	err = ioutil.WriteFile(string(filename), b, 0644)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// OpenStateDict sets the network weights from a PyTorch-style state dict JSON file
+func (ss *Sim) OpenStateDict(filename gi.FileName) error {
+	b, err := ioutil.ReadFile(string(filename))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	sd := &StateDict{}
+	if err = json.Unmarshal(b, sd); err != nil {
+		log.Println(err)
+		return err
+	}
+	err = ss.StateDictToNet(sd)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// OpenSDNames loads the SDNames mapping from our state dict keys to those of
+// an external model, from a JSON file with a single object of key: name pairs
+func (ss *Sim) OpenSDNames(filename gi.FileName) error {
+	b, err := ioutil.ReadFile(string(filename))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	ss.SDNames = make(map[string]string)
+	err = json.Unmarshal(b, &ss.SDNames)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
+
+// StateDictFileName returns the default state dict file name for the current run
+func (ss *Sim) StateDictFileName() string {
+	return ss.Net.Nm + "_" + ss.RunName() + "_" + ss.RunEpochName(ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur) + ".sd.json"
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestTrainRuns trains 2 short runs, with the test at the end of each run
+// that SaveARFs triggers, and checks that both runs are done
+func TestTrainRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("trains the network")
+	}
+	ss := &Sim{}
+	ss.New()
+	ss.MaxRuns = 2
+	ss.MaxEpcs = 1
+	ss.TestEpcs = 1
+	ss.SaveARFs = true
+	ss.Config()
+	ss.Init()
+	ss.TrainEnv.Trial.Max = 5
+	ss.TestEnv.Trial.Max = 5
+
+	// the ARFs are saved in the current directory at the end of each run
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	ss.Train()
+	if !ss.RunsDone {
+		t.Errorf("RunsDone = false after Train")
+	}
+	if ss.RunLog.Rows != 2 {
+		t.Errorf("RunLog has %d rows, want 2", ss.RunLog.Rows)
+	}
+}
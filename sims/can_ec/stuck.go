@@ -0,0 +1,134 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+////////////////////////////////////////////////////////////////////
+// Env: wall collisions and stuck episodes
+
+// StuckParams configures the detection of stuck episodes, where the agent
+// stays within Dist grid cells of the same location for Steps steps, e.g.,
+// turning back and forth in a corner, and the unstick behavior of the
+// reflexive policy (ActGen) while stuck.
+type StuckParams struct {
+	Steps   int  `def:"20" min:"0" desc:"number of steps within Dist of the same location after which the agent is stuck -- 0 = no stuck detection"`
+	Dist    int  `def:"1" min:"0" desc:"distance in grid cells (along X or Y) from the location where the agent was that does not count as a displacement"`
+	Unstick bool `def:"false" desc:"when stuck, ActGen heads toward the most open direction, instead of its usual heuristics, until displaced"`
+}
+
+// Defaults sets default params
+func (sp *StuckParams) Defaults() {
+	sp.Steps = 20
+	sp.Dist = 1
+	sp.Unstick = false
+}
+
+// StuckCounts are counts of steps, wall collisions and stuck episodes,
+// accumulated by the env since Init
+type StuckCounts struct {
+	Steps      int `desc:"number of actions executed"`
+	Collisions int `desc:"number of Forward actions blocked by a barrier"`
+	StuckEps   int `desc:"number of stuck episodes started"`
+	StuckSteps int `desc:"number of actions executed while stuck"`
+}
+
+// Sub returns the counts minus those of oc
+func (sc StuckCounts) Sub(oc StuckCounts) StuckCounts {
+	return StuckCounts{Steps: sc.Steps - oc.Steps, Collisions: sc.Collisions - oc.Collisions, StuckEps: sc.StuckEps - oc.StuckEps, StuckSteps: sc.StuckSteps - oc.StuckSteps}
+}
+
+// InitStuck initializes the collision and stuck state -- called in Init
+func (ev *XYHDEnv) InitStuck() {
+	ev.Collided = false
+	ev.IsStuck = false
+	ev.StillSteps = 0
+	ev.StuckAnchor = ev.PosI
+	ev.StuckCnt = StuckCounts{}
+}
+
+// UpdateStuck updates the collision and stuck state and counts after an
+// action, where collided is true if the action was blocked by a barrier
+func (ev *XYHDEnv) UpdateStuck(collided bool) {
+	ev.Collided = collided
+	ev.StuckCnt.Steps++
+	if collided {
+		ev.StuckCnt.Collisions++
+	}
+	d := ev.PosI.Sub(ev.StuckAnchor)
+	if absInt(d.X) > ev.Stuck.Dist || absInt(d.Y) > ev.Stuck.Dist {
+		ev.StuckAnchor = ev.PosI
+		ev.StillSteps = 0
+		ev.IsStuck = false
+		return
+	}
+	ev.StillSteps++
+	if !ev.IsStuck && ev.Stuck.Steps > 0 && ev.StillSteps >= ev.Stuck.Steps {
+		ev.IsStuck = true
+		ev.StuckCnt.StuckEps++
+	}
+	if ev.IsStuck {
+		ev.StuckCnt.StuckSteps++
+	}
+}
+
+// UnstickAct returns the action to take when stuck: Forward if the front is
+// at least as open as the sides and back (by ProxDist), otherwise a turn
+// toward the more open side, continuing any turn in progress if the back is
+// the most open
+func (ev *XYHDEnv) UnstickAct() int {
+	fr, rt, lf, bk := ev.ProxDist[0], ev.ProxDist[1], ev.ProxDist[2], ev.ProxDist[3]
+	switch {
+	case fr >= rt && fr >= lf && fr >= bk:
+		return int(Forward)
+	case bk > rt && bk > lf:
+		if ev.Act == int(Right) {
+			return int(Right)
+		}
+		return int(Left)
+	case rt > lf:
+		return int(Right)
+	}
+	return int(Left)
+}
+
+////////////////////////////////////////////////////////////////////
+// Sim: per-epoch collision and stuck logging
+
+// StuckCols are the TrnEpcLog columns for the collision and stuck counts
+var StuckCols = []string{"Collisions", "StuckEps", "StuckFrac"}
+
+// LogStuckEpc records the collision and stuck counts of the TrainEnv over the
+// epoch in given row of the TrnEpcLog: number of collisions and stuck episodes,
+// and the fraction of steps spent stuck
+func (ss *Sim) LogStuckEpc(dt *etable.Table, row int) {
+	cur := ss.TrainEnv.StuckCnt
+	ec := cur.Sub(ss.StuckPrv)
+	ss.StuckPrv = cur
+	dt.SetCellFloat("Collisions", row, float64(ec.Collisions))
+	dt.SetCellFloat("StuckEps", row, float64(ec.StuckEps))
+	if ec.Steps > 0 {
+		dt.SetCellFloat("StuckFrac", row, float64(ec.StuckSteps)/float64(ec.Steps))
+	}
+}
+
+// ConfigStuckCols adds the StuckCols to given TrnEpcLog schema
+func ConfigStuckCols(sch etable.Schema) etable.Schema {
+	sch = append(sch, etable.Column{"Collisions", etensor.INT64, nil, nil})
+	sch = append(sch, etable.Column{"StuckEps", etensor.INT64, nil, nil})
+	sch = append(sch, etable.Column{"StuckFrac", etensor.FLOAT64, nil, nil})
+	return sch
+}
+
+// ConfigStuckPlot sets the TrnEpcPlot params for StuckCols
+func ConfigStuckPlot(plt *eplot.Plot2D) {
+	plt.SetColParams("Collisions", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("StuckEps", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("StuckFrac", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+}
@@ -0,0 +1,408 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+func init() {
+	AddAnalysis(&Analysis{Name: "sr", Label: "View SR", Desc: "view the leading eigenvectors of the successor representation implied by each SR layer, as of the end of the last epoch.", Needs: []string{AnalysisSR}, Run: func(ss *Sim) []AnalysisOut {
+		var lnms []string
+		for lnm := range ss.SR.Eigs {
+			lnms = append(lnms, lnm)
+		}
+		sort.Strings(lnms)
+		outs := make([]AnalysisOut, len(lnms))
+		for i, lnm := range lnms {
+			outs[i] = AnalysisOut{Name: lnm + "_SREigs", Tensor: ss.SR.Eigs[lnm]}
+		}
+		return outs
+	}})
+}
+
+// SRAnalysis estimates the successor representation (SR) implied by hidden
+// layer activity: discounted future occupancy of spatial bins is regressed on
+// the current activity of each layer by TD learning of a linear readout W
+// (units x bins).  At the end of each epoch, the implied SR over bins is
+// M = A^T W, where A is the mean activity of each unit in each bin (its rate map),
+// and the leading eigenvectors of the symmetrized M are compared with the
+// unit rate maps -- the learned grid patterns -- by spatial correlation.
+type SRAnalysis struct {
+	On     bool       `desc:"estimate the SR on each training trial, and analyze it at the end of each epoch"`
+	Layers []string   `desc:"hidden layers to estimate the SR for"`
+	Gamma  float32    `def:"0.9" min:"0" max:"1" desc:"discount factor for future occupancy"`
+	Lrate  float32    `def:"0.01" desc:"learning rate for the TD regression of future occupancy on activity"`
+	Bins   evec.Vec2i `desc:"number of spatial bins in each dimension -- positions are binned to keep the SR small"`
+	NEigs  int        `def:"8" desc:"number of leading eigenvectors to compute and compare"`
+	Iters  int        `def:"200" desc:"number of power iterations per eigenvector"`
+	Save   bool       `desc:"save the eigenvectors for each layer to a file at the end of each epoch"`
+
+	W       map[string]*etensor.Float32 `view:"-" desc:"TD readout weights from units to discounted future bin occupancy, per layer [units][bins]"`
+	ActSum  map[string]*etensor.Float32 `view:"-" desc:"sum of unit activity in each bin, per layer [units][bins]"`
+	Prev    map[string]*etensor.Float32 `view:"-" desc:"activity on the previous trial, per layer"`
+	BinN    []float32                   `view:"-" desc:"number of trials in each bin"`
+	PrevBin int                         `view:"-" desc:"bin on the previous trial, -1 if none"`
+	Eigs    map[string]*etensor.Float32 `view:"no-inline" desc:"leading eigenvectors of the implied SR for each layer, as spatial maps [eig][Y][X]"`
+}
+
+func (sr *SRAnalysis) Defaults() {
+	sr.Layers = []string{"EC"}
+	sr.Gamma = 0.9
+	sr.Lrate = 0.01
+	sr.Bins.Set(12, 12)
+	sr.NEigs = 8
+	sr.Iters = 200
+}
+
+// Reset resets the SR estimates, for a new run
+func (sr *SRAnalysis) Reset() {
+	sr.W = make(map[string]*etensor.Float32)
+	sr.ActSum = make(map[string]*etensor.Float32)
+	sr.Prev = make(map[string]*etensor.Float32)
+	sr.Eigs = make(map[string]*etensor.Float32)
+	sr.BinN = make([]float32, sr.Bins.X*sr.Bins.Y)
+	sr.PrevBin = -1
+}
+
+// NBins returns the total number of bins
+func (sr *SRAnalysis) NBins() int {
+	return sr.Bins.X * sr.Bins.Y
+}
+
+// Bin returns the bin index for given position in a world of given size
+func (sr *SRAnalysis) Bin(pos, size evec.Vec2i) int {
+	bx := pos.X * sr.Bins.X / size.X
+	by := pos.Y * sr.Bins.Y / size.Y
+	return by*sr.Bins.X + bx
+}
+
+// SRTrial updates the SR estimates from the current trial's activity --
+// called after each training trial
+func (ss *Sim) SRTrial() {
+	sr := &ss.SR
+	ev := &ss.TrainEnv
+	if !sr.On {
+		return
+	}
+	if sr.W == nil || len(sr.BinN) != sr.NBins() {
+		sr.Reset()
+	}
+	nb := sr.NBins()
+	bin := sr.Bin(ev.PosI, ev.Size)
+	sr.BinN[bin]++
+	pred := make([]float32, nb)
+	for _, lnm := range sr.Layers {
+		ly := ss.Net.LayerByName(lnm)
+		if ly == nil {
+			continue
+		}
+		vt := ss.ValsTsr(lnm)
+		ly.UnitValsTensor(vt, "ActM")
+		nu := len(vt.Values)
+		w, ok := sr.W[lnm]
+		if !ok {
+			w = &etensor.Float32{}
+			w.SetShape([]int{nu, nb}, nil, []string{"Unit", "Bin"})
+			sr.W[lnm] = w
+			as := &etensor.Float32{}
+			as.SetShape([]int{nu, nb}, nil, []string{"Unit", "Bin"})
+			sr.ActSum[lnm] = as
+			sr.Prev[lnm] = vt.Clone().(*etensor.Float32)
+			ss.SRAddAct(lnm, vt, bin)
+			continue
+		}
+		ss.SRAddAct(lnm, vt, bin)
+		if sr.PrevBin < 0 {
+			sr.Prev[lnm].CopyFrom(vt)
+			continue
+		}
+		// TD error for each bin: occupancy of prev bin + discounted prediction
+		// from current activity - prediction from prev activity
+		prev := sr.Prev[lnm]
+		for j := range pred {
+			pred[j] = 0
+		}
+		for ui := 0; ui < nu; ui++ {
+			ca := vt.Values[ui]
+			pa := prev.Values[ui]
+			if ca == 0 && pa == 0 {
+				continue
+			}
+			wu := w.Values[ui*nb : (ui+1)*nb]
+			for j, wv := range wu {
+				pred[j] += sr.Gamma*ca*wv - pa*wv
+			}
+		}
+		pred[sr.PrevBin] += 1
+		for ui := 0; ui < nu; ui++ {
+			pa := prev.Values[ui]
+			if pa == 0 {
+				continue
+			}
+			wu := w.Values[ui*nb : (ui+1)*nb]
+			for j, dl := range pred {
+				wu[j] += sr.Lrate * pa * dl
+			}
+		}
+		prev.CopyFrom(vt)
+	}
+	sr.PrevBin = bin
+}
+
+// SRAddAct adds given layer activity to the activity sums for given bin
+func (ss *Sim) SRAddAct(lnm string, vt *etensor.Float32, bin int) {
+	as := ss.SR.ActSum[lnm]
+	nb := ss.SR.NBins()
+	for ui, v := range vt.Values {
+		as.Values[ui*nb+bin] += v
+	}
+}
+
+// SRAnalyze computes the implied SR for each layer, its leading eigenvectors,
+// and their best correlation with unit rate maps, adding them to the SRLog --
+// called at the end of each training epoch
+func (ss *Sim) SRAnalyze() {
+	sr := &ss.SR
+	if !sr.On || sr.W == nil {
+		return
+	}
+	nb := sr.NBins()
+	vis := make([]int, 0, nb) // visited bins
+	for b, n := range sr.BinN {
+		if n > 0 {
+			vis = append(vis, b)
+		}
+	}
+	nv := len(vis)
+	if nv < 2 {
+		return
+	}
+	for _, lnm := range sr.Layers {
+		w, ok := sr.W[lnm]
+		if !ok {
+			continue
+		}
+		as := sr.ActSum[lnm]
+		nu := w.Shp[0]
+		// rate maps over visited bins
+		rm := make([][]float64, nu)
+		for ui := range rm {
+			rm[ui] = make([]float64, nv)
+			for i, b := range vis {
+				rm[ui][i] = float64(as.Values[ui*nb+b] / sr.BinN[b])
+			}
+		}
+		// implied SR, symmetrized
+		m := make([][]float64, nv)
+		for i := range m {
+			m[i] = make([]float64, nv)
+		}
+		for ui := 0; ui < nu; ui++ {
+			wu := w.Values[ui*nb : (ui+1)*nb]
+			for i := range vis {
+				a := rm[ui][i]
+				if a == 0 {
+					continue
+				}
+				mi := m[i]
+				for j, bj := range vis {
+					mi[j] += a * float64(wu[bj])
+				}
+			}
+		}
+		for i := 0; i < nv; i++ {
+			for j := i + 1; j < nv; j++ {
+				s := 0.5 * (m[i][j] + m[j][i])
+				m[i][j] = s
+				m[j][i] = s
+			}
+		}
+		vals, vecs := SymEigs(m, sr.NEigs, sr.Iters)
+
+		et, ok := sr.Eigs[lnm]
+		if !ok {
+			et = &etensor.Float32{}
+			sr.Eigs[lnm] = et
+		}
+		et.SetShape([]int{len(vals), sr.Bins.Y, sr.Bins.X}, nil, []string{"Eig", "Y", "X"})
+		et.SetZeros()
+		for k, v := range vecs {
+			for i, b := range vis {
+				et.Values[k*nb+b] = float32(v[i])
+			}
+			bu, bc := -1, 0.0
+			for ui := range rm {
+				c := math.Abs(Correl(v, rm[ui]))
+				if c > bc {
+					bu, bc = ui, c
+				}
+			}
+			ss.LogSR(ss.SRLog, lnm, k, vals[k], bu, bc)
+		}
+		if sr.Save {
+			fnm := ss.LogFileName(fmt.Sprintf("%s_SREigs_epc%03d", lnm, ss.TrainEnv.Epoch.Prv))
+			etensor.SaveCSV(et, gi.FileName(fnm), '\t')
+		}
+	}
+}
+
+// SymEigs returns the n eigenvalues of largest magnitude, and their
+// eigenvectors (unit length), of the symmetric matrix m, in order of
+// decreasing magnitude, using power iteration with deflation
+func SymEigs(m [][]float64, n, iters int) ([]float64, [][]float64) {
+	nv := len(m)
+	if n > nv {
+		n = nv
+	}
+	vals := make([]float64, 0, n)
+	vecs := make([][]float64, 0, n)
+	tmp := make([]float64, nv)
+	for k := 0; k < n; k++ {
+		v := make([]float64, nv)
+		for i := range v {
+			v[i] = float64(1 + (i+k)%3) // deterministic, non-constant start
+		}
+		lam := 0.0
+		for it := 0; it < iters; it++ {
+			for i, mi := range m {
+				s := 0.0
+				for j, mij := range mi {
+					s += mij * v[j]
+				}
+				tmp[i] = s
+			}
+			for pk, pv := range vecs { // deflate
+				d := Dot(pv, v) * vals[pk]
+				for i := range tmp {
+					tmp[i] -= d * pv[i]
+				}
+			}
+			lam = Dot(v, tmp)
+			nrm := math.Sqrt(Dot(tmp, tmp))
+			if nrm == 0 {
+				break
+			}
+			for i := range v {
+				v[i] = tmp[i] / nrm
+			}
+		}
+		vals = append(vals, lam)
+		vecs = append(vecs, v)
+	}
+	idx := make([]int, len(vals))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return math.Abs(vals[idx[i]]) > math.Abs(vals[idx[j]]) })
+	svals := make([]float64, len(vals))
+	svecs := make([][]float64, len(vals))
+	for i, ix := range idx {
+		svals[i] = vals[ix]
+		svecs[i] = vecs[ix]
+	}
+	return svals, svecs
+}
+
+// Dot returns the dot product of a and b
+func Dot(a, b []float64) float64 {
+	s := 0.0
+	for i, av := range a {
+		s += av * b[i]
+	}
+	return s
+}
+
+// Correl returns the Pearson correlation between a and b, 0 if either is constant
+func Correl(a, b []float64) float64 {
+	r, _ := CorrelOk(a, b)
+	return r
+}
+
+// CorrelOk returns the Pearson correlation between a and b, and false if
+// either is constant, or there are fewer than 2 values
+func CorrelOk(a, b []float64) (float64, bool) {
+	if len(a) < 2 {
+		return 0, false
+	}
+	n := float64(len(a))
+	ma, mb := 0.0, 0.0
+	for i, av := range a {
+		ma += av
+		mb += b[i]
+	}
+	ma /= n
+	mb /= n
+	sab, saa, sbb := 0.0, 0.0, 0.0
+	for i, av := range a {
+		da := av - ma
+		db := b[i] - mb
+		sab += da * db
+		saa += da * da
+		sbb += db * db
+	}
+	if saa == 0 || sbb == 0 {
+		return 0, false
+	}
+	return sab / math.Sqrt(saa*sbb), true
+}
+
+// LogSR adds the analysis of one SR eigenvector to the SRLog
+func (ss *Sim) LogSR(dt *etable.Table, lnm string, eig int, val float64, unit int, corr float64) {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ss.TrainEnv.Epoch.Prv))
+	dt.SetCellString("Layer", row, lnm)
+	dt.SetCellFloat("Eig", row, float64(eig))
+	dt.SetCellFloat("EigVal", row, val)
+	dt.SetCellFloat("BestUnit", row, float64(unit))
+	dt.SetCellFloat("BestCorr", row, corr)
+	ss.SQLWriteRow("sr", dt, row)
+
+	ss.UpdtPlot(ss.SRPlot)
+}
+
+func (ss *Sim) ConfigSRLog(dt *etable.Table) {
+	dt.SetMetaData("name", "SRLog")
+	dt.SetMetaData("desc", "Leading eigenvectors of the successor representation implied by hidden layer activity, and their best correlation with unit rate maps, per epoch")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Layer", etensor.STRING, nil, nil},
+		{"Eig", etensor.INT64, nil, nil},
+		{"EigVal", etensor.FLOAT64, nil, nil},
+		{"BestUnit", etensor.INT64, nil, nil},
+		{"BestCorr", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigSRPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Successor Representation Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.Params.LegendCol = "Eig"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Eig", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("EigVal", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("BestUnit", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("BestCorr", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+
+	"github.com/emer/etable/agg"
+	"github.com/emer/etable/etable"
+)
+
+// SupStats are the TrnTrlLog performance stats reported separately for
+// supervised and unsupervised trials in the TrnEpcLog
+var SupStats = []string{"PosErr", "PosACC", "OriErr", "OriACC"}
+
+// SupTrial determines whether targets are provided on the current training trial,
+// according to SupFrac, or the PretrainSup of a Pretrain protocol phase --
+// called in TrainTrial prior to ApplyInputs
+func (ss *Sim) SupTrial() {
+	if ph := ss.Protocol.Cur(); ph != nil && ph.Pretrain {
+		ss.Supervised = rand.Float32() < ph.PretrainSup
+		return
+	}
+	ss.Supervised = ss.SupFrac >= 1 || rand.Float32() < ss.SupFrac
+}
+
+// IsSupLay returns true if given layer only gets targets on Supervised trials
+func (ss *Sim) IsSupLay(lnm string) bool {
+	for _, sl := range ss.SupLays {
+		if sl == lnm {
+			return true
+		}
+	}
+	return false
+}
+
+// LogSupEpc records the epoch averages of SupStats over supervised and unsupervised
+// trials separately, from given view of the TrnTrlLog, as Sup_ and Unsup_ columns.
+// Values are 0 if there were no trials of the given type.
+func (ss *Sim) LogSupEpc(dt *etable.Table, row int, trlix *etable.IdxView) {
+	for _, sup := range []bool{true, false} {
+		pfx := "Sup_"
+		if !sup {
+			pfx = "Unsup_"
+		}
+		ix := trlix.Clone()
+		ix.Filter(func(et *etable.Table, row int) bool {
+			return (et.CellFloat("Sup", row) == 1) == sup
+		})
+		for _, st := range SupStats {
+			v := 0.0
+			if ix.Len() > 0 {
+				v = agg.Agg(ix, st, agg.AggMean)[0]
+			}
+			dt.SetCellFloat(pfx+st, row, v)
+		}
+	}
+}
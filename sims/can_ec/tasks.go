@@ -0,0 +1,224 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/ki/kit"
+)
+
+// Tasks are the behavioral tasks that drive the actions of the agent in the
+// TrainEnv, which can be intermixed in blocks within a protocol phase
+type Tasks int32
+
+var KiT_Tasks = kit.Enums.AddEnum(TasksN, false, nil)
+
+const (
+	// TaskExplore is random exploration by the reflexive ActGen policy
+	TaskExplore Tasks = iota
+
+	// TaskForage is exploration, approaching the goal when it is within
+	// ForageDist, as if detecting food nearby
+	TaskForage
+
+	// TaskGoal is navigation straight to the goal, wherever it is
+	TaskGoal
+
+	TasksN
+)
+
+// FromString sets the task from its name, with or without the Task prefix
+func (i *Tasks) FromString(s string) error {
+	for j := Tasks(0); j < TasksN; j++ {
+		if j.String() == s || j.String() == "Task"+s {
+			*i = j
+			return nil
+		}
+	}
+	return fmt.Errorf("Tasks: %q not found", s)
+}
+
+// TaskBlock is one block of a task within a protocol phase, which ends after
+// Trials training trials, or when Goals goals have been reached, whichever
+// comes first
+type TaskBlock struct {
+	Task   string `desc:"task of the block: Explore, Forage or Goal"`
+	Trials int    `min:"0" desc:"duration of the block in training trials -- 0 = until Goals are reached"`
+	Goals  int    `min:"0" desc:"number of goals after which the block ends -- 0 = Trials only"`
+
+	Type Tasks `json:"-" view:"-" desc:"parsed Task"`
+}
+
+// TaskState is the state of the intermixed task blocks of the current
+// protocol phase, cycling through the phase Tasks in order, with the stats
+// of the current block, which are logged to the TaskLog when it ends, to
+// study interference and transfer between tasks in the same network.
+type TaskState struct {
+	ForageDist float32 `def:"5" min:"0" desc:"distance to the goal, in grid cells, within which it is approached in the Forage task"`
+
+	Cur    Tasks   `inactive:"+" desc:"current task"`
+	Block  int     `inactive:"+" desc:"index of the current block in the Tasks of the current protocol phase, -1 = none"`
+	NBlock int     `inactive:"+" desc:"number of blocks started in the run, numbering the blocks in the TaskLog"`
+	Trials int     `inactive:"+" desc:"number of trials in the current block"`
+	Goals  int     `inactive:"+" desc:"number of goals reached in the current block"`
+	GoalSt int     `view:"-" desc:"TrainEnv GoalsHit at the start of the current block"`
+	SumPos float64 `view:"-" desc:"sum of PosErr over the current block"`
+	SumACC float64 `view:"-" desc:"sum of PosACC over the current block"`
+	SumCos float64 `view:"-" desc:"sum of CosDiff over the current block"`
+}
+
+// Defaults sets default params
+func (ts *TaskState) Defaults() {
+	ts.ForageDist = 5
+}
+
+// Reset returns to exploration with no blocks -- called in NewRun
+func (ts *TaskState) Reset() {
+	ts.Cur = TaskExplore
+	ts.Block = -1
+	ts.NBlock = 0
+	ts.ResetAcc()
+}
+
+// ResetAcc resets the stats of the current block
+func (ts *TaskState) ResetAcc() {
+	ts.Trials = 0
+	ts.Goals = 0
+	ts.SumPos = 0
+	ts.SumACC = 0
+	ts.SumCos = 0
+}
+
+// TaskAct returns the action for the current task in given env: the task
+// only applies to the TrainEnv, and testing always uses ActGen.
+// Exploration uses the reflexive policy with its pause-and-scan bouts,
+// which are interrupted by goal-directed actions.
+func (ss *Sim) TaskAct(ev *XYHDEnv) Action {
+	if ev != &ss.TrainEnv {
+		return NewAction(Actions(ev.ActGen()))
+	}
+	switch ss.Task.Cur {
+	case TaskForage:
+		if ev.GoalDist <= ss.Task.ForageDist {
+			ev.EndBout()
+			return NewAction(Actions(ev.GoalAct()))
+		}
+	case TaskGoal:
+		ev.EndBout()
+		return NewAction(Actions(ev.GoalAct()))
+	}
+	return ev.ReflexAct()
+}
+
+// StartTaskBlock starts given block of the Tasks of the current protocol
+// phase, or exploration if it has none -- called in StartPhase
+func (ss *Sim) StartTaskBlock(bi int) {
+	ts := &ss.Task
+	ts.ResetAcc()
+	ts.GoalSt = ss.TrainEnv.GoalsHit
+	ph := ss.Protocol.Cur()
+	if ph == nil || len(ph.Tasks) == 0 {
+		ts.Cur = TaskExplore
+		ts.Block = -1
+		return
+	}
+	ts.Block = bi % len(ph.Tasks)
+	ts.Cur = ph.Tasks[ts.Block].Type
+	ts.NBlock++
+}
+
+// TaskTrialStats accumulates the stats of the current block from given row
+// of the TrnTrlLog, and at the end of the block, logs it and starts the next
+// one -- called in LogTrnTrl
+func (ss *Sim) TaskTrialStats(dt *etable.Table, row int) {
+	ts := &ss.Task
+	ph := ss.Protocol.Cur()
+	if ts.Block < 0 || ph == nil {
+		return
+	}
+	ts.Trials++
+	ts.Goals = ss.TrainEnv.GoalsHit - ts.GoalSt
+	ts.SumPos += dt.CellFloat("PosErr", row)
+	ts.SumACC += dt.CellFloat("PosACC", row)
+	ts.SumCos += dt.CellFloat("CosDiff", row)
+	tb := &ph.Tasks[ts.Block]
+	if (tb.Trials > 0 && ts.Trials >= tb.Trials) || (tb.Goals > 0 && ts.Goals >= tb.Goals) {
+		ss.LogTask(ss.TaskLog)
+		ss.StartTaskBlock(ts.Block + 1)
+	}
+}
+
+// LogTask adds a row for the current block, if it has any trials -- called
+// at the end of each block, and of each protocol phase
+func (ss *Sim) LogTask(dt *etable.Table) {
+	ts := &ss.Task
+	ph := ss.Protocol.Cur()
+	if ts.Block < 0 || ph == nil || ts.Trials == 0 {
+		return
+	}
+	n := float64(ts.Trials)
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ss.TrainEnv.Epoch.Cur))
+	dt.SetCellString("Phase", row, ph.Name)
+	dt.SetCellFloat("Block", row, float64(ts.NBlock))
+	dt.SetCellString("Task", row, ph.Tasks[ts.Block].Task)
+	dt.SetCellFloat("NTrials", row, n)
+	dt.SetCellFloat("Goals", row, float64(ts.Goals))
+	if ts.Goals > 0 {
+		dt.SetCellFloat("TrlPerGoal", row, n/float64(ts.Goals))
+	}
+	dt.SetCellFloat("PosErr", row, ts.SumPos/n)
+	dt.SetCellFloat("PosACC", row, ts.SumACC/n)
+	dt.SetCellFloat("CosDiff", row, ts.SumCos/n)
+	ss.SQLWriteRow("task", dt, row)
+
+	ss.UpdtPlot(ss.TaskPlot)
+}
+
+func (ss *Sim) ConfigTaskLog(dt *etable.Table) {
+	dt.SetMetaData("name", "TaskLog")
+	dt.SetMetaData("desc", "Record of each block of intermixed tasks in the protocol phases")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Phase", etensor.STRING, nil, nil},
+		{"Block", etensor.INT64, nil, nil},
+		{"Task", etensor.STRING, nil, nil},
+		{"NTrials", etensor.INT64, nil, nil},
+		{"Goals", etensor.INT64, nil, nil},
+		{"TrlPerGoal", etensor.FLOAT64, nil, nil},
+		{"PosErr", etensor.FLOAT64, nil, nil},
+		{"PosACC", etensor.FLOAT64, nil, nil},
+		{"CosDiff", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigTaskPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Task Block Plot"
+	plt.Params.XAxisCol = "Block"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Block", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NTrials", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Goals", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("TrlPerGoal", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PosErr", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PosACC", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("CosDiff", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+)
+
+// ConfigTestEnv configures the TestEnv with the same params as the TrainEnv
+// that determine the input patterns, and its own counters -- called in
+// ConfigEnv, after the TrainEnv is configured
+func (ss *Sim) ConfigTestEnv() {
+	tr := &ss.TrainEnv
+	ev := &ss.TestEnv
+	ev.Preset = tr.Preset
+	ev.Size = tr.Size
+	ev.AngInc = tr.AngInc
+	ev.Stuck = tr.Stuck
+	ev.GridMix = tr.GridMix
+	ev.StateEnc = make(map[string]EncoderTypes, len(tr.StateEnc))
+	for st, et := range tr.StateEnc {
+		ev.StateEnc[st] = et
+	}
+	ev.Config(tr.Trial.Max) // n trials per epoch
+	ev.Nm = "TestEnv"
+	ev.Dsc = "testing params and state"
+	ev.Init(0)
+	ev.Validate()
+}
+
+// CopyWorld copies the world layout of src: Preset, World grid, Start location
+// and angle, Goals and ChoicePt
+func (ev *XYHDEnv) CopyWorld(src *XYHDEnv) {
+	ev.Preset = src.Preset
+	ev.World.CopyFrom(src.World)
+	ev.Start = src.Start
+	ev.StartAngle = src.StartAngle
+	ev.Goals = append(ev.Goals[:0], src.Goals...)
+	ev.ChoicePt = src.ChoicePt
+}
+
+// InitTestEnv sets the TestEnv world and initializes it for the current run,
+// so that each test starts at the Start location with fresh counters,
+// independent of the state of the TrainEnv.  The world is the TestWorld
+// preset if set, else the held-out world with RandWorld, else the current
+// training world -- called in TestAll and RunCkptEval
+func (ss *Sim) InitTestEnv() {
+	tr := &ss.TrainEnv
+	ev := &ss.TestEnv
+	rw := &ss.RandWorld
+	switch {
+	case ss.TestWorld != "":
+		if err := ev.SetPreset(ss.TestWorld); err != nil {
+			log.Println(err)
+		}
+	case rw.On && rw.Eval != nil:
+		ev.CopyWorld(tr)
+		ev.World.CopyFrom(rw.Eval)
+	default:
+		ev.CopyWorld(tr)
+	}
+	ev.Init(tr.Run.Cur)
+	ss.LapTrials = nil
+	ss.LapARFSeen = 0
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	_ "net/http/pprof" // registers /debug/pprof handlers on the default mux
+
+	"github.com/emer/emergent/timer"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// PhaseTimers accumulate the wall-clock time spent in different parts of training
+// over each epoch, to find where time goes.  Logging time includes the epoch log
+// itself, which is counted in the following epoch.
+type PhaseTimers struct {
+	Cycle timer.Time `view:"-" desc:"Net.Cycle updates"`
+	DWt   timer.Time `view:"-" desc:"weight changes: DWt and WtFmDWt"`
+	Log   timer.Time `view:"-" desc:"trial and epoch logging, including epoch-level analyses"`
+	GUI   timer.Time `view:"-" desc:"GUI network and world view updates"`
+	Epoch timer.Time `view:"-" desc:"entire epoch"`
+}
+
+// Reset resets all timers, and starts the Epoch timer
+func (pt *PhaseTimers) Reset() {
+	pt.Cycle.Reset()
+	pt.DWt.Reset()
+	pt.Log.Reset()
+	pt.GUI.Reset()
+	pt.Epoch.ResetStart()
+}
+
+// PhaseTimeCols are the TrnEpcLog columns for PhaseTimers, in seconds
+var PhaseTimeCols = []string{"CycleSecs", "DWtSecs", "LogSecs", "GUISecs", "OtherSecs", "EpcSecs"}
+
+// LogPhaseTimes records the PhaseTimers totals for the epoch in given row of the
+// TrnEpcLog, prints them if running nogui, and resets the timers
+func (ss *Sim) LogPhaseTimes(dt *etable.Table, row int) {
+	pt := &ss.Timers
+	pt.Epoch.Stop()
+	epc := pt.Epoch.TotalSecs()
+	secs := []float64{pt.Cycle.TotalSecs(), pt.DWt.TotalSecs(), pt.Log.TotalSecs(), pt.GUI.TotalSecs(), 0, epc}
+	secs[4] = epc - (secs[0] + secs[1] + secs[2] + secs[3])
+	for i, col := range PhaseTimeCols {
+		dt.SetCellFloat(col, row, secs[i])
+	}
+	if ss.NoGui {
+		fmt.Printf("Epoch: %d  secs: %.3g  Cycle: %.3g  DWt: %.3g  Log: %.3g  GUI: %.3g  Other: %.3g\n", int(dt.CellFloat("Epoch", row)), epc, secs[0], secs[1], secs[2], secs[3], secs[4])
+	}
+	pt.Reset()
+}
+
+// ConfigPhaseTimeCols adds the PhaseTimeCols to given TrnEpcLog schema
+func ConfigPhaseTimeCols(sch etable.Schema) etable.Schema {
+	for _, col := range PhaseTimeCols {
+		sch = append(sch, etable.Column{col, etensor.FLOAT64, nil, nil})
+	}
+	return sch
+}
+
+// ConfigPhaseTimePlot sets the TrnEpcPlot params for PhaseTimeCols
+func ConfigPhaseTimePlot(plt *eplot.Plot2D) {
+	for _, col := range PhaseTimeCols {
+		plt.SetColParams(col, eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	}
+}
+
+// StartPProf starts an HTTP server on given address (e.g., localhost:6060) serving
+// the standard pprof profiles under /debug/pprof, for use with go tool pprof
+func StartPProf(addr string) {
+	go func() {
+		log.Println(http.ListenAndServe(addr, nil))
+	}()
+	fmt.Printf("Serving pprof profiles at: http://%s/debug/pprof\n", addr)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+	"github.com/goki/mat32"
+)
+
+// Trajectory saves the behavior of the TrainEnv over a run, as the sequence
+// of executed actions (after MotorNoise) and resulting positions, with the
+// scan or pause bouts they are part of, if any, and replays
+// it exactly in other runs, whatever their random seed, so that comparisons
+// between networks are not confounded by different behavior.  The steps are
+// grouped by Call: the index of the TakeAction call on the TrainEnv since the
+// start of the run, which takes several steps per trial.  Replayed positions
+// are checked against the saved ones, which differ if the world does.  Once
+// the trajectory is exhausted, actions are generated as usual.
+type Trajectory struct {
+	Save string `desc:"file to save the trajectory of the first run to, at its end -- empty = none"`
+	File string `desc:"saved trajectory file replayed by the TrainEnv in every run -- empty = none"`
+
+	Steps   *etable.Table `view:"no-inline" desc:"steps of the trajectory being saved or replayed"`
+	Call    int           `inactive:"+" desc:"index of the next TakeAction call on the TrainEnv in the run"`
+	Idx     int           `inactive:"+" desc:"index of the next step to replay"`
+	NMisPos int           `inactive:"+" desc:"number of replayed steps whose position differs from the saved one in the run"`
+	Saved   bool          `view:"-" desc:"the trajectory has been saved, so it is no longer recorded"`
+}
+
+// Replaying returns true if a saved trajectory is replayed
+func (tj *Trajectory) Replaying() bool {
+	return tj.File != "" && tj.Steps != nil
+}
+
+// Recording returns true if the trajectory of the run is recorded, to Save
+func (tj *Trajectory) Recording() bool {
+	return tj.Save != "" && !tj.Saved && !tj.Replaying()
+}
+
+// Reset starts the trajectory of a new run -- called in NewRun
+func (tj *Trajectory) Reset() {
+	tj.Call = 0
+	tj.Idx = 0
+	tj.NMisPos = 0
+	if tj.Recording() && tj.Steps != nil {
+		tj.Steps.SetNumRows(0)
+	}
+}
+
+// OpenTraj opens the saved trajectory File for replay
+func (ss *Sim) OpenTraj() error {
+	tj := &ss.Traj
+	dt := &etable.Table{}
+	ConfigTrajTable(dt)
+	err := dt.OpenCSV(gi.FileName(tj.File), etable.Tab)
+	if err != nil {
+		return err
+	}
+	if dt.Rows == 0 {
+		return fmt.Errorf("Trajectory: no steps in: %s", tj.File)
+	}
+	tj.Steps = dt
+	tj.Reset()
+	return nil
+}
+
+// TrajRecord records the step just taken by the TrainEnv with given command
+func (ss *Sim) TrajRecord(ev *XYHDEnv, cmd string) {
+	tj := &ss.Traj
+	if tj.Steps == nil {
+		tj.Steps = &etable.Table{}
+		ConfigTrajTable(tj.Steps)
+	}
+	dt := tj.Steps
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Call", row, float64(tj.Call))
+	dt.SetCellString("Cmd", row, cmd)
+	dt.SetCellString("Act", row, ev.ActExec.Act.String())
+	dt.SetCellFloat("Turn", row, float64(ev.ActExec.Turn))
+	dt.SetCellFloat("Step", row, float64(ev.ActExec.Step))
+	dt.SetCellFloat("X", row, float64(ev.PosF.X))
+	dt.SetCellFloat("Y", row, float64(ev.PosF.Y))
+	dt.SetCellFloat("Angle", row, float64(ev.Angle))
+	dt.SetCellString("Bout", row, ev.Bout)
+}
+
+// TrajReplay replays the steps of the current TakeAction call on the
+// TrainEnv, returning false if the trajectory is exhausted
+func (ss *Sim) TrajReplay(ev *XYHDEnv) bool {
+	tj := &ss.Traj
+	dt := tj.Steps
+	if tj.Idx >= dt.Rows {
+		if tj.Idx == dt.Rows {
+			log.Printf("Trajectory: %s exhausted after %d steps, generating actions\n", tj.File, dt.Rows)
+			tj.Idx++
+		}
+		return false
+	}
+	for ; tj.Idx < dt.Rows && int(dt.CellFloat("Call", tj.Idx)) == tj.Call; tj.Idx++ {
+		act, ok := ActionFromString(dt.CellString("Act", tj.Idx))
+		if !ok {
+			log.Printf("Trajectory: action not recognized: %s\n", dt.CellString("Act", tj.Idx))
+			continue
+		}
+		ac := Action{Act: act, Turn: float32(dt.CellFloat("Turn", tj.Idx)), Step: float32(dt.CellFloat("Step", tj.Idx))}
+		ss.ActAction = dt.CellString("Cmd", tj.Idx)
+		ev.Bout = dt.CellString("Bout", tj.Idx)
+		pos, pf := ev.PosI, ev.PosF
+		ev.ExecAct(ac)
+		ss.ExecAction = ev.ActExec.String()
+		ss.TrlSteps = append(ss.TrlSteps, ActStep{Act: ev.ActExec.Act, Pos: pos, Moved: ev.PosI != pos, Dist: ev.PosF.Sub(pf).Length(), Bout: ev.Bout})
+		sp := mat32.Vec2{float32(dt.CellFloat("X", tj.Idx)), float32(dt.CellFloat("Y", tj.Idx))}
+		if ev.PosF.Sub(sp).Length() > 1.0e-4 {
+			if tj.NMisPos == 0 {
+				log.Printf("Trajectory: replayed position %v differs from saved %v at step %d -- different world?\n", ev.PosF, sp, tj.Idx)
+			}
+			tj.NMisPos++
+		}
+	}
+	return true
+}
+
+// SaveTraj saves the recorded trajectory to Save, at the end of the first
+// run -- called in RunEnd
+func (ss *Sim) SaveTraj() {
+	tj := &ss.Traj
+	if !tj.Recording() || tj.Steps == nil {
+		return
+	}
+	tj.Steps.SaveCSV(gi.FileName(tj.Save), etable.Tab, etable.Headers)
+	fmt.Printf("Saved trajectory of %d steps to: %s\n", tj.Steps.Rows, tj.Save)
+	tj.Saved = true
+}
+
+func ConfigTrajTable(dt *etable.Table) {
+	dt.SetMetaData("name", "Trajectory")
+	dt.SetMetaData("desc", "Executed actions and resulting positions of each step of the TrainEnv over a run")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", "9") // float32 round trip, so positions replay exactly
+
+	sch := etable.Schema{
+		{"Call", etensor.INT64, nil, nil},
+		{"Cmd", etensor.STRING, nil, nil},
+		{"Act", etensor.STRING, nil, nil},
+		{"Turn", etensor.FLOAT64, nil, nil},
+		{"Step", etensor.FLOAT64, nil, nil},
+		{"X", etensor.FLOAT64, nil, nil},
+		{"Y", etensor.FLOAT64, nil, nil},
+		{"Angle", etensor.INT64, nil, nil},
+		{"Bout", etensor.STRING, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
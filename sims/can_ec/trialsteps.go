@@ -0,0 +1,52 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/etable/etensor"
+)
+
+// SubStep moves to the next state within the current trial, for trials that
+// span several env steps (TrialSteps), without advancing the counters
+func (ev *XYHDEnv) SubStep() {
+	ev.CopyNextToCur()
+}
+
+// SubStep records the input pattern for the next env step within the current
+// trial, keeping whether input is applied on this trial, and forces
+// ClampCycle to apply it at the next cycle it is on
+func (cs *ClampSched) SubStep(pats etensor.Tensor) {
+	cs.Pats = pats
+	cs.Clamped = false
+}
+
+// TrialSubStep takes the next of the TrialSteps env steps of the current
+// trial, if it is due at the current cycle: the sub-steps are evenly spaced
+// over the minus phase, taking the action and applying the patterns of the
+// new state, with the input manipulations of the trial set up by
+// ApplyInputs at its start, so that the targets applied by the last sub-step
+// are those of the end of the trial -- called in AlphaCycCycle prior to
+// ClampCycle
+func (ss *Sim) TrialSubStep() {
+	as := &ss.Alpha
+	k := ss.TrialSteps
+	if k <= 1 || as.Env == nil || as.SubStep >= k {
+		return
+	}
+	mc := ss.MinusQtrs * ss.CycPerQtr
+	cyc := as.Qtr*ss.CycPerQtr + as.Cyc
+	if cyc >= mc || cyc < (as.SubStep*mc)/k {
+		return
+	}
+	as.SubStep++
+	ev := as.Env
+	ss.TakeAction(ss.Net, ev)
+	if ev == &ss.TrainEnv {
+		ss.ActStats.AddTrial(ss.TrlSteps, ev.Size)
+		ss.Speed.AddSteps(ss.TrlSteps)
+	}
+	ev.SubStep()
+	ss.ApplyStatePats(ev, true)
+}
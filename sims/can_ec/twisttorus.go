@@ -0,0 +1,157 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/emergent/efuns"
+	"github.com/emer/emergent/prjn"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/mat32"
+)
+
+// TwistTorus implements a circular pattern of connectivity on a twisted torus
+// (Guanella et al., 2007): the sheet of pools is laid out with rows YScale
+// apart, and wraps around in X as usual, but wrapping around in Y also shifts
+// X by Shear of the sheet width.  With the default YScale = sqrt(3)/2 and
+// Shear = .5, the periodic boundaries are those of a hexagonal lattice, so
+// lateral inhibition settles into hexagonal rather than square grids, without
+// having to bias the units within each pool toward different offsets.
+// For 4D layers, distances are between pools, and all units of pools within
+// Radius are connected, as in PoolTile -- 2D layers are treated as one unit
+// per pool.  A Gaussian bump of TopoWts is available as in Circle, set by
+// InitTwistTorusScales.
+type TwistTorus struct {
+	Radius  float32 `desc:"radius of the circle, in pools, in the sheet coordinates"`
+	YScale  float32 `def:"0.866" desc:"distance between rows of pools, relative to columns -- sqrt(3)/2 for a hexagonal lattice"`
+	Shear   float32 `def:"0.5" desc:"shift in X, as a proportion of the sheet width, when wrapping around in Y -- 0 = standard torus"`
+	TopoWts bool    `desc:"if true, this prjn should set gaussian topographic weights, according to following parameters"`
+	Sigma   float32 `desc:"gaussian sigma (width) as a proportion of the radius of the circle"`
+	MaxWt   float32 `desc:"maximum weight value for GaussWts function -- multiplies values"`
+	SelfCon bool    `desc:"if true, and connecting layer to itself (self projection), then make a self-connection from unit to itself"`
+}
+
+func NewTwistTorus() *TwistTorus {
+	tt := &TwistTorus{}
+	tt.Defaults()
+	return tt
+}
+
+func (tt *TwistTorus) Defaults() {
+	tt.Radius = 2
+	tt.YScale = 0.866
+	tt.Shear = 0.5
+	tt.Sigma = 0.5
+	tt.MaxWt = 1
+}
+
+func (tt *TwistTorus) Name() string {
+	return "TwistTorus"
+}
+
+// twistPools returns the number of pools in Y and X, and units per pool, of
+// given layer shape: 2D layers have one unit per pool
+func twistPools(sh *etensor.Shape) (ny, nx, nu int) {
+	if sh.NumDims() == 4 {
+		return sh.Dim(0), sh.Dim(1), sh.Dim(2) * sh.Dim(3)
+	}
+	return sh.Dim(0), sh.Dim(1), 1
+}
+
+// Dist returns the minimum distance between pool positions a and b, in pool
+// units, over the twisted-torus wrap-arounds of a sheet of nx by ny pools
+func (tt *TwistTorus) Dist(a, b mat32.Vec2, nx, ny int) float32 {
+	w := float32(nx)
+	h := float32(ny) * tt.YScale
+	d := a.Sub(b)
+	d.Y *= tt.YScale
+	md := d.Length()
+	for wy := -1; wy <= 1; wy++ {
+		for wx := -1; wx <= 1; wx++ {
+			off := mat32.Vec2{float32(wx)*w + float32(wy)*tt.Shear*w, float32(wy) * h}
+			if l := d.Add(off).Length(); l < md {
+				md = l
+			}
+		}
+	}
+	return md
+}
+
+// RecvCtr returns the position in the sending sheet corresponding to given
+// receiving pool, scaled by the relative sizes of the sheets
+func (tt *TwistTorus) RecvCtr(ry, rx, rNy, rNx, sNy, sNx int) mat32.Vec2 {
+	return mat32.Vec2{float32(rx) * float32(sNx) / float32(rNx), float32(ry) * float32(sNy) / float32(rNy)}
+}
+
+func (tt *TwistTorus) Connect(send, recv *etensor.Shape, same bool) (sendn, recvn *etensor.Int32, cons *etensor.Bits) {
+	sendn, recvn, cons = prjn.NewTensors(send, recv)
+	sNy, sNx, sNu := twistPools(send)
+	rNy, rNx, rNu := twistPools(recv)
+
+	rnv := recvn.Values
+	snv := sendn.Values
+	sNtot := send.Len()
+
+	for ry := 0; ry < rNy; ry++ {
+		for rx := 0; rx < rNx; rx++ {
+			sctr := tt.RecvCtr(ry, rx, rNy, rNx, sNy, sNx)
+			for sy := 0; sy < sNy; sy++ {
+				for sx := 0; sx < sNx; sx++ {
+					sp := mat32.Vec2{float32(sx), float32(sy)}
+					if tt.Dist(sp, sctr, sNx, sNy) > tt.Radius {
+						continue
+					}
+					for ru := 0; ru < rNu; ru++ {
+						ri := (ry*rNx+rx)*rNu + ru
+						for su := 0; su < sNu; su++ {
+							si := (sy*sNx+sx)*sNu + su
+							if !tt.SelfCon && same && ri == si {
+								continue
+							}
+							cons.Values.Set(ri*sNtot+si, true)
+							rnv[ri]++
+							snv[si]++
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// GaussWts returns gaussian weight value for given unit indexes in
+// given send and recv layers according to Gaussian Sigma and MaxWt,
+// as a function of the twisted-torus distance between their pools.
+// Can be used for a Prjn.SetScalesFunc or SetWtsFunc
+func (tt *TwistTorus) GaussWts(si, ri int, send, recv *etensor.Shape) float32 {
+	sNy, sNx, sNu := twistPools(send)
+	rNy, rNx, rNu := twistPools(recv)
+	rpi := ri / rNu
+	spi := si / sNu
+	sctr := tt.RecvCtr(rpi/rNx, rpi%rNx, rNy, rNx, sNy, sNx)
+	sp := mat32.Vec2{float32(spi % sNx), float32(spi / sNx)}
+	d := tt.Dist(sp, sctr, sNx, sNy)
+	return tt.MaxWt * efuns.Gauss1DNoNorm(d, tt.Sigma*tt.Radius)
+}
+
+// InitTwistTorusScales sets the gaussian topographic weight scales of all the
+// TwistTorus prjns with TopoWts in net -- the counterpart of InitTopoScales,
+// which only knows about the standard patterns, and must be called after it
+func InitTwistTorusScales(net *leabra.Network) {
+	for _, ly := range net.Layers {
+		if ly.IsOff() {
+			continue
+		}
+		for pi := 0; pi < ly.NRecvPrjns(); pi++ {
+			pj := ly.RecvPrjn(pi)
+			tt, ok := pj.Pattern().(*TwistTorus)
+			if !ok || !tt.TopoWts {
+				continue
+			}
+			pj.(leabra.LeabraPrjn).AsLeabra().SetScalesFunc(tt.GaussWts)
+		}
+	}
+}
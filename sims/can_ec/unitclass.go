@@ -0,0 +1,516 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/emer/emergent/actrf"
+	"github.com/emer/emergent/evec"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/ki/ints"
+)
+
+// UnitClasses are the functional classes of units assigned by UnitClass:
+// a spatial class (Grid, Border or Place), HD if only heading tuned, Conj
+// if both spatially and heading tuned, and None otherwise
+var UnitClasses = []string{"Grid", "Border", "Place", "HD", "Conj", "None"}
+
+// UnitClass classifies every unit of the Layers as a grid, border, place,
+// head-direction or conjunctive cell, combining the gridness score of its
+// position RF autocorrelogram, the border score and spatial information of
+// its position RF, and the mean vector length of its heading RF.  The RFs
+// are accumulated over the training trials, and on the Periodic schedule the
+// proportion of units in each class is logged to the UnitClassLog, for the
+// fraction of grid cells over learning.
+type UnitClass struct {
+	Periodic
+	Bin          int     `def:"2" min:"1" desc:"size of the square bins of world cells that the position RFs are averaged in for the gridness autocorrelogram, for speed"`
+	GridThr      float64 `def:"0.3" desc:"gridness score above which a unit is a grid cell"`
+	BorderThr    float64 `def:"0.5" desc:"border score above which a unit is a border cell"`
+	PlaceThr     float64 `def:"0.5" desc:"spatial information, in bits, above which a unit with a compact field is a place cell"`
+	PlaceMaxFrac float64 `def:"0.25" desc:"maximum fraction of the arena covered by the firing field of a place cell"`
+	FieldThr     float64 `def:"0.2" desc:"fraction of the peak rate above which a location is in the firing field, for the border score and field size"`
+	MVLThr       float64 `def:"0.25" desc:"head-direction mean vector length above which a unit is heading tuned"`
+
+	RFs TrnRFs `view:"inline" desc:"position and heading RFs of the Layers since the last classification"`
+}
+
+// Defaults sets default params
+func (uc *UnitClass) Defaults() {
+	uc.Bin = 2
+	uc.GridThr = 0.3
+	uc.BorderThr = 0.5
+	uc.PlaceThr = 0.5
+	uc.PlaceMaxFrac = 0.25
+	uc.FieldThr = 0.2
+	uc.MVLThr = 0.25
+}
+
+// TrnRFs accumulates the position and heading RFs of layers over training
+// trials, named as the ARFs (layer_Pos and layer_Ang)
+type TrnRFs struct {
+	N   int              `inactive:"+" desc:"number of trials accumulated in the RFs"`
+	Pos *etensor.Float32 `view:"-" desc:"one-hot map of the TrainEnv position, the source of the Pos RFs"`
+	Ang *etensor.Float32 `view:"-" desc:"one-hot map of the TrainEnv heading, the source of the Ang RFs"`
+	RFs actrf.RFs        `view:"no-inline" desc:"the RFs"`
+}
+
+// Reset clears the accumulated RFs
+func (tr *TrnRFs) Reset() {
+	tr.RFs = actrf.RFs{}
+	tr.N = 0
+}
+
+// AccumTrnRFs accumulates the RFs of given layers in tr for the current
+// training trial
+func (ss *Sim) AccumTrnRFs(tr *TrnRFs, lays []string) {
+	ev := &ss.TrainEnv
+	if tr.Pos == nil {
+		tr.Pos = &etensor.Float32{}
+		tr.Ang = &etensor.Float32{}
+	}
+	tr.Pos.CopyShapeFrom(ev.World)
+	tr.Pos.SetZeros()
+	tr.Pos.Set([]int{ev.PosI.Y, ev.PosI.X}, 1)
+	tr.Ang.SetShape([]int{ev.NRotAngles}, nil, nil)
+	tr.Ang.SetZeros()
+	tr.Ang.Set1D(ev.AngIdx(ev.Angle), 1)
+	for _, lnm := range lays {
+		ly := ss.Net.LayerByName(lnm)
+		if ly == nil {
+			continue
+		}
+		vt := ss.ValsTsr(lnm)
+		ly.UnitValsTensor(vt, "ActM")
+		for _, src := range []struct {
+			nm  string
+			tsr *etensor.Float32
+		}{{"_Pos", tr.Pos}, {"_Ang", tr.Ang}} {
+			if tr.RFs.RFByName(lnm+src.nm) == nil {
+				tr.RFs.AddRF(lnm+src.nm, vt, src.tsr)
+			}
+			tr.RFs.Add(lnm+src.nm, vt, src.tsr, 0.01)
+		}
+	}
+	tr.N++
+}
+
+// Class returns the class of a unit with given scores
+func (uc *UnitClass) Class(grid, border, spatInfo, fieldFrac, mvl float64) string {
+	sp := ""
+	switch {
+	case grid >= uc.GridThr:
+		sp = "Grid"
+	case border >= uc.BorderThr:
+		sp = "Border"
+	case spatInfo >= uc.PlaceThr && fieldFrac <= uc.PlaceMaxFrac:
+		sp = "Place"
+	}
+	hd := mvl >= uc.MVLThr
+	switch {
+	case sp != "" && hd:
+		return "Conj"
+	case sp != "":
+		return sp
+	case hd:
+		return "HD"
+	}
+	return "None"
+}
+
+// corrAcc accumulates the sums for a Pearson correlation
+type corrAcc struct {
+	n, sx, sy, sxx, syy, sxy float64
+}
+
+func (ca *corrAcc) Add(x, y float64) {
+	ca.n++
+	ca.sx += x
+	ca.sy += y
+	ca.sxx += x * x
+	ca.syy += y * y
+	ca.sxy += x * y
+}
+
+// Corr returns the correlation, and false if there are fewer than minN
+// pairs or either variable is constant
+func (ca *corrAcc) Corr(minN int) (float64, bool) {
+	if ca.n < float64(minN) {
+		return 0, false
+	}
+	vx := ca.n*ca.sxx - ca.sx*ca.sx
+	vy := ca.n*ca.syy - ca.sy*ca.sy
+	if vx <= 0 || vy <= 0 {
+		return 0, false
+	}
+	return (ca.n*ca.sxy - ca.sx*ca.sy) / math.Sqrt(vx*vy), true
+}
+
+// ArenaGeom is the geometry of the arena of a world used by the spatial
+// scores: which cells are in the arena, their distance to the nearest wall,
+// and which walls they are next to, so that irregular arenas are handled
+type ArenaGeom struct {
+	Size    evec.Vec2i `desc:"size of the world"`
+	In      []bool     `desc:"cell is in the arena"`
+	Dist    []float64  `desc:"distance of each arena cell to the nearest wall, in steps: 1 = next to a wall"`
+	Walls   []int      `desc:"bit mask of the directions (left, right, down, up) in which each arena cell is next to a wall"`
+	NWall   [4]int     `desc:"number of arena cells next to a wall in each direction"`
+	NIn     int        `desc:"number of arena cells"`
+	MaxDist float64    `desc:"maximum Dist over the arena"`
+}
+
+// NewArenaGeom returns the arena geometry of the world of given env
+func NewArenaGeom(ev *XYHDEnv) *ArenaGeom {
+	sz := ev.Size
+	n := sz.X * sz.Y
+	ag := &ArenaGeom{Size: sz, In: make([]bool, n), Dist: make([]float64, n), Walls: make([]int, n)}
+	dirs := []evec.Vec2i{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	var queue []int
+	for i := range ag.In {
+		ag.In[i] = ev.InArena(evec.Vec2i{i % sz.X, i / sz.X})
+		if ag.In[i] {
+			ag.NIn++
+			ag.Dist[i] = -1
+		}
+	}
+	for i, in := range ag.In {
+		if !in {
+			continue
+		}
+		p := evec.Vec2i{i % sz.X, i / sz.X}
+		for di, d := range dirs {
+			np := p.Add(d)
+			if np.X < 0 || np.Y < 0 || np.X >= sz.X || np.Y >= sz.Y || !ag.In[np.Y*sz.X+np.X] {
+				ag.Walls[i] |= 1 << uint(di)
+				ag.NWall[di]++
+			}
+		}
+		if ag.Walls[i] != 0 {
+			ag.Dist[i] = 1
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		p := evec.Vec2i{i % sz.X, i / sz.X}
+		ag.MaxDist = math.Max(ag.MaxDist, ag.Dist[i])
+		for _, d := range dirs {
+			np := p.Add(d)
+			if np.X < 0 || np.Y < 0 || np.X >= sz.X || np.Y >= sz.Y {
+				continue
+			}
+			ni := np.Y*sz.X + np.X
+			if ag.In[ni] && ag.Dist[ni] < 0 {
+				ag.Dist[ni] = ag.Dist[i] + 1
+				queue = append(queue, ni)
+			}
+		}
+	}
+	return ag
+}
+
+// Field returns the peak rate over the arena, and the mask of arena cells
+// in the firing field: rate at least thr times the peak
+func (ag *ArenaGeom) Field(rates []float64, thr float64) (float64, []bool) {
+	peak := 0.0
+	for i, r := range rates {
+		if ag.In[i] && r > peak {
+			peak = r
+		}
+	}
+	fld := make([]bool, len(rates))
+	if peak <= 0 {
+		return peak, fld
+	}
+	for i, r := range rates {
+		fld[i] = ag.In[i] && r >= thr*peak
+	}
+	return peak, fld
+}
+
+// FieldFrac returns the fraction of the arena in the firing field fld
+func (ag *ArenaGeom) FieldFrac(fld []bool) float64 {
+	if ag.NIn == 0 {
+		return 0
+	}
+	n := 0
+	for _, f := range fld {
+		if f {
+			n++
+		}
+	}
+	return float64(n) / float64(ag.NIn)
+}
+
+// BorderScore returns the border score (Solstad et al, 2008) of given rates
+// with firing field fld: (cM - dm) / (cM + dm), where cM is the largest
+// fraction of the cells along any one wall direction covered by the field,
+// and dm is the rate-weighted mean distance of the field to the nearest
+// wall, normalized by the largest distance in the arena
+func (ag *ArenaGeom) BorderScore(rates []float64, fld []bool) float64 {
+	cM := 0.0
+	for di, nw := range ag.NWall {
+		if nw == 0 {
+			continue
+		}
+		n := 0
+		for i, f := range fld {
+			if f && ag.Walls[i]&(1<<uint(di)) != 0 {
+				n++
+			}
+		}
+		cM = math.Max(cM, float64(n)/float64(nw))
+	}
+	var sd, sw float64
+	for i, f := range fld {
+		if f {
+			sd += rates[i] * (ag.Dist[i] - 1)
+			sw += rates[i]
+		}
+	}
+	if sw <= 0 {
+		return 0
+	}
+	dm := 0.0
+	if ag.MaxDist > 1 {
+		dm = sd / sw / (ag.MaxDist - 1)
+	}
+	if cM+dm == 0 {
+		return 0
+	}
+	return (cM - dm) / (cM + dm)
+}
+
+// Gridness returns the gridness score of given rates: the spatial
+// autocorrelogram of the rates, averaged in bin x bin cells of the arena,
+// is correlated with itself rotated by 30 to 150 degrees, over the annulus
+// around its central peak, and the score is the lower of the correlations
+// at 60 and 120 minus the highest at 30, 90 and 150 -- high for hexagonal
+// grids.  Returns 0 if the autocorrelogram can not be computed.
+func (ag *ArenaGeom) Gridness(rates []float64, bin int) float64 {
+	bin = ints.MaxInt(bin, 1)
+	by, bx := ag.Size.Y/bin, ag.Size.X/bin
+	bm := make([]float64, by*bx)
+	bn := make([]int, by*bx)
+	for i, in := range ag.In {
+		y, x := (i/ag.Size.X)/bin, (i%ag.Size.X)/bin
+		if !in || y >= by || x >= bx {
+			continue
+		}
+		bm[y*bx+x] += rates[i]
+		bn[y*bx+x]++
+	}
+	for i, n := range bn {
+		if n > 0 {
+			bm[i] /= float64(n)
+		}
+	}
+	lag := ints.MinInt(by, bx) / 2
+	if lag < 3 {
+		return 0
+	}
+	aw := 2*lag + 1
+	ac := make([]float64, aw*aw)
+	acOk := make([]bool, aw*aw)
+	for dy := -lag; dy <= lag; dy++ {
+		for dx := -lag; dx <= lag; dx++ {
+			var ca corrAcc
+			for y := ints.MaxInt(0, -dy); y < ints.MinInt(by, by-dy); y++ {
+				for x := ints.MaxInt(0, -dx); x < ints.MinInt(bx, bx-dx); x++ {
+					i := y*bx + x
+					j := (y+dy)*bx + x + dx
+					if bn[i] > 0 && bn[j] > 0 {
+						ca.Add(bm[i], bm[j])
+					}
+				}
+			}
+			ai := (dy+lag)*aw + dx + lag
+			ac[ai], acOk[ai] = ca.Corr(20)
+		}
+	}
+	rotCorr := func(deg float64) (float64, bool) {
+		s, c := math.Sincos(deg * math.Pi / 180)
+		var ca corrAcc
+		for dy := -lag; dy <= lag; dy++ {
+			for dx := -lag; dx <= lag; dx++ {
+				r := math.Hypot(float64(dx), float64(dy))
+				ai := (dy+lag)*aw + dx + lag
+				if r < 2 || r > float64(lag) || !acOk[ai] {
+					continue
+				}
+				rx := int(math.Round(float64(dx)*c - float64(dy)*s))
+				ry := int(math.Round(float64(dx)*s + float64(dy)*c))
+				if rx < -lag || rx > lag || ry < -lag || ry > lag {
+					continue
+				}
+				ri := (ry+lag)*aw + rx + lag
+				if acOk[ri] {
+					ca.Add(ac[ai], ac[ri])
+				}
+			}
+		}
+		return ca.Corr(10)
+	}
+	var cs [5]float64
+	for i := range cs {
+		cr, ok := rotCorr(float64(30 * (i + 1)))
+		if !ok {
+			return 0
+		}
+		cs[i] = cr
+	}
+	return math.Min(cs[1], cs[3]) - math.Max(cs[0], math.Max(cs[2], cs[4]))
+}
+
+// ClassifyUnits computes the scores and class of each unit of given layers,
+// from their Pos and Ang RFs in rfs, which must have been averaged, in the
+// arena of given env
+func (ss *Sim) ClassifyUnits(rfs *actrf.RFs, lays []string, ev *XYHDEnv) *etable.Table {
+	uc := &ss.UnitClass
+	dt := &etable.Table{}
+	ConfigUnitClassTable(dt)
+	ag := NewArenaGeom(ev)
+	angs := make([]float64, ev.NRotAngles)
+	for i := range angs {
+		angs[i] = float64(i * ev.AngInc)
+	}
+	wts := make([]float64, len(angs))
+	for _, lnm := range lays {
+		paf, err := rfs.RFByNameTry(lnm + "_Pos")
+		if err != nil {
+			continue
+		}
+		aaf, err := rfs.RFByNameTry(lnm + "_Ang")
+		if err != nil {
+			continue
+		}
+		np := len(paf.SumSrc.Values)
+		if np != len(ag.In) {
+			continue // RFs of another world size
+		}
+		occ := make([]float64, np)
+		for pi := range occ {
+			occ[pi] = float64(paf.SumSrc.Values[pi])
+		}
+		rates := make([]float64, np)
+		nb := len(angs)
+		nu := len(aaf.RF.Values) / nb
+		for ui := 0; ui < nu; ui++ {
+			for pi := range rates {
+				rates[pi] = float64(paf.RF.Values[ui*np+pi])
+			}
+			for bi := range wts {
+				wts[bi] = float64(aaf.RF.Values[ui*nb+bi])
+			}
+			_, fld := ag.Field(rates, uc.FieldThr)
+			grid := ag.Gridness(rates, uc.Bin)
+			border := ag.BorderScore(rates, fld)
+			si := SpatialInfo(rates, occ)
+			ff := ag.FieldFrac(fld)
+			_, mvl := CircMean(angs, wts)
+			row := dt.Rows
+			dt.SetNumRows(row + 1)
+			dt.SetCellString("Layer", row, lnm)
+			dt.SetCellFloat("Unit", row, float64(ui))
+			dt.SetCellFloat("Gridness", row, grid)
+			dt.SetCellFloat("Border", row, border)
+			dt.SetCellFloat("SpatInfo", row, si)
+			dt.SetCellFloat("FieldFrac", row, ff)
+			dt.SetCellFloat("MVL", row, mvl)
+			dt.SetCellString("Class", row, uc.Class(grid, border, si, ff, mvl))
+		}
+	}
+	return dt
+}
+
+func init() {
+	AddAnalysis(&Analysis{Name: "unitclass", Label: "Unit Class", Desc: "classify ARF layer units as grid, border, place, head-direction or conjunctive cells, from the gridness, border score and spatial information of their Pos, and the mean vector length of their Ang activation rfs.", Needs: []string{AnalysisARFs}, Run: func(ss *Sim) []AnalysisOut {
+		ss.AvgNormARFs(nil)
+		dt := ss.ClassifyUnits(&ss.ARFs, ss.ARFLayers, &ss.TestEnv)
+		return []AnalysisOut{{Name: "unitclass", Table: dt}, {Name: "unitfrac", Table: ClassFracs(dt, "UnitClassFracs", UnitClasses)}}
+	}})
+}
+
+// UnitClassTrial accumulates the position and heading RFs of given layers
+// for the current training trial -- the Trial of the unitclass
+// PeriodicAnalysis
+func (ss *Sim) UnitClassTrial(lays []string) {
+	ss.AccumTrnRFs(&ss.UnitClass.RFs, lays)
+}
+
+// UnitClassEpoch classifies the units of given layers from the RFs
+// accumulated since the last classification, adding a row per layer of the
+// class proportions to dt -- the Epoch of the unitclass PeriodicAnalysis
+func (ss *Sim) UnitClassEpoch(dt *etable.Table, epc int, lays []string) {
+	uc := &ss.UnitClass
+	if uc.RFs.N == 0 {
+		return
+	}
+	uc.RFs.RFs.Avg()
+	ft := ClassFracs(ss.ClassifyUnits(&uc.RFs.RFs, lays, &ss.TrainEnv), "UnitClassFracs", UnitClasses)
+	for fr := 0; fr < ft.Rows; fr++ {
+		row := ss.AddPeriodicRow(dt, epc, ft.CellString("Layer", fr))
+		dt.SetCellFloat("NUnits", row, ft.CellFloat("NUnits", fr))
+		for _, cl := range UnitClasses {
+			dt.SetCellFloat(cl, row, ft.CellFloat(cl, fr))
+		}
+	}
+
+	ss.UpdtPlot(ss.UnitClassPlot)
+}
+
+func ConfigUnitClassTable(dt *etable.Table) {
+	dt.SetMetaData("name", "UnitClass")
+	dt.SetMetaData("desc", "Gridness, border, place and head-direction scores and class of units, from Pos and Ang receptive fields")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Layer", etensor.STRING, nil, nil},
+		{"Unit", etensor.INT64, nil, nil},
+		{"Gridness", etensor.FLOAT64, nil, nil},
+		{"Border", etensor.FLOAT64, nil, nil},
+		{"SpatInfo", etensor.FLOAT64, nil, nil},
+		{"FieldFrac", etensor.FLOAT64, nil, nil},
+		{"MVL", etensor.FLOAT64, nil, nil},
+		{"Class", etensor.STRING, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigUnitClassLog(dt *etable.Table) {
+	dt.SetMetaData("name", "UnitClassLog")
+	dt.SetMetaData("desc", "Fraction of units in each functional class (grid, border, place, HD, conjunctive) per layer over training")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := PeriodicSchema(etable.Schema{
+		{"NUnits", etensor.INT64, nil, nil},
+	})
+	for _, cl := range UnitClasses {
+		sch = append(sch, etable.Column{cl, etensor.FLOAT64, nil, nil})
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigUnitClassPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Unit Class Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.Params.LegendCol = "Layer"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NUnits", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	for _, cl := range UnitClasses {
+		plt.SetColParams(cl, cl == "Grid", eplot.FixMin, 0, eplot.FixMax, 1)
+	}
+	return plt
+}
@@ -0,0 +1,368 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/emergent/netview"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+// UnitGroup is a named group of units of a layer, defined by their index
+// within each pool, e.g., the same one of the 2x2 sub-units of every EC pool,
+// which form separate phase populations
+type UnitGroup struct {
+	Name  string `desc:"name of the group"`
+	Layer string `desc:"name of the layer of the group"`
+	Units []int  `desc:"indexes of the units of the group within each pool of the layer, in row-major order -- within the whole layer if it has no pools"`
+}
+
+// UnitGroups computes stats, ARFs and NetView highlighting per named group
+// of units within a layer, as the 4D pool substructure of EC has functional
+// meaning that layer-level stats wash out.  On each training trial, the
+// mean ActM of the units of each group, and the fraction of them above
+// ActThr, are accumulated, and logged to the UnitGroupLog at the end of each
+// epoch.  With ARFs, the ARFs of each group are computed along with those of
+// the ARFLayers, named <group>_Pos etc, on the units of the group as a
+// [pools Y, pools X, 1, units] tensor.  The Highlight group is shown in the
+// NetView by masking the recorded values of the other units of its layer.
+type UnitGroups struct {
+	Groups    []UnitGroup `desc:"the unit groups"`
+	Each      []string    `desc:"layers split into a group per unit index within their pools, named <layer>_u<index>, in addition to the Groups"`
+	ARFs      bool        `desc:"compute the ARFs of each group, along with those of the ARFLayers"`
+	ActThr    float32     `def:"0.5" desc:"ActM above which a unit is counted as active, for PctAct"`
+	Highlight string      `desc:"group highlighted in the NetView, by masking the other units of its layer -- empty = none"`
+
+	All  []UnitGroup        `view:"-" desc:"the Groups and those of the Each layers, validated by ConfigUnitGroups"`
+	Idxs map[string][]int   `view:"-" desc:"1D indexes of the units of each group in its layer"`
+	Act  map[string]float64 `view:"-" desc:"sum of the mean ActM of each group over the trials of the epoch"`
+	Pct  map[string]float64 `view:"-" desc:"sum of the fraction of active units of each group over the trials of the epoch"`
+	N    int                `view:"-" desc:"number of trials in the sums"`
+}
+
+// Defaults sets default params
+func (ug *UnitGroups) Defaults() {
+	ug.ActThr = 0.5
+}
+
+// Reset clears the sums
+func (ug *UnitGroups) Reset() {
+	ug.Act = make(map[string]float64)
+	ug.Pct = make(map[string]float64)
+	ug.N = 0
+}
+
+// ParseUnitGroups parses unit groups from semicolon-separated entries of
+// either Name=Layer:idx,idx for a group of given unit indexes within each
+// pool, or Layer:each for a group per unit index within the pools of Layer,
+// e.g., EC:each or EC_diag=EC:0,3;EC_off=EC:1,2
+func ParseUnitGroups(s string) (grps []UnitGroup, each []string, err error) {
+	for _, e := range strings.Split(s, ";") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if strings.HasSuffix(e, ":each") {
+			each = append(each, strings.TrimSuffix(e, ":each"))
+			continue
+		}
+		nl := strings.SplitN(e, "=", 2)
+		if len(nl) != 2 {
+			return nil, nil, fmt.Errorf("UnitGroups: %q must be Name=Layer:idx,idx or Layer:each", e)
+		}
+		lu := strings.SplitN(nl[1], ":", 2)
+		if len(lu) != 2 || nl[0] == "" || lu[0] == "" {
+			return nil, nil, fmt.Errorf("UnitGroups: %q must be Name=Layer:idx,idx", e)
+		}
+		ug := UnitGroup{Name: nl[0], Layer: lu[0]}
+		for _, is := range strings.Split(lu[1], ",") {
+			ui, err := strconv.Atoi(strings.TrimSpace(is))
+			if err != nil || ui < 0 {
+				return nil, nil, fmt.Errorf("UnitGroups: unit index of %s must be a non-negative integer: %s", ug.Name, is)
+			}
+			ug.Units = append(ug.Units, ui)
+		}
+		grps = append(grps, ug)
+	}
+	return grps, each, nil
+}
+
+// PoolShape returns the number of pools in Y and X of given layer, and the
+// number of units per pool -- 1x1 pools of all the units if it has no pools
+func PoolShape(ly emer.Layer) (py, px, nu int) {
+	shp := ly.Shape()
+	if shp.NumDims() == 4 {
+		return shp.Dim(0), shp.Dim(1), shp.Dim(2) * shp.Dim(3)
+	}
+	return 1, 1, shp.Len()
+}
+
+// UnitIdxs returns the 1D indexes in given layer of the units of the group
+func (ug *UnitGroup) UnitIdxs(ly emer.Layer) []int {
+	py, px, nu := PoolShape(ly)
+	idxs := make([]int, 0, py*px*len(ug.Units))
+	for pi := 0; pi < py*px; pi++ {
+		for _, ui := range ug.Units {
+			idxs = append(idxs, pi*nu+ui)
+		}
+	}
+	return idxs
+}
+
+// ConfigUnitGroups validates the unit groups on the network, expanding the
+// Each layers, and reporting and skipping groups whose layer or units do
+// not exist -- called in Config after the network
+func (ss *Sim) ConfigUnitGroups() {
+	ug := &ss.UnitGroups
+	ug.All = nil
+	ug.Idxs = make(map[string][]int)
+	grps := append([]UnitGroup(nil), ug.Groups...)
+	for _, lnm := range ug.Each {
+		ly := ss.Net.LayerByName(lnm)
+		if ly == nil {
+			log.Printf("UnitGroups: layer %s not found\n", lnm)
+			continue
+		}
+		_, _, nu := PoolShape(ly)
+		for ui := 0; ui < nu; ui++ {
+			grps = append(grps, UnitGroup{Name: fmt.Sprintf("%s_u%d", lnm, ui), Layer: lnm, Units: []int{ui}})
+		}
+	}
+	for _, g := range grps {
+		ly := ss.Net.LayerByName(g.Layer)
+		if ly == nil {
+			log.Printf("UnitGroups: layer %s of %s not found\n", g.Layer, g.Name)
+			continue
+		}
+		_, _, nu := PoolShape(ly)
+		ok := len(g.Units) > 0
+		for _, ui := range g.Units {
+			if ui >= nu {
+				ok = false
+			}
+		}
+		if !ok {
+			log.Printf("UnitGroups: units %v of %s must be within the %d units per pool of %s\n", g.Units, g.Name, nu, g.Layer)
+			continue
+		}
+		if _, has := ug.Idxs[g.Name]; has {
+			log.Printf("UnitGroups: group %s defined more than once\n", g.Name)
+			continue
+		}
+		ug.All = append(ug.All, g)
+		ug.Idxs[g.Name] = g.UnitIdxs(ly)
+	}
+	ug.Reset()
+}
+
+// UnitGroupNames returns the names of the validated unit groups
+func (ss *Sim) UnitGroupNames() []string {
+	nms := make([]string, len(ss.UnitGroups.All))
+	for i, g := range ss.UnitGroups.All {
+		nms[i] = g.Name
+	}
+	return nms
+}
+
+// UnitGroupTsr returns the values of the units of given group in vt, the
+// values of its layer, as a [pools Y, pools X, 1, units] tensor
+func (ss *Sim) UnitGroupTsr(g *UnitGroup, vt *etensor.Float32) *etensor.Float32 {
+	gt := ss.ValsTsr("UnitGroup_" + g.Name)
+	py, px, _ := PoolShape(ss.Net.LayerByName(g.Layer))
+	if gt.Len() != py*px*len(g.Units) {
+		gt.SetShape([]int{py, px, 1, len(g.Units)}, nil, []string{"PoolY", "PoolX", "1", "Unit"})
+	}
+	for i, ui := range ss.UnitGroups.Idxs[g.Name] {
+		gt.Values[i] = vt.Values[ui]
+	}
+	return gt
+}
+
+// UnitGroupTrial accumulates the mean ActM, and fraction of active units,
+// of each group on the current training trial -- called in LogTrnTrl
+func (ss *Sim) UnitGroupTrial() {
+	ug := &ss.UnitGroups
+	if len(ug.All) == 0 {
+		return
+	}
+	for _, g := range ug.All {
+		vt := ss.ValsTsr(g.Layer)
+		ss.Net.LayerByName(g.Layer).(leabra.LeabraLayer).AsLeabra().UnitValsTensor(vt, "ActM")
+		idxs := ug.Idxs[g.Name]
+		sum, nact := 0.0, 0
+		for _, ui := range idxs {
+			act := vt.Values[ui]
+			sum += float64(act)
+			if act > ug.ActThr {
+				nact++
+			}
+		}
+		ug.Act[g.Name] += sum / float64(len(idxs))
+		ug.Pct[g.Name] += float64(nact) / float64(len(idxs))
+	}
+	ug.N++
+}
+
+// LogUnitGroups adds a row per group of its stats over the epoch just
+// finished to dt, and clears the sums -- called in LogTrnEpc
+func (ss *Sim) LogUnitGroups(dt *etable.Table, epc int) {
+	ug := &ss.UnitGroups
+	if len(ug.All) == 0 || ug.N == 0 {
+		return
+	}
+	for _, g := range ug.All {
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+		dt.SetCellFloat("Epoch", row, float64(epc))
+		dt.SetCellString("Group", row, g.Name)
+		dt.SetCellString("Layer", row, g.Layer)
+		dt.SetCellFloat("NUnits", row, float64(len(ug.Idxs[g.Name])))
+		dt.SetCellFloat("ActM", row, ug.Act[g.Name]/float64(ug.N))
+		dt.SetCellFloat("PctAct", row, ug.Pct[g.Name]/float64(ug.N))
+	}
+	ug.Reset()
+
+	ss.UpdtPlot(ss.UnitGroupPlot)
+}
+
+// UnitGroupARFs returns the number of ARFs of the unit groups
+func (ss *Sim) UnitGroupARFs() int {
+	if !ss.UnitGroups.ARFs {
+		return 0
+	}
+	return len(ss.UnitGroups.All) * (len(ss.RFMaps) + 1)
+}
+
+// AddUnitGroupARFs configures the ARFs of the unit groups -- called in
+// UpdtARFs when configuring the ARFs
+func (ss *Sim) AddUnitGroupARFs() {
+	if !ss.UnitGroups.ARFs {
+		return
+	}
+	for i := range ss.UnitGroups.All {
+		g := &ss.UnitGroups.All[i]
+		vt := ss.ValsTsr(g.Layer)
+		ss.Net.LayerByName(g.Layer).(leabra.LeabraLayer).AsLeabra().UnitValsTensor(vt, "ActM")
+		gt := ss.UnitGroupTsr(g, vt)
+		for nm, mt := range ss.RFMaps {
+			af := ss.ARFs.AddRF(g.Name+"_"+nm, gt, mt)
+			ss.SetAFMetaData(&af.NormRF)
+		}
+		af := ss.ARFs.AddRF(g.Name+"_"+"Out_Position", gt, ss.ValsTsr("Out_Position"))
+		ss.SetAFMetaData(&af.NormRF)
+	}
+}
+
+// AccumUnitGroupARFs accumulates the ARFs of the unit groups for the
+// current trial, from the ActM of their layers -- called in UpdtARFs
+func (ss *Sim) AccumUnitGroupARFs() {
+	if !ss.UnitGroups.ARFs {
+		return
+	}
+	for i := range ss.UnitGroups.All {
+		g := &ss.UnitGroups.All[i]
+		vt := ss.ValsTsr(g.Layer)
+		ss.Net.LayerByName(g.Layer).(leabra.LeabraLayer).AsLeabra().UnitValsTensor(vt, "ActM")
+		gt := ss.UnitGroupTsr(g, vt)
+		for nm, mt := range ss.RFMaps {
+			ss.ARFs.Add(g.Name+"_"+nm, gt, mt, 0.01) // thr prevent weird artifacts
+		}
+		ss.ARFs.Add(g.Name+"_"+"Out_Position", gt, ss.ValsTsr("Out_Position"), 0.01)
+	}
+}
+
+// HighlightUnitGroup masks the values of the units of the layer of the
+// Highlight group that are not in it, in the last record of the NetView,
+// so that they are shown as missing -- called in UpdateView after Record
+func (ss *Sim) HighlightUnitGroup(nv *netview.NetView) {
+	ug := &ss.UnitGroups
+	if ug.Highlight == "" {
+		return
+	}
+	idxs, ok := ug.Idxs[ug.Highlight]
+	if !ok {
+		return
+	}
+	var lnm string
+	for _, g := range ug.All {
+		if g.Name == ug.Highlight {
+			lnm = g.Layer
+		}
+	}
+	nd := &nv.Data
+	ld, ok := nd.LayData[lnm]
+	if !ok || nd.Ring.Len == 0 || len(nd.UnVars) == 0 {
+		return
+	}
+	in := make([]bool, ld.NUnits)
+	for _, ui := range idxs {
+		in[ui] = true
+	}
+	nan := float32(math.NaN())
+	recsz := len(ld.Data) / nd.Ring.Max
+	varsz := recsz / len(nd.UnVars)
+	off := nd.Ring.Idx(nd.Ring.Len-1) * recsz
+	for vi := range nd.UnVars {
+		vo := off + vi*varsz
+		for ui, ing := range in {
+			if !ing {
+				ld.Data[vo+ui] = nan
+			}
+		}
+	}
+}
+
+// SaveUnitGroupLog saves the UnitGroupLog of the run, if any -- called in
+// RunEnd
+func (ss *Sim) SaveUnitGroupLog() {
+	if ss.UnitGroupLog.Rows == 0 {
+		return
+	}
+	fnm := ss.LogFileName(fmt.Sprintf("unitgroup_run%03d", ss.TrainEnv.Run.Cur))
+	ss.UnitGroupLog.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+}
+
+func (ss *Sim) ConfigUnitGroupLog(dt *etable.Table) {
+	dt.SetMetaData("name", "UnitGroupLog")
+	dt.SetMetaData("desc", "Mean ActM and fraction of active units per named unit group, over epochs of training")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Group", etensor.STRING, nil, nil},
+		{"Layer", etensor.STRING, nil, nil},
+		{"NUnits", etensor.INT64, nil, nil},
+		{"ActM", etensor.FLOAT64, nil, nil},
+		{"PctAct", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigUnitGroupPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Unit Group Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.Params.LegendCol = "Group"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NUnits", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("ActM", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PctAct", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
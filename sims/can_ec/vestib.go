@@ -0,0 +1,226 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+	"github.com/goki/mat32"
+)
+
+// GainBlock is one block of training trials during which the Vestibular
+// angular-velocity signal is scaled by Gain
+type GainBlock struct {
+	Epoch  int     `desc:"training epoch at the start of which the block starts"`
+	Trials int     `desc:"number of trials in the block -- 0 = rest of the run"`
+	Gain   float32 `desc:"gain on the angular-velocity signal -- 1 = veridical"`
+}
+
+// VestibGain implements vestibular adaptation experiments: for each GainBlock,
+// the angular-velocity signal in the Vestibular input is scaled by the block's
+// Gain, and the resulting shift of the decoded heading (Orientation layer)
+// relative to true heading is analyzed in the VestibLog, with one row per epoch
+// and block.  On turn trials, ShiftErr is the mean error of the decoded heading
+// in the direction of the turn, and RotGain is the regression slope of the
+// decoded rotation (decoded heading minus previous true heading) on the true
+// rotation: 1 if the network follows the true rotation, and Gain if it
+// follows the scaled vestibular signal.  The gain saturates where the scaled
+// signal reaches the edges of the Vestibular popcode range.
+type VestibGain struct {
+	Blocks []GainBlock `desc:"gain schedule -- if empty, the gain is always 1 and nothing is logged"`
+	File   string      `desc:"protocol file that Blocks were loaded from"`
+
+	Gain  float32          `inactive:"+" desc:"current gain"`
+	Block int              `inactive:"+" desc:"index of current block, -1 = none"`
+	Pats  *etensor.Float32 `view:"-" desc:"scaled Vestibular input pattern"`
+
+	AccBlock   int     `view:"-" desc:"block that the accumulators are for"`
+	AccGain    float32 `view:"-" desc:"gain that the accumulators are for"`
+	N          int     `view:"-" desc:"number of trials accumulated"`
+	NTurn      int     `view:"-" desc:"number of turn trials accumulated"`
+	SumShift   float64 `view:"-" desc:"sum of decoded heading error in the direction of the turn"`
+	SumDecTrue float64 `view:"-" desc:"sum of decoded rotation * true rotation"`
+	SumTrue2   float64 `view:"-" desc:"sum of squared true rotation"`
+}
+
+// Reset resets all state -- called at start of a new run
+func (vg *VestibGain) Reset() {
+	vg.Gain = 1
+	vg.Block = -1
+	vg.ResetAcc()
+}
+
+// ResetAcc resets the accumulators, for the current block and gain
+func (vg *VestibGain) ResetAcc() {
+	vg.AccBlock = vg.Block
+	vg.AccGain = vg.Gain
+	vg.N = 0
+	vg.NTurn = 0
+	vg.SumShift = 0
+	vg.SumDecTrue = 0
+	vg.SumTrue2 = 0
+}
+
+// SetBlock sets the current Block and Gain for given training epoch and trial
+// within the epoch, with ntrls trials per epoch.  Later blocks take precedence.
+func (vg *VestibGain) SetBlock(epc, trl, ntrls int) {
+	vg.Block = CurBlock(len(vg.Blocks), epc, trl, ntrls, func(bi int) (int, int) {
+		return vg.Blocks[bi].Epoch, vg.Blocks[bi].Trials
+	})
+	vg.Gain = 1
+	if vg.Block >= 0 {
+		vg.Gain = vg.Blocks[vg.Block].Gain
+	}
+}
+
+// OpenVestibGain loads the gain schedule from a JSON protocol file with a
+// list of blocks, e.g.: [{"Epoch": 100, "Trials": 2000, "Gain": 2}]
+func (ss *Sim) OpenVestibGain(filename gi.FileName) error {
+	b, err := ioutil.ReadFile(string(filename))
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	var blks []GainBlock
+	if err = json.Unmarshal(b, &blks); err != nil {
+		log.Println(err)
+		return err
+	}
+	ss.VestibGain.Blocks = blks
+	ss.VestibGain.File = string(filename)
+	return nil
+}
+
+// VestibPats returns the input pattern to apply to given layer with given
+// env state: for the Vestibular state, wherever the Inputs map it, the
+// angular-velocity signal scaled by the current gain, and pats unchanged
+// otherwise -- called in ApplyStatePats
+func (ss *Sim) VestibPats(lnm, stnm string, pats etensor.Tensor) etensor.Tensor {
+	vg := &ss.VestibGain
+	if stnm != "Vestibular" || len(vg.Blocks) == 0 {
+		return pats
+	}
+	if vg.Gain == 1 {
+		return pats
+	}
+	ev := &ss.TrainEnv
+	if vg.Pats == nil {
+		vg.Pats = etensor.NewFloat32(pats.Shapes(), nil, nil)
+	}
+	nv := 0.5*(vg.Gain*float32(-ev.RotAng)/float32(ev.AngInc)) + 0.5
+	ev.Encs["Vestibular"].Encode(vg.Pats, mat32.Vec2{nv, 0})
+	return vg.Pats
+}
+
+// VestibGainTrial sets the gain block of the current training trial --
+// called at the start of ApplyInputs
+func (ss *Sim) VestibGainTrial() {
+	vg := &ss.VestibGain
+	if len(vg.Blocks) == 0 {
+		return
+	}
+	ev := &ss.TrainEnv
+	vg.SetBlock(ev.Epoch.Cur, ev.Trial.Cur, ev.Trial.Max)
+}
+
+// VestibTrial accumulates the decoded heading shift for the current trial,
+// logging the prior block's stats if the gain block has changed --
+// called in TrainTrial after AlphaCyc
+func (ss *Sim) VestibTrial() {
+	vg := &ss.VestibGain
+	if len(vg.Blocks) == 0 {
+		return
+	}
+	if vg.Block != vg.AccBlock {
+		ss.LogVestib(ss.VestibLog, ss.TrainEnv.Epoch.Cur)
+		vg.ResetAcc()
+	}
+	ev := &ss.TrainEnv
+	vg.N++
+	if ev.RotAng == 0 {
+		return
+	}
+	dec := float64(ss.DecodeOri())
+	trot := float64(ev.RotAng)
+	drot := AngDiff(dec, float64(ev.PrevAngle))
+	vg.NTurn++
+	vg.SumShift += AngDiff(dec, float64(ev.Angle)) * math.Copysign(1, trot)
+	vg.SumDecTrue += drot * trot
+	vg.SumTrue2 += trot * trot
+}
+
+// LogVestib adds a row for the accumulated stats for given epoch,
+// if any trials were accumulated -- called in VestibTrial and LogTrnEpc
+func (ss *Sim) LogVestib(dt *etable.Table, epc int) {
+	vg := &ss.VestibGain
+	if len(vg.Blocks) == 0 || vg.N == 0 {
+		return
+	}
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(epc))
+	dt.SetCellFloat("Block", row, float64(vg.AccBlock))
+	dt.SetCellFloat("Gain", row, float64(vg.AccGain))
+	dt.SetCellFloat("N", row, float64(vg.N))
+	dt.SetCellFloat("NTurn", row, float64(vg.NTurn))
+	if vg.NTurn > 0 {
+		dt.SetCellFloat("ShiftErr", row, vg.SumShift/float64(vg.NTurn))
+		dt.SetCellFloat("RotGain", row, vg.SumDecTrue/vg.SumTrue2)
+	}
+	ss.SQLWriteRow("vestib", dt, row)
+
+	ss.UpdtPlot(ss.VestibPlot)
+}
+
+// LogVestibEpc logs the stats for the last epoch and resets the accumulators --
+// called in LogTrnEpc
+func (ss *Sim) LogVestibEpc() {
+	ss.LogVestib(ss.VestibLog, ss.TrainEnv.Epoch.Prv)
+	ss.VestibGain.ResetAcc()
+}
+
+func (ss *Sim) ConfigVestibLog(dt *etable.Table) {
+	dt.SetMetaData("name", "VestibLog")
+	dt.SetMetaData("desc", "Decoded heading shift under vestibular gain manipulation, per epoch and gain block")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Block", etensor.INT64, nil, nil},
+		{"Gain", etensor.FLOAT64, nil, nil},
+		{"N", etensor.INT64, nil, nil},
+		{"NTurn", etensor.INT64, nil, nil},
+		{"ShiftErr", etensor.FLOAT64, nil, nil},
+		{"RotGain", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigVestibPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CAN_EC Vestibular Gain Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Block", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Gain", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("N", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NTurn", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("ShiftErr", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("RotGain", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	return plt
+}
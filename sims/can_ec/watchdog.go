@@ -0,0 +1,134 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+// Watchdog checks layer activity and weights for NaN / Inf values or runaway Ge
+// at the end of each training epoch.  On detection it stops training, reports
+// the unstable layers and prjns, and saves a diagnostic dump of the weights,
+// last trial inputs and params to a directory.
+type Watchdog struct {
+	On      bool    `def:"true" desc:"check for instability each epoch"`
+	MaxGe   float32 `def:"5" desc:"maximum Ge of any unit in a layer before it is considered runaway"`
+	Tripped bool    `inactive:"+" desc:"instability was detected, and training was stopped"`
+	Report  string  `inactive:"+" desc:"report of the unstable layers and prjns"`
+	DumpDir string  `inactive:"+" desc:"directory that the diagnostic dump was saved to"`
+}
+
+func (wd *Watchdog) Defaults() {
+	wd.On = true
+	wd.MaxGe = 5
+}
+
+// IsBad returns true if the value is NaN or Inf
+func IsBad(v float32) bool {
+	return math.IsNaN(float64(v)) || math.IsInf(float64(v), 0)
+}
+
+// WatchdogCheck checks for instability, and returns a list of problems, empty if none
+func (ss *Sim) WatchdogCheck() []string {
+	var probs []string
+	for _, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		if ly.IsOff() {
+			continue
+		}
+		nbad := 0
+		var maxGe float32
+		for ni := range ly.Neurons {
+			nrn := &ly.Neurons[ni]
+			if IsBad(nrn.Act) || IsBad(nrn.Ge) || IsBad(nrn.Vm) || IsBad(nrn.ActM) || IsBad(nrn.ActP) {
+				nbad++
+				continue
+			}
+			if nrn.Ge > maxGe {
+				maxGe = nrn.Ge
+			}
+		}
+		if nbad > 0 {
+			probs = append(probs, fmt.Sprintf("layer %s: %d units with NaN / Inf activity", ly.Name(), nbad))
+		}
+		if maxGe > ss.Watchdog.MaxGe {
+			probs = append(probs, fmt.Sprintf("layer %s: runaway Ge: max %g > %g", ly.Name(), maxGe, ss.Watchdog.MaxGe))
+		}
+		for _, pji := range ly.RcvPrjns {
+			pj := pji.(leabra.LeabraPrjn).AsLeabra()
+			nbad := 0
+			for si := range pj.Syns {
+				sy := &pj.Syns[si]
+				if IsBad(sy.Wt) || IsBad(sy.LWt) || IsBad(sy.DWt) {
+					nbad++
+				}
+			}
+			if nbad > 0 {
+				probs = append(probs, fmt.Sprintf("prjn %s: %d synapses with NaN / Inf weights", pj.Name(), nbad))
+			}
+		}
+	}
+	return probs
+}
+
+// WatchdogEpoch runs the Watchdog check at the end of given training epoch,
+// and stops training and saves a dump if instability is found -- called in LogTrnEpc
+func (ss *Sim) WatchdogEpoch(epc int) {
+	wd := &ss.Watchdog
+	if !wd.On || wd.Tripped {
+		return
+	}
+	probs := ss.WatchdogCheck()
+	if len(probs) == 0 {
+		return
+	}
+	wd.Tripped = true
+	wd.Report = fmt.Sprintf("run %d epoch %d: %s", ss.TrainEnv.Run.Cur, epc, strings.Join(probs, "; "))
+	fmt.Printf("Watchdog: instability detected, stopping training:\n\t%s\n", strings.Join(probs, "\n\t"))
+	ss.StopNow = true
+	ss.WatchdogDump(epc)
+}
+
+// WatchdogDump saves the weights, last trial inputs and targets, params and the
+// Watchdog report to a dump directory for given epoch
+func (ss *Sim) WatchdogDump(epc int) {
+	wd := &ss.Watchdog
+	wd.DumpDir = ss.Net.Nm + "_" + ss.RunName() + "_dump_" + ss.RunEpochName(ss.TrainEnv.Run.Cur, epc)
+	if err := os.MkdirAll(wd.DumpDir, 0755); err != nil {
+		log.Println(err)
+		return
+	}
+	dfn := func(fnm string) string {
+		return filepath.Join(wd.DumpDir, fnm)
+	}
+	ioutil.WriteFile(dfn("report.txt"), []byte(wd.Report+"\n"), 0644)
+	if err := ss.SaveWtsMeta(dfn("weights.wts.gz")); err != nil {
+		log.Println(err)
+	}
+	ss.Params.SaveJSON(gi.FileName(dfn("params.json")))
+	ioutil.WriteFile(dfn("params_all.txt"), []byte(ss.Net.AllParams()), 0644)
+	tsr := &etensor.Float32{}
+	for _, lnm := range ss.InputLays {
+		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+		ly.UnitValsTensor(tsr, "Ext")
+		etensor.SaveCSV(tsr, gi.FileName(dfn(lnm+"_Ext.tsv")), '\t')
+	}
+	for _, lnm := range ss.TargetLays {
+		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+		ly.UnitValsTensor(tsr, "Targ")
+		etensor.SaveCSV(tsr, gi.FileName(dfn(lnm+"_Targ.tsv")), '\t')
+	}
+	fmt.Printf("Watchdog: diagnostic dump saved to: %s\n", wd.DumpDir)
+}
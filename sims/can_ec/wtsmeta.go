@@ -0,0 +1,199 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goki/gi/gi"
+)
+
+// WtsMetaCheck are the weights file metadata keys that must match the
+// current sim when weights are loaded -- the others are informational
+var WtsMetaCheck = []string{"Network", "Seed", "World", "Params"}
+
+// HashString returns a short hex hash of s, for provenance metadata
+func HashString(s string) string {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// WorldHash returns a hash of the world grid of the TrainEnv
+func (ss *Sim) WorldHash() string {
+	var b strings.Builder
+	if wd := ss.TrainEnv.World; wd != nil {
+		for _, v := range wd.Values {
+			b.WriteString(strconv.Itoa(v))
+			b.WriteByte(',')
+		}
+	}
+	return HashString(b.String())
+}
+
+// WtsMeta returns the provenance metadata embedded in saved weights files:
+// the random seed, run and epoch, TrainEnv world and pattern bank hashes, and
+// a hash of all the params of the network, along with the ParamSet and Tag
+func (ss *Sim) WtsMeta() map[string]string {
+	return map[string]string{
+		"Network":  ss.Net.Nm,
+		"Seed":     strconv.FormatInt(ss.RndSeed, 10),
+		"Run":      strconv.Itoa(ss.TrainEnv.Run.Cur),
+		"Epoch":    strconv.Itoa(ss.TrainEnv.Epoch.Cur),
+		"World":    ss.WorldHash(),
+		"Preset":   ss.TrainEnv.Preset.String(),
+		"Pats":     ss.TrainEnv.PatsHash(),
+		"Params":   HashString(ss.Net.AllParams()),
+		"ParamSet": ss.ParamSet,
+		"Tag":      ss.Tag,
+	}
+}
+
+// AddWtsMeta inserts the given metadata into weights JSON data, after the
+// Network name, where the weights reader expects the MetaData
+func AddWtsMeta(data []byte, md map[string]string) ([]byte, error) {
+	ni := bytes.Index(data, []byte(`"Network":`))
+	if ni < 0 {
+		return nil, fmt.Errorf("AddWtsMeta: no Network in weights data")
+	}
+	ei := bytes.IndexByte(data[ni:], '\n')
+	if ei < 0 {
+		return nil, fmt.Errorf("AddWtsMeta: no Network line in weights data")
+	}
+	mj, err := json.Marshal(md)
+	if err != nil {
+		return nil, err
+	}
+	ei += ni + 1
+	var b bytes.Buffer
+	b.Write(data[:ei])
+	b.WriteString("\t\"MetaData\": ")
+	b.Write(mj)
+	b.WriteString(",\n")
+	b.Write(data[ei:])
+	return b.Bytes(), nil
+}
+
+// SaveWtsMeta saves the network weights to given file, gzipped if it ends in
+// .gz, with the WtsMeta provenance metadata embedded in the JSON header
+func (ss *Sim) SaveWtsMeta(fnm string) error {
+	var buf bytes.Buffer
+	if err := ss.Net.WriteWtsJSON(&buf); err != nil {
+		return err
+	}
+	data, err := AddWtsMeta(buf.Bytes(), ss.WtsMeta())
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(fnm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if !strings.HasSuffix(fnm, ".gz") {
+		_, err = f.Write(data)
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ReadWtsMeta returns the metadata of the weights file, nil if it has none,
+// reading only its header
+func ReadWtsMeta(fnm string) (map[string]string, error) {
+	f, err := os.Open(fnm)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(fnm, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil { // {
+		return nil, err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case "MetaData":
+			var md map[string]string
+			err := dec.Decode(&md)
+			return md, err
+		case "Layers":
+			return nil, nil
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// CheckWtsMeta compares the weights file metadata md with the current sim,
+// returning an error listing the WtsMetaCheck keys that do not match
+func (ss *Sim) CheckWtsMeta(md map[string]string) error {
+	if md == nil {
+		return fmt.Errorf("no provenance metadata")
+	}
+	cur := ss.WtsMeta()
+	var diffs []string
+	for _, ky := range WtsMetaCheck {
+		if md[ky] != cur[ky] {
+			diffs = append(diffs, fmt.Sprintf("%s: %q != current %q", ky, md[ky], cur[ky]))
+		}
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+	sort.Strings(diffs)
+	return fmt.Errorf("provenance mismatch: %s", strings.Join(diffs, ", "))
+}
+
+// OpenWts loads the network weights from given file, reporting its
+// provenance metadata, with a warning if it does not match the current sim
+func (ss *Sim) OpenWts(fnm string) error {
+	md, err := ReadWtsMeta(fnm)
+	if err != nil {
+		return err
+	}
+	if md != nil {
+		fmt.Printf("Weights %s: run: %s  epoch: %s  seed: %s  params: %s  tag: %s\n", fnm, md["Run"], md["Epoch"], md["Seed"], md["ParamSet"], md["Tag"])
+	}
+	if err := ss.CheckWtsMeta(md); err != nil {
+		log.Printf("WARNING: weights %s: %v\n", fnm, err)
+	}
+	return ss.Net.OpenWtsJSON(gi.FileName(fnm))
+}
+
+// OpenWeights loads the network weights from file, checking their provenance
+func (ss *Sim) OpenWeights(filename gi.FileName) {
+	if err := ss.OpenWts(string(filename)); err != nil {
+		log.Println(err)
+	}
+}
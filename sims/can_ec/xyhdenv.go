@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"math/rand"
 	"os"
 
@@ -31,6 +32,12 @@ type XYHDEnv struct {
 	Dsc         string                      `desc:"description of this environment"`
 	Disp        bool                        `desc:"update display -- turn off to make it faster"`
 	Size        evec.Vec2i                  `desc:"size of 2D world"`
+	Preset      WorldPresets                `desc:"built-in world layout generated by GenWorld -- set prior to Config, or use SetPreset"`
+	TrackW      int                         `desc:"width of corridors in track and maze presets, in grid cells"`
+	Start       evec.Vec2i                  `desc:"starting location of agent at Init, set by GenWorld according to Preset"`
+	StartAngle  int                         `desc:"starting head direction of agent at Init, in degrees, set by GenWorld according to Preset"`
+	Goals       []evec.Vec2i                `desc:"goal locations for the Preset, e.g., ends of track or maze arms"`
+	ChoicePt    evec.Vec2i                  `desc:"location of the choice point for TMaze and Figure8 presets, at the top of the stem"`
 	PatSize     evec.Vec2i                  `desc:"size of patterns for mats, acts"`
 	PosSize     evec.Vec2i                  `desc:"size of patterns for xy coordinates"`
 	World       *etensor.Int                `view:"no-inline" desc:"2D grid world, each cell is a material (mat)"`
@@ -38,17 +45,25 @@ type XYHDEnv struct {
 	MatMap      map[string]int              `desc:"map of material name to index stored in world cell"`
 	BarrierIdx  int                         `desc:"index of material below which (inclusive) cannot move -- e.g., 1 for wall"`
 	Pats        map[string]*etensor.Float32 `desc:"patterns for each material (must include Empty) and for each action"`
+	PatBank     PatBankInfo                 `inactive:"+" desc:"version and hash identity of the bank of Pats, as last opened or saved"`
 	Acts        []string                    `desc:"list of actions: starts with: Left, Right, Forward"`
 	ActMap      map[string]int              `desc:"action map of action names to indexes"`
 	Params      map[string]float32          `desc:"map of optional interoceptive and world-dynamic parameters -- cleaner to store in a map"`
-	AngInc      int                         `desc:"angle increment for rotation, in degrees -- defaults to 90"`
+	AngInc      int                         `desc:"angle increment for rotation, in degrees -- must evenly divide 360 -- defaults to 90 -- determines NRotAngles and RingSize"`
 	NRotAngles  int                         `inactive:"+" desc:"total number of rotation angles in a circle"`
 	TraceActGen bool                        `desc:"for debugging, print out a trace of the action generation logic"`
-	RingSize    int                         `inactive:"+" desc:"number of units in ring population codes"`
+	RingSize    int                         `inactive:"+" desc:"number of units in ring population codes -- at least 16, and at least one per AngInc"`
 	VesSize     int                         `inactive:"+" desc:"number of units in population codes"`
+	MotorNoise  MotorNoise                  `view:"inline" desc:"noise in executing action commands -- executed action can differ from commanded"`
+	Stuck       StuckParams                 `view:"inline" desc:"detection of stuck episodes, and unstick behavior of ActGen"`
+	Scan        ScanParams                  `view:"inline" desc:"pause-and-scan behaviors of the reflexive policy (ReflexAct)"`
+	ProxRange   int                         `desc:"number of grid cells sensed by proximity (whisker) input in each direction (front, right, left, back) -- 1 = contact only"`
 	PopCode     popcode.OneD                `desc:"population code values, in normalized units"`
 	PopCode2d   popcode.TwoD                `desc:"2d population code values, in normalized units"`
 	AngCode     popcode.Ring                `desc:"angle population code values, in normalized units"`
+	StateEnc    map[string]EncoderTypes     `desc:"type of encoder to use for rendering each state (Angle, Vestibular, Position, and their Prev versions) -- states not listed use EncGaussBump, and Prev states default to the same as their current state -- note: decoding stats in the sim assume EncGaussBump for Position and Angle"`
+	GridMix     GridMixEnc                  `view:"inline" desc:"parameters for EncGridMix encoders"`
+	Encs        map[string]Encoder          `view:"-" desc:"encoder for each state, configured from StateEnc"`
 
 	// current state below (params above)
 	PrevPosF      mat32.Vec2                  `inactive:"+" desc:"current location of agent, floating point"`
@@ -59,8 +74,11 @@ type XYHDEnv struct {
 	Angle         int                         `inactive:"+" desc:"current angle, in degrees"`
 	RotAng        int                         `inactive:"+" desc:"angle that we just rotated -- drives vestibular"`
 	Act           int                         `inactive:"+" desc:"last action taken"`
+	ActCmd        Action                      `inactive:"+" desc:"last action commanded, including any continuous parameters"`
+	ActExec       Action                      `inactive:"+" desc:"last action actually executed, after MotorNoise"`
 	ProxMats      []int                       `desc:"material at each right angle: front, right, left, back"`
 	ProxPos       []evec.Vec2i                `desc:"coordinates for proximal grid points: front, right, left, back"`
+	ProxDist      []int                       `desc:"distance to nearest barrier in each direction: front, right, left, back -- ProxRange+1 if none within range"`
 	CurStates     map[string]*etensor.Float32 `desc:"current rendered state tensors -- extensible map"`
 	NextStates    map[string]*etensor.Float32 `desc:"next rendered state tensors -- updated from actions"`
 	RefreshEvents map[int]*WEvent             `desc:"list of events, key is tick step, to check each step to drive refresh of consumables -- removed from this active list when complete"`
@@ -72,6 +90,24 @@ type XYHDEnv struct {
 	Event         env.Ctr                     `view:"arbitrary counter for steps within a scene -- resets at consumption event"`
 	Scene         env.Ctr                     `view:"arbitrary counter incrementing over a coherent sequence of events: e.g., approaching food -- increments at consumption"`
 	Episode       env.Ctr                     `view:"arbitrary counter incrementing over scenes within larger episode: feeding, drinking, exploring, etc"`
+	Lap           env.Ctr                     `view:"inline" desc:"number of laps / journeys completed, for track and maze presets"`
+	LastGoal      int                         `inactive:"+" desc:"index of last goal reached, -1 if none"`
+	PassedStart   bool                        `inactive:"+" desc:"agent has passed through the Start location since reaching the last goal"`
+	Journey       string                      `inactive:"+" desc:"name of the last completed journey, e.g., LtoR, RtoL on a LinearTrack, Left or Right choice on a TMaze"`
+	LapDone       bool                        `inactive:"+" desc:"a lap was completed on the current step"`
+	GoalIdx       int                         `inactive:"+" desc:"index of the current goal in Goals, -1 if no Goals (random goal locations)"`
+	GoalPos       evec.Vec2i                  `inactive:"+" desc:"location of the current goal"`
+	GoalDir       float32                     `inactive:"+" desc:"egocentric direction to the current goal, in degrees relative to the head direction, positive = counter-clockwise (Left)"`
+	GoalDist      float32                     `inactive:"+" desc:"distance to the current goal, in grid cells"`
+	GoalsHit      int                         `inactive:"+" desc:"number of goals reached since Init"`
+	Collided      bool                        `inactive:"+" desc:"last action was blocked by a barrier"`
+	IsStuck       bool                        `inactive:"+" desc:"agent is stuck: it has stayed within Stuck.Dist of StuckAnchor for Stuck.Steps steps"`
+	StillSteps    int                         `inactive:"+" desc:"number of steps within Stuck.Dist of StuckAnchor"`
+	StuckAnchor   evec.Vec2i                  `inactive:"+" desc:"location of the last displacement, for stuck detection"`
+	StuckCnt      StuckCounts                 `inactive:"+" view:"inline" desc:"counts of steps, collisions and stuck episodes since Init"`
+	Bout          string                      `inactive:"+" desc:"scan or pause bout in progress, if any: Scan or Pause"`
+	BoutActs      []Action                    `view:"-" desc:"remaining in-place actions of the bout in progress"`
+	ScanCnt       ScanCounts                  `inactive:"+" view:"inline" desc:"counts of scan and pause bouts and their steps since Init"`
 }
 
 var KiT_XYHDEnv = kit.Types.AddType(&XYHDEnv{}, XYHDEnvProps)
@@ -85,24 +121,46 @@ func (ev *XYHDEnv) Config(ntrls int) {
 	ev.Dsc = "Example world with xy coordinate system and head direction"
 	ev.Mats = []string{"Empty", "Wall"}
 	ev.BarrierIdx = 1
-	ev.Acts = []string{"Left", "Right", "Forward"}
+	ev.Acts = ActionNames()
 	ev.Params = make(map[string]float32)
 
 	ev.Disp = false
-	ev.Size.Set(50, 50) // if changing to non-square, reset the popcode2d min
+	if ev.Size.X == 0 { // allow user override
+		ev.Size.Set(50, 50)
+	}
 	ev.PatSize.Set(5, 5)
 	ev.PosSize.Set(12, 12)
-	ev.AngInc = 90
-	ev.RingSize = 16 // was 16
-	ev.VesSize = 12  // was 12
+	if ev.AngInc == 0 { // allow user override
+		ev.AngInc = 90
+	}
+	if err := ev.ValidateAngInc(); err != nil {
+		log.Println(err)
+		ev.AngInc = 90
+	}
+	ev.RingSize = ints.MaxInt(16, 360/ev.AngInc)
+	ev.VesSize = 12 // was 12
+	ev.ProxRange = 4
+	ev.TrackW = 3
+	ev.MotorNoise.Defaults()
+	if ev.Scan.PauseSteps == 0 { // allow user override
+		ev.Scan.Defaults()
+	}
 	ev.PopCode.Defaults()
 	ev.PopCode.SetRange(-0.2, 1.2, 0.1)
 	ev.PopCode2d.Defaults()
-	ev.PopCode2d.SetRange(1/(float32(ev.Size.X)-2), 1, 0.1) // assume it's a square, 2 is length of walls
+	ev.PopCode2d.SetRange(1/(float32(ev.Size.X)-2), 1, 0.1) // 2 is length of walls
+	ev.PopCode2d.Min.Set(1/(float32(ev.Size.X)-2), 1/(float32(ev.Size.Y)-2))
 	//ev.PopCode2d.SetRange(0, 1, 0.1) // assume it's a square, 2 is length of walls
 	ev.AngCode.Defaults()
 	ev.AngCode.SetRange(0, 1, 0.1) // zycyc experiment
 
+	if ev.StateEnc == nil { // allow user override
+		ev.StateEnc = make(map[string]EncoderTypes)
+	}
+	if ev.GridMix.NScales == 0 { // allow user override
+		ev.GridMix.Defaults()
+	}
+
 	// debugging options:
 	ev.TraceActGen = false
 
@@ -142,6 +200,7 @@ func (ev *XYHDEnv) ConfigImpl() {
 
 	ev.ProxMats = make([]int, 4)
 	ev.ProxPos = make([]evec.Vec2i, 4)
+	ev.ProxDist = make([]int, 4)
 
 	ev.CurStates = make(map[string]*etensor.Float32)
 	ev.NextStates = make(map[string]*etensor.Float32)
@@ -150,6 +209,10 @@ func (ev *XYHDEnv) ConfigImpl() {
 	ps.SetShape([]int{1, 4, 2, 1}, nil, []string{"1", "Pos", "OnOff", "1"})
 	ev.NextStates["ProxSoma"] = ps
 
+	pw := &etensor.Float32{}
+	pw.SetShape([]int{1, 4, ev.ProxRange, 1}, nil, []string{"1", "Pos", "Dist", "1"})
+	ev.NextStates["ProxWhisker"] = pw
+
 	ag := &etensor.Float32{}
 	ag.SetShape([]int{1, ev.RingSize}, nil, []string{"1", "Pop"})
 	ev.NextStates["Angle"] = ag
@@ -174,7 +237,16 @@ func (ev *XYHDEnv) ConfigImpl() {
 	av.SetShape([]int{ev.PatSize.Y, ev.PatSize.X}, nil, []string{"Y", "X"})
 	ev.NextStates["Action"] = av
 
+	gd := &etensor.Float32{}
+	gd.SetShape([]int{1, ev.RingSize}, nil, []string{"1", "Pop"})
+	ev.NextStates["GoalDir"] = gd
+
+	gds := &etensor.Float32{}
+	gds.SetShape([]int{1, ev.VesSize}, nil, []string{"1", "Pop"})
+	ev.NextStates["GoalDist"] = gds
+
 	ev.CopyNextToCur() // get CurStates from NextStates
+	ev.ConfigEncs()
 
 	ev.MatMap = make(map[string]int, len(ev.Mats))
 	for i, m := range ev.Mats {
@@ -194,15 +266,104 @@ func (ev *XYHDEnv) ConfigImpl() {
 	ev.Episode.Scale = env.Episode
 }
 
+// ConfigEncs configures the Encs encoder for each state from StateEnc
+func (ev *XYHDEnv) ConfigEncs() {
+	ev.Encs = make(map[string]Encoder)
+	for _, st := range []string{"Angle", "Vestibular", "Position"} {
+		et := ev.StateEnc[st]
+		ev.Encs[st] = ev.NewEncoder(st, et)
+		pet, ok := ev.StateEnc["Prev"+st]
+		if !ok {
+			pet = et
+		}
+		if _, has := ev.NextStates["Prev"+st]; has {
+			ev.Encs["Prev"+st] = ev.NewEncoder(st, pet)
+		}
+	}
+	ev.Encs["GoalDir"] = ev.NewEncoder("Angle", ev.StateEnc["GoalDir"])
+	ev.Encs["GoalDist"] = ev.NewEncoder("GoalDist", ev.StateEnc["GoalDist"])
+}
+
+// NewEncoder returns a new encoder of given type for given (non-Prev) state name,
+// using the range of the state's popcode
+func (ev *XYHDEnv) NewEncoder(st string, et EncoderTypes) Encoder {
+	var pe PopCodeEnc
+	var rng EncRange
+	switch st {
+	case "Angle":
+		pe.Ring = &ev.AngCode
+		rng = EncRange{Min: mat32.Vec2{ev.AngCode.Min, 0}, Max: mat32.Vec2{ev.AngCode.Max, 1}, Wrap: true}
+	case "Position":
+		pe.TwoD = &ev.PopCode2d
+		rng = EncRange{Min: ev.PopCode2d.Min, Max: ev.PopCode2d.Max}
+	default:
+		pe.OneD = &ev.PopCode
+		rng = EncRange{Min: mat32.Vec2{ev.PopCode.Min, 0}, Max: mat32.Vec2{ev.PopCode.Max, 1}}
+	}
+	switch et {
+	case EncOneHot:
+		return &OneHotEnc{EncRange: rng}
+	case EncBinary:
+		return &BinaryEnc{EncRange: rng}
+	case EncGridMix:
+		ge := ev.GridMix
+		ge.EncRange = rng
+		return &ge
+	}
+	return &pe
+}
+
+// SetStateEnc sets the encoder type for given state, and reconfigures the encoders
+func (ev *XYHDEnv) SetStateEnc(st string, et EncoderTypes) {
+	if ev.StateEnc == nil {
+		ev.StateEnc = make(map[string]EncoderTypes)
+	}
+	ev.StateEnc[st] = et
+	ev.ConfigEncs()
+}
+
 func (ev *XYHDEnv) Validate() error {
 	if ev.Size.IsNil() {
 		return fmt.Errorf("XYHDEnv: %v has size == 0 -- need to Config", ev.Nm)
 	}
+	return ev.ValidateAngInc()
+}
+
+// ValidateAngInc returns an error if AngInc is not a positive divisor of 360
+func (ev *XYHDEnv) ValidateAngInc() error {
+	if ev.AngInc <= 0 || 360%ev.AngInc != 0 {
+		return fmt.Errorf("XYHDEnv: %v AngInc: %d must be a positive divisor of 360", ev.Nm, ev.AngInc)
+	}
 	return nil
 }
 
+// AngIdx returns the index of given angle among the NRotAngles angles,
+// rounding to the nearest AngInc
+func (ev *XYHDEnv) AngIdx(ang int) int {
+	ai := int(mat32.Round(float32(AngMod(ang)) / float32(ev.AngInc)))
+	return ints.MinInt(ints.MaxInt(ai, 0), ev.NRotAngles-1)
+}
+
+// RotIdx returns the index of given rotation among the 3 rotations:
+// -AngInc, 0, +AngInc, rounding to the nearest AngInc
+func (ev *XYHDEnv) RotIdx(rot int) int {
+	ri := 1 + int(mat32.Round(float32(rot)/float32(ev.AngInc)))
+	return ints.MinInt(ints.MaxInt(ri, 0), 2)
+}
+
 func (ev *XYHDEnv) State(element string) etensor.Tensor {
-	return ev.CurStates[element]
+	cs, ok := ev.CurStates[element]
+	if !ok {
+		return nil // not a nil *Float32, which is a non-nil Tensor
+	}
+	return cs
+}
+
+// HasState returns true if the env has a state of given name, available
+// from Config on, before the first Step
+func (ev *XYHDEnv) HasState(element string) bool {
+	_, ok := ev.NextStates[element]
+	return ok
 }
 
 // String returns the current state as a string
@@ -229,19 +390,42 @@ func (ev *XYHDEnv) Init(run int) {
 	ev.Tick.Cur = -1
 	ev.Event.Cur = -1
 
-	ev.PosI = ev.Size.DivScalar(2) // start in middle -- could be random..
-	ev.PosF = ev.PosI.ToVec2()
+	ev.ResetPos() // Start is set by GenWorld according to Preset -- middle for OpenField
 	for i := 0; i < 4; i++ {
 		ev.ProxMats[i] = 0
 	}
 
-	ev.Angle = 0
-	ev.RotAng = 0
+	ev.InitLaps()
+	ev.InitGoal()
+	ev.InitStuck()
+	ev.InitScan()
 
 	ev.RefreshEvents = make(map[int]*WEvent)
 	ev.AllEvents = make(map[int]*WEvent)
 }
 
+// MoveToStart moves the agent to the Start location and heading, e.g., after
+// the world changed within a run, restarting the lap, goal and stuck tracking
+func (ev *XYHDEnv) MoveToStart() {
+	ev.ResetPos()
+	ev.InitLaps()
+	ev.InitGoal()
+	ev.InitStuck()
+	ev.EndBout()
+}
+
+// ResetPos sets the current and previous position and heading to the Start
+// location and heading, so the Prev states do not carry over a location from
+// before the reset (e.g., in another world)
+func (ev *XYHDEnv) ResetPos() {
+	ev.PosI = ev.Start
+	ev.PosF = ev.PosI.ToVec2()
+	ev.PrevPosF, ev.PrevPosI = ev.PosF, ev.PosI
+	ev.Angle = ev.StartAngle
+	ev.PrevAngle = ev.Angle
+	ev.RotAng = 0
+}
+
 // SetWorld sets given mat at given point coord in world
 func (ev *XYHDEnv) SetWorld(p evec.Vec2i, mat int) {
 	ev.World.Set([]int{p.Y, p.X}, mat)
@@ -252,6 +436,13 @@ func (ev *XYHDEnv) GetWorld(p evec.Vec2i) int {
 	return ev.World.Value([]int{p.Y, p.X})
 }
 
+// InArena returns true if given point is inside the arena: not a barrier
+// (e.g., outside a circular or L-shaped arena), and so can be occupied
+func (ev *XYHDEnv) InArena(p evec.Vec2i) bool {
+	mat := ev.GetWorld(p)
+	return mat == 0 || mat > ev.BarrierIdx
+}
+
 ////////////////////////////////////////////////////////////////////
 // I/O
 
@@ -322,20 +513,16 @@ func (ev *XYHDEnv) SavePats(filename gi.FileName) error {
 	return ioutil.WriteFile(string(filename), jenc, 0644)
 }
 
-// OpenPats opens the patterns
+// OpenPats opens the patterns, from a versioned pattern bank saved by
+// SavePatBank, or a plain pattern file saved by SavePats
 func (ev *XYHDEnv) OpenPats(filename gi.FileName) error {
-	fp, err := os.Open(string(filename))
+	pi, err := ReadPatBank(string(filename), ev.Pats)
 	if err != nil {
 		fmt.Println("Error opening file:", err)
 		return err
 	}
-	defer fp.Close()
-	b, err := ioutil.ReadAll(fp)
-	err = json.Unmarshal(b, &ev.Pats)
-	if err != nil {
-		fmt.Println(err)
-	}
-	return err
+	ev.PatBank = pi
+	return nil
 }
 
 // AngMod returns angle modulo within 360 degrees
@@ -387,9 +574,28 @@ func (ev *XYHDEnv) ScanProx() {
 		_, gp := NextVecPoint(ev.PosF, v)
 		ev.ProxMats[i] = ev.GetWorld(gp)
 		ev.ProxPos[i] = gp
+		ev.ProxDist[i] = ev.ScanDist(v)
 	}
 }
 
+// ScanDist returns the distance in grid steps along vector v to the nearest
+// barrier, up to ProxRange -- returns ProxRange+1 if none found
+func (ev *XYHDEnv) ScanDist(v mat32.Vec2) int {
+	pf := ev.PosF
+	for d := 1; d <= ev.ProxRange; d++ {
+		var gp evec.Vec2i
+		pf, gp = NextVecPoint(pf, v)
+		if gp.X < 0 || gp.Y < 0 || gp.X >= ev.Size.X || gp.Y >= ev.Size.Y {
+			return d
+		}
+		mat := ev.GetWorld(gp)
+		if mat > 0 && mat <= ev.BarrierIdx {
+			return d
+		}
+	}
+	return ev.ProxRange + 1
+}
+
 ////////////////////////////////////////////////////////////////////
 // Actions
 
@@ -416,38 +622,52 @@ func (ev *XYHDEnv) AddNewEventRefresh(wev *WEvent) {
 	ev.AllEvents[wev.Tick] = wev
 }
 
-// TakeAct takes the action, updates state
+// TakeAct takes the discrete action of given index, updates state
 func (ev *XYHDEnv) TakeAct(act int) {
-	//as := ""
-	//if act >= len(ev.Acts) || act < 0 {
-	//	as = "Stay"
-	//} else {
-	//	as = ev.Acts[act]
-	//}
+	ev.DoAction(NewAction(Actions(act)))
+}
 
-	as := ev.Acts[act]
-	ev.RotAng = 0
+// DoAction executes the given action command, using env defaults
+// for any continuous parameters not specified, and updates state.
+// MotorNoise is applied to get the executed action (ActExec).
+func (ev *XYHDEnv) DoAction(cmd Action) {
+	ev.ActCmd = cmd
+	ev.ExecAct(ev.MotorNoise.Apply(cmd, float32(ev.AngInc)))
+}
 
-	nmat := len(ev.Mats)
-	frmat := ints.MinInt(ev.ProxMats[0], nmat)
-	//behmat := ev.ProxMats[3] // behind
+// ExecAct executes the given action exactly, without MotorNoise, as the
+// executed action (ActExec), and updates state -- used by DoAction, and to
+// replay the executed actions of a saved Trajectory
+func (ev *XYHDEnv) ExecAct(ac Action) {
+	ev.ActExec = ac
+	ev.Act = int(ac.Act)
+	turn := ev.AngInc
+	if ac.Turn != 0 {
+		turn = int(mat32.Round(ac.Turn))
+	}
+	step := float32(1)
+	if ac.Step == NoStep {
+		step = 0 // in place
+	} else if ac.Step != 0 {
+		step = ac.Step
+	}
+	ev.RotAng = 0
 
 	ev.PrevPosF, ev.PrevPosI = ev.PosF, ev.PosI
 	ev.PrevAngle = ev.Angle
-	switch as {
-	//case "Stay":
-	case "Left":
-		ev.RotAng = ev.AngInc
+	collided := false
+	switch ac.Act {
+	case Left:
+		ev.RotAng = turn
 		ev.Angle = AngMod(ev.Angle + ev.RotAng)
-		ev.PosF, ev.PosI = NextVecPoint(ev.PosF, AngVec(ev.Angle)) // when L/R contains forward
-	case "Right":
-		ev.RotAng = -ev.AngInc
+		ev.TurnStep(step)
+	case Right:
+		ev.RotAng = -turn
 		ev.Angle = AngMod(ev.Angle + ev.RotAng)
-		ev.PosF, ev.PosI = NextVecPoint(ev.PosF, AngVec(ev.Angle)) // when L/R contains forward
-	case "Forward":
-		if frmat > 0 && frmat <= ev.BarrierIdx {
-		} else {
-			ev.PosF, ev.PosI = NextVecPoint(ev.PosF, AngVec(ev.Angle))
+		ev.TurnStep(step)
+	case Forward:
+		if step > 0 { // else paused in place
+			collided = ev.MoveStep(step)
 		}
 		//case "Backward":
 		//	if behmat > 0 && behmat <= ev.BarrierIdx {
@@ -456,10 +676,46 @@ func (ev *XYHDEnv) TakeAct(act int) {
 		//	}
 	}
 	ev.ScanProx()
+	ev.UpdateLaps()
+	ev.UpdateGoal()
+	ev.UpdateStuck(collided)
 
 	ev.RenderState()
 }
 
+// TurnStep steps forward by step after a Left / Right turn -- a unit step is
+// taken as is, as in the original L/R actions that contain forward, and a
+// longer step stops at barriers, as in MoveStep
+func (ev *XYHDEnv) TurnStep(step float32) {
+	switch {
+	case step <= 0:
+	case step <= 1:
+		ev.PosF, ev.PosI = NextVecPoint(ev.PosF, AngVec(ev.Angle).MulScalar(step)) // when L/R contains forward
+	default:
+		ev.MoveStep(step)
+	}
+}
+
+// MoveStep moves by step grid cells along the current heading, checking every
+// cell along the step, and stopping before the first barrier or the edge of
+// the world -- returns true if the step was blocked before its full length
+func (ev *XYHDEnv) MoveStep(step float32) bool {
+	v := AngVec(ev.Angle)
+	for moved := float32(0); moved < step; {
+		ds := mat32.Min(1, step-moved)
+		pf, gp := NextVecPoint(ev.PosF, v.MulScalar(ds))
+		if gp.X < 0 || gp.Y < 0 || gp.X >= ev.Size.X || gp.Y >= ev.Size.Y {
+			return true
+		}
+		if mat := ev.GetWorld(gp); mat > 0 && mat <= ev.BarrierIdx {
+			return true
+		}
+		ev.PosF, ev.PosI = pf, gp
+		moved += ds
+	}
+	return false
+}
+
 // RenderProxSoma renders proximal soma state
 func (ev *XYHDEnv) RenderProxSoma() {
 	ps := ev.NextStates["ProxSoma"]
@@ -473,11 +729,23 @@ func (ev *XYHDEnv) RenderProxSoma() {
 	}
 }
 
+// RenderProxWhisker renders graded proximity (whisker) state: for each direction,
+// a thermometer code where closer barriers activate more units (all units at contact)
+func (ev *XYHDEnv) RenderProxWhisker() {
+	pw := ev.NextStates["ProxWhisker"]
+	pw.SetZeros()
+	for i := 0; i < 4; i++ {
+		for k := 0; k < ev.ProxRange; k++ {
+			if ev.ProxDist[i] <= ev.ProxRange-k {
+				pw.Set([]int{0, i, k, 0}, 1)
+			}
+		}
+	}
+}
+
 // RenderAngle renders angle using pop ring
 func (ev *XYHDEnv) RenderAngle(statenm string, angle int) {
-	as := ev.NextStates[statenm]
-	av := (float32(angle) / 360.0)
-	ev.AngCode.Encode(&as.Values, av, ev.RingSize)
+	ev.EncodeAngle(ev.NextStates[statenm], statenm, angle)
 
 	//as.SetZeros()
 	//if angle == 0 || angle == 360 {
@@ -494,9 +762,7 @@ func (ev *XYHDEnv) RenderAngle(statenm string, angle int) {
 
 // RenderVestib renders vestibular state
 func (ev *XYHDEnv) RenderVestibular() {
-	vs := ev.NextStates["Vestibular"]
-	nv := 0.5*(float32(-ev.RotAng)/90) + 0.5
-	ev.PopCode.Encode(&vs.Values, nv, ev.VesSize, false)
+	ev.EncodeVestibular(ev.NextStates["Vestibular"], ev.RotAng)
 
 	//vs.SetZeros()
 	//if ev.RotAng == -90 {
@@ -511,11 +777,27 @@ func (ev *XYHDEnv) RenderVestibular() {
 
 // RenderPosition renders position using 2d popcode
 func (ev *XYHDEnv) RenderPosition(statenm string, posf mat32.Vec2) {
-	xy := ev.NextStates[statenm]
+	ev.EncodePosition(ev.NextStates[statenm], statenm, posf)
+}
+
+// EncodeAngle encodes angle into pat, using the encoder for given state
+func (ev *XYHDEnv) EncodeAngle(pat *etensor.Float32, statenm string, angle int) {
+	av := (float32(angle) / 360.0)
+	ev.Encs[statenm].Encode(pat, mat32.Vec2{av, 0})
+}
+
+// EncodeVestibular encodes rotation angle rot into pat, using the Vestibular encoder
+func (ev *XYHDEnv) EncodeVestibular(pat *etensor.Float32, rot int) {
+	nv := 0.5*(float32(-rot)/float32(ev.AngInc)) + 0.5
+	ev.Encs["Vestibular"].Encode(pat, mat32.Vec2{nv, 0})
+}
+
+// EncodePosition encodes position posf into pat, using the encoder for given state
+func (ev *XYHDEnv) EncodePosition(pat *etensor.Float32, statenm string, posf mat32.Vec2) {
 	pv := posf
 	pv.X /= float32(ev.Size.X) - 2
 	pv.Y /= float32(ev.Size.Y) - 2
-	ev.PopCode2d.Encode(xy, pv, false)
+	ev.Encs[statenm].Encode(pat, pv)
 }
 
 // RenderAction renders action pattern
@@ -533,12 +815,14 @@ func (ev *XYHDEnv) RenderAction() {
 // RenderState renders the current state into NextState vars
 func (ev *XYHDEnv) RenderState() {
 	ev.RenderProxSoma()
+	ev.RenderProxWhisker()
 	ev.RenderAngle("Angle", ev.Angle)
 	ev.RenderAngle("PrevAngle", ev.PrevAngle)
 	ev.RenderVestibular()
 	ev.RenderPosition("Position", ev.PosF)
 	ev.RenderPosition("PrevPosition", ev.PrevPosF)
 	ev.RenderAction()
+	ev.RenderGoal()
 }
 
 // CopyNextToCur copy next state to current state
@@ -567,13 +851,12 @@ func (ev *XYHDEnv) Step() bool {
 }
 
 func (ev *XYHDEnv) Action(action string, nop etensor.Tensor) {
-	a, ok := ev.ActMap[action]
+	a, ok := ActionFromString(action)
 	if !ok {
 		fmt.Printf("Action not recognized: %s\n", action)
 		return
 	}
-	ev.Act = a
-	ev.TakeAct(ev.Act)
+	ev.DoAction(NewAction(a))
 }
 
 func (ev *XYHDEnv) Counter(scale env.TimeScales) (cur, prv int, chg bool) {
@@ -641,6 +924,16 @@ var XYHDEnvProps = ki.Props{
 				}},
 			},
 		}},
+		{"SavePatBank", ki.Props{
+			"label": "Save Pat Bank...",
+			"icon":  "file-save",
+			"desc":  "Save pats to json file as a versioned pattern bank, with the hash of the pats as its identity, and the version incremented if they changed",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".json",
+				}},
+			},
+		}},
 	},
 }
 
@@ -717,8 +1010,12 @@ func (ev *XYHDEnv) WorldRect(st, ed evec.Vec2i, mat int) {
 	ev.WorldLineVert(evec.Vec2i{ed.X, st.Y}, evec.Vec2i{ed.X, ed.Y}, mat)
 }
 
-// GenWorld generates a world -- edit to create in way desired
+// GenWorld generates a world according to Preset -- edit OpenField case to create in way desired
 func (ev *XYHDEnv) GenWorld() {
+	if ev.Preset != OpenField {
+		ev.GenPreset()
+		return
+	}
 	wall := ev.MatMap["Wall"]
 	ev.World.SetZeros()
 	// always start with a wall around the entire world -- no seeing the turtles..
@@ -735,6 +1032,10 @@ func (ev *XYHDEnv) GenWorld() {
 
 	// clear center
 	ev.SetWorld(ctr, 0)
+
+	ev.Start = ctr
+	ev.StartAngle = 0
+	ev.Goals = nil
 }
 
 ////////////////////////////////////////////////////////////////////
@@ -752,8 +1053,8 @@ func (ev *XYHDEnv) ActGenTrace(desc string, act int) {
 // coded heuristics -- i.e., what subcortical evolutionary instincts provide.
 func (ev *XYHDEnv) ActGen() int {
 	wall := ev.MatMap["Wall"]
-	left := ev.ActMap["Left"]
-	right := ev.ActMap["Right"]
+	left := int(Left)
+	right := int(Right)
 
 	nmat := len(ev.Mats)
 	frmat := ints.MinInt(ev.ProxMats[0], nmat)
@@ -770,7 +1071,13 @@ func (ev *XYHDEnv) ActGen() int {
 	lastact := ev.Act
 	frnd := rand.Float32()
 
-	act := ev.ActMap["Forward"] // default
+	act := int(Forward) // default
+
+	if ev.IsStuck && ev.Stuck.Unstick {
+		act = ev.UnstickAct()
+		ev.ActGenTrace("stuck, unstick", act)
+		return act
+	}
 
 	// when L/R contains forward
 	switch {
@@ -0,0 +1,89 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/goki/ki/kit"
+)
+
+// Actions are the discrete action types available to the agent
+type Actions int32
+
+//go:generate stringer -type=Actions -output stringer.go
+
+var KiT_Actions = kit.Enums.AddEnum(ActionsN, false, nil)
+
+// The actions avail -- names are also used as the keys for action patterns in env Pats
+const (
+	// Stay does nothing
+	Stay Actions = iota
+
+	// Left rotates left (counter-clockwise) by the turn angle
+	Left
+
+	// Right rotates right (clockwise) by the turn angle
+	Right
+
+	// Forward steps forward in the current heading, unless blocked by a barrier
+	Forward
+
+	// Backward steps backward from the current heading, unless blocked by a barrier
+	Backward
+
+	// Eat eats food directly in front
+	Eat
+
+	// Drink drinks water directly in front
+	Drink
+
+	ActionsN
+)
+
+// ActionNames returns the names of all the actions, in order
+func ActionNames() []string {
+	nms := make([]string, ActionsN)
+	for i := range nms {
+		nms[i] = Actions(i).String()
+	}
+	return nms
+}
+
+// ActionFromString returns the action with given name, and false if not found
+func ActionFromString(nm string) (Actions, bool) {
+	for a := Actions(0); a < ActionsN; a++ {
+		if a.String() == nm {
+			return a, true
+		}
+	}
+	return ActionsN, false
+}
+
+// Action is a motor command: a discrete action type with optional continuous
+// parameters -- zero-valued parameters use the env defaults, so the discrete
+// actions are just Action{Act: act}.
+type Action struct {
+	Act  Actions `desc:"discrete action type"`
+	Turn float32 `desc:"turn angle in degrees for Left / Right -- 0 = env default (AngInc)"`
+	Step float32 `desc:"step length in grid cells for Forward / Backward, rounded to whole cells -- 0 = env default (1)"`
+}
+
+// NewAction returns a discrete action with default parameters
+func NewAction(act Actions) Action {
+	return Action{Act: act}
+}
+
+// IsDiscrete returns true if the action uses default parameters
+func (ac Action) IsDiscrete() bool {
+	return ac.Turn == 0 && ac.Step == 0
+}
+
+func (ac Action) String() string {
+	if ac.IsDiscrete() {
+		return ac.Act.String()
+	}
+	return fmt.Sprintf("%s_%g_%g", ac.Act, ac.Turn, ac.Step)
+}
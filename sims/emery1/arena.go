@@ -0,0 +1,134 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/emer/emergent/actrf"
+	"github.com/emer/emergent/evec"
+	"github.com/goki/ki/ints"
+	"github.com/goki/ki/kit"
+)
+
+// ArenaShapes are the shapes of the arena within the outer walls of the world
+type ArenaShapes int32
+
+var KiT_ArenaShapes = kit.Enums.AddEnum(ArenaShapesN, false, nil)
+
+const (
+	// ArenaRect is the whole rectangular world inside the outer walls
+	ArenaRect ArenaShapes = iota
+
+	// ArenaCircle is the largest circle that fits inside the outer walls, the
+	// classic open field of place and grid cell recordings
+	ArenaCircle
+
+	// ArenaLShape is the rectangular world with its upper right quadrant
+	// walled off
+	ArenaLShape
+
+	ArenaShapesN
+)
+
+// FromString sets the shape from its name, with or without the Arena prefix
+func (i *ArenaShapes) FromString(s string) error {
+	for j := ArenaShapes(0); j < ArenaShapesN; j++ {
+		if j.String() == s || j.String() == "Arena"+s {
+			*i = j
+			return nil
+		}
+	}
+	return fmt.Errorf("ArenaShapes: %q not found", s)
+}
+
+// InShape returns true if given point is inside the arena shape, ignoring
+// the materials of the world
+func (ev *FWorld) InShape(p evec.Vec2i) bool {
+	sz := ev.Size
+	if p.X < 1 || p.Y < 1 || p.X >= sz.X-1 || p.Y >= sz.Y-1 {
+		return false
+	}
+	switch ev.Arena {
+	case ArenaCircle:
+		r := float32(ints.MinInt(sz.X, sz.Y))/2 - 1.5 // keep outer wall
+		dx := float32(p.X) - float32(sz.X-1)/2
+		dy := float32(p.Y) - float32(sz.Y-1)/2
+		return dx*dx+dy*dy <= r*r
+	case ArenaLShape:
+		ctr := sz.DivScalar(2)
+		return p.X < ctr.X || p.Y < ctr.Y
+	}
+	return true
+}
+
+// ApplyArena fills the cells outside the arena shape with given barrier
+// material, so they are impassable -- called in GenWorld
+func (ev *FWorld) ApplyArena(mat int) {
+	for y := 0; y < ev.Size.Y; y++ {
+		for x := 0; x < ev.Size.X; x++ {
+			p := evec.Vec2i{x, y}
+			if !ev.InShape(p) {
+				ev.SetWorld(p, mat)
+			}
+		}
+	}
+}
+
+// StartPos returns the start location of the agent: the center of the
+// world, or of the lower left quadrant for ArenaLShape
+func (ev *FWorld) StartPos() evec.Vec2i {
+	if ev.Arena == ArenaLShape {
+		return ev.Size.DivScalar(4)
+	}
+	return ev.Size.DivScalar(2)
+}
+
+// InArena returns true if given point is inside the arena: not a barrier
+// (e.g., outside a circular or L-shaped arena), and so can be occupied
+func (ev *FWorld) InArena(p evec.Vec2i) bool {
+	mat := ev.GetWorld(p)
+	return mat == 0 || mat > ev.BarrierIdx
+}
+
+// ArenaNormRF re-normalizes the NormRF of af, if its source is the world
+// grid (i.e., a Pos RF), over the cells inside the arena only, so that the
+// walls and out-of-arena cells of irregular arenas, which are never
+// occupied, do not set the range of the normalization: they are set to 0.
+func (ev *FWorld) ArenaNormRF(af *actrf.RF) {
+	nr := &af.NormRF
+	nd := nr.NumDims()
+	if nd < 2 || nr.Dim(nd-2) != ev.Size.Y || nr.Dim(nd-1) != ev.Size.X {
+		return
+	}
+	nsrc := ev.Size.Y * ev.Size.X
+	nu := nr.Len() / nsrc
+	for ui := 0; ui < nu; ui++ {
+		st := ui * nsrc
+		rf := af.RF.Values[st : st+nsrc]
+		vals := nr.Values[st : st+nsrc]
+		first := true
+		var mn, mx float32
+		for i, v := range rf {
+			if !ev.InArena(evec.Vec2i{i % ev.Size.X, i / ev.Size.X}) {
+				continue
+			}
+			if first || v < mn {
+				mn = v
+			}
+			if first || v > mx {
+				mx = v
+			}
+			first = false
+		}
+		for i, v := range rf {
+			if mx <= mn || !ev.InArena(evec.Vec2i{i % ev.Size.X, i / ev.Size.X}) {
+				vals[i] = 0
+				continue
+			}
+			vals[i] = (v - mn) / (mx - mn)
+		}
+	}
+}
@@ -14,14 +14,16 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/ccnlab/map-nav/sims/netlayout"
+	"github.com/ccnlab/map-nav/sims/paramcomp"
 	"github.com/emer/emergent/actrf"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/erand"
+	"github.com/emer/emergent/evec"
 	"github.com/emer/emergent/netview"
 	"github.com/emer/emergent/params"
 	"github.com/emer/emergent/prjn"
-	"github.com/emer/emergent/relpos"
 	"github.com/emer/empi/mpi"
 	"github.com/emer/etable/agg"
 	"github.com/emer/etable/eplot"
@@ -75,6 +77,7 @@ type Sim struct {
 	Net              *deep.Network     `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
 	PctCortex        float64           `desc:"proportion of action driven by the cortex vs. hard-coded reflexive subcortical"`
 	PctCortexMax     float64           `desc:"maximum PctCortex, when running on the schedule"`
+	M1Decode         M1Decode          `desc:"decoding of the cortical action from M1 population activity instead of VL"`
 	ARFs             actrf.RFs         `view:"no-inline" desc:"activation-based receptive fields"`
 	TrnEpcLog        *etable.Table     `view:"no-inline" desc:"training epoch-level log data"`
 	TrnTrlLog        *etable.Table     `view:"no-inline" desc:"training trial-level log data"`
@@ -86,8 +89,14 @@ type Sim struct {
 	TstCycLog        *etable.Table     `view:"no-inline" desc:"testing cycle-level log data"`
 	RunLog           *etable.Table     `view:"no-inline" desc:"summary log of each run"`
 	RunStats         *etable.Table     `view:"no-inline" desc:"aggregate stats on all runs"`
+	PlanLog          *etable.Table     `view:"no-inline" desc:"log of goal episodes using the planner"`
+	Planner          Planner           `view:"inline" desc:"model-based planner using the learned forward model to select actions toward a goal"`
 	Params           params.Sets       `view:"no-inline" desc:"full collection of param sets"`
-	ParamSet         string            `desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set"`
+	Bench            Bench             `desc:"standard benchmark protocol, run with the -bench flag"`
+	Progress         Progress          `view:"-" desc:"wall-clock time and throughput of training"`
+	LrateMult        float32           `inactive:"+" desc:"current learning rate multiplier from the TrainSched schedule"`
+	ParamSet         string            `desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- a paramcomp spec of set names and numeric overrides separated by +, e.g., LongPlus+Gi=1.6, with the ParamDeps of each set applied before it"`
+	SaveParams       string            `view:"-" desc:"if set, the composed params set applied by SetParams is saved to this file, as JSON"`
 	Tag              string            `desc:"extra tag string to add to any file names output from sim (e.g., weights files, log files, params for run)"`
 	Prjn4x4Skp2      *prjn.PoolTile    `view:"no-inline" desc:"feedforward 4x4 skip 2 topo prjn"`
 	Prjn4x4Skp2Recip *prjn.PoolTile    `view:"no-inline" desc:"feedforward 4x4 skip 2 topo prjn, recip"`
@@ -98,11 +107,20 @@ type Sim struct {
 	MaxEpcs          int               `desc:"maximum number of epochs to run per model run"`
 	NZeroStop        int               `desc:"if a positive number, training will stop after this many epochs with zero SSE"`
 	TrainEnv         FWorld            `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
+	TestEnv          FWorld            `desc:"Testing environment -- the same world as TrainEnv, traversed on a fresh trajectory from the start location for each test, with frozen weights"`
+	Inputs           []InputMap        `desc:"env states applied to each input layer by ApplyInputs -- set to the DefaultInputs for the sim config at Config unless InputsSet"`
+	InputsSet        bool              `desc:"if true, the Inputs were set by the user (e.g., -inputs) and are kept at Config -- otherwise they are set to the DefaultInputs, which depend on the layers that are on"`
+	StrictInputs     bool              `desc:"at Config, exit if any layer or state of the Inputs does not exist, instead of reporting it and skipping it in ApplyInputs"`
 	Time             leabra.Time       `desc:"leabra timing parameters and state"`
+	MinusQtrs        int               `def:"3" min:"1" desc:"number of quarters in the minus phase -- the action is taken at the end of the minus phase"`
+	PlusQtrs         int               `def:"1" min:"1" desc:"number of quarters in the plus phase -- more than 1 gives an extra-long plus phase"`
+	CycPerQtr        int               `def:"25" min:"1" desc:"number of cycles per quarter"`
+	ITI              ITI               `view:"inline" desc:"inter-trial interval of blank cycles with inputs off, and activity decay, between trials"`
 	ViewOn           bool              `desc:"whether to update the network view while running"`
 	TrainUpdt        leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt         leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
 	TestInterval     int               `desc:"how often to run through all the test patterns, in terms of training epochs"`
+	TestTrls         int               `desc:"number of trials in each test"`
 	LayStatNms       []string          `desc:"names of layers to collect more detailed stats on (avg act, etc)"`
 	ARFLayers        []string          `desc:"names of layers to compute position activation fields on"`
 
@@ -141,7 +159,9 @@ type Sim struct {
 	TstTrlPlot    *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
 	TstCycPlot    *eplot.Plot2D               `view:"-" desc:"the test-cycle plot"`
 	RunPlot       *eplot.Plot2D               `view:"-" desc:"the run plot"`
+	PlanPlot      *eplot.Plot2D               `view:"-" desc:"the planner plot"`
 	TrnEpcFile    *os.File                    `view:"-" desc:"log file"`
+	TstEpcFile    *os.File                    `view:"-" desc:"log file"`
 	RunFile       *os.File                    `view:"-" desc:"log file"`
 	PopVals       []float32                   `view:"-" desc:"tmp pop code values"`
 	ValsTsrs      map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
@@ -151,6 +171,8 @@ type Sim struct {
 	LogSetParams  bool                        `view:"-" desc:"if true, print message for all params that are set"`
 	IsRunning     bool                        `view:"-" desc:"true if sim is running"`
 	StopNow       bool                        `view:"-" desc:"flag to stop running"`
+	Stage         TrainStages                 `view:"-" desc:"stage of the current training trial in the training stepper"`
+	Alpha         AlphaState                  `view:"-" desc:"state of the alpha cycle in progress, run one cycle at a time by the training stepper"`
 	NeedsNewRun   bool                        `view:"-" desc:"flag to initialize NewRun if last one finished"`
 	RndSeed       int64                       `view:"-" desc:"the current random seed"`
 	UseMPI        bool                        `view:"-" desc:"if true, use MPI to distribute computation across nodes"`
@@ -177,6 +199,7 @@ func (ss *Sim) New() {
 	ss.TstCycLog = &etable.Table{}
 	ss.RunLog = &etable.Table{}
 	ss.RunStats = &etable.Table{}
+	ss.PlanLog = &etable.Table{}
 	ss.Params = ParamSets
 	ss.RndSeed = 1
 	ss.ViewOn = true
@@ -192,6 +215,13 @@ func (ss *Sim) New() {
 func (ss *Sim) Defaults() {
 	ss.PctCortexMax = 0.9
 	ss.TestInterval = 50000
+	ss.TestTrls = 200
+	ss.MinusQtrs = 3
+	ss.PlusQtrs = 1
+	ss.CycPerQtr = 25
+	ss.Bench.Defaults()
+	ss.M1Decode.Defaults()
+	ss.Planner.Defaults()
 }
 
 // NewPrjns creates new projections
@@ -219,12 +249,14 @@ func (ss *Sim) NewPrjns() {
 func (ss *Sim) Config() {
 	ss.ConfigEnv()
 	ss.ConfigNet(ss.Net)
+	ss.ConfigInputs()
 	ss.ConfigTrnEpcLog(ss.TrnEpcLog)
 	ss.ConfigTrnTrlLog(ss.TrnTrlLog)
 	ss.ConfigTstEpcLog(ss.TstEpcLog)
 	ss.ConfigTstTrlLog(ss.TstTrlLog)
 	ss.ConfigTstCycLog(ss.TstCycLog)
 	ss.ConfigRunLog(ss.RunLog)
+	ss.ConfigPlanLog(ss.PlanLog)
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -243,6 +275,18 @@ func (ss *Sim) ConfigEnv() {
 	ss.TrainEnv.Init(0)
 	ss.TrainEnv.Validate()
 
+	ss.TestEnv.AngInc = ss.TrainEnv.AngInc
+	ss.TestEnv.Size = ss.TrainEnv.Size
+	ss.TestEnv.Arena = ss.TrainEnv.Arena
+	ss.TestEnv.LocalView = ss.TrainEnv.LocalView
+	ss.TestEnv.KeepWorld = true // same world as TrainEnv, from world.tsv
+	ss.TestEnv.Config(ss.TestTrls)
+	ss.TestEnv.Nm = "TestEnv"
+	ss.TestEnv.Dsc = "testing params and state"
+	ss.TestEnv.Disp = false
+	ss.TestEnv.Init(0)
+	ss.TestEnv.Validate()
+
 	ss.ConfigRFMaps()
 }
 
@@ -278,9 +322,9 @@ func (ss *Sim) ConfigNet(net *deep.Network) {
 
 	v1f := net.AddLayer4D("V1F", 1, fsz, ev.PatSize.Y, ev.PatSize.X, emer.Input) // Fovea
 
-	s1s := net.AddLayer4D("S1S", 1, 4, 2, 1, emer.Input)                       // ProxSoma
-	s1v := net.AddLayer2D("S1V", ev.PopSize, 1, emer.Input)                    // Vestibular
-	ins := net.AddLayer4D("Ins", 1, len(ev.Inters), ev.PopSize, 1, emer.Input) // Inters = Insula
+	s1s := net.AddLayer4D("S1S", 1, 4, 2, 1, emer.Input)                // ProxSoma
+	s1v := net.AddLayer2D("S1V", ev.PopSize, 1, emer.Input)             // Vestibular
+	net.AddLayer4D("Ins", 1, len(ev.Inters), ev.PopSize, 1, emer.Input) // Inters = Insula
 
 	m1 := net.AddLayer2D("M1", 8, 8, emer.Hidden)
 	vl := net.AddLayer2D("VL", ev.PatSize.Y, ev.PatSize.X, emer.Target) // Action
@@ -312,6 +356,12 @@ func (ss *Sim) ConfigNet(net *deep.Network) {
 	itp.Shape().SetShape(v1f.Shape().Shp, nil, nil)
 	itp.(*deep.TRCLayer).Drivers.Add("V1F")
 
+	var locv emer.Layer
+	if ev.LocalView.On {
+		locv = net.AddLayer4D("LocV", ev.LocalView.NHeads, fsz, ev.PatSize.Y, ev.PatSize.X, emer.Input) // LocalView
+		locv.SetClass("LocV")
+	}
+
 	lip, lipct, lipp := net.AddDeep4D("LIP", 1, fsz, 5, 5)
 	lipp.Shape().SetShape(v2fd.Shape().Shp, nil, nil)
 	lipp.(*deep.TRCLayer).Drivers.Add("V2Fd")
@@ -322,6 +372,9 @@ func (ss *Sim) ConfigNet(net *deep.Network) {
 	s1s.SetClass("S1S")
 	s1sp.SetClass("S1S")
 
+	s1v.SetClass("S1V")
+	s1vp.SetClass("S1V")
+
 	mstd.SetClass("MSTd")
 	mstdct.SetClass("MSTd")
 	mstdp.SetClass("MSTd")
@@ -346,44 +399,6 @@ func (ss *Sim) ConfigNet(net *deep.Network) {
 	lipct.SetClass("LIP")
 	lipp.SetClass("LIP")
 
-	v1f.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "V2Pd", YAlign: relpos.Front, Space: 4})
-	v2fd.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "V1F", XAlign: relpos.Left, Space: 4})
-
-	s1s.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "V1F", YAlign: relpos.Front, Space: 4})
-	s1v.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "S1S", YAlign: relpos.Front, Space: 8})
-	ins.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "S1V", YAlign: relpos.Front, Space: 4})
-
-	s1sp.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "S1S", XAlign: relpos.Left, Space: 4})
-	s1vp.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "S1V", XAlign: relpos.Left, Space: 4})
-
-	vl.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "Ins", YAlign: relpos.Front, Space: 8})
-
-	mstd.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: "V2Pd", XAlign: relpos.Left, YAlign: relpos.Front})
-	mstdct.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "MSTd", XAlign: relpos.Left, Space: 4})
-	mstdp.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "MSTdCT", XAlign: relpos.Left, Space: 4})
-
-	cipl.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "MSTd", YAlign: relpos.Front, Space: 4})
-	ciplct.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "cIPL", XAlign: relpos.Left, Space: 4})
-	ciplp.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "cIPLCT", XAlign: relpos.Left, Space: 4})
-
-	pcc.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "cIPL", YAlign: relpos.Front, Space: 6})
-	pccct.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "PCC", XAlign: relpos.Left, Space: 4})
-	pccp.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "PCCCT", XAlign: relpos.Left, Space: 4})
-
-	sma.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "PCC", YAlign: relpos.Front, Space: 6})
-	smact.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "SMA", XAlign: relpos.Left, Space: 4})
-	smap.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "SMACT", XAlign: relpos.Left, Space: 4})
-
-	m1.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "SMA", YAlign: relpos.Front, Space: 2})
-
-	it.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "V2Pd", XAlign: relpos.Left, Space: 4})
-	itct.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "IT", XAlign: relpos.Left, Space: 4})
-	itp.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "ITCT", XAlign: relpos.Left, Space: 4})
-
-	lip.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: "V2Fd", YAlign: relpos.Front, Space: 4})
-	lipct.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "LIP", XAlign: relpos.Left, Space: 4})
-	lipp.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: "LIPCT", XAlign: relpos.Left, Space: 4})
-
 	full := prjn.NewFull()
 	sameu := prjn.NewPoolSameUnit()
 	sameu.SelfCon = false
@@ -466,6 +481,9 @@ func (ss *Sim) ConfigNet(net *deep.Network) {
 	net.ConnectLayers(s1s, pcc, full, emer.Forward)
 	net.ConnectLayers(s1v, pcc, full, emer.Forward)
 	net.ConnectLayers(vl, pcc, full, emer.Back)
+	if locv != nil {
+		net.ConnectLayers(locv, pcc, full, emer.Forward) // local view, alongside path integration
+	}
 
 	net.ConnectCtxtToCT(pccct, pccct, full).SetClass("CTSelf")
 
@@ -533,6 +551,17 @@ func (ss *Sim) ConfigNet(net *deep.Network) {
 	net.ConnectLayers(smact, lipct, full, emer.Back).SetClass("CTBack") // always need sma to predict action outcome
 	// net.ConnectLayers(pccct, lipct, full, emer.Back).SetClass("CTBack")
 
+	//////////////////////////////////////
+	// position
+
+	lay := netlayout.Layout{}
+	lay.Defaults()
+	lay.Groups = []string{"MSTd", "cIPL", "PCC", "SMA", "IT", "LIP", "S1S", "S1V"}
+	lay.Levels = map[string]int{"VL": 0} // action output next to the inputs
+	if err := lay.Apply(net); err != nil {
+		log.Println(err)
+	}
+
 	ss.PulvLays = make([]string, 0, 10)
 	ss.HidLays = make([]string, 0, 10)
 	ss.SuperLays = make([]string, 0, 10)
@@ -579,6 +608,7 @@ func (ss *Sim) InitWts(net *deep.Network) {
 	net.InitTopoScales() //  sets all wt scales
 	net.InitWts()
 	net.LrateMult(1) // restore initial learning rate value
+	ss.LrateMult = 1
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -593,6 +623,7 @@ func (ss *Sim) Init() {
 	ss.StopNow = false
 	ss.SetParams("", ss.LogSetParams) // all sheets
 	ss.NewRun()
+	ss.Progress.Reset()
 	ss.UpdateView(true)
 }
 
@@ -607,7 +638,7 @@ func (ss *Sim) NewRndSeed() {
 // and add a few tabs at the end to allow for expansion..
 func (ss *Sim) Counters(train bool) string {
 	// if train {
-	return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tEvent:\t%d\tCycle:\t%d\tName:\t%v\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Event.Cur, ss.Time.Cycle, ss.TrainEnv.Event.Cur)
+	return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tEvent:\t%d\tCycle:\t%d\tName:\t%v\t%s\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Event.Cur, ss.Time.Cycle, ss.TrainEnv.Event.Cur, ss.ProgressString())
 	// } else {
 	// 	return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tEvent:\t%d\tCycle:\t%d\tName:\t%v\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TestEnv.Event.Cur, ss.Time.Cycle, ss.TrainEnv.Event.Cur)
 	// }
@@ -632,10 +663,30 @@ func (ss *Sim) UpdateView(train bool) {
 // Handles netview updating within scope of AlphaCycle
 func (ss *Sim) AlphaCyc(train bool) {
 	// ss.Win.PollEvents() // this can be used instead of running in a separate goroutine
-	viewUpdt := ss.TrainUpdt
+	ss.AlphaCycStart(train)
+	for {
+		if _, done := ss.AlphaCycCycle(); done {
+			break
+		}
+	}
+}
+
+// AlphaCycStart starts an alpha cycle, to be run one cycle at a time by
+// AlphaCycCycle, as AlphaCyc does, or by the training stepper
+func (ss *Sim) AlphaCycStart(train bool) {
+	as := &ss.Alpha
+	as.Active = true
+	as.Train = train
+	as.ViewUpdt = ss.TrainUpdt
+	as.Env = &ss.TrainEnv
+	as.PctCortex = ss.PctCortex
 	if !train {
-		viewUpdt = ss.TestUpdt
+		as.ViewUpdt = ss.TestUpdt
+		as.Env = &ss.TestEnv
+		as.PctCortex = 0 // reflexive actions, so the test trajectory does not depend on the network
 	}
+	as.Qtr = 0
+	as.Cyc = 0
 
 	// update prior weight changes at start, so any DWt values remain visible at end
 	// you might want to do this less frequently to achieve a mini-batch update
@@ -645,61 +696,124 @@ func (ss *Sim) AlphaCyc(train bool) {
 		ss.Net.WtFmDWt()
 	}
 
-	ev := &ss.TrainEnv
-
 	ss.Net.AlphaCycInit(train)
+	ss.Time.CycPerQtr = ss.CycPerQtr
 	ss.Time.AlphaCycStart()
-	for qtr := 0; qtr < 4; qtr++ {
-		for cyc := 0; cyc < ss.Time.CycPerQtr; cyc++ {
-			ss.Net.Cycle(&ss.Time)
-			if !train {
-				ss.LogTstCyc(ss.TstCycLog, ss.Time.Cycle)
-			}
-			ss.Time.CycleInc()
-			if ss.ViewOn {
-				switch viewUpdt {
-				case leabra.Cycle:
-					ss.UpdateView(train)
-				case leabra.FastSpike:
-					if (cyc+1)%10 == 0 {
-						ss.UpdateView(train)
-					}
-				}
+}
+
+// AlphaCycCycle runs the next cycle of the alpha cycle started by
+// AlphaCycStart, returning qtrEnd = true if it was the last cycle of a
+// quarter, and done = true if it was the last cycle of the alpha cycle,
+// which is then finished (DWt).  The action is taken at the end of the
+// minus phase.
+func (ss *Sim) AlphaCycCycle() (qtrEnd, done bool) {
+	as := &ss.Alpha
+	train := as.Train
+	viewUpdt := as.ViewUpdt
+	nqtr := ss.MinusQtrs + ss.PlusQtrs
+	qtr := as.Qtr
+	cyc := as.Cyc
+	lq := ss.LeabraQtr(qtr)
+	if cyc == 0 {
+		ss.Time.Quarter = lq
+		if lq < 0 {
+			ss.Time.Quarter = 3
+		}
+		ss.Time.PlusPhase = qtr >= ss.MinusQtrs
+	}
+	ss.Net.Cycle(&ss.Time)
+	if !train {
+		ss.LogTstCyc(ss.TstCycLog, ss.Time.Cycle)
+	}
+	ss.Time.CycleInc()
+	if ss.ViewOn {
+		switch viewUpdt {
+		case leabra.Cycle:
+			ss.UpdateView(train)
+		case leabra.FastSpike:
+			if (cyc+1)%10 == 0 {
+				ss.UpdateView(train)
 			}
 		}
+	}
+	as.Cyc++
+	if as.Cyc < ss.Time.CycPerQtr {
+		return false, false
+	}
+	if lq >= 0 {
 		ss.Net.QuarterFinal(&ss.Time)
-		ss.Time.QuarterInc()
-		if qtr == 2 {
-			ss.TakeAction(ss.Net, ev)
-		}
-		if ss.ViewOn {
-			switch {
-			case viewUpdt <= leabra.Quarter:
+	}
+	if lq == 2 {
+		ss.TakeAction(ss.Net, as.Env, as.PctCortex)
+	}
+	if ss.ViewOn {
+		switch {
+		case viewUpdt <= leabra.Quarter:
+			ss.UpdateView(train)
+		case viewUpdt == leabra.Phase:
+			if qtr == ss.MinusQtrs-1 || qtr == nqtr-1 {
 				ss.UpdateView(train)
-			case viewUpdt == leabra.Phase:
-				if qtr >= 2 {
-					ss.UpdateView(train)
-				}
 			}
 		}
 	}
+	as.Qtr++
+	as.Cyc = 0
+	if as.Qtr < nqtr {
+		return true, false
+	}
+	ss.AlphaCycEnd()
+	return true, true
+}
 
-	if train {
+// AlphaCycEnd finishes the alpha cycle -- called by AlphaCycCycle after the
+// last cycle
+func (ss *Sim) AlphaCycEnd() {
+	as := &ss.Alpha
+	as.Active = false
+	ss.Time.Quarter = 4 // as after QuarterInc at end of standard alpha cycle
+	ss.Time.PlusPhase = false
+
+	if as.Train {
 		ss.Net.DWt()
 	}
-	if ss.ViewOn && viewUpdt == leabra.AlphaCycle {
-		ss.UpdateView(train)
+	if ss.ViewOn && as.ViewUpdt == leabra.AlphaCycle {
+		ss.UpdateView(as.Train)
 	}
-	if !train {
+	if !as.Train && ss.TstCycPlot != nil {
 		ss.TstCycPlot.GoUpdate() // make sure up-to-date at end
 	}
 }
 
+// LeabraQtr returns the leabra Time.Quarter for given quarter of the trial,
+// under the MinusQtrs / PlusQtrs phase structure: the last minus quarter is 2
+// and the last plus quarter is 3, so that QuarterFinal records ActM and ActP
+// (and clamps targets, updates deep context) at the end of each phase.
+// Returns -1 for plus quarters before the last one, for which QuarterFinal is not called.
+func (ss *Sim) LeabraQtr(qtr int) int {
+	switch {
+	case qtr == ss.MinusQtrs-1:
+		return 2
+	case qtr == 0:
+		return 0
+	case qtr < ss.MinusQtrs:
+		return 1
+	case qtr == ss.MinusQtrs+ss.PlusQtrs-1:
+		return 3
+	}
+	return -1
+}
+
 // TakeAction takes action for this step, using either decoded cortical
-// or reflexive subcortical action from env.
-func (ss *Sim) TakeAction(net *deep.Network, ev *FWorld) {
+// (from VL, or M1 if M1Decode.On) or reflexive subcortical action from env,
+// with probability pctCortex of the cortical one.
+func (ss *Sim) TakeAction(net *deep.Network, ev *FWorld, pctCortex float64) {
 	ly := net.LayerByName("VL").(leabra.LeabraLayer).AsLeabra()
 	nact := ss.DecodeAct(ly, ev)
+	if ss.M1Decode.On {
+		if mact, ok := ss.M1DecodeAct(net, ev); ok {
+			nact = mact
+		}
+	}
 	gact := ev.ActGen()
 	ss.NetAction = ev.Acts[nact]
 	ss.GenAction = ev.Acts[gact]
@@ -707,13 +821,20 @@ func (ss *Sim) TakeAction(net *deep.Network, ev *FWorld) {
 	if nact == gact {
 		ss.ActMatch = 1
 	}
-	if erand.BoolProb(ss.PctCortex, -1) {
-		ss.ActAction = ss.NetAction
-	} else {
-		ss.ActAction = ss.GenAction
+	act := Actions(gact)
+	if erand.BoolProb(pctCortex, -1) {
+		act = Actions(nact)
+	}
+	if ev.ExtAct >= 0 {
+		act = Actions(ev.ExtAct)
+		ev.ExtAct = -1
+	}
+	ss.ActAction = act.String()
+	if ss.M1Decode.On && ev == &ss.TrainEnv {
+		ss.M1Learn(ss.ActAction)
 	}
 	ly.SetType(emer.Input)
-	ev.Action(ss.ActAction, nil)
+	ev.ExecAct(NewAction(act))
 	ap, ok := ev.Pats[ss.ActAction]
 	if ok {
 		ly.ApplyExt(ap)
@@ -727,17 +848,20 @@ func (ss *Sim) DecodeAct(ly *leabra.Layer, ev *FWorld) int {
 	vt := ss.ValsTsr("VL")
 	ly.UnitValsTensor(vt, "ActM")
 
-	cnm := ""
+	act := -1
 	dst := float32(0)
-	for nm, pat := range ev.Pats {
+	for ai, nm := range ev.Acts { // in order, so ties go to the first action
+		pat, ok := ev.Pats[nm]
+		if !ok {
+			continue
+		}
 		d := metric.Correlation32(vt.Values, pat.Values)
-		if cnm == "" || d > dst {
-			cnm = nm
+		if act < 0 || d > dst {
+			act = ai
 			dst = d
 		}
 	}
-	act, ok := ev.ActMap[cnm]
-	if !ok {
+	if act < 0 {
 		act = rand.Intn(len(ev.Acts))
 	}
 	return act
@@ -751,23 +875,30 @@ func (ss *Sim) ApplyInputs(net *deep.Network, en env.Env) {
 	net.InitExt() // clear any existing inputs -- not strictly necessary if always
 	// going to the same layers, but good practice and cheap anyway
 
-	states := []string{"Depth", "FovDepth", "Fovea", "ProxSoma", "Vestibular", "Inters", "Action"}
-	lays := []string{"V2Pd", "V2Fd", "V1F", "S1S", "S1V", "Ins", "VL"}
-	for i, lnm := range lays {
-		lyi := ss.Net.LayerByName(lnm)
+	for _, im := range ss.Inputs {
+		lyi := ss.Net.LayerByName(im.Layer)
 		if lyi == nil {
 			continue
 		}
 		ly := lyi.(leabra.LeabraLayer).AsLeabra()
-		pats := en.State(states[i])
+		pats := en.State(im.State)
 		if pats != nil {
 			ly.ApplyExt(pats)
 		}
 	}
 }
 
-// TrainTrial runs one trial of training using TrainEnv
+// TrainTrial runs one trial of training using TrainEnv, or the rest of the
+// current trial if it was stepped partway through
 func (ss *Sim) TrainTrial() {
+	ss.TrainStep(StepTrial)
+}
+
+// TrainEnvStep steps the TrainEnv for a new training trial, with the epoch
+// and run level bookkeeping when the epoch changes -- returns false if the
+// run (or all runs) ended instead.
+// First stage of a trial in the training stepper.
+func (ss *Sim) TrainEnvStep() bool {
 	if ss.NeedsNewRun {
 		ss.NewRun()
 	}
@@ -780,30 +911,45 @@ func (ss *Sim) TrainTrial() {
 	if chg {
 		ss.LogTrnEpc(ss.TrnEpcLog)
 		ss.TrainSched(epc)
+		ss.PrintProgress()
 		ss.TrainEnv.Event.Cur = 0
 		if ss.ViewOn && ss.TrainUpdt > leabra.AlphaCycle {
 			ss.UpdateView(true)
 		}
-		// if epc%ss.TestInterval == 0 { // note: epc is *next* so won't trigger first time
-		// 	ss.TestAll()
-		// }
+		if ss.TestInterval > 0 && epc%ss.TestInterval == 0 { // note: epc is *next* so won't trigger first time
+			ss.TestAll()
+		}
 		if epc >= ss.MaxEpcs {
 			// done with training..
 			ss.RunEnd()
 			if ss.TrainEnv.Run.Incr() { // we are done!
 				ss.StopNow = true
-				return
+				return false
 			} else {
 				ss.NeedsNewRun = true
-				return
+				return false
 			}
 		}
 	}
+	return true
+}
 
+// TrainTrialStart applies the inputs for the training trial and starts its
+// alpha cycle -- second stage of a trial in the training stepper, followed
+// by the cycles (AlphaCycCycle) and TrainTrialEnd
+func (ss *Sim) TrainTrialStart() {
+	ss.ITICycles()
 	ss.ApplyInputs(ss.Net, &ss.TrainEnv)
-	ss.AlphaCyc(true)   // train
+	ss.AlphaCycStart(true) // train
+}
+
+// TrainTrialEnd computes the stats and logs the training trial after its
+// alpha cycle -- last stage of a trial in the training stepper
+func (ss *Sim) TrainTrialEnd() {
 	ss.TrialStats(true) // accumulate
 	ss.LogTrnTrl(ss.TrnTrlLog)
+	ss.PlanTrial() // sets next action if planning
+	ss.Progress.Trial()
 }
 
 // RunEnd is called at the end of a run -- save weights, record final log, etc here
@@ -824,13 +970,18 @@ func (ss *Sim) RunEnd() {
 func (ss *Sim) NewRun() {
 	run := ss.TrainEnv.Run.Cur
 	ss.PctCortex = 0
+	ss.M1Decode.Reset()
 	ss.TrainEnv.Init(run)
-	// ss.TestEnv.Init(run)
+	ss.TestEnv.Init(run)
 	ss.Time.Reset()
 	ss.InitWts(ss.Net)
 	ss.InitStats()
 	ss.TrnEpcLog.SetNumRows(0)
 	ss.TstEpcLog.SetNumRows(0)
+	ss.TstTrlLog.SetNumRows(0)
+	ss.PlanLog.SetNumRows(0)
+	ss.Planner.Reset()
+	ss.ResetStage()
 	ss.NeedsNewRun = false
 }
 
@@ -898,9 +1049,9 @@ func (ss *Sim) UpdtARFs() {
 		case "Act":
 			mt.Set1D(ss.TrainEnv.Act, 1)
 		case "Ang":
-			mt.Set1D(ss.TrainEnv.Angle/15, 1)
+			mt.Set1D(ss.TrainEnv.AngIdx(ss.TrainEnv.Angle), 1)
 		case "Rot":
-			mt.Set1D(1+ss.TrainEnv.RotAng/15, 1)
+			mt.Set1D(ss.TrainEnv.RotIdx(ss.TrainEnv.RotAng), 1)
 		}
 	}
 
@@ -932,9 +1083,13 @@ func (ss *Sim) UpdtARFs() {
 	}
 }
 
-// SaveAllARFs saves all ARFs to files
+// SaveAllARFs saves all ARFs to files, with the Pos ARFs normalized over
+// the arena
 func (ss *Sim) SaveAllARFs() {
+	ss.ARFs.Avg()
+	ss.ARFs.Norm()
 	for _, paf := range ss.ARFs.RFs {
+		ss.TrainEnv.ArenaNormRF(paf)
 		fnm := ss.LogFileName(paf.Name)
 		etensor.SaveCSV(&paf.NormRF, gi.FileName(fnm), '\t')
 	}
@@ -950,35 +1105,19 @@ func (ss *Sim) TrialStats(accum bool) {
 	if accum {
 		ss.SumActMatch += ss.ActMatch
 		ss.NumTrlStats++
+		ss.UpdtARFs() // training positions only
 	}
-	ss.UpdtARFs()
 	return
 }
 
 // TrainEpoch runs training trials for remainder of this epoch
 func (ss *Sim) TrainEpoch() {
-	ss.StopNow = false
-	curEpc := ss.TrainEnv.Epoch.Cur
-	for {
-		ss.TrainTrial()
-		if ss.StopNow || ss.TrainEnv.Epoch.Cur != curEpc {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepEpoch, 1)
 }
 
 // TrainRun runs training trials for remainder of run
 func (ss *Sim) TrainRun() {
-	ss.StopNow = false
-	curRun := ss.TrainEnv.Run.Cur
-	for {
-		ss.TrainTrial()
-		if ss.StopNow || ss.TrainEnv.Run.Cur != curRun {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepRun, 1)
 }
 
 // TrainSched implements the learning rate schedule etc.
@@ -995,27 +1134,20 @@ func (ss *Sim) TrainSched(epc int) {
 	case 50:
 		ss.ARFs.Reset() // now sufficiently learned to start recording..
 	case 150:
-		ss.Net.LrateMult(0.5)
+		ss.SetLrateMult(0.5)
 		fmt.Printf("dropped lrate 0.5 at epoch: %d\n", epc)
 	case 250:
-		ss.Net.LrateMult(0.2)
+		ss.SetLrateMult(0.2)
 		fmt.Printf("dropped lrate 0.2 at epoch: %d\n", epc)
 	case 350:
-		ss.Net.LrateMult(0.1)
+		ss.SetLrateMult(0.1)
 		fmt.Printf("dropped lrate 0.1 at epoch: %d\n", epc)
 	}
 }
 
 // Train runs the full training from this point onward
 func (ss *Sim) Train() {
-	ss.StopNow = false
-	for {
-		ss.TrainTrial()
-		if ss.StopNow {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepRun, ss.MaxRuns)
 }
 
 // Stop tells the sim to stop running
@@ -1048,35 +1180,41 @@ func (ss *Sim) SaveWeights(filename gi.FileName) {
 
 // TestTrial runs one trial of testing -- always sequentially presented inputs
 func (ss *Sim) TestTrial(returnOnChg bool) {
-	// ss.TestEnv.Step()
-	//
-	// // Query counters FIRST
-	// _, _, chg := ss.TestEnv.Counter(env.Epoch)
-	// if chg {
-	// 	if ss.ViewOn && ss.TestUpdt > leabra.AlphaCycle {
-	// 		ss.UpdateView(false)
-	// 	}
-	// 	ss.LogTstEpc(ss.TstEpcLog)
-	// 	if returnOnChg {
-	// 		return
-	// 	}
-	// }
-	//
-	// ss.ApplyInputs(&ss.TestEnv)
-	// ss.AlphaCyc(false)   // !train
-	// ss.TrialStats(false) // !accumulate
-	// ss.LogTstTrl(ss.TstTrlLog)
+	ss.TestEnv.Step()
+
+	// Query counters FIRST
+	_, _, chg := ss.TestEnv.Counter(env.Epoch)
+	if chg {
+		if ss.ViewOn && ss.TestUpdt > leabra.AlphaCycle {
+			ss.UpdateView(false)
+		}
+		ss.LogTstEpc(ss.TstEpcLog)
+		if returnOnChg {
+			return
+		}
+	}
+
+	ss.ITICycles()
+	ss.ApplyInputs(ss.Net, &ss.TestEnv)
+	ss.AlphaCyc(false)   // !train
+	ss.TrialStats(false) // !accumulate
+	ss.LogTstTrl(ss.TstTrlLog)
 }
 
-// TestAll runs through the full set of testing items
+// TestAll runs one test: TestTrls trials with frozen weights over a fresh
+// trajectory of the TestEnv, from its start location, driven by the
+// reflexive actions, evaluating the predictions (CosDiff) and actions
+// (ActMatch) of the network
 func (ss *Sim) TestAll() {
-	// ss.TestEnv.Init(ss.TrainEnv.Run.Cur)
+	ss.FinishTrainTrial()
+	ss.TestEnv.Trial.Max = ss.TestTrls
+	ss.TestEnv.Init(ss.TrainEnv.Run.Cur)
 	for {
 		ss.TestTrial(true) // return on change -- don't wrap
-		// _, _, chg := ss.TestEnv.Counter(env.Epoch)
-		// if chg || ss.StopNow {
-		// 	break
-		// }
+		_, _, chg := ss.TestEnv.Counter(env.Epoch)
+		if chg || ss.StopNow {
+			break
+		}
 	}
 }
 
@@ -1090,15 +1228,17 @@ func (ss *Sim) RunTestAll() {
 /////////////////////////////////////////////////////////////////////////
 //   Params setting
 
-// ParamsName returns name of current set of parameters
+// ParamsName returns name of current set of parameters, usable in file
+// names (see paramcomp.FileName)
 func (ss *Sim) ParamsName() string {
 	if ss.ParamSet == "" {
 		return "Base"
 	}
-	return ss.ParamSet
+	return paramcomp.FileName(ss.ParamSet)
 }
 
-// SetParams sets the params for "Base" and then current ParamSet.
+// SetParams sets the params for "Base" and then current ParamSet, as
+// composed by ComposeParams.
 // If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
 // otherwise just the named sheet
 // if setMsg = true then we output a message for each param that was set.
@@ -1107,13 +1247,25 @@ func (ss *Sim) SetParams(sheet string, setMsg bool) error {
 		// this is important for catching typos and ensuring that all sheets can be used
 		ss.Params.ValidateSheets([]string{"Network", "Sim"})
 	}
-	err := ss.SetParamsSet("Base", sheet, setMsg)
-	if ss.ParamSet != "" && ss.ParamSet != "Base" {
-		err = ss.SetParamsSet(ss.ParamSet, sheet, setMsg)
+	pset, err := ss.ComposeParams()
+	if err != nil {
+		return err
+	}
+	if sheet == "" && ss.SaveParams != "" {
+		if err := pset.SaveJSON(gi.FileName(ss.SaveParams)); err != nil {
+			log.Println(err)
+		}
 	}
+	err = ss.ApplyParamsSet(pset, sheet, setMsg)
 	return err
 }
 
+// ComposeParams returns the params set composed from Base and the ParamSet
+// spec, with the ParamDeps
+func (ss *Sim) ComposeParams() (*params.Set, error) {
+	return paramcomp.Compose(ss.Params, ParamDeps, ss.ParamSet)
+}
+
 // SetParamsSet sets the params for given params.Set name.
 // If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
 // otherwise just the named sheet
@@ -1123,6 +1275,14 @@ func (ss *Sim) SetParamsSet(setNm string, sheet string, setMsg bool) error {
 	if err != nil {
 		return err
 	}
+	return ss.ApplyParamsSet(pset, sheet, setMsg)
+}
+
+// ApplyParamsSet applies the params of given set.
+// If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
+// otherwise just the named sheet
+// if setMsg = true then we output a message for each param that was set.
+func (ss *Sim) ApplyParamsSet(pset *params.Set, sheet string, setMsg bool) error {
 	if sheet == "" || sheet == "Network" {
 		netp, ok := pset.Sheets["Network"]
 		if ok {
@@ -1138,7 +1298,7 @@ func (ss *Sim) SetParamsSet(setNm string, sheet string, setMsg bool) error {
 	}
 	// note: if you have more complex environments with parameters, definitely add
 	// sheets for them, e.g., "TrainEnv", "TestEnv" etc
-	return err
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -1421,9 +1581,9 @@ func (ss *Sim) ConfigTrnTrlPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 // LogTstTrl adds data from current trial to the TstTrlLog table.
 // log always contains number of testing items
 func (ss *Sim) LogTstTrl(dt *etable.Table) {
-	epc := ss.TrainEnv.Epoch.Prv // this is triggered by increment so use previous value
+	epc := ss.TrainEnv.Epoch.Cur // training epoch at which the test is run
 
-	trl := ss.TrainEnv.Event.Cur
+	trl := ss.TestEnv.Trial.Cur
 	row := trl
 
 	if dt.Rows <= row {
@@ -1433,8 +1593,16 @@ func (ss *Sim) LogTstTrl(dt *etable.Table) {
 	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
 	dt.SetCellFloat("Epoch", row, float64(epc))
 	dt.SetCellFloat("Trial", row, float64(trl))
-	// dt.SetCellString("TrialName", row, ss.TestEnv.String())
+	dt.SetCellString("TrialName", row, ss.TestEnv.String())
+	dt.SetCellFloat("X", row, float64(ss.TestEnv.PosI.X))
+	dt.SetCellFloat("Y", row, float64(ss.TestEnv.PosI.Y))
+	dt.SetCellString("NetAction", row, ss.NetAction)
+	dt.SetCellString("GenAction", row, ss.GenAction)
+	dt.SetCellFloat("ActMatch", row, ss.ActMatch)
 	dt.SetCellFloat("CosDiff", row, ss.TrlCosDiff)
+	for i, lnm := range ss.PulvLays {
+		dt.SetCellFloat(lnm+"_CosDiff", row, float64(ss.TrlCosDiffTRC[i]))
+	}
 
 	for _, lnm := range ss.LayStatNms {
 		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
@@ -1448,7 +1616,9 @@ func (ss *Sim) LogTstTrl(dt *etable.Table) {
 	// dt.SetCellTensor("Targs", row, ss.OutputValsTsr)
 
 	// note: essential to use Go version of update when called from another goroutine
-	ss.TstTrlPlot.GoUpdate()
+	if ss.TstTrlPlot != nil {
+		ss.TstTrlPlot.GoUpdate()
+	}
 }
 
 func (ss *Sim) ConfigTstTrlLog(dt *etable.Table) {
@@ -1457,14 +1627,22 @@ func (ss *Sim) ConfigTstTrlLog(dt *etable.Table) {
 	dt.SetMetaData("read-only", "true")
 	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
 
-	nt := ss.TrainEnv.Event.Prv
+	nt := ss.TestTrls
 	sch := etable.Schema{
 		{"Run", etensor.INT64, nil, nil},
 		{"Epoch", etensor.INT64, nil, nil},
 		{"Trial", etensor.INT64, nil, nil},
 		{"TrialName", etensor.STRING, nil, nil},
+		{"X", etensor.FLOAT64, nil, nil},
+		{"Y", etensor.FLOAT64, nil, nil},
+		{"NetAction", etensor.STRING, nil, nil},
+		{"GenAction", etensor.STRING, nil, nil},
+		{"ActMatch", etensor.FLOAT64, nil, nil},
 		{"CosDiff", etensor.FLOAT64, nil, nil},
 	}
+	for _, lnm := range ss.PulvLays {
+		sch = append(sch, etable.Column{lnm + "_CosDiff", etensor.FLOAT64, nil, nil})
+	}
 	for _, lnm := range ss.LayStatNms {
 		sch = append(sch, etable.Column{lnm + " ActM.Avg", etensor.FLOAT64, nil, nil})
 	}
@@ -1485,7 +1663,15 @@ func (ss *Sim) ConfigTstTrlPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 	plt.SetColParams("Epoch", eplot.Off, true, 0, eplot.FloatMax, 0)
 	plt.SetColParams("Trial", eplot.Off, true, 0, eplot.FloatMax, 0)
 	plt.SetColParams("TrialName", eplot.Off, true, 0, eplot.FloatMax, 0)
+	plt.SetColParams("X", eplot.Off, true, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Y", eplot.Off, true, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NetAction", eplot.Off, true, 0, eplot.FloatMax, 0)
+	plt.SetColParams("GenAction", eplot.Off, true, 0, eplot.FloatMax, 0)
+	plt.SetColParams("ActMatch", eplot.Off, true, 0, true, 1)
 	plt.SetColParams("CosDiff", true, true, 0, true, 1)
+	for _, lnm := range ss.PulvLays {
+		plt.SetColParams(lnm+"_CosDiff", eplot.Off, true, 0, true, 1)
+	}
 
 	for _, lnm := range ss.LayStatNms {
 		plt.SetColParams(lnm+" ActM.Avg", eplot.Off, true, 0, true, .5)
@@ -1506,13 +1692,17 @@ func (ss *Sim) LogTstEpc(dt *etable.Table) {
 
 	trl := ss.TstTrlLog
 	tix := etable.NewIdxView(trl)
-	epc := ss.TrainEnv.Epoch.Prv // ?
+	epc := ss.TrainEnv.Epoch.Cur // training epoch at which the test is run
 
 	// note: this shows how to use agg methods to compute summary data from another
 	// data table, instead of incrementing on the Sim
 	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
 	dt.SetCellFloat("Epoch", row, float64(epc))
+	dt.SetCellFloat("ActMatch", row, agg.Mean(tix, "ActMatch")[0])
 	dt.SetCellFloat("CosDiff", row, agg.Mean(tix, "CosDiff")[0])
+	for _, lnm := range ss.PulvLays {
+		dt.SetCellFloat(lnm+"_CosDiff", row, agg.Mean(tix, lnm+"_CosDiff")[0])
+	}
 
 	// trlix := etable.NewIdxView(trl)
 	// trlix.Filter(func(et *etable.Table, row int) bool {
@@ -1530,7 +1720,15 @@ func (ss *Sim) LogTstEpc(dt *etable.Table) {
 	// ss.TstErrStats = allsp.AggsToTable(false)
 
 	// note: essential to use Go version of update when called from another goroutine
-	ss.TstEpcPlot.GoUpdate()
+	if ss.TstEpcPlot != nil {
+		ss.TstEpcPlot.GoUpdate()
+	}
+	if ss.TstEpcFile != nil {
+		if row == 0 && ss.TrainEnv.Run.Cur == 0 {
+			dt.WriteCSVHeaders(ss.TstEpcFile, etable.Tab)
+		}
+		dt.WriteCSVRow(ss.TstEpcFile, row, etable.Tab)
+	}
 }
 
 func (ss *Sim) ConfigTstEpcLog(dt *etable.Table) {
@@ -1539,11 +1737,16 @@ func (ss *Sim) ConfigTstEpcLog(dt *etable.Table) {
 	dt.SetMetaData("read-only", "true")
 	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
 
-	dt.SetFromSchema(etable.Schema{
+	sch := etable.Schema{
 		{"Run", etensor.INT64, nil, nil},
 		{"Epoch", etensor.INT64, nil, nil},
+		{"ActMatch", etensor.FLOAT64, nil, nil},
 		{"CosDiff", etensor.FLOAT64, nil, nil},
-	}, 0)
+	}
+	for _, lnm := range ss.PulvLays {
+		sch = append(sch, etable.Column{lnm + "_CosDiff", etensor.FLOAT64, nil, nil})
+	}
+	dt.SetFromSchema(sch, 0)
 }
 
 func (ss *Sim) ConfigTstEpcPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
@@ -1553,7 +1756,11 @@ func (ss *Sim) ConfigTstEpcPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 	// order of params: on, fixMin, min, fixMax, max
 	plt.SetColParams("Run", false, true, 0, false, 0)
 	plt.SetColParams("Epoch", false, true, 0, false, 0)
-	plt.SetColParams("CosDiff", false, true, 0, true, 1)
+	plt.SetColParams("ActMatch", true, true, 0, true, 1)
+	plt.SetColParams("CosDiff", true, true, 0, true, 1)
+	for _, lnm := range ss.PulvLays {
+		plt.SetColParams(lnm+"_CosDiff", false, true, 0, true, 1)
+	}
 	return plt
 }
 
@@ -1574,7 +1781,7 @@ func (ss *Sim) LogTstCyc(dt *etable.Table, cyc int) {
 		dt.SetCellFloat(ly.Nm+" Act.Avg", cyc, float64(ly.Pools[0].Inhib.Act.Avg))
 	}
 
-	if cyc%10 == 0 { // too slow to do every cyc
+	if cyc%10 == 0 && ss.TstCycPlot != nil { // too slow to do every cyc
 		// note: essential to use Go version of update when called from another goroutine
 		ss.TstCycPlot.GoUpdate()
 	}
@@ -1853,7 +2060,7 @@ func (ss *Sim) UpdateWorldGui() {
 	}
 
 	nc := len(ss.TrainEnv.Mats)
-	ss.Trace.Set([]int{ss.TrainEnv.PosI.Y, ss.TrainEnv.PosI.X}, nc+ss.TrainEnv.Angle/ss.TrainEnv.AngInc)
+	ss.Trace.Set([]int{ss.TrainEnv.PosI.Y, ss.TrainEnv.PosI.X}, nc+ss.TrainEnv.AngIdx(ss.TrainEnv.Angle))
 
 	updt := ss.WorldTabs.UpdateStart()
 	ss.TraceView.UpdateSig()
@@ -1861,32 +2068,32 @@ func (ss *Sim) UpdateWorldGui() {
 }
 
 func (ss *Sim) Left() {
-	ss.TrainEnv.Action("Left", nil)
+	ss.TrainEnv.ExecAct(NewAction(Left))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Right() {
-	ss.TrainEnv.Action("Right", nil)
+	ss.TrainEnv.ExecAct(NewAction(Right))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Forward() {
-	ss.TrainEnv.Action("Forward", nil)
+	ss.TrainEnv.ExecAct(NewAction(Forward))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Backward() {
-	ss.TrainEnv.Action("Backward", nil)
+	ss.TrainEnv.ExecAct(NewAction(Backward))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Eat() {
-	ss.TrainEnv.Action("Eat", nil)
+	ss.TrainEnv.ExecAct(NewAction(Eat))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Drink() {
-	ss.TrainEnv.Action("Drink", nil)
+	ss.TrainEnv.ExecAct(NewAction(Drink))
 	ss.UpdateWorldGui()
 }
 
@@ -1947,6 +2154,9 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	plt = tv.AddNewTab(eplot.KiT_Plot2D, "RunPlot").(*eplot.Plot2D)
 	ss.RunPlot = ss.ConfigRunPlot(plt, ss.RunLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "PlanPlot").(*eplot.Plot2D)
+	ss.PlanPlot = ss.ConfigPlanPlot(plt, ss.PlanLog)
+
 	split.SetSplits(.3, .7)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -1974,13 +2184,32 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		ss.Stop()
 	})
 
-	tbar.AddAction(gi.ActOpts{Label: "Step Trial", Icon: "step-fwd", Tooltip: "Advances one training trial at a time.", UpdateFunc: func(act *gi.Action) {
+	tbar.AddAction(gi.ActOpts{Label: "Step Cycle", Icon: "step-fwd", Tooltip: "Advances one training cycle at a time.", UpdateFunc: func(act *gi.Action) {
 		act.SetActiveStateUpdt(!ss.IsRunning)
 	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
 		if !ss.IsRunning {
 			ss.IsRunning = true
-			ss.TrainTrial()
-			ss.IsRunning = false
+			ss.TrainSteps(StepCycle, 1)
+			vp.SetNeedsFullRender()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Step Quarter", Icon: "step-fwd", Tooltip: "Advances to the end of the current quarter of the training trial.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			ss.TrainSteps(StepQuarter, 1)
+			vp.SetNeedsFullRender()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Step Trial", Icon: "step-fwd", Tooltip: "Advances one training trial at a time -- or to the end of the current trial, if it was stepped partway through.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			ss.TrainSteps(StepTrial, 1)
 			vp.SetNeedsFullRender()
 		}
 	})
@@ -2019,6 +2248,7 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		ss.ARFs.Avg()
 		ss.ARFs.Norm()
 		for _, paf := range ss.ARFs.RFs {
+			ss.TrainEnv.ArenaNormRF(paf)
 			etview.TensorGridDialog(vp, &paf.NormRF, giv.DlgOpts{Title: "Act RF " + paf.Name, Prompt: paf.Name, TmpSave: nil}, nil, nil)
 		}
 	})
@@ -2163,9 +2393,19 @@ func (ss *Sim) CmdArgs() {
 	ss.NoGui = true
 	var nogui bool
 	var saveEpcLog bool
+	var saveTstEpcLog bool
 	var saveRunLog bool
 	var note string
-	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
+	var bench bool
+	var itiDecay float64
+	var localViewDecay float64
+	var arena string
+	var worldSize string
+	var inputs string
+	var step string
+	var nSteps int
+	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet to use on top of Base -- names of sets as listed in compiled-in params or loaded params, and numeric overrides, separated by +, e.g., LongPlus+Gi=1.6")
+	flag.StringVar(&ss.SaveParams, "save-params", "", "if set, save the fully composed params set applied to this file, as JSON")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
 	flag.StringVar(&note, "note", "", "user note -- describe the run params etc")
 	flag.IntVar(&ss.MaxRuns, "runs", 1, "number of runs to do (note that MaxEpcs is in paramset)")
@@ -2173,10 +2413,57 @@ func (ss *Sim) CmdArgs() {
 	flag.BoolVar(&ss.SaveWts, "wts", false, "if true, save final weights after each run")
 	flag.BoolVar(&ss.SaveARFs, "arfs", false, "if true, save final arfs after each run")
 	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
+	flag.BoolVar(&saveTstEpcLog, "tstepclog", true, "if true, save test epoch log to file")
 	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run epoch log to file")
+	flag.IntVar(&ss.TestInterval, "test-interval", 50000, "run a test every this many training epochs -- 0 = never")
+	flag.IntVar(&ss.TestTrls, "test-trls", 200, "number of trials in each test")
+	flag.StringVar(&step, "step", "", "instead of training all the runs, train -nsteps steps of given grain: Cycle, Quarter, Trial, Epoch or Run")
+	flag.IntVar(&nSteps, "nsteps", 1, "number of -step steps to train")
 	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
 	flag.BoolVar(&ss.UseMPI, "mpi", false, "if set, use MPI for distributed computation")
+	flag.IntVar(&ss.MinusQtrs, "minus-qtrs", 3, "number of quarters in the minus phase")
+	flag.IntVar(&ss.PlusQtrs, "plus-qtrs", 1, "number of quarters in the plus phase")
+	flag.IntVar(&ss.CycPerQtr, "cyc-per-qtr", 25, "number of cycles per quarter")
+	flag.IntVar(&ss.ITI.Cycles, "iti-cycles", 0, "number of blank cycles with all inputs off between trials -- 0 = none")
+	flag.Float64Var(&itiDecay, "iti-decay", 0, "proportion of activation state decayed between trials, before the -iti-cycles -- 0 = none, 1 = full reset")
+	flag.BoolVar(&ss.M1Decode.On, "m1-decode", false, "if true, decode the cortical action from M1 population activity, with prototypes learned from the actions taken, instead of from VL")
+	flag.Float64Var(&ss.PctCortexMax, "pct-cortex-max", 0.9, "maximum proportion of actions driven by the decoded cortical action, reached on the PctCortex schedule")
+	flag.BoolVar(&bench, "bench", false, "if true, run the standard benchmark protocol and append the results to the scoreboard file, instead of the usual runs")
+	flag.BoolVar(&ss.TrainEnv.LocalView.On, "local-view", false, "if true, add the LocV input layer, presenting a memory of the recent foveal snapshots keyed by heading, to PCC")
+	flag.IntVar(&ss.TrainEnv.LocalView.NHeads, "local-view-heads", 8, "number of heading slots in the -local-view memory")
+	flag.Float64Var(&localViewDecay, "local-view-decay", 0.1, "proportion of the -local-view snapshots decayed each step, except the current heading's -- 0 = kept until replaced")
+	flag.StringVar(&arena, "arena", "Rect", "shape of the arena within the outer walls of the generated world: Rect, Circle or LShape")
+	flag.StringVar(&worldSize, "world-size", "", "X,Y size of the world grid, including the outer walls -- empty = 100,100")
+	flag.StringVar(&inputs, "inputs", "", "env states applied to the input layers, as comma-separated State:Layer entries, e.g., Depth:V2Pd,Fovea:V1F,Action:VL -- empty = the defaults for the sim config")
+	flag.BoolVar(&ss.StrictInputs, "strict-inputs", false, "if true, exit at Config if any layer or state of the -inputs does not exist, instead of skipping it")
+	flag.IntVar(&ss.TrainEnv.AngInc, "ang-inc", 15, "head direction resolution: angle increment for rotation, in degrees -- must evenly divide 360, with FOV an even multiple of it")
+	flag.BoolVar(&ss.Planner.On, "plan", false, "if set, use the model-based planner to select actions toward the goal when in view")
+	flag.IntVar(&ss.Planner.Depth, "plan-depth", 3, "number of steps in each imagined action sequence for the planner")
+	flag.IntVar(&ss.Planner.Width, "plan-width", 8, "number of candidate action sequences evaluated by the planner")
+	flag.StringVar(&ss.Bench.File, "bench-file", "emery1_bench.tsv", "scoreboard file for -bench results")
 	flag.Parse()
+	ss.ITI.Decay = float32(itiDecay)
+	ss.TrainEnv.LocalView.Decay = float32(localViewDecay)
+	if err := ss.TrainEnv.Arena.FromString(arena); err != nil {
+		log.Println(err)
+	}
+	if ims, err := ParseInputs(inputs); err != nil {
+		if ss.StrictInputs {
+			log.Fatalln(err)
+		}
+		log.Println(err)
+	} else if len(ims) > 0 {
+		ss.Inputs = ims
+		ss.InputsSet = true
+	}
+	if worldSize != "" {
+		var sz evec.Vec2i
+		if _, err := fmt.Sscanf(worldSize, "%d,%d", &sz.X, &sz.Y); err != nil || sz.X < 20 || sz.Y < 20 {
+			log.Printf("-world-size must be X,Y of at least 20,20: %s\n", worldSize)
+		} else {
+			ss.TrainEnv.Size = sz
+		}
+	}
 	ss.Init()
 
 	if ss.UseMPI {
@@ -2198,6 +2485,11 @@ func (ss *Sim) CmdArgs() {
 		fmt.Printf("Using ParamSet: %s\n", ss.ParamSet)
 	}
 
+	if bench {
+		ss.RunBench()
+		return
+	}
+
 	if saveEpcLog {
 		var err error
 		fnm := ss.LogFileName("epc")
@@ -2210,6 +2502,18 @@ func (ss *Sim) CmdArgs() {
 			defer ss.TrnEpcFile.Close()
 		}
 	}
+	if saveTstEpcLog {
+		var err error
+		fnm := ss.LogFileName("tst_epc")
+		ss.TstEpcFile, err = os.Create(fnm)
+		if err != nil {
+			log.Println(err)
+			ss.TstEpcFile = nil
+		} else {
+			fmt.Printf("Saving test epoch log to: %v\n", fnm)
+			defer ss.TstEpcFile.Close()
+		}
+	}
 	if saveRunLog {
 		var err error
 		fnm := ss.LogFileName("run")
@@ -2225,6 +2529,16 @@ func (ss *Sim) CmdArgs() {
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
+	if step != "" {
+		var grain StepGrains
+		if err := grain.FromString(step); err != nil {
+			log.Println(err)
+			return
+		}
+		fmt.Printf("Running %d %s steps\n", nSteps, grain)
+		ss.TrainSteps(grain, nSteps)
+		return
+	}
 	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
 	ss.Train()
 }
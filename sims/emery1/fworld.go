@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"math"
 	"math/rand"
 	"os"
@@ -30,9 +31,11 @@ type FWorld struct {
 	Nm          string                      `desc:"name of this environment"`
 	Dsc         string                      `desc:"description of this environment"`
 	Disp        bool                        `desc:"update display -- turn off to make it faster"`
-	Size        evec.Vec2i                  `desc:"size of 2D world"`
+	Size        evec.Vec2i                  `desc:"size of 2D world, including the outer walls -- defaults to 100 x 100"`
+	Arena       ArenaShapes                 `desc:"shape of the arena within the outer walls -- cells outside it are walls, and are excluded from the normalization of Pos ARFs"`
 	PatSize     evec.Vec2i                  `desc:"size of patterns for mats, acts"`
 	World       *etensor.Int                `view:"no-inline" desc:"2D grid world, each cell is a material (mat)"`
+	KeepWorld   bool                        `desc:"keep the existing world.tsv instead of generating a new world in Config -- e.g., to use the same world across runs"`
 	Mats        []string                    `desc:"list of materials in the world, 0 = empty.  Any superpositions of states (e.g., CoveredFood) need to be discretely encoded, can be transformed through action rules"`
 	MatMap      map[string]int              `desc:"map of material name to index stored in world cell"`
 	BarrierIdx  int                         `desc:"index of material below which (inclusive) cannot move -- e.g., 1 for wall"`
@@ -43,7 +46,7 @@ type FWorld struct {
 	InterMap    map[string]int              `desc:"map of interoceptive state names to indexes"`
 	Params      map[string]float32          `desc:"map of optional interoceptive and world-dynamic parameters -- cleaner to store in a map"`
 	FOV         int                         `desc:"field of view in degrees, e.g., 180, must be even multiple of AngInc"`
-	AngInc      int                         `desc:"angle increment for rotation, in degrees -- defaults to 15"`
+	AngInc      int                         `desc:"angle increment for rotation, in degrees -- must evenly divide 360, and FOV / AngInc must be even -- defaults to 15 -- determines NFOVRays and NRotAngles"`
 	NRotAngles  int                         `inactive:"+" desc:"total number of rotation angles in a circle"`
 	NFOVRays    int                         `inactive:"+" desc:"total number of FOV rays that are traced"`
 	ShowRays    bool                        `desc:"for debugging only: show the main depth rays as they are traced out from point"`
@@ -53,6 +56,7 @@ type FWorld struct {
 	FoveaAngInc int                         `desc:"scan angle for fovea"`
 	PopSize     int                         `inactive:"+" desc:"number of units in population codes"`
 	PopCode     popcode.OneD                `desc:"population code values, in normalized units"`
+	LocalView   LocalView                   `view:"inline" desc:"memory of the recent foveal snapshots keyed by heading, for local-view-based localization"`
 
 	// current state below (params above)
 	PosF          mat32.Vec2                  `inactive:"+" desc:"current location of agent, floating point"`
@@ -60,6 +64,8 @@ type FWorld struct {
 	Angle         int                         `inactive:"+" desc:"current angle, in degrees"`
 	RotAng        int                         `inactive:"+" desc:"angle that we just rotated -- drives vestibular"`
 	Act           int                         `inactive:"+" desc:"last action taken"`
+	ActExec       Action                      `inactive:"+" desc:"last action executed, with its continuous parameters"`
+	ExtAct        int                         `inactive:"+" desc:"if >= 0, action to take on the next step instead of the decoded or ActGen action -- e.g., set by the planner, reset after each step"`
 	Depths        []float32                   `desc:"depth for each angle (NFOVRays), raw"`
 	DepthLogs     []float32                   `desc:"depth for each angle (NFOVRays), normalized log"`
 	ViewMats      []int                       `inactive:"+" desc:"material at each angle"`
@@ -93,7 +99,7 @@ func (ev *FWorld) Config(ntrls int) {
 	ev.Dsc = "Example world with basic food / water / eat / drink actions"
 	ev.Mats = []string{"Empty", "Wall", "Food", "Water", "FoodWas", "WaterWas"}
 	ev.BarrierIdx = 1
-	ev.Acts = []string{"Stay", "Left", "Right", "Forward", "Backward", "Eat", "Drink"}
+	ev.Acts = ActionNames()
 	ev.Inters = []string{"Energy", "Hydra", "BumpPain", "FoodRew", "WaterRew"}
 
 	ev.Params = make(map[string]float32)
@@ -110,15 +116,26 @@ func (ev *FWorld) Config(ntrls int) {
 	ev.Params["WaterRefresh"] = 50 // time steps before water is refreshed
 
 	ev.Disp = true
-	ev.Size.Set(100, 100)
+	if ev.Size.X == 0 { // allow user override
+		ev.Size.Set(100, 100)
+	}
 	ev.PatSize.Set(5, 5)
-	ev.AngInc = 15
+	if ev.AngInc == 0 { // allow user override
+		ev.AngInc = 15
+	}
 	ev.FOV = 180
+	if err := ev.ValidateAngInc(); err != nil {
+		log.Println(err)
+		ev.AngInc = 15
+	}
 	ev.FoveaSize = 1
 	ev.FoveaAngInc = 5
 	ev.PopSize = 16
 	ev.PopCode.Defaults()
 	ev.PopCode.SetRange(-0.2, 1.2, 0.1)
+	if ev.LocalView.NHeads == 0 { // allow user override
+		ev.LocalView.Defaults()
+	}
 
 	// debugging options:
 	ev.ShowRays = false
@@ -131,8 +148,10 @@ func (ev *FWorld) Config(ntrls int) {
 	ev.ConfigImpl()
 
 	// uncomment to generate a new world
-	ev.GenWorld()
-	ev.SaveWorld("world.tsv")
+	if !ev.KeepWorld {
+		ev.GenWorld()
+		ev.SaveWorld("world.tsv")
+	}
 }
 
 // ConfigPats configures the bit pattern representations of mats and acts
@@ -198,6 +217,8 @@ func (ev *FWorld) ConfigImpl() {
 	av.SetShape([]int{ev.PatSize.Y, ev.PatSize.X}, nil, []string{"Y", "X"})
 	ev.NextStates["Action"] = av
 
+	ev.ConfigLocalView()
+
 	ev.CopyNextToCur() // get CurStates from NextStates
 
 	ev.FovMats = make([]int, fsz)
@@ -234,13 +255,46 @@ func (ev *FWorld) Validate() error {
 	if ev.Size.IsNil() {
 		return fmt.Errorf("FWorld: %v has size == 0 -- need to Config", ev.Nm)
 	}
+	return ev.ValidateAngInc()
+}
+
+// ValidateAngInc returns an error if AngInc is not a positive divisor of 360,
+// or FOV is not an even multiple of it
+func (ev *FWorld) ValidateAngInc() error {
+	if ev.AngInc <= 0 || 360%ev.AngInc != 0 {
+		return fmt.Errorf("FWorld: %v AngInc: %d must be a positive divisor of 360", ev.Nm, ev.AngInc)
+	}
+	if (ev.FOV/ev.AngInc)%2 != 0 || ev.FOV%ev.AngInc != 0 {
+		return fmt.Errorf("FWorld: %v FOV: %d must be an even multiple of AngInc: %d", ev.Nm, ev.FOV, ev.AngInc)
+	}
 	return nil
 }
 
+// AngIdx returns the index of given angle among the NRotAngles angles,
+// rounding to the nearest AngInc
+func (ev *FWorld) AngIdx(ang int) int {
+	ai := int(mat32.Round(float32(AngMod(ang)) / float32(ev.AngInc)))
+	return ints.MinInt(ints.MaxInt(ai, 0), ev.NRotAngles-1)
+}
+
+// RotIdx returns the index of given rotation among the 3 rotations:
+// -AngInc, 0, +AngInc, rounding to the nearest AngInc
+func (ev *FWorld) RotIdx(rot int) int {
+	ri := 1 + int(mat32.Round(float32(rot)/float32(ev.AngInc)))
+	return ints.MinInt(ints.MaxInt(ri, 0), 2)
+}
+
 func (ev *FWorld) State(element string) etensor.Tensor {
 	return ev.CurStates[element]
 }
 
+// HasState returns true if the env has a state of given name, available
+// from Config on, before the first Step
+func (ev *FWorld) HasState(element string) bool {
+	_, ok := ev.NextStates[element]
+	return ok
+}
+
 // String returns the current state as a string
 func (ev *FWorld) String() string {
 	return fmt.Sprintf("Evt_%d_Pos_%d_%d_Ang_%d_Act_%s", ev.Event.Cur, ev.PosI.X, ev.PosI.Y, ev.Angle, ev.Acts[ev.Act])
@@ -265,7 +319,7 @@ func (ev *FWorld) Init(run int) {
 	ev.Tick.Cur = -1
 	ev.Event.Cur = -1
 
-	ev.PosI = ev.Size.DivScalar(2) // start in middle -- could be random..
+	ev.PosI = ev.StartPos() // start in middle of arena -- could be random..
 	ev.PosF = ev.PosI.ToVec2()
 	for i := 0; i < 4; i++ {
 		ev.ProxMats[i] = 0
@@ -273,12 +327,15 @@ func (ev *FWorld) Init(run int) {
 
 	ev.Angle = 0
 	ev.RotAng = 0
+	ev.ExtAct = -1
 	ev.InterStates["Energy"] = 1
 	ev.InterStates["Hydra"] = 1
 	ev.InterStates["BumpPain"] = 0
 	ev.InterStates["FoodRew"] = 0
 	ev.InterStates["WaterRew"] = 0
 
+	ev.InitLocalView()
+
 	ev.RefreshEvents = make(map[int]*WEvent)
 	ev.AllEvents = make(map[int]*WEvent)
 }
@@ -588,13 +645,27 @@ func (ev *FWorld) RefreshWorld() {
 	}
 }
 
-// TakeAct takes the action, updates state
+// TakeAct takes the given discrete action, updates state
 func (ev *FWorld) TakeAct(act int) {
-	as := ""
-	if act >= len(ev.Acts) || act < 0 {
-		as = "Stay"
-	} else {
-		as = ev.Acts[act]
+	ev.ExecAct(NewAction(Actions(act)))
+}
+
+// ExecAct executes the given action, updates state -- an invalid action is
+// a Stay
+func (ev *FWorld) ExecAct(ac Action) {
+	if ac.Act < 0 || ac.Act >= ActionsN {
+		ac = NewAction(Stay)
+	}
+	ev.ActExec = ac
+	ev.Act = int(ac.Act)
+	act := ev.Act
+	turn := ev.AngInc
+	if ac.Turn != 0 {
+		turn = int(mat32.Round(ac.Turn))
+	}
+	step := 1
+	if ac.Step != 0 {
+		step = ints.MaxInt(1, int(mat32.Round(ac.Step)))
 	}
 	ev.PassTime()
 
@@ -602,8 +673,7 @@ func (ev *FWorld) TakeAct(act int) {
 
 	nmat := len(ev.Mats)
 	frmat := ints.MinInt(ev.ProxMats[0], nmat)
-	behmat := ev.ProxMats[3] // behind
-	front := ev.Mats[frmat]  // state in front
+	front := ev.Mats[frmat] // state in front
 
 	mvc := ev.Params["MoveCost"]
 	rotc := ev.Params["RotCost"]
@@ -612,39 +682,35 @@ func (ev *FWorld) TakeAct(act int) {
 	ecost := float32(0) // extra energy cost
 	hcost := float32(0) // extra hydra cost
 
-	switch as {
-	case "Stay":
-	case "Left":
-		ev.RotAng = ev.AngInc
+	switch ac.Act {
+	case Stay:
+	case Left:
+		ev.RotAng = turn
 		ev.Angle = AngMod(ev.Angle + ev.RotAng)
 		ecost = rotc
 		hcost = rotc
-	case "Right":
-		ev.RotAng = -ev.AngInc
+	case Right:
+		ev.RotAng = -turn
 		ev.Angle = AngMod(ev.Angle + ev.RotAng)
 		ecost = rotc
 		hcost = rotc
-	case "Forward":
+	case Forward:
 		ecost = mvc
 		hcost = mvc
-		if frmat > 0 && frmat <= ev.BarrierIdx {
+		if ev.MoveStep(ev.Angle, step) {
 			ev.InterStates["BumpPain"] = 1
 			ecost += bumpc
 			hcost += bumpc
-		} else {
-			ev.PosF, ev.PosI = NextVecPoint(ev.PosF, AngVec(ev.Angle))
 		}
-	case "Backward":
+	case Backward:
 		ecost = mvc
 		hcost = mvc
-		if behmat > 0 && behmat <= ev.BarrierIdx {
+		if ev.MoveStep(AngMod(ev.Angle+180), step) {
 			ev.InterStates["BumpPain"] = 1
 			ecost += bumpc
 			hcost += bumpc
-		} else {
-			ev.PosF, ev.PosI = NextVecPoint(ev.PosF, AngVec(AngMod(ev.Angle+180)))
 		}
-	case "Eat":
+	case Eat:
 		if front == "Food" {
 			ev.InterStates["FoodRew"] = 1
 			hcost += ev.Params["EatCost"]
@@ -654,7 +720,7 @@ func (ev *FWorld) TakeAct(act int) {
 			ev.Event.Set(0)
 			ev.Scene.Incr()
 		}
-	case "Drink":
+	case Drink:
 		if front == "Water" {
 			ev.InterStates["WaterRew"] = 1
 			ecost += ev.Params["DrinkCost"]
@@ -675,6 +741,21 @@ func (ev *FWorld) TakeAct(act int) {
 	ev.RenderState()
 }
 
+// MoveStep moves by step grid cells along given angle, checking every cell
+// along the step, and stopping before the first barrier -- returns true if
+// the step was blocked before its full length
+func (ev *FWorld) MoveStep(ang int, step int) bool {
+	v := AngVec(ang)
+	for i := 0; i < step; i++ {
+		pf, gp := NextVecPoint(ev.PosF, v)
+		if mat := ev.GetWorld(gp); mat > 0 && mat <= ev.BarrierIdx {
+			return true
+		}
+		ev.PosF, ev.PosI = pf, gp
+	}
+	return false
+}
+
 // RenderView renders the current view state to NextStates tensor input states
 func (ev *FWorld) RenderView() {
 	dv := ev.NextStates["Depth"]
@@ -727,7 +808,7 @@ func (ev *FWorld) RenderInters() {
 // RenderVestib renders vestibular state
 func (ev *FWorld) RenderVestibular() {
 	vs := ev.NextStates["Vestibular"]
-	nv := 0.5*(float32(-ev.RotAng)/15) + 0.5
+	nv := 0.5*(float32(-ev.RotAng)/float32(ev.AngInc)) + 0.5
 	ev.PopCode.Encode(&vs.Values, nv, ev.PopSize, false)
 }
 
@@ -746,6 +827,7 @@ func (ev *FWorld) RenderAction() {
 // RenderState renders the current state into NextState vars
 func (ev *FWorld) RenderState() {
 	ev.RenderView()
+	ev.RenderLocalView()
 	ev.RenderProxSoma()
 	ev.RenderInters()
 	ev.RenderVestibular()
@@ -779,13 +861,12 @@ func (ev *FWorld) Step() bool {
 }
 
 func (ev *FWorld) Action(action string, nop etensor.Tensor) {
-	a, ok := ev.ActMap[action]
+	a, ok := ActionFromString(action)
 	if !ok {
 		fmt.Printf("Action not recognized: %s\n", action)
 		return
 	}
-	ev.Act = a
-	ev.TakeAct(ev.Act)
+	ev.ExecAct(NewAction(a))
 }
 
 func (ev *FWorld) Counter(scale env.TimeScales) (cur, prv int, chg bool) {
@@ -929,29 +1010,33 @@ func (ev *FWorld) WorldRect(st, ed evec.Vec2i, mat int) {
 	ev.WorldLineVert(evec.Vec2i{ed.X, st.Y}, evec.Vec2i{ed.X, ed.Y}, mat)
 }
 
-// GenWorld generates a world -- edit to create in way desired
+// GenWorld generates a world -- edit to create in way desired.
+// The layout is for a 100 x 100 world, scaled to the Size, with the cells
+// outside the Arena shape walled off.
 func (ev *FWorld) GenWorld() {
 	wall := ev.MatMap["Wall"]
 	food := ev.MatMap["Food"]
 	water := ev.MatMap["Water"]
+	pt := func(x, y int) evec.Vec2i { return evec.Vec2i{x * ev.Size.X / 100, y * ev.Size.Y / 100} }
 	ev.World.SetZeros()
 	// always start with a wall around the entire world -- no seeing the turtles..
 	ev.WorldRect(evec.Vec2i{0, 0}, evec.Vec2i{ev.Size.X - 1, ev.Size.Y - 1}, wall)
-	ev.WorldRect(evec.Vec2i{20, 20}, evec.Vec2i{40, 40}, wall)
-	ev.WorldRect(evec.Vec2i{60, 60}, evec.Vec2i{80, 80}, wall)
+	ev.WorldRect(pt(20, 20), pt(40, 40), wall)
+	ev.WorldRect(pt(60, 60), pt(80, 80), wall)
 
-	ev.WorldLine(evec.Vec2i{60, 20}, evec.Vec2i{80, 40}, wall) // double-thick lines = no leak
-	ev.WorldLine(evec.Vec2i{60, 19}, evec.Vec2i{80, 39}, wall)
+	ev.WorldLine(pt(60, 20), pt(80, 40), wall) // double-thick lines = no leak
+	ev.WorldLine(pt(60, 20).Sub(evec.Vec2i{0, 1}), pt(80, 40).Sub(evec.Vec2i{0, 1}), wall)
+	ev.ApplyArena(wall)
 
-	// don't put anything in center starting point
-	ctr := ev.Size.DivScalar(2)
-	ev.SetWorld(ctr, wall)
+	// don't put anything in the starting point
+	st := ev.StartPos()
+	ev.SetWorld(st, wall)
 
 	ev.WorldRandom(50, food)
 	ev.WorldRandom(50, water)
 
-	// clear center
-	ev.SetWorld(ctr, 0)
+	// clear start
+	ev.SetWorld(st, 0)
 }
 
 ////////////////////////////////////////////////////////////////////
@@ -971,9 +1056,9 @@ func (ev *FWorld) ActGen() int {
 	wall := ev.MatMap["Wall"]
 	food := ev.MatMap["Food"]
 	water := ev.MatMap["Water"]
-	left := ev.ActMap["Left"]
-	right := ev.ActMap["Right"]
-	eat := ev.ActMap["Eat"]
+	left := int(Left)
+	right := int(Right)
+	eat := int(Eat)
 
 	nmat := len(ev.Mats)
 	frmat := ints.MinInt(ev.ProxMats[0], nmat)
@@ -1046,7 +1131,7 @@ func (ev *FWorld) ActGen() int {
 	lastact := ev.Act
 	frnd := rand.Float32()
 
-	act := ev.ActMap["Forward"] // default
+	act := int(Forward) // default
 	switch {
 	case frmat == wall:
 		if lastact == left || lastact == right {
@@ -1057,10 +1142,10 @@ func (ev *FWorld) ActGen() int {
 			ev.ActGenTrace(fmt.Sprintf("at wall, rlp: %s, turn", rlps), act)
 		}
 	case frmat == food:
-		act = ev.ActMap["Eat"]
+		act = int(Eat)
 		ev.ActGenTrace("at food", act)
 	case frmat == water:
-		act = ev.ActMap["Drink"]
+		act = int(Drink)
 		ev.ActGenTrace("at water", act)
 	case fwt > wwt:
 		wts := fmt.Sprintf("fwt: %g > wwt: %g, dist: %g", fwt, wwt, fdp)
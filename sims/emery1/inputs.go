@@ -0,0 +1,96 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// InputMap maps an env state to the layer it is applied to by ApplyInputs
+type InputMap struct {
+	State string `desc:"name of the env state"`
+	Layer string `desc:"name of the layer the state is applied to"`
+}
+
+// DefaultInputs returns the Inputs for the current sim config: the depth,
+// fovea, somatosensory, vestibular, interoceptive and action states, with
+// the LocV layer if the LocalView is on
+func (ss *Sim) DefaultInputs() []InputMap {
+	ims := []InputMap{
+		{"Depth", "V2Pd"},
+		{"FovDepth", "V2Fd"},
+		{"Fovea", "V1F"},
+		{"ProxSoma", "S1S"},
+		{"Vestibular", "S1V"},
+		{"Inters", "Ins"},
+		{"Action", "VL"},
+	}
+	if ss.TrainEnv.LocalView.On {
+		ims = append(ims, InputMap{"LocalView", "LocV"})
+	}
+	return ims
+}
+
+// ParseInputs parses the Inputs from a comma-separated list of State:Layer
+// entries, e.g., Depth:V2Pd,Fovea:V1F,Action:VL
+func ParseInputs(s string) ([]InputMap, error) {
+	var ims []InputMap
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		fs := strings.Split(e, ":")
+		if len(fs) != 2 || fs[0] == "" || fs[1] == "" {
+			return nil, fmt.Errorf("Inputs: %q must be State:Layer", e)
+		}
+		ims = append(ims, InputMap{State: fs[0], Layer: fs[1]})
+	}
+	return ims, nil
+}
+
+// ValidateInputs checks that every layer and state of the Inputs exists,
+// and that no layer gets more than one state, returning an error listing
+// all the problems found
+func (ss *Sim) ValidateInputs() error {
+	var errs []string
+	lays := make(map[string]bool)
+	for _, im := range ss.Inputs {
+		if ss.Net.LayerByName(im.Layer) == nil {
+			errs = append(errs, fmt.Sprintf("layer %s not found", im.Layer))
+		}
+		if !ss.TrainEnv.HasState(im.State) {
+			errs = append(errs, fmt.Sprintf("state %s of layer %s not found", im.State, im.Layer))
+		}
+		if lays[im.Layer] {
+			errs = append(errs, fmt.Sprintf("layer %s mapped more than once", im.Layer))
+		}
+		lays[im.Layer] = true
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Inputs: %s", strings.Join(errs, "; "))
+}
+
+// ConfigInputs sets the DefaultInputs for the current sim config unless
+// the Inputs were set by the user (InputsSet), and validates them, exiting
+// if StrictInputs, or else reporting the mappings that ApplyInputs will
+// skip -- called in Config after the env and network
+func (ss *Sim) ConfigInputs() {
+	if !ss.InputsSet || len(ss.Inputs) == 0 {
+		ss.Inputs = ss.DefaultInputs()
+	}
+	err := ss.ValidateInputs()
+	if err == nil {
+		return
+	}
+	if ss.StrictInputs {
+		log.Fatalln(err)
+	}
+	log.Printf("%v -- skipped by ApplyInputs\n", err)
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// ITI is an inter-trial interval of blank cycles run with all inputs off,
+// after decaying activation state by Decay, so that the temporal continuity
+// of activity across trials is explicit and controllable.  Note that
+// AlphaCycInit at the start of each trial also decays state by the layer
+// Act.Init.Decay param, so for activity to carry over through the interval,
+// that must be less than 1.
+type ITI struct {
+	Cycles int     `min:"0" desc:"number of blank cycles run between trials with all inputs off -- 0 = none"`
+	Decay  float32 `min:"0" max:"1" desc:"proportion of activation state decayed at the start of the interval -- 0 = none, 1 = full reset"`
+
+	Zeros map[string]etensor.Tensor `view:"-" desc:"zero patterns applied to input layers during the interval, per layer"`
+}
+
+// On returns true if there is an interval to run
+func (it *ITI) On() bool {
+	return it.Cycles > 0 || it.Decay > 0
+}
+
+// ITICycles runs the inter-trial interval, if On -- called in TrainTrial
+// before ApplyInputs for the next trial
+func (ss *Sim) ITICycles() {
+	it := &ss.ITI
+	if !it.On() {
+		return
+	}
+	if it.Zeros == nil {
+		it.Zeros = make(map[string]etensor.Tensor)
+	}
+	for _, lyi := range ss.Net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		if ly.IsOff() {
+			continue
+		}
+		if ly.Type() != emer.Input {
+			ly.InitExt()
+			continue
+		}
+		zs, ok := it.Zeros[ly.Nm]
+		if !ok {
+			zs = etensor.NewFloat32(ly.Shape().Shapes(), nil, nil)
+			it.Zeros[ly.Nm] = zs
+		}
+		ly.ApplyExt(zs)
+	}
+	if it.Decay > 0 {
+		ss.Net.DecayState(it.Decay)
+	}
+	ss.Time.PlusPhase = false
+	for cyc := 0; cyc < it.Cycles; cyc++ {
+		ss.Net.Cycle(&ss.Time)
+		ss.Time.CycleInc()
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/etable/etensor"
+)
+
+// LocalView is a memory of the recent foveal snapshots keyed by heading, to
+// test models of local-view-based localization against path integration: a
+// ring buffer of NHeads slots, one per heading sector of 360 / NHeads
+// degrees, where each step the Fovea snapshot is stored in the slot of the
+// current heading, replacing the prior one, and all the other slots decay by
+// Decay, so the slots reflect how recently each heading was viewed.  It is
+// rendered as the LocalView state, a [NHeads, FoveaSize, Y, X] tensor with
+// one pool per heading and foveal position, presented by the LocV input
+// layer.
+type LocalView struct {
+	On     bool    `desc:"render the LocalView state, presented by the LocV input layer"`
+	NHeads int     `def:"8" min:"1" desc:"number of heading slots in the ring, each covering 360 / NHeads degrees"`
+	Decay  float32 `def:"0.1" min:"0" max:"1" desc:"proportion of the snapshots in the other slots decayed each step -- 0 = kept until replaced"`
+}
+
+// Defaults sets default params
+func (lv *LocalView) Defaults() {
+	lv.NHeads = 8
+	lv.Decay = 0.1
+}
+
+// HeadSlot returns the slot of the ring for given heading, in degrees
+func (lv *LocalView) HeadSlot(ang int) int {
+	return ((ang%360 + 360) % 360) * lv.NHeads / 360
+}
+
+// ConfigLocalView configures the LocalView state, if On -- called in Config
+func (ev *FWorld) ConfigLocalView() {
+	lv := &ev.LocalView
+	if !lv.On {
+		return
+	}
+	fsz := 1 + 2*ev.FoveaSize
+	ls := &etensor.Float32{}
+	ls.SetShape([]int{lv.NHeads, fsz, ev.PatSize.Y, ev.PatSize.X}, nil, []string{"Head", "Angle", "Y", "X"})
+	ev.NextStates["LocalView"] = ls
+}
+
+// InitLocalView clears the snapshots of the LocalView -- called in Init
+func (ev *FWorld) InitLocalView() {
+	if ls, ok := ev.NextStates["LocalView"]; ok {
+		ls.SetZeros()
+	}
+}
+
+// RenderLocalView decays the snapshots of the LocalView, and stores the
+// current Fovea snapshot in the slot of the current heading -- called in
+// RenderState, after RenderView
+func (ev *FWorld) RenderLocalView() {
+	ls, ok := ev.NextStates["LocalView"]
+	if !ok {
+		return
+	}
+	lv := &ev.LocalView
+	if lv.Decay > 0 {
+		for i := range ls.Values {
+			ls.Values[i] *= 1 - lv.Decay
+		}
+	}
+	sv := ls.SubSpace([]int{lv.HeadSlot(ev.Angle)}).(*etensor.Float32)
+	sv.CopyFrom(ev.NextStates["Fovea"])
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/metric"
+	"github.com/emer/leabra/deep"
+	"github.com/emer/leabra/leabra"
+)
+
+// M1Decode decodes the cortical action from the population activity of the
+// M1 layer, instead of the VL action layer, so that actions are read out of
+// motor cortex itself.  M1 has no fixed action code, so a prototype ActM
+// pattern is learned for each action, as the running average of the M1 ActM
+// on the training trials on which that action was taken, and the decoded
+// action is the one whose prototype has the highest correlation with the
+// current M1 ActM.  Until any prototype has been learned, the VL decoding
+// is used.  As with VL, the decoded action drives behavior on a PctCortex
+// proportion of training trials, closing the sensorimotor loop.
+type M1Decode struct {
+	On    bool    `desc:"decode the cortical action from M1 instead of VL"`
+	Lrate float32 `def:"0.05" min:"0" max:"1" desc:"rate of update of the prototype of the action taken toward the M1 ActM, on each training trial"`
+
+	Protos map[string]*etensor.Float32 `view:"-" desc:"prototype M1 ActM of each action, by action name"`
+}
+
+// Defaults sets default params
+func (md *M1Decode) Defaults() {
+	md.Lrate = 0.05
+}
+
+// Reset clears the learned prototypes -- called in NewRun
+func (md *M1Decode) Reset() {
+	md.Protos = nil
+}
+
+// M1DecodeAct decodes the M1 ActM state to the action with the closest
+// prototype, returning false if there are no prototypes yet.  The actions
+// are scanned in the order of the env Acts, so ties go to the first one.
+func (ss *Sim) M1DecodeAct(net *deep.Network, ev *FWorld) (int, bool) {
+	md := &ss.M1Decode
+	ly := net.LayerByName("M1").(leabra.LeabraLayer).AsLeabra()
+	vt := ss.ValsTsr("M1")
+	ly.UnitValsTensor(vt, "ActM")
+	act := -1
+	dst := float32(0)
+	for ai, nm := range ev.Acts {
+		pt, ok := md.Protos[nm]
+		if !ok {
+			continue
+		}
+		d := metric.Correlation32(vt.Values, pt.Values)
+		if act < 0 || d > dst {
+			act = ai
+			dst = d
+		}
+	}
+	return act, act >= 0
+}
+
+// M1Learn updates the prototype of the action taken toward the M1 ActM
+// recorded by M1DecodeAct -- called in TakeAction on training trials
+func (ss *Sim) M1Learn(act string) {
+	md := &ss.M1Decode
+	vt := ss.ValsTsr("M1")
+	if md.Protos == nil {
+		md.Protos = make(map[string]*etensor.Float32)
+	}
+	pt, ok := md.Protos[act]
+	if !ok {
+		md.Protos[act] = vt.Clone().(*etensor.Float32)
+		return
+	}
+	for i, v := range vt.Values {
+		pt.Values[i] += md.Lrate * (v - pt.Values[i])
+	}
+}
@@ -4,7 +4,10 @@
 
 package main
 
-import "github.com/emer/emergent/params"
+import (
+	"github.com/ccnlab/map-nav/sims/paramcomp"
+	"github.com/emer/emergent/params"
+)
 
 // ParamSets is the default set of parameters -- Base is always applied, and others can be optionally
 // selected to apply on top of that
@@ -168,7 +171,7 @@ var ParamSets = params.Sets{
 				}},
 		},
 	}},
-	{Name: "WtBalOn", Desc: "try with weight bal on", Sheets: params.Sheets{
+	{Name: "WtBalOn", Desc: "try with weight bal on, instead of momentum and normalization", Sheets: params.Sheets{
 		"Network": &params.Sheet{
 			{Sel: "Prjn", Desc: "weight bal on",
 				Params: params.Params{
@@ -176,4 +179,18 @@ var ParamSets = params.Sets{
 				}},
 		},
 	}},
+	{Name: "LongPlus", Desc: "extra-long plus phase, for prediction-horizon experiments", Sheets: params.Sheets{
+		"Sim": &params.Sheet{
+			{Sel: "Sim", Desc: "two quarters of plus phase",
+				Params: params.Params{
+					"Sim.PlusQtrs": "2",
+				}},
+		},
+	}},
+}
+
+// ParamDeps are the sets that each of the ParamSets builds on, applied
+// before it when it is used in the ParamSet spec
+var ParamDeps = paramcomp.Deps{
+	"WtBalOn": {"NoMomentum"},
 }
@@ -0,0 +1,428 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/deep"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/mat32"
+)
+
+////////////////////////////////////////////////////////////////////
+// Env: goal in view
+
+// GoalRay returns the index of the depth ray that hits the closest
+// instance of given material in the current view, and its normalized
+// log depth -- ray is -1 if the material is not in view
+func (ev *FWorld) GoalRay(mat int) (ray int, depth float32) {
+	ray = -1
+	depth = 1
+	for i, m := range ev.ViewMats {
+		if m == mat && ev.DepthLogs[i] < depth {
+			ray = i
+			depth = ev.DepthLogs[i]
+		}
+	}
+	return
+}
+
+// RayPool returns the pool of a layer with npool pools along the rays
+// (e.g., MSTdP) that is driven by given depth ray, as in the TRC driver
+// mapping from the NFOVRays pools of V2Pd
+func (ev *FWorld) RayPool(ray, npool int) int {
+	r := float64(ev.NFOVRays) / float64(npool)
+	for p := 0; p < npool; p++ {
+		if ray < int(math.Round(float64(p+1)*r)) {
+			return p
+		}
+	}
+	return npool - 1
+}
+
+// RayAfterAct returns the index of the ray that a point seen along given ray
+// will be on after the given action: rotations shift the view by one ray
+// (rays go from left to right), other actions keep it on the same ray.
+// Returns -1 if the point is rotated out of view.
+func (ev *FWorld) RayAfterAct(ray, act int) int {
+	switch Actions(act) {
+	case Left:
+		ray++
+	case Right:
+		ray--
+	}
+	if ray < 0 || ray >= ev.NFOVRays {
+		return -1
+	}
+	return ray
+}
+
+////////////////////////////////////////////////////////////////////
+// Sim: planner
+
+// Planner selects actions toward a goal by imagining the outcomes of candidate
+// action sequences with the learned forward model: starting from the current
+// depth view, the network is run through imagined trials without learning,
+// with each action of the sequence applied to VL as in TakeAction, and the
+// depth predicted by MSTdP at the end of the minus phase of the next trial fed
+// back as the V2Pd input.  The predicted depth along the ray on which the goal
+// was seen is the predicted distance to the goal, and the first action of the
+// sequence with the smallest final predicted distance is taken (receding horizon).
+// The activation state of the network is restored after the rollouts.
+// Planning is active while a goal is in view -- otherwise the usual cortical /
+// subcortical action is used -- and a goal episode succeeds when the goal is
+// directly in front.
+type Planner struct {
+	On       bool             `desc:"use the planner to select actions toward the goal when it is in view, instead of the decoded cortical or subcortical ActGen action"`
+	Depth    int              `def:"3" min:"1" desc:"number of steps in each imagined action sequence"`
+	Width    int              `def:"8" min:"1" desc:"number of candidate action sequences evaluated for each plan"`
+	MaxSteps int              `def:"50" desc:"maximum number of steps in a goal episode before it counts as a failure"`
+	Goal     string           `def:"Food" desc:"material that is the goal of navigation"`
+	Acts     []Actions        `desc:"actions that can be used in plans"`
+	Plan     []int            `inactive:"+" desc:"best action sequence from the last plan"`
+	PlanDist float32          `inactive:"+" desc:"predicted normalized log distance to goal at the end of the best plan"`
+	Active   bool             `inactive:"+" desc:"a goal episode is in progress"`
+	Steps    int              `inactive:"+" desc:"number of steps in the current goal episode"`
+	NPlans   int              `inactive:"+" desc:"number of steps in the current goal episode where the plan was used (goal in view)"`
+	StartDst float32          `inactive:"+" desc:"normalized log distance to goal at the start of the current goal episode"`
+	Pending  bool             `view:"-" desc:"a prediction for the last step is waiting to be compared with the actual outcome"`
+	PredRay  int              `view:"-" desc:"ray on which the goal is predicted to be after the last step"`
+	PredDst  float32          `view:"-" desc:"predicted normalized log distance to goal after the last step"`
+	SumErr   float64          `view:"-" desc:"sum of absolute prediction errors for the current goal episode"`
+	NErr     int              `view:"-" desc:"number of prediction errors summed"`
+	NEpisode int              `inactive:"+" desc:"number of goal episodes completed in this run"`
+	NSuccess int              `inactive:"+" desc:"number of successful goal episodes in this run"`
+	Time     leabra.Time      `view:"-" desc:"separate timing state for imagined trials, so the main Time is unaffected"`
+	State    NetState         `view:"-" desc:"activation state of the network saved before the rollouts"`
+	InTsr    *etensor.Float32 `view:"-" desc:"input depth for the imagined trial"`
+	OutTsr   *etensor.Float32 `view:"-" desc:"predicted depth from the imagined trial"`
+}
+
+func (pl *Planner) Defaults() {
+	pl.Depth = 3
+	pl.Width = 8
+	pl.MaxSteps = 50
+	pl.Goal = "Food"
+	pl.Acts = []Actions{Left, Right, Forward}
+	pl.Time.Defaults()
+}
+
+// Reset resets the goal episode state and success counts, for a new run
+func (pl *Planner) Reset() {
+	pl.Active = false
+	pl.Pending = false
+	pl.NEpisode = 0
+	pl.NSuccess = 0
+}
+
+// SuccessRate returns the proportion of successful goal episodes in this run
+func (pl *Planner) SuccessRate() float64 {
+	if pl.NEpisode == 0 {
+		return 0
+	}
+	return float64(pl.NSuccess) / float64(pl.NEpisode)
+}
+
+// PredErr returns the mean absolute error of the predicted distance to goal
+// for the current goal episode
+func (pl *Planner) PredErr() float64 {
+	if pl.NErr == 0 {
+		return 0
+	}
+	return pl.SumErr / float64(pl.NErr)
+}
+
+// NetState is a copy of the activation state of a deep network: the
+// neurons and pools of every layer, and the deep context and burst state
+type NetState struct {
+	Neurons [][]leabra.Neuron    `view:"-" desc:"neurons of each layer"`
+	Pools   [][]leabra.Pool      `view:"-" desc:"pools of each layer"`
+	CtxtGes [][]float32          `view:"-" desc:"context conductances of each CT layer (nil for others)"`
+	Supers  [][]deep.SuperNeuron `view:"-" desc:"super neuron state of each superficial layer (nil for others)"`
+}
+
+// Save saves the activation state of given network
+func (ns *NetState) Save(net *deep.Network) {
+	nl := len(net.Layers)
+	if len(ns.Neurons) != nl {
+		ns.Neurons = make([][]leabra.Neuron, nl)
+		ns.Pools = make([][]leabra.Pool, nl)
+		ns.CtxtGes = make([][]float32, nl)
+		ns.Supers = make([][]deep.SuperNeuron, nl)
+	}
+	for li, lyi := range net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		ns.Neurons[li] = append(ns.Neurons[li][:0], ly.Neurons...)
+		ns.Pools[li] = append(ns.Pools[li][:0], ly.Pools...)
+		switch dl := lyi.(type) {
+		case *deep.CTLayer:
+			ns.CtxtGes[li] = append(ns.CtxtGes[li][:0], dl.CtxtGes...)
+		case *deep.SuperLayer:
+			ns.Supers[li] = append(ns.Supers[li][:0], dl.SuperNeurs...)
+		}
+	}
+}
+
+// Restore restores the activation state of given network, as saved by Save
+func (ns *NetState) Restore(net *deep.Network) {
+	for li, lyi := range net.Layers {
+		ly := lyi.(leabra.LeabraLayer).AsLeabra()
+		copy(ly.Neurons, ns.Neurons[li])
+		copy(ly.Pools, ns.Pools[li])
+		switch dl := lyi.(type) {
+		case *deep.CTLayer:
+			copy(dl.CtxtGes, ns.CtxtGes[li])
+		case *deep.SuperLayer:
+			copy(dl.SuperNeurs, ns.Supers[li])
+		}
+	}
+}
+
+// PlanTrial runs the planner after each training trial: updates the goal
+// episode state, and if the goal is in view, plans and sets the action
+// to take on the next trial
+func (ss *Sim) PlanTrial() {
+	pl := &ss.Planner
+	ev := &ss.TrainEnv
+	if !pl.On {
+		return
+	}
+	gmat := ev.MatMap[pl.Goal]
+	ray, dist := ev.GoalRay(gmat)
+	if pl.Pending && pl.PredRay >= 0 {
+		pl.SumErr += math.Abs(float64(ev.DepthLogs[pl.PredRay] - pl.PredDst))
+		pl.NErr++
+	}
+	pl.Pending = false
+	if !pl.Active {
+		if ray < 0 {
+			return
+		}
+		pl.Active = true
+		pl.Steps = 0
+		pl.NPlans = 0
+		pl.StartDst = dist
+		pl.SumErr = 0
+		pl.NErr = 0
+	}
+	if ev.ProxMats[0] == gmat {
+		ss.LogPlan(ss.PlanLog, true, dist)
+		return // ActGen will consume the goal
+	}
+	if pl.Steps >= pl.MaxSteps {
+		ss.LogPlan(ss.PlanLog, false, dist)
+		return
+	}
+	pl.Steps++
+	if ray < 0 {
+		return // explore until goal is back in view
+	}
+	pl.NPlans++
+	ss.Plan(ray)
+	ev.ExtAct = pl.Plan[0]
+	pl.Pending = true
+	pl.PredRay = ev.RayAfterAct(ray, pl.Plan[0])
+}
+
+// Plan evaluates Width candidate action sequences of length Depth by rollouts
+// of the network, starting with the goal on given ray, and sets Plan to the
+// sequence with the lowest predicted final distance to the goal.
+// The first candidate always continues Forward.
+func (ss *Sim) Plan(ray int) {
+	pl := &ss.Planner
+	pl.State.Save(ss.Net)
+	seq := make([]int, pl.Depth)
+	pl.PlanDist = 2
+	for w := 0; w < pl.Width; w++ {
+		for d := range seq {
+			if w == 0 {
+				seq[d] = int(Forward)
+			} else {
+				seq[d] = int(pl.Acts[rand.Intn(len(pl.Acts))])
+			}
+		}
+		dists := ss.PlanRollout(seq, ray)
+		pl.State.Restore(ss.Net)
+		dist := dists[len(dists)-1]
+		if dist < pl.PlanDist {
+			pl.PlanDist = dist
+			pl.PredDst = dists[0]
+			pl.Plan = append(pl.Plan[:0], seq...)
+		}
+	}
+}
+
+// PlanRollout runs the network through imagined trials for given action
+// sequence, starting from the current depth view, and returns the predicted
+// normalized log distance to the goal after each step, tracking the goal from
+// given ray across rotations (1 = out of view).  Each action is applied to VL
+// after the minus phase, as in TakeAction, and its outcome is read from MSTdP
+// at the end of the minus phase of the next imagined trial, and applied as
+// the V2Pd input for the rest of it.  No learning takes place, but the
+// activation state is altered -- see NetState.
+func (ss *Sim) PlanRollout(acts []int, ray int) []float32 {
+	pl := &ss.Planner
+	ev := &ss.TrainEnv
+	v2pd := ss.Net.LayerByName("V2Pd").(leabra.LeabraLayer).AsLeabra()
+	mstdp := ss.Net.LayerByName("MSTdP").(leabra.LeabraLayer).AsLeabra()
+	vl := ss.Net.LayerByName("VL").(leabra.LeabraLayer).AsLeabra()
+	if pl.InTsr == nil {
+		pl.InTsr = &etensor.Float32{}
+		pl.OutTsr = &etensor.Float32{}
+	}
+	pl.InTsr.CopyFrom(ev.NextStates["Depth"])
+	npool := mstdp.Shp.Dim(1)
+	dists := make([]float32, len(acts))
+	pl.Time.CycPerQtr = ss.CycPerQtr
+	nqtr := ss.MinusQtrs + ss.PlusQtrs
+	for i := 0; i <= len(acts); i++ {
+		ss.Net.InitExt()
+		v2pd.ApplyExt(pl.InTsr)
+		ss.Net.AlphaCycInit(false)
+		pl.Time.AlphaCycStart()
+		for qtr := 0; qtr < nqtr; qtr++ {
+			lq := ss.LeabraQtr(qtr)
+			pl.Time.Quarter = lq
+			if lq < 0 {
+				pl.Time.Quarter = 3
+			}
+			pl.Time.PlusPhase = qtr >= ss.MinusQtrs
+			for cyc := 0; cyc < pl.Time.CycPerQtr; cyc++ {
+				ss.Net.Cycle(&pl.Time)
+				pl.Time.CycleInc()
+			}
+			if lq >= 0 {
+				ss.Net.QuarterFinal(&pl.Time)
+			}
+			if lq != 2 {
+				continue
+			}
+			if i > 0 {
+				mstdp.UnitValsTensor(pl.OutTsr, "ActM")
+				ray = ev.RayAfterAct(ray, acts[i-1])
+				dists[i-1] = 1
+				if ray >= 0 {
+					dists[i-1] = mat32.Clamp(ss.PredDepth(pl.OutTsr, ev.RayPool(ray, npool)), 0, 1)
+				}
+				ss.PredDepthInput(pl.OutTsr, pl.InTsr)
+				v2pd.ApplyExt(pl.InTsr)
+				if v2pd.Act.Clamp.Hard {
+					v2pd.HardClamp()
+				}
+			}
+			if i == len(acts) {
+				break
+			}
+			vl.SetType(emer.Input)
+			vl.ApplyExt(ev.Pats[ev.Acts[acts[i]]])
+			vl.SetType(emer.Target)
+		}
+	}
+	return dists
+}
+
+// PredDepth decodes the normalized log depth of given pool of the MSTdP
+// activity in pt
+func (ss *Sim) PredDepth(pt *etensor.Float32, pool int) float32 {
+	ev := &ss.TrainEnv
+	vals := make([]float32, ev.PopSize)
+	for pi := range vals {
+		vals[pi] = pt.Value([]int{0, pool, pi, 0})
+	}
+	return ev.PopCode.Decode(vals)
+}
+
+// PredDepthInput sets the V2Pd-shaped depth input dt from the MSTdP activity
+// in pt, each ray taking the activity of the pool it drives
+func (ss *Sim) PredDepthInput(pt, dt *etensor.Float32) {
+	ev := &ss.TrainEnv
+	npool := pt.Dim(1)
+	for ray := 0; ray < ev.NFOVRays; ray++ {
+		pool := ev.RayPool(ray, npool)
+		for pi := 0; pi < ev.PopSize; pi++ {
+			dt.Set([]int{0, ray, pi, 0}, pt.Value([]int{0, pool, pi, 0}))
+		}
+	}
+}
+
+// LogPlan adds data for the goal episode just completed to the PlanLog,
+// and ends the episode
+func (ss *Sim) LogPlan(dt *etable.Table, success bool, dist float32) {
+	pl := &ss.Planner
+	ev := &ss.TrainEnv
+	pl.NEpisode++
+	if success {
+		pl.NSuccess++
+	}
+	pl.Active = false
+
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ev.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ev.Epoch.Cur))
+	dt.SetCellFloat("Episode", row, float64(pl.NEpisode))
+	dt.SetCellFloat("Steps", row, float64(pl.Steps))
+	dt.SetCellFloat("NPlans", row, float64(pl.NPlans))
+	dt.SetCellFloat("StartDist", row, float64(pl.StartDst))
+	dt.SetCellFloat("EndDist", row, float64(dist))
+	if success {
+		dt.SetCellFloat("Success", row, 1)
+	} else {
+		dt.SetCellFloat("Success", row, 0)
+	}
+	dt.SetCellFloat("SuccessRate", row, pl.SuccessRate())
+	dt.SetCellFloat("PredErr", row, pl.PredErr())
+
+	// note: essential to use Go version of update when called from another goroutine
+	if ss.PlanPlot != nil {
+		ss.PlanPlot.GoUpdate()
+	}
+}
+
+func (ss *Sim) ConfigPlanLog(dt *etable.Table) {
+	dt.SetMetaData("name", "PlanLog")
+	dt.SetMetaData("desc", "Record of each goal episode using the planner")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Episode", etensor.INT64, nil, nil},
+		{"Steps", etensor.INT64, nil, nil},
+		{"NPlans", etensor.INT64, nil, nil},
+		{"StartDist", etensor.FLOAT64, nil, nil},
+		{"EndDist", etensor.FLOAT64, nil, nil},
+		{"Success", etensor.FLOAT64, nil, nil},
+		{"SuccessRate", etensor.FLOAT64, nil, nil},
+		{"PredErr", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigPlanPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Emery Planner Plot"
+	plt.Params.XAxisCol = "Episode"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Episode", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Steps", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NPlans", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("StartDist", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("EndDist", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Success", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("SuccessRate", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("PredErr", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
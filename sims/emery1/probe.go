@@ -0,0 +1,77 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// RunTrial runs one alpha cycle of the network on the given inputs, keyed by
+// layer name, without the env or any learning, and returns the minus-phase
+// activations (ActM) of the given layers, keyed by layer name -- all layers
+// that are not in inputs if none are given.  Inputs to Target layers are
+// clamped in the plus phase, as usual.  This is for probing a trained network
+// with synthetic stimuli, e.g.:
+//
+//	outs := ss.RunTrial(map[string]etensor.Tensor{"S1V": vpat}, "M1")
+//
+// Unlike AlphaCyc, no action is taken at the end of the minus phase.
+// Activation state carries over from the prior trial as it does in AlphaCyc.
+func (ss *Sim) RunTrial(inputs map[string]etensor.Tensor, lays ...string) map[string]*etensor.Float32 {
+	ss.Net.InitExt()
+	for lnm, pats := range inputs {
+		lyi := ss.Net.LayerByName(lnm)
+		if lyi == nil {
+			log.Printf("RunTrial: input layer not found: %s\n", lnm)
+			continue
+		}
+		lyi.(leabra.LeabraLayer).AsLeabra().ApplyExt(pats)
+	}
+
+	ss.Net.AlphaCycInit(false)
+	ss.Time.CycPerQtr = ss.CycPerQtr
+	ss.Time.AlphaCycStart()
+	nqtr := ss.MinusQtrs + ss.PlusQtrs
+	for qtr := 0; qtr < nqtr; qtr++ {
+		lq := ss.LeabraQtr(qtr)
+		ss.Time.Quarter = lq
+		if lq < 0 {
+			ss.Time.Quarter = 3
+		}
+		ss.Time.PlusPhase = qtr >= ss.MinusQtrs
+		for cyc := 0; cyc < ss.Time.CycPerQtr; cyc++ {
+			ss.Net.Cycle(&ss.Time)
+			ss.Time.CycleInc()
+		}
+		if lq >= 0 {
+			ss.Net.QuarterFinal(&ss.Time)
+		}
+	}
+	ss.Time.Quarter = 4
+	ss.Time.PlusPhase = false
+
+	if len(lays) == 0 {
+		for _, lyi := range ss.Net.Layers {
+			if _, in := inputs[lyi.Name()]; !in && !lyi.IsOff() {
+				lays = append(lays, lyi.Name())
+			}
+		}
+	}
+	outs := make(map[string]*etensor.Float32, len(lays))
+	for _, lnm := range lays {
+		lyi := ss.Net.LayerByName(lnm)
+		if lyi == nil {
+			log.Printf("RunTrial: output layer not found: %s\n", lnm)
+			continue
+		}
+		tsr := &etensor.Float32{}
+		lyi.UnitValsTensor(tsr, "ActM")
+		outs[lnm] = tsr
+	}
+	return outs
+}
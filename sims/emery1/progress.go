@@ -0,0 +1,91 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Progress tracks wall-clock time and training throughput, for the Counters
+// string and the nogui progress output, so long runs can be monitored.
+// The clock starts at the first training trial after Reset.
+type Progress struct {
+	Start  time.Time `view:"-" desc:"wall-clock time of the first training trial"`
+	Trials int       `view:"-" desc:"number of training trials since Start"`
+}
+
+// Reset resets the clock and trial count
+func (pr *Progress) Reset() {
+	pr.Trials = 0
+}
+
+// Trial records a training trial
+func (pr *Progress) Trial() {
+	if pr.Trials == 0 {
+		pr.Start = time.Now()
+	}
+	pr.Trials++
+}
+
+// Elapsed returns the wall-clock time since Start
+func (pr *Progress) Elapsed() time.Duration {
+	if pr.Trials == 0 {
+		return 0
+	}
+	return time.Since(pr.Start)
+}
+
+// TrlPerSec returns the number of training trials per second since Start
+func (pr *Progress) TrlPerSec() float64 {
+	secs := pr.Elapsed().Seconds()
+	if secs == 0 {
+		return 0
+	}
+	return float64(pr.Trials) / secs
+}
+
+// ETA returns the estimated time to complete given number of remaining trials,
+// at the current throughput
+func (pr *Progress) ETA(remain int) time.Duration {
+	tps := pr.TrlPerSec()
+	if tps == 0 {
+		return 0
+	}
+	return time.Duration(float64(remain) / tps * float64(time.Second))
+}
+
+// TrialsLeft returns the number of training trials remaining over all runs
+func (ss *Sim) TrialsLeft() int {
+	env := &ss.TrainEnv
+	epcs := (env.Run.Max-env.Run.Cur-1)*ss.MaxEpcs + (ss.MaxEpcs - env.Epoch.Cur)
+	left := epcs*env.Trial.Max - env.Trial.Cur
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// ProgressString returns the elapsed time, throughput, time to completion,
+// PctCortex and learning rate multiplier, tab separated as in Counters
+func (ss *Sim) ProgressString() string {
+	pr := &ss.Progress
+	return fmt.Sprintf("Elapsed:\t%v\tTrl/s:\t%.1f\tETA:\t%v\tPctCortex:\t%.2f\tLrate:\t%g", pr.Elapsed().Round(time.Second), pr.TrlPerSec(), pr.ETA(ss.TrialsLeft()).Round(time.Second), ss.PctCortex, ss.LrateMult)
+}
+
+// PrintProgress prints the progress at the end of an epoch, in nogui mode
+func (ss *Sim) PrintProgress() {
+	if !ss.NoGui {
+		return
+	}
+	fmt.Printf("Run: %d\tEpoch: %d\t%s\n", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Prv, ss.ProgressString())
+}
+
+// SetLrateMult sets the learning rate multiplier on the network, relative to
+// the initial learning rate, and records it for the progress output
+func (ss *Sim) SetLrateMult(mult float32) {
+	ss.LrateMult = mult
+	ss.Net.LrateMult(mult)
+}
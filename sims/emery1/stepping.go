@@ -0,0 +1,141 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/emer/emergent/env"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/ki/kit"
+)
+
+// StepGrains are the granularities at which training can be stepped
+type StepGrains int32
+
+var KiT_StepGrains = kit.Enums.AddEnum(StepGrainsN, false, nil)
+
+const (
+	// StepCycle steps one cycle
+	StepCycle StepGrains = iota
+
+	// StepQuarter steps to the end of the current quarter, of the MinusQtrs
+	// and PlusQtrs quarters of the trial
+	StepQuarter
+
+	// StepTrial steps to the end of the current trial, with the next
+	// action planned
+	StepTrial
+
+	// StepEpoch steps until the epoch changes
+	StepEpoch
+
+	// StepRun steps until the run ends
+	StepRun
+
+	StepGrainsN
+)
+
+// FromString sets the grain from its name, with or without the Step prefix
+func (i *StepGrains) FromString(s string) error {
+	for j := StepGrains(0); j < StepGrainsN; j++ {
+		if j.String() == s || j.String() == "Step"+s {
+			*i = j
+			return nil
+		}
+	}
+	return fmt.Errorf("StepGrains: %q not found", s)
+}
+
+// TrainStages are the stages of a training trial in the FWorld, at which the
+// training stepper can be stopped and resumed
+type TrainStages int32
+
+const (
+	// StageEnv is before the TrainEnv is stepped for a new trial
+	StageEnv TrainStages = iota
+
+	// StageInputs is after the TrainEnv is stepped, before the ITI cycles
+	// are run and the inputs applied
+	StageInputs
+
+	// StageCycles is during the MinusQtrs + PlusQtrs quarters of the alpha
+	// cycle, between cycles -- the action is taken at the end of the minus
+	// phase
+	StageCycles
+)
+
+// AlphaState is the state of an alpha cycle in progress, so that it can be
+// run one cycle at a time, and take its action in the right env
+type AlphaState struct {
+	Active    bool              `desc:"an alpha cycle is in progress"`
+	Train     bool              `desc:"learning is on for the alpha cycle"`
+	ViewUpdt  leabra.TimeScales `desc:"view update time scale for the alpha cycle"`
+	Env       *FWorld           `desc:"env that the action is taken in at the end of the minus phase"`
+	PctCortex float64           `desc:"proportion of actions driven by the cortex, for the action"`
+	Qtr       int               `desc:"quarter of the next cycle"`
+	Cyc       int               `desc:"cycle within the quarter of the next cycle"`
+}
+
+// TrainStep advances training by one step of given grain from wherever it was
+// stopped: to the end of the next cycle, or of the current quarter or trial,
+// or until the epoch changes or the run ends.  A step of any grain also ends
+// when the run ends.  The ITI cycles and the inputs of a trial are run in one
+// go, before its first cycle step.  Train, TrainEpoch, TrainRun, the Step
+// buttons and the -step flag all go through this, so they can be freely
+// mixed, and StopNow is honored between trials.
+func (ss *Sim) TrainStep(grain StepGrains) {
+	for {
+		switch ss.Stage {
+		case StageEnv:
+			if ss.StopNow {
+				return
+			}
+			if !ss.TrainEnvStep() {
+				return
+			}
+			ss.Stage = StageInputs
+			if _, _, chg := ss.TrainEnv.Counter(env.Epoch); chg && grain == StepEpoch {
+				return
+			}
+		case StageInputs:
+			ss.TrainTrialStart()
+			ss.Stage = StageCycles
+		case StageCycles:
+			qtrEnd, done := ss.AlphaCycCycle()
+			if done {
+				ss.TrainTrialEnd()
+				ss.Stage = StageEnv
+			}
+			if grain == StepCycle || (grain == StepQuarter && qtrEnd) || (grain == StepTrial && done) {
+				return
+			}
+		}
+	}
+}
+
+// TrainSteps runs n steps of given grain of training, or until stopped
+func (ss *Sim) TrainSteps(grain StepGrains, n int) {
+	ss.StopNow = false
+	for i := 0; i < n && !ss.StopNow; i++ {
+		ss.TrainStep(grain)
+	}
+	ss.Stopped()
+}
+
+// ResetStage resets the training stepper to the start of a trial, abandoning
+// any trial in progress -- called in NewRun
+func (ss *Sim) ResetStage() {
+	ss.Stage = StageEnv
+	ss.Alpha.Active = false
+}
+
+// FinishTrainTrial runs the rest of the training trial in progress, if it
+// was stepped partway through, before the network is used for testing
+func (ss *Sim) FinishTrainTrial() {
+	if ss.Stage == StageCycles {
+		ss.TrainStep(StepTrial)
+	}
+}
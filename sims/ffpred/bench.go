@@ -0,0 +1,142 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emer/etable/agg"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// Bench is the standard benchmark protocol: a fixed world, generated from WorldSeed,
+// a set of random seeds, and number of training epochs.  Run with the -bench flag,
+// which trains one run per seed and appends a row of key metrics and runtime for
+// each to the scoreboard File, so learning quality and speed can be tracked across
+// code revisions.
+type Bench struct {
+	WorldSeed int64    `def:"1" desc:"random seed for generating the world used for all runs"`
+	Seeds     []int64  `desc:"random seeds, one run per seed"`
+	Epochs    int      `def:"50" desc:"number of training epochs per run"`
+	Stats     []string `desc:"TrnEpcLog columns recorded in the scoreboard"`
+	NAvg      int      `def:"5" desc:"number of final epochs that Stats are averaged over"`
+	File      string   `def:"ffpred_bench.tsv" desc:"scoreboard file that rows are appended to -- headers are written if it does not yet exist"`
+}
+
+func (bn *Bench) Defaults() {
+	bn.WorldSeed = 1
+	bn.Seeds = []int64{1, 2, 3}
+	bn.Epochs = 50
+	bn.Stats = []string{"ActMatch", "CosDiff"}
+	bn.NAvg = 5
+	bn.File = "ffpred_bench.tsv"
+}
+
+// GitCommit returns the short hash of the current git commit, or "unknown"
+func GitCommit() string {
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// RunBench runs the Bench protocol and appends the results to the scoreboard file
+func (ss *Sim) RunBench() {
+	bn := &ss.Bench
+	rand.Seed(bn.WorldSeed)
+	ss.TrainEnv.GenWorld()
+	if err := ss.TrainEnv.SaveWorld("world.tsv"); err != nil { // loaded in Init
+		log.Println(err)
+		return
+	}
+	ss.TrainEnv.KeepWorld = true
+	ss.SaveWts = false
+	ss.SaveARFs = false
+
+	dt := &etable.Table{}
+	ss.ConfigBenchLog(dt)
+	commit := GitCommit()
+	date := time.Now().Format("2006-01-02 15:04")
+	for _, seed := range bn.Seeds {
+		fmt.Printf("Bench: world seed: %d  seed: %d  epochs: %d\n", bn.WorldSeed, seed, bn.Epochs)
+		ss.RndSeed = seed
+		ss.Init()
+		ss.MaxEpcs = bn.Epochs
+		stm := time.Now()
+		for !ss.StopNow && !ss.NeedsNewRun {
+			ss.TrainTrial()
+		}
+		secs := time.Since(stm).Seconds()
+
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellString("Date", row, date)
+		dt.SetCellString("Commit", row, commit)
+		dt.SetCellString("Params", row, ss.RunName())
+		dt.SetCellFloat("WorldSeed", row, float64(bn.WorldSeed))
+		dt.SetCellFloat("Seed", row, float64(seed))
+		dt.SetCellFloat("Epochs", row, float64(bn.Epochs))
+		epcix := etable.NewIdxView(ss.TrnEpcLog)
+		if n := epcix.Len(); n > bn.NAvg {
+			epcix.Idxs = epcix.Idxs[n-bn.NAvg:]
+		}
+		for _, st := range bn.Stats {
+			dt.SetCellFloat(st, row, agg.Agg(epcix, st, agg.AggMean)[0])
+		}
+		dt.SetCellFloat("Secs", row, secs)
+		dt.SetCellFloat("SecsPerEpc", row, secs/float64(bn.Epochs))
+	}
+	ss.SaveBench(dt)
+}
+
+// SaveBench appends the rows of given bench log to the scoreboard File
+func (ss *Sim) SaveBench(dt *etable.Table) {
+	_, err := os.Stat(ss.Bench.File)
+	isNew := os.IsNotExist(err)
+	f, err := os.OpenFile(ss.Bench.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+	if isNew {
+		dt.WriteCSVHeaders(f, etable.Tab)
+	}
+	for row := 0; row < dt.Rows; row++ {
+		dt.WriteCSVRow(f, row, etable.Tab)
+	}
+	fmt.Printf("Bench: results appended to: %s\n", ss.Bench.File)
+}
+
+func (ss *Sim) ConfigBenchLog(dt *etable.Table) {
+	dt.SetMetaData("name", "BenchLog")
+	dt.SetMetaData("desc", "Benchmark scoreboard: key metrics and runtime per seed")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Date", etensor.STRING, nil, nil},
+		{"Commit", etensor.STRING, nil, nil},
+		{"Params", etensor.STRING, nil, nil},
+		{"WorldSeed", etensor.INT64, nil, nil},
+		{"Seed", etensor.INT64, nil, nil},
+		{"Epochs", etensor.INT64, nil, nil},
+	}
+	for _, st := range ss.Bench.Stats {
+		sch = append(sch, etable.Column{st, etensor.FLOAT64, nil, nil})
+	}
+	sch = append(sch, etable.Column{"Secs", etensor.FLOAT64, nil, nil})
+	sch = append(sch, etable.Column{"SecsPerEpc", etensor.FLOAT64, nil, nil})
+	dt.SetFromSchema(sch, 0)
+}
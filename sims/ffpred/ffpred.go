@@ -16,6 +16,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ccnlab/map-nav/sims/netlayout"
+	"github.com/ccnlab/map-nav/sims/paramcomp"
 	"github.com/emer/axon/axon"
 	"github.com/emer/emergent/actrf"
 	"github.com/emer/emergent/emer"
@@ -23,7 +25,6 @@ import (
 	"github.com/emer/emergent/netview"
 	"github.com/emer/emergent/params"
 	"github.com/emer/emergent/prjn"
-	"github.com/emer/emergent/relpos"
 	"github.com/emer/empi/mpi"
 	"github.com/emer/etable/agg"
 	"github.com/emer/etable/eplot"
@@ -196,6 +197,10 @@ var ParamSets = params.Sets{
 				Params: params.Params{
 					"Prjn.PrjnScale.Rel": "0.5", // 0.5 > 0.2
 				}},
+			{Sel: ".FmSelfP", Desc: "self-prediction error, weaker than the pulvinar",
+				Params: params.Params{
+					"Prjn.PrjnScale.Rel": "0.05",
+				}},
 			{Sel: ".FwdToPulv", Desc: "feedforward to pulvinar directly",
 				Params: params.Params{
 					"Prjn.PrjnScale.Rel": "0.1",
@@ -205,9 +210,37 @@ var ParamSets = params.Sets{
 					"Prjn.PrjnScale.Rel": "0.2",
 				}},
 		},
+		"LrateMult": &params.Sheet{ // see LrateMultParam
+			// {Sel: ".FmPulv", Desc: "faster decoding",
+			// 	Params: params.Params{
+			// 		"Prjn.LrateMult": "2",
+			// 	}},
+		},
+	}},
+	{Name: "HiddenNoise", Desc: "conductance noise in the hidden layers, with the Base Dist and Var", Sheets: params.Sheets{
+		"Network": &params.Sheet{
+			{Sel: ".Hidden", Desc: "noise on",
+				Params: params.Params{
+					"Layer.Act.Noise.Type": "GeNoise",
+				}},
+		},
+	}},
+	{Name: "MoreNoise", Desc: "twice the HiddenNoise variance", Sheets: params.Sheets{
+		"Network": &params.Sheet{
+			{Sel: ".Hidden", Desc: "more noise",
+				Params: params.Params{
+					"Layer.Act.Noise.Var": "0.01",
+				}},
+		},
 	}},
 }
 
+// ParamDeps are the sets that each of the ParamSets builds on, applied
+// before it when it is used in the ParamSet spec
+var ParamDeps = paramcomp.Deps{
+	"MoreNoise": {"HiddenNoise"},
+}
+
 // Sim encapsulates the entire simulation model, and we define all the
 // functionality as methods on this struct.  This structure keeps all relevant
 // state information organized and available without having to pass everything around
@@ -228,11 +261,30 @@ type Sim struct {
 	TstCycLog        *etable.Table                 `view:"no-inline" desc:"testing cycle-level log data"`
 	RunLog           *etable.Table                 `view:"no-inline" desc:"summary log of each run"`
 	RunStats         *etable.Table                 `view:"no-inline" desc:"aggregate stats on all runs"`
+	PlanLog          *etable.Table                 `view:"no-inline" desc:"log of goal episodes using the planner"`
+	RhythmLog        *etable.Table                 `view:"no-inline" desc:"log of the rhythmicity of the recorded layers over testing"`
+	FlowLog          *etable.Table                 `view:"no-inline" desc:"log of the information flow between the recorded layers over testing"`
+	WtChgLog         *etable.Table                 `view:"no-inline" desc:"log of the weight changes of each prjn over training"`
+	Planner          Planner                       `view:"inline" desc:"model-based planner using the learned forward model to select actions toward a goal"`
 	MinusCycles      int                           `desc:"number of minus-phase cycles"`
 	PlusCycles       int                           `desc:"number of plus-phase cycles"`
+	ITI              ITI                           `view:"inline" desc:"inter-trial interval of blank cycles with inputs off, and activity decay, between trials"`
+	Inputs           []InputMap                    `desc:"env states applied to each input and target layer by ApplyInputs -- empty = DefaultInputs, set at Config"`
+	StrictInputs     bool                          `desc:"at Config, exit if any layer or state of the Inputs does not exist, instead of reporting it and skipping it in ApplyInputs"`
+	ObsNorm          ObsNorm                       `desc:"normalization of env states by their running mean and variance over training, before they are applied to the input layers"`
+	SelfPred         SelfPred                      `desc:"auxiliary objective of hidden layers predicting their own next-step activity"`
+	Replay           Replay                        `desc:"episodic memory buffer of recent training trials, replayed with prioritized sampling by prediction error"`
+	Rhythm           Rhythm                        `desc:"temporal autocorrelation and spectral analysis of the cycle-level activity of the SpikeRecLays during testing"`
+	Flow             Flow                          `desc:"directed information flow (Granger causality, transfer entropy) between the SpikeRecLays during testing"`
 	ErrLrMod         axon.LrateMod                 `view:"inline" desc:"learning rate modulation as function of error"`
 	Params           params.Sets                   `view:"no-inline" desc:"full collection of param sets"`
-	ParamSet         string                        `desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set"`
+	Bench            Bench                         `desc:"standard benchmark protocol, run with the -bench flag"`
+	Progress         Progress                      `view:"-" desc:"wall-clock time and throughput of training"`
+	LrateSched       float32                       `inactive:"+" desc:"current learning rate schedule multiplier from the TrainSched schedule"`
+	LrateMults       map[string]float32            `inactive:"+" desc:"learning rate multiplier of each prjn, by name, from the LrateMult params sheet -- applied on top of Prjn.Learn.Lrate.Base"`
+	WtChg            WtChg                         `desc:"DWt and cumulative weight change norms of each prjn per epoch, for which pathways are learning"`
+	ParamSet         string                        `desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- a paramcomp spec of set names and numeric overrides separated by +, e.g., Base+Gi=1.6, with the ParamDeps of each set applied before it"`
+	SaveParams       string                        `view:"-" desc:"if set, the composed params set applied by SetParams is saved to this file, as JSON"`
 	Tag              string                        `desc:"extra tag string to add to any file names output from sim (e.g., weights files, log files, params for run)"`
 	Prjn4x4Skp2      *prjn.PoolTile                `view:"no-inline" desc:"feedforward 4x4 skip 2 topo prjn"`
 	Prjn4x4Skp2Recip *prjn.PoolTile                `view:"no-inline" desc:"feedforward 4x4 skip 2 topo prjn, recip"`
@@ -241,18 +293,20 @@ type Sim struct {
 	Prjn4x4Skp4Recip *prjn.PoolTile                `view:"no-inline" desc:"feedforward 4x4 skip 4 topo prjn, recip"`
 	MaxRuns          int                           `desc:"maximum number of model runs to perform"`
 	MaxEpcs          int                           `desc:"maximum number of epochs to run per model run"`
-	TestEpcs         int                           `desc:"number of epochs of testing to run, cumulative after MaxEpcs of training"`
 	NZeroStop        int                           `desc:"if a positive number, training will stop after this many epochs with zero SSE"`
 	TrainEnv         FWorld                        `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
+	TestEnv          FWorld                        `desc:"Testing environment -- the same world as TrainEnv, traversed on a fresh trajectory from the start location for each test, with frozen weights"`
 	Time             axon.Time                     `desc:"axon timing parameters and state"`
 	ViewOn           bool                          `desc:"whether to update the network view while running"`
 	TrainUpdt        axon.TimeScales               `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt         axon.TimeScales               `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
 	TestInterval     int                           `desc:"how often to run through all the test patterns, in terms of training epochs"`
-	CosDifActs       []string                      `view:"-" desc:"actions to track CosDif performance by"`
+	TestTrls         int                           `desc:"number of trials in each test"`
+	CosDifActs       []Actions                     `view:"-" desc:"actions to track CosDif performance by"`
 	LayStatNms       []string                      `desc:"names of layers to collect more detailed stats on (avg act, etc)"`
 	ARFLayers        []string                      `desc:"names of layers to compute position activation fields on"`
 	SpikeRecLays     []string                      `desc:"names of layers to record spikes of during testing"`
+	RasterVar        string                        `def:"Spike" desc:"unit variable recorded in the spike rasters: Spike, or a rate code such as Act"`
 	SpikeRasters     map[string]*etensor.Float32   `desc:"spike raster data for different layers"`
 	SpikeRastGrids   map[string]*etview.TensorGrid `desc:"spike raster plots for different layers"`
 
@@ -290,6 +344,9 @@ type Sim struct {
 	TstTrlPlot   *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
 	TstCycPlot   *eplot.Plot2D               `view:"-" desc:"the test-cycle plot"`
 	RunPlot      *eplot.Plot2D               `view:"-" desc:"the run plot"`
+	PlanPlot     *eplot.Plot2D               `view:"-" desc:"the planner plot"`
+	RhythmPlot   *eplot.Plot2D               `view:"-" desc:"the rhythm plot"`
+	WtChgPlot    *eplot.Plot2D               `view:"-" desc:"the weight change plot"`
 	TrnEpcFile   *os.File                    `view:"-" desc:"log file"`
 	TstEpcFile   *os.File                    `view:"-" desc:"log file"`
 	RunFile      *os.File                    `view:"-" desc:"log file"`
@@ -301,6 +358,8 @@ type Sim struct {
 	LogSetParams bool                        `view:"-" desc:"if true, print message for all params that are set"`
 	IsRunning    bool                        `view:"-" desc:"true if sim is running"`
 	StopNow      bool                        `view:"-" desc:"flag to stop running"`
+	Stage        TrainStages                 `view:"-" desc:"stage of the current training trial in the training stepper"`
+	Theta        ThetaState                  `view:"-" desc:"state of the theta cycle in progress, run one cycle at a time by the training stepper"`
 	NeedsNewRun  bool                        `view:"-" desc:"flag to initialize NewRun if last one finished"`
 	RndSeed      int64                       `view:"-" desc:"the current random seed"`
 	UseMPI       bool                        `view:"-" desc:"if true, use MPI to distribute computation across nodes"`
@@ -327,6 +386,10 @@ func (ss *Sim) New() {
 	ss.TstCycLog = &etable.Table{}
 	ss.RunLog = &etable.Table{}
 	ss.RunStats = &etable.Table{}
+	ss.PlanLog = &etable.Table{}
+	ss.RhythmLog = &etable.Table{}
+	ss.FlowLog = &etable.Table{}
+	ss.WtChgLog = &etable.Table{}
 
 	ss.Time.Defaults()
 	ss.MinusCycles = 150
@@ -340,10 +403,11 @@ func (ss *Sim) New() {
 	ss.ViewOn = true
 	ss.TrainUpdt = axon.AlphaCycle
 	ss.TestUpdt = axon.GammaCycle
-	ss.CosDifActs = []string{"Forward", "Left", "Right"}
+	ss.CosDifActs = []Actions{Forward, Left, Right}
 	ss.LayStatNms = []string{"MSTd", "MSTdCT"}
 	ss.ARFLayers = []string{"MSTd", "MSTdCT"}
 	ss.SpikeRecLays = []string{"V2Wd", "MSTd", "MSTdCT", "V2WdP"}
+	ss.RasterVar = "Spike"
 	ss.Defaults()
 	ss.NewPrjns()
 }
@@ -352,6 +416,13 @@ func (ss *Sim) New() {
 func (ss *Sim) Defaults() {
 	ss.PctCortexMax = 0.5 // for good rfs
 	ss.TestInterval = 50000
+	ss.TestTrls = 200
+	ss.Planner.Defaults()
+	ss.Bench.Defaults()
+	ss.ObsNorm.Defaults()
+	ss.Replay.Defaults()
+	ss.Rhythm.Defaults()
+	ss.Flow.Defaults()
 }
 
 // NewPrjns creates new projections
@@ -383,12 +454,20 @@ func (ss *Sim) NewPrjns() {
 func (ss *Sim) Config() {
 	ss.ConfigEnv()
 	ss.ConfigNet(ss.Net)
+	ss.ConfigInputs()
 	ss.ConfigTrnEpcLog(ss.TrnEpcLog)
 	ss.ConfigTrnTrlLog(ss.TrnTrlLog)
 	ss.ConfigTstEpcLog(ss.TstEpcLog)
 	ss.ConfigTstTrlLog(ss.TstTrlLog)
 	ss.ConfigTstCycLog(ss.TstCycLog)
 	ss.ConfigRunLog(ss.RunLog)
+	ss.ConfigPlanLog(ss.PlanLog)
+	ss.ConfigRhythmLog(ss.RhythmLog)
+	ss.ConfigFlowLog(ss.FlowLog)
+	ss.ConfigWtChgLog(ss.WtChgLog)
+	if ss.RasterAnalyses() {
+		ss.ConfigSpikeRasts() // recorded without the gui
+	}
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -397,7 +476,6 @@ func (ss *Sim) ConfigEnv() {
 	}
 	if ss.MaxEpcs == 0 { // allow user override
 		ss.MaxEpcs = 100
-		ss.TestEpcs = 500
 		ss.NZeroStop = -1
 	}
 
@@ -408,6 +486,15 @@ func (ss *Sim) ConfigEnv() {
 	ss.TrainEnv.Init(0)
 	ss.TrainEnv.Validate()
 
+	ss.TestEnv.AngInc = ss.TrainEnv.AngInc
+	ss.TestEnv.KeepWorld = true // same world as TrainEnv, from world.tsv
+	ss.TestEnv.Config(ss.TestTrls)
+	ss.TestEnv.Nm = "TestEnv"
+	ss.TestEnv.Dsc = "testing params and state"
+	ss.TestEnv.Disp = false
+	ss.TestEnv.Init(0)
+	ss.TestEnv.Validate()
+
 	ss.ConfigRFMaps()
 }
 
@@ -474,14 +561,18 @@ func (ss *Sim) ConfigNet(net *axon.Network) {
 	// net.LateralConnectLayerPrjn(mstd, p1to1, &axon.HebbPrjn{}).SetType(emer.Inhib)
 	net.LateralConnectLayerPrjn(mstdct, p1to1, &axon.HebbPrjn{}).SetType(emer.Inhib)
 
+	ss.ConfigSelfPred(net)
+
 	//////////////////////////////////////
 	// position
 
-	v2wdp.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: v2wd.Name(), YAlign: relpos.Front, Space: 4})
-	act.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: v2wdp.Name(), YAlign: relpos.Front, Space: 4})
-
-	mstd.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: v2wd.Name(), XAlign: relpos.Left, YAlign: relpos.Front})
-	mstdct.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: mstd.Name(), XAlign: relpos.Left, Space: 4})
+	lay := netlayout.Layout{}
+	lay.Defaults()
+	lay.Groups = []string{"MSTd"}
+	lay.Levels = map[string]int{v2wdp.Name(): 0} // pulvinar next to its driver
+	if err := lay.Apply(net); err != nil {
+		log.Println(err)
+	}
 
 	//////////////////////////////////////
 	// collect
@@ -500,6 +591,9 @@ func (ss *Sim) ConfigNet(net *axon.Network) {
 		case axon.TRC:
 			ss.PulvLays = append(ss.PulvLays, ly.Name())
 		case emer.Target:
+			if ss.SelfPred.IsPLay(ly.Name()) {
+				continue // separate stats
+			}
 			ss.PulvLays = append(ss.PulvLays, ly.Name())
 		case emer.Hidden:
 			ss.SuperLays = append(ss.SuperLays, ly.Name())
@@ -538,6 +632,8 @@ func (ss *Sim) ConfigNet(net *axon.Network) {
 // Initialize network weights including scales
 func (ss *Sim) InitWts(net *axon.Network) {
 	net.InitWts()
+	net.LrateSched(1) // restore initial learning rate value
+	ss.LrateSched = 1
 	// net.InitTopoSWts() //  sets all wt scales
 }
 
@@ -553,6 +649,7 @@ func (ss *Sim) Init() {
 	ss.StopNow = false
 	ss.SetParams("", ss.LogSetParams) // all sheets
 	ss.NewRun()
+	ss.Progress.Reset()
 	ss.UpdateView(true)
 }
 
@@ -567,7 +664,7 @@ func (ss *Sim) NewRndSeed() {
 // and add a few tabs at the end to allow for expansion..
 func (ss *Sim) Counters(train bool) string {
 	// if train {
-	return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tEvent:\t%d\tCycle:\t%d\tName:\t%v\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Event.Cur, ss.Time.Cycle, ss.TrainEnv.Event.Cur)
+	return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tEvent:\t%d\tCycle:\t%d\tName:\t%v\t%s\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TrainEnv.Event.Cur, ss.Time.Cycle, ss.TrainEnv.Event.Cur, ss.ProgressString())
 	// } else {
 	// 	return fmt.Sprintf("Run:\t%d\tEpoch:\t%d\tEvent:\t%d\tCycle:\t%d\tName:\t%v\t\t\t", ss.TrainEnv.Run.Cur, ss.TrainEnv.Epoch.Cur, ss.TestEnv.Event.Cur, ss.Time.Cycle, ss.TrainEnv.Event.Cur)
 	// }
@@ -611,10 +708,25 @@ func (ss *Sim) UpdateViewTime(train bool, viewUpdt axon.TimeScales) {
 // Handles netview updating within scope of ThetaCycle
 func (ss *Sim) ThetaCyc(train bool) {
 	// ss.Win.PollEvents() // this can be used instead of running in a separate goroutine
-	viewUpdt := ss.TrainUpdt
+	ss.ThetaCycStart(train)
+	for {
+		if _, done := ss.ThetaCycCycle(); done {
+			break
+		}
+	}
+}
+
+// ThetaCycStart starts a theta cycle, to be run one cycle at a time by
+// ThetaCycCycle, as ThetaCyc does, or by the training stepper
+func (ss *Sim) ThetaCycStart(train bool) {
+	th := &ss.Theta
+	th.Active = true
+	th.Train = train
+	th.ViewUpdt = ss.TrainUpdt
 	if !train {
-		viewUpdt = ss.TestUpdt
+		th.ViewUpdt = ss.TestUpdt
 	}
+	th.Cyc = 0
 
 	// update prior weight changes at start, so any DWt values remain visible at end
 	// you might want to do this less frequently to achieve a mini-batch update
@@ -624,19 +736,29 @@ func (ss *Sim) ThetaCyc(train bool) {
 		ss.Net.WtFmDWt()
 	}
 
-	minusCyc := ss.MinusCycles
-	plusCyc := ss.PlusCycles
-
 	ss.Net.NewState()
 	ss.Time.NewState()
+}
 
-	for cyc := 0; cyc < minusCyc; cyc++ { // do the minus phase
-		ss.Net.Cycle(&ss.Time)
-		ss.LogTstCyc(ss.TstCycLog, ss.Time.Cycle)
-		if !ss.NoGui {
-			ss.RecordSpikes(ss.Time.Cycle)
-		}
-		ss.Time.CycleInc()
+// ThetaCycCycle runs the next cycle of the theta cycle started by
+// ThetaCycStart, returning qtrEnd = true if it was the last cycle of a
+// quarter (QtrCycles) or a phase, and done = true if it was the last cycle
+// of the theta cycle, which is then finished (stats, DWt)
+func (ss *Sim) ThetaCycCycle() (qtrEnd, done bool) {
+	th := &ss.Theta
+	train := th.Train
+	viewUpdt := th.ViewUpdt
+	minusCyc := ss.MinusCycles
+	ncyc := minusCyc + ss.PlusCycles
+	cyc := th.Cyc
+
+	ss.Net.Cycle(&ss.Time)
+	ss.LogTstCyc(ss.TstCycLog, ss.Time.Cycle)
+	if !ss.NoGui || (!train && ss.RasterAnalyses()) {
+		ss.RecordSpikes(ss.Time.Cycle)
+	}
+	ss.Time.CycleInc()
+	if cyc < minusCyc { // minus phase
 		switch ss.Time.Cycle { // save states at beta-frequency -- not used computationally
 		case 75:
 			ss.Net.ActSt1(&ss.Time)
@@ -652,35 +774,45 @@ func (ss *Sim) ThetaCyc(train bool) {
 
 		if cyc == minusCyc-1 { // do before view update
 			ss.Net.MinusPhase(&ss.Time)
+			ss.ApplySelfPred() // targets for plus phase
 		}
-		if ss.ViewOn {
-			ss.UpdateViewTime(train, viewUpdt)
-		}
+	} else if cyc == ncyc-1 { // do before view update
+		ss.Net.PlusPhase(&ss.Time)
 	}
-	ss.Time.NewPhase()
-	if viewUpdt == axon.Phase {
-		ss.UpdateView(train)
+	if ss.ViewOn {
+		ss.UpdateViewTime(train, viewUpdt)
 	}
-	for cyc := 0; cyc < plusCyc; cyc++ { // do the plus phase
-		ss.Net.Cycle(&ss.Time)
-		ss.LogTstCyc(ss.TstCycLog, ss.Time.Cycle)
-		if !ss.NoGui {
-			ss.RecordSpikes(ss.Time.Cycle)
-		}
-		ss.Time.CycleInc()
-
-		if cyc == plusCyc-1 { // do before view update
-			ss.Net.PlusPhase(&ss.Time)
-		}
-		if ss.ViewOn {
-			ss.UpdateViewTime(train, viewUpdt)
+	th.Cyc++
+	if th.Cyc == minusCyc {
+		ss.Time.NewPhase()
+		if viewUpdt == axon.Phase {
+			ss.UpdateView(train)
 		}
 	}
+	if th.Cyc < ncyc {
+		return th.Cyc%QtrCycles == 0 || th.Cyc == minusCyc, false
+	}
+	ss.ThetaCycEnd()
+	return true, true
+}
 
-	ss.TrialStats(train) // need stats for lrmod
+// ThetaCycEnd finishes the theta cycle -- called by ThetaCycCycle after the
+// last cycle
+func (ss *Sim) ThetaCycEnd() {
+	th := &ss.Theta
+	th.Active = false
+	train := th.Train
+	viewUpdt := th.ViewUpdt
+
+	if ss.Replay.Active {
+		ss.TrialStatsTRC(false) // replay error, for its priority
+	} else {
+		ss.TrialStats(train) // need stats for lrmod
+	}
 
 	if train {
 		ss.Net.DWt()
+		ss.WtChgTrial()
 	}
 	if viewUpdt == axon.Phase || viewUpdt == axon.AlphaCycle || viewUpdt == axon.ThetaCycle {
 		ss.UpdateView(train)
@@ -695,26 +827,39 @@ func (ss *Sim) ThetaCyc(train bool) {
 // args so that it can be used for various different contexts
 // (training, testing, etc).
 func (ss *Sim) ApplyInputs(net *axon.Network, en env.Env) {
+	ss.ApplyStates(net, en.State)
+}
+
+// ApplyStates applies the input patterns returned by given state function,
+// e.g., the State method of an env, or of a stored Replay tuple
+func (ss *Sim) ApplyStates(net *axon.Network, state func(string) etensor.Tensor) {
 	net.InitExt() // clear any existing inputs -- not strictly necessary if always
 	// going to the same layers, but good practice and cheap anyway
 
-	states := []string{"PrevDepth", "Depth", "PrevAction"}
-	lays := []string{"V2Wd", "V2WdP", "Act"}
-	for i, lnm := range lays {
-		lyi := ss.Net.LayerByName(lnm)
+	for _, im := range ss.Inputs {
+		lyi := ss.Net.LayerByName(im.Layer)
 		if lyi == nil {
 			continue
 		}
 		ly := lyi.(axon.AxonLayer).AsAxon()
-		pats := en.State(states[i])
+		pats := state(im.State)
 		if pats != nil {
-			ly.ApplyExt(pats)
+			ly.ApplyExt(ss.ObsNorm.Norm(im.State, pats))
 		}
 	}
 }
 
-// TrainTrial runs one trial of training using TrainEnv
+// TrainTrial runs one trial of training using TrainEnv, or the rest of the
+// current trial if it was stepped partway through
 func (ss *Sim) TrainTrial() {
+	ss.TrainStep(StepTrial)
+}
+
+// TrainEnvStep steps the TrainEnv for a new training trial, with the epoch
+// and run level bookkeeping when the epoch changes -- returns false if the
+// run (or all runs) ended instead.
+// First stage of a trial in the training stepper.
+func (ss *Sim) TrainEnvStep() bool {
 	if ss.NeedsNewRun {
 		ss.NewRun()
 	}
@@ -726,31 +871,51 @@ func (ss *Sim) TrainTrial() {
 	epc, _, chg := ss.TrainEnv.Counter(env.Epoch)
 	if chg {
 		ss.LogTrnEpc(ss.TrnEpcLog)
+		ss.LogWtChg(ss.WtChgLog)
 		ss.TrainSched(epc)
+		ss.PrintProgress()
 		ss.TrainEnv.Event.Cur = 0
 		if ss.ViewOn && ss.TrainUpdt > axon.ThetaCycle {
 			ss.UpdateView(true)
 		}
+		if ss.TestInterval > 0 && epc%ss.TestInterval == 0 { // note: epc is *next* so won't trigger first time
+			ss.TestAll()
+		}
 		if epc >= ss.MaxEpcs {
 			// done with training..
-			if ss.SaveARFs {
-				ss.TestAll() // todo: renable later
+			if ss.SaveARFs || ss.RasterAnalyses() {
+				ss.TestAll()
 			}
 			ss.RunEnd()
 			if ss.TrainEnv.Run.Incr() { // we are done!
 				ss.StopNow = true
-				return
+				return false
 			} else {
 				ss.NeedsNewRun = true
-				return
+				return false
 			}
 		}
 	}
+	return true
+}
 
+// TrainTrialStart applies the inputs for the training trial and starts its
+// theta cycle -- second stage of a trial in the training stepper, followed
+// by the cycles (ThetaCycCycle) and TrainTrialEnd
+func (ss *Sim) TrainTrialStart() {
+	ss.ITICycles()
+	ss.ObsNorm.Update(&ss.TrainEnv)
 	ss.ApplyInputs(ss.Net, &ss.TrainEnv)
-	ss.ThetaCyc(true) // train
-	// ss.TrialStats(true) // now in alphacyc
+	ss.ThetaCycStart(true) // train
+}
+
+// TrainTrialEnd logs the training trial after its theta cycle, which
+// computed the stats -- last stage of a trial in the training stepper
+func (ss *Sim) TrainTrialEnd() {
 	ss.LogTrnTrl(ss.TrnTrlLog)
+	ss.PlanTrial() // sets next action if planning
+	ss.ReplayTrials()
+	ss.Progress.Trial()
 }
 
 // RunEnd is called at the end of a run -- save weights, record final log, etc here
@@ -764,6 +929,9 @@ func (ss *Sim) RunEnd() {
 	if ss.SaveARFs {
 		ss.SaveAllARFs()
 	}
+	ss.SaveRhythmLog()
+	ss.SaveFlowLog()
+	ss.SaveWtChgLog()
 }
 
 // NewRun intializes a new run of the model, using the TrainEnv.Run counter
@@ -772,12 +940,24 @@ func (ss *Sim) NewRun() {
 	run := ss.TrainEnv.Run.Cur
 	ss.PctCortex = 0
 	ss.TrainEnv.Init(run)
-	// ss.TestEnv.Init(run)
+	ss.TestEnv.Init(run)
 	ss.Time.Reset()
 	ss.InitWts(ss.Net)
+	ss.WtChgInit()
 	ss.InitStats()
 	ss.TrnEpcLog.SetNumRows(0)
 	ss.TstEpcLog.SetNumRows(0)
+	ss.PlanLog.SetNumRows(0)
+	ss.RhythmLog.SetNumRows(0)
+	ss.FlowLog.SetNumRows(0)
+	ss.WtChgLog.SetNumRows(0)
+	ss.Planner.Reset()
+	ss.ObsNorm.Reset()
+	ss.Replay.Reset()
+	ss.Rhythm.Reset()
+	ss.Rhythm.ResetHist()
+	ss.Flow.Reset()
+	ss.ResetStage()
 	ss.NeedsNewRun = false
 }
 
@@ -791,6 +971,7 @@ func (ss *Sim) InitStats() {
 	// clear rest just to make Sim look initialized
 	ss.EpcActMatch = 0
 	ss.EpcCosDiff = 0
+	ss.SelfPred.Reset()
 }
 
 // TrialStatsTRC computes the trial-level statistics for TRC layers
@@ -821,17 +1002,18 @@ func (ss *Sim) SetAFMetaData(af etensor.Tensor) {
 
 // UpdtARFs updates position activation rf's
 func (ss *Sim) UpdtARFs() {
+	ev := &ss.TestEnv // only in testing
 	for nm, mt := range ss.RFMaps {
 		mt.SetZeros()
 		switch nm {
 		case "Pos":
-			mt.Set([]int{ss.TrainEnv.PosI.Y, ss.TrainEnv.PosI.X}, 1)
+			mt.Set([]int{ev.PosI.Y, ev.PosI.X}, 1)
 		case "Act":
-			mt.Set1D(ss.TrainEnv.Act, 1)
+			mt.Set1D(ev.Act, 1)
 		case "Ang":
-			mt.Set1D(ss.TrainEnv.Angle/15, 1)
+			mt.Set1D(ev.AngIdx(ev.Angle), 1)
 		case "Rot":
-			mt.Set1D(1+ss.TrainEnv.RotAng/15, 1)
+			mt.Set1D(ev.RotIdx(ev.RotAng), 1)
 		}
 	}
 
@@ -900,6 +1082,7 @@ func (ss *Sim) OpenAllARFs(path gi.FileName) {
 // You can also aggregate directly from log data, as is done for testing stats
 func (ss *Sim) TrialStats(accum bool) {
 	ss.TrialStatsTRC(accum)
+	ss.SelfPredStats(accum)
 	if accum {
 		ss.SumActMatch += ss.ActMatch
 		ss.NumTrlStats++
@@ -911,28 +1094,12 @@ func (ss *Sim) TrialStats(accum bool) {
 
 // TrainEpoch runs training trials for remainder of this epoch
 func (ss *Sim) TrainEpoch() {
-	ss.StopNow = false
-	curEpc := ss.TrainEnv.Epoch.Cur
-	for {
-		ss.TrainTrial()
-		if ss.StopNow || ss.TrainEnv.Epoch.Cur != curEpc {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepEpoch, 1)
 }
 
 // TrainRun runs training trials for remainder of run
 func (ss *Sim) TrainRun() {
-	ss.StopNow = false
-	curRun := ss.TrainEnv.Run.Cur
-	for {
-		ss.TrainTrial()
-		if ss.StopNow || ss.TrainEnv.Run.Cur != curRun {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepRun, 1)
 }
 
 // TrainSched implements the learning rate schedule etc.
@@ -949,27 +1116,20 @@ func (ss *Sim) TrainSched(epc int) {
 	// case 50:
 	// 	ss.ARFs.Reset() // now sufficiently learned to start recording..
 	case 150:
-		ss.Net.LrateSched(0.5)
+		ss.SetLrateSched(0.5)
 		fmt.Printf("dropped lrate 0.5 at epoch: %d\n", epc)
 	case 250:
-		ss.Net.LrateSched(0.2)
+		ss.SetLrateSched(0.2)
 		fmt.Printf("dropped lrate 0.2 at epoch: %d\n", epc)
 	case 350:
-		ss.Net.LrateSched(0.1)
+		ss.SetLrateSched(0.1)
 		fmt.Printf("dropped lrate 0.1 at epoch: %d\n", epc)
 	}
 }
 
 // Train runs the full training from this point onward
 func (ss *Sim) Train() {
-	ss.StopNow = false
-	for {
-		ss.TrainTrial()
-		if ss.StopNow {
-			break
-		}
-	}
-	ss.Stopped()
+	ss.TrainSteps(StepRun, ss.MaxRuns)
 }
 
 // Stop tells the sim to stop running
@@ -1000,52 +1160,48 @@ func (ss *Sim) SaveWeights(filename gi.FileName) {
 ////////////////////////////////////////////////////////////////////////////////////////////
 // Testing
 
-// note: using TrainEnv for everything
-
-// TestTrial runs one trial of testing -- always sequentially presented inputs
+// TestTrial runs one trial of testing on the TestEnv, with frozen weights
 func (ss *Sim) TestTrial(returnOnChg bool) {
-	ss.TrainEnv.Step() // the Env encapsulates and manages all counter state
+	ss.TestEnv.Step() // the Env encapsulates and manages all counter state
 
-	// Key to query counters FIRST because current state is in NEXT epoch
-	// if epoch counter has changed
-	epc, _, chg := ss.TrainEnv.Counter(env.Epoch)
+	// Query counters FIRST
+	_, _, chg := ss.TestEnv.Counter(env.Epoch)
 	if chg {
+		ss.RhythmEpoch()
+		ss.FlowEpoch()
 		ss.LogTstEpc(ss.TstEpcLog)
-		ss.TrainSched(epc)
-		ss.TrainEnv.Event.Cur = 0
-		if ss.ViewOn && ss.TrainUpdt > axon.ThetaCycle {
-			ss.UpdateView(true)
+		if ss.ViewOn && ss.TestUpdt > axon.ThetaCycle {
+			ss.UpdateView(false)
 		}
-		if epc >= ss.TestEpcs {
-			// done with training..
-			ss.RunEnd()
-			if ss.TrainEnv.Run.Incr() { // we are done!
-				ss.StopNow = true
-				return
-			} else {
-				ss.NeedsNewRun = true
-				return
-			}
+		if returnOnChg {
+			return
 		}
 	}
 
-	ss.ApplyInputs(ss.Net, &ss.TrainEnv)
-	ss.ThetaCyc(false) // train
-	// ss.TrialStats(true) // now in alphacyc
+	ss.ITICycles()
+	ss.ApplyInputs(ss.Net, &ss.TestEnv)
+	ss.ThetaCyc(false) // !train
+	// ss.TrialStats(false) // now in alphacyc
+	ss.RhythmTrial()
+	ss.FlowTrial()
 	ss.LogTstTrl(ss.TstTrlLog)
 }
 
-// TestAll runs through the full set of testing items
+// TestAll runs one test: TestTrls trials with frozen weights over a fresh
+// trajectory of the TestEnv, from its start location -- training (the Run
+// and the TrainEnv) is unaffected
 func (ss *Sim) TestAll() {
-	ss.StopNow = false
-	curRun := ss.TrainEnv.Run.Cur
+	ss.FinishTrainTrial()
+	ss.TestEnv.Trial.Max = ss.TestTrls
+	ss.TestEnv.Init(ss.TrainEnv.Run.Cur)
+	ss.Rhythm.ResetHist()
 	for {
-		ss.TestTrial(false)
-		if ss.StopNow || ss.TrainEnv.Run.Cur != curRun {
+		ss.TestTrial(true) // return on change -- don't wrap
+		_, _, chg := ss.TestEnv.Counter(env.Epoch)
+		if chg || ss.StopNow {
 			break
 		}
 	}
-	ss.Stopped()
 }
 
 // RunTestAll runs through the full set of testing items, has stop running = false at end -- for gui
@@ -1058,30 +1214,47 @@ func (ss *Sim) RunTestAll() {
 /////////////////////////////////////////////////////////////////////////
 //   Params setting
 
-// ParamsName returns name of current set of parameters
+// ParamsName returns name of current set of parameters, usable in file
+// names (see paramcomp.FileName)
 func (ss *Sim) ParamsName() string {
 	if ss.ParamSet == "" {
 		return "Base"
 	}
-	return ss.ParamSet
+	return paramcomp.FileName(ss.ParamSet)
 }
 
-// SetParams sets the params for "Base" and then current ParamSet.
+// SetParams sets the params for "Base" and then current ParamSet, as
+// composed by ComposeParams.
 // If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
 // otherwise just the named sheet
 // if setMsg = true then we output a message for each param that was set.
 func (ss *Sim) SetParams(sheet string, setMsg bool) error {
 	if sheet == "" {
 		// this is important for catching typos and ensuring that all sheets can be used
-		ss.Params.ValidateSheets([]string{"Network", "Sim"})
+		ss.Params.ValidateSheets([]string{"Network", "Sim", "LrateMult"})
+	}
+	pset, err := ss.ComposeParams()
+	if err != nil {
+		return err
+	}
+	if sheet == "" && ss.SaveParams != "" {
+		if err := pset.SaveJSON(gi.FileName(ss.SaveParams)); err != nil {
+			log.Println(err)
+		}
 	}
-	err := ss.SetParamsSet("Base", sheet, setMsg)
-	if ss.ParamSet != "" && ss.ParamSet != "Base" {
-		err = ss.SetParamsSet(ss.ParamSet, sheet, setMsg)
+	err = ss.ApplyParamsSet(pset, sheet, setMsg)
+	if sheet == "" || sheet == "Network" {
+		ss.ApplyLrateMults(setMsg) // after all Network sheets set Lrate.Base
 	}
 	return err
 }
 
+// ComposeParams returns the params set composed from Base and the ParamSet
+// spec, with the ParamDeps
+func (ss *Sim) ComposeParams() (*params.Set, error) {
+	return paramcomp.Compose(ss.Params, ParamDeps, ss.ParamSet)
+}
+
 // SetParamsSet sets the params for given params.Set name.
 // If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
 // otherwise just the named sheet
@@ -1091,6 +1264,14 @@ func (ss *Sim) SetParamsSet(setNm string, sheet string, setMsg bool) error {
 	if err != nil {
 		return err
 	}
+	return ss.ApplyParamsSet(pset, sheet, setMsg)
+}
+
+// ApplyParamsSet applies the params of given set.
+// If sheet is empty, then it applies all avail sheets (e.g., Network, Sim)
+// otherwise just the named sheet
+// if setMsg = true then we output a message for each param that was set.
+func (ss *Sim) ApplyParamsSet(pset *params.Set, sheet string, setMsg bool) error {
 	if sheet == "" || sheet == "Network" {
 		netp, ok := pset.Sheets["Network"]
 		if ok {
@@ -1106,7 +1287,7 @@ func (ss *Sim) SetParamsSet(setNm string, sheet string, setMsg bool) error {
 	}
 	// note: if you have more complex environments with parameters, definitely add
 	// sheets for them, e.g., "TrainEnv", "TestEnv" etc
-	return err
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -1175,12 +1356,18 @@ func (ss *Sim) ConfigSpikeGrid(tg *etview.TensorGrid, sr *etensor.Float32) {
 	tg.SetTensor(sr)
 }
 
+// RasterAnalyses returns true if any analyses of the spike rasters are on,
+// so they are recorded during testing without the gui
+func (ss *Sim) RasterAnalyses() bool {
+	return ss.Rhythm.On || ss.Flow.On
+}
+
 // RecordSpikes
 func (ss *Sim) RecordSpikes(cyc int) {
 	for _, lnm := range ss.SpikeRecLays {
 		ly := ss.Net.LayerByName(lnm).(axon.AxonLayer).AsAxon()
 		tv := ss.ValsTsr(lnm)
-		ly.UnitValsTensor(tv, "Spike")
+		ly.UnitValsTensor(tv, ss.RasterVar)
 		sr := ss.SpikeRastTsr(lnm)
 		ss.SetSpikeRastCol(sr, tv, cyc)
 	}
@@ -1274,6 +1461,12 @@ func (ss *Sim) LogTrnEpc(dt *etable.Table) {
 	dt.SetCellFloat("Epoch", row, float64(epc))
 	dt.SetCellFloat("ActMatch", row, ss.EpcActMatch)
 	dt.SetCellFloat("CosDiff", row, ss.EpcCosDiff)
+	if ss.SelfPred.On() {
+		dt.SetCellFloat("SelfCosDiff", row, ss.SelfPred.EpcStats())
+	}
+	if ss.Replay.On() {
+		dt.SetCellFloat("ReplayCosDiff", row, ss.Replay.EpcStats())
+	}
 
 	for _, lnm := range ss.TrainEnv.Acts {
 		rw := ss.TrnErrStats.RowsByString("GenAction", lnm, etable.Equals, etable.UseCase)
@@ -1292,12 +1485,12 @@ func (ss *Sim) LogTrnEpc(dt *etable.Table) {
 		dt.SetCellFloat(lnm+"_MaxGeM", row, float64(ly.ActAvg.AvgMaxGeM))
 		dt.SetCellFloat(lnm+"_ActAvg", row, float64(ly.ActAvg.ActMAvg))
 		for _, act := range ss.CosDifActs {
-			arow := ss.TrnErrStats.RowsByString("GenAction", act, etable.Equals, etable.UseCase)
+			arow := ss.TrnErrStats.RowsByString("GenAction", act.String(), etable.Equals, etable.UseCase)
 			if len(arow) != 1 {
 				continue
 			}
 			val := ss.TrnErrStats.Cols[2+li].FloatVal1D(arow[0])
-			dt.SetCellFloat(lnm+"_CosDiff_"+act, row, val)
+			dt.SetCellFloat(lnm+"_CosDiff_"+act.String(), row, val)
 		}
 	}
 
@@ -1340,6 +1533,12 @@ func (ss *Sim) ConfigTrnEpcLog(dt *etable.Table) {
 		{"ActMatch", etensor.FLOAT64, nil, nil},
 		{"CosDiff", etensor.FLOAT64, nil, nil},
 	}
+	if ss.SelfPred.On() {
+		sch = append(sch, etable.Column{"SelfCosDiff", etensor.FLOAT64, nil, nil})
+	}
+	if ss.Replay.On() {
+		sch = append(sch, etable.Column{"ReplayCosDiff", etensor.FLOAT64, nil, nil})
+	}
 	for _, lnm := range ss.TrainEnv.Acts {
 		sch = append(sch, etable.Column{lnm + "Cor", etensor.FLOAT64, nil, nil})
 	}
@@ -1351,7 +1550,7 @@ func (ss *Sim) ConfigTrnEpcLog(dt *etable.Table) {
 		sch = append(sch, etable.Column{lnm + "_MaxGeM", etensor.FLOAT64, nil, nil})
 		sch = append(sch, etable.Column{lnm + "_ActAvg", etensor.FLOAT64, nil, nil})
 		for _, act := range ss.CosDifActs {
-			sch = append(sch, etable.Column{lnm + "_CosDiff_" + act, etensor.FLOAT64, nil, nil})
+			sch = append(sch, etable.Column{lnm + "_CosDiff_" + act.String(), etensor.FLOAT64, nil, nil})
 		}
 	}
 	for _, lnm := range ss.HidLays {
@@ -1377,6 +1576,12 @@ func (ss *Sim) ConfigTrnEpcPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("ActMatch", eplot.Off, eplot.FixMin, 0, eplot.FixMax, .25)
 	plt.SetColParams("CosDiff", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	if ss.SelfPred.On() {
+		plt.SetColParams("SelfCosDiff", eplot.On, eplot.FixMin, -1, eplot.FixMax, 1)
+	}
+	if ss.Replay.On() {
+		plt.SetColParams("ReplayCosDiff", eplot.On, eplot.FixMin, -1, eplot.FixMax, 1)
+	}
 
 	for _, lnm := range ss.TrainEnv.Acts {
 		plt.SetColParams(lnm+"Cor", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
@@ -1389,7 +1594,7 @@ func (ss *Sim) ConfigTrnEpcPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 		plt.SetColParams(lnm+"_MaxGeM", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
 		plt.SetColParams(lnm+"_ActAvg", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, .25)
 		for _, act := range ss.CosDifActs {
-			plt.SetColParams(lnm+"_CosDiff_"+act, eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+			plt.SetColParams(lnm+"_CosDiff_"+act.String(), eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
 		}
 	}
 	for _, lnm := range ss.HidLays {
@@ -1500,10 +1705,10 @@ func (ss *Sim) LogTstTrl(dt *etable.Table) {
 	row := dt.Rows
 	dt.SetNumRows(row + 1)
 
-	env := &ss.TrainEnv
+	env := &ss.TestEnv
 
-	dt.SetCellFloat("Run", row, float64(env.Run.Cur))
-	dt.SetCellFloat("Epoch", row, float64(env.Epoch.Cur))
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ss.TrainEnv.Epoch.Cur)) // training epoch at which the test is run
 	dt.SetCellFloat("Event", row, float64(env.Event.Cur))
 	dt.SetCellFloat("X", row, float64(env.PosI.X))
 	dt.SetCellFloat("Y", row, float64(env.PosI.Y))
@@ -1513,8 +1718,8 @@ func (ss *Sim) LogTstTrl(dt *etable.Table) {
 	dt.SetCellFloat("ActMatch", row, ss.ActMatch)
 	dt.SetCellFloat("CosDiff", row, ss.TrlCosDiff)
 
-	for _, lnm := range ss.TrainEnv.Inters {
-		dt.SetCellFloat(lnm, row, float64(ss.TrainEnv.InterStates[lnm]))
+	for _, lnm := range env.Inters {
+		dt.SetCellFloat(lnm, row, float64(env.InterStates[lnm]))
 	}
 	// note: essential to use Go version of update when called from another goroutine
 	ss.TstTrlPlot.GoUpdate()
@@ -1572,7 +1777,7 @@ func (ss *Sim) LogTstEpc(dt *etable.Table) {
 	row := dt.Rows
 	dt.SetNumRows(row + 1)
 
-	epc := ss.TrainEnv.Epoch.Prv // this is triggered by increment so use previous value
+	epc := ss.TrainEnv.Epoch.Cur // training epoch at which the test is run
 
 	trl := ss.TstTrlLog
 	trlix := etable.NewIdxView(trl)
@@ -1606,7 +1811,7 @@ func (ss *Sim) LogTstEpc(dt *etable.Table) {
 	// note: essential to use Go version of update when called from another goroutine
 	ss.TstEpcPlot.GoUpdate()
 	if ss.TstEpcFile != nil {
-		if ss.TrainEnv.Run.Cur == 0 && epc == ss.MaxEpcs {
+		if row == 0 && ss.TrainEnv.Run.Cur == 0 {
 			dt.WriteCSVHeaders(ss.TstEpcFile, etable.Tab)
 		}
 		dt.WriteCSVRow(ss.TstEpcFile, row, etable.Tab)
@@ -1947,7 +2152,7 @@ func (ss *Sim) UpdateWorldGui() {
 	}
 
 	nc := len(ss.TrainEnv.Mats)
-	ss.Trace.Set([]int{ss.TrainEnv.PosI.Y, ss.TrainEnv.PosI.X}, nc+ss.TrainEnv.Angle/ss.TrainEnv.AngInc)
+	ss.Trace.Set([]int{ss.TrainEnv.PosI.Y, ss.TrainEnv.PosI.X}, nc+ss.TrainEnv.AngIdx(ss.TrainEnv.Angle))
 
 	updt := ss.WorldTabs.UpdateStart()
 	ss.TraceView.UpdateSig()
@@ -1955,32 +2160,32 @@ func (ss *Sim) UpdateWorldGui() {
 }
 
 func (ss *Sim) Left() {
-	ss.TrainEnv.Action("Left", nil)
+	ss.TrainEnv.ExecAct(NewAction(Left))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Right() {
-	ss.TrainEnv.Action("Right", nil)
+	ss.TrainEnv.ExecAct(NewAction(Right))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Forward() {
-	ss.TrainEnv.Action("Forward", nil)
+	ss.TrainEnv.ExecAct(NewAction(Forward))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Backward() {
-	ss.TrainEnv.Action("Backward", nil)
+	ss.TrainEnv.ExecAct(NewAction(Backward))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Eat() {
-	ss.TrainEnv.Action("Eat", nil)
+	ss.TrainEnv.ExecAct(NewAction(Eat))
 	ss.UpdateWorldGui()
 }
 
 func (ss *Sim) Drink() {
-	ss.TrainEnv.Action("Drink", nil)
+	ss.TrainEnv.ExecAct(NewAction(Drink))
 	ss.UpdateWorldGui()
 }
 
@@ -2055,6 +2260,15 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	plt = tv.AddNewTab(eplot.KiT_Plot2D, "RunPlot").(*eplot.Plot2D)
 	ss.RunPlot = ss.ConfigRunPlot(plt, ss.RunLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "PlanPlot").(*eplot.Plot2D)
+	ss.PlanPlot = ss.ConfigPlanPlot(plt, ss.PlanLog)
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "WtChgPlot").(*eplot.Plot2D)
+	ss.WtChgPlot = ss.ConfigWtChgPlot(plt, ss.WtChgLog)
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "RhythmPlot").(*eplot.Plot2D)
+	ss.RhythmPlot = ss.ConfigRhythmPlot(plt, ss.RhythmLog)
+
 	split.SetSplits(.3, .7)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -2082,13 +2296,32 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		ss.Stop()
 	})
 
-	tbar.AddAction(gi.ActOpts{Label: "Step Trial", Icon: "step-fwd", Tooltip: "Advances one training trial at a time.", UpdateFunc: func(act *gi.Action) {
+	tbar.AddAction(gi.ActOpts{Label: "Step Cycle", Icon: "step-fwd", Tooltip: "Advances one training cycle at a time.", UpdateFunc: func(act *gi.Action) {
 		act.SetActiveStateUpdt(!ss.IsRunning)
 	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
 		if !ss.IsRunning {
 			ss.IsRunning = true
-			ss.TrainTrial()
-			ss.IsRunning = false
+			ss.TrainSteps(StepCycle, 1)
+			vp.SetNeedsFullRender()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Step Quarter", Icon: "step-fwd", Tooltip: "Advances to the end of the current quarter (25 cycles, or the end of a phase) of the training trial.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			ss.TrainSteps(StepQuarter, 1)
+			vp.SetNeedsFullRender()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Step Trial", Icon: "step-fwd", Tooltip: "Advances one training trial at a time -- or to the end of the current trial, if it was stepped partway through.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			ss.TrainSteps(StepTrial, 1)
 			vp.SetNeedsFullRender()
 		}
 	})
@@ -2288,18 +2521,73 @@ func (ss *Sim) CmdArgs() {
 	var saveEpcLog bool
 	var saveRunLog bool
 	var note string
-	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
+	var bench bool
+	var itiDecay float64
+	var itiGlong float64
+	var obsNorm string
+	var inputs string
+	var selfPred string
+	var replayAlpha float64
+	var step string
+	var nSteps int
+	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet to use on top of Base -- names of sets as listed in compiled-in params or loaded params, and numeric overrides, separated by +, e.g., MoreNoise+Gi=1.6")
+	flag.StringVar(&ss.SaveParams, "save-params", "", "if set, save the fully composed params set applied to this file, as JSON")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
 	flag.StringVar(&note, "note", "", "user note -- describe the run params etc")
 	flag.IntVar(&ss.MaxRuns, "runs", 1, "number of runs to do (note that MaxEpcs is in paramset)")
+	flag.IntVar(&ss.TestInterval, "test-interval", 50000, "run a test every this many training epochs -- 0 = never")
+	flag.IntVar(&ss.TestTrls, "test-trls", 200, "number of trials in each test")
 	flag.BoolVar(&ss.LogSetParams, "setparams", false, "if true, print a record of each parameter that is set")
 	flag.BoolVar(&ss.SaveWts, "wts", false, "if true, save final weights after each run")
 	flag.BoolVar(&ss.SaveARFs, "arfs", false, "if true, save final arfs after each run")
 	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
 	flag.BoolVar(&saveRunLog, "runlog", false, "if true, save run epoch log to file")
 	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	flag.StringVar(&step, "step", "", "instead of training all the runs, train -nsteps steps of given grain: Cycle, Quarter, Trial, Epoch or Run")
+	flag.IntVar(&nSteps, "nsteps", 1, "number of -step steps to train")
 	flag.BoolVar(&ss.UseMPI, "mpi", false, "if set, use MPI for distributed computation")
+	flag.BoolVar(&ss.Planner.On, "plan", false, "if set, use the model-based planner to select actions toward the goal when in view")
+	flag.IntVar(&ss.Planner.Depth, "plan-depth", 3, "number of steps in each imagined action sequence for the planner")
+	flag.IntVar(&ss.Planner.Width, "plan-width", 8, "number of candidate action sequences evaluated by the planner")
+	flag.BoolVar(&bench, "bench", false, "if true, run the standard benchmark protocol and append the results to the scoreboard file, instead of the usual runs")
+	flag.IntVar(&ss.ITI.Cycles, "iti-cycles", 0, "number of blank cycles with all inputs off between trials -- 0 = none")
+	flag.Float64Var(&itiDecay, "iti-decay", 0, "proportion of activation state decayed between trials, before the -iti-cycles -- 0 = none, 1 = full reset")
+	flag.Float64Var(&itiGlong, "iti-glong", 0, "proportion of long time-constant conductances (NMDA, GABA-B) decayed between trials")
+	flag.IntVar(&ss.TrainEnv.AngInc, "ang-inc", 15, "head direction resolution: angle increment for rotation, in degrees -- must evenly divide 360, with FOV an even multiple of it")
+	flag.StringVar(&inputs, "inputs", "", "env states applied to the input and target layers, as comma-separated State:Layer entries -- empty = PrevDepth:V2Wd,Depth:V2WdP,PrevAction:Act")
+	flag.BoolVar(&ss.StrictInputs, "strict-inputs", false, "if true, exit at Config if any layer or state of the -inputs does not exist, instead of skipping it")
+	flag.StringVar(&obsNorm, "obs-norm", "", "env states to normalize by their running mean and variance over training, as comma-separated State:Mode entries with Mode Center, ZScore or Whiten, e.g., Depth:Whiten")
+	flag.StringVar(&selfPred, "self-pred", "", "comma-separated superficial hidden layers that also learn to predict their own next-step activity from their CT layer, e.g., MSTd")
+	flag.IntVar(&ss.Replay.N, "replay", 0, "number of replay trials from a prioritized buffer of recent training trials after each training trial -- 0 = none")
+	flag.IntVar(&ss.Replay.Cap, "replay-cap", 1000, "capacity of the -replay buffer, in training trials")
+	flag.Float64Var(&replayAlpha, "replay-alpha", 0.6, "exponent of the prediction error priorities in -replay sampling: 0 = uniform")
+	flag.BoolVar(&ss.WtChg.On, "wt-chg", false, "if true, log the mean L2 norm of DWt per trial, and of the cumulative weight change since the start of the run, of each prjn per epoch")
+	flag.BoolVar(&ss.Rhythm.On, "rhythm", false, "if true, test after training and log the autocorrelation and spectral peaks of the cycle-level activity of the recorded layers, for rhythmicity in the theta band")
+	flag.IntVar(&ss.Rhythm.MaxLag, "rhythm-lag", 250, "maximum lag of the -rhythm autocorrelations, in cycles (msec)")
+	flag.BoolVar(&ss.Flow.On, "flow", false, "if true, test after training and log the Granger causality and transfer entropy between the recorded layers")
+	flag.IntVar(&ss.Flow.Order, "flow-order", 4, "number of past time steps of -flow, of 5 cycles each, used to predict each step")
+	flag.StringVar(&ss.RasterVar, "raster-var", "Spike", "unit variable recorded in the spike rasters used by -rhythm and -flow: Spike, or a rate code such as Act")
+	flag.StringVar(&ss.Bench.File, "bench-file", "ffpred_bench.tsv", "scoreboard file for -bench results")
 	flag.Parse()
+	ss.ITI.Decay = float32(itiDecay)
+	ss.ITI.Glong = float32(itiGlong)
+	ss.Replay.Alpha = float32(replayAlpha)
+	if ims, err := ParseInputs(inputs); err != nil {
+		if ss.StrictInputs {
+			log.Fatalln(err)
+		}
+		log.Println(err)
+	} else {
+		ss.Inputs = ims
+	}
+	if sts, err := ParseObsNorm(obsNorm); err != nil {
+		log.Println(err)
+	} else {
+		ss.ObsNorm.States = sts
+	}
+	if selfPred != "" {
+		ss.SelfPred.Lays = strings.Split(selfPred, ",")
+	}
 	ss.Init()
 
 	if ss.UseMPI {
@@ -2321,6 +2609,11 @@ func (ss *Sim) CmdArgs() {
 		fmt.Printf("Using ParamSet: %s\n", ss.ParamSet)
 	}
 
+	if bench {
+		ss.RunBench()
+		return
+	}
+
 	if saveEpcLog {
 		var err error
 		fnm := ss.LogFileName("trn_epc")
@@ -2357,6 +2650,16 @@ func (ss *Sim) CmdArgs() {
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
+	if step != "" {
+		var grain StepGrains
+		if err := grain.FromString(step); err != nil {
+			log.Println(err)
+			return
+		}
+		fmt.Printf("Running %d %s steps\n", nSteps, grain)
+		ss.TrainSteps(grain, nSteps)
+		return
+	}
 	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
 	ss.Train()
 }
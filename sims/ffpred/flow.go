@@ -0,0 +1,235 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// Flow measures the directed information flow between the SpikeRecLays
+// during testing (e.g., MSTd -> MSTdCT -> V2WdP), from the time series of
+// their mean activity in the cycle-level raster recordings, averaged in
+// bins of Bin cycles and concatenated over the test trials (skipping the
+// ITI cycles, which are not recorded).  At the end of each test, the
+// pairwise linear Granger causality of each layer on each other is
+// computed, with Order bins of history: the log ratio of the residual
+// variance of the prediction of the target from its own past, to that from
+// its own and the source's past.  For Gaussian signals, the transfer
+// entropy is half the Granger causality, in nats.  The From x To matrices
+// of each epoch are logged to the FlowLog.
+type Flow struct {
+	On    bool `desc:"compute the information flow between the SpikeRecLays during testing"`
+	Bin   int  `def:"5" min:"1" desc:"number of cycles (msec) averaged in each time step of the series"`
+	Order int  `def:"4" min:"1" desc:"number of past time steps used to predict each step"`
+
+	Series map[string][]float64 `view:"-" desc:"time series of the mean activity of each layer since the last epoch"`
+}
+
+// Defaults sets default params
+func (fl *Flow) Defaults() {
+	fl.Bin = 5
+	fl.Order = 4
+}
+
+// Reset clears the time series
+func (fl *Flow) Reset() {
+	for lnm := range fl.Series {
+		fl.Series[lnm] = fl.Series[lnm][:0]
+	}
+}
+
+// ResidVar returns the residual variance of the least-squares prediction of
+// y from the Order past steps of each of the srcs (which should include y
+// itself for an autoregression), with an intercept
+func (fl *Flow) ResidVar(y []float64, srcs ...[]float64) float64 {
+	p := fl.Order
+	n := len(y) - p
+	k := 1 + p*len(srcs)
+	if n <= k {
+		return 0
+	}
+	xs := make([]float64, k)
+	regs := func(t int) []float64 {
+		xs[0] = 1
+		for si, s := range srcs {
+			for l := 1; l <= p; l++ {
+				xs[1+si*p+l-1] = s[t-l]
+			}
+		}
+		return xs
+	}
+	xtx := make([]float64, k*k)
+	xty := make([]float64, k)
+	for t := p; t < len(y); t++ {
+		x := regs(t)
+		for i := 0; i < k; i++ {
+			xty[i] += x[i] * y[t]
+			for j := 0; j < k; j++ {
+				xtx[i*k+j] += x[i] * x[j]
+			}
+		}
+	}
+	for i := 0; i < k; i++ {
+		xtx[i*k+i] += 1e-9 * (1 + xtx[i*k+i]) // ridge for stability
+	}
+	b := SolveLinear(xtx, xty, k)
+	ss := 0.0
+	for t := p; t < len(y); t++ {
+		x := regs(t)
+		pr := 0.0
+		for i, bi := range b {
+			pr += bi * x[i]
+		}
+		d := y[t] - pr
+		ss += d * d
+	}
+	return ss / float64(n)
+}
+
+// SolveLinear solves the k x k system a x = b by Gaussian elimination with
+// partial pivoting, overwriting a and b -- singular components are 0
+func SolveLinear(a, b []float64, k int) []float64 {
+	for c := 0; c < k; c++ {
+		piv := c
+		for r := c + 1; r < k; r++ {
+			if math.Abs(a[r*k+c]) > math.Abs(a[piv*k+c]) {
+				piv = r
+			}
+		}
+		if a[piv*k+c] == 0 {
+			continue
+		}
+		if piv != c {
+			for j := 0; j < k; j++ {
+				a[c*k+j], a[piv*k+j] = a[piv*k+j], a[c*k+j]
+			}
+			b[c], b[piv] = b[piv], b[c]
+		}
+		for r := c + 1; r < k; r++ {
+			f := a[r*k+c] / a[c*k+c]
+			if f == 0 {
+				continue
+			}
+			for j := c; j < k; j++ {
+				a[r*k+j] -= f * a[c*k+j]
+			}
+			b[r] -= f * b[c]
+		}
+	}
+	x := make([]float64, k)
+	for r := k - 1; r >= 0; r-- {
+		if a[r*k+r] == 0 {
+			continue
+		}
+		s := b[r]
+		for j := r + 1; j < k; j++ {
+			s -= a[r*k+j] * x[j]
+		}
+		x[r] = s / a[r*k+r]
+	}
+	return x
+}
+
+// Granger returns the Granger causality of series x on series y: the log
+// ratio of the residual variance of y predicted from its own past, to that
+// from its own and x's past -- 0 if y is fully predicted or constant
+func (fl *Flow) Granger(x, y []float64) float64 {
+	vr := fl.ResidVar(y, y)
+	vf := fl.ResidVar(y, y, x)
+	if vr <= 0 || vf <= 0 {
+		return 0
+	}
+	return math.Max(math.Log(vr/vf), 0)
+}
+
+// FlowTrial adds the binned mean activity of the recorded layers on the
+// current test trial to their series -- called in TestTrial
+func (ss *Sim) FlowTrial() {
+	fl := &ss.Flow
+	if !fl.On {
+		return
+	}
+	if fl.Series == nil {
+		fl.Series = make(map[string][]float64)
+	}
+	for _, lnm := range ss.SpikeRecLays {
+		sr := ss.SpikeRastTsr(lnm)
+		if sr.Len() == 0 {
+			continue
+		}
+		nu, nc := sr.Dim(0), sr.Dim(1)
+		for c0 := 0; c0+fl.Bin <= nc; c0 += fl.Bin {
+			sum := 0.0
+			for ui := 0; ui < nu; ui++ {
+				for _, v := range sr.Values[ui*nc+c0 : ui*nc+c0+fl.Bin] {
+					sum += float64(v)
+				}
+			}
+			fl.Series[lnm] = append(fl.Series[lnm], sum/float64(nu*fl.Bin))
+		}
+	}
+}
+
+// FlowEpoch computes the Granger causality and transfer entropy matrices
+// between the recorded layers from the series since the last time, logging
+// them to the FlowLog -- called at the end of each test
+func (ss *Sim) FlowEpoch() {
+	fl := &ss.Flow
+	if !fl.On {
+		return
+	}
+	nl := len(ss.SpikeRecLays)
+	dt := ss.FlowLog
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ss.TrainEnv.Epoch.Cur))
+	gc := dt.CellTensor("Granger", row).(*etensor.Float64)
+	te := dt.CellTensor("TE", row).(*etensor.Float64)
+	for fi, fnm := range ss.SpikeRecLays {
+		for ti, tnm := range ss.SpikeRecLays {
+			x, y := fl.Series[fnm], fl.Series[tnm]
+			if fi == ti || len(x) != len(y) {
+				continue
+			}
+			g := fl.Granger(x, y)
+			gc.Values[fi*nl+ti] = g
+			te.Values[fi*nl+ti] = g / 2
+		}
+	}
+	fl.Reset()
+}
+
+// SaveFlowLog saves the FlowLog of the run, if any -- called in RunEnd
+func (ss *Sim) SaveFlowLog() {
+	if !ss.Flow.On || ss.FlowLog.Rows == 0 {
+		return
+	}
+	fnm := ss.LogFileName(fmt.Sprintf("flow_run%03d", ss.TrainEnv.Run.Cur))
+	ss.FlowLog.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+}
+
+func (ss *Sim) ConfigFlowLog(dt *etable.Table) {
+	dt.SetMetaData("name", "FlowLog")
+	dt.SetMetaData("desc", "Granger causality and transfer entropy between the recorded layers over testing, as From x To matrices")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	nl := len(ss.SpikeRecLays)
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Granger", etensor.FLOAT64, []int{nl, nl}, []string{"From", "To"}},
+		{"TE", etensor.FLOAT64, []int{nl, nl}, []string{"From", "To"}},
+	}
+	dt.SetFromSchema(sch, 0)
+	dt.SetMetaData("Layers", fmt.Sprint(ss.SpikeRecLays))
+}
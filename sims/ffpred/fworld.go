@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"math"
 	"math/rand"
 	"os"
@@ -34,6 +35,7 @@ type FWorld struct {
 	Size        evec.Vec2i                  `desc:"size of 2D world"`
 	PatSize     evec.Vec2i                  `desc:"size of patterns for mats, acts"`
 	World       *etensor.Int                `view:"no-inline" desc:"2D grid world, each cell is a material (mat)"`
+	KeepWorld   bool                        `desc:"keep the existing world.tsv instead of generating a new world in Config -- e.g., to use the same world across runs"`
 	Mats        []string                    `desc:"list of materials in the world, 0 = empty.  Any superpositions of states (e.g., CoveredFood) need to be discretely encoded, can be transformed through action rules"`
 	MatMap      map[string]int              `desc:"map of material name to index stored in world cell"`
 	BarrierIdx  int                         `desc:"index of material below which (inclusive) cannot move -- e.g., 1 for wall"`
@@ -44,7 +46,7 @@ type FWorld struct {
 	InterMap    map[string]int              `desc:"map of interoceptive state names to indexes"`
 	Params      map[string]float32          `desc:"map of optional interoceptive and world-dynamic parameters -- cleaner to store in a map"`
 	FOV         int                         `desc:"field of view in degrees, e.g., 180, must be even multiple of AngInc"`
-	AngInc      int                         `desc:"angle increment for rotation, in degrees -- defaults to 15"`
+	AngInc      int                         `desc:"angle increment for rotation, in degrees -- must evenly divide 360, and FOV / AngInc must be even -- defaults to 15 -- determines NFOVRays and NRotAngles"`
 	NRotAngles  int                         `inactive:"+" desc:"total number of rotation angles in a circle"`
 	NFOVRays    int                         `inactive:"+" desc:"total number of FOV rays that are traced"`
 	ShowRays    bool                        `desc:"for debugging only: show the main depth rays as they are traced out from point"`
@@ -64,6 +66,8 @@ type FWorld struct {
 	Angle         int                         `inactive:"+" desc:"current angle, in degrees"`
 	RotAng        int                         `inactive:"+" desc:"angle that we just rotated -- drives vestibular"`
 	Act           int                         `inactive:"+" desc:"last action taken"`
+	ActExec       Action                      `inactive:"+" desc:"last action executed, with its continuous parameters"`
+	ExtAct        int                         `inactive:"+" desc:"if >= 0, action to take on the next step instead of the ActGen action -- e.g., set by the planner, reset after each step"`
 	Depths        []float32                   `desc:"depth for each angle (NFOVRays), raw"`
 	DepthLogs     []float32                   `desc:"depth for each angle (NFOVRays), normalized log"`
 	ViewMats      []int                       `inactive:"+" desc:"material at each angle"`
@@ -97,7 +101,7 @@ func (ev *FWorld) Config(ntrls int) {
 	ev.Dsc = "Example world with basic food / water / eat / drink actions"
 	ev.Mats = []string{"Empty", "Wall", "Food", "Water", "FoodWas", "WaterWas"}
 	ev.BarrierIdx = 1
-	ev.Acts = []string{"Stay", "Left", "Right", "Forward", "Backward", "Eat", "Drink"}
+	ev.Acts = ActionNames()
 	ev.Inters = []string{"Energy", "Hydra", "BumpPain", "FoodRew", "WaterRew"}
 
 	ev.Params = make(map[string]float32)
@@ -116,8 +120,14 @@ func (ev *FWorld) Config(ntrls int) {
 	ev.Disp = false
 	ev.Size.Set(100, 100)
 	ev.PatSize.Set(5, 5)
-	ev.AngInc = 15
+	if ev.AngInc == 0 { // allow user override
+		ev.AngInc = 15
+	}
 	ev.FOV = 180
+	if err := ev.ValidateAngInc(); err != nil {
+		log.Println(err)
+		ev.AngInc = 15
+	}
 	ev.FoveaSize = 1
 	ev.FoveaAngInc = 5
 	ev.PopSize = 16
@@ -139,8 +149,10 @@ func (ev *FWorld) Config(ntrls int) {
 	ev.ConfigImpl()
 
 	// uncomment to generate a new world
-	ev.GenWorld()
-	ev.SaveWorld("world.tsv")
+	if !ev.KeepWorld {
+		ev.GenWorld()
+		ev.SaveWorld("world.tsv")
+	}
 }
 
 // ConfigPats configures the bit pattern representations of mats and acts
@@ -251,15 +263,53 @@ func (ev *FWorld) Validate() error {
 	if ev.Size.IsNil() {
 		return fmt.Errorf("FWorld: %v has size == 0 -- need to Config", ev.Nm)
 	}
+	return ev.ValidateAngInc()
+}
+
+// ValidateAngInc returns an error if AngInc is not a positive divisor of 360,
+// or FOV is not an even multiple of it
+func (ev *FWorld) ValidateAngInc() error {
+	if ev.AngInc <= 0 || 360%ev.AngInc != 0 {
+		return fmt.Errorf("FWorld: %v AngInc: %d must be a positive divisor of 360", ev.Nm, ev.AngInc)
+	}
+	if (ev.FOV/ev.AngInc)%2 != 0 || ev.FOV%ev.AngInc != 0 {
+		return fmt.Errorf("FWorld: %v FOV: %d must be an even multiple of AngInc: %d", ev.Nm, ev.FOV, ev.AngInc)
+	}
 	return nil
 }
 
+// AngIdx returns the index of given angle among the NRotAngles angles,
+// rounding to the nearest AngInc
+func (ev *FWorld) AngIdx(ang int) int {
+	ai := int(mat32.Round(float32(AngMod(ang)) / float32(ev.AngInc)))
+	return ints.MinInt(ints.MaxInt(ai, 0), ev.NRotAngles-1)
+}
+
+// RotIdx returns the index of given rotation among the 3 rotations:
+// -AngInc, 0, +AngInc, rounding to the nearest AngInc
+func (ev *FWorld) RotIdx(rot int) int {
+	ri := 1 + int(mat32.Round(float32(rot)/float32(ev.AngInc)))
+	return ints.MinInt(ints.MaxInt(ri, 0), 2)
+}
+
 func (ev *FWorld) State(element string) etensor.Tensor {
+	sts := ev.NextStates
 	if strings.HasPrefix(element, "Prev") {
 		element = element[4:]
-		return ev.CurStates[element] // cur for prediction, Next for encoder
+		sts = ev.CurStates // cur for prediction, Next for encoder
+	}
+	st, ok := sts[element]
+	if !ok {
+		return nil // not a nil *Float32, which is a non-nil Tensor
 	}
-	return ev.NextStates[element]
+	return st
+}
+
+// HasState returns true if the env has a state of given name, available
+// from Config on, before the first Step
+func (ev *FWorld) HasState(element string) bool {
+	_, ok := ev.NextStates[strings.TrimPrefix(element, "Prev")]
+	return ok
 }
 
 // String returns the current state as a string
@@ -294,6 +344,7 @@ func (ev *FWorld) Init(run int) {
 
 	ev.Angle = 0
 	ev.RotAng = 0
+	ev.ExtAct = -1
 	ev.InterStates["Energy"] = 1
 	ev.InterStates["Hydra"] = 1
 	ev.InterStates["BumpPain"] = 0
@@ -611,13 +662,30 @@ func (ev *FWorld) RefreshWorld() {
 
 // TakeAct takes the action, updates state
 func (ev *FWorld) TakeAct() {
-	act := ev.ActGen()
-	ev.Act = act
-	as := ""
-	if act >= len(ev.Acts) || act < 0 {
-		as = "Stay"
-	} else {
-		as = ev.Acts[act]
+	act := ev.ExtAct
+	if act < 0 {
+		act = ev.ActGen()
+	}
+	ev.ExtAct = -1
+	ev.ExecAct(NewAction(Actions(act)))
+}
+
+// ExecAct executes the given action, updates state -- an invalid action is
+// a Stay
+func (ev *FWorld) ExecAct(ac Action) {
+	if ac.Act < 0 || ac.Act >= ActionsN {
+		ac = NewAction(Stay)
+	}
+	ev.ActExec = ac
+	ev.Act = int(ac.Act)
+	act := ev.Act
+	turn := ev.AngInc
+	if ac.Turn != 0 {
+		turn = int(mat32.Round(ac.Turn))
+	}
+	step := 1
+	if ac.Step != 0 {
+		step = ints.MaxInt(1, int(mat32.Round(ac.Step)))
 	}
 	ev.PassTime()
 
@@ -625,8 +693,7 @@ func (ev *FWorld) TakeAct() {
 
 	nmat := len(ev.Mats)
 	frmat := ints.MinInt(ev.ProxMats[0], nmat)
-	behmat := ev.ProxMats[3] // behind
-	front := ev.Mats[frmat]  // state in front
+	front := ev.Mats[frmat] // state in front
 
 	mvc := ev.Params["MoveCost"]
 	rotc := ev.Params["RotCost"]
@@ -635,39 +702,35 @@ func (ev *FWorld) TakeAct() {
 	ecost := float32(0) // extra energy cost
 	hcost := float32(0) // extra hydra cost
 
-	switch as {
-	case "Stay":
-	case "Left":
-		ev.RotAng = ev.AngInc
+	switch ac.Act {
+	case Stay:
+	case Left:
+		ev.RotAng = turn
 		ev.Angle = AngMod(ev.Angle + ev.RotAng)
 		ecost = rotc
 		hcost = rotc
-	case "Right":
-		ev.RotAng = -ev.AngInc
+	case Right:
+		ev.RotAng = -turn
 		ev.Angle = AngMod(ev.Angle + ev.RotAng)
 		ecost = rotc
 		hcost = rotc
-	case "Forward":
+	case Forward:
 		ecost = mvc
 		hcost = mvc
-		if frmat > 0 && frmat <= ev.BarrierIdx {
+		if ev.MoveStep(ev.Angle, step) {
 			ev.InterStates["BumpPain"] = 1
 			ecost += bumpc
 			hcost += bumpc
-		} else {
-			ev.PosF, ev.PosI = NextVecPoint(ev.PosF, AngVec(ev.Angle))
 		}
-	case "Backward":
+	case Backward:
 		ecost = mvc
 		hcost = mvc
-		if behmat > 0 && behmat <= ev.BarrierIdx {
+		if ev.MoveStep(AngMod(ev.Angle+180), step) {
 			ev.InterStates["BumpPain"] = 1
 			ecost += bumpc
 			hcost += bumpc
-		} else {
-			ev.PosF, ev.PosI = NextVecPoint(ev.PosF, AngVec(AngMod(ev.Angle+180)))
 		}
-	case "Eat":
+	case Eat:
 		if front == "Food" {
 			ev.InterStates["FoodRew"] = 1
 			hcost += ev.Params["EatCost"]
@@ -677,7 +740,7 @@ func (ev *FWorld) TakeAct() {
 			ev.Event.Set(0)
 			ev.Scene.Incr()
 		}
-	case "Drink":
+	case Drink:
 		if front == "Water" {
 			ev.InterStates["WaterRew"] = 1
 			ecost += ev.Params["DrinkCost"]
@@ -698,6 +761,21 @@ func (ev *FWorld) TakeAct() {
 	ev.RenderState()
 }
 
+// MoveStep moves by step grid cells along given angle, checking every cell
+// along the step, and stopping before the first barrier -- returns true if
+// the step was blocked before its full length
+func (ev *FWorld) MoveStep(ang int, step int) bool {
+	v := AngVec(ang)
+	for i := 0; i < step; i++ {
+		pf, gp := NextVecPoint(ev.PosF, v)
+		if mat := ev.GetWorld(gp); mat > 0 && mat <= ev.BarrierIdx {
+			return true
+		}
+		ev.PosF, ev.PosI = pf, gp
+	}
+	return false
+}
+
 // RenderView renders the current view state to NextStates tensor input states
 func (ev *FWorld) RenderView() {
 	dv := ev.NextStates["Depth"]
@@ -766,7 +844,7 @@ func (ev *FWorld) RenderInters() {
 // RenderVestib renders vestibular state
 func (ev *FWorld) RenderVestibular() {
 	vs := ev.NextStates["Vestibular"]
-	nv := 0.5*(float32(-ev.RotAng)/15) + 0.5
+	nv := 0.5*(float32(-ev.RotAng)/float32(ev.AngInc)) + 0.5
 	ev.PopCode.Encode(&vs.Values, nv, ev.PopSize, false)
 }
 
@@ -1004,9 +1082,9 @@ func (ev *FWorld) ActGen() int {
 	wall := ev.MatMap["Wall"]
 	food := ev.MatMap["Food"]
 	water := ev.MatMap["Water"]
-	left := ev.ActMap["Left"]
-	right := ev.ActMap["Right"]
-	eat := ev.ActMap["Eat"]
+	left := int(Left)
+	right := int(Right)
+	eat := int(Eat)
 
 	nmat := len(ev.Mats)
 	frmat := ints.MinInt(ev.ProxMats[0], nmat)
@@ -1079,7 +1157,7 @@ func (ev *FWorld) ActGen() int {
 	lastact := ev.Act
 	frnd := rand.Float32()
 
-	act := ev.ActMap["Forward"] // default
+	act := int(Forward) // default
 	switch {
 	case frmat == wall:
 		if lastact == left || lastact == right {
@@ -1090,10 +1168,10 @@ func (ev *FWorld) ActGen() int {
 			ev.ActGenTrace(fmt.Sprintf("at wall, rlp: %s, turn", rlps), act)
 		}
 	case frmat == food:
-		act = ev.ActMap["Eat"]
+		act = int(Eat)
 		ev.ActGenTrace("at food", act)
 	case frmat == water:
-		act = ev.ActMap["Drink"]
+		act = int(Drink)
 		ev.ActGenTrace("at water", act)
 	case fwt > wwt:
 		wts := fmt.Sprintf("fwt: %g > wwt: %g, dist: %g", fwt, wwt, fdp)
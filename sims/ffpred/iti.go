@@ -0,0 +1,40 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// ITI is an inter-trial interval of blank cycles run with all inputs off,
+// after decaying activation state by Decay, so that the temporal continuity
+// of activity across trials is explicit and controllable.  Note that
+// NewState at the start of each theta cycle also decays state by the layer
+// Act.Decay params, so for activity to carry over through the interval,
+// those must be less than 1.
+type ITI struct {
+	Cycles int     `min:"0" desc:"number of blank cycles run between trials with all inputs off -- 0 = none"`
+	Decay  float32 `min:"0" max:"1" desc:"proportion of activation state decayed at the start of the interval -- 0 = none, 1 = full reset"`
+	Glong  float32 `min:"0" max:"1" desc:"proportion of long time-constant conductances (NMDA, GABA-B) decayed at the start of the interval"`
+}
+
+// On returns true if there is an interval to run
+func (it *ITI) On() bool {
+	return it.Cycles > 0 || it.Decay > 0 || it.Glong > 0
+}
+
+// ITICycles runs the inter-trial interval, if On -- called in TrainTrial and
+// TestTrial before ApplyInputs for the next trial
+func (ss *Sim) ITICycles() {
+	it := &ss.ITI
+	if !it.On() {
+		return
+	}
+	ss.Net.InitExt()
+	if it.Decay > 0 || it.Glong > 0 {
+		ss.Net.DecayState(it.Decay, it.Glong)
+	}
+	ss.Time.PlusPhase = false
+	for cyc := 0; cyc < it.Cycles; cyc++ {
+		ss.Net.Cycle(&ss.Time)
+		ss.Time.CycleInc()
+	}
+}
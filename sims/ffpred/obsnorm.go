@@ -0,0 +1,312 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/emer/emergent/env"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/ki/kit"
+	"github.com/goki/mat32"
+	"gonum.org/v1/gonum/mat"
+)
+
+// NormModes are the ways of normalizing an env state before it is applied
+type NormModes int32
+
+var KiT_NormModes = kit.Enums.AddEnum(NormModesN, false, nil)
+
+const (
+	// NormNone applies the state as is
+	NormNone NormModes = iota
+
+	// NormCenter subtracts the running mean of each unit
+	NormCenter
+
+	// NormZScore subtracts the running mean of each unit and divides by its
+	// running standard deviation, standardizing each unit independently
+	NormZScore
+
+	// NormWhiten subtracts the running mean of each unit and applies the ZCA
+	// whitening transform of the running covariance of the units, which
+	// also decorrelates them, e.g., the neighboring rays of the depth inputs
+	NormWhiten
+
+	NormModesN
+)
+
+// FromString sets the mode from its name, with or without the Norm prefix
+func (i *NormModes) FromString(s string) error {
+	for j := NormModes(0); j < NormModesN; j++ {
+		if j.String() == s || j.String() == "Norm"+s {
+			*i = j
+			return nil
+		}
+	}
+	return fmt.Errorf("NormModes: %q not found", s)
+}
+
+// NormStats are the running mean and variance of each unit of a state, and
+// the running covariance of the units for NormWhiten
+type NormStats struct {
+	N    int       `desc:"number of trials accumulated"`
+	Mean []float32 `desc:"running mean of each unit"`
+	Var  []float32 `desc:"running variance of each unit"`
+	Cov  []float64 `desc:"running covariance of the units, n x n row-major, for NormWhiten"`
+	W    []float32 `desc:"ZCA whitening matrix computed from Cov, n x n row-major, for NormWhiten -- nil until first computed"`
+	WInv []float32 `desc:"inverse of W, to map whitened values back to raw ones"`
+}
+
+// Whiten computes the ZCA whitening matrix W = U diag(1/sqrt(l+eps)) U^T, and
+// its inverse, from the eigendecomposition of the running covariance.
+// Returns false if the decomposition fails, leaving W unchanged.
+func (ns *NormStats) Whiten(eps float32) bool {
+	n := len(ns.Mean)
+	var es mat.EigenSym
+	if !es.Factorize(mat.NewSymDense(n, append([]float64(nil), ns.Cov...)), true) {
+		return false
+	}
+	vals := es.Values(nil)
+	var u mat.Dense
+	es.VectorsTo(&u)
+	if len(ns.W) != n*n {
+		ns.W = make([]float32, n*n)
+		ns.WInv = make([]float32, n*n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			var w, wi float64
+			for k, l := range vals {
+				if l < 0 {
+					l = 0 // numerical noise
+				}
+				sd := math.Sqrt(l + float64(eps))
+				uu := u.At(i, k) * u.At(j, k)
+				w += uu / sd
+				wi += uu * sd
+			}
+			ns.W[i*n+j], ns.W[j*n+i] = float32(w), float32(w)
+			ns.WInv[i*n+j], ns.WInv[j*n+i] = float32(wi), float32(wi)
+		}
+	}
+	return true
+}
+
+// ObsNorm normalizes env states before they are applied to the input layers,
+// using the running mean and variance of each unit (channel) of the state
+// over training trials (and the covariance of the units, for NormWhiten), so
+// that strong intensity biases, as in the raw depth inputs, do not slow
+// learning.  Normalized values are mapped back into the
+// activation range around Mid, and clipped to 0-1.  The Prev version of a
+// state (e.g., PrevDepth) uses the same mode and stats as the state.
+type ObsNorm struct {
+	States         map[string]NormModes `desc:"normalization mode of each state, by name without the Prev prefix, e.g., Depth -- states not listed are applied as is"`
+	Tau            float32              `def:"1000" min:"1" desc:"time constant of the running averages, in trials -- the averages are cumulative over the first Tau trials"`
+	Gain           float32              `def:"0.25" desc:"activation per standard deviation from the mean, for NormZScore and NormWhiten"`
+	Mid            float32              `def:"0.5" desc:"activation value that the mean of each unit maps to"`
+	Eps            float32              `def:"0.0001" desc:"added to the variance before taking the standard deviation, for units that do not vary -- and to the eigenvalues of the covariance for NormWhiten"`
+	WhitenInterval int                  `def:"100" min:"1" desc:"interval in trials at which the NormWhiten whitening matrix is recomputed from the running covariance -- the state is applied as is until it is first computed"`
+
+	Stats map[string]*NormStats       `view:"-" desc:"running stats of each state, by name without the Prev prefix"`
+	Outs  map[string]*etensor.Float32 `view:"-" desc:"normalized state tensors, by state name"`
+}
+
+// Defaults sets default params
+func (on *ObsNorm) Defaults() {
+	on.Tau = 1000
+	on.Gain = 0.25
+	on.Mid = 0.5
+	on.Eps = 0.0001
+	on.WhitenInterval = 100
+}
+
+// Reset resets the running stats -- called in NewRun
+func (on *ObsNorm) Reset() {
+	on.Stats = make(map[string]*NormStats)
+}
+
+// Mode returns the normalization mode of given state, Prev or not
+func (on *ObsNorm) Mode(state string) NormModes {
+	return on.States[strings.TrimPrefix(state, "Prev")]
+}
+
+// Update adds the current states of en to the running stats of the states
+// being normalized -- called in TrainTrial before ApplyInputs, so the stats
+// only reflect training
+func (on *ObsNorm) Update(en env.Env) {
+	if on.Stats == nil {
+		on.Reset()
+	}
+	for st, md := range on.States {
+		if md == NormNone {
+			continue
+		}
+		pats := en.State(st)
+		if pats == nil {
+			continue
+		}
+		n := pats.Len()
+		ns, ok := on.Stats[st]
+		if !ok || len(ns.Mean) != n {
+			ns = &NormStats{Mean: make([]float32, n), Var: make([]float32, n)}
+			if md == NormWhiten {
+				ns.Cov = make([]float64, n*n)
+			}
+			on.Stats[st] = ns
+		}
+		ns.N++
+		dt := 1 / float32(ns.N)
+		if dt < 1/on.Tau {
+			dt = 1 / on.Tau
+		}
+		if md == NormWhiten {
+			on.UpdateCov(ns, pats, dt)
+		}
+		for i := range ns.Mean {
+			v := float32(pats.FloatVal1D(i))
+			d := v - ns.Mean[i]
+			ns.Mean[i] += dt * d
+			ns.Var[i] += dt * (d*(v-ns.Mean[i]) - ns.Var[i])
+		}
+		if md == NormWhiten && ns.N%on.WhitenInterval == 0 {
+			ns.Whiten(on.Eps)
+		}
+	}
+}
+
+// UpdateCov adds pats to the running covariance of ns with rate dt, using
+// the means before they are updated with pats
+func (on *ObsNorm) UpdateCov(ns *NormStats, pats etensor.Tensor, dt float32) {
+	n := len(ns.Mean)
+	ddt := float64(dt)
+	d := make([]float64, n)  // deviation from old mean
+	dn := make([]float64, n) // deviation from new mean
+	for i, mn := range ns.Mean {
+		v := pats.FloatVal1D(i)
+		d[i] = v - float64(mn)
+		dn[i] = v - (float64(mn) + ddt*d[i])
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			c := &ns.Cov[i*n+j]
+			*c += ddt * (d[i]*dn[j] - *c)
+		}
+	}
+}
+
+// stats returns the stats for given state if it is being normalized and has
+// stats of given size, else nil
+func (on *ObsNorm) stats(state string, n int) *NormStats {
+	if on.Mode(state) == NormNone {
+		return nil
+	}
+	ns, ok := on.Stats[strings.TrimPrefix(state, "Prev")]
+	if !ok || ns.N == 0 || len(ns.Mean) != n {
+		return nil
+	}
+	if on.Mode(state) == NormWhiten && len(ns.W) != n*n {
+		return nil
+	}
+	return ns
+}
+
+// Norm returns the normalized version of pats for given state, or pats
+// unchanged if the state is not normalized -- called in ApplyInputs
+func (on *ObsNorm) Norm(state string, pats etensor.Tensor) etensor.Tensor {
+	ns := on.stats(state, pats.Len())
+	if ns == nil {
+		return pats
+	}
+	if on.Outs == nil {
+		on.Outs = make(map[string]*etensor.Float32)
+	}
+	out, ok := on.Outs[state]
+	if !ok {
+		out = &etensor.Float32{}
+		on.Outs[state] = out
+	}
+	out.CopyShapeFrom(pats)
+	md := on.Mode(state)
+	if md == NormWhiten {
+		n := len(ns.Mean)
+		d := make([]float32, n)
+		for i, mn := range ns.Mean {
+			d[i] = float32(pats.FloatVal1D(i)) - mn
+		}
+		for i := range d {
+			z := float32(0)
+			for j, dv := range d {
+				z += ns.W[i*n+j] * dv
+			}
+			out.Values[i] = mat32.Clamp(on.Mid+on.Gain*z, 0, 1)
+		}
+		return out
+	}
+	for i, mn := range ns.Mean {
+		z := float32(pats.FloatVal1D(i)) - mn
+		if md == NormZScore {
+			z *= on.Gain / mat32.Sqrt(ns.Var[i]+on.Eps)
+		}
+		out.Values[i] = mat32.Clamp(on.Mid+z, 0, 1)
+	}
+	return out
+}
+
+// Denorm maps the normalized activations in tsr for given state back to raw
+// state values, in place, e.g., to decode predictions of the state
+func (on *ObsNorm) Denorm(state string, tsr *etensor.Float32) {
+	ns := on.stats(state, tsr.Len())
+	if ns == nil {
+		return
+	}
+	md := on.Mode(state)
+	if md == NormWhiten {
+		n := len(ns.Mean)
+		z := make([]float32, n)
+		for i := range z {
+			z[i] = (tsr.Values[i] - on.Mid) / on.Gain
+		}
+		for i, mn := range ns.Mean {
+			v := mn
+			for j, zv := range z {
+				v += ns.WInv[i*n+j] * zv
+			}
+			tsr.Values[i] = v
+		}
+		return
+	}
+	for i, mn := range ns.Mean {
+		z := tsr.Values[i] - on.Mid
+		if md == NormZScore {
+			z *= mat32.Sqrt(ns.Var[i]+on.Eps) / on.Gain
+		}
+		tsr.Values[i] = mn + z
+	}
+}
+
+// ParseObsNorm parses the normalization modes of states from a comma-separated
+// list of State:Mode entries, e.g., Depth:Whiten,Fovea:Center
+func ParseObsNorm(s string) (map[string]NormModes, error) {
+	sts := make(map[string]NormModes)
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		fs := strings.Split(e, ":")
+		if len(fs) != 2 {
+			return nil, fmt.Errorf("ObsNorm: %q must be State:Mode", e)
+		}
+		var md NormModes
+		if err := md.FromString(fs[1]); err != nil {
+			return nil, fmt.Errorf("ObsNorm: %q mode: %v", e, err)
+		}
+		sts[strings.TrimPrefix(fs[0], "Prev")] = md
+	}
+	return sts, nil
+}
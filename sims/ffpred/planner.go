@@ -0,0 +1,322 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/emer/axon/axon"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/mat32"
+)
+
+////////////////////////////////////////////////////////////////////
+// Env: goal in view
+
+// GoalRay returns the index of the depth ray that hits the closest
+// instance of given material in the current view, and its normalized
+// log depth -- ray is -1 if the material is not in view
+func (ev *FWorld) GoalRay(mat int) (ray int, depth float32) {
+	ray = -1
+	depth = 1
+	for i, m := range ev.ViewMats {
+		if m == mat && ev.DepthLogs[i] < depth {
+			ray = i
+			depth = ev.DepthLogs[i]
+		}
+	}
+	return
+}
+
+// DecodeDepth decodes the normalized log depth along given ray
+// from a Depth-shaped tensor (e.g., the predicted V2WdP activity)
+func (ev *FWorld) DecodeDepth(dv *etensor.Float32, ray int) float32 {
+	np := ev.DepthSize / ev.DepthPools
+	vals := make([]float32, ev.DepthSize)
+	for dp := 0; dp < ev.DepthPools; dp++ {
+		for pi := 0; pi < np; pi++ {
+			vals[dp*np+pi] = dv.Value([]int{dp, ray, pi, 0})
+		}
+	}
+	return ev.DepthCode.Decode(vals)
+}
+
+// RayAfterAct returns the index of the ray that a point seen along given ray
+// will be on after the given action: rotations shift the view by one ray
+// (rays go from left to right), other actions keep it on the same ray.
+// Returns -1 if the point is rotated out of view.
+func (ev *FWorld) RayAfterAct(ray, act int) int {
+	switch Actions(act) {
+	case Left:
+		ray++
+	case Right:
+		ray--
+	}
+	if ray < 0 || ray >= ev.NFOVRays {
+		return -1
+	}
+	return ray
+}
+
+////////////////////////////////////////////////////////////////////
+// Sim: planner
+
+// Planner selects actions toward a goal by imagining the outcomes of candidate
+// action sequences with the learned forward model: starting from the current
+// depth view, the network is run without learning, with each action of the
+// sequence applied to the Act layer and the predicted depth (V2WdP) fed back as
+// the next V2Wd input.  The predicted depth along the ray on which the goal was
+// seen is the predicted distance to the goal, and the first action of the
+// sequence with the smallest final predicted distance is taken (receding horizon).
+// Planning is active while a goal is in view -- otherwise the subcortical
+// ActGen is used -- and a goal episode succeeds when the goal is directly in front.
+type Planner struct {
+	On       bool             `desc:"use the planner to select actions toward the goal when it is in view, instead of the subcortical ActGen"`
+	Depth    int              `def:"3" min:"1" desc:"number of steps in each imagined action sequence"`
+	Width    int              `def:"8" min:"1" desc:"number of candidate action sequences evaluated for each plan"`
+	Cycles   int              `def:"150" desc:"number of cycles to settle the network for each imagined step -- typically same as MinusCycles"`
+	MaxSteps int              `def:"50" desc:"maximum number of steps in a goal episode before it counts as a failure"`
+	Goal     string           `def:"Food" desc:"material that is the goal of navigation"`
+	Acts     []Actions        `desc:"actions that can be used in plans"`
+	Plan     []int            `inactive:"+" desc:"best action sequence from the last plan"`
+	PlanDist float32          `inactive:"+" desc:"predicted normalized log distance to goal at the end of the best plan"`
+	Active   bool             `inactive:"+" desc:"a goal episode is in progress"`
+	Steps    int              `inactive:"+" desc:"number of steps in the current goal episode"`
+	NPlans   int              `inactive:"+" desc:"number of steps in the current goal episode where the plan was used (goal in view)"`
+	StartDst float32          `inactive:"+" desc:"normalized log distance to goal at the start of the current goal episode"`
+	Pending  bool             `view:"-" desc:"a prediction for the last step is waiting to be compared with the actual outcome"`
+	PredRay  int              `view:"-" desc:"ray on which the goal is predicted to be after the last step"`
+	PredDst  float32          `view:"-" desc:"predicted normalized log distance to goal after the last step"`
+	SumErr   float64          `view:"-" desc:"sum of absolute prediction errors for the current goal episode"`
+	NErr     int              `view:"-" desc:"number of prediction errors summed"`
+	NEpisode int              `inactive:"+" desc:"number of goal episodes completed in this run"`
+	NSuccess int              `inactive:"+" desc:"number of successful goal episodes in this run"`
+	Time     axon.Time        `view:"-" desc:"separate timing state for imagined steps, so the main Time is unaffected"`
+	InTsr    *etensor.Float32 `view:"-" desc:"input depth for the imagined step"`
+	OutTsr   *etensor.Float32 `view:"-" desc:"predicted depth from the imagined step"`
+}
+
+func (pl *Planner) Defaults() {
+	pl.Depth = 3
+	pl.Width = 8
+	pl.Cycles = 150
+	pl.MaxSteps = 50
+	pl.Goal = "Food"
+	pl.Acts = []Actions{Left, Right, Forward}
+	pl.Time.Defaults()
+}
+
+// Reset resets the goal episode state and success counts, for a new run
+func (pl *Planner) Reset() {
+	pl.Active = false
+	pl.Pending = false
+	pl.NEpisode = 0
+	pl.NSuccess = 0
+}
+
+// SuccessRate returns the proportion of successful goal episodes in this run
+func (pl *Planner) SuccessRate() float64 {
+	if pl.NEpisode == 0 {
+		return 0
+	}
+	return float64(pl.NSuccess) / float64(pl.NEpisode)
+}
+
+// PredErr returns the mean absolute error of the predicted distance to goal
+// for the current goal episode
+func (pl *Planner) PredErr() float64 {
+	if pl.NErr == 0 {
+		return 0
+	}
+	return pl.SumErr / float64(pl.NErr)
+}
+
+// PlanTrial runs the planner after each training trial: updates the goal
+// episode state, and if the goal is in view, plans and sets the next action
+func (ss *Sim) PlanTrial() {
+	pl := &ss.Planner
+	ev := &ss.TrainEnv
+	if !pl.On {
+		return
+	}
+	gmat := ev.MatMap[pl.Goal]
+	ray, dist := ev.GoalRay(gmat)
+	if pl.Pending && pl.PredRay >= 0 {
+		pl.SumErr += math.Abs(float64(ev.DepthLogs[pl.PredRay] - pl.PredDst))
+		pl.NErr++
+	}
+	pl.Pending = false
+	if !pl.Active {
+		if ray < 0 {
+			return
+		}
+		pl.Active = true
+		pl.Steps = 0
+		pl.NPlans = 0
+		pl.StartDst = dist
+		pl.SumErr = 0
+		pl.NErr = 0
+	}
+	if ev.ProxMats[0] == gmat {
+		ss.LogPlan(ss.PlanLog, true, dist)
+		return // ActGen will consume the goal
+	}
+	if pl.Steps >= pl.MaxSteps {
+		ss.LogPlan(ss.PlanLog, false, dist)
+		return
+	}
+	pl.Steps++
+	if ray < 0 {
+		return // explore using ActGen until goal is back in view
+	}
+	pl.NPlans++
+	ss.Plan(ray)
+	ev.ExtAct = pl.Plan[0]
+	pl.Pending = true
+	pl.PredRay = ev.RayAfterAct(ray, pl.Plan[0])
+}
+
+// Plan evaluates Width candidate action sequences of length Depth by rollouts
+// of the network, starting with the goal on given ray, and sets Plan to the
+// sequence with the lowest predicted final distance to the goal.
+// The first candidate always continues Forward.
+func (ss *Sim) Plan(ray int) {
+	pl := &ss.Planner
+	seq := make([]int, pl.Depth)
+	pl.PlanDist = 2
+	for w := 0; w < pl.Width; w++ {
+		for d := range seq {
+			if w == 0 {
+				seq[d] = int(Forward)
+			} else {
+				seq[d] = int(pl.Acts[rand.Intn(len(pl.Acts))])
+			}
+		}
+		dists := ss.PlanRollout(seq, ray)
+		dist := dists[len(dists)-1]
+		if dist < pl.PlanDist {
+			pl.PlanDist = dist
+			pl.PredDst = dists[0]
+			pl.Plan = append(pl.Plan[:0], seq...)
+		}
+	}
+}
+
+// PlanRollout runs the network forward through given action sequence, starting
+// from the current depth view, feeding back its own predicted depth as the next
+// input, and returns the predicted normalized log distance to the goal after
+// each step, tracking the goal from given ray across rotations (1 = out of view).
+// No learning takes place, but activation state is altered until the next trial.
+func (ss *Sim) PlanRollout(acts []int, ray int) []float32 {
+	pl := &ss.Planner
+	ev := &ss.TrainEnv
+	v2wd := ss.Net.LayerByName("V2Wd").(axon.AxonLayer).AsAxon()
+	v2wdp := ss.Net.LayerByName("V2WdP").(axon.AxonLayer).AsAxon()
+	act := ss.Net.LayerByName("Act").(axon.AxonLayer).AsAxon()
+	if pl.InTsr == nil {
+		pl.InTsr = &etensor.Float32{}
+		pl.OutTsr = &etensor.Float32{}
+	}
+	pl.InTsr.CopyFrom(ss.ObsNorm.Norm("Depth", ev.NextStates["Depth"]))
+	dists := make([]float32, len(acts))
+	for i, a := range acts {
+		ss.Net.InitExt()
+		v2wd.ApplyExt(pl.InTsr)
+		act.ApplyExt(ev.Pats[ev.Acts[a]])
+		ss.Net.NewState()
+		pl.Time.NewState()
+		for cyc := 0; cyc < pl.Cycles; cyc++ {
+			ss.Net.Cycle(&pl.Time)
+			pl.Time.CycleInc()
+		}
+		v2wdp.UnitValsTensor(pl.OutTsr, "Act")
+		pl.InTsr.CopyFrom(pl.OutTsr)
+		ss.ObsNorm.Denorm("Depth", pl.OutTsr) // decode raw depth
+		ray = ev.RayAfterAct(ray, a)
+		if ray < 0 {
+			dists[i] = 1
+		} else {
+			dists[i] = mat32.Clamp(ev.DecodeDepth(pl.OutTsr, ray), 0, 1)
+		}
+	}
+	return dists
+}
+
+// LogPlan adds data for the goal episode just completed to the PlanLog,
+// and ends the episode
+func (ss *Sim) LogPlan(dt *etable.Table, success bool, dist float32) {
+	pl := &ss.Planner
+	ev := &ss.TrainEnv
+	pl.NEpisode++
+	if success {
+		pl.NSuccess++
+	}
+	pl.Active = false
+
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ev.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ev.Epoch.Cur))
+	dt.SetCellFloat("Episode", row, float64(pl.NEpisode))
+	dt.SetCellFloat("Steps", row, float64(pl.Steps))
+	dt.SetCellFloat("NPlans", row, float64(pl.NPlans))
+	dt.SetCellFloat("StartDist", row, float64(pl.StartDst))
+	dt.SetCellFloat("EndDist", row, float64(dist))
+	if success {
+		dt.SetCellFloat("Success", row, 1)
+	} else {
+		dt.SetCellFloat("Success", row, 0)
+	}
+	dt.SetCellFloat("SuccessRate", row, pl.SuccessRate())
+	dt.SetCellFloat("PredErr", row, pl.PredErr())
+
+	// note: essential to use Go version of update when called from another goroutine
+	if ss.PlanPlot != nil {
+		ss.PlanPlot.GoUpdate()
+	}
+}
+
+func (ss *Sim) ConfigPlanLog(dt *etable.Table) {
+	dt.SetMetaData("name", "PlanLog")
+	dt.SetMetaData("desc", "Record of each goal episode using the planner")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Episode", etensor.INT64, nil, nil},
+		{"Steps", etensor.INT64, nil, nil},
+		{"NPlans", etensor.INT64, nil, nil},
+		{"StartDist", etensor.FLOAT64, nil, nil},
+		{"EndDist", etensor.FLOAT64, nil, nil},
+		{"Success", etensor.FLOAT64, nil, nil},
+		{"SuccessRate", etensor.FLOAT64, nil, nil},
+		{"PredErr", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigPlanPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Emery Planner Plot"
+	plt.Params.XAxisCol = "Episode"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Episode", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Steps", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NPlans", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("StartDist", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("EndDist", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Success", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("SuccessRate", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("PredErr", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+
+	"github.com/emer/axon/axon"
+	"github.com/emer/etable/etensor"
+)
+
+// RunTrial runs one theta cycle of the network on the given inputs, keyed by
+// layer name, without the env or any learning, and returns the minus-phase
+// activations (ActM) of the given layers, keyed by layer name -- all layers
+// that are not in inputs if none are given.  Inputs to Target layers are
+// clamped in the plus phase, as usual.  This is for probing a trained network
+// with synthetic stimuli, e.g.:
+//
+//	outs := ss.RunTrial(map[string]etensor.Tensor{"V2Wd": dpat, "Act": apat}, "V2WdP")
+//
+// Activation state is decayed at the start as in ThetaCyc, by the
+// Act.Decay params.  No stats are computed and nothing is logged.
+func (ss *Sim) RunTrial(inputs map[string]etensor.Tensor, lays ...string) map[string]*etensor.Float32 {
+	ss.Net.InitExt()
+	for lnm, pats := range inputs {
+		lyi := ss.Net.LayerByName(lnm)
+		if lyi == nil {
+			log.Printf("RunTrial: input layer not found: %s\n", lnm)
+			continue
+		}
+		lyi.(axon.AxonLayer).AsAxon().ApplyExt(pats)
+	}
+
+	ss.Net.NewState()
+	ss.Time.NewState()
+	for cyc := 0; cyc < ss.MinusCycles; cyc++ {
+		ss.Net.Cycle(&ss.Time)
+		ss.Time.CycleInc()
+	}
+	ss.Net.MinusPhase(&ss.Time)
+	ss.Time.NewPhase()
+	for cyc := 0; cyc < ss.PlusCycles; cyc++ {
+		ss.Net.Cycle(&ss.Time)
+		ss.Time.CycleInc()
+	}
+	ss.Net.PlusPhase(&ss.Time)
+
+	if len(lays) == 0 {
+		for _, lyi := range ss.Net.Layers {
+			if _, in := inputs[lyi.Name()]; !in && !lyi.IsOff() {
+				lays = append(lays, lyi.Name())
+			}
+		}
+	}
+	outs := make(map[string]*etensor.Float32, len(lays))
+	for _, lnm := range lays {
+		lyi := ss.Net.LayerByName(lnm)
+		if lyi == nil {
+			log.Printf("RunTrial: output layer not found: %s\n", lnm)
+			continue
+		}
+		tsr := &etensor.Float32{}
+		lyi.UnitValsTensor(tsr, "ActM")
+		outs[lnm] = tsr
+	}
+	return outs
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/emer/etable/etensor"
+)
+
+// ReplayTuple is one training trial stored in the Replay buffer
+type ReplayTuple struct {
+	States map[string]*etensor.Float32 `desc:"raw env states of the trial, by name"`
+	Pri    float32                     `desc:"priority of the tuple: its prediction error (1 - CosDiff) the last time it was trained on, plus Eps"`
+}
+
+// State returns the stored state of given name, nil if none
+func (rt *ReplayTuple) State(nm string) etensor.Tensor {
+	tsr, ok := rt.States[nm]
+	if !ok {
+		return nil
+	}
+	return tsr
+}
+
+// Replay is an episodic memory buffer of recent training trials, replayed
+// with prioritized sampling by prediction error, to improve data efficiency
+// for rare events such as wall encounters.  Each on-policy training trial
+// is stored in the buffer, replacing the oldest once it holds Cap tuples,
+// and is followed by N replay trials, which learn as usual, sampled with
+// probability proportional to Pri^Alpha.  The priority of each replayed
+// tuple is updated with its new prediction error.  Replay trials do not
+// count in the trial and epoch stats, other than ReplayCosDiff.
+type Replay struct {
+	N     int     `min:"0" desc:"number of replay trials after each on-policy training trial -- 0 = off"`
+	Cap   int     `def:"1000" min:"1" desc:"capacity of the buffer: the most recent Cap training trials are kept"`
+	Alpha float32 `def:"0.6" min:"0" desc:"exponent of the priorities in sampling: 0 = uniform, 1 = proportional to prediction error"`
+	Eps   float32 `def:"0.01" min:"0" desc:"added to the prediction error of each tuple, so all tuples can be sampled"`
+
+	Active     bool          `inactive:"+" desc:"a replay trial is running"`
+	Buf        []ReplayTuple `view:"-" desc:"the buffer of tuples, used as a ring once full"`
+	Next       int           `view:"-" desc:"index in Buf of the next tuple to store, once full"`
+	EpcCosDiff float64       `inactive:"+" desc:"last epoch's cosine difference of the replay trials, before learning on them"`
+	SumCosDiff float64       `view:"-" desc:"sum of replay trial CosDiff over the epoch"`
+	NTrls      int           `view:"-" desc:"number of replay trials in SumCosDiff"`
+}
+
+// Defaults sets default params
+func (rp *Replay) Defaults() {
+	rp.Cap = 1000
+	rp.Alpha = 0.6
+	rp.Eps = 0.01
+}
+
+// On returns true if replay is used
+func (rp *Replay) On() bool {
+	return rp.N > 0
+}
+
+// Reset empties the buffer and resets the stats -- called in NewRun
+func (rp *Replay) Reset() {
+	rp.Buf = rp.Buf[:0]
+	rp.Next = 0
+	rp.Active = false
+	rp.EpcCosDiff = 0
+	rp.SumCosDiff = 0
+	rp.NTrls = 0
+}
+
+// Priority returns the priority for given trial CosDiff
+func (rp *Replay) Priority(cosDiff float64) float32 {
+	err := 1 - float32(cosDiff)
+	if err < 0 {
+		err = 0
+	}
+	return err + rp.Eps
+}
+
+// Store stores the current states of given names (the states of the Inputs,
+// as applied by ApplyInputs) from the env, through its State function, with
+// the priority for given trial CosDiff
+func (rp *Replay) Store(sts []string, state func(string) etensor.Tensor, cosDiff float64) {
+	var rt *ReplayTuple
+	if len(rp.Buf) < rp.Cap {
+		rp.Buf = append(rp.Buf, ReplayTuple{})
+		rt = &rp.Buf[len(rp.Buf)-1]
+	} else {
+		rt = &rp.Buf[rp.Next]
+		rp.Next = (rp.Next + 1) % len(rp.Buf)
+	}
+	if rt.States == nil {
+		rt.States = make(map[string]*etensor.Float32)
+	}
+	for _, nm := range sts {
+		pats := state(nm)
+		if pats == nil {
+			continue
+		}
+		tsr, ok := rt.States[nm]
+		if !ok {
+			tsr = &etensor.Float32{}
+			rt.States[nm] = tsr
+		}
+		tsr.CopyShapeFrom(pats)
+		tsr.CopyFrom(pats)
+	}
+	rt.Pri = rp.Priority(cosDiff)
+}
+
+// Sample returns the index of a tuple sampled with probability proportional
+// to Pri^Alpha, -1 if the buffer is empty
+func (rp *Replay) Sample() int {
+	n := len(rp.Buf)
+	if n == 0 {
+		return -1
+	}
+	ws := make([]float64, n)
+	sum := 0.0
+	for i := range rp.Buf {
+		ws[i] = math.Pow(float64(rp.Buf[i].Pri), float64(rp.Alpha))
+		sum += ws[i]
+	}
+	r := rand.Float64() * sum
+	for i, w := range ws {
+		r -= w
+		if r < 0 {
+			return i
+		}
+	}
+	return n - 1
+}
+
+// EpcStats computes the EpcCosDiff from the replay trials since the last call
+func (rp *Replay) EpcStats() float64 {
+	if rp.NTrls > 0 {
+		rp.EpcCosDiff = rp.SumCosDiff / float64(rp.NTrls)
+	}
+	rp.SumCosDiff = 0
+	rp.NTrls = 0
+	return rp.EpcCosDiff
+}
+
+// ReplayTrials stores the current training trial in the Replay buffer and
+// runs the Replay.N replay trials that follow it -- called in TrainTrial
+func (ss *Sim) ReplayTrials() {
+	rp := &ss.Replay
+	if !rp.On() {
+		return
+	}
+	rp.Store(ss.InputStates(), ss.TrainEnv.State, ss.TrlCosDiff)
+	rp.Active = true
+	for i := 0; i < rp.N; i++ {
+		ri := rp.Sample()
+		rt := &rp.Buf[ri]
+		ss.ApplyStates(ss.Net, rt.State)
+		ss.ThetaCyc(true) // train
+		rt.Pri = rp.Priority(ss.TrlCosDiff)
+		rp.SumCosDiff += ss.TrlCosDiff
+		rp.NTrls++
+	}
+	rp.Active = false
+}
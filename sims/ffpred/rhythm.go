@@ -0,0 +1,399 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// Rhythm measures the rhythmicity of the units of the SpikeRecLays during
+// testing, from the cycle-level raster recordings (of RasterVar: spikes or
+// a rate code).  The rasters of successive test trials are treated as one
+// continuous stream (the ITI cycles, which are not recorded, are skipped),
+// over which the temporal autocorrelation of each unit is accumulated up to
+// MaxLag cycles (msec).  At the end of each test, the autocorrelation
+// of each active unit is scanned for its first side peak after the central
+// trough, and its power spectrum is computed from it (Blackman-Tukey, with a
+// Hann lag window) to find the spectral peak and the fraction of power in
+// the theta band.  The per-layer summaries are logged to the RhythmLog.
+type Rhythm struct {
+	On        bool    `desc:"compute the rhythmicity of the SpikeRecLays units during testing"`
+	MaxLag    int     `def:"250" min:"1" desc:"maximum lag of the autocorrelations, in cycles (msec) -- sets the lowest frequency resolved, 4 Hz for 250"`
+	MinFreq   float64 `def:"2" min:"0" desc:"lowest frequency of the spectra, in Hz"`
+	MaxFreq   float64 `def:"100" desc:"highest frequency of the spectra, in Hz"`
+	ThetaMin  float64 `def:"4" desc:"lower edge of the theta band, in Hz"`
+	ThetaMax  float64 `def:"8" desc:"upper edge of the theta band, in Hz"`
+	RhythmThr float64 `def:"0.1" desc:"autocorrelation side peak above which a unit is rhythmic"`
+
+	Accs map[string]*rhythmAcc `view:"-" desc:"autocorrelation sums of each layer since the last epoch"`
+}
+
+// Defaults sets default params
+func (rh *Rhythm) Defaults() {
+	rh.MaxLag = 250
+	rh.MinFreq = 2
+	rh.MaxFreq = 100
+	rh.ThetaMin = 4
+	rh.ThetaMax = 8
+	rh.RhythmThr = 0.1
+}
+
+// Reset clears the accumulated sums, keeping the recent history of each
+// layer for the next trial
+func (rh *Rhythm) Reset() {
+	for _, ra := range rh.Accs {
+		ra.Reset()
+	}
+}
+
+// ResetHist clears the recent history of each layer, so that the next
+// trial does not continue the stream -- called at the start of testing
+func (rh *Rhythm) ResetHist() {
+	for _, ra := range rh.Accs {
+		ra.NHist = 0
+	}
+}
+
+// rhythmAcc accumulates the lagged products of the raster of the units of
+// a layer, over a stream of trials
+type rhythmAcc struct {
+	NUnits int       // number of units
+	MaxLag int       // maximum lag
+	Sxy    []float64 // sum of x(t) x(t-lag), per unit and lag, unit-major
+	Cnt    []float64 // number of products in Sxy, per lag
+	Sx     []float64 // sum of x(t), per unit
+	N      float64   // number of cycles in Sx
+	Hist   []float32 // the last NHist cycles of each unit, oldest first, unit-major with stride MaxLag
+	NHist  int       // number of cycles of history
+}
+
+// Reset clears the sums
+func (ra *rhythmAcc) Reset() {
+	for i := range ra.Sxy {
+		ra.Sxy[i] = 0
+	}
+	for i := range ra.Cnt {
+		ra.Cnt[i] = 0
+	}
+	for i := range ra.Sx {
+		ra.Sx[i] = 0
+	}
+	ra.N = 0
+}
+
+// Add adds the raster of one trial, nu units x nc cycles, continuing from
+// the history of the previous trials
+func (ra *rhythmAcc) Add(vals []float32, nu, nc, maxLag int) {
+	if ra.NUnits != nu || ra.MaxLag != maxLag {
+		nl := maxLag + 1
+		*ra = rhythmAcc{NUnits: nu, MaxLag: maxLag, Sxy: make([]float64, nu*nl), Cnt: make([]float64, nl), Sx: make([]float64, nu), Hist: make([]float32, nu*maxLag)}
+	}
+	nl := maxLag + 1
+	for ui := 0; ui < nu; ui++ {
+		x := vals[ui*nc : (ui+1)*nc]
+		hs := ra.Hist[ui*maxLag : (ui+1)*maxLag]
+		h := hs[:ra.NHist]
+		sxy := ra.Sxy[ui*nl : (ui+1)*nl]
+		for t, v := range x {
+			if v == 0 {
+				continue
+			}
+			ra.Sx[ui] += float64(v)
+			for l := 0; l <= maxLag; l++ {
+				var w float32
+				if t >= l {
+					w = x[t-l]
+				} else if hi := len(h) + t - l; hi >= 0 {
+					w = h[hi]
+				} else {
+					break
+				}
+				sxy[l] += float64(v * w)
+			}
+		}
+		if nc >= maxLag {
+			copy(hs, x[nc-maxLag:])
+			continue
+		}
+		keep := ra.NHist
+		if keep > maxLag-nc {
+			keep = maxLag - nc
+		}
+		copy(hs[:keep], hs[ra.NHist-keep:ra.NHist])
+		copy(hs[keep:keep+nc], x)
+	}
+	for l := range ra.Cnt {
+		if n := nc - l + ra.NHist; n > nc {
+			ra.Cnt[l] += float64(nc)
+		} else if n > 0 {
+			ra.Cnt[l] += float64(n)
+		}
+	}
+	ra.N += float64(nc)
+	ra.NHist += nc
+	if ra.NHist > maxLag {
+		ra.NHist = maxLag
+	}
+}
+
+// AutoCorr sets ac to the autocorrelation of given unit, from the
+// autocovariance relative to its mean over the stream, returning false if
+// the unit is inactive
+func (ra *rhythmAcc) AutoCorr(ui int, ac []float64) bool {
+	if ra.N == 0 || ra.Sx[ui] == 0 {
+		return false
+	}
+	nl := ra.MaxLag + 1
+	m := ra.Sx[ui] / ra.N
+	sxy := ra.Sxy[ui*nl : (ui+1)*nl]
+	for l := range ac {
+		if ra.Cnt[l] > 0 {
+			ac[l] = sxy[l]/ra.Cnt[l] - m*m
+		} else {
+			ac[l] = 0
+		}
+	}
+	if ac[0] <= 0 {
+		return false
+	}
+	c0 := ac[0]
+	for l := range ac {
+		ac[l] /= c0
+	}
+	return true
+}
+
+// SidePeak returns the height and lag of the first side peak of
+// autocorrelation ac: its maximum after its first trough -- 0 if none
+func SidePeak(ac []float64) (float64, int) {
+	l := 1
+	for l < len(ac)-1 && ac[l+1] < ac[l] {
+		l++
+	}
+	pk, lag := 0.0, 0
+	for ; l < len(ac); l++ {
+		if ac[l] > pk {
+			pk, lag = ac[l], l
+		}
+	}
+	return pk, lag
+}
+
+// Freqs returns the frequencies of the spectra, in 1 Hz steps
+func (rh *Rhythm) Freqs() []float64 {
+	var fs []float64
+	for f := rh.MinFreq; f <= rh.MaxFreq; f++ {
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// SpectrumTab returns the Hann-windowed cosine table of the spectra, for
+// the frequencies fs, by frequency then lag -- a cycle is 1 msec
+func (rh *Rhythm) SpectrumTab(fs []float64) []float64 {
+	nl := rh.MaxLag + 1
+	tab := make([]float64, len(fs)*nl)
+	for fi, f := range fs {
+		for l := 0; l < nl; l++ {
+			w := 0.5 * (1 + math.Cos(math.Pi*float64(l)/float64(nl)))
+			tab[fi*nl+l] = w * math.Cos(2*math.Pi*f*float64(l)/1000)
+		}
+	}
+	return tab
+}
+
+// Spectrum sets pow to the power spectrum of autocorrelation ac, using the
+// cosine table tab, with negative power clipped to 0
+func Spectrum(ac, tab, pow []float64) {
+	nl := len(ac)
+	for fi := range pow {
+		p := ac[0] * tab[fi*nl]
+		for l := 1; l < nl; l++ {
+			p += 2 * ac[l] * tab[fi*nl+l]
+		}
+		if p < 0 {
+			p = 0
+		}
+		pow[fi] = p
+	}
+}
+
+// PeakFreq returns the frequency of maximum power of pow, and the fraction
+// of the total power in the theta band
+func (rh *Rhythm) PeakFreq(fs, pow []float64) (float64, float64) {
+	pk, pf := -1.0, 0.0
+	var tot, th float64
+	for fi, p := range pow {
+		if p > pk {
+			pk, pf = p, fs[fi]
+		}
+		tot += p
+		if fs[fi] >= rh.ThetaMin && fs[fi] <= rh.ThetaMax {
+			th += p
+		}
+	}
+	if tot > 0 {
+		th /= tot
+	}
+	return pf, th
+}
+
+// RhythmTrial adds the rasters of the current test trial to the
+// autocorrelations -- called in TestTrial
+func (ss *Sim) RhythmTrial() {
+	rh := &ss.Rhythm
+	if !rh.On {
+		return
+	}
+	if rh.Accs == nil {
+		rh.Accs = make(map[string]*rhythmAcc)
+	}
+	for _, lnm := range ss.SpikeRecLays {
+		sr := ss.SpikeRastTsr(lnm)
+		if sr.Len() == 0 {
+			continue
+		}
+		ra, ok := rh.Accs[lnm]
+		if !ok {
+			ra = &rhythmAcc{}
+			rh.Accs[lnm] = ra
+		}
+		ra.Add(sr.Values, sr.Dim(0), sr.Dim(1), rh.MaxLag)
+	}
+}
+
+// RhythmEpoch computes the rhythmicity of the units from the trials since
+// the last time, logging it per layer to the RhythmLog -- called at the end
+// of each test
+func (ss *Sim) RhythmEpoch() {
+	rh := &ss.Rhythm
+	if !rh.On {
+		return
+	}
+	fs := rh.Freqs()
+	tab := rh.SpectrumTab(fs)
+	ac := make([]float64, rh.MaxLag+1)
+	pow := make([]float64, len(fs))
+	mpow := make([]float64, len(fs))
+	dt := ss.RhythmLog
+	for _, lnm := range ss.SpikeRecLays {
+		ra, ok := rh.Accs[lnm]
+		if !ok || ra.N == 0 {
+			continue
+		}
+		for fi := range mpow {
+			mpow[fi] = 0
+		}
+		var nact, nrhy, nth int
+		var sPk, sLag, sFreq, sTh float64
+		for ui := 0; ui < ra.NUnits; ui++ {
+			if !ra.AutoCorr(ui, ac) {
+				continue
+			}
+			nact++
+			pk, lag := SidePeak(ac)
+			if pk > rh.RhythmThr {
+				nrhy++
+				sLag += float64(lag)
+			}
+			sPk += pk
+			Spectrum(ac, tab, pow)
+			pf, th := rh.PeakFreq(fs, pow)
+			sFreq += pf
+			sTh += th
+			if pf >= rh.ThetaMin && pf <= rh.ThetaMax {
+				nth++
+			}
+			for fi, p := range pow {
+				mpow[fi] += p
+			}
+		}
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+		dt.SetCellFloat("Epoch", row, float64(ss.TrainEnv.Epoch.Cur))
+		dt.SetCellString("Layer", row, lnm)
+		dt.SetCellFloat("NActive", row, float64(nact))
+		if nact > 0 {
+			na := float64(nact)
+			lpf, lth := rh.PeakFreq(fs, mpow)
+			dt.SetCellFloat("Rhythmic", row, float64(nrhy)/na)
+			dt.SetCellFloat("ACPeak", row, sPk/na)
+			dt.SetCellFloat("UnitFreq", row, sFreq/na)
+			dt.SetCellFloat("ThetaUnits", row, float64(nth)/na)
+			dt.SetCellFloat("ThetaPow", row, sTh/na)
+			dt.SetCellFloat("PeakFreq", row, lpf)
+			dt.SetCellFloat("LayThetaPow", row, lth)
+		}
+		if nrhy > 0 {
+			dt.SetCellFloat("ACPeakLag", row, sLag/float64(nrhy))
+		}
+	}
+	rh.Reset()
+
+	// note: essential to use Go version of update when called from another goroutine
+	if ss.RhythmPlot != nil {
+		ss.RhythmPlot.GoUpdate()
+	}
+}
+
+// SaveRhythmLog saves the RhythmLog of the run, if any -- called in RunEnd
+func (ss *Sim) SaveRhythmLog() {
+	if !ss.Rhythm.On || ss.RhythmLog.Rows == 0 {
+		return
+	}
+	fnm := ss.LogFileName(fmt.Sprintf("rhythm_run%03d", ss.TrainEnv.Run.Cur))
+	ss.RhythmLog.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+}
+
+func (ss *Sim) ConfigRhythmLog(dt *etable.Table) {
+	dt.SetMetaData("name", "RhythmLog")
+	dt.SetMetaData("desc", "Rhythmicity of the units of each recorded layer over testing, from the autocorrelation and spectrum of their cycle-level activity")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Layer", etensor.STRING, nil, nil},
+		{"NActive", etensor.INT64, nil, nil},
+		{"Rhythmic", etensor.FLOAT64, nil, nil},
+		{"ACPeak", etensor.FLOAT64, nil, nil},
+		{"ACPeakLag", etensor.FLOAT64, nil, nil},
+		{"UnitFreq", etensor.FLOAT64, nil, nil},
+		{"ThetaUnits", etensor.FLOAT64, nil, nil},
+		{"ThetaPow", etensor.FLOAT64, nil, nil},
+		{"PeakFreq", etensor.FLOAT64, nil, nil},
+		{"LayThetaPow", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigRhythmPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Emery Rhythm Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.Params.LegendCol = "Layer"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NActive", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Rhythmic", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("ACPeak", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("ACPeakLag", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("UnitFreq", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("ThetaUnits", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("ThetaPow", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("PeakFreq", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("LayThetaPow", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
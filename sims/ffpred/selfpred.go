@@ -0,0 +1,132 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/emer/axon/axon"
+	"github.com/emer/emergent/emer"
+	"github.com/emer/emergent/prjn"
+	"github.com/emer/etable/etensor"
+)
+
+// SelfPred is an optional auxiliary objective, in addition to the V2WdP
+// pulvinar targets, where superficial hidden layers learn to predict their
+// own next-step activity, in the manner of temporal contrastive or
+// predictive coding objectives.  Each of the Lays gets a self-prediction
+// target layer (e.g., MSTdP for MSTd), driven by its CT layer, which carries
+// the context of the previous trial, with the minus phase activity of the
+// layer on the current trial as its plus phase target.  The error projects
+// back to the super and CT layers through FmSelfP prjns, as for FmPulv.
+type SelfPred struct {
+	Lays []string `desc:"superficial hidden layers that predict their own next-step activity from their CT layer, e.g., MSTd -- empty = off -- only takes effect when the network is configured"`
+
+	PLays      []string                    `view:"-" desc:"self-prediction target layers, named as the Lays with a P suffix"`
+	Tsrs       map[string]*etensor.Float32 `view:"-" desc:"minus phase activity of each of the Lays, by name"`
+	TrlCosDiff float64                     `inactive:"+" desc:"current trial's cosine difference of the self-prediction layers"`
+	EpcCosDiff float64                     `inactive:"+" desc:"last epoch's cosine difference of the self-prediction layers"`
+	SumCosDiff float64                     `view:"-" desc:"sum of TrlCosDiff over the epoch"`
+	N          int                         `view:"-" desc:"number of trials in SumCosDiff"`
+}
+
+// On returns true if any layers predict their own next-step activity
+func (sp *SelfPred) On() bool {
+	return len(sp.Lays) > 0
+}
+
+// IsPLay returns true if given layer is a self-prediction target layer
+func (sp *SelfPred) IsPLay(lnm string) bool {
+	for _, pnm := range sp.PLays {
+		if pnm == lnm {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset resets the accumulated stats -- called in InitStats
+func (sp *SelfPred) Reset() {
+	sp.TrlCosDiff = 0
+	sp.EpcCosDiff = 0
+	sp.SumCosDiff = 0
+	sp.N = 0
+}
+
+// EpcStats computes the EpcCosDiff from the trials since the last call
+func (sp *SelfPred) EpcStats() float64 {
+	if sp.N > 0 {
+		sp.EpcCosDiff = sp.SumCosDiff / float64(sp.N)
+	}
+	sp.SumCosDiff = 0
+	sp.N = 0
+	return sp.EpcCosDiff
+}
+
+// ConfigSelfPred adds a self-prediction target layer for each of the Lays,
+// with the same shape, and its prjns -- called in ConfigNet
+func (ss *Sim) ConfigSelfPred(net *axon.Network) {
+	sp := &ss.SelfPred
+	sp.PLays = nil
+	p1to1 := prjn.NewPoolOneToOne()
+	for _, lnm := range sp.Lays {
+		sly, err := net.LayerByNameTry(lnm)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		ct, err := net.LayerByNameTry(lnm + "CT")
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		pl := net.AddLayer(lnm+"P", sly.Shape().Shp, emer.Target)
+		pl.SetClass("SelfPred")
+		net.ConnectLayers(ct, pl, p1to1, emer.Forward).SetClass("ToSelfP")
+		net.ConnectLayers(pl, sly, p1to1, emer.Back).SetClass("FmSelfP")
+		net.ConnectLayers(pl, ct, p1to1, emer.Back).SetClass("FmSelfP")
+		sp.PLays = append(sp.PLays, pl.Name())
+	}
+}
+
+// ApplySelfPred applies the minus phase activity of each of the Lays as the
+// target of its self-prediction layer, for the plus phase -- called at the
+// end of the minus phase in ThetaCyc
+func (ss *Sim) ApplySelfPred() {
+	sp := &ss.SelfPred
+	if sp.Tsrs == nil {
+		sp.Tsrs = make(map[string]*etensor.Float32)
+	}
+	for _, pnm := range sp.PLays {
+		lnm := strings.TrimSuffix(pnm, "P")
+		tsr, ok := sp.Tsrs[lnm]
+		if !ok {
+			tsr = &etensor.Float32{}
+			sp.Tsrs[lnm] = tsr
+		}
+		ss.Net.LayerByName(lnm).UnitValsTensor(tsr, "ActM")
+		ss.Net.LayerByName(pnm).(axon.AxonLayer).AsAxon().ApplyExt(tsr)
+	}
+}
+
+// SelfPredStats computes the TrlCosDiff of the self-prediction layers,
+// accumulating it for the epoch if accum -- called in TrialStats
+func (ss *Sim) SelfPredStats(accum bool) {
+	sp := &ss.SelfPred
+	if len(sp.PLays) == 0 {
+		return
+	}
+	acd := 0.0
+	for _, pnm := range sp.PLays {
+		ly := ss.Net.LayerByName(pnm).(axon.AxonLayer).AsAxon()
+		acd += float64(ly.CosDiff.Cos)
+	}
+	sp.TrlCosDiff = acd / float64(len(sp.PLays))
+	if accum {
+		sp.SumCosDiff += sp.TrlCosDiff
+		sp.N++
+	}
+}
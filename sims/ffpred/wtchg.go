@@ -0,0 +1,152 @@
+// Copyright (c) 2021, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// WtChg tracks how much each prjn is learning, to see which pathways learn
+// at any point in training, and to validate the LrateSched drops and the
+// LrateMult multipliers, including 0 to freeze a prjn.  On each training
+// trial (including Replay trials), the L2 norm of the DWt of each prjn is
+// accumulated, and at the end of each epoch its mean over the trials is
+// logged to the WtChgLog with the L2 norm of the cumulative weight change
+// since the start of the run, and the effective learning rate.
+type WtChg struct {
+	On bool `desc:"track the weight changes of each prjn"`
+
+	Wts    map[string][]float32 `view:"-" desc:"weights of each prjn at the start of the run"`
+	SumDWt map[string]float64   `view:"-" desc:"sum of the L2 norm of DWt of each prjn over the trials of the epoch"`
+	N      int                  `view:"-" desc:"number of trials in SumDWt"`
+}
+
+// WtChgInit records the weights at the start of the run, and clears the
+// sums -- called in NewRun after the weights are initialized
+func (ss *Sim) WtChgInit() {
+	wc := &ss.WtChg
+	if !wc.On {
+		return
+	}
+	wc.Wts = make(map[string][]float32)
+	wc.SumDWt = make(map[string]float64)
+	wc.N = 0
+	for _, pj := range ss.AllPrjns() {
+		wts := make([]float32, len(pj.Syns))
+		for si := range pj.Syns {
+			wts[si] = pj.Syns[si].Wt
+		}
+		wc.Wts[pj.Name()] = wts
+	}
+}
+
+// WtChgTrial accumulates the L2 norm of the DWt of each prjn on the
+// current trial -- called in ThetaCyc after DWt
+func (ss *Sim) WtChgTrial() {
+	wc := &ss.WtChg
+	if !wc.On || wc.SumDWt == nil {
+		return
+	}
+	for _, pj := range ss.AllPrjns() {
+		sum := 0.0
+		for si := range pj.Syns {
+			dw := float64(pj.Syns[si].DWt)
+			sum += dw * dw
+		}
+		wc.SumDWt[pj.Name()] += math.Sqrt(sum)
+	}
+	wc.N++
+}
+
+// LogWtChg adds a row per prjn of the weight changes over the epoch just
+// finished to dt, and clears the sums -- called after LogTrnEpc
+func (ss *Sim) LogWtChg(dt *etable.Table) {
+	wc := &ss.WtChg
+	if !wc.On || wc.SumDWt == nil {
+		return
+	}
+	epc := ss.TrainEnv.Epoch.Prv // this is triggered by increment so use previous value
+	for _, pj := range ss.AllPrjns() {
+		nm := pj.Name()
+		wts := wc.Wts[nm]
+		var chg, w0 float64
+		if len(wts) == len(pj.Syns) {
+			for si := range pj.Syns {
+				d := float64(pj.Syns[si].Wt - wts[si])
+				chg += d * d
+				w0 += float64(wts[si]) * float64(wts[si])
+			}
+		}
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+		dt.SetCellFloat("Epoch", row, float64(epc))
+		dt.SetCellString("Prjn", row, nm)
+		dt.SetCellFloat("Lrate", row, float64(pj.Learn.Lrate.Eff))
+		if wc.N > 0 {
+			dt.SetCellFloat("DWt", row, wc.SumDWt[nm]/float64(wc.N))
+		}
+		dt.SetCellFloat("WtChg", row, math.Sqrt(chg))
+		if w0 > 0 {
+			dt.SetCellFloat("RelWtChg", row, math.Sqrt(chg/w0))
+		}
+		wc.SumDWt[nm] = 0
+	}
+	wc.N = 0
+
+	// note: essential to use Go version of update when called from another goroutine
+	if ss.WtChgPlot != nil {
+		ss.WtChgPlot.GoUpdate()
+	}
+}
+
+// SaveWtChgLog saves the WtChgLog of the run, if any -- called in RunEnd
+func (ss *Sim) SaveWtChgLog() {
+	if !ss.WtChg.On || ss.WtChgLog.Rows == 0 {
+		return
+	}
+	fnm := ss.LogFileName(fmt.Sprintf("wtchg_run%03d", ss.TrainEnv.Run.Cur))
+	ss.WtChgLog.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
+}
+
+func (ss *Sim) ConfigWtChgLog(dt *etable.Table) {
+	dt.SetMetaData("name", "WtChgLog")
+	dt.SetMetaData("desc", "Mean L2 norm of DWt per trial, and L2 norm of cumulative weight change since the start of the run, per prjn over epochs of training")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Prjn", etensor.STRING, nil, nil},
+		{"Lrate", etensor.FLOAT64, nil, nil},
+		{"DWt", etensor.FLOAT64, nil, nil},
+		{"WtChg", etensor.FLOAT64, nil, nil},
+		{"RelWtChg", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigWtChgPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Emery Weight Change Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.Params.LegendCol = "Prjn"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Lrate", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("DWt", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("WtChg", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("RelWtChg", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	return plt
+}
@@ -984,16 +984,18 @@ func (ss *Sim) TestTrial(returnOnChg bool) {
 	// ss.LogTstTrl(ss.TstTrlLog)
 }
 
-// TestAll runs through the full set of testing items
+// TestAll runs through the full set of testing items.
+// There is no TestEnv yet, so this does nothing: looping on TestTrial
+// without its epoch counter would never end.
 func (ss *Sim) TestAll() {
 	// ss.TestEnv.Init(ss.TrainEnv.Run.Cur)
-	for {
-		ss.TestTrial(true) // return on change -- don't wrap
-		// _, _, chg := ss.TestEnv.Counter(env.Epoch)
-		// if chg || ss.StopNow {
-		// 	break
-		// }
-	}
+	// for {
+	// 	ss.TestTrial(true) // return on change -- don't wrap
+	// 	_, _, chg := ss.TestEnv.Counter(env.Epoch)
+	// 	if chg || ss.StopNow {
+	// 		break
+	// 	}
+	// }
 }
 
 // RunTestAll runs through the full set of testing items, has stop running = false at end -- for gui
@@ -0,0 +1,195 @@
+// Copyright (c) 2021, The CCNLab Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package netlayout sets the relative positions (SetRelPos) of the layers of a
+network automatically from its projection topology, so that layers do not
+have to be placed by hand in each ConfigNet, which breaks whenever layers
+are added or removed.
+
+Layers are assigned to levels by the longest path of Forward projections
+from the input layers, which have level 0, and each level is laid out as a
+row of layers RightOf each other, in the order they were added to the
+network, Above the row of the level below.  Layers sharing one of the Groups
+classes (e.g., the superficial, CT and pulvinar layers of one deep area) are
+stacked Behind the first layer of the group, which is placed by its level.
+Levels can be pinned by layer name, and any layer can be given a manual
+relpos.Rel in Overrides, which is used as-is.
+
+Call Apply after all projections have been made, and before Build:
+
+	lay := netlayout.Layout{}
+	lay.Defaults()
+	lay.Groups = []string{"MSTd"}
+	if err := lay.Apply(net); err != nil {
+		log.Println(err)
+	}
+*/
+package netlayout
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/emergent/relpos"
+)
+
+// Layout has the parameters for laying out a network by Apply
+type Layout struct {
+	Space       float32               `desc:"space between layers in a row (RightOf)"`
+	LevelSpace  float32               `desc:"space between rows of levels (Above)"`
+	BehindSpace float32               `desc:"space between the layers of a group (Behind)"`
+	Groups      []string              `desc:"classes whose layers are stacked Behind the first layer of the class, in order added"`
+	Levels      map[string]int        `desc:"levels pinned by layer name, instead of computed from Forward projections"`
+	Overrides   map[string]relpos.Rel `desc:"manual relative positions by layer name, used instead of the automatic ones"`
+}
+
+// Defaults sets default params
+func (lo *Layout) Defaults() {
+	lo.Space = 4
+	lo.LevelSpace = 0
+	lo.BehindSpace = 4
+}
+
+// Validate returns an error if any of the Levels is negative or pins a layer
+// that is not in net
+func (lo *Layout) Validate(net emer.Network) error {
+	nms := make([]string, 0, len(lo.Levels))
+	for lnm := range lo.Levels {
+		nms = append(nms, lnm)
+	}
+	sort.Strings(nms)
+	for _, lnm := range nms {
+		if lv := lo.Levels[lnm]; lv < 0 {
+			return fmt.Errorf("netlayout: Levels: layer %s has negative level %d", lnm, lv)
+		}
+		if net.LayerByName(lnm) == nil {
+			return fmt.Errorf("netlayout: Levels: layer %s not found", lnm)
+		}
+	}
+	return nil
+}
+
+// LayerLevels returns the level of each layer in net, by layer index: 0 for
+// input layers and layers without Forward inputs, otherwise one more than the
+// highest level of the layers sending Forward projections to it, unless
+// pinned in Levels.  Cycles of Forward projections are cut off after as many
+// passes as there are layers.
+func (lo *Layout) LayerLevels(net emer.Network) []int {
+	nl := net.NLayers()
+	idxs := make(map[string]int, nl)
+	for li := 0; li < nl; li++ {
+		idxs[net.Layer(li).Name()] = li
+	}
+	levs := make([]int, nl)
+	for li := 0; li < nl; li++ {
+		if lv, has := lo.Levels[net.Layer(li).Name()]; has {
+			levs[li] = lv
+		}
+	}
+	for pass := 0; pass < nl; pass++ {
+		chg := false
+		for li := 0; li < nl; li++ {
+			ly := net.Layer(li)
+			if _, has := lo.Levels[ly.Name()]; has || ly.Type() == emer.Input {
+				continue
+			}
+			lv := 0
+			for pi := 0; pi < ly.NRecvPrjns(); pi++ {
+				pj := ly.RecvPrjn(pi)
+				if pj.Type() != emer.Forward {
+					continue
+				}
+				si, has := idxs[pj.SendLay().Name()]
+				if !has || si == li {
+					continue
+				}
+				if levs[si]+1 > lv {
+					lv = levs[si] + 1
+				}
+			}
+			if lv != levs[li] {
+				levs[li] = lv
+				chg = true
+			}
+		}
+		if !chg {
+			break
+		}
+	}
+	return levs
+}
+
+// Group returns the first of the Groups classes of given layer, or "" if none
+func (lo *Layout) Group(ly emer.Layer) string {
+	cls := strings.Fields(ly.Class())
+	for _, gp := range lo.Groups {
+		for _, cl := range cls {
+			if cl == gp {
+				return gp
+			}
+		}
+	}
+	return ""
+}
+
+// Apply sets the RelPos of all the layers in net -- call after all
+// projections have been made, and before Build.  Returns the Validate error,
+// without setting any RelPos, if the Levels are invalid.
+func (lo *Layout) Apply(net emer.Network) error {
+	if err := lo.Validate(net); err != nil {
+		return err
+	}
+	levs := lo.LayerLevels(net)
+	maxLev := 0
+	for _, lv := range levs {
+		if lv > maxLev {
+			maxLev = lv
+		}
+	}
+	rows := make([][]emer.Layer, maxLev+1)
+	gpLast := make(map[string]string) // last layer name of each group
+	for li, lv := range levs {
+		ly := net.Layer(li)
+		if _, has := lo.Overrides[ly.Name()]; has {
+			continue
+		}
+		gp := lo.Group(ly)
+		if gp != "" {
+			if prv, has := gpLast[gp]; has {
+				ly.SetRelPos(relpos.Rel{Rel: relpos.Behind, Other: prv, XAlign: relpos.Left, Space: lo.BehindSpace})
+				gpLast[gp] = ly.Name()
+				continue
+			}
+			gpLast[gp] = ly.Name()
+		}
+		rows[lv] = append(rows[lv], ly)
+	}
+
+	below := ""
+	for _, row := range rows {
+		for i, ly := range row {
+			switch {
+			case i > 0:
+				ly.SetRelPos(relpos.Rel{Rel: relpos.RightOf, Other: row[i-1].Name(), YAlign: relpos.Front, Space: lo.Space})
+			case below != "":
+				ly.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: below, XAlign: relpos.Left, YAlign: relpos.Front, Space: lo.LevelSpace})
+			default:
+				ly.SetRelPos(relpos.Rel{Rel: relpos.NoRel})
+			}
+		}
+		if len(row) > 0 {
+			below = row[0].Name()
+		}
+	}
+
+	for lnm, rel := range lo.Overrides {
+		if ly := net.LayerByName(lnm); ly != nil {
+			ly.SetRelPos(rel)
+		}
+	}
+	return nil
+}
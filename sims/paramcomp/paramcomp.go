@@ -0,0 +1,293 @@
+// Copyright (c) 2021, The CCNLab Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package paramcomp composes the params.Set applied by a sim from a ParamSet
+spec, instead of only layering named sets on Base, so that the tuning
+variants of the sims can be built from each other and tweaked without adding
+a new set for every combination.
+
+A spec is a list of terms separated by + (or spaces, as before), each of
+which is either the name of a set, or a numeric override of a param:
+
+	VestDelay+LongPlus
+	Base+Gi=1.6
+	LongPlus+#EC:Layer.Inhib.Layer.Gi=1.8+Prjn.Learn.Lrate=0.02
+
+Sets can declare the sets they build on in Deps, which are applied before
+them, recursively, and each set is applied only once, with Base always
+first.  An override Param=Value sets the value of every param already in the
+composed set whose path is Param or ends in .Param (e.g., Gi matches both
+Layer.Inhib.Layer.Gi and Layer.Inhib.Pool.Gi), for each Sel that has it, and
+is an error if there are none.  An override Sel:Param=Value sets the param
+for that Sel, in the sheet that has the Sel, or Network otherwise.
+
+The composed set has the Sels of each sheet of all the sets in order of
+application, followed by the override Sels, so that the last Sel setting a
+param wins as usual, with the name of the set each came from as a [Name]
+prefix of its Desc (see SelSet), and it can be saved to a file with its
+SaveJSON method, to record exactly what a run used.  FileName makes a spec
+usable in the names of log files:
+
+	pset, err := paramcomp.Compose(ss.Params, ParamDeps, ss.ParamSet)
+*/
+package paramcomp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emer/emergent/params"
+)
+
+// Deps maps the name of a param set to the names of the sets it builds on,
+// which are applied before it
+type Deps map[string][]string
+
+// Override is a numeric override of a param in the composed set
+type Override struct {
+	Sel   string `desc:"selector of the Sel to set the param for -- empty = every Sel with the param"`
+	Param string `desc:"param path, or the last part(s) of it if Sel is empty, e.g., Gi"`
+	Val   string `desc:"value, which must be a number"`
+}
+
+// String returns the override in spec form
+func (ov *Override) String() string {
+	if ov.Sel == "" {
+		return ov.Param + "=" + ov.Val
+	}
+	return ov.Sel + ":" + ov.Param + "=" + ov.Val
+}
+
+// Matches returns true if given param path is selected by the Param of the
+// override
+func (ov *Override) Matches(path string) bool {
+	return path == ov.Param || strings.HasSuffix(path, "."+ov.Param)
+}
+
+// Spec is a parsed ParamSet spec
+type Spec struct {
+	Sets      []string   `desc:"names of the sets, in order of application, with their dependencies, starting with Base"`
+	Overrides []Override `desc:"overrides applied after all the sets, in order"`
+}
+
+// ParseOverride parses an override term of a spec: [Sel:]Param=Value
+func ParseOverride(term string) (Override, error) {
+	var ov Override
+	eq := strings.Index(term, "=")
+	pth := term[:eq]
+	ov.Val = term[eq+1:]
+	if ci := strings.LastIndex(pth, ":"); ci >= 0 {
+		ov.Sel = pth[:ci]
+		pth = pth[ci+1:]
+		if ov.Sel == "" {
+			return ov, fmt.Errorf("paramcomp: override %q: empty Sel", term)
+		}
+	}
+	ov.Param = pth
+	if ov.Param == "" {
+		return ov, fmt.Errorf("paramcomp: override %q: empty Param", term)
+	}
+	if _, err := strconv.ParseFloat(ov.Val, 64); err != nil {
+		return ov, fmt.Errorf("paramcomp: override %q: value is not a number", term)
+	}
+	return ov, nil
+}
+
+// ParseSpec parses given ParamSet spec, resolving the dependencies of its
+// sets in deps
+func ParseSpec(spec string, deps Deps) (*Spec, error) {
+	sp := &Spec{}
+	var nms []string
+	for _, term := range strings.FieldsFunc(spec, func(r rune) bool { return r == '+' || r == ' ' || r == '\t' }) {
+		if strings.Contains(term, "=") {
+			ov, err := ParseOverride(term)
+			if err != nil {
+				return nil, err
+			}
+			sp.Overrides = append(sp.Overrides, ov)
+			continue
+		}
+		nms = append(nms, term)
+	}
+	sets, err := deps.Resolve(nms)
+	if err != nil {
+		return nil, err
+	}
+	sp.Sets = sets
+	return sp, nil
+}
+
+// Resolve returns given set names in order of application: Base first, and
+// each set after the sets it depends on, recursively, and only once.
+// Returns an error for a dependency cycle.
+func (dp Deps) Resolve(nms []string) ([]string, error) {
+	order := []string{"Base"}
+	done := map[string]bool{"Base": true}
+	visiting := map[string]bool{}
+	var visit func(nm string, path []string) error
+	visit = func(nm string, path []string) error {
+		if done[nm] {
+			return nil
+		}
+		path = append(path, nm)
+		if visiting[nm] {
+			return fmt.Errorf("paramcomp: dependency cycle: %s", strings.Join(path, " -> "))
+		}
+		visiting[nm] = true
+		for _, dn := range dp[nm] {
+			if err := visit(dn, path); err != nil {
+				return err
+			}
+		}
+		visiting[nm] = false
+		done[nm] = true
+		order = append(order, nm)
+		return nil
+	}
+	for _, nm := range nms {
+		if err := visit(nm, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// String returns the fully resolved spec, with all the sets, e.g., as the
+// name of the composed set
+func (sp *Spec) String() string {
+	terms := append([]string{}, sp.Sets...)
+	for i := range sp.Overrides {
+		terms = append(terms, sp.Overrides[i].String())
+	}
+	return strings.Join(terms, "+")
+}
+
+// Compose returns the set composed from the Sets of the spec in sets, and
+// the Overrides.  The Sels are copied, so sets is not modified.
+func (sp *Spec) Compose(sets params.Sets) (*params.Set, error) {
+	cs := &params.Set{Name: sp.String(), Desc: "composed by paramcomp from: " + sp.String(), Sheets: params.Sheets{}}
+	for _, nm := range sp.Sets {
+		pset, err := sets.SetByNameTry(nm)
+		if err != nil {
+			return nil, err
+		}
+		for shnm, sh := range pset.Sheets {
+			csh := cs.Sheets[shnm]
+			if csh == nil {
+				csh = &params.Sheet{}
+				cs.Sheets[shnm] = csh
+			}
+			for _, sl := range *sh {
+				*csh = append(*csh, CopySel(sl, "["+nm+"] "+sl.Desc))
+			}
+		}
+	}
+	for i := range sp.Overrides {
+		if err := Apply(cs, &sp.Overrides[i]); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+// SelSet returns the name of the set that given Sel of a composed set came
+// from, recorded as a [Name] prefix of its Desc ("override" for the override
+// Sels), or def if it has none
+func SelSet(sl *params.Sel, def string) string {
+	if !strings.HasPrefix(sl.Desc, "[") {
+		return def
+	}
+	if ei := strings.Index(sl.Desc, "] "); ei > 1 {
+		return sl.Desc[1:ei]
+	}
+	return def
+}
+
+// CopySel returns a copy of given Sel, with given Desc
+func CopySel(sl *params.Sel, desc string) *params.Sel {
+	cp := &params.Sel{Sel: sl.Sel, Desc: desc, Params: make(params.Params, len(sl.Params))}
+	for pnm, pv := range sl.Params {
+		cp.Params[pnm] = pv
+	}
+	return cp
+}
+
+// Apply adds the Sels setting given override to the composed set cs
+func Apply(cs *params.Set, ov *Override) error {
+	desc := "[override] " + ov.String()
+	shnms := make([]string, 0, len(cs.Sheets))
+	for shnm := range cs.Sheets {
+		shnms = append(shnms, shnm)
+	}
+	sort.Strings(shnms)
+	if ov.Sel != "" {
+		tsh := "Network"
+		for _, shnm := range shnms {
+			if cs.Sheets[shnm].SelByName(ov.Sel) != nil {
+				tsh = shnm
+				break
+			}
+		}
+		sh := cs.Sheets[tsh]
+		if sh == nil {
+			sh = &params.Sheet{}
+			cs.Sheets[tsh] = sh
+		}
+		*sh = append(*sh, &params.Sel{Sel: ov.Sel, Desc: desc, Params: params.Params{ov.Param: ov.Val}})
+		return nil
+	}
+	nset := 0
+	for _, shnm := range shnms {
+		sh := cs.Sheets[shnm]
+		var osls []*params.Sel
+		omap := map[string]*params.Sel{} // override Sels by selector
+		for _, sl := range *sh {
+			for pnm := range sl.Params {
+				if !ov.Matches(pnm) {
+					continue
+				}
+				osl, has := omap[sl.Sel]
+				if !has {
+					osl = &params.Sel{Sel: sl.Sel, Desc: desc, Params: params.Params{}}
+					omap[sl.Sel] = osl
+					osls = append(osls, osl)
+				}
+				if _, has := osl.Params[pnm]; !has {
+					nset++
+				}
+				osl.Params[pnm] = ov.Val
+			}
+		}
+		*sh = append(*sh, osls...)
+	}
+	if nset == 0 {
+		return fmt.Errorf("paramcomp: override %s: no param matches %s", ov.String(), ov.Param)
+	}
+	return nil
+}
+
+// FileName returns given spec as a name that can be used in file names,
+// with each character other than a letter, digit, - or _ replaced by _
+func FileName(spec string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, spec)
+}
+
+// Compose parses given ParamSet spec, resolving dependencies in deps, and
+// returns the set composed from it in sets
+func Compose(sets params.Sets, deps Deps, spec string) (*params.Set, error) {
+	sp, err := ParseSpec(spec, deps)
+	if err != nil {
+		return nil, err
+	}
+	return sp.Compose(sets)
+}
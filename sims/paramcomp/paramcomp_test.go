@@ -0,0 +1,206 @@
+// Copyright (c) 2021, The CCNLab Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package paramcomp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emer/emergent/params"
+)
+
+var testSets = params.Sets{
+	{Name: "Base", Desc: "base", Sheets: params.Sheets{
+		"Network": &params.Sheet{
+			{Sel: "Layer", Desc: "all layers",
+				Params: params.Params{
+					"Layer.Inhib.Layer.Gi": "1.8",
+					"Layer.Inhib.Pool.Gi":  "1.8",
+				}},
+			{Sel: "Prjn", Desc: "all prjns",
+				Params: params.Params{
+					"Prjn.Learn.Lrate": "0.04",
+				}},
+		},
+		"Sim": &params.Sheet{
+			{Sel: "Sim", Desc: "sim",
+				Params: params.Params{
+					"Sim.PlusQtrs": "1",
+				}},
+		},
+	}},
+	{Name: "A", Desc: "a", Sheets: params.Sheets{
+		"Network": &params.Sheet{
+			{Sel: "#EC", Desc: "ec",
+				Params: params.Params{
+					"Layer.Inhib.Layer.Gi": "2",
+				}},
+		},
+	}},
+	{Name: "B", Desc: "b", Sheets: params.Sheets{
+		"LrateMult": &params.Sheet{
+			{Sel: "#Out", Desc: "out",
+				Params: params.Params{
+					"Prjn.LrateMult": "2",
+				}},
+		},
+	}},
+}
+
+func TestParseOverride(t *testing.T) {
+	tests := []struct {
+		term string
+		want Override
+		err  bool
+	}{
+		{"Gi=1.6", Override{Param: "Gi", Val: "1.6"}, false},
+		{"#EC:Layer.Inhib.Layer.Gi=1.8", Override{Sel: "#EC", Param: "Layer.Inhib.Layer.Gi", Val: "1.8"}, false},
+		{"Gi=high", Override{}, true},
+		{"=1", Override{}, true},
+		{":Gi=1", Override{}, true},
+	}
+	for _, tt := range tests {
+		ov, err := ParseOverride(tt.term)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseOverride(%q) error = %v, want error %v", tt.term, err, tt.err)
+			continue
+		}
+		if err == nil && ov != tt.want {
+			t.Errorf("ParseOverride(%q) = %+v, want %+v", tt.term, ov, tt.want)
+		}
+		if err == nil && ov.String() != tt.term {
+			t.Errorf("ParseOverride(%q).String() = %q", tt.term, ov.String())
+		}
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	deps := Deps{"B": {"A"}}
+	tests := []struct {
+		spec string
+		sets []string
+		nov  int
+		str  string
+	}{
+		{"", []string{"Base"}, 0, "Base"},
+		{"A", []string{"Base", "A"}, 0, "Base+A"},
+		{"B", []string{"Base", "A", "B"}, 0, "Base+A+B"},
+		{"B A Base", []string{"Base", "A", "B"}, 0, "Base+A+B"},
+		{"A+Gi=1.6+#EC:Gi=2", []string{"Base", "A"}, 2, "Base+A+Gi=1.6+#EC:Gi=2"},
+	}
+	for _, tt := range tests {
+		sp, err := ParseSpec(tt.spec, deps)
+		if err != nil {
+			t.Errorf("ParseSpec(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(sp.Sets, tt.sets) || len(sp.Overrides) != tt.nov {
+			t.Errorf("ParseSpec(%q) = %v, %d overrides, want %v, %d", tt.spec, sp.Sets, len(sp.Overrides), tt.sets, tt.nov)
+		}
+		if sp.String() != tt.str {
+			t.Errorf("ParseSpec(%q).String() = %q, want %q", tt.spec, sp.String(), tt.str)
+		}
+	}
+	if _, err := ParseSpec("A+Gi=x", deps); err == nil {
+		t.Errorf("ParseSpec with a bad override: no error")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	deps := Deps{"C": {"B", "A"}, "B": {"A"}}
+	got, err := deps.Resolve([]string{"C", "B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Base", "A", "B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve = %v, want %v", got, want)
+	}
+	cyc := Deps{"A": {"B"}, "B": {"A"}}
+	if _, err := cyc.Resolve([]string{"A"}); err == nil || !strings.Contains(err.Error(), "paramcomp: dependency cycle") {
+		t.Errorf("Resolve of a cycle: error = %v", err)
+	}
+}
+
+func TestCompose(t *testing.T) {
+	cs, err := Compose(testSets, Deps{"B": {"A"}}, "B+Gi=1.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.Name != "Base+A+B+Gi=1.6" {
+		t.Errorf("Name = %q", cs.Name)
+	}
+	net := *cs.Sheets["Network"]
+	// Layer, Prjn from Base, #EC from A, then the Gi override of Layer and #EC
+	if len(net) != 5 {
+		t.Fatalf("Network has %d Sels, want 5", len(net))
+	}
+	if SelSet(net[0], "") != "Base" || SelSet(net[2], "") != "A" || SelSet(net[3], "") != "override" {
+		t.Errorf("SelSet = %s, %s, %s", SelSet(net[0], ""), SelSet(net[2], ""), SelSet(net[3], ""))
+	}
+	if net[3].Sel != "Layer" || net[3].Params["Layer.Inhib.Layer.Gi"] != "1.6" || net[3].Params["Layer.Inhib.Pool.Gi"] != "1.6" {
+		t.Errorf("override of Layer = %v %v", net[3].Sel, net[3].Params)
+	}
+	if net[4].Sel != "#EC" || net[4].Params["Layer.Inhib.Layer.Gi"] != "1.6" {
+		t.Errorf("override of #EC = %v %v", net[4].Sel, net[4].Params)
+	}
+	if _, has := cs.Sheets["LrateMult"]; !has {
+		t.Errorf("no LrateMult sheet from B")
+	}
+	base, _ := testSets.SetByNameTry("Base")
+	if base.Sheets["Network"].SelByName("Layer").Params["Layer.Inhib.Layer.Gi"] != "1.8" {
+		t.Errorf("Compose modified the sets")
+	}
+	if _, err := Compose(testSets, nil, "C"); err == nil {
+		t.Errorf("Compose of a missing set: no error")
+	}
+}
+
+func TestApply(t *testing.T) {
+	compose := func() *params.Set {
+		cs, err := Compose(testSets, nil, "B")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cs
+	}
+	cs := compose()
+	if err := Apply(cs, &Override{Param: "Gbar.L", Val: "0.2"}); err == nil || !strings.Contains(err.Error(), "no param matches") {
+		t.Errorf("Apply of an unmatched param: error = %v", err)
+	}
+	if err := Apply(cs, &Override{Param: "Inhib.Gi", Val: "1"}); err == nil {
+		t.Errorf("Apply of a partial path component: no error")
+	}
+	if err := Apply(cs, &Override{Param: "Lrate", Val: "0.02"}); err != nil {
+		t.Error(err)
+	}
+	net := *cs.Sheets["Network"]
+	if last := net[len(net)-1]; last.Sel != "Prjn" || last.Params["Prjn.Learn.Lrate"] != "0.02" {
+		t.Errorf("override of Lrate = %v %v", last.Sel, last.Params)
+	}
+
+	cs = compose()
+	if err := Apply(cs, &Override{Sel: "#Out", Param: "Prjn.LrateMult", Val: "3"}); err != nil {
+		t.Error(err)
+	}
+	lm := *cs.Sheets["LrateMult"]
+	if len(lm) != 2 || lm[1].Sel != "#Out" || lm[1].Params["Prjn.LrateMult"] != "3" {
+		t.Errorf("LrateMult = %v, want the #Out override appended", lm)
+	}
+	nn := len(*cs.Sheets["Network"])
+	if err := Apply(cs, &Override{Sel: "#Hidden", Param: "Layer.Inhib.Layer.Gi", Val: "1.2"}); err != nil {
+		t.Error(err)
+	}
+	net = *cs.Sheets["Network"]
+	if len(net) != nn+1 || net[nn].Sel != "#Hidden" {
+		t.Errorf("override of a new Sel not appended to Network")
+	}
+}
+
+func TestFileName(t *testing.T) {
+	if got, want := FileName("LongPlus+#EC:Layer.Inhib.Layer.Gi=1.8"), "LongPlus__EC_Layer_Inhib_Layer_Gi_1_8"; got != want {
+		t.Errorf("FileName = %q, want %q", got, want)
+	}
+}